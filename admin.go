@@ -0,0 +1,54 @@
+package main
+
+import (
+	"expvar"
+	"log"
+	"net/http"
+	"net/http/pprof"
+	"time"
+)
+
+// Counters exported on /debug/vars of the admin server.
+var (
+	statTranscriptions = expvar.NewInt("transcriptions")
+	statAudioSeconds   = expvar.NewFloat("audio_seconds")
+
+	statCallbacksFailed   = expvar.NewInt("callbacks_failed")
+	statRequestsCoalesced = expvar.NewInt("requests_coalesced")
+)
+
+// newAdminMux serves net/http/pprof under /debug/pprof/, expvar under
+// /debug/vars, and model management, failed callbacks, and runtime
+// statistics under /admin/. It is kept off the public API mux.
+func newAdminMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/admin/models", handleModels)
+	mux.HandleFunc("/admin/models/reload", handleModelReload)
+	mux.HandleFunc("/admin/callbacks/failed", handleDeadLetters)
+	mux.HandleFunc("/admin/stats", handleStats)
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	return mux
+}
+
+// startAdminServer serves the admin mux on addr in the background.
+// There is no write timeout: CPU profiles and traces stream for as long as
+// the caller asks (?seconds=N).
+func startAdminServer(addr string) *http.Server {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           newAdminMux(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("admin listen: %v", err)
+		}
+	}()
+	log.Printf("Admin server (pprof, expvar) on %s", addr)
+	return srv
+}
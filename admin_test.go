@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// --- newAdminMux ---
+
+func TestAdminMux(t *testing.T) {
+	srv := httptest.NewServer(newAdminMux())
+	defer srv.Close()
+
+	for _, path := range []string{"/debug/pprof/", "/debug/pprof/cmdline", "/debug/vars", "/admin/callbacks/failed", "/admin/stats"} {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		resp.Body.Close() //nolint:errcheck
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("GET %s = %d, want 200", path, resp.StatusCode)
+		}
+	}
+}
+
+func TestAdminMux_Vars(t *testing.T) {
+	srv := httptest.NewServer(newAdminMux())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/debug/vars")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	var vars map[string]json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&vars); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	for _, k := range []string{"transcriptions", "audio_seconds", "memstats"} {
+		if _, ok := vars[k]; !ok {
+			t.Errorf("/debug/vars missing %q", k)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// auditEntry is one line of the audit log: who transcribed which audio,
+// when, with which parameters, and how it ended.
+type auditEntry struct {
+	Time       time.Time         `json:"time"`
+	RequestID  string            `json:"request_id,omitempty"`
+	Tenant     string            `json:"tenant,omitempty"`
+	Source     string            `json:"source"`
+	Audio      string            `json:"audio"`
+	Params     TranscribeRequest `json:"params"`  // without the audio source, which Audio names
+	Outcome    string            `json:"outcome"` // "ok" or "error"
+	Error      string            `json:"error,omitempty"`
+	Language   string            `json:"language,omitempty"`
+	AudioS     float64           `json:"audio_s,omitempty"`
+	DurationMs float64           `json:"duration_ms"`
+	Cached     bool              `json:"cached,omitempty"`
+	Text       string            `json:"text,omitempty"` // with AUDIT_LOG_TEXT only
+}
+
+// auditLog appends entries as JSON lines; nil when AUDIT_LOG is unset.
+var auditLog *auditWriter
+
+type auditWriter struct {
+	mu       sync.Mutex
+	w        io.WriteCloser
+	withText bool // record the transcript text
+}
+
+// openAuditLog opens the audit log at path for appending, creating it
+// readable by its owner only.
+func openAuditLog(path string, withText bool) (*auditWriter, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &auditWriter{w: f, withText: withText}, nil
+}
+
+// record appends e as one line.
+func (a *auditWriter) record(e auditEntry) {
+	line, err := json.Marshal(e)
+	if err != nil {
+		log.Printf("WARNING: audit log: %v", err)
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, err := a.w.Write(append(line, '\n')); err != nil {
+		log.Printf("WARNING: audit log: %v", err)
+	}
+}
+
+// Close closes the audit log.
+func (a *auditWriter) Close() error { return a.w.Close() }
+
+// auditTranscription records a finished transcription, failed or not, in
+// the audit log when AUDIT_LOG is set. audio names the input; the
+// transcript text is left out unless AUDIT_LOG_TEXT is set.
+func auditTranscription(ctx context.Context, source, audio string, req TranscribeRequest, resp TranscribeResponse) {
+	if auditLog == nil {
+		return
+	}
+	req.AudioPath, req.AudioURL, req.AudioBase64 = "", "", ""
+	e := auditEntry{
+		Time:       time.Now().UTC(),
+		RequestID:  requestID(ctx),
+		Tenant:     tenantName(ctx),
+		Source:     source,
+		Audio:      audio,
+		Params:     req,
+		Outcome:    "ok",
+		Error:      resp.Error,
+		Language:   resp.Language,
+		AudioS:     resp.AudioS,
+		DurationMs: resp.DurationMs,
+		Cached:     resp.Cached,
+	}
+	if resp.Error != "" {
+		e.Outcome = "error"
+	}
+	if auditLog.withText {
+		e.Text = resp.Text
+	}
+	auditLog.record(e)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// setTestAuditLog writes the audit log to a temp file for one test and
+// returns its path.
+func setTestAuditLog(t *testing.T, withText bool) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	a, err := openAuditLog(path, withText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := auditLog
+	auditLog = a
+	t.Cleanup(func() {
+		a.Close() //nolint:errcheck
+		auditLog = old
+	})
+	return path
+}
+
+// readAuditLog returns the entries of the audit log at path.
+func readAuditLog(t *testing.T, path string) []auditEntry {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close() //nolint:errcheck
+	var entries []auditEntry
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var e auditEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			t.Fatalf("line %q: %v", sc.Text(), err)
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// --- auditTranscription ---
+
+func TestAuditTranscription(t *testing.T) {
+	path := setTestAuditLog(t, false)
+	ctx := withRequestID(withTenant(context.Background(), &tenant{TenantConfig: TenantConfig{Name: "acme"}}), "r1")
+	req := TranscribeRequest{AudioURL: "https://example.com/a.wav?sig=secret", Language: "ru", Diarize: true}
+	recordTranscript(ctx, sourceTranscribe, req.audioName(), req, &TranscribeResponse{Text: "привет", Language: "ru", AudioS: 2, DurationMs: 30})
+	recordTranscript(context.Background(), sourceUpload, "b.wav", TranscribeRequest{}, &TranscribeResponse{Error: "audio too long"})
+
+	entries := readAuditLog(t, path)
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	ok, failed := entries[0], entries[1]
+	if ok.RequestID != "r1" || ok.Tenant != "acme" || ok.Source != sourceTranscribe || ok.Audio != "https://example.com/a.wav" ||
+		ok.Outcome != "ok" || ok.Language != "ru" || ok.AudioS != 2 || ok.Time.IsZero() {
+		t.Errorf("entry = %+v", ok)
+	}
+	if ok.Params.AudioURL != "" || ok.Params.Language != "ru" || !ok.Params.Diarize {
+		t.Errorf("params = %+v, want the options without the audio source", ok.Params)
+	}
+	if ok.Text != "" {
+		t.Errorf("text %q logged without AUDIT_LOG_TEXT", ok.Text)
+	}
+	if failed.Outcome != "error" || failed.Error != "audio too long" || failed.Audio != "b.wav" {
+		t.Errorf("failed entry = %+v", failed)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("audit log mode = %v (%v), want 0600", info.Mode().Perm(), err)
+	}
+}
+
+func TestAuditTranscription_Text(t *testing.T) {
+	path := setTestAuditLog(t, true)
+	recordTranscript(context.Background(), sourcePCM, "", TranscribeRequest{}, &TranscribeResponse{Text: "hello"})
+	if entries := readAuditLog(t, path); len(entries) != 1 || entries[0].Text != "hello" {
+		t.Errorf("entries = %+v, want the text", entries)
+	}
+}
+
+func TestAuditTranscription_Disabled(t *testing.T) {
+	old := auditLog
+	auditLog = nil
+	t.Cleanup(func() { auditLog = old })
+	recordTranscript(context.Background(), sourcePCM, "", TranscribeRequest{}, &TranscribeResponse{Text: "hello"})
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/anatolykoptev/moonshine-whisper/pkg/moonshine"
+)
+
+// benchOptions are the parsed flags of the bench subcommand.
+type benchOptions struct {
+	configPath  string
+	runs        int
+	concurrency int
+	langs       []string // nil=every model with reference audio
+	json        bool
+	files       []string // reference audio; none=the test_wavs of each model
+}
+
+// parseBench parses the bench subcommand arguments. Usage errors are
+// reported on stderr.
+func parseBench(args []string, stderr io.Writer) (benchOptions, error) {
+	flags := flag.NewFlagSet("bench", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: moonshine-whisper bench [flags] [reference audio files...]")
+		flags.PrintDefaults()
+	}
+	var o benchOptions
+	flags.StringVar(&o.configPath, "config", os.Getenv("MOONSHINE_CONFIG"), "path to YAML config file (env vars override it)")
+	flags.IntVar(&o.runs, "n", 10, "decodes of each reference file per model")
+	flags.IntVar(&o.concurrency, "concurrency", 1, "decodes run at once")
+	langs := flags.String("language", "", "comma-separated languages to benchmark (default: every model with test_wavs, or en for given files)")
+	flags.BoolVar(&o.json, "json", false, "print one JSON report per model instead of a table")
+	if err := flags.Parse(args); err != nil {
+		return o, err
+	}
+	if o.runs <= 0 {
+		return o, fmt.Errorf("-n must be > 0, got %d", o.runs)
+	}
+	if o.concurrency <= 0 {
+		return o, fmt.Errorf("-concurrency must be > 0, got %d", o.concurrency)
+	}
+	for _, lang := range strings.Split(*langs, ",") {
+		if strings.TrimSpace(lang) != "" {
+			o.langs = append(o.langs, normLang(lang))
+		}
+	}
+	if flags.NArg() > 0 {
+		o.files = flags.Args()
+		if o.langs == nil {
+			o.langs = []string{"en"}
+		}
+	}
+	return o, nil
+}
+
+// benchReport summarizes the decodes of one model.
+type benchReport struct {
+	Language  string `json:"language"`
+	Backend   string `json:"backend"`
+	Files     int    `json:"files"`
+	Decodes   int    `json:"decodes"`
+	Errors    int    `json:"errors"`
+	LatencyMs struct {
+		P50 float64 `json:"p50"`
+		P90 float64 `json:"p90"`
+		P99 float64 `json:"p99"`
+	} `json:"latency_ms"`
+	AudioS         float64              `json:"audio_s"`
+	RealTimeFactor float64              `json:"real_time_factor"` // decode time per second of audio
+	Throughput     float64              `json:"throughput"`       // seconds of audio decoded per second of wall time
+	ModelInfo      *moonshine.ModelInfo `json:"model_info,omitempty"`
+}
+
+// newBenchReport summarizes decodes of audioS seconds of audio in total
+// that took latencies seconds each, and wall seconds together.
+func newBenchReport(lang string, latencies []float64, audioS, wall float64) benchReport {
+	r := benchReport{Language: lang, Decodes: len(latencies), AudioS: roundStat(audioS)}
+	sorted := slices.Sorted(slices.Values(latencies))
+	ms := make([]float64, len(sorted))
+	var total float64
+	for i, s := range sorted {
+		ms[i] = s * 1000
+		total += s
+	}
+	r.LatencyMs.P50 = percentile(ms, 50)
+	r.LatencyMs.P90 = percentile(ms, 90)
+	r.LatencyMs.P99 = percentile(ms, 99)
+	if audioS > 0 {
+		r.RealTimeFactor = roundStat(total / audioS)
+	}
+	if wall > 0 {
+		r.Throughput = roundStat(audioS / wall)
+	}
+	return r
+}
+
+// benchReferences returns the reference audio bundled with the model in
+// dir: the test_wavs that sherpa-onnx ships with its model releases.
+func benchReferences(dir string) []string {
+	if dir == "" {
+		return nil
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "test_wavs", "*.wav"))
+	return files
+}
+
+// runBench implements `moonshine-whisper bench`: it loads the models once,
+// decodes each reference file -n times per model, and reports latency
+// percentiles, real-time factor, and throughput without starting the HTTP
+// server. It returns the process exit code.
+func runBench(args []string) int {
+	o, err := parseBench(args, os.Stderr)
+	if err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintf(os.Stderr, "bench: %v\n", err)
+		}
+		return 2
+	}
+
+	closeLog := setup(o.configPath)
+	defer closeLog()
+	freeModels := loadModels()
+	defer freeModels()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	langs := o.langs
+	if langs == nil {
+		langs = slices.Sorted(maps.Keys(models.dirs))
+	}
+	failed := 0
+	var reports []benchReport
+	for _, lang := range langs {
+		files := o.files
+		if files == nil {
+			files = benchReferences(models.dirs[lang])
+		}
+		switch {
+		case models.dirs[lang] == "":
+			if o.langs != nil {
+				fmt.Fprintf(os.Stderr, "%s: no model configured\n", lang)
+				failed++
+			}
+			continue
+		case len(files) == 0:
+			fmt.Fprintf(os.Stderr, "%s: no reference audio in %s; pass files to decode\n",
+				lang, filepath.Join(models.dirs[lang], "test_wavs"))
+			failed++
+			continue
+		}
+		r, err := benchModel(ctx, lang, files, o.runs, o.concurrency)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", lang, err)
+			failed++
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		reports = append(reports, r)
+	}
+
+	if o.json {
+		enc := json.NewEncoder(os.Stdout)
+		for _, r := range reports {
+			enc.Encode(r) //nolint:errcheck
+		}
+	} else {
+		fmt.Printf("moonshine-whisper %s (%s), %d CPUs, provider %s, %d decode(s) at once\n\n",
+			version, commit, runtime.NumCPU(), engine.EffectiveProvider(), o.concurrency)
+		writeBenchTable(os.Stdout, reports)
+	}
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// benchModel decodes each of files runs times with the model of lang,
+// concurrency at a time. Files are converted once, by an untimed first
+// decode, so only recognition is measured.
+func benchModel(ctx context.Context, lang string, files []string, runs, concurrency int) (benchReport, error) {
+	opts := moonshine.Options{Lang: lang, WaitForLoad: true}
+	type reference struct {
+		samples    []float32
+		sampleRate int
+	}
+	refs := make([]reference, len(files))
+	var audioS float64
+	for i, path := range files {
+		o := opts
+		o.OnDecoded = func(samples []float32, sampleRate int) { refs[i] = reference{samples, sampleRate} }
+		if _, err := engine.TranscribeFile(ctx, path, o); err != nil {
+			return benchReport{}, fmt.Errorf("%s: %w", path, err)
+		}
+		audioS += float64(len(refs[i].samples)) / float64(refs[i].sampleRate)
+	}
+
+	var next atomic.Int64
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var latencies []float64
+	errs := 0
+	start := time.Now()
+	for range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				i := int(next.Add(1)) - 1
+				if i >= runs*len(refs) {
+					return
+				}
+				ref := refs[i%len(refs)]
+				t := time.Now()
+				_, err := engine.TranscribeSamples(ctx, ref.samples, ref.sampleRate, opts)
+				mu.Lock()
+				if err != nil {
+					errs++
+				} else {
+					latencies = append(latencies, time.Since(t).Seconds())
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return benchReport{}, err
+	}
+	r := newBenchReport(lang, latencies, audioS*float64(runs), time.Since(start).Seconds())
+	r.Backend, r.Files, r.Errors = engine.Backend(lang), len(files), errs
+	if info, ok := engine.ModelInfo(lang); ok {
+		r.ModelInfo = &info
+	}
+	return r, nil
+}
+
+// writeBenchTable prints reports as an aligned table.
+func writeBenchTable(w io.Writer, reports []benchReport) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "language\tbackend\tfiles\tdecodes\terrors\taudio_s\tp50_ms\tp90_ms\tp99_ms\trtf\tthroughput\t")
+	for _, r := range reports {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%.1f\t%.1f\t%.1f\t%.1f\t%.3f\t%.1fx\t\n",
+			r.Language, r.Backend, r.Files, r.Decodes, r.Errors, r.AudioS,
+			r.LatencyMs.P50, r.LatencyMs.P90, r.LatencyMs.P99, r.RealTimeFactor, r.Throughput)
+	}
+	tw.Flush() //nolint:errcheck
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// --- parseBench ---
+
+func TestParseBench(t *testing.T) {
+	o, err := parseBench([]string{"-n", "5", "-concurrency", "2", "-language", "RU, en", "-json"}, io.Discard)
+	if err != nil {
+		t.Fatalf("parseBench: %v", err)
+	}
+	if o.runs != 5 || o.concurrency != 2 || !o.json || !slices.Equal(o.langs, []string{"ru", "en"}) || o.files != nil {
+		t.Errorf("options = %+v", o)
+	}
+
+	o, err = parseBench([]string{"a.wav"}, io.Discard)
+	if err != nil {
+		t.Fatalf("parseBench: %v", err)
+	}
+	if o.runs != 10 || o.concurrency != 1 || !slices.Equal(o.langs, []string{"en"}) || !slices.Equal(o.files, []string{"a.wav"}) {
+		t.Errorf("defaults with files = %+v", o)
+	}
+}
+
+func TestParseBench_Errors(t *testing.T) {
+	for _, args := range [][]string{{"-n", "0"}, {"-concurrency", "-1"}, {"-bogus"}} {
+		if _, err := parseBench(args, io.Discard); err == nil {
+			t.Errorf("parseBench(%q): expected error", args)
+		}
+	}
+}
+
+// --- newBenchReport ---
+
+func TestNewBenchReport(t *testing.T) {
+	latencies := []float64{0.3, 0.1, 0.2, 0.4}
+	r := newBenchReport("en", latencies, 10, 0.5)
+	if r.Decodes != 4 || r.AudioS != 10 {
+		t.Errorf("decodes = %d, audio_s = %g", r.Decodes, r.AudioS)
+	}
+	if r.LatencyMs.P50 != 200 || r.LatencyMs.P90 != 400 || r.LatencyMs.P99 != 400 {
+		t.Errorf("latency = %+v", r.LatencyMs)
+	}
+	if r.RealTimeFactor != 0.1 || r.Throughput != 20 {
+		t.Errorf("rtf = %g, throughput = %g", r.RealTimeFactor, r.Throughput)
+	}
+	if !slices.Equal(latencies, []float64{0.3, 0.1, 0.2, 0.4}) {
+		t.Error("latencies reordered")
+	}
+
+	if r := newBenchReport("ru", nil, 0, 0); r.Decodes != 0 || r.RealTimeFactor != 0 || r.Throughput != 0 {
+		t.Errorf("empty report = %+v", r)
+	}
+}
+
+// --- benchReferences ---
+
+func TestBenchReferences(t *testing.T) {
+	dir := t.TempDir()
+	if got := benchReferences(dir); got != nil {
+		t.Errorf("no test_wavs: %v", got)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "test_wavs"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"1.wav", "0.wav", "trans.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, "test_wavs", name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got := benchReferences(dir)
+	if len(got) != 2 || filepath.Base(got[0]) != "0.wav" || filepath.Base(got[1]) != "1.wav" {
+		t.Errorf("benchReferences = %v", got)
+	}
+	if got := benchReferences(""); got != nil {
+		t.Errorf("no dir: %v", got)
+	}
+}
+
+// --- writeBenchTable ---
+
+func TestWriteBenchTable(t *testing.T) {
+	r := newBenchReport("en", []float64{0.25}, 5, 0.25)
+	r.Backend, r.Files = "moonshine", 1
+	var buf bytes.Buffer
+	writeBenchTable(&buf, []benchReport{r})
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], "p50_ms") {
+		t.Fatalf("table = %q", buf.String())
+	}
+	for _, want := range []string{"en", "moonshine", "250.0", "0.050", "20.0x"} {
+		if !strings.Contains(lines[1], want) {
+			t.Errorf("row %q lacks %q", lines[1], want)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/anatolykoptev/moonshine-whisper/pkg/moonshine"
+)
+
+// transcripts caches successful responses by audio content and options.
+// nil disables caching.
+var transcripts *transcriptCache
+
+// transcriptCache is an LRU cache of transcription results with a TTL.
+type transcriptCache struct {
+	mu    sync.Mutex
+	size  int
+	ttl   time.Duration
+	ll    *list.List // front = most recently used
+	items map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key     string
+	resp    TranscribeResponse
+	expires time.Time
+}
+
+// newTranscriptCache holds up to size results for ttl each.
+func newTranscriptCache(size int, ttl time.Duration) *transcriptCache {
+	return &transcriptCache{size: size, ttl: ttl, ll: list.New(), items: make(map[string]*list.Element)}
+}
+
+// get returns the unexpired response stored under key.
+func (c *transcriptCache) get(key string) (TranscribeResponse, bool) {
+	if c == nil {
+		return TranscribeResponse{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return TranscribeResponse{}, false
+	}
+	e := el.Value.(*cacheEntry)
+	if time.Now().After(e.expires) {
+		c.ll.Remove(el)
+		delete(c.items, key)
+		return TranscribeResponse{}, false
+	}
+	c.ll.MoveToFront(el)
+	return e.resp, true
+}
+
+// put stores resp under key, evicting the least recently used entry when full.
+func (c *transcriptCache) put(key string, resp TranscribeResponse) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expires := time.Now().Add(c.ttl)
+	if el, ok := c.items[key]; ok {
+		el.Value = &cacheEntry{key: key, resp: resp, expires: expires}
+		c.ll.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.ll.PushFront(&cacheEntry{key: key, resp: resp, expires: expires})
+	for c.ll.Len() > c.size {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// purge drops every entry, e.g. after a model reload.
+func (c *transcriptCache) purge() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ll.Init()
+	clear(c.items)
+}
+
+// flight is a transcription in progress that requests with the same key
+// wait for instead of running their own.
+type flight struct {
+	done   chan struct{} // closed once resp and status are set
+	resp   TranscribeResponse
+	status int
+}
+
+// flights holds the transcriptions in progress by key.
+var flights struct {
+	mu sync.Mutex
+	m  map[string]*flight
+}
+
+// withCache returns the cached response for key or runs fn, caching a 200
+// result. With DEDUP_REQUESTS, a request arriving while fn runs for the same
+// key waits for that result instead, marked coalesced; it runs fn itself if
+// that run ends because its own request was canceled or timed out, and
+// stops waiting once ctx is done. An empty key bypasses both.
+func withCache(ctx context.Context, key string, start time.Time, fn func() (TranscribeResponse, int)) (TranscribeResponse, int) {
+	if key == "" {
+		return fn()
+	}
+	for {
+		if resp, ok := transcripts.get(key); ok {
+			resp.Cached = true
+			stats.recordCacheHit()
+			resp.DurationMs = float64(time.Since(start).Milliseconds())
+			return resp, http.StatusOK
+		}
+		if !cfg.DedupRequests {
+			return runFlight(key, nil, fn)
+		}
+		flights.mu.Lock()
+		f, ok := flights.m[key]
+		if !ok {
+			f = &flight{done: make(chan struct{})}
+			if flights.m == nil {
+				flights.m = make(map[string]*flight)
+			}
+			flights.m[key] = f
+			flights.mu.Unlock()
+			return runFlight(key, f, fn)
+		}
+		flights.mu.Unlock()
+		select {
+		case <-f.done:
+		case <-ctx.Done():
+			return contextError(ctx)
+		}
+		if f.status == statusClientClosedRequest || f.status == http.StatusGatewayTimeout {
+			continue
+		}
+		statRequestsCoalesced.Add(1)
+		resp := f.resp
+		resp.Coalesced = true
+		resp.DurationMs = float64(time.Since(start).Milliseconds())
+		return resp, f.status
+	}
+}
+
+// runFlight runs fn for key, caching a 200 result, and hands the result to
+// the requests waiting on f, if any.
+func runFlight(key string, f *flight, fn func() (TranscribeResponse, int)) (TranscribeResponse, int) {
+	resp, status := fn()
+	if status == http.StatusOK {
+		transcripts.put(key, resp)
+	}
+	if f != nil {
+		f.resp, f.status = resp, status
+		flights.mu.Lock()
+		delete(flights.m, key)
+		flights.mu.Unlock()
+		close(f.done)
+	}
+	return resp, status
+}
+
+// keysEnabled reports whether requests need cache keys: for the cache or
+// for DEDUP_REQUESTS.
+func keysEnabled() bool {
+	return transcripts != nil || cfg.DedupRequests
+}
+
+// fileCacheKey returns the cache key for transcribing path with opts, or ""
+// when keys are not needed (see keysEnabled) or the file cannot be read.
+func fileCacheKey(path string, opts moonshine.Options) string {
+	if !keysEnabled() {
+		return ""
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close() //nolint:errcheck
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return ""
+	}
+	return fmt.Sprintf("file:%x|%s", h.Sum(nil), optionsKey(opts))
+}
+
+// samplesCacheKey returns the cache key for transcribing decoded samples
+// with opts, or "" when keys are not needed (see keysEnabled).
+func samplesCacheKey(samples []float32, sampleRate int, opts moonshine.Options) string {
+	if !keysEnabled() {
+		return ""
+	}
+	h := sha256.New()
+	buf := binary.LittleEndian.AppendUint32(make([]byte, 0, 16<<10), uint32(sampleRate))
+	for _, s := range samples {
+		if len(buf) == cap(buf) {
+			h.Write(buf) //nolint:errcheck
+			buf = buf[:0]
+		}
+		buf = binary.LittleEndian.AppendUint32(buf, math.Float32bits(s))
+	}
+	h.Write(buf) //nolint:errcheck
+	return fmt.Sprintf("pcm:%x|%s", h.Sum(nil), optionsKey(opts))
+}
+
+// optionsKey encodes every option that affects the transcript.
+func optionsKey(o moonshine.Options) string {
+	return fmt.Sprintf("%s|%s|%s|%t|%d|%s|%s|%d|%t|%s|%g|%v|%t|%t|%s|%t|%t|%g|%s|%t|%d|%s|%t|%t", o.Lang, boolKey(o.VAD), boolKey(o.Punctuate),
+		o.Diarize, o.MaxSpeakers, o.Hotwords, o.DecodingMethod, o.BeamSize, o.SplitChannels, boolKey(o.Telephony), o.MaxAudioDurationS,
+		o.AutoLanguages, o.Emotions, o.ITN, o.Task, o.Denoise, o.Normalize, o.VADMinDurationS, o.Quality, o.TrimSilence, o.AudioTrack, o.Channel, o.DTMF, o.SkipMusic)
+}
+
+// boolKey renders an optional bool as "auto", "true", or "false".
+func boolKey(b *bool) string {
+	if b == nil {
+		return "auto"
+	}
+	return fmt.Sprint(*b)
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/anatolykoptev/moonshine-whisper/pkg/moonshine"
+)
+
+// --- transcriptCache ---
+
+func TestTranscriptCache_LRU(t *testing.T) {
+	c := newTranscriptCache(2, time.Minute)
+	c.put("a", TranscribeResponse{Text: "a"})
+	c.put("b", TranscribeResponse{Text: "b"})
+	if _, ok := c.get("a"); !ok { // a becomes most recent
+		t.Fatal("a missing")
+	}
+	c.put("c", TranscribeResponse{Text: "c"})
+
+	if _, ok := c.get("b"); ok {
+		t.Error("b should have been evicted as least recently used")
+	}
+	for _, k := range []string{"a", "c"} {
+		if resp, ok := c.get(k); !ok || resp.Text != k {
+			t.Errorf("get(%q) = %q, %v", k, resp.Text, ok)
+		}
+	}
+
+	c.purge()
+	if _, ok := c.get("a"); ok {
+		t.Error("purge should drop all entries")
+	}
+}
+
+func TestTranscriptCache_TTL(t *testing.T) {
+	c := newTranscriptCache(4, time.Nanosecond)
+	c.put("a", TranscribeResponse{Text: "a"})
+	time.Sleep(time.Millisecond)
+	if _, ok := c.get("a"); ok {
+		t.Error("expired entry returned")
+	}
+	if c.ll.Len() != 0 {
+		t.Errorf("expired entry not removed, len = %d", c.ll.Len())
+	}
+}
+
+func TestTranscriptCache_Nil(t *testing.T) {
+	var c *transcriptCache
+	c.put("a", TranscribeResponse{})
+	c.purge()
+	if _, ok := c.get("a"); ok {
+		t.Error("nil cache must miss")
+	}
+}
+
+// --- withCache ---
+
+func TestWithCache(t *testing.T) {
+	old := transcripts
+	transcripts = newTranscriptCache(4, time.Minute)
+	t.Cleanup(func() { transcripts = old })
+
+	calls := 0
+	run := func(status int) func() (TranscribeResponse, int) {
+		return func() (TranscribeResponse, int) {
+			calls++
+			return TranscribeResponse{Text: "hi"}, status
+		}
+	}
+
+	withCache(context.Background(), "fail", time.Now(), run(http.StatusBadRequest))
+	withCache(context.Background(), "fail", time.Now(), run(http.StatusBadRequest))
+	if calls != 2 {
+		t.Errorf("errors must not be cached: %d calls, want 2", calls)
+	}
+
+	calls = 0
+	if resp, _ := withCache(context.Background(), "ok", time.Now(), run(http.StatusOK)); resp.Cached {
+		t.Error("first response marked cached")
+	}
+	resp, status := withCache(context.Background(), "ok", time.Now(), run(http.StatusOK))
+	if calls != 1 || !resp.Cached || resp.Text != "hi" || status != http.StatusOK {
+		t.Errorf("second call: calls=%d resp=%+v status=%d, want cache hit", calls, resp, status)
+	}
+
+	withCache(context.Background(), "", time.Now(), run(http.StatusOK))
+	withCache(context.Background(), "", time.Now(), run(http.StatusOK))
+	if calls != 3 {
+		t.Errorf("empty key must bypass the cache: %d calls, want 3", calls)
+	}
+}
+
+// setDedup sets DEDUP_REQUESTS for the test.
+func setDedup(t *testing.T, on bool) {
+	t.Helper()
+	old := cfg.DedupRequests
+	cfg.DedupRequests = on
+	t.Cleanup(func() { cfg.DedupRequests = old })
+}
+
+func TestWithCache_Coalesce(t *testing.T) {
+	setDedup(t, true)
+	old := transcripts
+	transcripts = newTranscriptCache(4, time.Minute) // for requests arriving late
+	t.Cleanup(func() { transcripts = old })
+	var calls atomic.Int32
+	release := make(chan struct{})
+	fn := func() (TranscribeResponse, int) {
+		calls.Add(1)
+		<-release
+		return TranscribeResponse{Text: "hi"}, http.StatusOK
+	}
+
+	results := make(chan TranscribeResponse, 3)
+	for range 3 {
+		go func() {
+			resp, _ := withCache(context.Background(), "same", time.Now(), fn)
+			results <- resp
+		}()
+	}
+	time.Sleep(20 * time.Millisecond) // let the requests join the first
+	close(release)
+	shared := 0
+	for range 3 {
+		if resp := <-results; resp.Text != "hi" {
+			t.Errorf("resp = %+v", resp)
+		} else if resp.Coalesced || resp.Cached {
+			shared++
+		}
+	}
+	if calls.Load() != 1 || shared != 2 {
+		t.Errorf("%d runs, %d shared; want 1 and 2", calls.Load(), shared)
+	}
+}
+
+func TestWithCache_CoalesceCanceledLeader(t *testing.T) {
+	setDedup(t, true)
+	var wg sync.WaitGroup
+	t.Cleanup(wg.Wait)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	wg.Go(func() {
+		withCache(context.Background(), "k", time.Now(), func() (TranscribeResponse, int) {
+			close(started)
+			<-release
+			return TranscribeResponse{Error: "request canceled"}, statusClientClosedRequest
+		})
+	})
+	<-started
+	done := make(chan TranscribeResponse)
+	go func() {
+		resp, _ := withCache(context.Background(), "k", time.Now(), func() (TranscribeResponse, int) {
+			return TranscribeResponse{Text: "own"}, http.StatusOK
+		})
+		done <- resp
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	if resp := <-done; resp.Text != "own" || resp.Coalesced {
+		t.Errorf("resp = %+v, want its own run after the leader was canceled", resp)
+	}
+
+	started = make(chan struct{})
+	blocked := make(chan struct{})
+	wg.Go(func() {
+		withCache(context.Background(), "k2", time.Now(), func() (TranscribeResponse, int) {
+			close(started)
+			<-blocked
+			return TranscribeResponse{}, http.StatusOK
+		})
+	})
+	<-started
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, status := withCache(ctx, "k2", time.Now(), nil); status != statusClientClosedRequest {
+		t.Errorf("canceled waiter status = %d, want %d", status, statusClientClosedRequest)
+	}
+	close(blocked)
+}
+
+// --- cache keys ---
+
+func TestCacheKeys(t *testing.T) {
+	old := transcripts
+	transcripts = newTranscriptCache(4, time.Minute)
+	t.Cleanup(func() { transcripts = old })
+
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.wav"), filepath.Join(dir, "b.wav")
+	os.WriteFile(a, []byte("same bytes"), 0o600) //nolint:errcheck
+	os.WriteFile(b, []byte("same bytes"), 0o600) //nolint:errcheck
+
+	en := moonshine.Options{Lang: "en"}
+	if fileCacheKey(a, en) == "" || fileCacheKey(a, en) != fileCacheKey(b, en) {
+		t.Error("identical content must share a key regardless of path")
+	}
+	if fileCacheKey(a, en) == fileCacheKey(a, moonshine.Options{Lang: "ru"}) {
+		t.Error("different options must not share a key")
+	}
+	yes := true
+	if fileCacheKey(a, en) == fileCacheKey(a, moonshine.Options{Lang: "en", VAD: &yes}) {
+		t.Error("vad override must change the key")
+	}
+	if fileCacheKey(a, en) == fileCacheKey(a, moonshine.Options{Lang: "en", SplitChannels: true}) {
+		t.Error("split_channels must change the key")
+	}
+	if fileCacheKey(a, en) == fileCacheKey(a, moonshine.Options{Lang: "en", Telephony: &yes}) {
+		t.Error("telephony override must change the key")
+	}
+	if fileCacheKey(a, en) == fileCacheKey(a, moonshine.Options{Lang: "en", Quality: moonshine.QualityAccurate}) {
+		t.Error("quality must change the key")
+	}
+	if fileCacheKey(filepath.Join(dir, "missing.wav"), en) != "" {
+		t.Error("unreadable file must disable caching")
+	}
+
+	s := []float32{0, 0.5, -0.5}
+	if samplesCacheKey(s, 16000, en) == samplesCacheKey(s, 8000, en) {
+		t.Error("sample rate must change the key")
+	}
+	if samplesCacheKey(s, 16000, en) != samplesCacheKey([]float32{0, 0.5, -0.5}, 16000, en) {
+		t.Error("equal samples must share a key")
+	}
+
+	transcripts = nil
+	if fileCacheKey(a, en) != "" || samplesCacheKey(s, 16000, en) != "" {
+		t.Error("keys must be empty when caching and deduplication are disabled")
+	}
+	setDedup(t, true)
+	if fileCacheKey(a, en) == "" {
+		t.Error("deduplication needs keys without the cache")
+	}
+}
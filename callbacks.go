@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// callbackMaxBackoff caps the wait between callback attempts.
+const callbackMaxBackoff = 5 * time.Minute
+
+// deadLetterLimit bounds the failed callbacks kept for GET
+// /admin/callbacks/failed; the oldest are dropped first.
+const deadLetterLimit = 1000
+
+// deadLetter is a callback that was given up on.
+type deadLetter struct {
+	JobID    string    `json:"job_id"`
+	URL      string    `json:"url"`
+	Attempts int       `json:"attempts"`
+	Error    string    `json:"error"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+// deadLetters holds the most recent failed callbacks, oldest first.
+var deadLetters struct {
+	mu   sync.Mutex
+	list []deadLetter
+}
+
+// deliverCallback POSTs the finished job as JSON to its callback URL. A
+// network error, 408, 429, or 5xx is retried up to CALLBACK_RETRIES times,
+// waiting CALLBACK_BACKOFF_S and doubling the wait each time; a callback
+// that still fails is recorded as a dead letter. The job's request ID is
+// sent as X-Request-ID, and each attempt is signed with the callback secret
+// of the job's tenant (see signCallback).
+func deliverCallback(j Job) {
+	ctx := withRequestID(context.Background(), j.RequestID)
+	body, err := json.Marshal(j)
+	if err != nil {
+		logf(ctx, "job %s callback: encode: %v", j.ID, err)
+		return
+	}
+	secret := callbackSecret(tenantsByName[j.tenant])
+	backoff := cfg.CallbackBackoff
+	for attempt := 1; ; attempt++ {
+		retry, err := postCallback(callbackClient, j.CallbackURL, j.RequestID, secret, body)
+		if err == nil {
+			return
+		}
+		if !retry || attempt > cfg.CallbackRetries {
+			logf(ctx, "job %s callback: giving up after %d attempt(s): %v", j.ID, attempt, err)
+			addDeadLetter(deadLetter{JobID: j.ID, URL: j.CallbackURL, Attempts: attempt, Error: err.Error(), FailedAt: time.Now()})
+			return
+		}
+		logf(ctx, "job %s callback: %v; retrying in %s", j.ID, err, backoff)
+		time.Sleep(backoff)
+		backoff = min(2*backoff, callbackMaxBackoff)
+	}
+}
+
+// postCallback makes one delivery attempt with client, with reqID as
+// X-Request-ID unless empty and signed with secret unless empty, and reports
+// whether a failure is worth retrying. A refused private address is not.
+func postCallback(client *http.Client, url, reqID, secret string, body []byte) (retry bool, err error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if reqID != "" {
+		req.Header.Set(requestIDHeader, reqID)
+	}
+	if secret != "" {
+		signCallback(req.Header, secret, time.Now(), uuid.New().String(), body)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return !errors.Is(err, errPrivateAddress), err
+	}
+	resp.Body.Close() //nolint:errcheck
+	if resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry = resp.StatusCode >= 500 || resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("%s returned %d", url, resp.StatusCode)
+}
+
+// callbackSecret returns the secret that signs callbacks of t's jobs: its
+// callback_secret, else CALLBACK_SECRET.
+func callbackSecret(t *tenant) string {
+	if t != nil && t.CallbackSecret != "" {
+		return t.CallbackSecret
+	}
+	return cfg.CallbackSecret
+}
+
+// signCallback sets the signature headers of a callback with body:
+// X-Signature-Timestamp (Unix seconds), X-Signature-Nonce, and X-Signature,
+// "sha256=" and the hex HMAC-SHA256 with secret of
+// "<timestamp>.<nonce>.<body>". Receivers recompute it, and reject stale
+// timestamps and repeated nonces to stop replays.
+func signCallback(h http.Header, secret string, now time.Time, nonce string, body []byte) {
+	ts := strconv.FormatInt(now.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts + "." + nonce + ".")) //nolint:errcheck
+	mac.Write(body)                           //nolint:errcheck
+	h.Set("X-Signature-Timestamp", ts)
+	h.Set("X-Signature-Nonce", nonce)
+	h.Set("X-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+}
+
+// addDeadLetter records a failed callback, dropping the oldest beyond
+// deadLetterLimit.
+func addDeadLetter(d deadLetter) {
+	statCallbacksFailed.Add(1)
+	deadLetters.mu.Lock()
+	defer deadLetters.mu.Unlock()
+	deadLetters.list = append(deadLetters.list, d)
+	if n := len(deadLetters.list) - deadLetterLimit; n > 0 {
+		deadLetters.list = append([]deadLetter(nil), deadLetters.list[n:]...)
+	}
+}
+
+// handleDeadLetters handles GET /admin/callbacks/failed: the callbacks given
+// up on, most recent first. DELETE clears the list.
+func handleDeadLetters(w http.ResponseWriter, r *http.Request) {
+	deadLetters.mu.Lock()
+	defer deadLetters.mu.Unlock()
+	switch r.Method {
+	case http.MethodGet:
+		list := make([]deadLetter, len(deadLetters.list))
+		for i, d := range deadLetters.list {
+			list[len(list)-1-i] = d
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"failed": list})
+	case http.MethodDelete:
+		deadLetters.list = nil
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "GET or DELETE only")
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// setCallbackRetries makes callbacks retry n times without waiting, and
+// clears the dead letters.
+func setCallbackRetries(t *testing.T, n int) {
+	t.Helper()
+	oldRetries, oldBackoff, oldPrivate := cfg.CallbackRetries, cfg.CallbackBackoff, cfg.CallbackAllowPrivate
+	cfg.CallbackRetries, cfg.CallbackBackoff, cfg.CallbackAllowPrivate = n, time.Millisecond, true // loopback test servers
+	deadLetters.list = nil
+	t.Cleanup(func() {
+		cfg.CallbackRetries, cfg.CallbackBackoff, cfg.CallbackAllowPrivate = oldRetries, oldBackoff, oldPrivate
+		deadLetters.list = nil
+	})
+}
+
+// --- deliverCallback ---
+
+func TestDeliverCallback(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int // responses in order; the last repeats
+		wantAttempts int32
+		wantDead     bool
+	}{
+		{"ok", []int{http.StatusOK}, 1, false},
+		{"retried until ok", []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusNoContent}, 3, false},
+		{"retries exhausted", []int{http.StatusBadGateway}, 3, true},
+		{"not retried", []int{http.StatusNotFound}, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setCallbackRetries(t, 2)
+			var attempts atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := int(attempts.Add(1))
+				w.WriteHeader(tt.statuses[min(n, len(tt.statuses))-1])
+			}))
+			defer srv.Close()
+
+			deliverCallback(Job{ID: "j1", Status: jobDone, CallbackURL: srv.URL})
+			if got := attempts.Load(); got != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", got, tt.wantAttempts)
+			}
+			if dead := len(deadLetters.list) == 1; dead != tt.wantDead {
+				t.Fatalf("dead letters = %+v, want dead %v", deadLetters.list, tt.wantDead)
+			}
+			if tt.wantDead {
+				d := deadLetters.list[0]
+				if d.JobID != "j1" || d.URL != srv.URL || d.Attempts != int(tt.wantAttempts) || d.Error == "" {
+					t.Errorf("dead letter = %+v", d)
+				}
+			}
+		})
+	}
+}
+
+func TestDeliverCallback_PrivateAddress(t *testing.T) {
+	setCallbackRetries(t, 2)
+	cfg.CallbackAllowPrivate = false
+	var attempts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+	}))
+	defer srv.Close()
+
+	deliverCallback(Job{ID: "j1", Status: jobDone, CallbackURL: srv.URL})
+	if got := attempts.Load(); got != 0 {
+		t.Errorf("server hit %d times, want the connection refused", got)
+	}
+	if len(deadLetters.list) != 1 || deadLetters.list[0].Attempts != 1 {
+		t.Fatalf("dead letters = %+v, want one, not retried", deadLetters.list)
+	}
+}
+
+func TestDeliverCallback_RequestID(t *testing.T) {
+	setCallbackRetries(t, 0)
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("X-Request-ID")
+	}))
+	defer srv.Close()
+	deliverCallback(Job{ID: "j1", Status: jobDone, CallbackURL: srv.URL, RequestID: "req-1"})
+	if got != "req-1" {
+		t.Errorf("X-Request-ID = %q, want req-1", got)
+	}
+}
+
+func TestDeliverCallback_Signature(t *testing.T) {
+	setCallbackRetries(t, 0)
+	old := cfg.CallbackSecret
+	cfg.CallbackSecret = "s3cret"
+	t.Cleanup(func() { cfg.CallbackSecret = old })
+	var h http.Header
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h = r.Header.Clone()
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+	deliverCallback(Job{ID: "j1", Status: jobDone, CallbackURL: srv.URL})
+
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte(h.Get("X-Signature-Timestamp") + "." + h.Get("X-Signature-Nonce") + "."))
+	mac.Write(body)
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); h.Get("X-Signature") != want {
+		t.Errorf("X-Signature = %q, want %q", h.Get("X-Signature"), want)
+	}
+	if h.Get("X-Signature-Nonce") == "" {
+		t.Error("X-Signature-Nonce not set")
+	}
+	if ts, err := strconv.ParseInt(h.Get("X-Signature-Timestamp"), 10, 64); err != nil || time.Since(time.Unix(ts, 0)) > time.Minute {
+		t.Errorf("X-Signature-Timestamp = %q", h.Get("X-Signature-Timestamp"))
+	}
+}
+
+// --- signCallback ---
+
+func TestSignCallback(t *testing.T) {
+	h := make(http.Header)
+	signCallback(h, "key", time.Unix(1700000000, 0), "n1", []byte(`{"id":"j1"}`))
+	// printf '1700000000.n1.{"id":"j1"}' | openssl dgst -sha256 -hmac key
+	const want = "sha256=522ce1649b78c1d602ffcc3ac870c7d6a31d68000ade715f652911ede3649c60"
+	if h.Get("X-Signature") != want || h.Get("X-Signature-Timestamp") != "1700000000" || h.Get("X-Signature-Nonce") != "n1" {
+		t.Errorf("headers = %v, want X-Signature %q", h, want)
+	}
+}
+
+// --- callbackSecret ---
+
+func TestCallbackSecret(t *testing.T) {
+	old := cfg.CallbackSecret
+	cfg.CallbackSecret = "global"
+	t.Cleanup(func() { cfg.CallbackSecret = old })
+	tests := []struct {
+		t    *tenant
+		want string
+	}{
+		{nil, "global"},
+		{&tenant{}, "global"},
+		{&tenant{TenantConfig: TenantConfig{CallbackSecret: "acme"}}, "acme"},
+	}
+	for _, tt := range tests {
+		if got := callbackSecret(tt.t); got != tt.want {
+			t.Errorf("callbackSecret(%+v) = %q, want %q", tt.t, got, tt.want)
+		}
+	}
+}
+
+func TestAddDeadLetter_Limit(t *testing.T) {
+	setCallbackRetries(t, 0)
+	for i := range deadLetterLimit + 5 {
+		addDeadLetter(deadLetter{Attempts: i})
+	}
+	if n := len(deadLetters.list); n != deadLetterLimit {
+		t.Fatalf("kept %d dead letters, want %d", n, deadLetterLimit)
+	}
+	if first := deadLetters.list[0].Attempts; first != 5 {
+		t.Errorf("oldest kept = %d, want 5", first)
+	}
+}
+
+// --- handleDeadLetters ---
+
+func TestHandleDeadLetters(t *testing.T) {
+	setCallbackRetries(t, 0)
+	addDeadLetter(deadLetter{JobID: "old"})
+	addDeadLetter(deadLetter{JobID: "new"})
+
+	rec := httptest.NewRecorder()
+	handleDeadLetters(rec, httptest.NewRequest(http.MethodGet, "/admin/callbacks/failed", nil))
+	var got struct{ Failed []deadLetter }
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(got.Failed) != 2 || got.Failed[0].JobID != "new" {
+		t.Errorf("failed = %+v, want most recent first", got.Failed)
+	}
+
+	rec = httptest.NewRecorder()
+	handleDeadLetters(rec, httptest.NewRequest(http.MethodDelete, "/admin/callbacks/failed", nil))
+	if rec.Code != http.StatusNoContent || len(deadLetters.list) != 0 {
+		t.Errorf("DELETE = %d, %d left, want 204 and none", rec.Code, len(deadLetters.list))
+	}
+
+	rec = httptest.NewRecorder()
+	handleDeadLetters(rec, httptest.NewRequest(http.MethodPost, "/admin/callbacks/failed", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST = %d, want 405", rec.Code)
+	}
+}
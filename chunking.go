@@ -50,11 +50,3 @@ func findSplitPoint(chunk string) int {
 	// 4. Hard cut
 	return len(chunk)
 }
-
-// sanitizeUTF8 ensures text is valid UTF-8 and strips null bytes.
-// Ported from Vaelor's channels.sanitizeUTF8.
-func sanitizeUTF8(text string) string {
-	text = strings.ToValidUTF8(text, "")
-	text = strings.ReplaceAll(text, "\x00", "")
-	return text
-}
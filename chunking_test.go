@@ -311,76 +311,3 @@ func TestFindSplitPoint_MultipleSentences(t *testing.T) {
 		t.Errorf("findSplitPoint = %d, want 5 (last '. ')", idx)
 	}
 }
-
-// --- sanitizeUTF8 ---
-
-func TestSanitizeUTF8_Valid(t *testing.T) {
-	if got := sanitizeUTF8("hello world"); got != "hello world" {
-		t.Errorf("got %q, want %q", got, "hello world")
-	}
-}
-
-func TestSanitizeUTF8_Empty(t *testing.T) {
-	if got := sanitizeUTF8(""); got != "" {
-		t.Errorf("got %q, want empty", got)
-	}
-}
-
-func TestSanitizeUTF8_NullBytes(t *testing.T) {
-	if got := sanitizeUTF8("hello\x00world"); got != "helloworld" {
-		t.Errorf("got %q, want %q", got, "helloworld")
-	}
-}
-
-func TestSanitizeUTF8_MultipleNullBytes(t *testing.T) {
-	if got := sanitizeUTF8("\x00\x00\x00"); got != "" {
-		t.Errorf("got %q, want empty", got)
-	}
-}
-
-func TestSanitizeUTF8_OnlyInvalidBytes(t *testing.T) {
-	if got := sanitizeUTF8("\xff\xfe\xfd"); got != "" {
-		t.Errorf("got %q, want empty", got)
-	}
-}
-
-func TestSanitizeUTF8_InvalidBytes(t *testing.T) {
-	if got := sanitizeUTF8("hello\xff\xfeworld"); got != "helloworld" {
-		t.Errorf("got %q, want %q", got, "helloworld")
-	}
-}
-
-func TestSanitizeUTF8_Cyrillic(t *testing.T) {
-	if got := sanitizeUTF8("Привет мир"); got != "Привет мир" {
-		t.Errorf("got %q, want %q", got, "Привет мир")
-	}
-}
-
-func TestSanitizeUTF8_Emoji(t *testing.T) {
-	if got := sanitizeUTF8("hello 🌍 world"); got != "hello 🌍 world" {
-		t.Errorf("got %q, want %q", got, "hello 🌍 world")
-	}
-}
-
-func TestSanitizeUTF8_MixedInvalidAndNull(t *testing.T) {
-	got := sanitizeUTF8("ok\xff\x00good\xfe")
-	if got != "okgood" {
-		t.Errorf("got %q, want %q", got, "okgood")
-	}
-}
-
-func TestSanitizeUTF8_SurrogatePairs(t *testing.T) {
-	// UTF-8 encoded surrogates (U+D800) are invalid and should be stripped.
-	got := sanitizeUTF8("a\xed\xa0\x80b")
-	if got != "ab" {
-		t.Errorf("got %q, want %q", got, "ab")
-	}
-}
-
-func TestSanitizeUTF8_TruncatedMultibyte(t *testing.T) {
-	// First byte of a 2-byte sequence without continuation.
-	got := sanitizeUTF8("abc\xc3")
-	if got != "abc" {
-		t.Errorf("got %q, want %q", got, "abc")
-	}
-}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/anatolykoptev/moonshine-whisper/pkg/moonshine"
+)
+
+// maxTaggingTopK bounds the number of classes /classify returns.
+const maxTaggingTopK = 50
+
+// ClassifyResponse is the JSON response of POST /classify.
+type ClassifyResponse struct {
+	Tags       []moonshine.AudioTag `json:"tags"` // most likely first
+	DurationMs float64              `json:"duration_ms"`
+}
+
+// handleClassify handles POST /classify: the most likely sound event
+// classes (speech, music, applause, ...) of the audio in a /transcribe JSON
+// body or an "audio" multipart upload, so callers can skip transcribing
+// media without speech. The top_k query parameter overrides TAGGING_TOP_K.
+func handleClassify(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "POST only")
+		return
+	}
+	topK := cfg.TaggingTopK
+	if v := r.URL.Query().Get("top_k"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxTaggingTopK {
+			writeError(w, http.StatusBadRequest, "top_k must be in [1, "+strconv.Itoa(maxTaggingTopK)+"]")
+			return
+		}
+		topK = n
+	}
+	start := time.Now()
+	ctx, req, audioPath, done, ok := analysisAudio(w, r)
+	if !ok {
+		return
+	}
+	defer done()
+	tags, err := engineFor(ctx).Classify(ctx, audioPath, requestOptions(ctx, req), topK)
+	if err != nil {
+		resp, status := engineError(ctx, err)
+		writeJSON(w, status, resp)
+		return
+	}
+	writeJSON(w, http.StatusOK, ClassifyResponse{Tags: tags, DurationMs: float64(time.Since(start).Milliseconds())})
+}
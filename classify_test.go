@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// --- handleClassify ---
+
+func TestHandleClassify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "clip.wav")
+	if err := os.WriteFile(path, silentWav(16000, 16000), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		want   int
+	}{
+		{"method", http.MethodGet, "/classify", "", http.StatusMethodNotAllowed},
+		{"top_k zero", http.MethodPost, "/classify?top_k=0", `{"audio_path":"` + path + `"}`, http.StatusBadRequest},
+		{"top_k too large", http.MethodPost, "/classify?top_k=51", `{"audio_path":"` + path + `"}`, http.StatusBadRequest},
+		{"invalid json", http.MethodPost, "/classify", "{", http.StatusBadRequest},
+		{"no source", http.MethodPost, "/classify", `{}`, http.StatusBadRequest},
+		{"model not loaded", http.MethodPost, "/classify?top_k=3", `{"audio_path":"` + path + `"}`, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handleClassify(rec, httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body)))
+		if rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d (%s)", tt.name, rec.Code, tt.want, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/anatolykoptev/moonshine-whisper/pkg/moonshine"
+)
+
+// Output formats of the transcribe subcommand, named after their file extensions.
+const (
+	outputText = "txt"
+	outputJSON = "json"
+	outputSRT  = "srt"
+	outputCSV  = "csv"
+	outputTSV  = "tsv"
+	outputVTT  = "vtt"
+	outputTTML = "ttml"
+)
+
+// cliOptions are the parsed flags of the transcribe subcommand.
+type cliOptions struct {
+	configPath string
+	format     string
+	outDir     string // ""=stdout
+	opts       moonshine.Options
+	files      []string
+}
+
+// parseCLI parses the transcribe subcommand arguments. Usage errors are
+// reported on stderr.
+func parseCLI(args []string, stderr io.Writer) (cliOptions, error) {
+	flags := flag.NewFlagSet("transcribe", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: moonshine-whisper transcribe [flags] <audio files...>")
+		flags.PrintDefaults()
+	}
+	var o cliOptions
+	flags.StringVar(&o.configPath, "config", os.Getenv("MOONSHINE_CONFIG"), "path to YAML config file (env vars override it)")
+	flags.StringVar(&o.format, "format", outputText, "output format: txt, json, srt, vtt, ttml, csv, or tsv")
+	flags.StringVar(&o.outDir, "out", "", "write <name>.<format> files to this directory instead of stdout")
+	lang := flags.String("language", "en", "language code")
+	flags.StringVar(&o.opts.Task, "task", "", "transcribe (default) or translate (to English)")
+	vad := flags.String("vad", "", "force VAD on/off (true|false, empty=auto)")
+	punct := flags.String("punctuate", "", "force punctuation on/off (true|false, empty=auto)")
+	telephony := flags.String("telephony", "", "force the telephony model on/off (true|false, empty=auto for 8 kHz audio)")
+	flags.StringVar(&o.opts.Quality, "quality", "", "fast (default) or accurate, the larger EN model of MOONSHINE_ACCURATE_MODELS_DIR")
+	flags.BoolVar(&o.opts.Diarize, "diarize", false, "label segments with speakers")
+	flags.IntVar(&o.opts.MaxSpeakers, "max-speakers", 0, "maximum number of speakers (0=DIARIZE_MAX_SPEAKERS)")
+	flags.BoolVar(&o.opts.SplitChannels, "split-channels", false, "transcribe each channel of a stereo file separately")
+	flags.StringVar(&o.opts.Channel, "channel", "", "channel to transcribe instead of averaging: mix, left, right, or a 0-based number")
+	flags.IntVar(&o.opts.AudioTrack, "audio-track", 0, "0-based audio track of a multi-track file, such as a video, to transcribe")
+	flags.BoolVar(&o.opts.ITN, "itn", false, "write spoken numbers, amounts, dates, and times in written form")
+	flags.BoolVar(&o.opts.Denoise, "denoise", false, "suppress background noise before recognition")
+	flags.BoolVar(&o.opts.Normalize, "normalize", false, "bring quiet or loud audio to a standard loudness before recognition")
+	flags.BoolVar(&o.opts.TrimSilence, "trim-silence", false, "strip leading and trailing silence before recognition, even without VAD")
+	flags.BoolVar(&o.opts.DTMF, "dtmf", false, "report DTMF key presses and keep the tones from the recognizer")
+	flags.BoolVar(&o.opts.SkipMusic, "skip-music", false, "leave music without speech undecoded (needs TAGGING_MODEL)")
+	flags.IntVar(&o.opts.NumThreads, "num-threads", 0, "cap on ONNX threads across chunks decoded in parallel (0=all)")
+	hotwords := flags.String("hotwords", "", "comma-separated hotwords, optionally phrase:boost")
+	if err := flags.Parse(args); err != nil {
+		return o, err
+	}
+
+	o.format = strings.ToLower(o.format)
+	if !slices.Contains([]string{outputText, outputJSON, outputSRT, outputVTT, outputTTML, outputCSV, outputTSV}, o.format) {
+		return o, fmt.Errorf("unknown format %q (want txt, json, srt, vtt, ttml, csv, or tsv)", o.format)
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return o, errors.New("no audio files given")
+	}
+	req := TranscribeRequest{
+		Language:  *lang,
+		VAD:       parseBoolPtr(*vad),
+		Punctuate: parseBoolPtr(*punct),
+		Hotwords:  parseHotwords(*hotwords),
+	}
+	if msg := validateHotwords(req.Hotwords); msg != "" {
+		return o, errors.New(msg)
+	}
+	if !moonshine.ValidQuality(o.opts.Quality) {
+		return o, fmt.Errorf("unknown quality %q (want fast or accurate)", o.opts.Quality)
+	}
+	if !moonshine.ValidTask(o.opts.Task) {
+		return o, fmt.Errorf("unknown task %q (want transcribe or translate)", o.opts.Task)
+	}
+	o.opts.Lang = normLang(req.Language)
+	o.opts.VAD, o.opts.Punctuate = req.VAD, req.Punctuate
+	o.opts.Telephony = parseBoolPtr(*telephony)
+	o.opts.Hotwords = encodeHotwords(req.Hotwords)
+	o.files = flags.Args()
+	return o, nil
+}
+
+// runCLI implements `moonshine-whisper transcribe`: it loads the models once,
+// transcribes each file in turn, and prints or writes the results without
+// starting the HTTP server. It returns the process exit code.
+func runCLI(args []string) int {
+	o, err := parseCLI(args, os.Stderr)
+	if err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintf(os.Stderr, "transcribe: %v\n", err)
+		}
+		return 2
+	}
+
+	closeLog := setup(o.configPath)
+	defer closeLog()
+	freeModels := loadModels()
+	defer freeModels()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	failed := 0
+	for _, path := range o.files {
+		resp, status := transcribeFile(ctx, path, o.opts)
+		if status != http.StatusOK {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", path, resp.Error)
+			failed++
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		if err := o.emit(path, resp, len(o.files) > 1); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// emit writes the transcript of path to outDir, or to stdout under a
+// "==> path <==" header when several files are transcribed.
+func (o cliOptions) emit(path string, resp TranscribeResponse, many bool) error {
+	data, err := renderTranscript(o.format, path, resp)
+	if err != nil {
+		return err
+	}
+	if o.outDir != "" {
+		base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		return os.WriteFile(filepath.Join(o.outDir, base+"."+o.format), data, 0o644)
+	}
+	if many && o.format != outputJSON {
+		fmt.Printf("==> %s <==\n", path)
+	}
+	_, err = os.Stdout.Write(data)
+	return err
+}
+
+// renderTranscript encodes resp in format. JSON output is a single line
+// carrying the source file name so several results can be streamed.
+func renderTranscript(format, path string, resp TranscribeResponse) ([]byte, error) {
+	switch format {
+	case outputJSON:
+		data, err := json.Marshal(struct {
+			File string `json:"file"`
+			TranscribeResponse
+		}{path, resp})
+		return append(data, '\n'), err
+	case outputSRT:
+		return []byte(formatSRT(resp)), nil
+	case outputVTT:
+		return []byte(formatVTT(resp)), nil
+	case outputTTML:
+		return []byte(formatTTML(resp)), nil
+	case outputCSV:
+		return []byte(formatTable(resp, ',')), nil
+	case outputTSV:
+		return []byte(formatTable(resp, '\t')), nil
+	default:
+		return []byte(resp.Text + "\n"), nil
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+)
+
+// --- parseCLI ---
+
+func TestParseCLI(t *testing.T) {
+	o, err := parseCLI([]string{"-language", "RU", "-format", "SRT", "-vad", "false", "-diarize",
+		"-hotwords", "Сбер:2", "a.wav", "b.ogg"}, io.Discard)
+	if err != nil {
+		t.Fatalf("parseCLI: %v", err)
+	}
+	if o.format != outputSRT || o.opts.Lang != "ru" || !o.opts.Diarize {
+		t.Errorf("format=%q lang=%q diarize=%v", o.format, o.opts.Lang, o.opts.Diarize)
+	}
+	if o.opts.VAD == nil || *o.opts.VAD || o.opts.Punctuate != nil {
+		t.Errorf("vad=%v punctuate=%v, want false and auto", o.opts.VAD, o.opts.Punctuate)
+	}
+	if o.opts.Hotwords == "" {
+		t.Error("hotwords not encoded")
+	}
+	if len(o.files) != 2 || o.files[0] != "a.wav" || o.files[1] != "b.ogg" {
+		t.Errorf("files = %v", o.files)
+	}
+}
+
+func TestParseCLI_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"no files", nil},
+		{"bad format", []string{"-format", "docx", "a.wav"}},
+		{"unknown flag", []string{"-bogus", "a.wav"}},
+		{"bad task", []string{"-task", "summarize", "a.wav"}},
+		{"bad quality", []string{"-quality", "best", "a.wav"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseCLI(tt.args, io.Discard); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+// --- renderTranscript ---
+
+func TestRenderTranscript(t *testing.T) {
+	resp := TranscribeResponse{Text: "hello", AudioS: 1}
+	if got, _ := renderTranscript(outputText, "a.wav", resp); string(got) != "hello\n" {
+		t.Errorf("txt = %q", got)
+	}
+	if got, _ := renderTranscript(outputSRT, "a.wav", resp); !strings.Contains(string(got), "00:00:01,000") {
+		t.Errorf("srt = %q", got)
+	}
+	if got, _ := renderTranscript(outputTSV, "a.wav", resp); string(got) != "start\tend\tspeaker\ttext\n0.000\t1.000\t\thello\n" {
+		t.Errorf("tsv = %q", got)
+	}
+
+	got, err := renderTranscript(outputJSON, "a.wav", resp)
+	if err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	if strings.Count(string(got), "\n") != 1 {
+		t.Errorf("json output should be a single line: %q", got)
+	}
+	var out map[string]any
+	if err := json.Unmarshal(got, &out); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if out["file"] != "a.wav" || out["text"] != "hello" {
+		t.Errorf("json = %v", out)
+	}
+}
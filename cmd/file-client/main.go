@@ -0,0 +1,80 @@
+// Command file-client uploads audio files to a running moonshine-whisper
+// service and prints the transcripts. It is a reference client for the
+// POST /transcribe/upload endpoint, built on the Go client in pkg/client.
+//
+//	file-client -server http://localhost:8092 -language ru call1.ogg call2.wav
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/anatolykoptev/moonshine-whisper/pkg/client"
+)
+
+func main() {
+	server := flag.String("server", "http://localhost:8092", "service base URL")
+	lang := flag.String("language", "en", "language code")
+	vad := flag.String("vad", "", "force VAD on/off (true|false, empty=auto)")
+	punct := flag.String("punctuate", "", "force punctuation on/off (true|false, empty=auto)")
+	maxChunk := flag.Int("max-chunk-len", 0, "split text into chunks of at most N bytes")
+	asJSON := flag.Bool("json", false, "print the raw JSON response")
+	timeout := flag.Duration("timeout", 5*time.Minute, "per-file request timeout")
+	apiKey := flag.String("api-key", os.Getenv("MOONSHINE_API_KEY"), "API key, for services with tenants")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: file-client [flags] <audio files...>")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	c := &client.Client{BaseURL: *server, APIKey: *apiKey, HTTP: &http.Client{Timeout: *timeout}}
+	fields := map[string]string{
+		"language":  *lang,
+		"vad":       *vad,
+		"punctuate": *punct,
+	}
+	if *maxChunk > 0 {
+		fields["max_chunk_len"] = strconv.Itoa(*maxChunk)
+	}
+
+	failed := 0
+	for _, path := range flag.Args() {
+		raw, resp, err := c.Upload(context.Background(), path, fields)
+		if err != nil {
+			log.Printf("%s: %v", path, err)
+			failed++
+			continue
+		}
+		if *asJSON {
+			fmt.Printf("%s\n", bytes.TrimSpace(raw))
+			continue
+		}
+		if resp.Error != "" {
+			log.Printf("%s: %s", path, resp.Error)
+			failed++
+			continue
+		}
+		if flag.NArg() > 1 {
+			fmt.Printf("== %s (%.0fms)\n", path, resp.DurationMs)
+		}
+		if len(resp.Chunks) > 0 {
+			for _, c := range resp.Chunks {
+				fmt.Println(c)
+			}
+		} else {
+			fmt.Println(resp.Text)
+		}
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
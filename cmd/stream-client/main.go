@@ -0,0 +1,111 @@
+// Command stream-client streams live audio to a running moonshine-whisper
+// service over a WebSocket and prints the captions as they are decoded:
+// partial results are rewritten in place, finished utterances kept. It is
+// a reference client for the WebSocket on /transcribe/stream, built on the
+// Go client in pkg/client.
+//
+// The audio is 16 kHz mono 16-bit PCM on stdin, such as a microphone
+// captured by arecord or ffmpeg, or the files named as arguments:
+//
+//	arecord -f S16_LE -r 16000 -c 1 -t raw | stream-client -language en
+//	ffmpeg -f avfoundation -i :0 -f s16le -ac 1 -ar 16000 - | stream-client
+//	stream-client -content-type audio/webm recording.webm
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+
+	"github.com/anatolykoptev/moonshine-whisper/pkg/client"
+)
+
+func main() {
+	server := flag.String("server", "http://localhost:8092", "service base URL")
+	lang := flag.String("language", "en", "language code")
+	contentType := flag.String("content-type", "audio/l16;rate=16000", "content type of the audio")
+	punct := flag.String("punctuate", "", "force punctuation on/off (true|false, empty=auto)")
+	twoPass := flag.String("two-pass", "", "re-decode finished utterances offline (true|false, empty=auto)")
+	apiKey := flag.String("api-key", os.Getenv("MOONSHINE_API_KEY"), "API key, for services with tenants")
+	asJSON := flag.Bool("json", false, "print the raw JSON messages")
+	flag.Parse()
+
+	var in io.Reader = os.Stdin
+	if flag.NArg() > 0 {
+		var files []io.Reader
+		for _, path := range flag.Args() {
+			f, err := os.Open(path)
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer f.Close() //nolint:errcheck
+			files = append(files, f)
+		}
+		in = io.MultiReader(files...)
+	}
+
+	q := url.Values{"language": {*lang}, "content_type": {*contentType}}
+	for k, v := range map[string]string{"punctuate": *punct, "two_pass": *twoPass} {
+		if v != "" {
+			q.Set(k, v)
+		}
+	}
+	c := &client.Client{BaseURL: *server, APIKey: *apiKey}
+	ws, err := c.Dial(context.Background(), q)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer ws.Close() //nolint:errcheck
+
+	go func() {
+		if err := ws.SendAudio(in); err != nil {
+			log.Printf("send: %v", err)
+		}
+	}()
+
+	failed := false
+	partial := false
+	for {
+		data, err := ws.ReadMessage()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if *asJSON {
+			fmt.Printf("%s\n", data)
+			continue
+		}
+		var res client.StreamResult
+		if err := json.Unmarshal(data, &res); err != nil {
+			log.Fatalf("bad message %q: %v", data, err)
+		}
+		switch {
+		case res.Error != "":
+			log.Print(res.Error)
+			failed = true
+		case res.Final:
+			fmt.Printf("\r\033[K[%6.1fs] %s\n", res.Start, res.Text)
+			partial = false
+		default:
+			fmt.Printf("\r\033[K[%6.1fs] %s", res.Start, res.Text)
+			partial = true
+		}
+	}
+	if partial {
+		fmt.Println()
+	}
+	if failed || ws.CloseCode != client.CloseNormal {
+		if ws.CloseCode != client.CloseNormal {
+			log.Printf("closed with %d %s", ws.CloseCode, ws.CloseReason)
+		}
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// compressMinBytes is the smallest response body worth compressing; below
+// it the encoding overhead outweighs the savings.
+const compressMinBytes = 1024
+
+// compressibleTypes are the response media types that are compressed.
+// Streams (NDJSON, server-sent events) are left alone so every line reaches
+// the client as soon as it is flushed.
+var compressibleTypes = map[string]bool{
+	"application/json":          true,
+	"application/ttml+xml":      true,
+	"application/x-subrip":      true,
+	"text/csv":                  true,
+	"text/plain":                true,
+	"text/tab-separated-values": true,
+	"text/vtt":                  true,
+}
+
+// compressMiddleware compresses responses of compressibleTypes with gzip
+// or deflate, whichever the client's Accept-Encoding prefers, once the body
+// reaches compressMinBytes.
+func compressMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		encoding := acceptedEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding}
+		defer cw.Close() //nolint:errcheck
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// acceptedEncoding returns "gzip" or "deflate", whichever header (an
+// Accept-Encoding value) accepts with the higher weight, gzip on a tie, or
+// "" when it accepts neither.
+func acceptedEncoding(header string) string {
+	weights := map[string]float64{}
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		switch name {
+		case "gzip", "deflate":
+			weights[name] = q
+		case "*":
+			for _, enc := range []string{"gzip", "deflate"} {
+				if _, ok := weights[enc]; !ok {
+					weights[enc] = q
+				}
+			}
+		}
+	}
+	gz, df := weights["gzip"], weights["deflate"]
+	switch {
+	case gz > 0 && gz >= df:
+		return "gzip"
+	case df > 0:
+		return "deflate"
+	}
+	return ""
+}
+
+// compressWriter holds back the status and the first compressMinBytes of a
+// compressible response, then either compresses the rest of it or, when
+// the response ends first, writes it as is.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+
+	status      int
+	wroteHeader bool // the status has been passed on
+	passthrough bool // the response is written uncompressed
+	buf         []byte
+	enc         io.WriteCloser
+}
+
+func (c *compressWriter) WriteHeader(code int) {
+	if c.status != 0 {
+		return
+	}
+	c.status = code
+	mediaType, _, _ := mime.ParseMediaType(c.Header().Get("Content-Type"))
+	if !compressibleTypes[mediaType] || c.Header().Get("Content-Encoding") != "" {
+		c.passthrough = true
+		c.wroteHeader = true
+		c.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (c *compressWriter) Write(p []byte) (int, error) {
+	if c.status == 0 {
+		c.WriteHeader(http.StatusOK)
+	}
+	if c.passthrough {
+		return c.ResponseWriter.Write(p)
+	}
+	if c.enc != nil {
+		return c.enc.Write(p)
+	}
+	c.buf = append(c.buf, p...)
+	if len(c.buf) < compressMinBytes {
+		return len(p), nil
+	}
+	if err := c.startEncoding(); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// startEncoding sends the headers of a compressed response and compresses
+// the buffered body.
+func (c *compressWriter) startEncoding() error {
+	h := c.Header()
+	h.Set("Content-Encoding", c.encoding)
+	h.Del("Content-Length")
+	c.wroteHeader = true
+	c.ResponseWriter.WriteHeader(c.status)
+	if c.encoding == "gzip" {
+		c.enc = gzip.NewWriter(c.ResponseWriter)
+	} else {
+		c.enc, _ = flate.NewWriter(c.ResponseWriter, flate.DefaultCompression)
+	}
+	buf := c.buf
+	c.buf = nil
+	_, err := c.enc.Write(buf)
+	return err
+}
+
+// Flush sends what has been written so far, compressing it if the body is
+// already being compressed.
+func (c *compressWriter) Flush() {
+	if !c.passthrough && c.enc == nil {
+		c.writePlain() //nolint:errcheck
+	}
+	if f, ok := c.enc.(interface{ Flush() error }); ok {
+		f.Flush() //nolint:errcheck
+	}
+	http.NewResponseController(c.ResponseWriter).Flush() //nolint:errcheck
+}
+
+// writePlain gives up on compression and writes the buffered body as is.
+func (c *compressWriter) writePlain() error {
+	c.passthrough = true
+	if !c.wroteHeader {
+		c.wroteHeader = true
+		if c.status == 0 {
+			c.status = http.StatusOK
+		}
+		c.ResponseWriter.WriteHeader(c.status)
+	}
+	buf := c.buf
+	c.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	_, err := c.ResponseWriter.Write(buf)
+	return err
+}
+
+// Close ends the response: it finishes the compressed stream, or writes a
+// body too short to compress as is.
+func (c *compressWriter) Close() error {
+	if c.enc != nil {
+		return c.enc.Close()
+	}
+	if c.passthrough || c.status == 0 && len(c.buf) == 0 {
+		return nil
+	}
+	return c.writePlain()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (c *compressWriter) Unwrap() http.ResponseWriter { return c.ResponseWriter }
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// --- acceptedEncoding ---
+
+func TestAcceptedEncoding(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"deflate", "deflate"},
+		{"gzip, deflate, br", "gzip"},
+		{"GZIP", "gzip"},
+		{"deflate, gzip;q=0.5", "deflate"},
+		{"gzip;q=0, deflate", "deflate"},
+		{"gzip;q=0", ""},
+		{"*", "gzip"},
+		{"*;q=0, deflate", "deflate"},
+		{"br, identity", ""},
+	}
+	for _, tt := range tests {
+		if got := acceptedEncoding(tt.header); got != tt.want {
+			t.Errorf("acceptedEncoding(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+// --- compressMiddleware ---
+
+func TestCompressMiddleware(t *testing.T) {
+	long := strings.Repeat("transcribed text ", 200)
+	handler := compressMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/long":
+			writeJSON(w, http.StatusCreated, TranscribeResponse{Text: long})
+		case "/short":
+			writeJSON(w, http.StatusOK, TranscribeResponse{Text: "hi"})
+		case "/csv", "/tsv", "/ttml":
+			w.Header().Set("Content-Type", formatContentTypes[strings.TrimPrefix(r.URL.Path, "/")])
+			io.WriteString(w, long) //nolint:errcheck
+		case "/stream":
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			io.WriteString(w, long) //nolint:errcheck
+		}
+	}))
+
+	tests := []struct {
+		path     string
+		accept   string
+		encoding string
+	}{
+		{"/long", "gzip", "gzip"},
+		{"/long", "deflate", "deflate"},
+		{"/long", "", ""},
+		{"/short", "gzip", ""},
+		{"/csv", "gzip", "gzip"},
+		{"/tsv", "gzip", "gzip"},
+		{"/ttml", "gzip", "gzip"},
+		{"/stream", "gzip", ""},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		r.Header.Set("Accept-Encoding", tt.accept)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+
+		if got := rec.Header().Get("Content-Encoding"); got != tt.encoding {
+			t.Errorf("%s %q: Content-Encoding = %q, want %q", tt.path, tt.accept, got, tt.encoding)
+			continue
+		}
+		var body io.Reader = rec.Body
+		switch tt.encoding {
+		case "gzip":
+			zr, err := gzip.NewReader(rec.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body = zr
+		case "deflate":
+			body = flate.NewReader(rec.Body)
+		}
+		data, err := io.ReadAll(body)
+		if err != nil {
+			t.Fatalf("%s %q: read body: %v", tt.path, tt.accept, err)
+		}
+		if tt.path == "/long" && (!strings.Contains(string(data), long) || rec.Code != http.StatusCreated) {
+			t.Errorf("%s %q: status %d, body not the transcript", tt.path, tt.accept, rec.Code)
+		}
+		if tt.path == "/short" && !strings.Contains(string(data), `"hi"`) {
+			t.Errorf("%s: body = %q", tt.path, data)
+		}
+		if !strings.Contains(rec.Header().Get("Vary"), "Accept-Encoding") {
+			t.Errorf("%s %q: missing Vary: Accept-Encoding", tt.path, tt.accept)
+		}
+	}
+}
@@ -0,0 +1,748 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anatolykoptev/moonshine-whisper/pkg/moonshine"
+	"gopkg.in/yaml.v3"
+)
+
+// appConfig holds all service configuration. Values come from defaults,
+// then an optional YAML file (--config), then environment variables.
+type appConfig struct {
+	Port         string         `yaml:"port"`
+	ModelsDir    string         `yaml:"models_dir"`
+	RUModelsDir  string         `yaml:"ru_models_dir"`
+	ZHModelsDir  string         `yaml:"zh_models_dir"`
+	PunctModel   string         `yaml:"punct_model"`
+	PunctVocab   string         `yaml:"punct_vocab"`
+	NumThreads   int            `yaml:"threads"`
+	ModelThreads map[string]int `yaml:"model_threads"` // language -> ONNX threads, overriding threads
+	PoolSize     int            `yaml:"recognizer_pool_size"`
+	PoolSizes    map[string]int `yaml:"recognizer_pool_sizes"` // language -> recognizers, overriding recognizer_pool_size
+	Provider     string         `yaml:"provider"`              // ONNX Runtime execution provider; ""=platform default
+	LazyLoad     bool           `yaml:"lazy_load"`             // load each language model on its first request
+
+	ModelDirs       map[string]string `yaml:"model_dirs"`       // language -> model directory, for any number of languages
+	ModelBackends   map[string]string `yaml:"model_backends"`   // language -> moonshine, zipformer, or whisper
+	StreamingModels map[string]string `yaml:"streaming_models"` // language -> streaming model directory
+	TelephonyModels map[string]string `yaml:"telephony_models"` // language -> 8 kHz telephony model directory
+
+	AccurateModelsDir string `yaml:"accurate_models_dir"` // larger Moonshine (EN) model for quality=accurate
+	TranslateModelDir string `yaml:"translate_model_dir"` // multilingual Whisper for task=translate
+
+	ModelPrecision string `yaml:"model_precision"` // auto, int8, or fp32 model files
+
+	AdminAddr string `yaml:"admin_addr"`
+
+	TLSCert           string        `yaml:"tls_cert"`
+	TLSKey            string        `yaml:"tls_key"`
+	TLSReloadInterval time.Duration `yaml:"tls_reload_interval"`
+
+	RUDecodingMethod string `yaml:"ru_decoding_method"`
+	RUBeamSize       int    `yaml:"ru_beam_size"`
+
+	HotwordsFile  string  `yaml:"hotwords_file"`
+	HotwordsScore float64 `yaml:"hotwords_score"`
+
+	LMModels map[string]string  `yaml:"lm_models"` // language -> LM fused into Zipformer beam search
+	LMScales map[string]float64 `yaml:"lm_scales"` // language -> LM weight; default 0.5
+
+	VADModel        string  `yaml:"vad_model"`
+	VADBackend      string  `yaml:"vad_backend"` // silero or ten
+	VADThreshold    float64 `yaml:"vad_threshold"`
+	VADMinSilenceS  float64 `yaml:"vad_min_silence_s"`
+	VADMinSpeechS   float64 `yaml:"vad_min_speech_s"`
+	VADMinDurationS float64 `yaml:"vad_min_duration_s"`
+	ChunkOverlapS   float64 `yaml:"chunk_overlap_s"` // seconds shared by pieces of long audio split without VAD
+
+	MaxAudioDurationS float64 `yaml:"max_audio_duration_s"`
+	NativeDecode      bool    `yaml:"native_decode"`
+
+	FFmpegPath     string        `yaml:"ffmpeg_path"`
+	FFprobePath    string        `yaml:"ffprobe_path"`
+	ProbeInput     bool          `yaml:"probe_input"` // check files with ffprobe before converting them
+	FFmpegArgs     []string      `yaml:"ffmpeg_args"`
+	FFmpegTimeout  time.Duration `yaml:"ffmpeg_timeout"`
+	FFmpegMaxProcs int           `yaml:"ffmpeg_max_procs"`
+
+	TempDir       string        `yaml:"temp_dir"`
+	TempMinFreeMB int           `yaml:"temp_min_free_mb"`
+	TempMaxAge    time.Duration `yaml:"temp_max_age"` // 0 = an hour, or twice RequestTimeout
+
+	RequestTimeout time.Duration `yaml:"request_timeout"`
+
+	HallucinationMaxRatio   float64 `yaml:"hallucination_max_ratio"`
+	HallucinationMaxRepeats int     `yaml:"hallucination_max_repeats"`
+	HallucinationBlocklist  string  `yaml:"hallucination_blocklist"`
+
+	SelftestDir    string  `yaml:"selftest_dir"`     // <lang>.wav reference recordings with <lang>.txt transcripts
+	SelftestMaxWER float64 `yaml:"selftest_max_wer"` // word error rate up to which a language passes
+
+	DiarizeSegmentationModel string  `yaml:"diarize_segmentation_model"`
+	DiarizeEmbeddingModel    string  `yaml:"diarize_embedding_model"`
+	DiarizeThreshold         float64 `yaml:"diarize_threshold"`
+	DiarizeMaxSpeakers       int     `yaml:"diarize_max_speakers"`
+
+	TaggingModel     string `yaml:"tagging_model"`
+	TaggingModelType string `yaml:"tagging_model_type"`
+	TaggingLabels    string `yaml:"tagging_labels"`
+	TaggingTopK      int    `yaml:"tagging_top_k"`
+
+	LIDEncoder string `yaml:"lid_encoder"`
+	LIDDecoder string `yaml:"lid_decoder"`
+
+	SpeakerModel     string  `yaml:"speaker_embedding_model"`
+	SpeakerDB        string  `yaml:"speaker_db"`
+	SpeakerThreshold float64 `yaml:"speaker_threshold"`
+
+	EmotionModelDir string `yaml:"emotion_model_dir"`
+
+	DenoiseModel string `yaml:"denoise_model"`
+
+	DownloadMaxMB        int           `yaml:"download_max_mb"`
+	DownloadTimeout      time.Duration `yaml:"download_timeout"`
+	DownloadAllowPrivate bool          `yaml:"download_allow_private"`
+	InlineMaxMB          int           `yaml:"inline_max_mb"`
+	UploadMaxMB          int           `yaml:"upload_max_mb"`
+
+	MaxConcurrent  int `yaml:"max_concurrent"`
+	MaxQueued      int `yaml:"max_queued"`
+	ShedQueueDepth int `yaml:"shed_queue_depth"`
+
+	CacheSize     int           `yaml:"cache_size"`
+	CacheTTL      time.Duration `yaml:"cache_ttl"`
+	DedupRequests bool          `yaml:"dedup_requests"`
+
+	JobWorkers   int           `yaml:"job_workers"`
+	JobQueueSize int           `yaml:"job_queue_size"`
+	JobRetention time.Duration `yaml:"job_retention"`
+	JobStore     string        `yaml:"job_store"`
+	JobRedisURL  string        `yaml:"job_redis_url"`
+	JobDBPath    string        `yaml:"job_db_path"`
+
+	CallbackRetries int           `yaml:"callback_retries"`
+	CallbackBackoff time.Duration `yaml:"callback_backoff"`
+	CallbackSecret  string        `yaml:"callback_secret"` // signs callbacks and RTP webhooks; "" = unsigned
+
+	CallbackAllowPrivate bool `yaml:"callback_allow_private"`
+
+	TranscriptDB string `yaml:"transcript_db"`
+	RetainAudio  string `yaml:"retain_audio"` // directory or s3://bucket/prefix; "" = audio is deleted
+	RetainWAV    bool   `yaml:"retain_wav"`
+
+	WatchDir      string        `yaml:"watch_dir"`
+	WatchInterval time.Duration `yaml:"watch_interval"`
+	WatchFormats  []string      `yaml:"watch_formats"`
+	WatchLanguage string        `yaml:"watch_language"`
+
+	RTPAddr        string        `yaml:"rtp_addr"`
+	RTPLanguage    string        `yaml:"rtp_language"`
+	RTPWebhookURL  string        `yaml:"rtp_webhook_url"`
+	RTPIdleTimeout time.Duration `yaml:"rtp_idle_timeout"`
+	RTPMaxCalls    int           `yaml:"rtp_max_calls"`
+
+	KafkaBrokers      []string `yaml:"kafka_brokers"`
+	KafkaRequestTopic string   `yaml:"kafka_request_topic"`
+	KafkaResultTopic  string   `yaml:"kafka_result_topic"`
+	KafkaGroupID      string   `yaml:"kafka_group_id"`
+
+	NATSURL           string `yaml:"nats_url"`
+	NATSSubject       string `yaml:"nats_subject"`
+	NATSQueueGroup    string `yaml:"nats_queue_group"`
+	NATSStream        string `yaml:"nats_stream"`
+	NATSResultSubject string `yaml:"nats_result_subject"`
+
+	CORSAllowedOrigins []string `yaml:"cors_allowed_origins"`
+	CORSAllowedMethods []string `yaml:"cors_allowed_methods"`
+	CORSAllowedHeaders []string `yaml:"cors_allowed_headers"`
+
+	CompressResponses bool `yaml:"compress_responses"`
+
+	LogRequests bool   `yaml:"log_requests"`
+	LogFile     string `yaml:"log_file"`
+
+	AuditLog     string `yaml:"audit_log"`
+	AuditLogText bool   `yaml:"audit_log_text"`
+
+	Tenants []TenantConfig `yaml:"tenants"` // YAML only; API keys required when set
+
+	Schedules []ScheduleConfig `yaml:"schedules"` // YAML only
+}
+
+var cfg appConfig
+
+// defaultConfig returns the built-in configuration.
+func defaultConfig() appConfig {
+	return appConfig{
+		Port:              "8092",
+		ModelsDir:         "/models",
+		RUModelsDir:       "/ru-models",
+		ZHModelsDir:       "/zh-models",
+		TranslateModelDir: "/translate",
+		PunctModel:        "/punct/model.int8.onnx",
+		PunctVocab:        "/punct/bpe.vocab",
+		ModelPrecision:    moonshine.PrecisionAuto,
+		NumThreads:        4,
+		PoolSize:          1,
+		VADModel:          "/vad/silero_vad.onnx",
+		VADThreshold:      0.5,
+		VADMinSilenceS:    0.5,
+		VADMinSpeechS:     0.25,
+		VADMinDurationS:   10,
+		ChunkOverlapS:     1,
+		MaxAudioDurationS: 300,
+		NativeDecode:      true,
+		FFmpegPath:        "ffmpeg",
+		FFprobePath:       "ffprobe",
+		ProbeInput:        true,
+		FFmpegTimeout:     5 * time.Minute,
+		TempDir:           os.TempDir(),
+		TempMinFreeMB:     100,
+		DownloadMaxMB:     100,
+		DownloadTimeout:   time.Minute,
+		InlineMaxMB:       10,
+		UploadMaxMB:       100,
+		JobWorkers:        1,
+		JobQueueSize:      100,
+		JobRetention:      time.Hour,
+		JobStore:          jobStoreMemory,
+		JobDBPath:         "jobs.db",
+		CallbackRetries:   5,
+		CallbackBackoff:   time.Second,
+		CompressResponses: true,
+		LogRequests:       true,
+
+		DiarizeSegmentationModel: "/diarize/segmentation.onnx",
+		DiarizeEmbeddingModel:    "/diarize/embedding.onnx",
+		DiarizeThreshold:         0.5,
+		TaggingModel:             "/tagging/model.int8.onnx",
+		TaggingModelType:         moonshine.TaggingZipformer,
+		TaggingLabels:            "/tagging/class_labels_indices.csv",
+		TaggingTopK:              5,
+		LIDEncoder:               "/lid/tiny-encoder.int8.onnx",
+		LIDDecoder:               "/lid/tiny-decoder.int8.onnx",
+		SpeakerModel:             "/diarize/embedding.onnx",
+		SpeakerThreshold:         0.5,
+		EmotionModelDir:          "/emotion",
+		DenoiseModel:             "/denoise/gtcrn_simple.onnx",
+		HotwordsScore:            1.5,
+		RUDecodingMethod:         "greedy_search",
+		RUBeamSize:               4,
+
+		RequestTimeout: 10 * time.Minute,
+
+		CORSAllowedMethods: []string{"GET", "POST", "PUT", "OPTIONS"},
+		CORSAllowedHeaders: []string{"Content-Type", "Authorization"},
+
+		HallucinationMaxRatio:   2.4,
+		HallucinationMaxRepeats: 5,
+		SelftestMaxWER:          0.2,
+
+		MaxConcurrent: 2,
+		MaxQueued:     32,
+
+		CacheSize:     256,
+		CacheTTL:      10 * time.Minute,
+		DedupRequests: true,
+
+		WatchInterval: 5 * time.Second,
+		WatchFormats:  []string{outputText, outputJSON, outputSRT},
+		WatchLanguage: "en",
+
+		RTPLanguage:    "en",
+		RTPIdleTimeout: 5 * time.Second,
+		RTPMaxCalls:    32,
+
+		KafkaRequestTopic: "transcribe-requests",
+		KafkaResultTopic:  "transcribe-results",
+		KafkaGroupID:      "moonshine-whisper",
+
+		NATSSubject:       "transcribe",
+		NATSQueueGroup:    "moonshine-whisper",
+		NATSResultSubject: "transcribe.results",
+	}
+}
+
+// loadConfig builds the configuration from defaults, the YAML file at path
+// (skipped when empty), and environment overrides, then validates it.
+func loadConfig(path string) (appConfig, error) {
+	c := defaultConfig()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return c, fmt.Errorf("read config: %w", err)
+		}
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&c); err != nil && !errors.Is(err, io.EOF) {
+			return c, fmt.Errorf("parse config %s: %w", path, err)
+		}
+	}
+	if err := c.applyEnv(); err != nil {
+		return c, err
+	}
+	return c, c.validate()
+}
+
+// applyEnv overrides fields from environment variables that are set.
+func (c *appConfig) applyEnv() error {
+	var e envLoader
+	e.str(&c.Port, "MOONSHINE_PORT")
+	e.str(&c.ModelsDir, "MOONSHINE_MODELS_DIR")
+	e.str(&c.RUModelsDir, "ZIPFORMER_RU_DIR")
+	e.str(&c.ZHModelsDir, "PARAFORMER_ZH_DIR")
+	e.mapping(&c.ModelDirs, "MODEL_DIRS")
+	e.prefixed(&c.ModelDirs, "MODEL_DIR_")
+	e.mapping(&c.ModelBackends, "MODEL_BACKENDS")
+	e.mapping(&c.StreamingModels, "STREAMING_MODELS")
+	e.mapping(&c.TelephonyModels, "TELEPHONY_MODELS")
+	e.str(&c.AccurateModelsDir, "MOONSHINE_ACCURATE_MODELS_DIR")
+	e.str(&c.TranslateModelDir, "TRANSLATE_MODEL_DIR")
+	e.str(&c.ModelPrecision, "MODEL_PRECISION")
+	e.str(&c.PunctModel, "PUNCT_MODEL")
+	e.str(&c.PunctVocab, "PUNCT_VOCAB")
+	e.integer(&c.NumThreads, "MOONSHINE_THREADS")
+	e.ints(&c.ModelThreads, "MODEL_THREADS")
+	e.integer(&c.PoolSize, "RECOGNIZER_POOL_SIZE")
+	e.ints(&c.PoolSizes, "RECOGNIZER_POOL_SIZES")
+	e.str(&c.Provider, "ONNX_PROVIDER")
+	e.boolean(&c.LazyLoad, "LAZY_LOAD")
+	e.str(&c.AdminAddr, "MOONSHINE_ADMIN_ADDR")
+	e.str(&c.TLSCert, "MOONSHINE_TLS_CERT")
+	e.str(&c.TLSKey, "MOONSHINE_TLS_KEY")
+	e.seconds(&c.TLSReloadInterval, "MOONSHINE_TLS_RELOAD_S")
+	e.str(&c.RUDecodingMethod, "RU_DECODING_METHOD")
+	e.integer(&c.RUBeamSize, "RU_BEAM_SIZE")
+	e.str(&c.HotwordsFile, "HOTWORDS_FILE")
+	e.float(&c.HotwordsScore, "HOTWORDS_SCORE")
+	e.mapping(&c.LMModels, "LM_MODELS")
+	e.floats(&c.LMScales, "LM_SCALES")
+	e.str(&c.VADModel, "SILERO_VAD_MODEL")
+	e.str(&c.VADModel, "VAD_MODEL")
+	e.str(&c.VADBackend, "VAD_BACKEND")
+	e.float(&c.VADThreshold, "VAD_THRESHOLD")
+	e.float(&c.VADMinSilenceS, "VAD_MIN_SILENCE_S")
+	e.float(&c.VADMinSpeechS, "VAD_MIN_SPEECH_S")
+	e.float(&c.VADMinDurationS, "VAD_MIN_DURATION_S")
+	e.float(&c.ChunkOverlapS, "CHUNK_OVERLAP_S")
+	e.float(&c.MaxAudioDurationS, "MAX_AUDIO_DURATION_S")
+	e.boolean(&c.NativeDecode, "NATIVE_DECODE")
+	e.str(&c.FFmpegPath, "FFMPEG_PATH")
+	e.str(&c.FFprobePath, "FFPROBE_PATH")
+	e.boolean(&c.ProbeInput, "PROBE_INPUT")
+	e.fields(&c.FFmpegArgs, "FFMPEG_ARGS")
+	e.seconds(&c.FFmpegTimeout, "FFMPEG_TIMEOUT_S")
+	e.integer(&c.FFmpegMaxProcs, "FFMPEG_MAX_PROCS")
+	e.str(&c.TempDir, "TEMP_DIR")
+	e.integer(&c.TempMinFreeMB, "TEMP_MIN_FREE_MB")
+	e.seconds(&c.TempMaxAge, "TEMP_MAX_AGE_S")
+	e.seconds(&c.RequestTimeout, "REQUEST_TIMEOUT_S")
+	e.float(&c.HallucinationMaxRatio, "HALLUCINATION_MAX_RATIO")
+	e.integer(&c.HallucinationMaxRepeats, "HALLUCINATION_MAX_REPEATS")
+	e.str(&c.HallucinationBlocklist, "HALLUCINATION_BLOCKLIST")
+	e.str(&c.SelftestDir, "SELFTEST_DIR")
+	e.float(&c.SelftestMaxWER, "SELFTEST_MAX_WER")
+	e.str(&c.DiarizeSegmentationModel, "DIARIZE_SEGMENTATION_MODEL")
+	e.str(&c.DiarizeEmbeddingModel, "DIARIZE_EMBEDDING_MODEL")
+	e.float(&c.DiarizeThreshold, "DIARIZE_THRESHOLD")
+	e.integer(&c.DiarizeMaxSpeakers, "DIARIZE_MAX_SPEAKERS")
+	e.str(&c.TaggingModel, "TAGGING_MODEL")
+	e.str(&c.TaggingModelType, "TAGGING_MODEL_TYPE")
+	e.str(&c.TaggingLabels, "TAGGING_LABELS")
+	e.integer(&c.TaggingTopK, "TAGGING_TOP_K")
+	e.str(&c.LIDEncoder, "LID_ENCODER")
+	e.str(&c.LIDDecoder, "LID_DECODER")
+	e.str(&c.SpeakerModel, "SPEAKER_EMBEDDING_MODEL")
+	e.str(&c.SpeakerDB, "SPEAKER_DB")
+	e.float(&c.SpeakerThreshold, "SPEAKER_THRESHOLD")
+	e.str(&c.EmotionModelDir, "EMOTION_MODEL_DIR")
+	e.str(&c.DenoiseModel, "DENOISE_MODEL")
+	e.integer(&c.DownloadMaxMB, "DOWNLOAD_MAX_MB")
+	e.seconds(&c.DownloadTimeout, "DOWNLOAD_TIMEOUT_S")
+	e.boolean(&c.DownloadAllowPrivate, "DOWNLOAD_ALLOW_PRIVATE")
+	e.integer(&c.InlineMaxMB, "INLINE_MAX_MB")
+	e.integer(&c.UploadMaxMB, "UPLOAD_MAX_MB")
+	e.integer(&c.MaxConcurrent, "MAX_CONCURRENT")
+	e.integer(&c.MaxQueued, "MAX_QUEUED")
+	e.integer(&c.ShedQueueDepth, "SHED_QUEUE_DEPTH")
+	e.integer(&c.CacheSize, "CACHE_SIZE")
+	e.seconds(&c.CacheTTL, "CACHE_TTL_S")
+	e.boolean(&c.DedupRequests, "DEDUP_REQUESTS")
+	e.integer(&c.JobWorkers, "JOB_WORKERS")
+	e.integer(&c.JobQueueSize, "JOB_QUEUE_SIZE")
+	e.seconds(&c.JobRetention, "JOB_RETENTION_S")
+	e.str(&c.JobStore, "JOB_STORE")
+	e.str(&c.JobRedisURL, "JOB_REDIS_URL")
+	e.str(&c.JobDBPath, "JOB_DB_PATH")
+	e.integer(&c.CallbackRetries, "CALLBACK_RETRIES")
+	e.seconds(&c.CallbackBackoff, "CALLBACK_BACKOFF_S")
+	e.str(&c.CallbackSecret, "CALLBACK_SECRET")
+	e.boolean(&c.CallbackAllowPrivate, "CALLBACK_ALLOW_PRIVATE")
+	e.str(&c.TranscriptDB, "TRANSCRIPT_DB")
+	e.str(&c.RetainAudio, "RETAIN_AUDIO")
+	e.boolean(&c.RetainWAV, "RETAIN_WAV")
+	e.str(&c.WatchDir, "WATCH_DIR")
+	e.seconds(&c.WatchInterval, "WATCH_INTERVAL_S")
+	e.list(&c.WatchFormats, "WATCH_FORMATS")
+	e.str(&c.WatchLanguage, "WATCH_LANGUAGE")
+	e.str(&c.RTPAddr, "RTP_ADDR")
+	e.str(&c.RTPLanguage, "RTP_LANGUAGE")
+	e.str(&c.RTPWebhookURL, "RTP_WEBHOOK_URL")
+	e.seconds(&c.RTPIdleTimeout, "RTP_IDLE_TIMEOUT_S")
+	e.integer(&c.RTPMaxCalls, "RTP_MAX_CALLS")
+	e.list(&c.KafkaBrokers, "KAFKA_BROKERS")
+	e.str(&c.KafkaRequestTopic, "KAFKA_REQUEST_TOPIC")
+	e.str(&c.KafkaResultTopic, "KAFKA_RESULT_TOPIC")
+	e.str(&c.KafkaGroupID, "KAFKA_GROUP_ID")
+	e.str(&c.NATSURL, "NATS_URL")
+	e.str(&c.NATSSubject, "NATS_SUBJECT")
+	e.str(&c.NATSQueueGroup, "NATS_QUEUE_GROUP")
+	e.str(&c.NATSStream, "NATS_STREAM")
+	e.str(&c.NATSResultSubject, "NATS_RESULT_SUBJECT")
+	e.list(&c.CORSAllowedOrigins, "CORS_ALLOWED_ORIGINS")
+	e.list(&c.CORSAllowedMethods, "CORS_ALLOWED_METHODS")
+	e.list(&c.CORSAllowedHeaders, "CORS_ALLOWED_HEADERS")
+	e.boolean(&c.CompressResponses, "COMPRESS_RESPONSES")
+	e.boolean(&c.LogRequests, "LOG_REQUESTS")
+	e.str(&c.LogFile, "LOG_FILE")
+	e.str(&c.AuditLog, "AUDIT_LOG")
+	e.boolean(&c.AuditLogText, "AUDIT_LOG_TEXT")
+	return errors.Join(e.errs...)
+}
+
+// validate rejects configurations the service cannot run with.
+func (c *appConfig) validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+	check(c.Port != "", "port must be set")
+	check(c.ModelsDir != "", "models_dir must be set")
+	check(c.NumThreads > 0, "threads must be > 0, got %d", c.NumThreads)
+	for lang, n := range c.ModelThreads {
+		check(c.knownLanguage(lang), "model_threads languages must be en, ru, zh, or a model_dirs language, got %q", lang)
+		check(n > 0, "model_threads for %q must be > 0, got %d", lang, n)
+	}
+	check(c.PoolSize > 0, "recognizer_pool_size must be > 0, got %d", c.PoolSize)
+	for lang, n := range c.PoolSizes {
+		check(c.knownLanguage(lang), "recognizer_pool_sizes languages must be en, ru, zh, or a model_dirs language, got %q", lang)
+		check(n > 0, "recognizer_pool_sizes for %q must be > 0, got %d", lang, n)
+	}
+	check(moonshine.ValidProvider(c.Provider), "provider must be one of %s on this platform, got %q",
+		strings.Join(moonshine.Providers(), ", "), c.Provider)
+	check(moonshine.ValidPrecision(c.ModelPrecision), "model_precision must be auto, int8, or fp32, got %q", c.ModelPrecision)
+	for lang, dir := range c.ModelDirs {
+		check(moonshine.ValidLanguage(lang), "model_dirs languages must be 2-3 lowercase letters, got %q", lang)
+		check(dir != "", "model_dirs directory for %q must be set", lang)
+	}
+	for lang, backend := range c.ModelBackends {
+		check(c.knownLanguage(lang), "model_backends languages must be en, ru, zh, or a model_dirs language, got %q", lang)
+		check(moonshine.ValidBackend(backend), "model_backends backend for %q must be moonshine, zipformer, whisper, paraformer, sensevoice, nemo_ctc, or nemo_transducer, got %q", lang, backend)
+	}
+	for lang, dir := range c.StreamingModels {
+		check(c.knownLanguage(lang), "streaming_models languages must be en, ru, zh, or a model_dirs language, got %q", lang)
+		check(dir != "", "streaming_models directory for %q must be set", lang)
+	}
+	for lang, dir := range c.TelephonyModels {
+		check(c.knownLanguage(lang), "telephony_models languages must be en, ru, zh, or a model_dirs language, got %q", lang)
+		check(dir != "", "telephony_models directory for %q must be set", lang)
+	}
+	check(c.AdminAddr == "" || validHostPort(c.AdminAddr), "admin_addr must be host:port, got %q", c.AdminAddr)
+	check((c.TLSCert == "") == (c.TLSKey == ""), "tls_cert and tls_key must be set together")
+	check(c.TLSReloadInterval >= 0, "tls_reload_interval must be >= 0, got %s", c.TLSReloadInterval)
+	check(moonshine.ValidDecodingMethod(c.RUDecodingMethod), "ru_decoding_method must be greedy_search or modified_beam_search, got %q", c.RUDecodingMethod)
+	check(c.RUBeamSize > 0 && c.RUBeamSize <= moonshine.MaxBeamSize, "ru_beam_size must be in [1, %d], got %d", moonshine.MaxBeamSize, c.RUBeamSize)
+	check(c.HotwordsScore > 0, "hotwords_score must be > 0, got %g", c.HotwordsScore)
+	check(c.HotwordsFile == "" || c.RUDecodingMethod == moonshine.ModifiedBeamSearch, "hotwords_file needs ru_decoding_method modified_beam_search")
+	for lang, path := range c.LMModels {
+		check(c.knownLanguage(lang), "lm_models languages must be en, ru, zh, or a model_dirs language, got %q", lang)
+		check(path != "", "lm_models file for %q must be set", lang)
+	}
+	for lang, scale := range c.LMScales {
+		check(c.LMModels[lang] != "", "lm_scales language %q has no lm_models entry", lang)
+		check(scale > 0, "lm_scales for %q must be > 0, got %g", lang, scale)
+	}
+	check(moonshine.ValidVADBackend(c.VADBackend), "vad_backend must be silero or ten, got %q", c.VADBackend)
+	check(c.VADThreshold > 0 && c.VADThreshold < 1, "vad_threshold must be in (0, 1), got %g", c.VADThreshold)
+	check(c.VADMinSilenceS >= 0, "vad_min_silence_s must be >= 0, got %g", c.VADMinSilenceS)
+	check(c.VADMinSpeechS >= 0, "vad_min_speech_s must be >= 0, got %g", c.VADMinSpeechS)
+	check(c.VADMinDurationS >= 0, "vad_min_duration_s must be >= 0, got %g", c.VADMinDurationS)
+	check(c.ChunkOverlapS >= 0 && c.ChunkOverlapS <= 5, "chunk_overlap_s must be in [0, 5], got %g", c.ChunkOverlapS)
+	check(c.MaxAudioDurationS > 0, "max_audio_duration_s must be > 0, got %g", c.MaxAudioDurationS)
+	check(c.FFmpegPath != "", "ffmpeg_path must be set")
+	check(c.FFprobePath != "", "ffprobe_path must be set")
+	check(c.FFmpegTimeout >= 0, "ffmpeg_timeout must be >= 0, got %s", c.FFmpegTimeout)
+	check(c.FFmpegMaxProcs >= 0, "ffmpeg_max_procs must be >= 0, got %d", c.FFmpegMaxProcs)
+	check(c.TempDir != "", "temp_dir must be set")
+	check(c.TempMinFreeMB >= 0, "temp_min_free_mb must be >= 0, got %d", c.TempMinFreeMB)
+	check(c.HallucinationMaxRatio >= 0, "hallucination_max_ratio must be >= 0, got %g", c.HallucinationMaxRatio)
+	check(c.HallucinationMaxRepeats >= 0, "hallucination_max_repeats must be >= 0, got %d", c.HallucinationMaxRepeats)
+	check(c.SelftestMaxWER >= 0 && c.SelftestMaxWER <= 1, "selftest_max_wer must be in [0, 1], got %g", c.SelftestMaxWER)
+	check(c.RequestTimeout >= 0, "request_timeout must be >= 0, got %s", c.RequestTimeout)
+	check(c.TempMaxAge >= 0, "temp_max_age must be >= 0, got %s", c.TempMaxAge)
+	check(c.TempMaxAge == 0 || c.RequestTimeout == 0 || c.TempMaxAge > c.RequestTimeout,
+		"temp_max_age must exceed request_timeout (%s), got %s", c.RequestTimeout, c.TempMaxAge)
+	check(c.DiarizeThreshold > 0, "diarize_threshold must be > 0, got %g", c.DiarizeThreshold)
+	check(c.DiarizeMaxSpeakers >= 0, "diarize_max_speakers must be >= 0, got %d", c.DiarizeMaxSpeakers)
+	check(c.TaggingModelType == moonshine.TaggingZipformer || c.TaggingModelType == moonshine.TaggingCED,
+		"tagging_model_type must be zipformer or ced, got %q", c.TaggingModelType)
+	check(c.SpeakerThreshold > 0 && c.SpeakerThreshold < 1, "speaker_threshold must be in (0, 1), got %g", c.SpeakerThreshold)
+	check(c.TaggingTopK > 0 && c.TaggingTopK <= maxTaggingTopK, "tagging_top_k must be in [1, %d], got %d", maxTaggingTopK, c.TaggingTopK)
+	check(c.DownloadMaxMB > 0, "download_max_mb must be > 0, got %d", c.DownloadMaxMB)
+	check(c.DownloadTimeout > 0, "download_timeout must be > 0, got %s", c.DownloadTimeout)
+	check(c.InlineMaxMB > 0, "inline_max_mb must be > 0, got %d", c.InlineMaxMB)
+	check(c.UploadMaxMB > 0, "upload_max_mb must be > 0, got %d", c.UploadMaxMB)
+	check(c.MaxConcurrent >= 0, "max_concurrent must be >= 0, got %d", c.MaxConcurrent)
+	check(c.MaxQueued >= 0, "max_queued must be >= 0, got %d", c.MaxQueued)
+	check(c.ShedQueueDepth >= 0, "shed_queue_depth must be >= 0, got %d", c.ShedQueueDepth)
+	check(c.CacheSize >= 0, "cache_size must be >= 0, got %d", c.CacheSize)
+	check(c.CacheSize == 0 || c.CacheTTL > 0, "cache_ttl must be > 0, got %s", c.CacheTTL)
+	check(c.JobWorkers > 0, "job_workers must be > 0, got %d", c.JobWorkers)
+	check(c.JobQueueSize > 0, "job_queue_size must be > 0, got %d", c.JobQueueSize)
+	check(c.JobRetention > 0, "job_retention must be > 0, got %s", c.JobRetention)
+	check(c.JobStore == jobStoreMemory || c.JobStore == jobStoreRedis || c.JobStore == jobStoreBolt,
+		"job_store must be memory, redis, or bolt, got %q", c.JobStore)
+	check(c.JobStore != jobStoreRedis || c.JobRedisURL != "", "job_redis_url must be set when job_store is redis")
+	check(c.JobStore != jobStoreBolt || c.JobDBPath != "", "job_db_path must be set when job_store is bolt")
+	check(c.CallbackRetries >= 0, "callback_retries must be >= 0, got %d", c.CallbackRetries)
+	check(c.CallbackBackoff > 0, "callback_backoff must be > 0, got %s", c.CallbackBackoff)
+	check(!isObjectURI(c.RetainAudio) || strings.HasPrefix(c.RetainAudio, "s3://"), "retain_audio must be a directory or an s3:// URI, got %q", c.RetainAudio)
+	check(!c.RetainWAV || c.RetainAudio != "", "retain_wav needs retain_audio")
+	check(c.WatchDir == "" || c.WatchInterval > 0, "watch_interval must be > 0, got %s", c.WatchInterval)
+	for _, f := range c.WatchFormats {
+		check(f == outputText || f == outputJSON || f == outputSRT, "watch_formats must be txt, json, or srt, got %q", f)
+	}
+	schedules := make(map[string]bool)
+	for i, sc := range c.Schedules {
+		check(sc.Name != "", "schedules[%d].name must be set", i)
+		check(!schedules[sc.Name], "schedules names must be unique, got %q twice", sc.Name)
+		schedules[sc.Name] = true
+		_, err := parseCron(sc.Cron)
+		check(err == nil, "schedule %q: %v", sc.Name, err)
+		for _, target := range []string{sc.Source, sc.Destination} {
+			check(target != "" && (!isObjectURI(target) || strings.HasPrefix(target, "s3://")),
+				"schedule %q source and destination must be a directory or an s3:// URI, got %q", sc.Name, target)
+		}
+		check(sc.Source != sc.Destination, "schedule %q destination must differ from source", sc.Name)
+		for _, f := range sc.Formats {
+			check(slices.Contains([]string{outputText, outputJSON, outputSRT, outputVTT, outputTTML, outputCSV, outputTSV}, f),
+				"schedule %q formats must be txt, json, srt, vtt, ttml, csv, or tsv, got %q", sc.Name, f)
+		}
+		check(sc.Language == "" || c.knownLanguage(normLang(sc.Language)), "schedule %q language must be en, ru, zh, or a model_dirs language, got %q", sc.Name, sc.Language)
+	}
+	check(c.RTPAddr == "" || validHostPort(c.RTPAddr), "rtp_addr must be host:port, got %q", c.RTPAddr)
+	check(c.RTPWebhookURL == "" || validHTTPURL(c.RTPWebhookURL), "rtp_webhook_url must be an absolute http(s) URL, got %q", c.RTPWebhookURL)
+	check(c.RTPIdleTimeout > 0, "rtp_idle_timeout must be > 0, got %s", c.RTPIdleTimeout)
+	check(c.RTPMaxCalls > 0, "rtp_max_calls must be > 0, got %d", c.RTPMaxCalls)
+	for _, b := range c.KafkaBrokers {
+		check(validHostPort(b), "kafka_brokers must be host:port, got %q", b)
+	}
+	if len(c.KafkaBrokers) > 0 {
+		check(c.KafkaRequestTopic != "" && c.KafkaResultTopic != "", "kafka_request_topic and kafka_result_topic must be set when kafka_brokers is set")
+		check(c.KafkaRequestTopic != c.KafkaResultTopic, "kafka_result_topic must differ from kafka_request_topic, got %q", c.KafkaResultTopic)
+		check(c.KafkaGroupID != "", "kafka_group_id must be set when kafka_brokers is set")
+	}
+	if c.NATSURL != "" {
+		check(c.NATSSubject != "" || c.NATSStream != "", "nats_subject or nats_stream must be set when nats_url is set")
+		check(c.NATSQueueGroup != "", "nats_queue_group must be set when nats_url is set")
+		check(c.NATSStream == "" || c.NATSResultSubject != "", "nats_result_subject must be set when nats_stream is set")
+		check(c.NATSResultSubject != c.NATSSubject, "nats_result_subject must differ from nats_subject, got %q", c.NATSResultSubject)
+	}
+	names, keys := make(map[string]bool), make(map[string]bool)
+	for i, t := range c.Tenants {
+		check(t.Name != "", "tenants[%d].name must be set", i)
+		check(!names[t.Name], "tenants names must be unique, got %q twice", t.Name)
+		names[t.Name] = true
+		check(len(t.APIKeys) > 0, "tenant %q must have api_keys", t.Name)
+		check(moonshine.ValidPriority(t.Priority), "tenant %q priority must be normal or low, got %q", t.Name, t.Priority)
+		for _, k := range t.APIKeys {
+			check(k != "", "tenant %q api_keys must not be empty", t.Name)
+			check(!keys[k], "tenant %q repeats an API key already listed", t.Name)
+			keys[k] = true
+		}
+		for j, lang := range t.Languages {
+			t.Languages[j] = normLang(lang)
+			check(c.knownLanguage(t.Languages[j]), "tenant %q languages must be en, ru, zh, or a model_dirs language, got %q", t.Name, lang)
+		}
+		msg := validateHotwords(t.Hotwords)
+		check(msg == "", "tenant %q: %s", t.Name, msg)
+		check(t.MaxAudioDurationS >= 0, "tenant %q max_audio_duration_s must be >= 0, got %g", t.Name, t.MaxAudioDurationS)
+		check(t.VADMinDurationS >= 0, "tenant %q vad_min_duration_s must be >= 0, got %g", t.Name, t.VADMinDurationS)
+	}
+	check(len(c.CORSAllowedOrigins) == 0 || len(c.CORSAllowedMethods) > 0,
+		"cors_allowed_methods must not be empty when cors_allowed_origins is set")
+	return errors.Join(errs...)
+}
+
+// knownLanguage reports whether a model can be configured for lang: en, ru,
+// zh, or a language of ModelDirs.
+func (c *appConfig) knownLanguage(lang string) bool {
+	return lang == "en" || lang == "ru" || lang == "zh" || c.ModelDirs[lang] != ""
+}
+
+// validHostPort reports whether s is a listen address such as ":6060" or
+// "127.0.0.1:6060".
+func validHostPort(s string) bool {
+	_, port, err := net.SplitHostPort(s)
+	if err != nil {
+		return false
+	}
+	n, err := strconv.Atoi(port)
+	return err == nil && n > 0 && n < 65536
+}
+
+// envLoader applies environment overrides and collects parse errors.
+type envLoader struct {
+	errs []error
+}
+
+func (e *envLoader) str(dst *string, key string) {
+	if v := os.Getenv(key); v != "" {
+		*dst = v
+	}
+}
+
+func (e *envLoader) integer(dst *int, key string) {
+	if v := os.Getenv(key); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			e.errs = append(e.errs, fmt.Errorf("%s: %w", key, err))
+			return
+		}
+		*dst = n
+	}
+}
+
+func (e *envLoader) float(dst *float64, key string) {
+	if v := os.Getenv(key); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			e.errs = append(e.errs, fmt.Errorf("%s: %w", key, err))
+			return
+		}
+		*dst = f
+	}
+}
+
+func (e *envLoader) boolean(dst *bool, key string) {
+	if v := os.Getenv(key); v != "" {
+		b := parseBoolPtr(v)
+		if b == nil {
+			e.errs = append(e.errs, fmt.Errorf("%s: invalid boolean %q", key, v))
+			return
+		}
+		*dst = *b
+	}
+}
+
+// list parses a comma-separated value, trimming spaces and dropping empty items.
+func (e *envLoader) list(dst *[]string, key string) {
+	if v := os.Getenv(key); v != "" {
+		var items []string
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		*dst = items
+	}
+}
+
+// fields parses a whitespace-separated value, such as command-line arguments.
+func (e *envLoader) fields(dst *[]string, key string) {
+	if v := os.Getenv(key); v != "" {
+		*dst = strings.Fields(v)
+	}
+}
+
+// mapping parses comma-separated key=value pairs, e.g. "en=/a,ru=/b".
+func (e *envLoader) mapping(dst *map[string]string, key string) {
+	var items []string
+	e.list(&items, key)
+	if items == nil {
+		return
+	}
+	m := make(map[string]string, len(items))
+	for _, item := range items {
+		k, v, ok := strings.Cut(item, "=")
+		if !ok {
+			e.errs = append(e.errs, fmt.Errorf("%s: invalid pair %q (want key=value)", key, item))
+			return
+		}
+		m[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	*dst = m
+}
+
+// floats parses a comma-separated list of key=number pairs.
+func (e *envLoader) floats(dst *map[string]float64, key string) {
+	var pairs map[string]string
+	e.mapping(&pairs, key)
+	if pairs == nil {
+		return
+	}
+	m := make(map[string]float64, len(pairs))
+	for k, v := range pairs {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			e.errs = append(e.errs, fmt.Errorf("%s: %s: %w", key, k, err))
+			return
+		}
+		m[k] = f
+	}
+	*dst = m
+}
+
+// ints parses a comma-separated list of key=integer pairs.
+func (e *envLoader) ints(dst *map[string]int, key string) {
+	var pairs map[string]string
+	e.mapping(&pairs, key)
+	if pairs == nil {
+		return
+	}
+	m := make(map[string]int, len(pairs))
+	for k, v := range pairs {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			e.errs = append(e.errs, fmt.Errorf("%s: %s: %w", key, k, err))
+			return
+		}
+		m[k] = n
+	}
+	*dst = m
+}
+
+// prefixed adds an entry to dst for every variable named prefix+KEY, keyed
+// by the lowercased KEY: MODEL_DIR_DE=/de-models sets "de".
+func (e *envLoader) prefixed(dst *map[string]string, prefix string) {
+	for _, kv := range os.Environ() {
+		k, v, _ := strings.Cut(kv, "=")
+		key, ok := strings.CutPrefix(k, prefix)
+		if !ok || key == "" || v == "" {
+			continue
+		}
+		if *dst == nil {
+			*dst = make(map[string]string)
+		}
+		(*dst)[strings.ToLower(key)] = v
+	}
+}
+
+// seconds parses a number of seconds into a duration.
+func (e *envLoader) seconds(dst *time.Duration, key string) {
+	f := dst.Seconds()
+	e.float(&f, key)
+	*dst = time.Duration(f * float64(time.Second))
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfig writes a YAML config file into a temp dir and returns its path.
+func writeConfig(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfig_Defaults(t *testing.T) {
+	c, err := loadConfig("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(c, defaultConfig()) {
+		t.Errorf("loadConfig(\"\") = %+v, want defaults", c)
+	}
+}
+
+func TestLoadConfig_File(t *testing.T) {
+	path := writeConfig(t, `
+port: "9000"
+threads: 8
+vad_threshold: 0.35
+max_audio_duration_s: 600
+job_retention: 30m
+log_requests: false
+`)
+	c, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.Port != "9000" || c.NumThreads != 8 || c.VADThreshold != 0.35 || c.MaxAudioDurationS != 600 {
+		t.Errorf("file values not applied: %+v", c)
+	}
+	if c.JobRetention != 30*time.Minute {
+		t.Errorf("JobRetention = %s, want 30m", c.JobRetention)
+	}
+	if c.LogRequests {
+		t.Error("LogRequests should be false")
+	}
+	if c.ModelsDir != "/models" {
+		t.Errorf("unset key should keep default, got ModelsDir=%q", c.ModelsDir)
+	}
+}
+
+func TestLoadConfig_EmptyFile(t *testing.T) {
+	c, err := loadConfig(writeConfig(t, ""))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(c, defaultConfig()) {
+		t.Error("empty file should yield defaults")
+	}
+}
+
+func TestLoadConfig_EnvOverridesFile(t *testing.T) {
+	path := writeConfig(t, "port: \"9000\"\nthreads: 8\n")
+	t.Setenv("MOONSHINE_PORT", "9100")
+	t.Setenv("JOB_RETENTION_S", "90")
+	t.Setenv("LOG_REQUESTS", "no")
+	t.Setenv("CORS_ALLOWED_ORIGINS", "https://app.example.com, ,http://localhost:3000")
+	t.Setenv("STREAMING_MODELS", "en=/stream/en, ru = /stream/ru")
+	t.Setenv("TELEPHONY_MODELS", "ru=/tel/ru")
+	t.Setenv("FFMPEG_ARGS", "-threads 1  -nostdin")
+	t.Setenv("MODEL_DIRS", "fr=/fr-models")
+	t.Setenv("MODEL_DIR_DE", "/de-models")
+	t.Setenv("MODEL_BACKENDS", "de=whisper")
+	t.Setenv("LM_MODELS", "ru=/lm/ru.onnx")
+	t.Setenv("LM_SCALES", "ru=0.3")
+	t.Setenv("RECOGNIZER_POOL_SIZES", "en=4, ru=1")
+	t.Setenv("MODEL_THREADS", "ru=8")
+
+	c, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.Port != "9100" {
+		t.Errorf("Port = %q, want env override 9100", c.Port)
+	}
+	if c.NumThreads != 8 {
+		t.Errorf("NumThreads = %d, want file value 8", c.NumThreads)
+	}
+	if c.JobRetention != 90*time.Second {
+		t.Errorf("JobRetention = %s, want 90s", c.JobRetention)
+	}
+	if c.LogRequests {
+		t.Error("LogRequests should be overridden to false")
+	}
+	if want := []string{"https://app.example.com", "http://localhost:3000"}; !reflect.DeepEqual(c.CORSAllowedOrigins, want) {
+		t.Errorf("CORSAllowedOrigins = %q, want %q", c.CORSAllowedOrigins, want)
+	}
+	if want := map[string]string{"en": "/stream/en", "ru": "/stream/ru"}; !reflect.DeepEqual(c.StreamingModels, want) {
+		t.Errorf("StreamingModels = %v, want %v", c.StreamingModels, want)
+	}
+	if want := map[string]string{"ru": "/tel/ru"}; !reflect.DeepEqual(c.TelephonyModels, want) {
+		t.Errorf("TelephonyModels = %v, want %v", c.TelephonyModels, want)
+	}
+	if want := []string{"-threads", "1", "-nostdin"}; !reflect.DeepEqual(c.FFmpegArgs, want) {
+		t.Errorf("FFmpegArgs = %q, want %q", c.FFmpegArgs, want)
+	}
+	if want := map[string]string{"fr": "/fr-models", "de": "/de-models"}; !reflect.DeepEqual(c.ModelDirs, want) {
+		t.Errorf("ModelDirs = %v, want %v", c.ModelDirs, want)
+	}
+	if want := map[string]float64{"ru": 0.3}; !reflect.DeepEqual(c.LMScales, want) {
+		t.Errorf("LMScales = %v, want %v", c.LMScales, want)
+	}
+	if want := map[string]int{"en": 4, "ru": 1}; !reflect.DeepEqual(c.PoolSizes, want) {
+		t.Errorf("PoolSizes = %v, want %v", c.PoolSizes, want)
+	}
+	if want := map[string]int{"ru": 8}; !reflect.DeepEqual(c.ModelThreads, want) {
+		t.Errorf("ModelThreads = %v, want %v", c.ModelThreads, want)
+	}
+}
+
+func TestLoadConfig_UnknownKey(t *testing.T) {
+	_, err := loadConfig(writeConfig(t, "prot: 9000\n"))
+	if err == nil || !strings.Contains(err.Error(), "prot") {
+		t.Errorf("expected unknown-field error mentioning key, got %v", err)
+	}
+}
+
+func TestLoadConfig_MissingFile(t *testing.T) {
+	if _, err := loadConfig("/nonexistent/config.yaml"); err == nil {
+		t.Error("expected error for missing config file")
+	}
+}
+
+func TestLoadConfig_InvalidEnv(t *testing.T) {
+	t.Setenv("MOONSHINE_THREADS", "four")
+	t.Setenv("LOG_REQUESTS", "maybe")
+	t.Setenv("STREAMING_MODELS", "/stream/en")
+	_, err := loadConfig("")
+	if err == nil {
+		t.Fatal("expected error for invalid env values")
+	}
+	for _, key := range []string{"MOONSHINE_THREADS", "LOG_REQUESTS", "STREAMING_MODELS"} {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("error %q should mention %s", err, key)
+		}
+	}
+}
+
+func TestLoadConfig_Validation(t *testing.T) {
+	tests := []struct {
+		body string
+		want string
+	}{
+		{"threads: 0", "threads"},
+		{"recognizer_pool_size: 0", "recognizer_pool_size"},
+		{"model_precision: fp16", "model_precision"},
+		{"provider: tpu", "provider"},
+		{"provider: rocm", "provider"},
+		{"streaming_models: {de: /stream/de}", "streaming_models"},
+		{"telephony_models: {en: \"\"}", "telephony_models"},
+		{"model_backends: {en: kaldi}", "model_backends"},
+		{"model_backends: {de: whisper}", "model_backends"},
+		{"model_dirs: {German: /de-models}", "model_dirs"},
+		{"model_dirs: {de: ''}", "model_dirs"},
+		{"lm_models: {de: /lm/de.onnx}", "lm_models"},
+		{"lm_scales: {ru: 0.3}", "lm_scales"},
+		{"recognizer_pool_sizes: {de: 2}", "recognizer_pool_sizes"},
+		{"model_threads: {ru: -1}", "model_threads"},
+		{"selftest_max_wer: 1.5", "selftest_max_wer"},
+		{"model_threads: {xx: 2}", "model_threads"},
+		{"recognizer_pool_sizes: {en: 0}", "recognizer_pool_sizes"},
+		{"lm_models: {ru: /lm/ru.onnx}\nlm_scales: {ru: 0}", "lm_scales"},
+		{"vad_threshold: 1.5", "vad_threshold"},
+		{"max_audio_duration_s: 0", "max_audio_duration_s"},
+		{"vad_min_duration_s: -1", "vad_min_duration_s"},
+		{"vad_backend: webrtc", "vad_backend"},
+		{"chunk_overlap_s: 6", "chunk_overlap_s"},
+		{"ffmpeg_path: \"\"", "ffmpeg_path"},
+		{"ffmpeg_timeout: -1s", "ffmpeg_timeout"},
+		{"ffmpeg_max_procs: -1", "ffmpeg_max_procs"},
+		{"temp_dir: \"\"", "temp_dir"},
+		{"temp_min_free_mb: -1", "temp_min_free_mb"},
+		{"temp_max_age: -1s", "temp_max_age"},
+		{"temp_max_age: 5m", "temp_max_age"},
+		{"upload_max_mb: 0", "upload_max_mb"},
+		{"job_queue_size: -3", "job_queue_size"},
+		{"max_concurrent: -1", "max_concurrent"},
+		{"max_queued: -1", "max_queued"},
+		{"cache_size: -1", "cache_size"},
+		{"cache_ttl: 0s", "cache_ttl"},
+		{"diarize_max_speakers: -1", "diarize_max_speakers"},
+		{"tagging_model_type: beats", "tagging_model_type"},
+		{"tagging_top_k: 0", "tagging_top_k"},
+		{"speaker_threshold: 1", "speaker_threshold"},
+		{"hotwords_score: 0", "hotwords_score"},
+		{"ru_decoding_method: beam", "ru_decoding_method"},
+		{"ru_beam_size: 0", "ru_beam_size"},
+		{"hotwords_file: /hotwords.txt", "hotwords_file needs ru_decoding_method modified_beam_search"},
+		{"request_timeout: -1s", "request_timeout"},
+		{"hallucination_max_ratio: -1", "hallucination_max_ratio"},
+		{"hallucination_max_repeats: -2", "hallucination_max_repeats"},
+		{"admin_addr: \"6060\"", "admin_addr"},
+		{"tls_cert: /certs/server.crt", "tls_key"},
+		{"tls_reload_interval: -1s", "tls_reload_interval"},
+		{"watch_dir: /in\nwatch_interval: 0s", "watch_interval"},
+		{"watch_formats: [txt, docx]", "watch_formats"},
+		{"job_store: sqlite", "job_store"},
+		{"job_store: redis", "job_redis_url"},
+		{"job_store: bolt\njob_db_path: \"\"", "job_db_path"},
+		{"callback_retries: -1", "callback_retries"},
+		{"callback_backoff: 0s", "callback_backoff"},
+		{"rtp_addr: \"5004\"", "rtp_addr"},
+		{"rtp_webhook_url: ftp://example.com", "rtp_webhook_url"},
+		{"rtp_idle_timeout: 0s", "rtp_idle_timeout"},
+		{"rtp_max_calls: 0", "rtp_max_calls"},
+		{"kafka_brokers: [kafka]", "kafka_brokers"},
+		{"kafka_brokers: [\"kafka:9092\"]\nkafka_request_topic: \"\"", "kafka_request_topic"},
+		{"kafka_brokers: [\"kafka:9092\"]\nkafka_result_topic: transcribe-requests", "kafka_result_topic"},
+		{"kafka_brokers: [\"kafka:9092\"]\nkafka_group_id: \"\"", "kafka_group_id"},
+		{"nats_url: nats://nats:4222\nnats_subject: \"\"", "nats_subject"},
+		{"nats_url: nats://nats:4222\nnats_queue_group: \"\"", "nats_queue_group"},
+		{"nats_url: nats://nats:4222\nnats_stream: TRANSCRIBE\nnats_result_subject: \"\"", "nats_result_subject"},
+		{"nats_url: nats://nats:4222\nnats_result_subject: transcribe", "nats_result_subject"},
+		{"cors_allowed_origins: [\"*\"]\ncors_allowed_methods: []", "cors_allowed_methods"},
+		{"tenants: [{api_keys: [k1]}]", "name"},
+		{"tenants: [{name: a}]", "api_keys"},
+		{"tenants: [{name: a, api_keys: [k1]}, {name: a, api_keys: [k2]}]", "unique"},
+		{"tenants: [{name: a, api_keys: [k1]}, {name: b, api_keys: [k1]}]", "API key"},
+		{"tenants: [{name: a, api_keys: [k1], languages: [de]}]", "languages"},
+		{"tenants: [{name: a, api_keys: [k1], hotwords: [{phrase: \"a/b\"}]}]", "hotword"},
+		{"tenants: [{name: a, api_keys: [k1], max_audio_duration_s: -1}]", "max_audio_duration_s"},
+		{"tenants: [{name: a, api_keys: [k1], vad_min_duration_s: -1}]", "vad_min_duration_s"},
+		{"schedules: [{cron: \"@daily\", source: /in, destination: /out}]", "name"},
+		{"schedules: [{name: a, cron: \"* * *\", source: /in, destination: /out}]", "5 fields"},
+		{"schedules: [{name: a, cron: \"@daily\", destination: /out}]", "source"},
+		{"schedules: [{name: a, cron: \"@daily\", source: \"gs://b/in\", destination: /out}]", "s3://"},
+		{"schedules: [{name: a, cron: \"@daily\", source: /in, destination: /in}]", "differ"},
+		{"schedules: [{name: a, cron: \"@daily\", source: /in, destination: /out, formats: [docx]}]", "formats"},
+		{"schedules: [{name: a, cron: \"@daily\", source: /in, destination: /out, language: de}]", "language"},
+		{"schedules: [{name: a, cron: \"@daily\", source: /a, destination: /b}, {name: a, cron: \"@daily\", source: /c, destination: /d}]", "unique"},
+		{"port: \"\"", "port"},
+	}
+	for _, tt := range tests {
+		_, err := loadConfig(writeConfig(t, tt.body))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("config %q: error = %v, want mention of %s", tt.body, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// corsMaxAge is how long (seconds) browsers may cache a preflight response.
+const corsMaxAge = "600"
+
+// corsMiddleware adds CORS headers for requests from allowed origins and
+// answers preflight requests itself. "*" in origins allows any origin.
+// X-Request-ID is exposed to scripts of allowed origins.
+// Requests from other origins pass through without CORS headers, so the
+// browser blocks them; their preflights get a 403.
+func corsMiddleware(next http.Handler, origins, methods, headers []string) http.Handler {
+	allowMethods := strings.Join(methods, ", ")
+	allowHeaders := strings.Join(headers, ", ")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		h := w.Header()
+		h.Add("Vary", "Origin")
+		allowed := originAllowed(origin, origins)
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		if preflight {
+			if !allowed {
+				writeError(w, http.StatusForbidden, "origin not allowed")
+				return
+			}
+			h.Set("Access-Control-Allow-Origin", origin)
+			h.Set("Access-Control-Allow-Methods", allowMethods)
+			h.Set("Access-Control-Allow-Headers", allowHeaders)
+			h.Set("Access-Control-Max-Age", corsMaxAge)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if allowed {
+			h.Set("Access-Control-Allow-Origin", origin)
+			h.Set("Access-Control-Expose-Headers", requestIDHeader)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// originAllowed reports whether origin matches an entry of origins
+// (case-insensitively) or origins contains "*".
+func originAllowed(origin string, origins []string) bool {
+	return slices.ContainsFunc(origins, func(o string) bool {
+		return o == "*" || strings.EqualFold(o, origin)
+	})
+}
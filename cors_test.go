@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// --- corsMiddleware ---
+
+func TestCORSMiddleware(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	h := corsMiddleware(next, []string{"https://app.example.com"},
+		[]string{"GET", "POST"}, []string{"Content-Type"})
+
+	tests := []struct {
+		name        string
+		method      string
+		origin      string
+		preflight   bool
+		wantStatus  int
+		wantOrigin  string
+		wantMethods string
+	}{
+		{"no origin", http.MethodPost, "", false, http.StatusTeapot, "", ""},
+		{"allowed origin", http.MethodPost, "https://app.example.com", false, http.StatusTeapot, "https://app.example.com", ""},
+		{"origin case-insensitive", http.MethodPost, "https://APP.example.com", false, http.StatusTeapot, "https://APP.example.com", ""},
+		{"other origin", http.MethodPost, "https://evil.example", false, http.StatusTeapot, "", ""},
+		{"preflight allowed", http.MethodOptions, "https://app.example.com", true, http.StatusNoContent, "https://app.example.com", "GET, POST"},
+		{"preflight other origin", http.MethodOptions, "https://evil.example", true, http.StatusForbidden, "", ""},
+		{"plain OPTIONS", http.MethodOptions, "https://app.example.com", false, http.StatusTeapot, "https://app.example.com", ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "/transcribe/upload", nil)
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+		if tt.preflight {
+			req.Header.Set("Access-Control-Request-Method", "POST")
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d", tt.name, rec.Code, tt.wantStatus)
+		}
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+			t.Errorf("%s: Allow-Origin = %q, want %q", tt.name, got, tt.wantOrigin)
+		}
+		if got := rec.Header().Get("Access-Control-Allow-Methods"); got != tt.wantMethods {
+			t.Errorf("%s: Allow-Methods = %q, want %q", tt.name, got, tt.wantMethods)
+		}
+		if got := rec.Header().Get("Access-Control-Expose-Headers"); (got != "") != (tt.wantOrigin != "" && !tt.preflight) {
+			t.Errorf("%s: Expose-Headers = %q", tt.name, got)
+		}
+	}
+}
+
+func TestCORSMiddleware_PreflightHeaders(t *testing.T) {
+	h := corsMiddleware(http.NotFoundHandler(), []string{"*"},
+		[]string{"POST"}, []string{"Content-Type", "Authorization"})
+	req := httptest.NewRequest(http.MethodOptions, "/transcribe", nil)
+	req.Header.Set("Origin", "http://localhost:3000")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	want := map[string]string{
+		"Access-Control-Allow-Origin":  "http://localhost:3000",
+		"Access-Control-Allow-Headers": "Content-Type, Authorization",
+		"Access-Control-Max-Age":       corsMaxAge,
+		"Vary":                         "Origin",
+	}
+	for k, v := range want {
+		if got := rec.Header().Get(k); got != v {
+			t.Errorf("%s = %q, want %q", k, got, v)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+)
+
+// downloadClient fetches remote audio; per-request limits come from cfg.
+// Private addresses are refused unless cfg.DownloadAllowPrivate.
+var downloadClient = &http.Client{Transport: guardedTransport(func() bool { return cfg.DownloadAllowPrivate })}
+
+// validHTTPURL reports whether s is an absolute http(s) URL.
+func validHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// downloadAudio fetches rawURL into a temp file, enforcing cfg.DownloadTimeout,
+// cfg.DownloadMaxMB, and an audio/video content type. The download is
+// aborted when ctx is done.
+// Returns the temp path (caller removes it) or an HTTP status and error.
+func downloadAudio(ctx context.Context, rawURL string) (string, int, error) {
+	maxBytes := int64(cfg.DownloadMaxMB) << 20
+	ctx, cancel := context.WithTimeout(ctx, cfg.DownloadTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return "", http.StatusBadRequest, fmt.Errorf("audio_url: %w", err)
+	}
+	resp, err := downloadClient.Do(req)
+	if errors.Is(err, errPrivateAddress) {
+		return "", http.StatusBadRequest, fmt.Errorf("audio_url: %w; set DOWNLOAD_ALLOW_PRIVATE to allow it", err)
+	}
+	if err != nil {
+		return "", http.StatusBadGateway, fmt.Errorf("download: %w", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		return "", http.StatusBadGateway, fmt.Errorf("download: remote returned %s", resp.Status)
+	}
+	ct := resp.Header.Get("Content-Type")
+	if !audioContentType(ct) {
+		return "", http.StatusUnsupportedMediaType, fmt.Errorf("download: unsupported content type %q", ct)
+	}
+	if resp.ContentLength > maxBytes {
+		return "", http.StatusRequestEntityTooLarge,
+			fmt.Errorf("download: %d bytes exceeds limit of %d", resp.ContentLength, maxBytes)
+	}
+	return saveBody(resp.Body, downloadExt(req.URL.Path, ct), maxBytes)
+}
+
+// decodeInlineAudio decodes base64 audio (plain or a data: URI) into a temp
+// file, enforcing cfg.InlineMaxMB on the decoded size. ctx is unused; the
+// parameter matches the other audio fetchers.
+// Returns the temp path (caller removes it) or an HTTP status and error.
+func decodeInlineAudio(_ context.Context, encoded string) (string, int, error) {
+	maxBytes := int64(cfg.InlineMaxMB) << 20
+	mediaType := ""
+	if rest, ok := strings.CutPrefix(encoded, "data:"); ok {
+		meta, data, found := strings.Cut(rest, ",")
+		if !found || !strings.HasSuffix(meta, ";base64") {
+			return "", http.StatusBadRequest, fmt.Errorf("audio_base64: data URI must be base64-encoded")
+		}
+		mediaType, encoded = strings.TrimSuffix(meta, ";base64"), data
+	}
+	if int64(base64.StdEncoding.DecodedLen(len(encoded))) > maxBytes+2 {
+		return "", http.StatusRequestEntityTooLarge,
+			fmt.Errorf("audio_base64: decoded audio exceeds limit of %d bytes", maxBytes)
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", http.StatusBadRequest, fmt.Errorf("audio_base64: %w", err)
+	}
+	if len(data) == 0 {
+		return "", http.StatusBadRequest, fmt.Errorf("audio_base64: empty audio")
+	}
+	ext := downloadExt("", mediaType)
+	if isWAVHeader(data) {
+		ext = ".wav"
+	}
+	return saveBody(bytes.NewReader(data), ext, maxBytes)
+}
+
+// isWAVHeader reports whether data starts with a RIFF/WAVE header.
+func isWAVHeader(data []byte) bool {
+	return len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WAVE"
+}
+
+// maxJSONBodyBytes bounds JSON request bodies: the inline audio limit plus
+// base64 overhead and room for the other fields.
+func maxJSONBodyBytes() int64 {
+	return (int64(cfg.InlineMaxMB)<<20)*4/3 + 64<<10
+}
+
+// saveBody streams body into a new temp file with the given extension,
+// failing with 413 once more than maxBytes have been read.
+func saveBody(body io.Reader, ext string, maxBytes int64) (string, int, error) {
+	tmpFile, err := newTempPath(ext)
+	if err != nil {
+		return "", http.StatusInsufficientStorage, err
+	}
+	out, err := os.Create(tmpFile)
+	if err != nil {
+		return "", http.StatusInternalServerError, fmt.Errorf("save temp: %w", err)
+	}
+	n, err := io.Copy(out, io.LimitReader(body, maxBytes+1))
+	_ = out.Close()
+	if err != nil {
+		os.Remove(tmpFile) //nolint:errcheck
+		return "", http.StatusBadGateway, fmt.Errorf("download: %w", err)
+	}
+	if n > maxBytes {
+		os.Remove(tmpFile) //nolint:errcheck
+		return "", http.StatusRequestEntityTooLarge,
+			fmt.Errorf("download: body exceeds limit of %d bytes", maxBytes)
+	}
+	return tmpFile, http.StatusOK, nil
+}
+
+// audioContentType reports whether a downloaded body may contain audio.
+// Generic binary and missing types are accepted since many object stores
+// serve audio that way.
+func audioContentType(ct string) bool {
+	if ct == "" {
+		return true
+	}
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(mt, "audio/"), strings.HasPrefix(mt, "video/"):
+		return true
+	case mt == "application/ogg", mt == "application/octet-stream":
+		return true
+	}
+	return false
+}
+
+// downloadExt picks a file extension for a download, preferring the URL path
+// and falling back to the content type. ffmpeg probes the actual format, so
+// the extension only matters for routing WAV around conversion.
+func downloadExt(urlPath, ct string) string {
+	if ext := path.Ext(urlPath); ext != "" && len(ext) <= 5 {
+		return strings.ToLower(ext)
+	}
+	mt, _, _ := mime.ParseMediaType(ct)
+	switch mt {
+	case "audio/wav", "audio/x-wav", "audio/wave", "audio/vnd.wave":
+		return ".wav"
+	}
+	return ".audio"
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withDownloadLimits sets download limits for the duration of a test, and
+// lets downloads reach the loopback test servers.
+func withDownloadLimits(t *testing.T, maxMB int, timeout time.Duration) {
+	t.Helper()
+	oldMB, oldTimeout, oldPrivate := cfg.DownloadMaxMB, cfg.DownloadTimeout, cfg.DownloadAllowPrivate
+	cfg.DownloadMaxMB, cfg.DownloadTimeout, cfg.DownloadAllowPrivate = maxMB, timeout, true
+	t.Cleanup(func() {
+		cfg.DownloadMaxMB, cfg.DownloadTimeout, cfg.DownloadAllowPrivate = oldMB, oldTimeout, oldPrivate
+	})
+}
+
+// --- validHTTPURL ---
+
+func TestValidHTTPURL(t *testing.T) {
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{"http://example.com/hook", true},
+		{"https://example.com:8443/a?b=c", true},
+		{"ftp://example.com/x", false},
+		{"/relative/path", false},
+		{"http://", false},
+		{"not a url", false},
+	}
+	for _, tt := range tests {
+		if got := validHTTPURL(tt.in); got != tt.want {
+			t.Errorf("validHTTPURL(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+// --- audioContentType ---
+
+func TestAudioContentType(t *testing.T) {
+	tests := []struct {
+		ct   string
+		want bool
+	}{
+		{"", true},
+		{"audio/mpeg", true},
+		{"audio/wav; codecs=1", true},
+		{"video/mp4", true},
+		{"application/ogg", true},
+		{"application/octet-stream", true},
+		{"text/html; charset=utf-8", false},
+		{"application/json", false},
+		{";;;", false},
+	}
+	for _, tt := range tests {
+		if got := audioContentType(tt.ct); got != tt.want {
+			t.Errorf("audioContentType(%q) = %v, want %v", tt.ct, got, tt.want)
+		}
+	}
+}
+
+// --- downloadExt ---
+
+func TestDownloadExt(t *testing.T) {
+	tests := []struct {
+		path, ct, want string
+	}{
+		{"/a/b/clip.MP3", "", ".mp3"},
+		{"/clip.wav", "application/octet-stream", ".wav"},
+		{"/presigned", "audio/x-wav", ".wav"},
+		{"/presigned", "audio/ogg", ".audio"},
+		{"/weird.extension", "audio/wav", ".wav"},
+	}
+	for _, tt := range tests {
+		if got := downloadExt(tt.path, tt.ct); got != tt.want {
+			t.Errorf("downloadExt(%q, %q) = %q, want %q", tt.path, tt.ct, got, tt.want)
+		}
+	}
+}
+
+// --- downloadAudio ---
+
+func TestDownloadAudio_OK(t *testing.T) {
+	withDownloadLimits(t, 1, 5*time.Second)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "audio/mpeg")
+		w.Write([]byte("ID3fake")) //nolint:errcheck
+	}))
+	defer srv.Close()
+
+	path, status, err := downloadAudio(context.Background(), srv.URL+"/clip.mp3")
+	if err != nil {
+		t.Fatalf("unexpected error: %v (status %d)", err, status)
+	}
+	defer os.Remove(path) //nolint:errcheck
+	if !strings.HasSuffix(path, ".mp3") {
+		t.Errorf("path = %q, want .mp3 suffix", path)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "ID3fake" {
+		t.Errorf("file content = %q", data)
+	}
+}
+
+func TestDownloadAudio_Errors(t *testing.T) {
+	withDownloadLimits(t, 1, 5*time.Second)
+	big := strings.Repeat("x", 1<<20+1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			http.NotFound(w, r)
+		case "/html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html>")) //nolint:errcheck
+		case "/big":
+			w.Header().Set("Content-Type", "audio/wav")
+			w.Write([]byte(big)) //nolint:errcheck
+		case "/chunked":
+			w.Header().Set("Content-Type", "audio/wav")
+			for range 2 {
+				w.Write([]byte(big[:1<<19+1])) //nolint:errcheck
+				w.(http.Flusher).Flush()
+			}
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/missing", http.StatusBadGateway},
+		{"/html", http.StatusUnsupportedMediaType},
+		{"/big", http.StatusRequestEntityTooLarge},
+		{"/chunked", http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		path, status, err := downloadAudio(context.Background(), srv.URL+tt.path)
+		if err == nil {
+			os.Remove(path) //nolint:errcheck
+			t.Errorf("%s: expected error", tt.path)
+			continue
+		}
+		if status != tt.want {
+			t.Errorf("%s: status = %d, want %d (%v)", tt.path, status, tt.want, err)
+		}
+	}
+}
+
+func TestDownloadAudio_Timeout(t *testing.T) {
+	withDownloadLimits(t, 1, 50*time.Millisecond)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(500 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	if _, status, err := downloadAudio(context.Background(), srv.URL); err == nil || status != http.StatusBadGateway {
+		t.Errorf("expected timeout error with 502, got status %d err %v", status, err)
+	}
+}
+
+// --- decodeInlineAudio ---
+
+func TestDecodeInlineAudio(t *testing.T) {
+	oldMB := cfg.InlineMaxMB
+	cfg.InlineMaxMB = 1
+	t.Cleanup(func() { cfg.InlineMaxMB = oldMB })
+
+	wav := append([]byte("RIFF\x00\x00\x00\x00WAVE"), make([]byte, 32)...)
+	tests := []struct {
+		name    string
+		in      string
+		wantExt string
+		status  int
+	}{
+		{"wav sniffed", base64.StdEncoding.EncodeToString(wav), ".wav", http.StatusOK},
+		{"data uri", "data:audio/mpeg;base64," + base64.StdEncoding.EncodeToString([]byte("ID3")), ".audio", http.StatusOK},
+		{"data uri wav type", "data:audio/wav;base64," + base64.StdEncoding.EncodeToString([]byte("xx")), ".wav", http.StatusOK},
+		{"not base64", "!!!", "", http.StatusBadRequest},
+		{"data uri not base64", "data:audio/wav,abc", "", http.StatusBadRequest},
+		{"empty after decode", "data:audio/wav;base64,", "", http.StatusBadRequest},
+		{"too large", base64.StdEncoding.EncodeToString(make([]byte, 1<<20+10)), "", http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		path, status, err := decodeInlineAudio(context.Background(), tt.in)
+		if status != tt.status {
+			t.Errorf("%s: status = %d, want %d (err %v)", tt.name, status, tt.status, err)
+		}
+		if err != nil {
+			continue
+		}
+		if !strings.HasSuffix(path, tt.wantExt) {
+			t.Errorf("%s: path = %q, want suffix %q", tt.name, path, tt.wantExt)
+		}
+		os.Remove(path) //nolint:errcheck
+	}
+}
+
+func TestDownloadAudio_PrivateAddress(t *testing.T) {
+	withDownloadLimits(t, 1, 5*time.Second)
+	cfg.DownloadAllowPrivate = false
+	hits := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Header().Set("Content-Type", "audio/mpeg")
+		w.Write([]byte("ID3fake")) //nolint:errcheck
+	}))
+	defer srv.Close()
+
+	_, status, err := downloadAudio(context.Background(), srv.URL+"/clip.mp3")
+	if status != http.StatusBadRequest || err == nil || !strings.Contains(err.Error(), "DOWNLOAD_ALLOW_PRIVATE") {
+		t.Errorf("loopback: status %d, err %v; want 400 naming DOWNLOAD_ALLOW_PRIVATE", status, err)
+	}
+	if hits != 0 {
+		t.Errorf("server hit %d times, want the connection refused", hits)
+	}
+}
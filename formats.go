@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anatolykoptev/moonshine-whisper/pkg/moonshine"
+)
+
+// Response formats of the transcription endpoints, picked with the format
+// parameter or the Accept header.
+const (
+	responseJSON  = "json"
+	responseText  = "text"
+	responseCSV   = "csv"
+	responseTSV   = "tsv"
+	responseVTT   = "vtt"
+	responseTTML  = "ttml"
+	responseJSONL = "jsonl" // segments as they are decoded, see transcriptWriter
+)
+
+// errFormat is the message for an unknown format parameter.
+const errFormat = "format must be json, jsonl, text, csv, tsv, vtt, or ttml"
+
+// formatContentTypes are the Content-Type headers of the non-JSON formats.
+var formatContentTypes = map[string]string{
+	responseText:  "text/plain; charset=utf-8",
+	responseCSV:   "text/csv; charset=utf-8",
+	responseTSV:   "text/tab-separated-values; charset=utf-8",
+	responseVTT:   "text/vtt; charset=utf-8",
+	responseTTML:  "application/ttml+xml; charset=utf-8",
+	responseJSONL: "application/jsonl",
+}
+
+// responseFormat returns the format to answer r in: format when set,
+// otherwise text when the Accept header prefers text/plain to JSON, and
+// JSON by default. ok is false for an unknown format.
+func responseFormat(r *http.Request, format string) (string, bool) {
+	switch format {
+	case responseJSON, responseJSONL, responseText, responseCSV, responseTSV, responseVTT, responseTTML:
+		return format, true
+	case "":
+		if acceptQuality(r.Header.Get("Accept"), "text/plain") > acceptQuality(r.Header.Get("Accept"), "application/json") {
+			return responseText, true
+		}
+		return responseJSON, true
+	}
+	return "", false
+}
+
+// acceptQuality returns the q-value accept gives mediaType, from an exact
+// match, a type/* range, or */*; 0 when it is not acceptable.
+func acceptQuality(accept, mediaType string) float64 {
+	best, specificity := 0.0, -1
+	for _, part := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		s := -1
+		switch {
+		case mt == mediaType:
+			s = 2
+		case strings.HasSuffix(mt, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(mt, "*")):
+			s = 1
+		case mt == "*/*":
+			s = 0
+		}
+		if s <= specificity {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		best, specificity = q, s
+	}
+	return best
+}
+
+// writeTranscript writes resp with the given HTTP status in format: JSON,
+// the transcript alone for text, a segment table for CSV and TSV, or
+// subtitles for WebVTT and TTML. On
+// failure the non-JSON formats carry the error message as plain text.
+func writeTranscript(w http.ResponseWriter, format string, status int, resp TranscribeResponse) {
+	w.Header().Add("Vary", "Accept")
+	if resp.retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(resp.retryAfter))
+	}
+	var body string
+	switch {
+	case format == responseJSON:
+		writeJSON(w, status, resp)
+		return
+	case resp.Error != "":
+		body, format = resp.Error+"\n", responseText
+	case format == responseCSV:
+		body = formatTable(resp, ',')
+	case format == responseTSV:
+		body = formatTable(resp, '\t')
+	case format == responseVTT:
+		body = formatVTT(resp)
+	case format == responseTTML:
+		body = formatTTML(resp)
+	default:
+		body = resp.Text + "\n"
+	}
+	w.Header().Set("Content-Type", formatContentTypes[format])
+	w.WriteHeader(status)
+	w.Write([]byte(body)) //nolint:errcheck
+}
+
+// formatTable renders resp as a start, end, speaker, text table with a
+// header row, one row per cue (see subtitleCues), with fields separated by
+// comma. Times are in seconds; fields holding the separator, quotes, or
+// line breaks are quoted.
+func formatTable(resp TranscribeResponse, comma rune) string {
+	var b strings.Builder
+	cw := csv.NewWriter(&b)
+	cw.Comma = comma
+	cw.Write([]string{"start", "end", "speaker", "text"}) //nolint:errcheck
+	for _, s := range subtitleCues(resp) {
+		if s.Text == "" {
+			continue
+		}
+		cw.Write([]string{fmt.Sprintf("%.3f", s.Start), fmt.Sprintf("%.3f", s.End), s.Speaker, s.Text}) //nolint:errcheck
+	}
+	cw.Flush()
+	return b.String()
+}
+
+// transcriptWriter writes the response of a transcription endpoint in its
+// format. For JSON Lines it streams: a {"segment": ...} line for each
+// segment as it is decoded, then a {"result": ...} line with the rest of
+// the response, or an {"error": ...} line. The 200 status goes out with
+// the first segment, so a request failing before then still gets its own.
+type transcriptWriter struct {
+	w      http.ResponseWriter
+	format string
+
+	mu   sync.Mutex
+	sent int // segment lines written
+}
+
+// jsonlLine is one line of a JSON Lines response.
+type jsonlLine struct {
+	Segment *moonshine.Segment  `json:"segment,omitempty"`
+	Result  *TranscribeResponse `json:"result,omitempty"`
+	Error   string              `json:"error,omitempty"`
+}
+
+// onSegment returns the moonshine.Options.OnSegment callback for the
+// format: nil unless it streams segments.
+func (t *transcriptWriter) onSegment() func(moonshine.Segment) {
+	if t.format != responseJSONL {
+		return nil
+	}
+	return func(s moonshine.Segment) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if t.sent == 0 {
+			t.start(http.StatusOK)
+		}
+		t.line(jsonlLine{Segment: &s})
+		t.sent++
+	}
+}
+
+// write finishes the response with resp and its HTTP status. JSON Lines
+// responses whose segments were not streamed, as when cached, get them now.
+func (t *transcriptWriter) write(status int, resp TranscribeResponse) {
+	if t.format != responseJSONL {
+		writeTranscript(t.w, t.format, status, resp)
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.sent == 0 {
+		if resp.retryAfter > 0 {
+			t.w.Header().Set("Retry-After", strconv.Itoa(resp.retryAfter))
+		}
+		t.start(status)
+	}
+	if resp.Error != "" {
+		t.line(jsonlLine{Error: resp.Error})
+		return
+	}
+	if t.sent == 0 {
+		for _, s := range resp.Segments {
+			t.line(jsonlLine{Segment: &s})
+		}
+	}
+	resp.Segments = nil
+	t.line(jsonlLine{Result: &resp})
+}
+
+// start sends the JSON Lines headers with status.
+func (t *transcriptWriter) start(status int) {
+	t.w.Header().Add("Vary", "Accept")
+	t.w.Header().Set("Content-Type", formatContentTypes[responseJSONL])
+	t.w.WriteHeader(status)
+}
+
+// line writes v as one line and flushes it to the client. Each line gets
+// the server's write timeout afresh, so a stream longer than that is not
+// cut off between segments.
+func (t *transcriptWriter) line(v jsonlLine) {
+	rc := http.NewResponseController(t.w)
+	rc.SetWriteDeadline(time.Now().Add(serverTimeout)) //nolint:errcheck
+	json.NewEncoder(t.w).Encode(v)                     //nolint:errcheck
+	rc.Flush()                                         //nolint:errcheck
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anatolykoptev/moonshine-whisper/pkg/moonshine"
+)
+
+// --- responseFormat ---
+
+func TestResponseFormat(t *testing.T) {
+	tests := []struct {
+		format, accept string
+		want           string
+		ok             bool
+	}{
+		{"", "", responseJSON, true},
+		{"", "*/*", responseJSON, true},
+		{"", "text/plain", responseText, true},
+		{"", "text/*", responseText, true},
+		{"", "application/json, text/plain;q=0.5", responseJSON, true},
+		{"", "text/plain, application/json;q=0.9", responseText, true},
+		{"", "text/plain;q=0.9, */*;q=0.8", responseText, true},
+		{"", "text/plain;q=0.2, */*;q=0.8", responseJSON, true},
+		{"", "text/html", responseJSON, true},
+		{"text", "application/json", responseText, true},
+		{"json", "text/plain", responseJSON, true},
+		{"tsv", "", responseTSV, true},
+		{"vtt", "", responseVTT, true},
+		{"ttml", "", responseTTML, true},
+		{"jsonl", "", responseJSONL, true},
+		{"csv", "text/plain", responseCSV, true},
+		{"xml", "", "", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/transcribe", nil)
+		if tt.accept != "" {
+			r.Header.Set("Accept", tt.accept)
+		}
+		got, ok := responseFormat(r, tt.format)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("responseFormat(%q, Accept %q) = %q, %v; want %q, %v", tt.format, tt.accept, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// --- writeTranscript ---
+
+func TestWriteTranscript(t *testing.T) {
+	tests := []struct {
+		format   string
+		status   int
+		resp     TranscribeResponse
+		wantType string
+		wantBody string
+	}{
+		{responseText, http.StatusOK, TranscribeResponse{Text: "hello world", DurationMs: 5}, "text/plain; charset=utf-8", "hello world\n"},
+		{responseText, http.StatusBadRequest, TranscribeResponse{Error: "audio too long"}, "text/plain; charset=utf-8", "audio too long\n"},
+		{responseJSON, http.StatusOK, TranscribeResponse{Text: "hi"}, "application/json", `{"text":"hi","duration_ms":0}` + "\n"},
+		{responseTSV, http.StatusOK, TranscribeResponse{Text: "hi", AudioS: 1.5}, "text/tab-separated-values; charset=utf-8", "start\tend\tspeaker\ttext\n0.000\t1.500\t\thi\n"},
+		{responseCSV, http.StatusServiceUnavailable, TranscribeResponse{Error: "model not loaded"}, "text/plain; charset=utf-8", "model not loaded\n"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		writeTranscript(rec, tt.format, tt.status, tt.resp)
+		if rec.Code != tt.status || rec.Header().Get("Content-Type") != tt.wantType || rec.Body.String() != tt.wantBody {
+			t.Errorf("%s: got %d %q %q, want %d %q %q", tt.format, rec.Code, rec.Header().Get("Content-Type"), rec.Body, tt.status, tt.wantType, tt.wantBody)
+		}
+	}
+}
+
+// --- transcriptWriter ---
+
+func TestTranscriptWriter_JSONL(t *testing.T) {
+	seg := moonshine.Segment{Start: 0, End: 1, Text: "hi"}
+	tests := []struct {
+		name     string
+		streamed []moonshine.Segment
+		status   int
+		resp     TranscribeResponse
+		want     string
+	}{
+		{"streamed", []moonshine.Segment{seg}, http.StatusOK, TranscribeResponse{Text: "hi", Segments: []moonshine.Segment{seg}},
+			`{"segment":{"start":0,"end":1,"text":"hi"}}` + "\n" + `{"result":{"text":"hi","duration_ms":0}}` + "\n"},
+		{"cached", nil, http.StatusOK, TranscribeResponse{Text: "hi", Segments: []moonshine.Segment{seg}},
+			`{"segment":{"start":0,"end":1,"text":"hi"}}` + "\n" + `{"result":{"text":"hi","duration_ms":0}}` + "\n"},
+		{"failed early", nil, http.StatusBadRequest, TranscribeResponse{Error: "audio too long"}, `{"error":"audio too long"}` + "\n"},
+		{"failed late", []moonshine.Segment{seg}, http.StatusInternalServerError, TranscribeResponse{Error: "decode failed"},
+			`{"segment":{"start":0,"end":1,"text":"hi"}}` + "\n" + `{"error":"decode failed"}` + "\n"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		out := &transcriptWriter{w: rec, format: responseJSONL}
+		for _, s := range tt.streamed {
+			out.onSegment()(s)
+		}
+		out.write(tt.status, tt.resp)
+		wantStatus := tt.status
+		if len(tt.streamed) > 0 {
+			wantStatus = http.StatusOK
+		}
+		if rec.Code != wantStatus || rec.Header().Get("Content-Type") != "application/jsonl" || rec.Body.String() != tt.want {
+			t.Errorf("%s: got %d %q %q, want %d %q", tt.name, rec.Code, rec.Header().Get("Content-Type"), rec.Body, wantStatus, tt.want)
+		}
+	}
+}
+
+func TestTranscriptWriter_JSONLPastWriteTimeout(t *testing.T) {
+	seg := moonshine.Segment{Start: 0, End: 1, Text: "hi"}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		out := &transcriptWriter{w: w, format: responseJSONL}
+		for range 3 {
+			out.onSegment()(seg)
+			time.Sleep(80 * time.Millisecond) // decoding the next segment
+		}
+		out.write(http.StatusOK, TranscribeResponse{Text: "hi hi hi"})
+	}))
+	srv.Config.WriteTimeout = 100 * time.Millisecond
+	srv.Start()
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	body, err := io.ReadAll(resp.Body)
+	lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+	if err != nil || len(lines) != 4 || !strings.HasPrefix(lines[3], `{"result":`) {
+		t.Errorf("got %d lines %q, err %v; want 3 segments and the result", len(lines), body, err)
+	}
+}
+
+func TestTranscriptWriter_NotStreaming(t *testing.T) {
+	rec := httptest.NewRecorder()
+	out := &transcriptWriter{w: rec, format: responseText}
+	if out.onSegment() != nil {
+		t.Error("onSegment() != nil for a non-streaming format")
+	}
+	out.write(http.StatusOK, TranscribeResponse{Text: "hi"})
+	if rec.Body.String() != "hi\n" {
+		t.Errorf("body = %q, want %q", rec.Body, "hi\n")
+	}
+}
+
+// --- formatTable ---
+
+func TestFormatTable(t *testing.T) {
+	resp := TranscribeResponse{Segments: []moonshine.Segment{
+		{Start: 0.25, End: 2, Speaker: "SPEAKER_00", Text: "Hello, world."},
+		{Start: 2, End: 3, Text: ""},
+		{Start: 3.5, End: 5.125, Speaker: "SPEAKER_01", Text: `She said "hi"`},
+	}}
+	wantCSV := "start,end,speaker,text\n" +
+		"0.250,2.000,SPEAKER_00,\"Hello, world.\"\n" +
+		"3.500,5.125,SPEAKER_01,\"She said \"\"hi\"\"\"\n"
+	if got := formatTable(resp, ','); got != wantCSV {
+		t.Errorf("csv = %q, want %q", got, wantCSV)
+	}
+	wantTSV := "start\tend\tspeaker\ttext\n" +
+		"0.250\t2.000\tSPEAKER_00\tHello, world.\n" +
+		"3.500\t5.125\tSPEAKER_01\t\"She said \"\"hi\"\"\"\n"
+	if got := formatTable(resp, '\t'); got != wantTSV {
+		t.Errorf("tsv = %q, want %q", got, wantTSV)
+	}
+	if got := formatTable(TranscribeResponse{}, '\t'); got != "start\tend\tspeaker\ttext\n" {
+		t.Errorf("empty = %q, want the header only", got)
+	}
+}
@@ -1,36 +1,181 @@
 package main
 
 import (
+	"bufio"
+	"cmp"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"mime"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/google/uuid"
+	"github.com/anatolykoptev/moonshine-whisper/pkg/moonshine"
 )
 
 // TranscribeRequest is the JSON body for POST /transcribe.
 type TranscribeRequest struct {
-	AudioPath   string `json:"audio_path"`
-	Language    string `json:"language,omitempty"`
-	VAD         *bool  `json:"vad,omitempty"`          // nil=auto, false=skip
-	MaxChunkLen int    `json:"max_chunk_len,omitempty"` // 0=no chunking
-	Punctuate   *bool  `json:"punctuate,omitempty"`     // nil=auto, true=force
+	AudioPath   string    `json:"audio_path,omitempty"`   // local path, s3://bucket/key, or gs://bucket/object
+	AudioURL    string    `json:"audio_url,omitempty"`    // http(s) URL downloaded before transcription
+	AudioBase64 string    `json:"audio_base64,omitempty"` // inline audio bytes, optionally as a data: URI
+	Language    string    `json:"language,omitempty"`
+	VAD         *bool     `json:"vad,omitempty"`           // nil=auto, false=skip
+	MaxChunkLen int       `json:"max_chunk_len,omitempty"` // 0=no chunking
+	Punctuate   *bool     `json:"punctuate,omitempty"`     // nil=auto, true=force
+	Diarize     bool      `json:"diarize,omitempty"`       // label segments with speakers
+	Emotions    bool      `json:"emotions,omitempty"`      // label the audio and segments with emotions
+	ITN         bool      `json:"itn,omitempty"`           // write numbers, amounts, dates, and times in written form
+	Denoise     bool      `json:"denoise,omitempty"`       // suppress background noise before VAD and recognition
+	Normalize   bool      `json:"normalize,omitempty"`     // bring the audio to a standard loudness first
+	TrimSilence bool      `json:"trim_silence,omitempty"`  // strip leading and trailing silence, even without VAD
+	DTMF        bool      `json:"dtmf,omitempty"`          // report DTMF key presses instead of transcribing them
+	SkipMusic   bool      `json:"skip_music,omitempty"`    // leave music without speech undecoded
+	Task        string    `json:"task,omitempty"`          // transcribe (default) or translate (to English)
+	MaxSpeakers int       `json:"max_speakers,omitempty"`  // 0=server default
+	Hotwords    []Hotword `json:"hotwords,omitempty"`      // Zipformer transducer (RU) models only
+	Telephony   *bool     `json:"telephony,omitempty"`     // nil=auto for 8 kHz audio
+	Quality     string    `json:"quality,omitempty"`       // fast (default) or accurate, the larger EN model
+
+	// SplitChannels transcribes each channel of a stereo recording separately.
+	SplitChannels bool `json:"split_channels,omitempty"`
+	// Channel picks one channel to transcribe instead of averaging them:
+	// mix (default), left, right, or a 0-based channel number.
+	Channel string `json:"channel,omitempty"`
+	// AudioTrack picks the 0-based audio track of a multi-track file, such
+	// as a screen recording with separate microphone and system audio.
+	AudioTrack int `json:"audio_track,omitempty"`
+
+	DecodingMethod string `json:"decoding_method,omitempty"` // greedy_search or modified_beam_search
+	BeamSize       int    `json:"beam_size,omitempty"`       // modified_beam_search paths
+
+	NumThreads int    `json:"num_threads,omitempty"` // cap on ONNX threads across parallel chunks; 0=all
+	Priority   string `json:"priority,omitempty"`    // normal (default), or low to yield to normal requests
+}
+
+// options returns the pipeline settings requested by req.
+func (req TranscribeRequest) options() moonshine.Options {
+	return moonshine.Options{
+		Lang:        normLang(req.Language),
+		VAD:         req.VAD,
+		Punctuate:   req.Punctuate,
+		Diarize:     req.Diarize,
+		Emotions:    req.Emotions,
+		ITN:         req.ITN,
+		Denoise:     req.Denoise,
+		Normalize:   req.Normalize,
+		TrimSilence: req.TrimSilence,
+		DTMF:        req.DTMF,
+		SkipMusic:   req.SkipMusic,
+		Task:        req.Task,
+		MaxSpeakers: req.MaxSpeakers,
+		Hotwords:    encodeHotwords(req.Hotwords),
+		Telephony:   req.Telephony,
+		Quality:     req.Quality,
+
+		SplitChannels:  req.SplitChannels,
+		Channel:        req.Channel,
+		AudioTrack:     req.AudioTrack,
+		DecodingMethod: req.DecodingMethod,
+		BeamSize:       req.BeamSize,
+		NumThreads:     req.NumThreads,
+		Priority:       req.Priority,
+	}
+}
+
+// requestFromValues builds a TranscribeRequest from form or query values
+// (language, task, vad, punctuate, max_chunk_len, diarize, max_speakers,
+// emotions, itn, denoise, normalize, trim_silence, dtmf, skip_music, hotwords,
+// telephony, quality, split_channels, channel, audio_track, decoding_method,
+// beam_size, num_threads, priority).
+func requestFromValues(get func(string) string) TranscribeRequest {
+	req := TranscribeRequest{
+		Language:  get("language"),
+		Task:      get("task"),
+		VAD:       parseBoolPtr(get("vad")),
+		Punctuate: parseBoolPtr(get("punctuate")),
+		Hotwords:  parseHotwords(get("hotwords")),
+		Telephony: parseBoolPtr(get("telephony")),
+		Quality:   get("quality"),
+		Channel:   get("channel"),
+
+		DecodingMethod: get("decoding_method"),
+		Priority:       get("priority"),
+	}
+	if n, err := strconv.Atoi(get("max_chunk_len")); err == nil && n > 0 {
+		req.MaxChunkLen = n
+	}
+	if d := parseBoolPtr(get("diarize")); d != nil {
+		req.Diarize = *d
+	}
+	if n, err := strconv.Atoi(get("max_speakers")); err == nil {
+		req.MaxSpeakers = n
+	}
+	if b := parseBoolPtr(get("emotions")); b != nil {
+		req.Emotions = *b
+	}
+	if b := parseBoolPtr(get("itn")); b != nil {
+		req.ITN = *b
+	}
+	if b := parseBoolPtr(get("denoise")); b != nil {
+		req.Denoise = *b
+	}
+	if b := parseBoolPtr(get("normalize")); b != nil {
+		req.Normalize = *b
+	}
+	if b := parseBoolPtr(get("trim_silence")); b != nil {
+		req.TrimSilence = *b
+	}
+	if b := parseBoolPtr(get("dtmf")); b != nil {
+		req.DTMF = *b
+	}
+	if b := parseBoolPtr(get("skip_music")); b != nil {
+		req.SkipMusic = *b
+	}
+	if b := parseBoolPtr(get("split_channels")); b != nil {
+		req.SplitChannels = *b
+	}
+	if n, err := strconv.Atoi(get("audio_track")); err == nil {
+		req.AudioTrack = n
+	}
+	if n, err := strconv.Atoi(get("beam_size")); err == nil {
+		req.BeamSize = n
+	}
+	if n, err := strconv.Atoi(get("num_threads")); err == nil {
+		req.NumThreads = n
+	}
+	return req
 }
 
 // TranscribeResponse is the JSON response returned by transcription endpoints.
 type TranscribeResponse struct {
-	Text       string   `json:"text"`
-	Chunks     []string `json:"chunks,omitempty"`
-	DurationMs float64  `json:"duration_ms"`
-	SpeechMs   float64  `json:"speech_ms,omitempty"`
-	Error      string   `json:"error,omitempty"`
+	Text       string                   `json:"text"`
+	Language   string                   `json:"language,omitempty"` // language transcribed; detected with language=auto
+	Chunks     []string                 `json:"chunks,omitempty"`
+	Segments   []moonshine.Segment      `json:"segments,omitempty"` // VAD or decoded chunks, or speaker turns when diarize=true
+	DurationMs float64                  `json:"duration_ms"`
+	AudioS     float64                  `json:"audio_s,omitempty"` // length of the input audio in seconds
+	SpeechMs   float64                  `json:"speech_ms,omitempty"`
+	Filtered   bool                     `json:"filtered,omitempty"`  // some text was suppressed as a hallucination
+	RawText    string                   `json:"raw_text,omitempty"`  // transcript before suppression, when filtered
+	Emotions   []moonshine.EmotionScore `json:"emotions,omitempty"`  // of the whole audio, with emotions=true
+	DTMF       []moonshine.DTMFEvent    `json:"dtmf,omitempty"`      // key presses, with dtmf=true
+	Skipped    []moonshine.Span         `json:"skipped,omitempty"`   // music left undecoded, with skip_music=true
+	Cached     bool                     `json:"cached,omitempty"`    // served from the transcript cache
+	Coalesced  bool                     `json:"coalesced,omitempty"` // shared with an identical request in flight
+	Error      string                   `json:"error,omitempty"`
+
+	TranscriptID string `json:"transcript_id,omitempty"` // ID in the transcript store, when enabled
+	AudioID      string `json:"audio_id,omitempty"`      // ID of the retained audio, with RETAIN_AUDIO
+	RequestID    string `json:"request_id,omitempty"`    // X-Request-ID of the request that asked for it
+
+	retryAfter int // seconds for the Retry-After header of a 503; 0 = none
 }
 
 type statusWriter struct {
@@ -43,13 +188,17 @@ func (s *statusWriter) WriteHeader(code int) {
 	s.ResponseWriter.WriteHeader(code)
 }
 
-// loggingMiddleware logs every HTTP request with method, path, status, and latency.
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (s *statusWriter) Unwrap() http.ResponseWriter { return s.ResponseWriter }
+
+// loggingMiddleware logs every HTTP request with its request ID, method,
+// path, status, and latency.
 func loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
 		next.ServeHTTP(sw, r)
-		log.Printf("%s %s %d %dms", r.Method, r.URL.Path, sw.status, time.Since(start).Milliseconds())
+		logf(r.Context(), "%s %s %d %dms", r.Method, r.URL.Path, sw.status, time.Since(start).Milliseconds())
 	})
 }
 
@@ -65,6 +214,22 @@ func writeError(w http.ResponseWriter, status int, msg string) {
 	writeJSON(w, status, TranscribeResponse{Error: msg})
 }
 
+// readJSON decodes a size-limited JSON request body into v. On failure it
+// writes a 400 (or 413 when the body is too large) and returns false.
+func readJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, maxJSONBodyBytes())
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit))
+			return false
+		}
+		writeError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return false
+	}
+	return true
+}
+
 // normLang normalizes a language string to lowercase, defaulting to "en".
 func normLang(s string) string {
 	s = strings.ToLower(strings.TrimSpace(s))
@@ -87,20 +252,76 @@ func parseBoolPtr(s string) *bool {
 	return nil
 }
 
-// handleHealth returns service status, model readiness, and version info.
+// handleHealth returns service status, model readiness, audio limits, and
+// version info. The limits are those of the tenant of the API key, if any.
 func handleHealth(w http.ResponseWriter, r *http.Request) {
-	writeJSON(w, http.StatusOK, map[string]any{
-		"status":      "ok",
-		"engine":      "sherpa-onnx",
-		"version":     version,
-		"commit":      commit,
-		"vad":         vadDetector != nil,
-		"punctuation": punctuator != nil,
-		"languages": map[string]any{
-			"en": map[string]any{"model": "moonshine-v2-base-en", "ready": true},
-			"ru": map[string]any{"model": "zipformer-ru-int8", "ready": recognizerRU != nil},
+	ctx := r.Context()
+	if t, ok := tenantsByKey[requestAPIKey(r)]; ok {
+		ctx = withTenant(ctx, t)
+	}
+	h := map[string]any{
+		"status":   "ok",
+		"engine":   "sherpa-onnx",
+		"version":  version,
+		"commit":   commit,
+		"provider": engine.EffectiveProvider(),
+		// Set when ONNX_PROVIDER asked for a provider the ONNX Runtime
+		// library lacks, so sherpa-onnx fell back to the CPU.
+		"provider_fallback": engine.EffectiveProvider() != engine.Provider(),
+		"limits": map[string]any{
+			"max_audio_duration_s": maxAudioDuration(ctx),
+			"vad_min_duration_s":   vadMinDuration(ctx),
 		},
-	})
+	}
+	langs := map[string]any{
+		"en": languageHealth("en", "moonshine-v2-base-en"),
+		"ru": languageHealth("ru", "zipformer-ru-int8"),
+		"zh": languageHealth("zh", "paraformer-zh"),
+	}
+	for _, lang := range engine.Languages() {
+		if langs[lang] == nil {
+			langs[lang] = languageHealth(lang, engine.Backend(lang))
+		}
+	}
+	h["languages"] = langs
+	for name, ok := range engineCapabilities() {
+		h[name] = ok
+	}
+	if b := engine.VADBackend(); b != "" {
+		h["vad_backend"] = b
+	}
+	writeJSON(w, http.StatusOK, h)
+}
+
+// engineCapabilities reports which optional models and tools of the engine
+// are available, by the name /health reports them under.
+func engineCapabilities() map[string]bool {
+	return map[string]bool{
+		"vad":         engine.HasVAD(),
+		"punctuation": engine.HasPunctuation(),
+		"diarization": engine.HasDiarization(),
+		"tagging":     engine.HasTagging(),
+		"language_id": engine.HasLID(),
+		"speaker_id":  engine.HasSpeakerEmbedding(),
+		"emotion":     engine.HasEmotion(),
+		"translation": engine.HasTranslation(),
+		"denoise":     engine.HasDenoise(),
+		"ffmpeg":      engine.HasFFmpeg(),
+	}
+}
+
+// languageHealth reports the readiness of lang, served by model, and the
+// build of its loaded model files.
+func languageHealth(lang, model string) map[string]any {
+	backend := engine.Backend(lang)
+	h := map[string]any{"model": model, "backend": backend, "ready": engine.HasLanguage(lang), "loading": engine.Loading(lang), "streaming": engine.HasStreaming(lang), "telephony": engine.HasTelephony(lang), "accurate": engine.HasAccurate(lang), "lm": engine.HasLM(lang)}
+	if backend != moonshine.BackendMoonshine {
+		h["precision"] = engine.Precision(lang)
+	}
+	if info, ok := engine.ModelInfo(lang); ok {
+		h["model_info"] = info
+	}
+	return h
 }
 
 // handleTranscribe handles POST /transcribe with a JSON body containing audio_path.
@@ -109,20 +330,289 @@ func handleTranscribe(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusMethodNotAllowed, "POST only")
 		return
 	}
+	extendDeadlines(w)
+	format, ok := responseFormat(r, r.URL.Query().Get("format"))
+	if !ok {
+		writeError(w, http.StatusBadRequest, errFormat)
+		return
+	}
 	var req TranscribeRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+	if !readJSON(w, r, &req) {
 		return
 	}
-	if req.AudioPath == "" {
-		writeError(w, http.StatusBadRequest, "audio_path required")
+	if msg := validateSource(req); msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
 		return
 	}
-	resp, status := transcribeFile(req.AudioPath, normLang(req.Language), req.VAD, req.Punctuate)
+	if msg := validateOptions(req); msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+	if msg := validateTenant(r.Context(), req); msg != "" {
+		writeError(w, http.StatusForbidden, msg)
+		return
+	}
+	ctx, cancel := withRequestTimeout(r.Context())
+	defer cancel()
+	out := &transcriptWriter{w: w, format: format}
+	resp, status := runTranscribeRequest(ctx, req, func(o *moonshine.Options) { o.OnSegment = out.onSegment() })
+	recordTranscript(ctx, sourceTranscribe, req.audioName(), req, &resp)
+	out.write(status, resp)
+}
+
+// validateSource checks that req names exactly one audio source and returns
+// an error message, or "" if valid.
+func validateSource(req TranscribeRequest) string {
+	n := 0
+	for _, src := range []string{req.AudioPath, req.AudioURL, req.AudioBase64} {
+		if src != "" {
+			n++
+		}
+	}
+	switch {
+	case n == 0:
+		return "audio_path, audio_url, or audio_base64 required"
+	case n > 1:
+		return "audio_path, audio_url, and audio_base64 are mutually exclusive"
+	case req.AudioURL != "" && !validHTTPURL(req.AudioURL):
+		return "audio_url must be an absolute http(s) URL"
+	}
+	return ""
+}
+
+// validateOptions checks the recognition options of req and returns an
+// error message, or "" if valid.
+func validateOptions(req TranscribeRequest) string {
+	transducer := engine.Backend(normLang(req.Language)) == moonshine.BackendZipformer
+	decoding := engine.CheckDecoding(moonshine.Options{Lang: normLang(req.Language), DecodingMethod: req.DecodingMethod, BeamSize: req.BeamSize, Hotwords: encodeHotwords(req.Hotwords)})
+	switch {
+	case req.MaxSpeakers < 0:
+		return "max_speakers must be >= 0"
+	case req.NumThreads < 0:
+		return "num_threads must be >= 0"
+	case req.AudioTrack < 0:
+		return "audio_track must be >= 0"
+	case !moonshine.ValidChannel(req.Channel):
+		return "channel must be mix, left, right, or a channel number"
+	case req.SplitChannels && req.Channel != "":
+		return "split_channels and channel are mutually exclusive"
+	case !moonshine.ValidPriority(req.Priority):
+		return "priority must be normal or low"
+	case !moonshine.ValidQuality(req.Quality):
+		return "quality must be fast or accurate"
+	case !moonshine.ValidTask(req.Task):
+		return "task must be transcribe or translate"
+	case req.Task == moonshine.TaskTranslate && (len(req.Hotwords) > 0 || req.DecodingMethod != "" || req.BeamSize > 0):
+		return "hotwords and decoding options do not apply to task translate"
+	case req.DecodingMethod != "" && !moonshine.ValidDecodingMethod(req.DecodingMethod):
+		return "decoding_method must be greedy_search or modified_beam_search"
+	case req.BeamSize < 0 || req.BeamSize > moonshine.MaxBeamSize:
+		return fmt.Sprintf("beam_size must be in [0, %d]", moonshine.MaxBeamSize)
+	case !transducer && (req.DecodingMethod == moonshine.ModifiedBeamSearch || req.BeamSize > 0):
+		return "beam search requires a Zipformer transducer model (language ru)"
+	case len(req.Hotwords) > 0 && !transducer:
+		return "hotwords require a Zipformer transducer model (language ru)"
+	}
+	if msg := validateHotwords(req.Hotwords); msg != "" {
+		return msg
+	}
+	if decoding != nil {
+		return decoding.Error() + "; decoding is set by RU_DECODING_METHOD and RU_BEAM_SIZE"
+	}
+	return ""
+}
+
+// runTranscribeRequest resolves the audio source of a validated
+// TranscribeRequest, transcribes it, and applies text chunking. hooks, if
+// set, adds callbacks such as progress reporting to the engine options.
+// Shared by the synchronous endpoint and background jobs.
+func runTranscribeRequest(ctx context.Context, req TranscribeRequest, hooks func(*moonshine.Options)) (TranscribeResponse, int) {
+	audioPath, cleanup, status, err := fetchAudio(ctx, req)
+	if ctx.Err() != nil {
+		return contextError(ctx)
+	}
+	if err != nil {
+		return TranscribeResponse{Error: err.Error()}, status
+	}
+	defer cleanup()
+	opts := requestOptions(ctx, req)
+	if hooks != nil {
+		hooks(&opts)
+	}
+	audioID := retainAudio(ctx, audioPath, &opts)
+	resp, status := transcribeFile(ctx, audioPath, opts)
 	if status == http.StatusOK && req.MaxChunkLen > 0 {
 		resp.Chunks = splitText(resp.Text, req.MaxChunkLen)
 	}
-	writeJSON(w, status, resp)
+	resp.AudioID = audioID
+	return resp, status
+}
+
+// fetchAudio resolves the audio source of a validated TranscribeRequest to
+// a local file, downloading or decoding it first when needed; cleanup
+// removes any temp file. On failure it returns the HTTP status for err, or
+// ctx.Err() once ctx is done.
+func fetchAudio(ctx context.Context, req TranscribeRequest) (audioPath string, cleanup func(), status int, err error) {
+	audioPath = req.AudioPath
+	var fetch func(context.Context, string) (string, int, error)
+	switch {
+	case req.AudioURL != "":
+		audioPath, fetch = req.AudioURL, downloadAudio
+	case req.AudioBase64 != "":
+		audioPath, fetch = req.AudioBase64, decodeInlineAudio
+	case isObjectURI(req.AudioPath):
+		fetch = fetchObject
+	}
+	if fetch == nil {
+		return audioPath, func() {}, http.StatusOK, nil
+	}
+	tmpFile, status, err := fetch(ctx, audioPath)
+	if ctx.Err() != nil {
+		if err == nil {
+			os.Remove(tmpFile) //nolint:errcheck
+		}
+		return "", nil, 0, ctx.Err()
+	}
+	if err != nil {
+		return "", nil, status, err
+	}
+	return tmpFile, func() { os.Remove(tmpFile) }, http.StatusOK, nil //nolint:errcheck
+}
+
+// uploadFieldBytes bounds the form fields of an upload other than the audio.
+const uploadFieldBytes = 64 << 10
+
+// saveUpload streams the "audio" file of a multipart request to a temp file,
+// without buffering it in memory, and returns its path, original name, and
+// the other form fields. Files over UPLOAD_MAX_MB are rejected with 413, as
+// soon as Content-Length shows it or once the limit is read. On failure it
+// writes the error response and returns ok false.
+func saveUpload(w http.ResponseWriter, r *http.Request) (tmpFile, name string, values url.Values, ok bool) {
+	maxBytes := int64(cfg.UploadMaxMB) << 20
+	if r.ContentLength > maxBytes+uploadFieldBytes {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("upload exceeds %d MB", cfg.UploadMaxMB))
+		return "", "", nil, false
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes+uploadFieldBytes)
+	mr, err := r.MultipartReader()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "parse form: "+err.Error())
+		return "", "", nil, false
+	}
+
+	fail := func(status int, msg string) (string, string, url.Values, bool) {
+		if tmpFile != "" {
+			os.Remove(tmpFile) //nolint:errcheck
+		}
+		writeError(w, status, msg)
+		return "", "", nil, false
+	}
+	values = url.Values{}
+	fieldBytes := int64(0)
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fail(uploadReadStatus(err), "parse form: "+err.Error())
+		}
+		if part.FormName() == "audio" && part.FileName() != "" && tmpFile == "" {
+			name = part.FileName()
+			ext := filepath.Ext(name)
+			if ext == "" {
+				ext = ".wav"
+			}
+			if tmpFile, err = newTempPath(ext); err != nil {
+				return fail(http.StatusInsufficientStorage, err.Error())
+			}
+			out, err := os.Create(tmpFile)
+			if err != nil {
+				return fail(http.StatusInternalServerError, "save temp: "+err.Error())
+			}
+			n, err := io.Copy(out, io.LimitReader(part, maxBytes+1))
+			_ = out.Close()
+			if err != nil {
+				return fail(uploadReadStatus(err), "read upload: "+err.Error())
+			}
+			if n > maxBytes {
+				return fail(http.StatusRequestEntityTooLarge, fmt.Sprintf("upload exceeds %d MB", cfg.UploadMaxMB))
+			}
+			continue
+		}
+		if part.FileName() != "" {
+			continue
+		}
+		v, err := io.ReadAll(io.LimitReader(part, uploadFieldBytes-fieldBytes+1))
+		if err != nil {
+			return fail(uploadReadStatus(err), "parse form: "+err.Error())
+		}
+		if fieldBytes += int64(len(v)); fieldBytes > uploadFieldBytes {
+			return fail(http.StatusRequestEntityTooLarge, fmt.Sprintf("form fields exceed %d bytes", uploadFieldBytes))
+		}
+		values.Add(part.FormName(), string(v))
+	}
+	if tmpFile == "" {
+		return fail(http.StatusBadRequest, "audio file required")
+	}
+	return tmpFile, name, values, true
+}
+
+// saveRawUpload streams the body of a raw upload to a temp file, named
+// for the format of its Content-Type or its Content-Disposition filename
+// (returned as name), without buffering it in memory. Bodies over
+// UPLOAD_MAX_MB are rejected with 413. On failure it writes the error
+// response and returns ok false.
+func saveRawUpload(w http.ResponseWriter, r *http.Request) (tmpFile, name string, ok bool) {
+	maxBytes := int64(cfg.UploadMaxMB) << 20
+	if r.ContentLength > maxBytes {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("upload exceeds %d MB", cfg.UploadMaxMB))
+		return "", "", false
+	}
+	if _, params, err := mime.ParseMediaType(r.Header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+		name = filepath.Base(params["filename"])
+	}
+	body := bufio.NewReader(http.MaxBytesReader(w, r.Body, maxBytes))
+	head, err := body.Peek(12)
+	if len(head) == 0 {
+		if err == io.EOF {
+			writeError(w, http.StatusBadRequest, "audio body required")
+		} else {
+			writeError(w, uploadReadStatus(err), "read body: "+err.Error())
+		}
+		return "", "", false
+	}
+	ext := downloadExt(name, r.Header.Get("Content-Type"))
+	if isWAVHeader(head) {
+		ext = ".wav"
+	}
+	if tmpFile, err = newTempPath(ext); err != nil {
+		writeError(w, http.StatusInsufficientStorage, err.Error())
+		return "", "", false
+	}
+	out, err := os.Create(tmpFile)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "save temp: "+err.Error())
+		return "", "", false
+	}
+	_, err = io.Copy(out, body)
+	_ = out.Close()
+	if err != nil {
+		os.Remove(tmpFile) //nolint:errcheck
+		writeError(w, uploadReadStatus(err), "read body: "+err.Error())
+		return "", "", false
+	}
+	return tmpFile, name, true
+}
+
+// uploadReadStatus returns 413 for an error from reading a body past its
+// MaxBytesReader limit and 400 otherwise.
+func uploadReadStatus(err error) int {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
 }
 
 // handleUpload handles POST /transcribe/upload with multipart file upload.
@@ -131,37 +621,92 @@ func handleUpload(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusMethodNotAllowed, "POST only")
 		return
 	}
-	if err := r.ParseMultipartForm(50 << 20); err != nil {
-		writeError(w, http.StatusBadRequest, "parse form: "+err.Error())
+	extendDeadlines(w)
+	tmpFile, filename, values, ok := saveUpload(w, r)
+	if !ok {
 		return
 	}
-	file, header, err := r.FormFile("audio")
-	if err != nil {
-		writeError(w, http.StatusBadRequest, "audio file required")
+	defer os.Remove(tmpFile) //nolint:errcheck
+
+	format, ok := responseFormat(r, cmp.Or(values.Get("format"), r.URL.Query().Get("format")))
+	if !ok {
+		writeError(w, http.StatusBadRequest, errFormat)
 		return
 	}
-	defer file.Close() //nolint:errcheck
+	req := requestFromValues(values.Get)
+	if msg := validateOptions(req); msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+	if msg := validateTenant(r.Context(), req); msg != "" {
+		writeError(w, http.StatusForbidden, msg)
+		return
+	}
+	ctx, cancel := withRequestTimeout(r.Context())
+	defer cancel()
+	out := &transcriptWriter{w: w, format: format}
+	opts := requestOptions(ctx, req)
+	opts.OnSegment = out.onSegment()
+	audioID := retainAudio(ctx, tmpFile, &opts)
+	resp, status := transcribeFile(ctx, tmpFile, opts)
+	if status == http.StatusOK && req.MaxChunkLen > 0 {
+		resp.Chunks = splitText(resp.Text, req.MaxChunkLen)
+	}
+	resp.AudioID = audioID
+	recordTranscript(ctx, sourceUpload, filename, req, &resp)
+	out.write(status, resp)
+}
 
-	ext := filepath.Ext(header.Filename)
-	if ext == "" {
-		ext = ".wav"
+// handleRawUpload handles PUT /transcribe/raw: the body is the audio file
+// itself, in any format ffmpeg reads, with the options as query
+// parameters, sparing programmatic clients the multipart encoding of
+// /transcribe/upload. Headerless PCM goes to /transcribe/pcm instead.
+func handleRawUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		writeError(w, http.StatusMethodNotAllowed, "PUT only")
+		return
 	}
-	tmpFile := fmt.Sprintf("/tmp/moonshine_%s%s", uuid.New().String()[:8], ext)
-	out, err := os.Create(tmpFile)
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, "save temp: "+err.Error())
+	extendDeadlines(w)
+	format, ok := responseFormat(r, r.URL.Query().Get("format"))
+	if !ok {
+		writeError(w, http.StatusBadRequest, errFormat)
+		return
+	}
+	ct := r.Header.Get("Content-Type")
+	if _, err := parsePCMContentType(ct); err == nil {
+		writeError(w, http.StatusUnsupportedMediaType, "headerless PCM has no format to probe; use POST /transcribe/pcm")
+		return
+	}
+	if !audioContentType(ct) {
+		writeError(w, http.StatusUnsupportedMediaType, fmt.Sprintf("unsupported Content-Type %q (want an audio or video type, application/ogg, or application/octet-stream)", ct))
+		return
+	}
+	req := requestFromValues(r.URL.Query().Get)
+	if msg := validateOptions(req); msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+	if msg := validateTenant(r.Context(), req); msg != "" {
+		writeError(w, http.StatusForbidden, msg)
+		return
+	}
+	tmpFile, filename, ok := saveRawUpload(w, r)
+	if !ok {
 		return
 	}
-	io.Copy(out, file) //nolint:errcheck
-	_ = out.Close()
 	defer os.Remove(tmpFile) //nolint:errcheck
 
-	resp, status := transcribeFile(tmpFile, normLang(r.FormValue("language")),
-		parseBoolPtr(r.FormValue("vad")), parseBoolPtr(r.FormValue("punctuate")))
-	if status == http.StatusOK {
-		if maxChunk, err := strconv.Atoi(r.FormValue("max_chunk_len")); err == nil && maxChunk > 0 {
-			resp.Chunks = splitText(resp.Text, maxChunk)
-		}
+	ctx, cancel := withRequestTimeout(r.Context())
+	defer cancel()
+	out := &transcriptWriter{w: w, format: format}
+	opts := requestOptions(ctx, req)
+	opts.OnSegment = out.onSegment()
+	audioID := retainAudio(ctx, tmpFile, &opts)
+	resp, status := transcribeFile(ctx, tmpFile, opts)
+	if status == http.StatusOK && req.MaxChunkLen > 0 {
+		resp.Chunks = splitText(resp.Text, req.MaxChunkLen)
 	}
-	writeJSON(w, status, resp)
+	resp.AudioID = audioID
+	recordTranscript(ctx, sourceUpload, filename, req, &resp)
+	out.write(status, resp)
 }
@@ -1,6 +1,18 @@
 package main
 
-import "testing"
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/anatolykoptev/moonshine-whisper/pkg/moonshine"
+)
 
 // --- normLang ---
 
@@ -67,3 +79,281 @@ func TestParseBoolPtr_WithSpaces(t *testing.T) {
 		t.Error("parseBoolPtr with leading/trailing spaces should parse false")
 	}
 }
+
+// --- requestFromValues ---
+
+func TestRequestFromValues(t *testing.T) {
+	q := map[string]string{
+		"language": "RU", "vad": "false", "max_chunk_len": "200",
+		"diarize": "yes", "max_speakers": "3", "emotions": "1", "itn": "true", "denoise": "1", "normalize": "true", "trim_silence": "true", "dtmf": "1", "skip_music": "true", "task": "translate", "split_channels": "true", "audio_track": "2", "channel": "right", "telephony": "false", "quality": "accurate",
+		"decoding_method": "modified_beam_search", "beam_size": "8", "num_threads": "2", "priority": "low",
+	}
+	req := requestFromValues(func(k string) string { return q[k] })
+	if req.Language != "RU" || req.VAD == nil || *req.VAD || req.Punctuate != nil {
+		t.Errorf("basic fields = %+v", req)
+	}
+	if req.MaxChunkLen != 200 || !req.Diarize || req.MaxSpeakers != 3 || !req.Emotions || !req.ITN {
+		t.Errorf("numeric/diarize fields = %+v", req)
+	}
+	if req.DecodingMethod != "modified_beam_search" || req.BeamSize != 8 {
+		t.Errorf("decoding fields = %+v", req)
+	}
+	if opts := req.options(); opts.NumThreads != 2 || opts.Priority != moonshine.PriorityLow {
+		t.Errorf("num_threads/priority = %d %q", opts.NumThreads, opts.Priority)
+	}
+	opts := req.options()
+	if opts.Lang != "ru" || !opts.Diarize || !opts.Emotions || !opts.ITN || !opts.Denoise || !opts.Normalize || !opts.TrimSilence || !opts.DTMF || !opts.SkipMusic || opts.Task != "translate" || opts.MaxSpeakers != 3 || !opts.SplitChannels || opts.AudioTrack != 2 || opts.Channel != moonshine.ChannelRight || opts.Telephony == nil || *opts.Telephony || opts.Quality != moonshine.QualityAccurate {
+		t.Errorf("options() = %+v", opts)
+	}
+
+	empty := requestFromValues(func(string) string { return "" })
+	if empty.Diarize || empty.Emotions || empty.ITN || empty.SplitChannels || empty.MaxChunkLen != 0 || empty.options().Lang != "en" {
+		t.Errorf("empty values = %+v", empty)
+	}
+}
+
+// --- validateSource ---
+
+func TestValidateSource(t *testing.T) {
+	tests := []struct {
+		req   TranscribeRequest
+		valid bool
+	}{
+		{TranscribeRequest{AudioPath: "/a.wav"}, true},
+		{TranscribeRequest{AudioURL: "https://example.com/a.mp3"}, true},
+		{TranscribeRequest{}, false},
+		{TranscribeRequest{AudioPath: "/a.wav", AudioURL: "https://example.com/a.mp3"}, false},
+		{TranscribeRequest{AudioURL: "file:///etc/passwd"}, false},
+		{TranscribeRequest{AudioBase64: "UklGRg=="}, true},
+		{TranscribeRequest{AudioPath: "/a.wav", AudioBase64: "UklGRg=="}, false},
+	}
+	for _, tt := range tests {
+		if got := validateSource(tt.req) == ""; got != tt.valid {
+			t.Errorf("validateSource(%+v) valid = %v, want %v", tt.req, got, tt.valid)
+		}
+	}
+}
+
+// --- validateOptions ---
+
+func TestValidateOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		req  TranscribeRequest
+		want string
+	}{
+		{"defaults", TranscribeRequest{}, ""},
+		{"negative max_speakers", TranscribeRequest{MaxSpeakers: -1}, "max_speakers"},
+		{"negative audio_track", TranscribeRequest{AudioTrack: -1}, "audio_track"},
+		{"channel left", TranscribeRequest{Channel: "left"}, ""},
+		{"channel number", TranscribeRequest{Channel: "2"}, ""},
+		{"bad channel", TranscribeRequest{Channel: "center"}, "channel must be"},
+		{"channel and split", TranscribeRequest{Channel: "left", SplitChannels: true}, "mutually exclusive"},
+		{"hotwords ru greedy", TranscribeRequest{Language: "RU", Hotwords: []Hotword{{"Яндекс", 2}}}, "hotwords need modified_beam_search"},
+		{"hotwords en", TranscribeRequest{Hotwords: []Hotword{{"Moonshine", 0}}}, "transducer"},
+		{"bad hotword", TranscribeRequest{Language: "ru", Hotwords: []Hotword{{"a/b", 0}}}, "must not contain"},
+		{"hotwords greedy", TranscribeRequest{Language: "ru", DecodingMethod: "greedy_search", Hotwords: []Hotword{{"a", 0}}}, "RU_DECODING_METHOD"},
+		{"greedy ru", TranscribeRequest{Language: "ru", DecodingMethod: "greedy_search"}, ""},
+		{"beam ru", TranscribeRequest{Language: "ru", DecodingMethod: "modified_beam_search"}, "not loaded"},
+		{"beam_size ru", TranscribeRequest{Language: "ru", BeamSize: 4}, "RU_DECODING_METHOD"},
+		{"greedy en", TranscribeRequest{DecodingMethod: "greedy_search"}, ""},
+		{"beam en", TranscribeRequest{DecodingMethod: "modified_beam_search"}, "transducer"},
+		{"beam_size en", TranscribeRequest{BeamSize: 4}, "transducer"},
+		{"unknown method", TranscribeRequest{Language: "ru", DecodingMethod: "beam"}, "decoding_method"},
+		{"beam_size too large", TranscribeRequest{Language: "ru", BeamSize: moonshine.MaxBeamSize + 1}, "beam_size"},
+		{"translate", TranscribeRequest{Language: "ru", Task: "translate"}, ""},
+		{"unknown task", TranscribeRequest{Task: "summarize"}, "task"},
+		{"low priority", TranscribeRequest{Priority: "low", NumThreads: 1}, ""},
+		{"unknown priority", TranscribeRequest{Priority: "urgent"}, "priority"},
+		{"unknown quality", TranscribeRequest{Quality: "best"}, "quality"},
+		{"negative num_threads", TranscribeRequest{NumThreads: -1}, "num_threads"},
+		{"translate hotwords", TranscribeRequest{Language: "ru", Task: "translate", Hotwords: []Hotword{{"a", 0}}}, "translate"},
+		{"translate beam", TranscribeRequest{Language: "ru", Task: "translate", DecodingMethod: "modified_beam_search"}, "translate"},
+	}
+	for _, tt := range tests {
+		got := validateOptions(tt.req)
+		if (tt.want == "") != (got == "") || !strings.Contains(got, tt.want) {
+			t.Errorf("%s: validateOptions = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// --- saveUpload ---
+
+// uploadRequest builds a multipart request with fields and, unless audio is
+// nil, an "audio" file.
+func uploadRequest(audio []byte, fields map[string]string) *http.Request {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for k, v := range fields {
+		mw.WriteField(k, v) //nolint:errcheck
+	}
+	if audio != nil {
+		fw, _ := mw.CreateFormFile("audio", "clip.mp3")
+		fw.Write(audio) //nolint:errcheck
+	}
+	mw.Close() //nolint:errcheck
+	r := httptest.NewRequest(http.MethodPost, "/transcribe/upload", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return r
+}
+
+func TestSaveUpload(t *testing.T) {
+	old := cfg
+	cfg = defaultConfig()
+	cfg.TempDir = t.TempDir()
+	cfg.TempMinFreeMB = 0
+	cfg.UploadMaxMB = 1
+	t.Cleanup(func() { cfg = old })
+
+	rec := httptest.NewRecorder()
+	tmpFile, name, values, ok := saveUpload(rec, uploadRequest([]byte("ID3 audio"), map[string]string{"language": "ru"}))
+	if !ok {
+		t.Fatalf("saveUpload failed: %d %s", rec.Code, rec.Body)
+	}
+	data, _ := os.ReadFile(tmpFile)
+	if string(data) != "ID3 audio" || name != "clip.mp3" || values.Get("language") != "ru" {
+		t.Errorf("saveUpload = %q %q %v, want the audio, its name, and language=ru", data, name, values)
+	}
+	os.Remove(tmpFile) //nolint:errcheck
+
+	// Content-Length unknown: the limit is enforced while streaming.
+	chunked := uploadRequest(make([]byte, 1<<20+1), nil)
+	chunked.ContentLength = -1
+	chunked.Body = io.NopCloser(chunked.Body)
+
+	tests := []struct {
+		name string
+		r    *http.Request
+		want int
+	}{
+		{"too large", uploadRequest(make([]byte, 2<<20), nil), http.StatusRequestEntityTooLarge},
+		{"too large streamed", chunked, http.StatusRequestEntityTooLarge},
+		{"fields too large", uploadRequest([]byte("a"), map[string]string{"hotwords": strings.Repeat("a", uploadFieldBytes+1)}), http.StatusRequestEntityTooLarge},
+		{"no audio", uploadRequest(nil, map[string]string{"language": "ru"}), http.StatusBadRequest},
+		{"not multipart", httptest.NewRequest(http.MethodPost, "/transcribe/upload", strings.NewReader("x")), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		if _, _, _, ok := saveUpload(rec, tt.r); ok || rec.Code != tt.want {
+			t.Errorf("%s: ok = %v, status = %d, want %d", tt.name, ok, rec.Code, tt.want)
+		}
+	}
+	if entries, _ := os.ReadDir(cfg.TempDir); len(entries) != 0 {
+		t.Errorf("temp files left behind: %d", len(entries))
+	}
+}
+
+func TestSaveRawUpload(t *testing.T) {
+	old := cfg
+	cfg = defaultConfig()
+	cfg.TempDir = t.TempDir()
+	cfg.TempMinFreeMB = 0
+	cfg.UploadMaxMB = 1
+	t.Cleanup(func() { cfg = old })
+
+	tests := []struct {
+		name, ct, disposition string
+		body                  []byte
+		wantExt, wantName     string
+	}{
+		{"ogg", "audio/ogg", "", []byte("OggS audio"), ".audio", ""},
+		{"wav by type", "audio/wav", "", []byte("RIFF"), ".wav", ""},
+		{"wav by header", "application/octet-stream", "", []byte("RIFF\x24\x00\x00\x00WAVEfmt "), ".wav", ""},
+		{"named", "audio/mpeg", `attachment; filename="../calls/call-42.MP3"`, []byte("ID3 audio"), ".mp3", "call-42.MP3"},
+		{"inline", "audio/mpeg", "inline", []byte("ID3 audio"), ".audio", ""},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPut, "/transcribe/raw", bytes.NewReader(tt.body))
+		r.Header.Set("Content-Type", tt.ct)
+		if tt.disposition != "" {
+			r.Header.Set("Content-Disposition", tt.disposition)
+		}
+		rec := httptest.NewRecorder()
+		tmpFile, name, ok := saveRawUpload(rec, r)
+		if !ok {
+			t.Errorf("%s: saveRawUpload failed: %d %s", tt.name, rec.Code, rec.Body)
+			continue
+		}
+		data, _ := os.ReadFile(tmpFile)
+		if !bytes.Equal(data, tt.body) || filepath.Ext(tmpFile) != tt.wantExt || name != tt.wantName {
+			t.Errorf("%s: saved %q as %s named %q, want %s named %q", tt.name, data, filepath.Ext(tmpFile), name, tt.wantExt, tt.wantName)
+		}
+		os.Remove(tmpFile) //nolint:errcheck
+	}
+
+	chunked := httptest.NewRequest(http.MethodPut, "/transcribe/raw", io.NopCloser(bytes.NewReader(make([]byte, 1<<20+1))))
+	chunked.ContentLength = -1
+	rejects := []struct {
+		name string
+		r    *http.Request
+		want int
+	}{
+		{"empty", httptest.NewRequest(http.MethodPut, "/transcribe/raw", nil), http.StatusBadRequest},
+		{"too large", httptest.NewRequest(http.MethodPut, "/transcribe/raw", bytes.NewReader(make([]byte, 2<<20))), http.StatusRequestEntityTooLarge},
+		{"too large streamed", chunked, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range rejects {
+		rec := httptest.NewRecorder()
+		if _, _, ok := saveRawUpload(rec, tt.r); ok || rec.Code != tt.want {
+			t.Errorf("%s: ok = %v, status = %d, want %d", tt.name, ok, rec.Code, tt.want)
+		}
+	}
+	if entries, _ := os.ReadDir(cfg.TempDir); len(entries) != 0 {
+		t.Errorf("temp files left behind: %d", len(entries))
+	}
+}
+
+// --- handleRawUpload ---
+
+func TestHandleRawUpload_Rejects(t *testing.T) {
+	tests := []struct {
+		method, query, ct string
+		want              int
+	}{
+		{http.MethodPost, "", "audio/ogg", http.StatusMethodNotAllowed},
+		{http.MethodPut, "", "audio/l16;rate=16000", http.StatusUnsupportedMediaType},
+		{http.MethodPut, "", "audio/pcmu", http.StatusUnsupportedMediaType},
+		{http.MethodPut, "", "text/plain", http.StatusUnsupportedMediaType},
+		{http.MethodPut, "?format=docx", "audio/ogg", http.StatusBadRequest},
+		{http.MethodPut, "?task=summarize", "audio/ogg", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "/transcribe/raw"+tt.query, strings.NewReader("OggS"))
+		req.Header.Set("Content-Type", tt.ct)
+		rec := httptest.NewRecorder()
+		handleRawUpload(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s %s %q = %d, want %d: %s", tt.method, tt.query, tt.ct, rec.Code, tt.want, rec.Body)
+		}
+	}
+}
+
+// --- readJSON ---
+
+func TestReadJSON_TooLarge(t *testing.T) {
+	oldMB := cfg.InlineMaxMB
+	cfg.InlineMaxMB = 0 // limit = 64 KiB of non-audio fields
+	t.Cleanup(func() { cfg.InlineMaxMB = oldMB })
+
+	body := `{"audio_base64":"` + strings.Repeat("A", 100<<10) + `"}`
+	rec := httptest.NewRecorder()
+	var req TranscribeRequest
+	if readJSON(rec, httptest.NewRequest(http.MethodPost, "/transcribe", strings.NewReader(body)), &req) {
+		t.Fatal("readJSON should reject oversized body")
+	}
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status = %d, want 413", rec.Code)
+	}
+}
+
+func TestReadJSON_Invalid(t *testing.T) {
+	rec := httptest.NewRecorder()
+	var req TranscribeRequest
+	if readJSON(rec, httptest.NewRequest(http.MethodPost, "/transcribe", strings.NewReader("{")), &req) {
+		t.Fatal("readJSON should reject invalid JSON")
+	}
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxHotwords bounds the per-request hotword list.
+const maxHotwords = 200
+
+// Hotword is a phrase recognition should be biased toward. It decodes from
+// either a JSON string or {"phrase": "...", "boost": 2.0}.
+type Hotword struct {
+	Phrase string  `json:"phrase"`
+	Boost  float64 `json:"boost,omitempty"` // 0=HOTWORDS_SCORE
+}
+
+// UnmarshalJSON accepts a bare string as a hotword without a boost.
+func (h *Hotword) UnmarshalJSON(data []byte) error {
+	var phrase string
+	if err := json.Unmarshal(data, &phrase); err == nil {
+		*h = Hotword{Phrase: phrase}
+		return nil
+	}
+	type plain Hotword
+	return json.Unmarshal(data, (*plain)(h))
+}
+
+// parseHotwords parses a comma-separated form/query value such as
+// "Moonshine:2.5,sherpa onnx" into hotwords.
+func parseHotwords(s string) []Hotword {
+	var out []Hotword
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		h := Hotword{Phrase: item}
+		if i := strings.LastIndexByte(item, ':'); i > 0 {
+			if boost, err := strconv.ParseFloat(item[i+1:], 64); err == nil {
+				h = Hotword{Phrase: strings.TrimSpace(item[:i]), Boost: boost}
+			}
+		}
+		out = append(out, h)
+	}
+	return out
+}
+
+// validateHotwords returns an error message for an unusable hotword list, or "".
+func validateHotwords(hotwords []Hotword) string {
+	if len(hotwords) > maxHotwords {
+		return fmt.Sprintf("too many hotwords: %d > %d", len(hotwords), maxHotwords)
+	}
+	for _, h := range hotwords {
+		switch {
+		case strings.TrimSpace(h.Phrase) == "":
+			return "hotword phrase must not be empty"
+		case strings.ContainsAny(h.Phrase, "/:\n\r"):
+			return fmt.Sprintf("hotword %q must not contain '/', ':', or newlines", h.Phrase)
+		case h.Boost < 0:
+			return fmt.Sprintf("hotword %q boost must be >= 0", h.Phrase)
+		}
+	}
+	return ""
+}
+
+// encodeHotwords renders hotwords in sherpa-onnx's stream format:
+// phrases separated by "/", each optionally followed by " :boost".
+func encodeHotwords(hotwords []Hotword) string {
+	parts := make([]string, 0, len(hotwords))
+	for _, h := range hotwords {
+		p := strings.Join(strings.Fields(h.Phrase), " ")
+		if h.Boost > 0 {
+			p += " :" + strconv.FormatFloat(h.Boost, 'f', -1, 64)
+		}
+		parts = append(parts, p)
+	}
+	return strings.Join(parts, "/")
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// --- Hotword JSON ---
+
+func TestHotword_UnmarshalJSON(t *testing.T) {
+	var req TranscribeRequest
+	body := `{"hotwords":["Moonshine",{"phrase":"sherpa onnx","boost":2.5},{"phrase":"Kubernetes"}]}`
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []Hotword{{"Moonshine", 0}, {"sherpa onnx", 2.5}, {"Kubernetes", 0}}
+	if len(req.Hotwords) != len(want) {
+		t.Fatalf("hotwords = %+v, want %+v", req.Hotwords, want)
+	}
+	for i := range want {
+		if req.Hotwords[i] != want[i] {
+			t.Errorf("hotword %d = %+v, want %+v", i, req.Hotwords[i], want[i])
+		}
+	}
+
+	if err := json.Unmarshal([]byte(`{"hotwords":[42]}`), &req); err == nil {
+		t.Error("expected error for numeric hotword")
+	}
+}
+
+// --- parseHotwords ---
+
+func TestParseHotwords(t *testing.T) {
+	got := parseHotwords(" Moonshine:2.5, sherpa onnx ,,Kubernetes:x ")
+	want := []Hotword{{"Moonshine", 2.5}, {"sherpa onnx", 0}, {"Kubernetes:x", 0}}
+	if len(got) != len(want) {
+		t.Fatalf("parseHotwords = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("hotword %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	if got := parseHotwords(""); got != nil {
+		t.Errorf("parseHotwords(\"\") = %+v, want nil", got)
+	}
+}
+
+// --- validateHotwords ---
+
+func TestValidateHotwords(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []Hotword
+		want string
+	}{
+		{"ok", []Hotword{{"Moonshine", 2}, {"sherpa onnx", 0}}, ""},
+		{"empty phrase", []Hotword{{" ", 0}}, "empty"},
+		{"slash", []Hotword{{"a/b", 0}}, "must not contain"},
+		{"colon", []Hotword{{"a :3", 0}}, "must not contain"},
+		{"negative boost", []Hotword{{"a", -1}}, "boost"},
+		{"too many", make([]Hotword, maxHotwords+1), "too many"},
+	}
+	for _, tt := range tests {
+		got := validateHotwords(tt.in)
+		if (tt.want == "") != (got == "") || !strings.Contains(got, tt.want) {
+			t.Errorf("%s: validateHotwords = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// --- encodeHotwords ---
+
+func TestEncodeHotwords(t *testing.T) {
+	got := encodeHotwords([]Hotword{{"Moonshine", 0}, {"sherpa   onnx", 2.5}, {"K8S", 3}})
+	if want := "Moonshine/sherpa onnx :2.5/K8S :3"; got != want {
+		t.Errorf("encodeHotwords = %q, want %q", got, want)
+	}
+	if got := encodeHotwords(nil); got != "" {
+		t.Errorf("encodeHotwords(nil) = %q", got)
+	}
+}
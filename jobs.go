@@ -0,0 +1,498 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/anatolykoptev/moonshine-whisper/pkg/moonshine"
+	"github.com/google/uuid"
+)
+
+// JOB_STORE backends.
+const (
+	jobStoreMemory = "memory"
+	jobStoreRedis  = "redis"
+	jobStoreBolt   = "bolt"
+)
+
+// Job states reported by GET /jobs/{id}.
+const (
+	jobQueued  = "queued"
+	jobRunning = "running"
+	jobDone    = "done"
+	jobFailed  = "failed"
+)
+
+// JobRequest is the JSON body for POST /jobs: a TranscribeRequest plus an
+// optional URL that receives the finished job via POST.
+type JobRequest struct {
+	TranscribeRequest
+	CallbackURL string `json:"callback_url,omitempty"`
+
+	tenant    string // name of the tenant that created the job
+	requestID string // X-Request-ID of the request that created it
+}
+
+// Job is the state of one asynchronous transcription.
+type Job struct {
+	ID          string              `json:"id"`
+	Status      string              `json:"status"`
+	CreatedAt   time.Time           `json:"created_at"`
+	StartedAt   *time.Time          `json:"started_at,omitempty"`
+	FinishedAt  *time.Time          `json:"finished_at,omitempty"`
+	CallbackURL string              `json:"callback_url,omitempty"`
+	RequestID   string              `json:"request_id,omitempty"` // X-Request-ID of the request that created the job
+	Progress    *JobProgress        `json:"progress,omitempty"`
+	Result      *TranscribeResponse `json:"result,omitempty"`
+
+	req        TranscribeRequest
+	tenant     string
+	checkpoint *moonshine.Checkpoint // chunks decoded so far, with a backend
+	changed    chan struct{}         // closed and replaced on every update
+}
+
+// JobProgress tracks a running job through its VAD chunks, or speaker turns
+// when diarizing.
+type JobProgress struct {
+	ChunksDone    int     `json:"chunks_done"`
+	ChunksTotal   int     `json:"chunks_total"`
+	ChunksResumed int     `json:"chunks_resumed,omitempty"` // of chunks_done, taken from an interrupted run
+	ElapsedS      float64 `json:"elapsed_s"`
+	ETAS          float64 `json:"eta_s"` // projected from the average time per chunk so far
+}
+
+// jobStore keeps jobs in memory; finished jobs are evicted after cfg.JobRetention.
+// With a backend, the store also saves every job to it, and the backend
+// holds the queue and answers lookups.
+type jobStore struct {
+	mu      sync.Mutex
+	jobs    map[string]*Job
+	queue   chan *Job
+	backend jobBackend // nil = in memory only
+}
+
+// jobBackend stores jobs and their queue outside the process, so queued
+// jobs survive restarts and replicas can share one queue. A job claimed by
+// a worker that stopped before finishing it is queued again.
+type jobBackend interface {
+	// push stores and queues a new job; it returns false if limit jobs
+	// are already queued.
+	push(j Job, limit int) (bool, error)
+	// pop claims the oldest queued job, waiting until there is one or ctx
+	// is done.
+	pop(ctx context.Context) (Job, error)
+	// save stores an update of a job; a finished job is released and
+	// kept for cfg.JobRetention.
+	save(j Job) error
+	load(id string) (Job, bool, error)
+}
+
+// jobPollInterval is how often GET /jobs/{id}/events checks a backend for
+// updates made by other replicas.
+const jobPollInterval = time.Second
+
+var errJobQueueFull = errors.New("job queue full")
+
+var jobs = &jobStore{jobs: make(map[string]*Job)}
+
+// callbackClient delivers job callbacks to the callback_url of the job;
+// private addresses are refused unless cfg.CallbackAllowPrivate.
+var callbackClient = &http.Client{
+	Timeout:   10 * time.Second,
+	Transport: guardedTransport(func() bool { return cfg.CallbackAllowPrivate }),
+}
+
+// webhookClient delivers to URLs the operator configured, such as
+// RTP_WEBHOOK_URL, which may well be internal.
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+// startJobWorkers opens the JOB_STORE backend, creates the job queue, and
+// starts n background workers.
+func startJobWorkers(n, queueSize int) error {
+	b, err := openJobBackend(cfg.JobStore)
+	if err != nil {
+		return err
+	}
+	jobs.backend = b
+	jobs.queue = make(chan *Job, queueSize)
+	for range n {
+		go jobWorker()
+	}
+	log.Printf("Job queue ready (store=%s, workers=%d, queue=%d)", cfg.JobStore, n, queueSize)
+	return nil
+}
+
+// openJobBackend opens the backend named by JOB_STORE; "memory" has none.
+func openJobBackend(store string) (jobBackend, error) {
+	switch store {
+	case jobStoreRedis:
+		return newRedisJobBackend(cfg.JobRedisURL)
+	case jobStoreBolt:
+		return openBoltJobBackend(cfg.JobDBPath)
+	}
+	return nil, nil
+}
+
+// jobWorker processes queued jobs until the queue is closed, or claims them
+// from the backend forever.
+func jobWorker() {
+	if jobs.backend == nil {
+		for j := range jobs.queue {
+			runJob(j)
+		}
+		return
+	}
+	for {
+		j, err := jobs.backend.pop(context.Background())
+		if err != nil {
+			log.Printf("WARNING: job queue: %v", err)
+			time.Sleep(time.Second)
+			continue
+		}
+		runJob(jobs.adopt(j))
+	}
+}
+
+// enqueue registers a new job and queues it; it returns errJobQueueFull if
+// the queue is full.
+func (s *jobStore) enqueue(req JobRequest) (*Job, error) {
+	j := &Job{
+		ID:          uuid.New().String(),
+		Status:      jobQueued,
+		CreatedAt:   time.Now(),
+		CallbackURL: req.CallbackURL,
+		RequestID:   req.requestID,
+		req:         req.TranscribeRequest,
+		tenant:      req.tenant,
+		changed:     make(chan struct{}),
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.evictLocked(j.CreatedAt)
+	if s.backend != nil {
+		ok, err := s.backend.push(*j, cap(s.queue)) // the queue channel is unused but sized by JOB_QUEUE_SIZE
+		switch {
+		case err != nil:
+			return nil, err
+		case !ok:
+			return nil, errJobQueueFull
+		}
+		return j, nil
+	}
+	select {
+	case s.queue <- j:
+	default:
+		return nil, errJobQueueFull
+	}
+	s.jobs[j.ID] = j
+	return j, nil
+}
+
+// adopt tracks a job claimed from the backend for running here.
+func (s *jobStore) adopt(j Job) *Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j.changed = make(chan struct{})
+	s.jobs[j.ID] = &j
+	return &j
+}
+
+// get returns a snapshot of the job with the given ID.
+func (s *jobStore) get(id string) (Job, bool) {
+	if s.backend != nil {
+		j, ok, err := s.backend.load(id)
+		if err != nil {
+			log.Printf("WARNING: job %s: %v", id, err)
+		}
+		return j, ok
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *j, true
+}
+
+// update applies fn to the job under the store lock, wakes its watchers,
+// saves it to the backend, and returns a snapshot.
+func (s *jobStore) update(j *Job, fn func(*Job)) Job {
+	s.mu.Lock()
+	fn(j)
+	if j.changed != nil {
+		close(j.changed)
+	}
+	j.changed = make(chan struct{})
+	snap := *j
+	s.mu.Unlock()
+	if s.backend != nil {
+		if err := s.backend.save(snap); err != nil {
+			log.Printf("WARNING: job %s: save: %v", j.ID, err)
+		}
+	}
+	return snap
+}
+
+// watch returns a snapshot of the job with the given ID and a channel that
+// is closed on its next update. With a backend, the channel is also closed
+// after jobPollInterval, as another replica may be running the job.
+func (s *jobStore) watch(id string) (Job, <-chan struct{}, bool) {
+	if s.backend != nil {
+		j, ok := s.get(id)
+		if !ok {
+			return Job{}, nil, false
+		}
+		s.mu.Lock()
+		var local <-chan struct{}
+		if l, ok := s.jobs[id]; ok {
+			local = l.changed
+		}
+		s.mu.Unlock()
+		changed := make(chan struct{})
+		go func() {
+			select {
+			case <-local:
+			case <-time.After(jobPollInterval):
+			}
+			close(changed)
+		}()
+		return j, changed, true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	if !ok {
+		return Job{}, nil, false
+	}
+	if j.changed == nil {
+		j.changed = make(chan struct{})
+	}
+	return *j, j.changed, true
+}
+
+// evictLocked drops finished jobs older than the retention window.
+func (s *jobStore) evictLocked(now time.Time) {
+	for id, j := range s.jobs {
+		if j.FinishedAt != nil && now.Sub(*j.FinishedAt) > cfg.JobRetention {
+			delete(s.jobs, id)
+		}
+	}
+}
+
+// jobRecord is a job as a backend stores it, with its request, tenant, and
+// checkpoint.
+type jobRecord struct {
+	Job
+	Request    TranscribeRequest     `json:"request"`
+	Tenant     string                `json:"tenant,omitempty"`
+	Checkpoint *moonshine.Checkpoint `json:"checkpoint,omitempty"`
+}
+
+// encodeJob encodes j with its request for a backend.
+func encodeJob(j Job) ([]byte, error) {
+	return json.Marshal(jobRecord{Job: j, Request: j.req, Tenant: j.tenant, Checkpoint: j.checkpoint})
+}
+
+// decodeJob decodes a job stored by encodeJob.
+func decodeJob(data []byte) (Job, error) {
+	var r jobRecord
+	if err := json.Unmarshal(data, &r); err != nil {
+		return Job{}, err
+	}
+	r.Job.req, r.Job.tenant, r.Job.checkpoint = r.Request, r.Tenant, r.Checkpoint
+	return r.Job, nil
+}
+
+// requeued resets a job whose worker stopped before finishing it. Its
+// checkpoint is kept, so the chunks already decoded are not decoded again.
+func requeued(j Job) Job {
+	j.Status, j.StartedAt, j.Progress = jobQueued, nil, nil
+	return j
+}
+
+// runJob transcribes a job, records the result, and fires its callback.
+// With a backend, the decoded chunks are saved with each progress update,
+// and a job interrupted before is continued from its checkpoint.
+func runJob(j *Job) {
+	started := time.Now()
+	var resume *moonshine.Checkpoint
+	jobs.update(j, func(j *Job) {
+		j.Status = jobRunning
+		j.StartedAt = &started
+		if jobs.backend != nil {
+			resume = j.checkpoint
+			j.checkpoint = &moonshine.Checkpoint{Chunks: make(map[int]moonshine.ChunkResult)}
+			if resume != nil {
+				j.checkpoint.Total, j.checkpoint.Chunks = resume.Total, maps.Clone(resume.Chunks)
+			}
+		}
+	})
+
+	ctx, cancel := withRequestTimeout(withRequestID(withTenant(context.Background(), tenantsByName[j.tenant]), j.RequestID))
+	resp, status := runTranscribeRequest(ctx, j.req, func(o *moonshine.Options) {
+		o.WaitForLoad = true
+		o.Progress = func(done, total int) {
+			resumed := 0
+			if resume != nil && resume.Total == total {
+				resumed = len(resume.Chunks)
+			}
+			jobs.update(j, func(j *Job) { j.Progress = newJobProgress(done, resumed, total, time.Since(started)) })
+		}
+		if j.checkpoint == nil {
+			return
+		}
+		o.Resume = resume
+		// Progress and OnChunk calls are serialized, so the checkpoint is
+		// not written while a progress update saves it.
+		o.OnChunk = func(i, total int, c moonshine.ChunkResult) {
+			jobs.mu.Lock()
+			if j.checkpoint.Total != total {
+				j.checkpoint.Total, j.checkpoint.Chunks = total, make(map[int]moonshine.ChunkResult)
+			}
+			j.checkpoint.Chunks[i] = c
+			jobs.mu.Unlock()
+		}
+	})
+	recordTranscript(ctx, sourceJob, j.req.audioName(), j.req, &resp)
+	cancel()
+
+	snap := jobs.update(j, func(j *Job) {
+		now := time.Now()
+		j.FinishedAt = &now
+		j.checkpoint = nil
+		j.Result = &resp
+		j.Status = jobDone
+		if status != http.StatusOK {
+			j.Status = jobFailed
+		}
+	})
+	logf(ctx, "job %s %s in %dms", snap.ID, snap.Status, snap.FinishedAt.Sub(*snap.StartedAt).Milliseconds())
+
+	if snap.CallbackURL != "" {
+		go deliverCallback(snap)
+	}
+}
+
+// newJobProgress reports done of total chunks after elapsed, resumed of
+// them from a checkpoint, projecting the remaining time from the average of
+// the chunks decoded so far.
+func newJobProgress(done, resumed, total int, elapsed time.Duration) *JobProgress {
+	p := &JobProgress{ChunksDone: done, ChunksTotal: total, ChunksResumed: resumed, ElapsedS: elapsed.Seconds()}
+	if done > resumed {
+		p.ETAS = p.ElapsedS / float64(done-resumed) * float64(total-done)
+	}
+	return p
+}
+
+// handleJobCreate handles POST /jobs: validates the payload, queues a job,
+// and returns its ID immediately.
+func handleJobCreate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "POST only")
+		return
+	}
+	var req JobRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if msg := validateSource(req.TranscribeRequest); msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+	if msg := validateOptions(req.TranscribeRequest); msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+	if msg := validateTenant(r.Context(), req.TranscribeRequest); msg != "" {
+		writeError(w, http.StatusForbidden, msg)
+		return
+	}
+	req.tenant, req.requestID = tenantName(r.Context()), requestID(r.Context())
+	if req.CallbackURL != "" && !validHTTPURL(req.CallbackURL) {
+		writeError(w, http.StatusBadRequest, "callback_url must be an absolute http(s) URL")
+		return
+	}
+	if req.CallbackURL != "" && !cfg.CallbackAllowPrivate {
+		if err := checkPublicURL(r.Context(), req.CallbackURL); err != nil {
+			writeError(w, http.StatusBadRequest, "callback_url: "+err.Error()+"; set CALLBACK_ALLOW_PRIVATE to allow it")
+			return
+		}
+	}
+	j, err := jobs.enqueue(req)
+	if err != nil {
+		if !errors.Is(err, errJobQueueFull) {
+			log.Printf("WARNING: job store: %v", err)
+			err = errors.New("job store unavailable")
+		}
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	snap, _ := jobs.get(j.ID)
+	writeJSON(w, http.StatusAccepted, snap)
+}
+
+// handleJobGet handles GET /jobs/{id}.
+func handleJobGet(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "GET only")
+		return
+	}
+	j, ok := jobs.get(r.PathValue("id"))
+	if !ok || j.tenant != tenantName(r.Context()) {
+		writeError(w, http.StatusNotFound, "job not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, j)
+}
+
+// handleJobEvents handles GET /jobs/{id}/events: a server-sent event stream
+// with the job snapshot on every change, named after its status, ending
+// once the job is done or failed.
+func handleJobEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "GET only")
+		return
+	}
+	id := r.PathValue("id")
+	j, changed, ok := jobs.watch(id)
+	if !ok || j.tenant != tenantName(r.Context()) {
+		writeError(w, http.StatusNotFound, "job not found")
+		return
+	}
+
+	// Jobs can run far longer than the server's write timeout.
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{}) //nolint:errcheck
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	var last []byte
+	for {
+		data, _ := json.Marshal(j)
+		if !bytes.Equal(data, last) {
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", j.Status, data)
+			rc.Flush() //nolint:errcheck
+			last = data
+		}
+		if j.FinishedAt != nil {
+			return
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-changed:
+		}
+		if j, changed, ok = jobs.watch(id); !ok {
+			return
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"log"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Buckets of the BoltDB job backend: jobs by ID, and the IDs of queued jobs
+// by sequence number.
+var (
+	boltJobsBucket  = []byte("jobs")
+	boltQueueBucket = []byte("queue")
+)
+
+// boltJobBackend keeps jobs in a local BoltDB file so queued jobs survive
+// restarts of a single instance. The file is locked by one process at a
+// time, so it cannot be shared between replicas.
+type boltJobBackend struct {
+	db     *bolt.DB
+	pushed chan struct{} // signalled on push to wake a waiting pop
+}
+
+// openBoltJobBackend opens or creates the database at path and queues again
+// the jobs that were running when the previous process stopped.
+func openBoltJobBackend(path string) (*boltJobBackend, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	b := &boltJobBackend{db: db, pushed: make(chan struct{}, 1)}
+	if err := db.Update(b.recover); err != nil {
+		db.Close() //nolint:errcheck
+		return nil, err
+	}
+	return b, nil
+}
+
+// recover creates the buckets and requeues the jobs that were claimed but
+// not finished: those running, and those queued but no longer in the queue.
+func (b *boltJobBackend) recover(tx *bolt.Tx) error {
+	jb, err := tx.CreateBucketIfNotExists(boltJobsBucket)
+	if err != nil {
+		return err
+	}
+	qb, err := tx.CreateBucketIfNotExists(boltQueueBucket)
+	if err != nil {
+		return err
+	}
+	inQueue := make(map[string]bool)
+	err = qb.ForEach(func(_, id []byte) error {
+		inQueue[string(id)] = true
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	var running []Job
+	err = jb.ForEach(func(_, v []byte) error {
+		j, err := decodeJob(v)
+		if err == nil && (j.Status == jobRunning || j.Status == jobQueued && !inQueue[j.ID]) {
+			running = append(running, j)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, j := range running {
+		if err := b.queue(jb, qb, requeued(j)); err != nil {
+			return err
+		}
+		log.Printf("job %s requeued: the previous process stopped", j.ID)
+	}
+	return nil
+}
+
+// queue stores j and appends it to the queue.
+func (b *boltJobBackend) queue(jb, qb *bolt.Bucket, j Job) error {
+	data, err := encodeJob(j)
+	if err != nil {
+		return err
+	}
+	if err := jb.Put([]byte(j.ID), data); err != nil {
+		return err
+	}
+	seq, err := qb.NextSequence()
+	if err != nil {
+		return err
+	}
+	return qb.Put(binary.BigEndian.AppendUint64(nil, seq), []byte(j.ID))
+}
+
+func (b *boltJobBackend) push(j Job, limit int) (bool, error) {
+	queued := false
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		jb, qb := tx.Bucket(boltJobsBucket), tx.Bucket(boltQueueBucket)
+		if err := b.evict(jb, j.CreatedAt); err != nil {
+			return err
+		}
+		n, c := 0, qb.Cursor()
+		for k, _ := c.First(); k != nil && n < limit; k, _ = c.Next() {
+			n++
+		}
+		if n >= limit {
+			return nil
+		}
+		queued = true
+		return b.queue(jb, qb, j)
+	})
+	if queued && err == nil {
+		select {
+		case b.pushed <- struct{}{}:
+		default:
+		}
+	}
+	return queued && err == nil, err
+}
+
+// evict drops finished jobs older than the retention window.
+func (b *boltJobBackend) evict(jb *bolt.Bucket, now time.Time) error {
+	var expired [][]byte
+	err := jb.ForEach(func(k, v []byte) error {
+		j, err := decodeJob(v)
+		if err == nil && j.FinishedAt != nil && now.Sub(*j.FinishedAt) > cfg.JobRetention {
+			expired = append(expired, k)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, k := range expired {
+		if err := jb.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *boltJobBackend) pop(ctx context.Context) (Job, error) {
+	for {
+		var j Job
+		found, empty := false, false
+		err := b.db.Update(func(tx *bolt.Tx) error {
+			qb := tx.Bucket(boltQueueBucket)
+			k, id := qb.Cursor().First()
+			if k == nil {
+				empty = true
+				return nil
+			}
+			if err := qb.Delete(k); err != nil {
+				return err
+			}
+			data := tx.Bucket(boltJobsBucket).Get(id)
+			var err error
+			j, err = decodeJob(data)
+			found = err == nil // a lost or unreadable job is skipped
+			return nil
+		})
+		switch {
+		case err != nil:
+			return Job{}, err
+		case found:
+			return j, nil
+		case !empty:
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return Job{}, ctx.Err()
+		case <-b.pushed:
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func (b *boltJobBackend) save(j Job) error {
+	data, err := encodeJob(j)
+	if err != nil {
+		return err
+	}
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltJobsBucket).Put([]byte(j.ID), data)
+	})
+}
+
+func (b *boltJobBackend) load(id string) (Job, bool, error) {
+	var data []byte
+	err := b.db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(boltJobsBucket).Get([]byte(id)); v != nil {
+			data = append([]byte(nil), v...)
+		}
+		return nil
+	})
+	if err != nil || data == nil {
+		return Job{}, false, err
+	}
+	j, err := decodeJob(data)
+	return j, err == nil, err
+}
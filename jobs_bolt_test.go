@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/anatolykoptev/moonshine-whisper/pkg/moonshine"
+)
+
+// openTestBoltBackend opens a job database in a temp dir, closed at cleanup.
+func openTestBoltBackend(t *testing.T, path string) *boltJobBackend {
+	t.Helper()
+	b, err := openBoltJobBackend(path)
+	if err != nil {
+		t.Fatalf("openBoltJobBackend: %v", err)
+	}
+	t.Cleanup(func() { b.db.Close() }) //nolint:errcheck
+	return b
+}
+
+// --- boltJobBackend ---
+
+func TestBoltJobBackend_Queue(t *testing.T) {
+	b := openTestBoltBackend(t, filepath.Join(t.TempDir(), "jobs.db"))
+	for _, id := range []string{"a", "b"} {
+		j := Job{ID: id, Status: jobQueued, CreatedAt: time.Now(), req: TranscribeRequest{AudioPath: "/" + id + ".wav"}}
+		if ok, err := b.push(j, 2); !ok || err != nil {
+			t.Fatalf("push %s = %v, %v", id, ok, err)
+		}
+	}
+	if ok, err := b.push(Job{ID: "c"}, 2); ok || err != nil {
+		t.Errorf("push over limit = %v, %v, want false", ok, err)
+	}
+
+	j, err := b.pop(context.Background())
+	if err != nil || j.ID != "a" || j.req.AudioPath != "/a.wav" {
+		t.Fatalf("pop = %+v, %v, want job a with its request", j, err)
+	}
+	now := time.Now()
+	j.Status, j.FinishedAt = jobDone, &now
+	if err := b.save(j); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok, _ := b.load("a"); !ok || got.Status != jobDone {
+		t.Errorf("load a = %+v, %v, want done", got, ok)
+	}
+	if _, ok, _ := b.load("missing"); ok {
+		t.Error("load missing job should fail")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if j, err := b.pop(ctx); err != nil || j.ID != "b" {
+		t.Fatalf("pop = %+v, %v, want job b", j, err)
+	}
+	if _, err := b.pop(ctx); err == nil {
+		t.Error("pop on an empty queue should wait until ctx is done")
+	}
+}
+
+func TestBoltJobBackend_RecoversClaimedJobs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.db")
+	b, err := openBoltJobBackend(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"running", "claimed", "done"} {
+		b.push(Job{ID: id, Status: jobQueued, CreatedAt: time.Now()}, 10) //nolint:errcheck
+	}
+	for range 3 {
+		b.pop(context.Background()) //nolint:errcheck
+	}
+	started := time.Now()
+	for _, j := range []Job{
+		{ID: "running", Status: jobRunning, StartedAt: &started, Progress: &JobProgress{ChunksDone: 1},
+			checkpoint: &moonshine.Checkpoint{Total: 3, Chunks: map[int]moonshine.ChunkResult{0: {Text: "hello"}}}},
+		{ID: "done", Status: jobDone, FinishedAt: &started},
+	} {
+		if err := b.save(j); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b.db.Close() //nolint:errcheck
+
+	b = openTestBoltBackend(t, path)
+	got := map[string]Job{}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	for {
+		j, err := b.pop(ctx)
+		if err != nil {
+			break
+		}
+		got[j.ID] = j
+	}
+	if len(got) != 2 {
+		t.Fatalf("requeued %v, want running and claimed", got)
+	}
+	if j := got["running"]; j.Status != jobQueued || j.StartedAt != nil || j.Progress != nil {
+		t.Errorf("requeued job = %+v, want queued without progress", j)
+	}
+	if c := got["running"].checkpoint; c == nil || c.Total != 3 || c.Chunks[0].Text != "hello" {
+		t.Errorf("requeued checkpoint = %+v, want the decoded chunk kept", c)
+	}
+}
+
+func TestBoltJobBackend_EvictsExpired(t *testing.T) {
+	oldRetention := cfg.JobRetention
+	cfg.JobRetention = time.Minute
+	t.Cleanup(func() { cfg.JobRetention = oldRetention })
+
+	b := openTestBoltBackend(t, filepath.Join(t.TempDir(), "jobs.db"))
+	finished := time.Now().Add(-2 * time.Minute)
+	b.save(Job{ID: "old", Status: jobDone, FinishedAt: &finished}) //nolint:errcheck
+	b.push(Job{ID: "new", CreatedAt: time.Now()}, 1)               //nolint:errcheck
+	if _, ok, _ := b.load("old"); ok {
+		t.Error("expired job should be evicted")
+	}
+}
+
+func TestBoltJobBackend_Locked(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.db")
+	openTestBoltBackend(t, path)
+	if _, err := openBoltJobBackend(path); err == nil {
+		t.Error("a second process must not open the same database")
+	}
+}
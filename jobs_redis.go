@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis keys of the job backend: each job is stored at redisJobKey, its ID
+// moves from the queue list to the running list when claimed, and a lease
+// key shows the claiming replica is still alive.
+const (
+	redisQueueKey   = "moonshine:jobs:queue"
+	redisRunningKey = "moonshine:jobs:running"
+	redisJobKey     = "moonshine:job:"
+	redisLeaseKey   = "moonshine:job-lease:"
+)
+
+// redisLeaseTTL is how long a claimed job stays with its replica without a
+// lease renewal; renewals happen every third of it.
+const redisLeaseTTL = 30 * time.Second
+
+// redisJobBackend shares one job queue between replicas through Redis. A
+// job whose lease expires, because its replica stopped, is queued again.
+type redisJobBackend struct {
+	rdb *redis.Client
+
+	mu       sync.Mutex
+	active   map[string]bool // IDs of jobs claimed by this replica
+	unleased map[string]bool // running IDs without a lease at the last check
+}
+
+// newRedisJobBackend connects to the Redis server at url
+// (redis://[:password@]host:port/db) and starts renewing leases and
+// requeueing abandoned jobs.
+func newRedisJobBackend(url string) (*redisJobBackend, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	b := &redisJobBackend{rdb: redis.NewClient(opts), active: make(map[string]bool), unleased: make(map[string]bool)}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := b.rdb.Ping(ctx).Err(); err != nil {
+		b.rdb.Close() //nolint:errcheck
+		return nil, err
+	}
+	go b.maintain()
+	return b, nil
+}
+
+func (b *redisJobBackend) push(j Job, limit int) (bool, error) {
+	ctx := context.Background()
+	data, err := encodeJob(j)
+	if err != nil {
+		return false, err
+	}
+	n, err := b.rdb.LLen(ctx, redisQueueKey).Result()
+	if err != nil {
+		return false, err
+	}
+	if n >= int64(limit) {
+		return false, nil
+	}
+	_, err = b.rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.Set(ctx, redisJobKey+j.ID, data, 0)
+		p.LPush(ctx, redisQueueKey, j.ID)
+		return nil
+	})
+	return err == nil, err
+}
+
+func (b *redisJobBackend) pop(ctx context.Context) (Job, error) {
+	for {
+		id, err := b.rdb.BLMove(ctx, redisQueueKey, redisRunningKey, "RIGHT", "LEFT", 5*time.Second).Result()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return Job{}, err
+		}
+		if err := b.rdb.Set(ctx, redisLeaseKey+id, 1, redisLeaseTTL).Err(); err != nil {
+			return Job{}, err
+		}
+		b.mu.Lock()
+		b.active[id] = true
+		b.mu.Unlock()
+		j, ok, err := b.load(id)
+		if err != nil {
+			return Job{}, err
+		}
+		if !ok {
+			b.release(ctx, id)
+			continue
+		}
+		return j, nil
+	}
+}
+
+func (b *redisJobBackend) save(j Job) error {
+	ctx := context.Background()
+	data, err := encodeJob(j)
+	if err != nil {
+		return err
+	}
+	if j.FinishedAt == nil {
+		return b.rdb.Set(ctx, redisJobKey+j.ID, data, 0).Err()
+	}
+	if err := b.rdb.Set(ctx, redisJobKey+j.ID, data, cfg.JobRetention).Err(); err != nil {
+		return err
+	}
+	b.release(ctx, j.ID)
+	return nil
+}
+
+func (b *redisJobBackend) load(id string) (Job, bool, error) {
+	data, err := b.rdb.Get(context.Background(), redisJobKey+id).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return Job{}, false, nil
+	}
+	if err != nil {
+		return Job{}, false, err
+	}
+	j, err := decodeJob(data)
+	return j, err == nil, err
+}
+
+// release drops a claimed job from the running list and its lease.
+func (b *redisJobBackend) release(ctx context.Context, id string) {
+	b.mu.Lock()
+	delete(b.active, id)
+	b.mu.Unlock()
+	b.rdb.LRem(ctx, redisRunningKey, 1, id) //nolint:errcheck
+	b.rdb.Del(ctx, redisLeaseKey+id)        //nolint:errcheck
+}
+
+// maintain renews the leases of this replica's jobs and requeues jobs whose
+// lease has expired, forever.
+func (b *redisJobBackend) maintain() {
+	for range time.Tick(redisLeaseTTL / 3) {
+		ctx := context.Background()
+		b.mu.Lock()
+		for id := range b.active {
+			b.rdb.Expire(ctx, redisLeaseKey+id, redisLeaseTTL) //nolint:errcheck
+		}
+		b.mu.Unlock()
+		if err := b.requeueAbandoned(ctx); err != nil {
+			log.Printf("WARNING: job queue: %v", err)
+		}
+	}
+}
+
+// requeueAbandoned moves running jobs found without a lease on two checks
+// in a row back to the front of the queue; the second check skips jobs
+// claimed just before their lease was set. Only the replica whose LREM
+// removes the ID requeues it.
+func (b *redisJobBackend) requeueAbandoned(ctx context.Context) error {
+	ids, err := b.rdb.LRange(ctx, redisRunningKey, 0, -1).Result()
+	if err != nil {
+		return err
+	}
+	seen := b.unleased
+	b.unleased = make(map[string]bool)
+	for _, id := range ids {
+		if n, err := b.rdb.Exists(ctx, redisLeaseKey+id).Result(); err != nil || n > 0 {
+			continue
+		}
+		if !seen[id] {
+			b.unleased[id] = true
+			continue
+		}
+		if n, err := b.rdb.LRem(ctx, redisRunningKey, 1, id).Result(); err != nil || n == 0 {
+			continue
+		}
+		j, ok, err := b.load(id)
+		if err != nil || !ok {
+			continue
+		}
+		if err := b.save(requeued(j)); err != nil {
+			return err
+		}
+		if err := b.rdb.RPush(ctx, redisQueueKey, id).Err(); err != nil {
+			return err
+		}
+		log.Printf("job %s requeued: its worker stopped", id)
+	}
+	return nil
+}
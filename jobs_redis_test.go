@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// newTestRedisBackend connects a backend to an in-process Redis server.
+func newTestRedisBackend(t *testing.T) (*redisJobBackend, *miniredis.Miniredis) {
+	t.Helper()
+	srv := miniredis.RunT(t)
+	b, err := newRedisJobBackend("redis://" + srv.Addr())
+	if err != nil {
+		t.Fatalf("newRedisJobBackend: %v", err)
+	}
+	t.Cleanup(func() { b.rdb.Close() }) //nolint:errcheck
+	return b, srv
+}
+
+// --- redisJobBackend ---
+
+func TestRedisJobBackend_Queue(t *testing.T) {
+	b, srv := newTestRedisBackend(t)
+	for _, id := range []string{"a", "b"} {
+		j := Job{ID: id, Status: jobQueued, CreatedAt: time.Now(), req: TranscribeRequest{AudioPath: "/" + id + ".wav"}}
+		if ok, err := b.push(j, 2); !ok || err != nil {
+			t.Fatalf("push %s = %v, %v", id, ok, err)
+		}
+	}
+	if ok, err := b.push(Job{ID: "c"}, 2); ok || err != nil {
+		t.Errorf("push over limit = %v, %v, want false", ok, err)
+	}
+
+	j, err := b.pop(context.Background())
+	if err != nil || j.ID != "a" || j.req.AudioPath != "/a.wav" {
+		t.Fatalf("pop = %+v, %v, want job a with its request", j, err)
+	}
+	if !srv.Exists(redisLeaseKey + "a") {
+		t.Error("a claimed job must have a lease")
+	}
+
+	now := time.Now()
+	j.Status, j.FinishedAt = jobDone, &now
+	if err := b.save(j); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok, _ := b.load("a"); !ok || got.Status != jobDone {
+		t.Errorf("load a = %+v, %v, want done", got, ok)
+	}
+	if ttl := srv.TTL(redisJobKey + "a"); ttl != cfg.JobRetention {
+		t.Errorf("finished job TTL = %s, want %s", ttl, cfg.JobRetention)
+	}
+	if running, _ := srv.List(redisRunningKey); len(running) != 0 || srv.Exists(redisLeaseKey+"a") {
+		t.Errorf("finished job must be released, running = %v", running)
+	}
+}
+
+func TestRedisJobBackend_RequeuesAbandoned(t *testing.T) {
+	b, srv := newTestRedisBackend(t)
+	b.push(Job{ID: "a", Status: jobQueued, CreatedAt: time.Now()}, 10) //nolint:errcheck
+	j, _ := b.pop(context.Background())
+	started := time.Now()
+	j.Status, j.StartedAt = jobRunning, &started
+	b.save(j) //nolint:errcheck
+
+	ctx := context.Background()
+	b.requeueAbandoned(ctx) //nolint:errcheck
+	if queue, _ := srv.List(redisQueueKey); len(queue) != 0 {
+		t.Fatalf("job with a lease requeued: %v", queue)
+	}
+
+	srv.FastForward(redisLeaseTTL + time.Second) // the replica stopped renewing
+	b.requeueAbandoned(ctx)                      //nolint:errcheck
+	if queue, _ := srv.List(redisQueueKey); len(queue) != 0 {
+		t.Fatalf("job requeued on the first check without a lease: %v", queue)
+	}
+	b.requeueAbandoned(ctx) //nolint:errcheck
+	if queue, _ := srv.List(redisQueueKey); len(queue) != 1 || queue[0] != "a" {
+		t.Fatalf("queue = %v, want [a]", queue)
+	}
+	if got, _, _ := b.load("a"); got.Status != jobQueued || got.StartedAt != nil {
+		t.Errorf("requeued job = %+v, want queued", got)
+	}
+}
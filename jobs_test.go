@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestJobStore swaps the global store for an isolated one with a queue
+// of the given size and no workers.
+func newTestJobStore(t *testing.T, queueSize int) {
+	t.Helper()
+	old := jobs
+	jobs = &jobStore{jobs: make(map[string]*Job), queue: make(chan *Job, queueSize)}
+	t.Cleanup(func() { jobs = old })
+}
+
+// --- jobStore ---
+
+func TestJobStore_EnqueueAndGet(t *testing.T) {
+	newTestJobStore(t, 1)
+	j, err := jobs.enqueue(JobRequest{TranscribeRequest: TranscribeRequest{AudioPath: "/a.wav"}})
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	got, ok := jobs.get(j.ID)
+	if !ok {
+		t.Fatal("job not found after enqueue")
+	}
+	if got.Status != jobQueued {
+		t.Errorf("status = %q, want %q", got.Status, jobQueued)
+	}
+}
+
+func TestJobStore_QueueFull(t *testing.T) {
+	newTestJobStore(t, 1)
+	if _, err := jobs.enqueue(JobRequest{}); err != nil {
+		t.Fatalf("first enqueue: %v", err)
+	}
+	if _, err := jobs.enqueue(JobRequest{}); err != errJobQueueFull {
+		t.Errorf("second enqueue error = %v, want %v", err, errJobQueueFull)
+	}
+	if n := len(jobs.jobs); n != 1 {
+		t.Errorf("store has %d jobs, want 1", n)
+	}
+}
+
+func TestJobStore_EvictsExpired(t *testing.T) {
+	newTestJobStore(t, 4)
+	oldRetention := cfg.JobRetention
+	cfg.JobRetention = time.Minute
+	t.Cleanup(func() { cfg.JobRetention = oldRetention })
+
+	finished := time.Now().Add(-2 * time.Minute)
+	jobs.jobs["old"] = &Job{ID: "old", Status: jobDone, FinishedAt: &finished}
+	jobs.jobs["running"] = &Job{ID: "running", Status: jobRunning}
+
+	jobs.enqueue(JobRequest{})
+	if _, ok := jobs.get("old"); ok {
+		t.Error("expired job should be evicted")
+	}
+	if _, ok := jobs.get("running"); !ok {
+		t.Error("unfinished job should be kept")
+	}
+}
+
+func TestJobStore_WatchWakesOnUpdate(t *testing.T) {
+	newTestJobStore(t, 1)
+	j, _ := jobs.enqueue(JobRequest{})
+	_, changed, ok := jobs.watch(j.ID)
+	if !ok {
+		t.Fatal("job not found")
+	}
+	select {
+	case <-changed:
+		t.Fatal("changed closed before an update")
+	default:
+	}
+	jobs.update(j, func(j *Job) { j.Status = jobRunning })
+	select {
+	case <-changed:
+	default:
+		t.Error("update must close the watch channel")
+	}
+	if snap, _, _ := jobs.watch(j.ID); snap.Status != jobRunning {
+		t.Errorf("status = %q, want %q", snap.Status, jobRunning)
+	}
+}
+
+func TestJobStore_Backend(t *testing.T) {
+	newTestJobStore(t, 1)
+	jobs.backend = openTestBoltBackend(t, filepath.Join(t.TempDir(), "jobs.db"))
+
+	j, err := jobs.enqueue(JobRequest{TranscribeRequest: TranscribeRequest{AudioPath: "/a.wav"}})
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	if _, err := jobs.enqueue(JobRequest{}); err != errJobQueueFull {
+		t.Errorf("second enqueue error = %v, want %v", err, errJobQueueFull)
+	}
+
+	claimed, err := jobs.backend.pop(context.Background())
+	if err != nil || claimed.ID != j.ID {
+		t.Fatalf("pop = %+v, %v, want job %s", claimed, err, j.ID)
+	}
+	local := jobs.adopt(claimed)
+	_, changed, _ := jobs.watch(j.ID)
+	jobs.update(local, func(j *Job) { j.Status = jobRunning })
+	select {
+	case <-changed:
+	case <-time.After(jobPollInterval / 2):
+		t.Error("update must wake watchers before the poll interval")
+	}
+	if got, ok := jobs.get(j.ID); !ok || got.Status != jobRunning || got.req.AudioPath != "/a.wav" {
+		t.Errorf("get = %+v, %v, want the running job from the backend", got, ok)
+	}
+}
+
+// --- newJobProgress ---
+
+func TestNewJobProgress(t *testing.T) {
+	tests := []struct {
+		done, resumed, total int
+		elapsed              time.Duration
+		wantETA              float64
+	}{
+		{0, 0, 10, time.Second, 0},
+		{2, 0, 10, 4 * time.Second, 16},
+		{10, 0, 10, 20 * time.Second, 0},
+		{6, 4, 10, 4 * time.Second, 8},
+		{4, 4, 10, time.Second, 0},
+	}
+	for _, tt := range tests {
+		p := newJobProgress(tt.done, tt.resumed, tt.total, tt.elapsed)
+		if p.ChunksDone != tt.done || p.ChunksResumed != tt.resumed || p.ChunksTotal != tt.total || p.ElapsedS != tt.elapsed.Seconds() || p.ETAS != tt.wantETA {
+			t.Errorf("newJobProgress(%d, %d, %d, %s) = %+v, want eta %g", tt.done, tt.resumed, tt.total, tt.elapsed, *p, tt.wantETA)
+		}
+	}
+}
+
+// --- runJob / callbacks ---
+
+func TestRunJob_FailureDeliversCallback(t *testing.T) {
+	newTestJobStore(t, 1)
+	setCallbackRetries(t, 0)
+	got := make(chan Job, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var j Job
+		if err := json.NewDecoder(r.Body).Decode(&j); err != nil {
+			t.Errorf("decode callback: %v", err)
+		}
+		got <- j
+	}))
+	defer srv.Close()
+
+	j, _ := jobs.enqueue(JobRequest{
+		TranscribeRequest: TranscribeRequest{AudioPath: "/tmp/nonexistent_12345.wav"},
+		CallbackURL:       srv.URL,
+	})
+	runJob(j)
+
+	select {
+	case cb := <-got:
+		if cb.ID != j.ID {
+			t.Errorf("callback id = %q, want %q", cb.ID, j.ID)
+		}
+		if cb.Status != jobFailed {
+			t.Errorf("callback status = %q, want %q", cb.Status, jobFailed)
+		}
+		if cb.Result == nil || cb.Result.Error == "" {
+			t.Error("callback should carry the error result")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("callback not delivered")
+	}
+}
+
+// --- handlers ---
+
+func TestHandleJobCreate_Validation(t *testing.T) {
+	newTestJobStore(t, 1)
+	tests := []struct {
+		body string
+		want int
+	}{
+		{`not json`, http.StatusBadRequest},
+		{`{}`, http.StatusBadRequest},
+		{`{"audio_path":"/a.wav","callback_url":"ftp://x"}`, http.StatusBadRequest},
+		{`{"audio_path":"/a.wav","callback_url":"http://127.0.0.1:8080/hook"}`, http.StatusBadRequest},
+		{`{"audio_path":"/a.wav","callback_url":"http://169.254.169.254/latest/meta-data/"}`, http.StatusBadRequest},
+		{`{"audio_path":"/a.wav","callback_url":"http://[::ffff:10.0.0.1]/hook"}`, http.StatusBadRequest},
+		{`{"audio_path":"/a.wav"}`, http.StatusAccepted},
+		{`{"audio_path":"/b.wav"}`, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handleJobCreate(rec, httptest.NewRequest(http.MethodPost, "/jobs", strings.NewReader(tt.body)))
+		if rec.Code != tt.want {
+			t.Errorf("POST /jobs %s = %d, want %d", tt.body, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestHandleJobGet(t *testing.T) {
+	newTestJobStore(t, 1)
+	j, _ := jobs.enqueue(JobRequest{TranscribeRequest: TranscribeRequest{AudioPath: "/a.wav"}})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/jobs/{id}", handleJobGet)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jobs/"+j.ID, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET existing job = %d, want 200", rec.Code)
+	}
+	var got Job
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got.ID != j.ID {
+		t.Errorf("id = %q, want %q", got.ID, j.ID)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jobs/missing", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("GET missing job = %d, want 404", rec.Code)
+	}
+
+	other := httptest.NewRequest(http.MethodGet, "/jobs/"+j.ID, nil)
+	other = other.WithContext(withTenant(other.Context(), &tenant{TenantConfig: TenantConfig{Name: "acme"}}))
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, other)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("GET another tenant's job = %d, want 404", rec.Code)
+	}
+}
+
+func TestHandleJobEvents(t *testing.T) {
+	newTestJobStore(t, 1)
+	j, _ := jobs.enqueue(JobRequest{})
+	jobs.update(j, func(j *Job) {
+		now := time.Now()
+		j.Status, j.FinishedAt = jobDone, &now
+	})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/jobs/{id}/events", handleJobEvents)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jobs/"+j.ID+"/events", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET events = %d, want 200", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", ct)
+	}
+	if body := rec.Body.String(); !strings.HasPrefix(body, "event: done\ndata: {") || strings.Count(body, "event:") != 1 {
+		t.Errorf("finished job should send one done event, got %q", body)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jobs/missing/events", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("GET missing job events = %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// kafkaReader is the part of *kafka.Reader the worker uses.
+type kafkaReader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// kafkaWriter is the part of *kafka.Writer the worker uses.
+type kafkaWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// startKafkaWorker consumes KAFKA_REQUEST_TOPIC as consumer group
+// KAFKA_GROUP_ID and publishes results to KAFKA_RESULT_TOPIC until ctx is
+// done.
+func startKafkaWorker(ctx context.Context) {
+	r := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:  cfg.KafkaBrokers,
+		GroupID:  cfg.KafkaGroupID,
+		Topic:    cfg.KafkaRequestTopic,
+		MaxBytes: (cfg.InlineMaxMB*4/3 + 1) << 20, // room for audio_base64
+	})
+	w := &kafka.Writer{
+		Addr:         kafka.TCP(cfg.KafkaBrokers...),
+		Topic:        cfg.KafkaResultTopic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+	}
+	go runKafkaWorker(ctx, r, w)
+}
+
+// runKafkaWorker transcribes request messages one at a time, publishing each
+// result keyed by request ID before committing the request, so a request
+// whose result was not published is redelivered after a restart.
+func runKafkaWorker(ctx context.Context, r kafkaReader, w kafkaWriter) {
+	defer r.Close() //nolint:errcheck
+	defer w.Close() //nolint:errcheck
+	for {
+		m, err := r.FetchMessage(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Printf("WARNING: kafka fetch: %v", err)
+			if errors.Is(err, kafka.ErrGroupClosed) || !sleepCtx(ctx, time.Second) {
+				return
+			}
+			continue
+		}
+		j := processQueueMessage(ctx, m.Value, string(m.Key))
+		if ctx.Err() != nil {
+			return // interrupted by shutdown; left uncommitted for the next start
+		}
+		if !publishKafkaResult(ctx, w, j) {
+			return
+		}
+		if err := r.CommitMessages(ctx, m); err != nil {
+			log.Printf("WARNING: kafka request %s: commit: %v", j.ID, err)
+		}
+	}
+}
+
+// publishKafkaResult publishes j, retrying until it succeeds. It returns
+// false if ctx is done first. Later commits would also commit the request,
+// so it is not skipped.
+func publishKafkaResult(ctx context.Context, w kafkaWriter, j Job) bool {
+	body, err := json.Marshal(j)
+	if err != nil {
+		log.Printf("WARNING: kafka request %s: encode result: %v", j.ID, err)
+		return true
+	}
+	for {
+		err := w.WriteMessages(ctx, kafka.Message{Key: []byte(j.ID), Value: body})
+		if err == nil {
+			return true
+		}
+		log.Printf("WARNING: kafka request %s: publish result: %v", j.ID, err)
+		if !sleepCtx(ctx, time.Second) {
+			return false
+		}
+	}
+}
+
+// sleepCtx waits for d and reports whether ctx is still live.
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// fakeKafka serves msgs in order, then blocks until ctx is done. Writes fail
+// failWrites times before succeeding.
+type fakeKafka struct {
+	mu         sync.Mutex
+	msgs       []kafka.Message
+	written    []kafka.Message
+	committed  []kafka.Message
+	failWrites int
+	cancel     context.CancelFunc
+}
+
+func (f *fakeKafka) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	f.mu.Lock()
+	if len(f.msgs) > 0 {
+		m := f.msgs[0]
+		f.msgs = f.msgs[1:]
+		f.mu.Unlock()
+		return m, nil
+	}
+	f.mu.Unlock()
+	f.cancel()
+	<-ctx.Done()
+	return kafka.Message{}, ctx.Err()
+}
+
+func (f *fakeKafka) CommitMessages(_ context.Context, msgs ...kafka.Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.committed = append(f.committed, msgs...)
+	return nil
+}
+
+func (f *fakeKafka) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failWrites > 0 {
+		f.failWrites--
+		return errors.New("leader not available")
+	}
+	f.written = append(f.written, msgs...)
+	return nil
+}
+
+func (f *fakeKafka) Close() error { return nil }
+
+// --- runKafkaWorker ---
+
+func TestRunKafkaWorker(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	f := &fakeKafka{
+		msgs: []kafka.Message{
+			{Key: []byte("k1"), Value: []byte(`{"id":"r1"}`), Offset: 1},
+			{Key: []byte("k2"), Value: []byte(`not json`), Offset: 2},
+		},
+		failWrites: 1,
+		cancel:     cancel,
+	}
+	runKafkaWorker(ctx, f, f)
+
+	if len(f.written) != 2 || len(f.committed) != 2 {
+		t.Fatalf("written %d, committed %d results, want 2 and 2", len(f.written), len(f.committed))
+	}
+	for i, want := range []string{"r1", "k2"} {
+		m := f.written[i]
+		if string(m.Key) != want {
+			t.Errorf("result %d key = %q, want %q", i, m.Key, want)
+		}
+		var j Job
+		if err := json.Unmarshal(m.Value, &j); err != nil {
+			t.Fatalf("result %d: %v", i, err)
+		}
+		if j.ID != want || j.Status != jobFailed || j.Result == nil || j.Result.Error == "" {
+			t.Errorf("result %d = %+v, want a failed job %s with an error", i, j, want)
+		}
+		if f.committed[i].Offset != int64(i+1) {
+			t.Errorf("commit %d offset = %d, want %d", i, f.committed[i].Offset, i+1)
+		}
+	}
+}
+
+func TestRunKafkaWorker_PublishInterrupted(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	f := &fakeKafka{
+		msgs:       []kafka.Message{{Key: []byte("k1"), Value: []byte(`{}`)}},
+		failWrites: 1 << 30,
+		cancel:     cancel,
+	}
+	time.AfterFunc(50*time.Millisecond, cancel)
+	runKafkaWorker(ctx, f, f)
+	if len(f.written) != 0 {
+		t.Fatalf("written %d results, want 0", len(f.written))
+	}
+	if len(f.committed) != 0 {
+		t.Errorf("committed %d requests without a published result", len(f.committed))
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/anatolykoptev/moonshine-whisper/pkg/moonshine"
+)
+
+// Number of languages POST /identify-language returns.
+const (
+	lidDefaultTopK = 3
+	lidMaxTopK     = 10
+)
+
+// IdentifyLanguageResponse is the JSON response of POST /identify-language.
+type IdentifyLanguageResponse struct {
+	Languages  []moonshine.LanguageScore `json:"languages"` // most likely first; empty without speech
+	DurationMs float64                   `json:"duration_ms"`
+}
+
+// handleIdentifyLanguage handles POST /identify-language: the languages
+// spoken in the audio of a /transcribe JSON body or an "audio" multipart
+// upload, with the top_k query parameter bounding how many are returned.
+func handleIdentifyLanguage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "POST only")
+		return
+	}
+	topK := lidDefaultTopK
+	if v := r.URL.Query().Get("top_k"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > lidMaxTopK {
+			writeError(w, http.StatusBadRequest, "top_k must be in [1, "+strconv.Itoa(lidMaxTopK)+"]")
+			return
+		}
+		topK = n
+	}
+	start := time.Now()
+	ctx, req, audioPath, done, ok := analysisAudio(w, r)
+	if !ok {
+		return
+	}
+	defer done()
+	langs, err := engineFor(ctx).IdentifyLanguage(ctx, audioPath, requestOptions(ctx, req))
+	if err != nil {
+		resp, status := engineError(ctx, err)
+		writeJSON(w, status, resp)
+		return
+	}
+	writeJSON(w, http.StatusOK, IdentifyLanguageResponse{
+		Languages:  langs[:min(topK, len(langs))],
+		DurationMs: float64(time.Since(start).Milliseconds()),
+	})
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// --- handleIdentifyLanguage ---
+
+func TestHandleIdentifyLanguage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "clip.wav")
+	if err := os.WriteFile(path, silentWav(16000, 16000), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	body := `{"audio_path":"` + path + `"}`
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		want   int
+	}{
+		{"method", http.MethodGet, "/identify-language", "", http.StatusMethodNotAllowed},
+		{"top_k zero", http.MethodPost, "/identify-language?top_k=0", body, http.StatusBadRequest},
+		{"top_k too large", http.MethodPost, "/identify-language?top_k=11", body, http.StatusBadRequest},
+		{"no source", http.MethodPost, "/identify-language", `{}`, http.StatusBadRequest},
+		{"model not loaded", http.MethodPost, "/identify-language", body, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handleIdentifyLanguage(rec, httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body)))
+		if rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d (%s)", tt.name, rec.Code, tt.want, rec.Body)
+		}
+	}
+}
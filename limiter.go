@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/anatolykoptev/moonshine-whisper/pkg/moonshine"
+)
+
+// Errors of decodeLimiter.acquire: no more requests may wait, or the queue
+// is long enough to shed load.
+var (
+	errQueueFull = errors.New("decode queue full")
+	errShed      = errors.New("decode queue over the shedding threshold")
+)
+
+// decodeLimiter bounds how many transcriptions run at once and how many
+// requests may wait for a slot, so bursts fail fast instead of piling up
+// on the recognizer mutexes. Requests wait in two lanes: a freed slot goes
+// to the longest waiting normal request, and to a low priority one only
+// when no normal request waits, so interactive requests never queue
+// behind batch files. MAX_QUEUED and SHED_QUEUE_DEPTH apply to each lane.
+type decodeLimiter struct {
+	size      int
+	maxQueued int
+	shedAt    int // queue depth at which requests get 503; 0 = never
+
+	mu      sync.Mutex
+	busy    int                // slots taken
+	waiting [2][]chan struct{} // normal, then low priority; first come first served
+	avg     time.Duration      // moving average of how long a slot is held
+}
+
+// newDecodeLimiter allows concurrent transcriptions with up to queued
+// waiters per lane, shedding requests once shedAt wait in their lane
+// (0 = never).
+func newDecodeLimiter(concurrent, queued, shedAt int) *decodeLimiter {
+	return &decodeLimiter{size: concurrent, maxQueued: queued, shedAt: shedAt}
+}
+
+// lane returns the waiting lane of a request: 1 for low priority, else 0.
+func lane(low bool) int {
+	if low {
+		return 1
+	}
+	return 0
+}
+
+// acquire takes a slot, waiting in the lane of low if all are busy. It
+// fails with errShed when shedAt requests already wait in the lane, with
+// errQueueFull when the lane is at capacity, or with ctx.Err() if ctx is
+// done first. The returned func releases the slot.
+func (l *decodeLimiter) acquire(ctx context.Context, low bool) (func(), error) {
+	ln := lane(low)
+	l.mu.Lock()
+	if l.busy < l.size {
+		l.busy++
+		l.mu.Unlock()
+		return l.releaser(), nil
+	}
+	n := len(l.waiting[ln])
+	if l.shedAt > 0 && n >= l.shedAt {
+		l.mu.Unlock()
+		return nil, errShed
+	}
+	if n >= l.maxQueued {
+		l.mu.Unlock()
+		return nil, errQueueFull
+	}
+	ch := make(chan struct{}, 1)
+	l.waiting[ln] = append(l.waiting[ln], ch)
+	l.mu.Unlock()
+
+	select {
+	case <-ch:
+		return l.releaser(), nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		if i := slices.Index(l.waiting[ln], ch); i >= 0 {
+			l.waiting[ln] = slices.Delete(l.waiting[ln], i, i+1)
+			l.mu.Unlock()
+		} else {
+			l.mu.Unlock()
+			l.release() // handed a slot as ctx ended; pass it on
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// releaser returns a func that records the slot hold time and frees the slot.
+func (l *decodeLimiter) releaser() func() {
+	start := time.Now()
+	return func() {
+		l.observe(time.Since(start))
+		l.release()
+	}
+}
+
+// release hands a slot to the longest waiting request, normal priority
+// first, or frees it.
+func (l *decodeLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for ln, w := range l.waiting {
+		if len(w) > 0 {
+			w[0] <- struct{}{}
+			l.waiting[ln] = w[1:]
+			return
+		}
+	}
+	l.busy--
+}
+
+// observe folds d into the moving average of slot hold times.
+func (l *decodeLimiter) observe(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.avg == 0 {
+		l.avg = d
+		return
+	}
+	l.avg = (7*l.avg + d) / 8
+}
+
+// queueDepth returns how many requests wait ahead of a new one in the lane
+// of low: the normal ones, and for low priority the low ones too.
+func (l *decodeLimiter) queueDepth(low bool) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := len(l.waiting[0])
+	if low {
+		n += len(l.waiting[1])
+	}
+	return n
+}
+
+// estimatedWait guesses how long a request queued now in the lane of low
+// would wait.
+func (l *decodeLimiter) estimatedWait(low bool) time.Duration {
+	ahead := l.queueDepth(low)
+	l.mu.Lock()
+	avg := l.avg
+	l.mu.Unlock()
+	return avg * time.Duration(ahead+1) / time.Duration(l.size)
+}
+
+// busyResponse is the body of a request the limiter turns away.
+type busyResponse struct {
+	Error          string  `json:"error"`
+	QueueDepth     int     `json:"queue_depth"`      // requests waiting ahead of it
+	EstimatedWaitS float64 `json:"estimated_wait_s"` // for a request queued now
+}
+
+// middleware runs next while holding a slot, queued in the low priority
+// lane for priority=low in the query or, without one, the tenant's default
+// priority. A full lane gets 429 and a lane past SHED_QUEUE_DEPTH 503, both
+// with a Retry-After estimate and a busyResponse; a client that gives up
+// while queued gets 499.
+func (l *decodeLimiter) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		low := requestPriority(r) == moonshine.PriorityLow
+		release, err := l.acquire(r.Context(), low)
+		if errors.Is(err, errQueueFull) || errors.Is(err, errShed) {
+			status := http.StatusTooManyRequests
+			if errors.Is(err, errShed) {
+				status = http.StatusServiceUnavailable
+			}
+			wait, depth := l.estimatedWait(low), l.queueDepth(low)
+			secs := max(1, int(math.Ceil(wait.Seconds())))
+			w.Header().Set("Retry-After", strconv.Itoa(secs))
+			writeJSON(w, status, busyResponse{
+				Error:          fmt.Sprintf("server busy: %d requests queued, retry in ~%ds", depth, secs),
+				QueueDepth:     depth,
+				EstimatedWaitS: math.Round(wait.Seconds()*10) / 10,
+			})
+			return
+		}
+		if err != nil {
+			writeError(w, statusClientClosedRequest, "request canceled while queued")
+			return
+		}
+		defer release()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestPriority returns the priority r queues with: its priority query
+// parameter, else the default of its tenant, else normal.
+func requestPriority(r *http.Request) string {
+	if p := r.URL.Query().Get("priority"); p != "" {
+		return p
+	}
+	if t := tenantFrom(r.Context()); t != nil && t.Priority != "" {
+		return t.Priority
+	}
+	return moonshine.PriorityNormal
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anatolykoptev/moonshine-whisper/pkg/moonshine"
+)
+
+// --- decodeLimiter ---
+
+func TestDecodeLimiter_QueueFull(t *testing.T) {
+	l := newDecodeLimiter(1, 1, 0)
+	release, err := l.acquire(context.Background(), false)
+	if err != nil {
+		t.Fatalf("first acquire: %v", err)
+	}
+
+	queued := make(chan error, 1)
+	go func() {
+		rel, err := l.acquire(context.Background(), false)
+		if err == nil {
+			rel()
+		}
+		queued <- err
+	}()
+	for l.queueDepth(false) != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err := l.acquire(context.Background(), false); !errors.Is(err, errQueueFull) {
+		t.Errorf("third acquire err = %v, want errQueueFull", err)
+	}
+	release()
+	if err := <-queued; err != nil {
+		t.Errorf("queued acquire err = %v, want slot after release", err)
+	}
+}
+
+func TestDecodeLimiter_Canceled(t *testing.T) {
+	l := newDecodeLimiter(1, 4, 0)
+	release, _ := l.acquire(context.Background(), false)
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := l.acquire(ctx, false); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want DeadlineExceeded", err)
+	}
+	if n := l.queueDepth(false); n != 0 {
+		t.Errorf("queued = %d after cancel, want 0", n)
+	}
+}
+
+func TestDecodeLimiter_EstimatedWait(t *testing.T) {
+	l := newDecodeLimiter(2, 8, 0)
+	l.observe(4 * time.Second)
+	l.waiting[0] = make([]chan struct{}, 3)
+	// 4 waiting (3 + the new request) over 2 slots at 4s each.
+	if got := l.estimatedWait(false); got != 8*time.Second {
+		t.Errorf("estimatedWait = %s, want 8s", got)
+	}
+	l.waiting[1] = make([]chan struct{}, 2)
+	if got := l.estimatedWait(true); got != 12*time.Second {
+		t.Errorf("estimatedWait(low) = %s, want 12s behind the normal requests", got)
+	}
+	l.observe(12 * time.Second)
+	if l.avg != 5*time.Second {
+		t.Errorf("avg = %s, want 5s", l.avg)
+	}
+}
+
+func TestDecodeLimiter_Middleware(t *testing.T) {
+	l := newDecodeLimiter(1, 0, 0)
+	h := l.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/transcribe", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("free slot: status = %d, want 200", rec.Code)
+	}
+
+	release, _ := l.acquire(context.Background(), false)
+	defer release()
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/transcribe", nil))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("busy: status = %d, want 429", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("busy: missing Retry-After")
+	}
+}
+
+func TestDecodeLimiter_Shed(t *testing.T) {
+	l := newDecodeLimiter(1, 4, 1)
+	l.observe(3 * time.Second)
+	h := l.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	release, _ := l.acquire(context.Background(), false)
+	defer release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go l.acquire(ctx, false) //nolint:errcheck
+	for l.queueDepth(false) != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/transcribe", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "6" {
+		t.Fatalf("status = %d, Retry-After %q; want 503, 6", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	var body busyResponse
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	// 2 waiting (1 + the new request) over 1 slot at 3s each.
+	if body.QueueDepth != 1 || body.EstimatedWaitS != 6 || body.Error == "" {
+		t.Errorf("body = %+v, want depth 1 and a 6s wait", body)
+	}
+}
+
+func TestDecodeLimiter_Lanes(t *testing.T) {
+	l := newDecodeLimiter(1, 1, 0)
+	release, _ := l.acquire(context.Background(), false)
+
+	order := make(chan string, 2)
+	var wg sync.WaitGroup
+	wait := func(name string, low bool) {
+		wg.Go(func() {
+			rel, err := l.acquire(context.Background(), low)
+			if err != nil {
+				t.Errorf("%s: %v", name, err)
+				return
+			}
+			order <- name
+			rel()
+		})
+	}
+	wait("batch", true)
+	for l.queueDepth(true) != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	wait("interactive", false)
+	for l.queueDepth(false) != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	// Each lane holds MAX_QUEUED on its own.
+	if _, err := l.acquire(context.Background(), false); !errors.Is(err, errQueueFull) {
+		t.Errorf("normal lane full: err = %v, want errQueueFull", err)
+	}
+	release()
+	wg.Wait()
+	if first := <-order; first != "interactive" {
+		t.Errorf("first slot went to %s, want the interactive request", first)
+	}
+}
+
+func TestRequestPriority(t *testing.T) {
+	batch := &tenant{TenantConfig: TenantConfig{Name: "batch", Priority: moonshine.PriorityLow}}
+	tests := []struct {
+		query string
+		t     *tenant
+		want  string
+	}{
+		{"", nil, moonshine.PriorityNormal},
+		{"?priority=low", nil, moonshine.PriorityLow},
+		{"", batch, moonshine.PriorityLow},
+		{"?priority=normal", batch, moonshine.PriorityNormal},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/transcribe"+tt.query, nil)
+		r = r.WithContext(withTenant(r.Context(), tt.t))
+		if got := requestPriority(r); got != tt.want {
+			t.Errorf("requestPriority(%q, tenant %v) = %q, want %q", tt.query, tt.t != nil, got, tt.want)
+		}
+	}
+}
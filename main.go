@@ -2,219 +2,222 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log"
+	"maps"
 	"net/http"
 	"os"
 	"os/signal"
-	"path/filepath"
-	"strconv"
-	"sync"
+	"strings"
 	"syscall"
 	"time"
 
-	sherpa "github.com/k2-fsa/sherpa-onnx-go/sherpa_onnx"
+	"github.com/anatolykoptev/moonshine-whisper/pkg/moonshine"
 )
 
 // injected via -ldflags at build time
 var (
 	version   = "dev"
 	commit    = "unknown"
-	buildDate = "unknown" //nolint:unused
+	buildDate = "unknown"
 )
 
-var (
-	recognizerEN *sherpa.OfflineRecognizer
-	recognizerRU *sherpa.OfflineRecognizer
-	muEN         sync.Mutex
-	muRU         sync.Mutex
+func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "transcribe":
+			os.Exit(runCLI(os.Args[2:]))
+		case "bench":
+			os.Exit(runBench(os.Args[2:]))
+		}
+	}
 
-	vadDetector *sherpa.VoiceActivityDetector
-	muVAD       sync.Mutex
-)
+	configPath := flag.String("config", os.Getenv("MOONSHINE_CONFIG"), "path to YAML config file (env vars override it)")
+	flag.Parse()
 
-// appConfig holds all service configuration loaded from environment variables.
-type appConfig struct {
-	Port              string
-	ModelsDir         string
-	RUModelsDir       string
-	VADModel          string
-	PunctModel        string
-	PunctVocab        string
-	NumThreads        int
-	VADMinDurationS   float64
-	MaxAudioDurationS float64
-}
+	closeLog := setup(*configPath)
+	defer closeLog()
+	freeModels := loadModels()
+	defer freeModels()
+	freeTenants := loadTenants()
+	defer freeTenants()
+
+	// Synchronous transcription shares a bounded pool of decode slots;
+	// async jobs are bounded by JOB_WORKERS instead.
+	limit := func(h http.HandlerFunc) http.Handler { return h }
+	if cfg.MaxConcurrent > 0 {
+		l := newDecodeLimiter(cfg.MaxConcurrent, cfg.MaxQueued, cfg.ShedQueueDepth)
+		limit = func(h http.HandlerFunc) http.Handler { return l.middleware(h) }
+	}
 
-var cfg appConfig
+	mux := http.NewServeMux()
+	mux.Handle("/transcribe", limit(handleTranscribe))
+	mux.Handle("/transcribe/upload", limit(handleUpload))
+	mux.Handle("/transcribe/pcm", limit(handlePCM))
+	mux.Handle("/transcribe/raw", limit(handleRawUpload))
+	mux.Handle("/probe", limit(handleProbe))
+	mux.Handle("/classify", limit(handleClassify))
+	mux.Handle("/identify-language", limit(handleIdentifyLanguage))
+	mux.Handle("/identify-speaker", limit(handleIdentifySpeaker))
+	mux.HandleFunc("/speakers", handleSpeakers)
+	mux.Handle("/speakers/{name}", limit(handleSpeaker))
+	mux.HandleFunc("/transcribe/stream", handleStream)
+	mux.HandleFunc("/health", handleHealth)
+	mux.HandleFunc("/version", handleVersion)
+	mux.Handle("/selftest", limit(handleSelftest))
+	mux.HandleFunc("/openapi.json", handleOpenAPI)
+	mux.HandleFunc("/jobs", handleJobCreate)
+	mux.HandleFunc("/jobs/{id}", handleJobGet)
+	mux.HandleFunc("/jobs/{id}/events", handleJobEvents)
 
-// loadConfig reads configuration from environment variables with sensible defaults.
-func loadConfig() appConfig {
-	threads := 4
-	if s := os.Getenv("MOONSHINE_THREADS"); s != "" {
-		if n, err := strconv.Atoi(s); err == nil && n > 0 {
-			threads = n
+	if cfg.TranscriptDB != "" {
+		var err error
+		if transcriptDB, err = openTranscriptStore(cfg.TranscriptDB); err != nil {
+			log.Fatalf("transcript store: %v", err)
 		}
+		mux.HandleFunc("/transcripts", handleTranscriptSearch)
+		mux.HandleFunc("/transcripts/{id}", handleTranscriptGet)
+		log.Printf("Storing transcripts in %s", cfg.TranscriptDB)
 	}
-	vadMin := 10.0
-	if s := os.Getenv("VAD_MIN_DURATION_S"); s != "" {
-		if f, err := strconv.ParseFloat(s, 64); err == nil && f >= 0 {
-			vadMin = f
+	if cfg.RetainAudio != "" {
+		var err error
+		if retainStore, err = openAudioStore(cfg.RetainAudio); err != nil {
+			log.Fatalf("audio retention: %v", err)
 		}
+		log.Printf("Retaining audio in %s", cfg.RetainAudio)
 	}
-	maxAudio := 300.0
-	if s := os.Getenv("MAX_AUDIO_DURATION_S"); s != "" {
-		if f, err := strconv.ParseFloat(s, 64); err == nil && f > 0 {
-			maxAudio = f
+	if cfg.AuditLog != "" {
+		var err error
+		if auditLog, err = openAuditLog(cfg.AuditLog, cfg.AuditLogText); err != nil {
+			log.Fatalf("audit log: %v", err)
 		}
+		defer auditLog.Close() //nolint:errcheck
+		log.Printf("Writing the audit log to %s", cfg.AuditLog)
 	}
-	return appConfig{
-		Port:              envOr("MOONSHINE_PORT", "8092"),
-		ModelsDir:         envOr("MOONSHINE_MODELS_DIR", "/models"),
-		RUModelsDir:       envOr("ZIPFORMER_RU_DIR", "/ru-models"),
-		VADModel:          envOr("SILERO_VAD_MODEL", "/vad/silero_vad.onnx"),
-		PunctModel:        envOr("PUNCT_MODEL", "/punct/model.int8.onnx"),
-		PunctVocab:        envOr("PUNCT_VOCAB", "/punct/bpe.vocab"),
-		NumThreads:        threads,
-		VADMinDurationS:   vadMin,
-		MaxAudioDurationS: maxAudio,
-	}
-}
-
-func main() {
-	cfg = loadConfig()
-
-	t0 := time.Now()
-	var wg sync.WaitGroup
-
-	cfgEN := &sherpa.OfflineRecognizerConfig{}
-	cfgEN.FeatConfig.SampleRate = 16000
-	cfgEN.FeatConfig.FeatureDim = 80
-	cfgEN.ModelConfig.Moonshine.Encoder = filepath.Join(cfg.ModelsDir, "encoder_model.ort")
-	cfgEN.ModelConfig.Moonshine.MergedDecoder = filepath.Join(cfg.ModelsDir, "decoder_model_merged.ort")
-	cfgEN.ModelConfig.Tokens = filepath.Join(cfg.ModelsDir, "tokens.txt")
-	cfgEN.ModelConfig.NumThreads = cfg.NumThreads
-	cfgEN.ModelConfig.Provider = "cpu"
-	cfgEN.DecodingMethod = "greedy_search"
-
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		t := time.Now()
-		recognizerEN = sherpa.NewOfflineRecognizer(cfgEN)
-		if recognizerEN == nil {
-			log.Fatalf("Failed to load EN model from %s", cfg.ModelsDir)
+	if cfg.SpeakerDB != "" {
+		var err error
+		if speakers, err = openSpeakerStore(cfg.SpeakerDB); err != nil {
+			log.Fatalf("speaker store: %v", err)
 		}
-		log.Printf("EN model loaded in %.2fs", time.Since(t).Seconds())
-	}()
-
-	ruEncoder := filepath.Join(cfg.RUModelsDir, "encoder.int8.onnx")
-	if _, err := os.Stat(ruEncoder); err == nil {
-		cfgRU := &sherpa.OfflineRecognizerConfig{}
-		cfgRU.FeatConfig.SampleRate = 16000
-		cfgRU.FeatConfig.FeatureDim = 80
-		cfgRU.ModelConfig.Transducer.Encoder = ruEncoder
-		cfgRU.ModelConfig.Transducer.Decoder = filepath.Join(cfg.RUModelsDir, "decoder.int8.onnx")
-		cfgRU.ModelConfig.Transducer.Joiner = filepath.Join(cfg.RUModelsDir, "joiner.int8.onnx")
-		cfgRU.ModelConfig.Tokens = filepath.Join(cfg.RUModelsDir, "tokens.txt")
-		cfgRU.ModelConfig.NumThreads = cfg.NumThreads
-		cfgRU.ModelConfig.Provider = "cpu"
-		cfgRU.DecodingMethod = "greedy_search"
-
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			t := time.Now()
-			recognizerRU = sherpa.NewOfflineRecognizer(cfgRU)
-			if recognizerRU != nil {
-				log.Printf("RU model loaded in %.2fs", time.Since(t).Seconds())
-			} else {
-				log.Printf("WARNING: failed to load RU model")
-			}
-		}()
-	} else {
-		log.Printf("RU model not found at %s, RU transcription unavailable", cfg.RUModelsDir)
+		log.Printf("Storing enrolled speakers in %s", cfg.SpeakerDB)
 	}
-
-	wg.Wait()
-	log.Printf("All models loaded in %.2fs", time.Since(t0).Seconds())
-	if recognizerEN != nil {
-		defer sherpa.DeleteOfflineRecognizer(recognizerEN)
+	if cfg.CacheSize > 0 {
+		transcripts = newTranscriptCache(cfg.CacheSize, cfg.CacheTTL)
 	}
-	if recognizerRU != nil {
-		defer sherpa.DeleteOfflineRecognizer(recognizerRU)
+	if err := startJobWorkers(cfg.JobWorkers, cfg.JobQueueSize); err != nil {
+		log.Fatalf("job store: %v", err)
 	}
 
-	if _, err := os.Stat(cfg.VADModel); err == nil {
-		vadCfg := &sherpa.VadModelConfig{
-			SileroVad: sherpa.SileroVadModelConfig{
-				Model:              cfg.VADModel,
-				Threshold:          0.5,
-				MinSilenceDuration: 0.5,
-				MinSpeechDuration:  0.25,
-				WindowSize:         512,
-			},
-			SampleRate: 16000,
-			NumThreads: 1,
-			Provider:   "cpu",
-		}
-		vadDetector = sherpa.NewVoiceActivityDetector(vadCfg, float32(cfg.MaxAudioDurationS))
-		if vadDetector != nil {
-			defer sherpa.DeleteVoiceActivityDetector(vadDetector)
-			log.Printf("Silero VAD loaded (min_duration=%.0fs)", cfg.VADMinDurationS)
-		}
-	} else {
-		log.Printf("Silero VAD not found at %s (set SILERO_VAD_MODEL to enable)", cfg.VADModel)
+	var handler http.Handler = statsMiddleware(tenantMiddleware(mux))
+	if cfg.CompressResponses {
+		handler = compressMiddleware(handler)
 	}
-
-	if _, errM := os.Stat(cfg.PunctModel); errM == nil {
-		if _, errV := os.Stat(cfg.PunctVocab); errV == nil {
-			initPunctuation(cfg.PunctModel, cfg.PunctVocab)
-		} else {
-			log.Printf("Punctuation vocab not found at %s (set PUNCT_VOCAB)", cfg.PunctVocab)
-		}
-	} else {
-		log.Printf("Punctuation model not found at %s (set PUNCT_MODEL to enable)", cfg.PunctModel)
+	if len(cfg.CORSAllowedOrigins) > 0 {
+		handler = corsMiddleware(handler, cfg.CORSAllowedOrigins, cfg.CORSAllowedMethods, cfg.CORSAllowedHeaders)
 	}
-
-	warmup()
-
-	mux := http.NewServeMux()
-	mux.HandleFunc("/transcribe", handleTranscribe)
-	mux.HandleFunc("/transcribe/upload", handleUpload)
-	mux.HandleFunc("/health", handleHealth)
-
+	if cfg.LogRequests {
+		handler = loggingMiddleware(handler)
+	}
+	handler = requestIDMiddleware(handler)
 	srv := &http.Server{
 		Addr:         ":" + cfg.Port,
-		Handler:      loggingMiddleware(mux),
-		ReadTimeout:  35 * time.Second,
-		WriteTimeout: 35 * time.Second,
+		Handler:      handler,
+		ReadTimeout:  serverTimeout,
+		WriteTimeout: serverTimeout,
 		IdleTimeout:  60 * time.Second,
 	}
+	scheme := "http"
+	if cfg.TLSCert != "" {
+		certs, err := newCertReloader(cfg.TLSCert, cfg.TLSKey, cfg.TLSReloadInterval)
+		if err != nil {
+			log.Fatalf("tls: %v", err)
+		}
+		srv.TLSConfig = certs.tlsConfig()
+		scheme = "https"
+	}
+
+	var adminSrv *http.Server
+	if cfg.AdminAddr != "" {
+		adminSrv = startAdminServer(cfg.AdminAddr)
+	}
 
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
-	if punctuator != nil {
-		defer sherpa.DeleteOnlinePunctuation(punctuator)
+	if err := os.MkdirAll(cfg.TempDir, 0o755); err != nil {
+		log.Fatalf("temp dir: %v", err)
+	}
+	go runTempJanitor(ctx)
+
+	if cfg.WatchDir != "" {
+		w, err := newFolderWatcher(cfg.WatchDir, cfg.WatchFormats, moonshine.Options{Lang: normLang(cfg.WatchLanguage), WaitForLoad: true})
+		if err != nil {
+			log.Fatalf("watch: %v", err)
+		}
+		go w.run(ctx, cfg.WatchInterval)
+		log.Printf("Watching %s every %s (%s)", cfg.WatchDir, cfg.WatchInterval, strings.Join(cfg.WatchFormats, ", "))
+	}
+
+	if err := startSchedules(ctx, cfg.Schedules); err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	if cfg.RTPAddr != "" {
+		opts := moonshine.Options{Lang: normLang(cfg.RTPLanguage)}
+		if !engine.HasStreaming(opts.Lang) {
+			log.Fatalf("rtp: %s streaming model not loaded", strings.ToUpper(opts.Lang))
+		}
+		l, err := newRTPListener(cfg.RTPAddr, opts)
+		if err != nil {
+			log.Fatalf("rtp: %v", err)
+		}
+		go l.run(ctx)
+		log.Printf("Listening for RTP on %s (%s)", cfg.RTPAddr, opts.Lang)
+	}
+
+	if len(cfg.KafkaBrokers) > 0 {
+		startKafkaWorker(ctx)
+		log.Printf("Consuming Kafka topic %s (results to %s)", cfg.KafkaRequestTopic, cfg.KafkaResultTopic)
 	}
 
-	ruStatus := "unavailable"
-	if recognizerRU != nil {
-		ruStatus = "ready"
+	if cfg.NATSURL != "" {
+		if err := startNATS(ctx); err != nil {
+			log.Fatalf("nats: %v", err)
+		}
+		log.Printf("Serving NATS subject %q, stream %q", cfg.NATSSubject, cfg.NATSStream)
 	}
+
 	vadStatus := "disabled"
-	if vadDetector != nil {
+	if engine.HasVAD() {
 		vadStatus = "ready"
 	}
 	punctStatus := "disabled"
-	if punctuator != nil {
+	if engine.HasPunctuation() {
 		punctStatus = "ready"
 	}
-	log.Printf("Service on :%s | EN: ready | RU: %s | VAD: %s | Punct: %s",
-		cfg.Port, ruStatus, vadStatus, punctStatus)
+	diarizeStatus := "disabled"
+	if engine.HasDiarization() {
+		diarizeStatus = "ready"
+	}
+	taggingStatus := "disabled"
+	if engine.HasTagging() {
+		taggingStatus = "ready"
+	}
+	lidStatus := "disabled"
+	if engine.HasLID() {
+		lidStatus = "ready"
+	}
+	log.Printf("Service on %s://:%s | EN: %s | RU: %s | ZH: %s | VAD: %s | Punct: %s | Diarize: %s | Tagging: %s | LID: %s",
+		scheme, cfg.Port, languageStatus("en"), languageStatus("ru"), languageStatus("zh"), vadStatus, punctStatus, diarizeStatus, taggingStatus, lidStatus)
 
 	go func() {
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if srv.TLSConfig != nil {
+			err = srv.ListenAndServeTLS("", "") // certificates come from TLSConfig
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Fatalf("listen: %v", err)
 		}
 	}()
@@ -226,35 +229,141 @@ func main() {
 	if err := srv.Shutdown(shutCtx); err != nil {
 		log.Printf("shutdown error: %v", err)
 	}
+	if adminSrv != nil {
+		adminSrv.Close() //nolint:errcheck
+	}
 	log.Println("Shutdown complete")
 }
 
-// warmup runs dummy inference on all loaded models to eliminate first-request latency.
-func warmup() {
-	samples := make([]float32, 16000)
+// setup loads the configuration into cfg and redirects logging to
+// LOG_FILE when set. The returned func closes the log file.
+func setup(configPath string) func() {
+	var err error
+	cfg, err = loadConfig(configPath)
+	if err != nil {
+		log.Fatalf("config: %v", err)
+	}
+	closeLog := func() {}
+	if cfg.LogFile != "" {
+		f, err := os.OpenFile(cfg.LogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			log.Fatalf("open log file: %v", err)
+		}
+		log.SetOutput(f)
+		closeLog = func() { f.Close() } //nolint:errcheck
+	}
+	if configPath != "" {
+		log.Printf("Config loaded from %s", configPath)
+	}
+	return closeLog
+}
 
-	muEN.Lock()
-	ws := sherpa.NewOfflineStream(recognizerEN)
-	ws.AcceptWaveform(16000, samples)
-	recognizerEN.Decode(ws)
-	sherpa.DeleteOfflineStream(ws)
-	muEN.Unlock()
+// loadModels builds the engine from cfg and loads the hallucination
+// blocklist, then warms up. The returned func frees the models.
+func loadModels() func() {
+	var err error
+	engine, err = moonshine.New(engineConfig())
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	models.dirs["en"], models.dirs["ru"], models.dirs["zh"] = cfg.ModelsDir, cfg.RUModelsDir, cfg.ZHModelsDir
+	maps.Copy(models.dirs, cfg.ModelDirs)
+	if p := engine.EffectiveProvider(); p != engine.Provider() {
+		log.Printf("WARNING: ONNX Runtime lacks the %s execution provider; models run on the %s", engine.Provider(), p)
+	}
 
-	if recognizerRU != nil {
-		muRU.Lock()
-		ws2 := sherpa.NewOfflineStream(recognizerRU)
-		ws2.AcceptWaveform(16000, samples)
-		recognizerRU.Decode(ws2)
-		sherpa.DeleteOfflineStream(ws2)
-		muRU.Unlock()
+	if cfg.HallucinationBlocklist != "" {
+		n, err := engine.LoadBlocklist(cfg.HallucinationBlocklist)
+		if err != nil {
+			log.Fatalf("hallucination blocklist: %v", err)
+		}
+		log.Printf("Hallucination blocklist: %d phrase(s) from %s", n, cfg.HallucinationBlocklist)
 	}
+
+	engine.Warmup()
 	log.Println("Warmup complete")
+	return engine.Close
+}
+
+// languageStatus describes the model of lang for the startup log.
+func languageStatus(lang string) string {
+	switch {
+	case engine.HasLanguage(lang):
+		return "ready"
+	case cfg.LazyLoad && models.dirs[lang] != "":
+		return "on first use"
+	}
+	return "unavailable"
 }
 
-// envOr returns the value of the environment variable key, or def if unset.
-func envOr(key, def string) string {
-	if v := os.Getenv(key); v != "" {
-		return v
+// engineConfig maps cfg onto the library configuration.
+func engineConfig() moonshine.Config {
+	return moonshine.Config{
+		ModelsDir:                cfg.ModelsDir,
+		RUModelsDir:              cfg.RUModelsDir,
+		ZHModelsDir:              cfg.ZHModelsDir,
+		ModelDirs:                cfg.ModelDirs,
+		Backends:                 cfg.ModelBackends,
+		StreamingModels:          cfg.StreamingModels,
+		TelephonyModels:          cfg.TelephonyModels,
+		AccurateModelsDir:        cfg.AccurateModelsDir,
+		TranslateModelDir:        cfg.TranslateModelDir,
+		ModelPrecision:           cfg.ModelPrecision,
+		NumThreads:               cfg.NumThreads,
+		ModelThreads:             cfg.ModelThreads,
+		PoolSize:                 cfg.PoolSize,
+		PoolSizes:                cfg.PoolSizes,
+		LazyLoad:                 cfg.LazyLoad,
+		Provider:                 cfg.Provider,
+		RUDecodingMethod:         cfg.RUDecodingMethod,
+		RUBeamSize:               cfg.RUBeamSize,
+		HotwordsFile:             cfg.HotwordsFile,
+		HotwordsScore:            cfg.HotwordsScore,
+		LMs:                      engineLMs(),
+		VADModel:                 cfg.VADModel,
+		VADBackend:               cfg.VADBackend,
+		VADThreshold:             cfg.VADThreshold,
+		VADMinSilenceS:           cfg.VADMinSilenceS,
+		VADMinSpeechS:            cfg.VADMinSpeechS,
+		VADMinDurationS:          cfg.VADMinDurationS,
+		ChunkOverlapS:            cfg.ChunkOverlapS,
+		PunctModel:               cfg.PunctModel,
+		PunctVocab:               cfg.PunctVocab,
+		DiarizeSegmentationModel: cfg.DiarizeSegmentationModel,
+		DiarizeEmbeddingModel:    cfg.DiarizeEmbeddingModel,
+		DiarizeThreshold:         cfg.DiarizeThreshold,
+		DiarizeMaxSpeakers:       cfg.DiarizeMaxSpeakers,
+		TaggingModel:             cfg.TaggingModel,
+		TaggingModelType:         cfg.TaggingModelType,
+		TaggingLabels:            cfg.TaggingLabels,
+		LIDEncoder:               cfg.LIDEncoder,
+		LIDDecoder:               cfg.LIDDecoder,
+		SpeakerModel:             cfg.SpeakerModel,
+		EmotionModelDir:          cfg.EmotionModelDir,
+		DenoiseModel:             cfg.DenoiseModel,
+		MaxAudioDurationS:        cfg.MaxAudioDurationS,
+		NativeDecode:             cfg.NativeDecode,
+		FFmpegPath:               cfg.FFmpegPath,
+		FFprobePath:              cfg.FFprobePath,
+		ProbeInput:               cfg.ProbeInput,
+		FFmpegArgs:               cfg.FFmpegArgs,
+		FFmpegTimeout:            cfg.FFmpegTimeout,
+		FFmpegMaxProcs:           cfg.FFmpegMaxProcs,
+		TempDir:                  cfg.TempDir,
+		TempMinFreeMB:            cfg.TempMinFreeMB,
+		HallucinationMaxRatio:    cfg.HallucinationMaxRatio,
+		HallucinationMaxRepeats:  cfg.HallucinationMaxRepeats,
+	}
+}
+
+// engineLMs pairs each language model of cfg with its scale.
+func engineLMs() map[string]moonshine.LM {
+	if len(cfg.LMModels) == 0 {
+		return nil
+	}
+	lms := make(map[string]moonshine.LM, len(cfg.LMModels))
+	for lang, path := range cfg.LMModels {
+		lms[lang] = moonshine.LM{Model: path, Scale: cfg.LMScales[lang]}
 	}
-	return def
+	return lms
 }
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Model reload states reported by GET /admin/models.
+const (
+	reloadLoading = "loading"
+	reloadDone    = "done"
+	reloadFailed  = "failed"
+)
+
+// modelReloadRequest is the JSON body for POST /admin/models/reload.
+type modelReloadRequest struct {
+	Language string `json:"language"`      // en or ru
+	Dir      string `json:"dir,omitempty"` // ""=currently loaded directory
+}
+
+// reloadStatus describes the latest model reload.
+type reloadStatus struct {
+	Language   string     `json:"language"`
+	Dir        string     `json:"dir"`
+	Status     string     `json:"status"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// models tracks the loaded model directories and the latest reload.
+var models = struct {
+	mu     sync.Mutex
+	dirs   map[string]string // language -> model directory
+	reload *reloadStatus
+}{dirs: make(map[string]string)}
+
+// handleModelReload handles POST /admin/models/reload: starts loading a
+// model directory in the background and returns 202 with the reload status.
+// Only one reload runs at a time; a concurrent request gets 409.
+func handleModelReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "POST only")
+		return
+	}
+	var req modelReloadRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	lang := normLang(req.Language)
+	if !cfg.knownLanguage(lang) {
+		writeError(w, http.StatusBadRequest, "language must be en, ru, zh, or a model_dirs language")
+		return
+	}
+
+	models.mu.Lock()
+	if models.reload != nil && models.reload.Status == reloadLoading {
+		models.mu.Unlock()
+		writeError(w, http.StatusConflict, "a model reload is already in progress")
+		return
+	}
+	dir := req.Dir
+	if dir == "" {
+		dir = models.dirs[lang]
+	}
+	st := &reloadStatus{Language: lang, Dir: dir, Status: reloadLoading, StartedAt: time.Now()}
+	models.reload = st
+	snap := *st
+	models.mu.Unlock()
+
+	go func() {
+		log.Printf("Reloading %s model from %s", lang, dir)
+		err := engine.ReloadModel(lang, dir)
+
+		models.mu.Lock()
+		defer models.mu.Unlock()
+		now := time.Now()
+		st.FinishedAt = &now
+		if err != nil {
+			st.Status, st.Error = reloadFailed, err.Error()
+			log.Printf("WARNING: %s model reload failed: %v", lang, err)
+			return
+		}
+		st.Status = reloadDone
+		models.dirs[lang] = dir
+		transcripts.purge() // cached results came from the old model
+		log.Printf("%s model reloaded from %s in %.2fs", lang, dir, now.Sub(st.StartedAt).Seconds())
+	}()
+	writeJSON(w, http.StatusAccepted, snap)
+}
+
+// handleModels handles GET /admin/models: loaded model directories and the
+// status of the latest reload.
+func handleModels(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "GET only")
+		return
+	}
+	models.mu.Lock()
+	defer models.mu.Unlock()
+	resp := map[string]any{"models": models.dirs}
+	if models.reload != nil {
+		resp["reload"] = *models.reload
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
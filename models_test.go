@@ -0,0 +1,97 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// --- handleModelReload ---
+
+func TestHandleModelReload_Validation(t *testing.T) {
+	tests := []struct {
+		method string
+		body   string
+		want   int
+	}{
+		{http.MethodGet, "", http.StatusMethodNotAllowed},
+		{http.MethodPost, "{", http.StatusBadRequest},
+		{http.MethodPost, `{"language":"de"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handleModelReload(rec, httptest.NewRequest(tt.method, "/admin/models/reload", strings.NewReader(tt.body)))
+		if rec.Code != tt.want {
+			t.Errorf("%s %q: status = %d, want %d", tt.method, tt.body, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestHandleModelReload_Failure(t *testing.T) {
+	old := cfg
+	cfg = defaultConfig()
+	t.Cleanup(func() {
+		cfg = old
+		models.mu.Lock()
+		models.reload = nil
+		models.mu.Unlock()
+	})
+
+	dir := t.TempDir()
+	rec := httptest.NewRecorder()
+	body := `{"language":"ru","dir":"` + dir + `"}`
+	handleModelReload(rec, httptest.NewRequest(http.MethodPost, "/admin/models/reload", strings.NewReader(body)))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202: %s", rec.Code, rec.Body)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		models.mu.Lock()
+		st := *models.reload
+		loaded := models.dirs["ru"]
+		models.mu.Unlock()
+		if st.Status != reloadLoading {
+			if st.Status != reloadFailed || st.Error == "" || st.FinishedAt == nil {
+				t.Errorf("reload status = %+v, want failed with error", st)
+			}
+			if loaded == dir {
+				t.Error("failed reload must not change the loaded directory")
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("reload did not finish")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHandleModelReload_Conflict(t *testing.T) {
+	models.mu.Lock()
+	models.reload = &reloadStatus{Language: "en", Status: reloadLoading, StartedAt: time.Now()}
+	models.mu.Unlock()
+	t.Cleanup(func() {
+		models.mu.Lock()
+		models.reload = nil
+		models.mu.Unlock()
+	})
+
+	rec := httptest.NewRecorder()
+	handleModelReload(rec, httptest.NewRequest(http.MethodPost, "/admin/models/reload", strings.NewReader(`{"language":"en"}`)))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("status = %d, want 409", rec.Code)
+	}
+}
+
+// --- handleModels ---
+
+func TestHandleModels(t *testing.T) {
+	rec := httptest.NewRecorder()
+	handleModels(rec, httptest.NewRequest(http.MethodGet, "/admin/models", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"models"`) {
+		t.Errorf("GET /admin/models = %d %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// natsProgressInterval is how often a JetStream request being transcribed
+// is marked in progress, so it is not redelivered before its ack.
+const natsProgressInterval = 10 * time.Second
+
+// natsMsg is the part of jetstream.Msg the work queue consumer uses.
+type natsMsg interface {
+	Data() []byte
+	Headers() nats.Header
+	Ack() error
+	InProgress() error
+}
+
+// startNATS connects to NATS_URL and serves transcription requests on
+// NATS_SUBJECT (request/reply) and from the JetStream stream NATS_STREAM
+// (work queue) until ctx is done.
+func startNATS(ctx context.Context) error {
+	nc, err := nats.Connect(cfg.NATSURL, nats.Name("moonshine-whisper"), nats.MaxReconnects(-1))
+	if err != nil {
+		return err
+	}
+	if cfg.NATSSubject != "" {
+		if _, err := nc.QueueSubscribe(cfg.NATSSubject, cfg.NATSQueueGroup, natsRequestHandler(ctx)); err != nil {
+			nc.Close()
+			return err
+		}
+	}
+	if cfg.NATSStream != "" {
+		js, err := jetstream.New(nc)
+		if err != nil {
+			nc.Close()
+			return err
+		}
+		cons, err := js.CreateOrUpdateConsumer(ctx, cfg.NATSStream, jetstream.ConsumerConfig{
+			Durable:   cfg.NATSQueueGroup,
+			AckPolicy: jetstream.AckExplicitPolicy,
+			AckWait:   3 * natsProgressInterval,
+		})
+		if err != nil {
+			nc.Close()
+			return err
+		}
+		next := func() (natsMsg, error) { return cons.Next(jetstream.FetchContext(ctx)) }
+		publish := func(data []byte) error {
+			_, err := js.Publish(ctx, cfg.NATSResultSubject, data)
+			return err
+		}
+		go runNATSConsumer(ctx, next, publish)
+	}
+	go func() {
+		<-ctx.Done()
+		nc.Drain() //nolint:errcheck
+	}()
+	return nil
+}
+
+// natsRequestHandler replies to each request with its finished job.
+// Requests are handled one at a time per subscription; more instances in
+// the queue group share the load.
+func natsRequestHandler(ctx context.Context) nats.MsgHandler {
+	return func(m *nats.Msg) {
+		body := natsResult(ctx, m.Data, m.Header.Get(nats.MsgIdHdr))
+		if m.Reply == "" {
+			log.Printf("WARNING: nats request on %s has no reply subject", m.Subject)
+			return
+		}
+		if err := m.Respond(body); err != nil {
+			log.Printf("WARNING: nats reply: %v", err)
+		}
+	}
+}
+
+// natsResult transcribes the QueueRequest in data and encodes the finished
+// job. id identifies the request when it has no ID.
+func natsResult(ctx context.Context, data []byte, id string) []byte {
+	j := processQueueMessage(ctx, data, id)
+	body, err := json.Marshal(j)
+	if err != nil {
+		log.Printf("WARNING: nats request %s: encode result: %v", j.ID, err)
+	}
+	return body
+}
+
+// runNATSConsumer transcribes work queue messages one at a time,
+// publishing each result before acknowledging the request, so a request
+// whose result was not published is redelivered.
+func runNATSConsumer(ctx context.Context, next func() (natsMsg, error), publish func([]byte) error) {
+	for {
+		m, err := next()
+		if ctx.Err() != nil {
+			return
+		}
+		if errors.Is(err, nats.ErrTimeout) {
+			continue
+		}
+		if err != nil {
+			log.Printf("WARNING: nats fetch: %v", err)
+			if !sleepCtx(ctx, time.Second) {
+				return
+			}
+			continue
+		}
+		body := processNATSMessage(ctx, m)
+		if ctx.Err() != nil {
+			return // interrupted by shutdown; redelivered after AckWait
+		}
+		if err := publish(body); err != nil {
+			log.Printf("WARNING: nats publish result: %v", err)
+			continue
+		}
+		if err := m.Ack(); err != nil {
+			log.Printf("WARNING: nats ack: %v", err)
+		}
+	}
+}
+
+// processNATSMessage transcribes m, marking it in progress until done.
+func processNATSMessage(ctx context.Context, m natsMsg) []byte {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		t := time.NewTicker(natsProgressInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+				m.InProgress() //nolint:errcheck
+			}
+		}
+	}()
+	return natsResult(ctx, m.Data(), m.Headers().Get(nats.MsgIdHdr))
+}
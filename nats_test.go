@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+)
+
+// fakeNATSMsg records acknowledgements.
+type fakeNATSMsg struct {
+	data  string
+	id    string
+	acked bool
+}
+
+func (m *fakeNATSMsg) Data() []byte         { return []byte(m.data) }
+func (m *fakeNATSMsg) Headers() nats.Header { return nats.Header{nats.MsgIdHdr: []string{m.id}} }
+func (m *fakeNATSMsg) Ack() error           { m.acked = true; return nil }
+func (m *fakeNATSMsg) InProgress() error    { return nil }
+
+// --- runNATSConsumer ---
+
+func TestRunNATSConsumer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	msgs := []*fakeNATSMsg{
+		{data: `{"id":"r1"}`, id: "m1"},
+		{data: `{}`, id: "m2"}, // publish fails: left unacked for redelivery
+		{data: `not json`, id: "m3"},
+	}
+	queue := []any{msgs[0], nats.ErrTimeout, errors.New("connection reset"), msgs[1], msgs[2]}
+	next := func() (natsMsg, error) {
+		if len(queue) == 0 {
+			cancel()
+			return nil, context.Canceled
+		}
+		item := queue[0]
+		queue = queue[1:]
+		if err, ok := item.(error); ok {
+			return nil, err
+		}
+		return item.(*fakeNATSMsg), nil
+	}
+	var mu sync.Mutex
+	var published []Job
+	publish := func(data []byte) error {
+		var j Job
+		if err := json.Unmarshal(data, &j); err != nil {
+			t.Fatalf("result: %v", err)
+		}
+		if j.ID == "m2" {
+			return errors.New("no responders")
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		published = append(published, j)
+		return nil
+	}
+	runNATSConsumer(ctx, next, publish)
+
+	if len(published) != 2 || published[0].ID != "r1" || published[1].ID != "m3" {
+		t.Fatalf("published %+v, want r1 and m3", published)
+	}
+	for _, j := range published {
+		if j.Status != jobFailed || j.Result == nil || j.Result.Error == "" {
+			t.Errorf("result %+v, want a failed job with an error", j)
+		}
+	}
+	for i, want := range []bool{true, false, true} {
+		if msgs[i].acked != want {
+			t.Errorf("message %s acked = %v, want %v", msgs[i].id, msgs[i].acked, want)
+		}
+	}
+}
@@ -0,0 +1,666 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Object-store input: audio_path may be s3://bucket/key or gs://bucket/object.
+// Credentials are resolved with the providers' standard chains using only
+// the standard library, so no cloud SDK is linked into the binary.
+
+// objectClient talks to S3, GCS, and their credential endpoints.
+var objectClient = &http.Client{}
+
+// isObjectURI reports whether p is an s3:// or gs:// URI.
+func isObjectURI(p string) bool {
+	return strings.HasPrefix(p, "s3://") || strings.HasPrefix(p, "gs://")
+}
+
+// parseObjectURI splits an s3:// or gs:// URI into scheme, bucket, and key.
+func parseObjectURI(uri string) (scheme, bucket, key string, err error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", "", "", err
+	}
+	key = strings.TrimPrefix(u.Path, "/")
+	if (u.Scheme != "s3" && u.Scheme != "gs") || u.Host == "" || key == "" {
+		return "", "", "", fmt.Errorf("invalid object URI %q (want s3://bucket/key or gs://bucket/object)", uri)
+	}
+	return u.Scheme, u.Host, key, nil
+}
+
+// fetchObject streams an S3 or GCS object into a temp file, enforcing the
+// same size and time limits as audio_url downloads.
+// Returns the temp path (caller removes it) or an HTTP status and error.
+func fetchObject(ctx context.Context, uri string) (string, int, error) {
+	scheme, bucket, key, err := parseObjectURI(uri)
+	if err != nil {
+		return "", http.StatusBadRequest, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, cfg.DownloadTimeout)
+	defer cancel()
+
+	var req *http.Request
+	switch scheme {
+	case "s3":
+		req, err = newS3Request(ctx, http.MethodGet, bucket, key, nil, nil)
+	default:
+		req, err = newGCSRequest(ctx, bucket, key)
+	}
+	if err != nil {
+		return "", http.StatusBadGateway, fmt.Errorf("%s: %w", scheme, err)
+	}
+	resp, err := objectClient.Do(req)
+	if err != nil {
+		return "", http.StatusBadGateway, fmt.Errorf("%s: %w", scheme, err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return "", http.StatusNotFound, fmt.Errorf("%s: object %s not found", scheme, uri)
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return "", http.StatusBadGateway, fmt.Errorf("%s: access denied to %s (%s)", scheme, uri, resp.Status)
+	default:
+		return "", http.StatusBadGateway, fmt.Errorf("%s: %s returned %s", scheme, uri, resp.Status)
+	}
+	maxBytes := int64(cfg.DownloadMaxMB) << 20
+	if resp.ContentLength > maxBytes {
+		return "", http.StatusRequestEntityTooLarge,
+			fmt.Errorf("%s: %d bytes exceeds limit of %d", scheme, resp.ContentLength, maxBytes)
+	}
+	return saveBody(resp.Body, downloadExt(key, resp.Header.Get("Content-Type")), maxBytes)
+}
+
+// --- S3 ---
+
+// awsCreds is one set of AWS credentials with an optional expiry.
+type awsCreds struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Expires         time.Time
+}
+
+var (
+	awsCredsMu    sync.Mutex
+	awsCredsCache awsCreds
+)
+
+// newS3Request builds a SigV4-signed request for an S3 object, or for the
+// bucket when key is ""; a body is sent unsigned. AWS_ENDPOINT_URL_S3 or
+// AWS_ENDPOINT_URL selects an S3-compatible endpoint (path-style).
+func newS3Request(ctx context.Context, method, bucket, key string, query url.Values, body io.Reader) (*http.Request, error) {
+	creds, err := awsCredentials(ctx)
+	if err != nil {
+		return nil, err
+	}
+	region := envFirst("AWS_REGION", "AWS_DEFAULT_REGION")
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	var rawURL string
+	if ep := envFirst("AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL"); ep != "" {
+		rawURL = strings.TrimRight(ep, "/") + "/" + bucket + "/" + s3Escape(key)
+	} else if strings.Contains(bucket, ".") {
+		rawURL = fmt.Sprintf("https://s3.%s.amazonaws.com/%s/%s", region, bucket, s3Escape(key))
+	} else {
+		rawURL = fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", bucket, region, s3Escape(key))
+	}
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, body)
+	if err != nil {
+		return nil, err
+	}
+	req.URL.RawQuery = query.Encode()
+	if body != nil {
+		req.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+	}
+	signV4(req, creds, region, "s3", time.Now())
+	return req, nil
+}
+
+// emptyPayloadHash is the SHA-256 of an empty request body.
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// unsignedPayload stands for the payload hash of a request whose body is
+// not signed.
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// signV4 signs req in place with AWS Signature Version 4. The host header and
+// every header already set on req are signed. The payload hash is that of an
+// empty body unless X-Amz-Content-Sha256 is set.
+func signV4(req *http.Request, creds awsCreds, region, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	payloadHash := req.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash == "" {
+		payloadHash = emptyPayloadHash
+	}
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonHeaders strings.Builder
+	for _, k := range names {
+		canonHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	sum := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(sum[:])
+
+	k := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	k = hmacSHA256(k, region)
+	k = hmacSHA256(k, service)
+	k = hmacSHA256(k, "aws4_request")
+	sig := hex.EncodeToString(hmacSHA256(k, toSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, signedHeaders, sig))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// canonicalQuery encodes query parameters sorted by key, as SigV4 requires.
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		vals := append([]string(nil), q[k]...)
+		sort.Strings(vals)
+		for _, v := range vals {
+			parts = append(parts, queryEscape(k)+"="+queryEscape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// s3Escape percent-encodes everything except unreserved characters and '/'.
+func s3Escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+// queryEscape is s3Escape that also encodes '/', for query components.
+func queryEscape(s string) string {
+	return strings.ReplaceAll(s3Escape(s), "/", "%2F")
+}
+
+// awsCredentials resolves credentials in the standard order: environment,
+// shared credentials file, web identity (EKS), ECS container endpoint, and
+// EC2 instance metadata. Expiring credentials are cached until near expiry.
+func awsCredentials(ctx context.Context) (awsCreds, error) {
+	if id, secret := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"); id != "" && secret != "" {
+		return awsCreds{AccessKeyID: id, SecretAccessKey: secret, SessionToken: os.Getenv("AWS_SESSION_TOKEN")}, nil
+	}
+	if c, ok := awsSharedCredentials(); ok {
+		return c, nil
+	}
+
+	awsCredsMu.Lock()
+	defer awsCredsMu.Unlock()
+	if awsCredsCache.AccessKeyID != "" && time.Until(awsCredsCache.Expires) > 5*time.Minute {
+		return awsCredsCache, nil
+	}
+	var (
+		c   awsCreds
+		err error
+	)
+	switch {
+	case os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE") != "" && os.Getenv("AWS_ROLE_ARN") != "":
+		c, err = awsWebIdentityCredentials(ctx)
+	case os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI") != "" || os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI") != "":
+		c, err = awsContainerCredentials(ctx)
+	default:
+		c, err = awsInstanceCredentials(ctx)
+	}
+	if err != nil {
+		return awsCreds{}, fmt.Errorf("no AWS credentials found: %w", err)
+	}
+	awsCredsCache = c
+	return c, nil
+}
+
+// awsSharedCredentials reads the profile (AWS_PROFILE, default "default")
+// from the shared credentials file.
+func awsSharedCredentials() (awsCreds, bool) {
+	file := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if file == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return awsCreds{}, false
+		}
+		file = filepath.Join(home, ".aws", "credentials")
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return awsCreds{}, false
+	}
+	profile := os.Getenv("AWS_PROFILE")
+	if profile == "" {
+		profile = "default"
+	}
+	var c awsCreds
+	section := ""
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		k, v, ok := strings.Cut(line, "=")
+		if !ok || section != profile {
+			continue
+		}
+		switch strings.TrimSpace(k) {
+		case "aws_access_key_id":
+			c.AccessKeyID = strings.TrimSpace(v)
+		case "aws_secret_access_key":
+			c.SecretAccessKey = strings.TrimSpace(v)
+		case "aws_session_token":
+			c.SessionToken = strings.TrimSpace(v)
+		}
+	}
+	return c, c.AccessKeyID != "" && c.SecretAccessKey != ""
+}
+
+// awsCredentialsJSON is the credential document served by ECS and EC2 IMDS.
+type awsCredentialsJSON struct {
+	AccessKeyID     string    `json:"AccessKeyId"`
+	SecretAccessKey string    `json:"SecretAccessKey"`
+	Token           string    `json:"Token"`
+	Expiration      time.Time `json:"Expiration"`
+}
+
+func (j awsCredentialsJSON) creds() awsCreds {
+	return awsCreds{AccessKeyID: j.AccessKeyID, SecretAccessKey: j.SecretAccessKey, SessionToken: j.Token, Expires: j.Expiration}
+}
+
+// awsContainerCredentials queries the ECS/EKS-pod-identity credential endpoint.
+func awsContainerCredentials(ctx context.Context) (awsCreds, error) {
+	endpoint := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI")
+	if rel := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); rel != "" {
+		endpoint = "http://169.254.170.2" + rel
+	}
+	headers := map[string]string{}
+	if tok := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN"); tok != "" {
+		headers["Authorization"] = tok
+	}
+	var j awsCredentialsJSON
+	if err := getJSON(ctx, endpoint, headers, &j); err != nil {
+		return awsCreds{}, fmt.Errorf("container credentials: %w", err)
+	}
+	return j.creds(), nil
+}
+
+// awsInstanceCredentials queries EC2 instance metadata (IMDSv2).
+func awsInstanceCredentials(ctx context.Context) (awsCreds, error) {
+	const base = "http://169.254.169.254/latest"
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, base+"/api/token", nil)
+	if err != nil {
+		return awsCreds{}, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "21600")
+	resp, err := objectClient.Do(req)
+	if err != nil {
+		return awsCreds{}, fmt.Errorf("instance metadata: %w", err)
+	}
+	token, _ := io.ReadAll(resp.Body)
+	resp.Body.Close() //nolint:errcheck
+	headers := map[string]string{"X-aws-ec2-metadata-token": string(token)}
+
+	role, err := getText(ctx, base+"/meta-data/iam/security-credentials/", headers)
+	if err != nil {
+		return awsCreds{}, fmt.Errorf("instance metadata: %w", err)
+	}
+	role, _, _ = strings.Cut(strings.TrimSpace(role), "\n")
+	var j awsCredentialsJSON
+	if err := getJSON(ctx, base+"/meta-data/iam/security-credentials/"+role, headers, &j); err != nil {
+		return awsCreds{}, fmt.Errorf("instance metadata: %w", err)
+	}
+	return j.creds(), nil
+}
+
+// awsWebIdentityCredentials exchanges the projected service-account token
+// for role credentials via STS AssumeRoleWithWebIdentity.
+func awsWebIdentityCredentials(ctx context.Context) (awsCreds, error) {
+	token, err := os.ReadFile(os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE"))
+	if err != nil {
+		return awsCreds{}, fmt.Errorf("web identity: %w", err)
+	}
+	session := os.Getenv("AWS_ROLE_SESSION_NAME")
+	if session == "" {
+		session = "moonshine-whisper"
+	}
+	q := url.Values{
+		"Action":           {"AssumeRoleWithWebIdentity"},
+		"Version":          {"2011-06-15"},
+		"RoleArn":          {os.Getenv("AWS_ROLE_ARN")},
+		"RoleSessionName":  {session},
+		"WebIdentityToken": {strings.TrimSpace(string(token))},
+	}
+	endpoint := "https://sts.amazonaws.com/"
+	if ep := os.Getenv("AWS_ENDPOINT_URL_STS"); ep != "" {
+		endpoint = ep
+	}
+	body, err := getText(ctx, endpoint+"?"+q.Encode(), nil)
+	if err != nil {
+		return awsCreds{}, fmt.Errorf("web identity: %w", err)
+	}
+	var out struct {
+		Creds struct {
+			AccessKeyID     string    `xml:"AccessKeyId"`
+			SecretAccessKey string    `xml:"SecretAccessKey"`
+			SessionToken    string    `xml:"SessionToken"`
+			Expiration      time.Time `xml:"Expiration"`
+		} `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+	}
+	if err := xml.Unmarshal([]byte(body), &out); err != nil {
+		return awsCreds{}, fmt.Errorf("web identity: %w", err)
+	}
+	return awsCreds{
+		AccessKeyID:     out.Creds.AccessKeyID,
+		SecretAccessKey: out.Creds.SecretAccessKey,
+		SessionToken:    out.Creds.SessionToken,
+		Expires:         out.Creds.Expiration,
+	}, nil
+}
+
+// --- GCS ---
+
+const gcsScope = "https://www.googleapis.com/auth/devstorage.read_only"
+
+var (
+	gcsTokenMu      sync.Mutex
+	gcsToken        string
+	gcsTokenExpires time.Time
+)
+
+// newGCSRequest builds an authenticated media GET for a GCS object.
+// STORAGE_EMULATOR_HOST points at an unauthenticated emulator.
+func newGCSRequest(ctx context.Context, bucket, object string) (*http.Request, error) {
+	base := "https://storage.googleapis.com"
+	emulator := os.Getenv("STORAGE_EMULATOR_HOST")
+	if emulator != "" {
+		base = strings.TrimRight(emulator, "/")
+		if !strings.Contains(base, "://") {
+			base = "http://" + base
+		}
+	}
+	rawURL := fmt.Sprintf("%s/storage/v1/b/%s/o/%s?alt=media", base, url.PathEscape(bucket), url.PathEscape(object))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if emulator == "" {
+		token, err := gcsAccessToken(ctx)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req, nil
+}
+
+// gcsCredentialsFile is the subset of an Application Default Credentials file we use.
+type gcsCredentialsFile struct {
+	Type         string `json:"type"`
+	ClientEmail  string `json:"client_email"`
+	PrivateKey   string `json:"private_key"`
+	TokenURI     string `json:"token_uri"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// gcsAccessToken resolves an OAuth token via Application Default Credentials:
+// GOOGLE_APPLICATION_CREDENTIALS, the gcloud ADC file, then the GCE metadata
+// server. Tokens are cached until near expiry.
+func gcsAccessToken(ctx context.Context) (string, error) {
+	gcsTokenMu.Lock()
+	defer gcsTokenMu.Unlock()
+	if gcsToken != "" && time.Until(gcsTokenExpires) > 5*time.Minute {
+		return gcsToken, nil
+	}
+
+	file := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	if file == "" {
+		if dir, err := os.UserConfigDir(); err == nil {
+			adc := filepath.Join(dir, "gcloud", "application_default_credentials.json")
+			if _, err := os.Stat(adc); err == nil {
+				file = adc
+			}
+		}
+	}
+
+	var (
+		tok tokenResponse
+		err error
+	)
+	if file != "" {
+		tok, err = gcsTokenFromFile(ctx, file)
+	} else {
+		err = getJSON(ctx, "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token",
+			map[string]string{"Metadata-Flavor": "Google"}, &tok)
+		if err != nil {
+			err = fmt.Errorf("metadata server: %w", err)
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("no GCS credentials found: %w", err)
+	}
+	if tok.AccessToken == "" {
+		return "", errors.New("no GCS credentials found: empty access token")
+	}
+	gcsToken = tok.AccessToken
+	gcsTokenExpires = time.Now().Add(time.Duration(tok.ExpiresIn) * time.Second)
+	return gcsToken, nil
+}
+
+// tokenResponse is an OAuth 2.0 token endpoint response.
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// gcsTokenFromFile exchanges service-account or authorized-user credentials
+// for an access token.
+func gcsTokenFromFile(ctx context.Context, file string) (tokenResponse, error) {
+	var tok tokenResponse
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return tok, err
+	}
+	var cred gcsCredentialsFile
+	if err := json.Unmarshal(data, &cred); err != nil {
+		return tok, fmt.Errorf("%s: %w", file, err)
+	}
+
+	var form url.Values
+	tokenURI := cred.TokenURI
+	if tokenURI == "" {
+		tokenURI = "https://oauth2.googleapis.com/token"
+	}
+	switch cred.Type {
+	case "service_account":
+		assertion, err := signServiceAccountJWT(cred, tokenURI, time.Now())
+		if err != nil {
+			return tok, fmt.Errorf("%s: %w", file, err)
+		}
+		form = url.Values{
+			"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+			"assertion":  {assertion},
+		}
+	case "authorized_user":
+		form = url.Values{
+			"grant_type":    {"refresh_token"},
+			"client_id":     {cred.ClientID},
+			"client_secret": {cred.ClientSecret},
+			"refresh_token": {cred.RefreshToken},
+		}
+	default:
+		return tok, fmt.Errorf("%s: unsupported credentials type %q", file, cred.Type)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return tok, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := objectClient.Do(req)
+	if err != nil {
+		return tok, fmt.Errorf("token exchange: %w", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	if resp.StatusCode != http.StatusOK {
+		return tok, fmt.Errorf("token exchange: %s", resp.Status)
+	}
+	return tok, json.NewDecoder(resp.Body).Decode(&tok)
+}
+
+// signServiceAccountJWT builds the RS256 JWT assertion for the OAuth
+// jwt-bearer grant.
+func signServiceAccountJWT(cred gcsCredentialsFile, aud string, now time.Time) (string, error) {
+	block, _ := pem.Decode([]byte(cred.PrivateKey))
+	if block == nil {
+		return "", errors.New("private_key is not PEM")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		if parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			return "", fmt.Errorf("parse private_key: %w", err)
+		}
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return "", errors.New("private_key is not RSA")
+	}
+
+	enc := base64.RawURLEncoding
+	header := enc.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, _ := json.Marshal(map[string]any{
+		"iss":   cred.ClientEmail,
+		"scope": gcsScope,
+		"aud":   aud,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	signing := header + "." + enc.EncodeToString(claims)
+	sum := sha256.Sum256([]byte(signing))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", err
+	}
+	return signing + "." + enc.EncodeToString(sig), nil
+}
+
+// --- helpers ---
+
+// getText performs a GET with headers and returns the body as a string.
+func getText(ctx context.Context, rawURL string, headers map[string]string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return "", err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := objectClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s returned %s", path.Base(req.URL.Path), resp.Status)
+	}
+	return string(body), nil
+}
+
+// getJSON performs a GET with headers and decodes the JSON body into v.
+func getJSON(ctx context.Context, rawURL string, headers map[string]string, v any) error {
+	body, err := getText(ctx, rawURL, headers)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(body), v)
+}
+
+// envFirst returns the first non-empty environment variable among keys.
+func envFirst(keys ...string) string {
+	for _, k := range keys {
+		if v := os.Getenv(k); v != "" {
+			return v
+		}
+	}
+	return ""
+}
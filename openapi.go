@@ -0,0 +1,37 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"sync"
+)
+
+// openAPISpec describes the public HTTP API. Keep it in step with the
+// handlers and their request and response types; TestOpenAPISchemas checks
+// the schemas against the types.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// openAPIDocument returns the spec with info.version set to the build
+// version.
+var openAPIDocument = sync.OnceValue(func() []byte {
+	var doc map[string]any
+	if err := json.Unmarshal(openAPISpec, &doc); err != nil {
+		panic("openapi.json: " + err.Error())
+	}
+	doc["info"].(map[string]any)["version"] = version
+	data, _ := json.Marshal(doc)
+	return data
+})
+
+// handleOpenAPI handles GET /openapi.json.
+func handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "GET only")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPIDocument()) //nolint:errcheck
+}
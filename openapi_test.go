@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/anatolykoptev/moonshine-whisper/pkg/moonshine"
+)
+
+// openAPISchemas returns the property names of each schema in the spec,
+// merging allOf parts.
+func openAPISchemas(t *testing.T) map[string][]string {
+	t.Helper()
+	var doc struct {
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]any
+				AllOf      []struct {
+					Ref        string `json:"$ref"`
+					Properties map[string]any
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal(openAPISpec, &doc); err != nil {
+		t.Fatalf("openapi.json: %v", err)
+	}
+	props := make(map[string][]string)
+	for name, s := range doc.Components.Schemas {
+		props[name] = slices.Collect(maps.Keys(s.Properties))
+	}
+	for name, s := range doc.Components.Schemas {
+		for _, part := range s.AllOf {
+			props[name] = append(props[name], slices.Collect(maps.Keys(part.Properties))...)
+			if part.Ref != "" {
+				props[name] = append(props[name], props[strings.TrimPrefix(part.Ref, "#/components/schemas/")]...)
+			}
+		}
+		slices.Sort(props[name])
+	}
+	return props
+}
+
+// jsonFields returns the JSON names of the exported fields of struct type
+// typ, including those of embedded structs.
+func jsonFields(typ reflect.Type) []string {
+	var names []string
+	for _, f := range reflect.VisibleFields(typ) {
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// --- openapi.json ---
+
+func TestOpenAPISchemas(t *testing.T) {
+	schemas := openAPISchemas(t)
+	types := map[string]any{
+		"TranscribeRequest":        TranscribeRequest{},
+		"TranscribeResponse":       TranscribeResponse{},
+		"Segment":                  moonshine.Segment{},
+		"Span":                     moonshine.Span{},
+		"StreamResult":             moonshine.StreamResult{},
+		"AudioInfo":                moonshine.AudioInfo{},
+		"AudioTag":                 moonshine.AudioTag{},
+		"ClassifyResponse":         ClassifyResponse{},
+		"LanguageScore":            moonshine.LanguageScore{},
+		"IdentifyLanguageResponse": IdentifyLanguageResponse{},
+		"Speaker":                  Speaker{},
+		"SpeakerMatch":             SpeakerMatch{},
+		"IdentifySpeakerResponse":  IdentifySpeakerResponse{},
+		"JobRequest":               JobRequest{},
+		"JobProgress":              JobProgress{},
+		"Job":                      Job{},
+		"Transcript":               Transcript{},
+		"ModelInfo":                moonshine.ModelInfo{},
+		"VersionInfo":              versionInfo{},
+		"SelftestResponse":         SelftestResponse{},
+		"SelftestLanguage":         SelftestLanguage{},
+	}
+	for name, v := range types {
+		if got, want := schemas[name], jsonFields(reflect.TypeOf(v)); !slices.Equal(got, want) {
+			t.Errorf("schema %s has properties %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestOpenAPIPaths(t *testing.T) {
+	var doc struct {
+		Paths map[string]map[string]any
+	}
+	if err := json.Unmarshal(openAPISpec, &doc); err != nil {
+		t.Fatalf("openapi.json: %v", err)
+	}
+	routes := map[string]string{
+		"/health":            "get",
+		"/version":           "get",
+		"/selftest":          "get",
+		"/openapi.json":      "get",
+		"/transcribe":        "post",
+		"/transcribe/upload": "post",
+		"/transcribe/pcm":    "post",
+		"/transcribe/raw":    "put",
+		"/transcribe/stream": "post",
+		"/probe":             "post",
+		"/classify":          "post",
+		"/identify-language": "post",
+		"/identify-speaker":  "post",
+		"/speakers":          "get",
+		"/speakers/{name}":   "post",
+		"/jobs":              "post",
+		"/jobs/{id}":         "get",
+		"/jobs/{id}/events":  "get",
+		"/transcripts":       "get",
+		"/transcripts/{id}":  "get",
+	}
+	for path, method := range routes {
+		if _, ok := doc.Paths[path][method]; !ok {
+			t.Errorf("%s %s not documented", strings.ToUpper(method), path)
+		}
+	}
+	if len(doc.Paths) != len(routes) {
+		t.Errorf("spec has %d paths, want %d", len(doc.Paths), len(routes))
+	}
+}
+
+func TestHandleOpenAPI(t *testing.T) {
+	rec := httptest.NewRecorder()
+	handleOpenAPI(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("status %d, content type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	var doc struct {
+		OpenAPI string
+		Info    struct{ Version string }
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&doc); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") || doc.Info.Version != version {
+		t.Errorf("openapi %q version %q, want 3.x and %q", doc.OpenAPI, doc.Info.Version, version)
+	}
+
+	rec = httptest.NewRecorder()
+	handleOpenAPI(rec, httptest.NewRequest(http.MethodPost, "/openapi.json", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST = %d, want 405", rec.Code)
+	}
+}
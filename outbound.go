@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// errPrivateAddress marks a connection refused by guardedTransport.
+var errPrivateAddress = errors.New("private, loopback, or link-local address not allowed")
+
+// sharedAddressSpace is the carrier-grade NAT range (RFC 6598), private in
+// practice but not to netip.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// publicAddress reports whether ip is on the public internet: not
+// loopback, private (RFC 1918, RFC 4193, RFC 6598), link-local (which holds
+// cloud metadata at 169.254.169.254), multicast, or unspecified.
+func publicAddress(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsValid() && !ip.IsLoopback() && !ip.IsPrivate() && !sharedAddressSpace.Contains(ip) &&
+		!ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() && !ip.IsInterfaceLocalMulticast() &&
+		!ip.IsMulticast() && !ip.IsUnspecified()
+}
+
+// checkPublicURL resolves the host of rawURL and returns an error wrapping
+// errPrivateAddress if any of its addresses is not public, so a URL can be
+// refused when it is submitted rather than when it is first dialed.
+func checkPublicURL(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	host := u.Hostname()
+	addrs := []netip.Addr{}
+	if ip, err := netip.ParseAddr(host); err == nil {
+		addrs = append(addrs, ip)
+	} else if addrs, err = net.DefaultResolver.LookupNetIP(ctx, "ip", host); err != nil {
+		return err
+	}
+	for _, ip := range addrs {
+		if !publicAddress(ip) {
+			return fmt.Errorf("%s resolves to %s: %w", host, ip, errPrivateAddress)
+		}
+	}
+	return nil
+}
+
+// guardedTransport returns a transport for URLs that API callers supply,
+// which refuses to connect to non-public addresses unless allowPrivate
+// reports true. The check runs on the resolved address of every connection,
+// so redirects and DNS names that point inside the network are caught too.
+func guardedTransport(allowPrivate func() bool) *http.Transport {
+	d := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			if allowPrivate() {
+				return nil
+			}
+			ap, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !publicAddress(ap.Addr()) {
+				return fmt.Errorf("%s: %w", ap.Addr(), errPrivateAddress)
+			}
+			return nil
+		},
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.DialContext = d.DialContext
+	return t
+}
@@ -0,0 +1,19 @@
+package sherpa_onnx
+
+// #include <stdlib.h>
+// #include "c-api.h"
+import "C"
+import "unsafe"
+
+// NewOfflineStreamWithHotwords creates an offline stream biased toward the
+// given hotwords. Hotwords are separated by "/" and may end with " :score".
+// They only take effect for transducer models using modified_beam_search.
+// The caller must call DeleteOfflineStream() to free the stream.
+func NewOfflineStreamWithHotwords(recognizer *OfflineRecognizer, hotwords string) *OfflineStream {
+	cHotwords := C.CString(hotwords)
+	defer C.free(unsafe.Pointer(cHotwords))
+
+	stream := &OfflineStream{}
+	stream.impl = C.SherpaOnnxCreateOfflineStreamWithHotwords(recognizer.impl, cHotwords)
+	return stream
+}
@@ -0,0 +1,5 @@
+package sherpa_onnx
+
+import sherpa "github.com/k2-fsa/sherpa-onnx-go-linux"
+
+var NewOfflineStreamWithHotwords = sherpa.NewOfflineStreamWithHotwords
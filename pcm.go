@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anatolykoptev/moonshine-whisper/pkg/moonshine"
+)
+
+// errPCMTooLong is returned when a PCM stream exceeds the frame limit.
+var errPCMTooLong = errors.New("audio exceeds max duration")
+
+// pcmFormat describes a raw audio stream: 16-bit linear PCM, or 8-bit
+// G.711 μ-law or A-law.
+type pcmFormat struct {
+	SampleRate int
+	Channels   int
+	BigEndian  bool
+	Encoding   string // pcmL16, pcmULaw, or pcmALaw
+}
+
+// Raw audio encodings, named after their media subtypes (RFC 3551).
+const (
+	pcmL16  = "l16"
+	pcmULaw = "pcmu"
+	pcmALaw = "pcma"
+)
+
+// parsePCMContentType parses an audio/l16 (RFC 2586), audio/pcmu, or
+// audio/pcma media type; audio/basic is 8 kHz μ-law. rate defaults to 16000
+// for L16 and 8000 for G.711, and channels to 1. L16 samples are big-endian
+// (network order) unless endianness=little-endian is given.
+func parsePCMContentType(ct string) (pcmFormat, error) {
+	f := pcmFormat{SampleRate: 16000, Channels: 1, BigEndian: true, Encoding: pcmL16}
+	mt, params, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return f, fmt.Errorf("invalid Content-Type %q", ct)
+	}
+	switch mt {
+	case "audio/l16":
+	case "audio/pcmu", "audio/basic":
+		f.SampleRate, f.Encoding = 8000, pcmULaw
+	case "audio/pcma":
+		f.SampleRate, f.Encoding = 8000, pcmALaw
+	default:
+		return f, fmt.Errorf("unsupported Content-Type %q (want audio/l16, audio/pcmu, or audio/pcma)", mt)
+	}
+	if v, ok := params["rate"]; ok {
+		if f.SampleRate, err = strconv.Atoi(v); err != nil || f.SampleRate < moonshine.MinSampleRate || f.SampleRate > moonshine.MaxSampleRate {
+			return f, fmt.Errorf("invalid rate %q (want %d-%d)", v, moonshine.MinSampleRate, moonshine.MaxSampleRate)
+		}
+	}
+	if v, ok := params["channels"]; ok {
+		if f.Channels, err = strconv.Atoi(v); err != nil || f.Channels < 1 || f.Channels > 2 {
+			return f, fmt.Errorf("invalid channels %q (want 1 or 2)", v)
+		}
+	}
+	switch strings.ToLower(params["endianness"]) {
+	case "", "big-endian":
+	case "little-endian":
+		f.BigEndian = false
+	default:
+		return f, fmt.Errorf("invalid endianness %q", params["endianness"])
+	}
+	return f, nil
+}
+
+// decodePCMStream reads PCM frames from r as they arrive, downmixing stereo
+// to mono. It stops with errPCMTooLong once more than maxFrames
+// frames have been read; a trailing partial frame is ignored.
+func decodePCMStream(r io.Reader, f pcmFormat, maxFrames int) ([]float32, error) {
+	var samples []float32
+	err := readPCM(r, f, func(s []float32) error {
+		samples = append(samples, s...)
+		if len(samples) > maxFrames {
+			return errPCMTooLong
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return samples, nil
+}
+
+// readPCM reads PCM frames from r until EOF and passes the mono samples of
+// each read to fn as soon as they arrive. An error from fn stops reading and
+// is returned.
+func readPCM(r io.Reader, f pcmFormat, fn func([]float32) error) error {
+	width, _ := pcmSample(f)
+	frameBytes := width * f.Channels
+	buf := make([]byte, 32*1024-(32*1024)%frameBytes)
+	pending := 0
+	for {
+		n, err := r.Read(buf[pending:])
+		n += pending
+		whole := n - n%frameBytes
+		if whole > 0 {
+			if ferr := fn(decodePCMFrames(buf[:whole], f)); ferr != nil {
+				return ferr
+			}
+		}
+		pending = copy(buf, buf[whole:n])
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// decodePCMFrames decodes the whole frames in data to mono samples,
+// averaging the channels. A trailing partial frame is ignored.
+func decodePCMFrames(data []byte, f pcmFormat) []float32 {
+	width, sample := pcmSample(f)
+	frameBytes := width * f.Channels
+	samples := make([]float32, 0, len(data)/frameBytes)
+	for i := 0; i+frameBytes <= len(data); i += frameBytes {
+		var sum float32
+		for c := 0; c < f.Channels; c++ {
+			sum += sample(data[i+width*c:])
+		}
+		samples = append(samples, sum/float32(f.Channels))
+	}
+	return samples
+}
+
+// pcmSample returns the width in bytes of one sample of f and its decoder.
+func pcmSample(f pcmFormat) (int, func([]byte) float32) {
+	switch f.Encoding {
+	case pcmULaw:
+		return 1, func(b []byte) float32 { return moonshine.DecodeULaw(b[0]) }
+	case pcmALaw:
+		return 1, func(b []byte) float32 { return moonshine.DecodeALaw(b[0]) }
+	}
+	order := binary.ByteOrder(binary.LittleEndian)
+	if f.BigEndian {
+		order = binary.BigEndian
+	}
+	return 2, func(b []byte) float32 { return float32(int16(order.Uint16(b))) / 32768.0 }
+}
+
+// handlePCM handles POST /transcribe/pcm with a raw audio/l16, audio/pcmu,
+// or audio/pcma body.
+// Options (language, vad, punctuate, max_chunk_len, diarize, max_speakers,
+// hotwords) are query parameters.
+func handlePCM(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "POST only")
+		return
+	}
+	extendDeadlines(w)
+	start := time.Now()
+	format, ok := responseFormat(r, r.URL.Query().Get("format"))
+	if !ok {
+		writeError(w, http.StatusBadRequest, errFormat)
+		return
+	}
+	f, err := parsePCMContentType(r.Header.Get("Content-Type"))
+	if err != nil {
+		writeError(w, http.StatusUnsupportedMediaType, err.Error())
+		return
+	}
+
+	maxDuration := maxAudioDuration(r.Context())
+	maxFrames := int(maxDuration * float64(f.SampleRate))
+	samples, err := decodePCMStream(r.Body, f, maxFrames)
+	if errors.Is(err, errPCMTooLong) {
+		writeError(w, http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("audio too long: > max %.0fs", maxDuration))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "read body: "+err.Error())
+		return
+	}
+
+	req := requestFromValues(r.URL.Query().Get)
+	if msg := validateOptions(req); msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+	if msg := validateTenant(r.Context(), req); msg != "" {
+		writeError(w, http.StatusForbidden, msg)
+		return
+	}
+	if req.SplitChannels {
+		// PCM channels are downmixed as they are read.
+		writeError(w, http.StatusBadRequest, "split_channels is not supported for raw PCM; upload a WAV file")
+		return
+	}
+	if req.AudioTrack > 0 || req.Channel != "" {
+		writeError(w, http.StatusBadRequest, "audio_track and channel are not supported for raw PCM; upload a WAV file")
+		return
+	}
+	ctx, cancel := withRequestTimeout(r.Context())
+	defer cancel()
+	out := &transcriptWriter{w: w, format: format}
+	opts := requestOptions(ctx, req)
+	opts.OnSegment = out.onSegment()
+	resp, status := withCache(ctx, tenantCacheKey(ctx, samplesCacheKey(samples, f.SampleRate, opts)), start, func() (TranscribeResponse, int) {
+		return transcribeSamples(ctx, samples, f.SampleRate, opts, start)
+	})
+	if status == http.StatusOK && req.MaxChunkLen > 0 {
+		resp.Chunks = splitText(resp.Text, req.MaxChunkLen)
+	}
+	resp.RequestID = requestID(ctx)
+	recordTranscript(ctx, sourcePCM, "", req, &resp)
+	out.write(status, resp)
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// --- parsePCMContentType ---
+
+func TestParsePCMContentType(t *testing.T) {
+	tests := []struct {
+		ct      string
+		want    pcmFormat
+		wantErr bool
+	}{
+		{"audio/l16", pcmFormat{16000, 1, true, pcmL16}, false},
+		{"audio/L16;rate=16000", pcmFormat{16000, 1, true, pcmL16}, false},
+		{"audio/l16; rate=8000; channels=2", pcmFormat{8000, 2, true, pcmL16}, false},
+		{"audio/l16;rate=16000;endianness=little-endian", pcmFormat{16000, 1, false, pcmL16}, false},
+		{"audio/wav", pcmFormat{}, true},
+		{"", pcmFormat{}, true},
+		{"audio/l16;rate=48000", pcmFormat{48000, 1, true, pcmL16}, false},
+		{"audio/l16;rate=abc", pcmFormat{}, true},
+		{"audio/l16;rate=100", pcmFormat{}, true},
+		{"audio/l16;channels=3", pcmFormat{}, true},
+		{"audio/l16;endianness=middle", pcmFormat{}, true},
+		{"audio/PCMU", pcmFormat{8000, 1, true, pcmULaw}, false},
+		{"audio/basic", pcmFormat{8000, 1, true, pcmULaw}, false},
+		{"audio/pcma;rate=16000;channels=2", pcmFormat{16000, 2, true, pcmALaw}, false},
+	}
+	for _, tt := range tests {
+		got, err := parsePCMContentType(tt.ct)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parsePCMContentType(%q) err = %v, wantErr %v", tt.ct, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("parsePCMContentType(%q) = %+v, want %+v", tt.ct, got, tt.want)
+		}
+	}
+}
+
+// --- decodePCMStream ---
+
+func TestDecodePCMStream_BigEndianMono(t *testing.T) {
+	data := make([]byte, 4)
+	binary.BigEndian.PutUint16(data[0:2], 0x4000)                // 16384 → 0.5
+	binary.BigEndian.PutUint16(data[2:4], uint16(0x10000-16384)) // -16384 → -0.5
+	got, err := decodePCMStream(bytes.NewReader(data), pcmFormat{16000, 1, true, pcmL16}, 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 2 || got[0] != 0.5 || got[1] != -0.5 {
+		t.Errorf("samples = %v, want [0.5 -0.5]", got)
+	}
+}
+
+func TestDecodePCMStream_LittleEndianStereo(t *testing.T) {
+	data := make([]byte, 4)
+	binary.LittleEndian.PutUint16(data[0:2], 0x4000) // L = 0.5
+	binary.LittleEndian.PutUint16(data[2:4], 0)      // R = 0
+	got, err := decodePCMStream(bytes.NewReader(data), pcmFormat{16000, 2, false, pcmL16}, 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 1 || got[0] != 0.25 {
+		t.Errorf("samples = %v, want [0.25]", got)
+	}
+}
+
+func TestDecodePCMStream_SplitReads(t *testing.T) {
+	// Frames straddling read boundaries must decode identically.
+	data := make([]byte, 2*1000)
+	for i := range 1000 {
+		binary.BigEndian.PutUint16(data[2*i:], uint16(i))
+	}
+	got, err := decodePCMStream(iotest.OneByteReader(bytes.NewReader(data)), pcmFormat{16000, 1, true, pcmL16}, 2000)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 1000 {
+		t.Fatalf("got %d samples, want 1000", len(got))
+	}
+	for i, s := range got {
+		if want := float32(i) / 32768.0; s != want {
+			t.Fatalf("sample %d = %f, want %f", i, s, want)
+		}
+	}
+}
+
+func TestDecodePCMStream_TrailingByte(t *testing.T) {
+	got, err := decodePCMStream(bytes.NewReader([]byte{0, 1, 2}), pcmFormat{16000, 1, true, pcmL16}, 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 1 {
+		t.Errorf("got %d samples, want 1", len(got))
+	}
+}
+
+func TestDecodePCMStream_TooLong(t *testing.T) {
+	_, err := decodePCMStream(bytes.NewReader(make([]byte, 2*101)), pcmFormat{16000, 1, true, pcmL16}, 100)
+	if err != errPCMTooLong {
+		t.Errorf("err = %v, want errPCMTooLong", err)
+	}
+}
+
+func TestDecodePCMStream_ReadError(t *testing.T) {
+	_, err := decodePCMStream(iotest.ErrReader(io.ErrUnexpectedEOF), pcmFormat{16000, 1, true, pcmL16}, 100)
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("err = %v, want ErrUnexpectedEOF", err)
+	}
+}
+
+func TestDecodePCMStream_G711(t *testing.T) {
+	tests := []struct {
+		f    pcmFormat
+		data []byte
+		want []float32
+	}{
+		{pcmFormat{8000, 1, true, pcmULaw}, []byte{0xFF, 0x80}, []float32{0, 32124.0 / 32768}},
+		{pcmFormat{8000, 1, true, pcmALaw}, []byte{0xD5, 0x2A}, []float32{8.0 / 32768, -32256.0 / 32768}},
+		{pcmFormat{8000, 2, true, pcmULaw}, []byte{0x80, 0x00, 0xFF}, []float32{0}}, // stereo cancels; partial frame dropped
+	}
+	for _, tt := range tests {
+		got, err := decodePCMStream(bytes.NewReader(tt.data), tt.f, 100)
+		if err != nil {
+			t.Fatalf("%+v: unexpected error: %v", tt.f, err)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%+v: samples = %v, want %v", tt.f, got, tt.want)
+		}
+	}
+}
+
+// --- readPCM ---
+
+func TestReadPCM_CallbackPerRead(t *testing.T) {
+	var calls, total int
+	err := readPCM(iotest.OneByteReader(bytes.NewReader(make([]byte, 2*100))), pcmFormat{16000, 1, true, pcmL16}, func(s []float32) error {
+		calls++
+		total += len(s)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if total != 100 || calls != 100 {
+		t.Errorf("got %d samples in %d calls, want one call per frame", total, calls)
+	}
+}
+
+func TestReadPCM_CallbackErrorStops(t *testing.T) {
+	stop := errors.New("stop")
+	err := readPCM(bytes.NewReader(make([]byte, 4)), pcmFormat{16000, 1, true, pcmL16}, func([]float32) error { return stop })
+	if !errors.Is(err, stop) {
+		t.Errorf("err = %v, want callback error", err)
+	}
+}
+
+// --- handlePCM ---
+
+func TestHandlePCM_Rejects(t *testing.T) {
+	oldMax := cfg.MaxAudioDurationS
+	cfg.MaxAudioDurationS = 0.001 // 16 frames at 16 kHz
+	t.Cleanup(func() { cfg.MaxAudioDurationS = oldMax })
+
+	tests := []struct {
+		method, ct string
+		body       []byte
+		want       int
+	}{
+		{http.MethodGet, "audio/l16", nil, http.StatusMethodNotAllowed},
+		{http.MethodPost, "audio/wav", nil, http.StatusUnsupportedMediaType},
+		{http.MethodPost, "audio/l16;rate=1000", nil, http.StatusUnsupportedMediaType},
+		{http.MethodPost, "audio/l16;rate=8000", nil, http.StatusBadRequest},
+		{http.MethodPost, "audio/l16;rate=16000", nil, http.StatusBadRequest},
+		{http.MethodPost, "audio/l16;rate=16000", make([]byte, 2*17), http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "/transcribe/pcm", bytes.NewReader(tt.body))
+		req.Header.Set("Content-Type", tt.ct)
+		rec := httptest.NewRecorder()
+		handlePCM(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s %q (%d bytes) = %d, want %d: %s", tt.method, tt.ct, len(tt.body), rec.Code, tt.want, rec.Body)
+		}
+	}
+}
+
+func TestHandlePCM_SplitChannels(t *testing.T) {
+	oldMax := cfg.MaxAudioDurationS
+	cfg.MaxAudioDurationS = 1
+	t.Cleanup(func() { cfg.MaxAudioDurationS = oldMax })
+
+	req := httptest.NewRequest(http.MethodPost, "/transcribe/pcm?split_channels=true", bytes.NewReader(make([]byte, 64)))
+	req.Header.Set("Content-Type", "audio/l16;rate=16000;channels=2")
+	rec := httptest.NewRecorder()
+	handlePCM(rec, req)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "split_channels") {
+		t.Errorf("status = %d, body = %s; want 400 mentioning split_channels", rec.Code, rec.Body)
+	}
+}
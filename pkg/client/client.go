@@ -0,0 +1,136 @@
+// Package client is a Go client for the HTTP API of a moonshine-whisper
+// service: file uploads to /transcribe/upload, and live streams over the
+// WebSocket on /transcribe/stream. The example commands in cmd/ are built
+// on it.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Client calls a moonshine-whisper service.
+type Client struct {
+	BaseURL string       // service base URL, such as http://localhost:8092
+	APIKey  string       // sent as a bearer token; "" for services without tenants
+	HTTP    *http.Client // nil = http.DefaultClient
+}
+
+// Result is the response to an upload.
+type Result struct {
+	Text       string   `json:"text"`
+	Chunks     []string `json:"chunks,omitempty"`
+	DurationMs float64  `json:"duration_ms"`
+	SpeechMs   float64  `json:"speech_ms,omitempty"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// Error is a response of the service that is not a result, such as a
+// refused stream handshake.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string { return fmt.Sprintf("HTTP %d: %s", e.StatusCode, e.Message) }
+
+// Upload posts the file at path to /transcribe/upload as the "audio" form
+// file, with the non-empty fields as form values, and returns the raw and
+// decoded response bodies. A failed transcription is a Result with Error
+// set; a response that is not JSON is an *Error.
+func (c *Client) Upload(ctx context.Context, path string, fields map[string]string) ([]byte, Result, error) {
+	var res Result
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, res, err
+	}
+	defer f.Close() //nolint:errcheck
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("audio", filepath.Base(path))
+	if err != nil {
+		return nil, res, err
+	}
+	if _, err := io.Copy(fw, f); err != nil {
+		return nil, res, fmt.Errorf("read file: %w", err)
+	}
+	for k, v := range fields {
+		if v != "" {
+			mw.WriteField(k, v) //nolint:errcheck
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, res, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint("/transcribe/upload", nil), &body)
+	if err != nil {
+		return nil, res, err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	c.authorize(req)
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, res, err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, res, fmt.Errorf("read response: %w", err)
+	}
+	if err := json.Unmarshal(raw, &res); err != nil {
+		return raw, res, &Error{resp.StatusCode, string(bytes.TrimSpace(raw))}
+	}
+	return raw, res, nil
+}
+
+// httpClient returns c.HTTP, or http.DefaultClient.
+func (c *Client) httpClient() *http.Client {
+	if c.HTTP != nil {
+		return c.HTTP
+	}
+	return http.DefaultClient
+}
+
+// endpoint returns the URL of path on the service, with query q.
+func (c *Client) endpoint(path string, q url.Values) string {
+	u := strings.TrimSuffix(c.BaseURL, "/") + path
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+	return u
+}
+
+// authorize sets the API key of c on req.
+func (c *Client) authorize(req *http.Request) {
+	if c.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+}
+
+// statusError returns the *Error of a response that is not the one
+// expected, with the message of its {"error": ...} body if it has one.
+func statusError(resp *http.Response) error {
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	var body struct {
+		Error string `json:"error"`
+	}
+	msg := strings.TrimSpace(string(raw))
+	if json.Unmarshal(raw, &body) == nil && body.Error != "" {
+		msg = body.Error
+	}
+	return &Error{resp.StatusCode, msg}
+}
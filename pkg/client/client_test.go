@@ -0,0 +1,60 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// --- Upload ---
+
+func TestUpload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "call.ogg")
+	if err := os.WriteFile(path, []byte("OggS audio"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/transcribe/upload" || r.Header.Get("Authorization") != "Bearer k1" {
+			t.Errorf("request = %s %v", r.URL.Path, r.Header)
+		}
+		f, fh, err := r.FormFile("audio")
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(f)
+		if fh.Filename != "call.ogg" || string(data) != "OggS audio" {
+			t.Errorf("audio = %s %q", fh.Filename, data)
+		}
+		if _, ok := r.MultipartForm.Value["vad"]; ok || r.FormValue("language") != "ru" {
+			t.Errorf("fields = %v, want language only", r.MultipartForm.Value)
+		}
+		w.Write([]byte(`{"text":"привет","duration_ms":1200}`)) //nolint:errcheck
+	}))
+	defer srv.Close()
+
+	c := &Client{BaseURL: srv.URL + "/", APIKey: "k1"}
+	_, res, err := c.Upload(context.Background(), path, map[string]string{"language": "ru", "vad": ""})
+	if err != nil || res.Text != "привет" || res.DurationMs != 1200 {
+		t.Errorf("Upload = %+v, %v", res, err)
+	}
+}
+
+func TestUpload_NotJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "call.wav")
+	os.WriteFile(path, []byte("RIFF"), 0o644) //nolint:errcheck
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad gateway", http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	_, _, err := (&Client{BaseURL: srv.URL}).Upload(context.Background(), path, nil)
+	var cerr *Error
+	if !errors.As(err, &cerr) || cerr.StatusCode != http.StatusBadGateway || cerr.Message != "bad gateway" {
+		t.Errorf("Upload = %v, want HTTP 502: bad gateway", err)
+	}
+}
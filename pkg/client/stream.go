@@ -0,0 +1,222 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// The client side of the WebSocket protocol (RFC 6455), as much as
+// /transcribe/stream needs: binary audio out, JSON text messages in.
+
+// websocketGUID is appended to the client key to compute the handshake
+// accept value.
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// maxMessage bounds a message from the server.
+const maxMessage = 1 << 20
+
+// chunkBytes is 100 ms of 16 kHz 16-bit PCM, the size of the audio
+// messages SendAudio sends.
+const chunkBytes = 3200
+
+// WebSocket opcodes.
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+)
+
+// CloseNormal is the close code of a stream that ended without an error.
+const CloseNormal = 1000
+
+// StreamResult is one message of a stream: a partial or final result, or
+// the error that ends the stream.
+type StreamResult struct {
+	Text  string  `json:"text"`
+	Final bool    `json:"final"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Error string  `json:"error,omitempty"`
+}
+
+// Stream is an open WebSocket on /transcribe/stream. Audio is sent by one
+// goroutine while messages are read by another.
+type Stream struct {
+	conn io.ReadWriteCloser
+	br   *bufio.Reader
+	wmu  sync.Mutex
+
+	// The close code and reason of the server, once ReadMessage has
+	// returned io.EOF.
+	CloseCode   int
+	CloseReason string
+}
+
+// Dial opens a stream with the options in q: language, content_type (of
+// the audio; the service defaults to audio/webm), punctuate, two_pass, and
+// the others of POST /transcribe/stream. ctx bounds the handshake. A
+// refused handshake is an *Error.
+func (c *Client) Dial(ctx context.Context, q url.Values) (*Stream, error) {
+	nonce := make([]byte, 16)
+	rand.Read(nonce) //nolint:errcheck
+	key := base64.StdEncoding.EncodeToString(nonce)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.endpoint("/transcribe/stream", q), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	c.authorize(req)
+
+	// The transport keeps the handshake on HTTP/1.1; the client's Timeout
+	// would cut the stream short, so only its transport is used.
+	resp, err := (&http.Client{Transport: c.httpClient().Transport}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		defer resp.Body.Close() //nolint:errcheck
+		return nil, statusError(resp)
+	}
+	conn, ok := resp.Body.(io.ReadWriteCloser)
+	if !ok {
+		resp.Body.Close() //nolint:errcheck
+		return nil, errors.New("websocket: the transport cannot upgrade connections")
+	}
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	if resp.Header.Get("Sec-WebSocket-Accept") != base64.StdEncoding.EncodeToString(sum[:]) {
+		conn.Close() //nolint:errcheck
+		return nil, errors.New("websocket: bad Sec-WebSocket-Accept from the server")
+	}
+	return &Stream{conn: conn, br: bufio.NewReader(conn)}, nil
+}
+
+// SendAudio sends in as binary messages of 100 ms of 16 kHz PCM, then the
+// text message "end", after which the service sends its last results and
+// closes the stream.
+func (s *Stream) SendAudio(in io.Reader) error {
+	buf := make([]byte, chunkBytes)
+	for {
+		n, err := in.Read(buf)
+		if n > 0 {
+			if werr := s.write(opBinary, buf[:n]); werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF {
+			return s.write(opText, []byte("end"))
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// ReadMessage returns the next text message, answering pings on the way
+// and joining fragmented messages. It returns io.EOF once the server closes
+// the stream, with its close code in s.CloseCode.
+func (s *Stream) ReadMessage() ([]byte, error) {
+	var msg []byte
+	for {
+		first, payload, err := s.readFrame()
+		if err != nil {
+			return nil, err
+		}
+		switch first & 0x0f {
+		case opClose:
+			if len(payload) >= 2 {
+				s.CloseCode = int(binary.BigEndian.Uint16(payload))
+				s.CloseReason = string(payload[2:])
+			}
+			s.write(opClose, payload) //nolint:errcheck // echo the close
+			return nil, io.EOF
+		case opPing:
+			s.write(opPong, payload) //nolint:errcheck // a write error surfaces on the next write
+		case opText, opContinuation:
+			if len(msg)+len(payload) > maxMessage {
+				return nil, fmt.Errorf("websocket: message larger than %d bytes", maxMessage)
+			}
+			msg = append(msg, payload...)
+			if first&0x80 != 0 {
+				return msg, nil
+			}
+		}
+	}
+}
+
+// Close closes the connection. After ReadMessage has returned io.EOF the
+// close handshake is done; before, it drops the stream.
+func (s *Stream) Close() error {
+	return s.conn.Close()
+}
+
+// readFrame reads one unmasked frame from the server.
+func (s *Stream) readFrame() (first byte, payload []byte, err error) {
+	var h [2]byte
+	if _, err := io.ReadFull(s.br, h[:]); err != nil {
+		// A lost connection is not the server closing the stream.
+		return 0, nil, fmt.Errorf("websocket: connection lost: %v", err)
+	}
+	n := uint64(h[1] & 0x7f)
+	switch n {
+	case 126:
+		var b [2]byte
+		if _, err := io.ReadFull(s.br, b[:]); err != nil {
+			return 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		if _, err := io.ReadFull(s.br, b[:]); err != nil {
+			return 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(b[:])
+	}
+	if n > maxMessage {
+		return 0, nil, fmt.Errorf("websocket: frame larger than %d bytes", maxMessage)
+	}
+	payload = make([]byte, n)
+	if _, err := io.ReadFull(s.br, payload); err != nil {
+		return 0, nil, err
+	}
+	return h[0], payload, nil
+}
+
+// write sends one frame, masked as client frames must be.
+func (s *Stream) write(op byte, payload []byte) error {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	hdr := []byte{0x80 | op}
+	switch n := len(payload); {
+	case n < 126:
+		hdr = append(hdr, 0x80|byte(n))
+	case n <= 0xffff:
+		hdr = binary.BigEndian.AppendUint16(append(hdr, 0x80|126), uint16(n))
+	default:
+		hdr = binary.BigEndian.AppendUint64(append(hdr, 0x80|127), uint64(n))
+	}
+	var mask [4]byte
+	rand.Read(mask[:]) //nolint:errcheck
+	data := make([]byte, len(payload))
+	for i, b := range payload {
+		data[i] = b ^ mask[i%4]
+	}
+	_, err := (&net.Buffers{hdr, mask[:], data}).WriteTo(s.conn)
+	return err
+}
@@ -0,0 +1,207 @@
+package moonshine
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	sherpa "github.com/k2-fsa/sherpa-onnx-go/sherpa_onnx"
+)
+
+// Backends of Config.Backends: the model family a language's directory
+// holds.
+const (
+	BackendMoonshine  = "moonshine"  // encoder_model.ort, decoder_model_merged.ort; the EN default
+	BackendZipformer  = "zipformer"  // transducer encoder, decoder, joiner; the RU default
+	BackendWhisper    = "whisper"    // encoder, decoder; robust on accented speech
+	BackendParaformer = "paraformer" // model; the ZH default
+	BackendSenseVoice = "sensevoice" // model; zh, en, ja, ko, and yue, with emotion and event tags
+
+	// NVIDIA NeMo models exported to ONNX, for languages without a
+	// Zipformer checkpoint.
+	BackendNeMoCTC        = "nemo_ctc"        // model
+	BackendNeMoTransducer = "nemo_transducer" // encoder, decoder, joiner
+)
+
+// ValidBackend reports whether b is a supported backend; "" means the
+// language's default.
+func ValidBackend(b string) bool {
+	return b == "" || b == BackendMoonshine || b == BackendZipformer || b == BackendWhisper || b == BackendParaformer ||
+		b == BackendSenseVoice || b == BackendNeMoCTC || b == BackendNeMoTransducer
+}
+
+// Backend returns the backend of the model that serves lang.
+func (e *Engine) Backend(lang string) string {
+	model := e.langModel(lang)
+	if b := e.cfg.Backends[model]; b != "" {
+		return b
+	}
+	switch model {
+	case "ru":
+		return BackendZipformer
+	case "zh":
+		return BackendParaformer
+	}
+	return BackendMoonshine
+}
+
+// newLangRecognizer loads the model of lang (one of Languages) from dir with
+// the backend Config.Backends sets for it.
+func (e *Engine) newLangRecognizer(lang, dir string) (*sherpa.OfflineRecognizer, sherpa.OfflineRecognizerConfig, error) {
+	switch e.Backend(lang) {
+	case BackendZipformer:
+		return e.newZipformerRecognizer(lang, dir)
+	case BackendWhisper:
+		return e.newWhisperRecognizer(dir, lang, TaskTranscribe)
+	case BackendParaformer:
+		return e.newParaformerRecognizer(lang, dir)
+	case BackendSenseVoice:
+		return e.newSenseVoiceRecognizer(lang, dir)
+	case BackendNeMoCTC:
+		return e.newNeMoCTCRecognizer(lang, dir)
+	case BackendNeMoTransducer:
+		return e.newNeMoTransducerRecognizer(lang, dir)
+	default:
+		return e.newMoonshineRecognizer(lang, dir)
+	}
+}
+
+// newWhisperRecognizer loads the Whisper model from dir, set to task in
+// language ("" lets Whisper detect it), and returns it with the config it
+// was created from.
+func (e *Engine) newWhisperRecognizer(dir, language, task string) (*sherpa.OfflineRecognizer, sherpa.OfflineRecognizerConfig, error) {
+	c := sherpa.OfflineRecognizerConfig{}
+	c.FeatConfig.SampleRate = 16000
+	c.FeatConfig.FeatureDim = 80
+	c.ModelConfig.Whisper.Encoder = e.onnxFile(dir, "encoder")
+	c.ModelConfig.Whisper.Decoder = e.onnxFile(dir, "decoder")
+	c.ModelConfig.Whisper.Language = language
+	c.ModelConfig.Whisper.Task = task
+	c.ModelConfig.Whisper.TailPaddings = -1
+	c.ModelConfig.Tokens = filepath.Join(dir, "tokens.txt")
+	c.ModelConfig.NumThreads = e.cfg.threads(language)
+	c.ModelConfig.Provider = e.Provider()
+	c.DecodingMethod = GreedySearch
+	if err := requireFiles(c.ModelConfig.Whisper.Encoder, c.ModelConfig.Whisper.Decoder, c.ModelConfig.Tokens); err != nil {
+		return nil, c, err
+	}
+	r := sherpa.NewOfflineRecognizer(&c)
+	if r == nil {
+		return nil, c, fmt.Errorf("failed to load Whisper model from %s", dir)
+	}
+	return r, c, nil
+}
+
+// newParaformerRecognizer loads the Paraformer model of lang from dir and returns
+// it with the config it was created from.
+func (e *Engine) newParaformerRecognizer(lang, dir string) (*sherpa.OfflineRecognizer, sherpa.OfflineRecognizerConfig, error) {
+	c := sherpa.OfflineRecognizerConfig{}
+	c.FeatConfig.SampleRate = 16000
+	c.FeatConfig.FeatureDim = 80
+	c.ModelConfig.Paraformer.Model = e.onnxFile(dir, "model")
+	c.ModelConfig.Tokens = filepath.Join(dir, "tokens.txt")
+	c.ModelConfig.NumThreads = e.cfg.threads(lang)
+	c.ModelConfig.Provider = e.Provider()
+	c.DecodingMethod = GreedySearch
+	if err := requireFiles(c.ModelConfig.Paraformer.Model, c.ModelConfig.Tokens); err != nil {
+		return nil, c, err
+	}
+	r := sherpa.NewOfflineRecognizer(&c)
+	if r == nil {
+		return nil, c, fmt.Errorf("failed to load Paraformer model from %s", dir)
+	}
+	return r, c, nil
+}
+
+// newSenseVoiceRecognizer loads the SenseVoice model of lang from dir, detecting the
+// spoken language and writing punctuation and numbers itself, and returns it
+// with the config it was created from.
+func (e *Engine) newSenseVoiceRecognizer(lang, dir string) (*sherpa.OfflineRecognizer, sherpa.OfflineRecognizerConfig, error) {
+	c := sherpa.OfflineRecognizerConfig{}
+	c.FeatConfig.SampleRate = 16000
+	c.FeatConfig.FeatureDim = 80
+	c.ModelConfig.SenseVoice.Model = e.onnxFile(dir, "model")
+	c.ModelConfig.SenseVoice.Language = "auto"
+	c.ModelConfig.SenseVoice.UseInverseTextNormalization = 1
+	c.ModelConfig.Tokens = filepath.Join(dir, "tokens.txt")
+	c.ModelConfig.NumThreads = e.cfg.threads(lang)
+	c.ModelConfig.Provider = e.Provider()
+	c.DecodingMethod = GreedySearch
+	if err := requireFiles(c.ModelConfig.SenseVoice.Model, c.ModelConfig.Tokens); err != nil {
+		return nil, c, err
+	}
+	r := sherpa.NewOfflineRecognizer(&c)
+	if r == nil {
+		return nil, c, fmt.Errorf("failed to load SenseVoice model from %s", dir)
+	}
+	return r, c, nil
+}
+
+// newNeMoCTCRecognizer loads the NeMo CTC model of lang from dir and returns it with
+// the config it was created from.
+func (e *Engine) newNeMoCTCRecognizer(lang, dir string) (*sherpa.OfflineRecognizer, sherpa.OfflineRecognizerConfig, error) {
+	c := sherpa.OfflineRecognizerConfig{}
+	c.FeatConfig.SampleRate = 16000
+	c.FeatConfig.FeatureDim = 80
+	c.ModelConfig.NemoCTC.Model = e.onnxFile(dir, "model")
+	c.ModelConfig.Tokens = filepath.Join(dir, "tokens.txt")
+	c.ModelConfig.NumThreads = e.cfg.threads(lang)
+	c.ModelConfig.Provider = e.Provider()
+	c.DecodingMethod = GreedySearch
+	if err := requireFiles(c.ModelConfig.NemoCTC.Model, c.ModelConfig.Tokens); err != nil {
+		return nil, c, err
+	}
+	r := sherpa.NewOfflineRecognizer(&c)
+	if r == nil {
+		return nil, c, fmt.Errorf("failed to load NeMo CTC model from %s", dir)
+	}
+	return r, c, nil
+}
+
+// newNeMoTransducerRecognizer loads the NeMo transducer of lang from dir and returns
+// it with the config it was created from. It decodes greedily; hotwords and
+// beam search are left to Zipformer.
+func (e *Engine) newNeMoTransducerRecognizer(lang, dir string) (*sherpa.OfflineRecognizer, sherpa.OfflineRecognizerConfig, error) {
+	c := sherpa.OfflineRecognizerConfig{}
+	c.FeatConfig.SampleRate = 16000
+	c.FeatConfig.FeatureDim = 80
+	c.ModelConfig.Transducer.Encoder = e.onnxFile(dir, "encoder")
+	c.ModelConfig.Transducer.Decoder = e.onnxFile(dir, "decoder")
+	c.ModelConfig.Transducer.Joiner = e.onnxFile(dir, "joiner")
+	c.ModelConfig.ModelType = "nemo_transducer"
+	c.ModelConfig.Tokens = filepath.Join(dir, "tokens.txt")
+	c.ModelConfig.NumThreads = e.cfg.threads(lang)
+	c.ModelConfig.Provider = e.Provider()
+	c.DecodingMethod = GreedySearch
+	if err := requireFiles(c.ModelConfig.Transducer.Encoder, c.ModelConfig.Transducer.Decoder,
+		c.ModelConfig.Transducer.Joiner, c.ModelConfig.Tokens); err != nil {
+		return nil, c, err
+	}
+	r := sherpa.NewOfflineRecognizer(&c)
+	if r == nil {
+		return nil, c, fmt.Errorf("failed to load NeMo transducer from %s", dir)
+	}
+	return r, c, nil
+}
+
+// selfPunctuating reports whether model writes its own punctuation, as
+// Whisper and SenseVoice do, so punctuation is not added by default.
+func (e *Engine) selfPunctuating(model string) bool {
+	return e.whisperModel(model) || e.Backend(modelArch(model)) == BackendSenseVoice
+}
+
+// eventName turns a SenseVoice audio event tag such as "<|Laughter|>" into
+// a label, or "" for plain speech and unknown events.
+func eventName(tag string) string {
+	label := strings.ToLower(strings.Trim(tag, "<|>"))
+	if label == "" || label == "speech" || label == "event_unk" || label == "unk" {
+		return ""
+	}
+	return label
+}
+
+// whisperModel reports whether model is decoded by Whisper, which hears at
+// most 30 seconds at a time.
+func (e *Engine) whisperModel(model string) bool {
+	return model == translateModel || e.Backend(modelArch(model)) == BackendWhisper
+}
@@ -0,0 +1,73 @@
+package moonshine
+
+import "testing"
+
+// --- Engine.Backend ---
+
+func TestBackend(t *testing.T) {
+	def := new(Engine)
+	whisper := &Engine{cfg: Config{Backends: map[string]string{"ru": BackendWhisper}}}
+	tests := []struct {
+		e    *Engine
+		lang string
+		want string
+	}{
+		{def, "en", BackendMoonshine},
+		{def, "ru", BackendZipformer},
+		{def, "es", BackendMoonshine},
+		{def, "zh", BackendParaformer},
+		{whisper, "ru", BackendWhisper},
+		{whisper, "en", BackendMoonshine},
+	}
+	for _, tt := range tests {
+		if got := tt.e.Backend(tt.lang); got != tt.want {
+			t.Errorf("Backend(%q) = %q, want %q", tt.lang, got, tt.want)
+		}
+	}
+}
+
+// --- Engine.whisperModel ---
+
+func TestWhisperModel(t *testing.T) {
+	e := &Engine{cfg: Config{Backends: map[string]string{"en": BackendWhisper}}}
+	for model, want := range map[string]bool{"en": true, "en/telephony": true, "ru": false, translateModel: true} {
+		if got := e.whisperModel(model); got != want {
+			t.Errorf("whisperModel(%q) = %v, want %v", model, got, want)
+		}
+	}
+	if new(Engine).whisperModel("en") {
+		t.Error("Moonshine EN must not count as Whisper")
+	}
+}
+
+// --- Engine.selfPunctuating ---
+
+func TestSelfPunctuating(t *testing.T) {
+	e := &Engine{cfg: Config{Backends: map[string]string{"en": BackendSenseVoice}}}
+	for model, want := range map[string]bool{"en": true, "en/accurate": true, "ru": false, "zh": false, translateModel: true} {
+		if got := e.selfPunctuating(model); got != want {
+			t.Errorf("selfPunctuating(%q) = %v, want %v", model, got, want)
+		}
+	}
+}
+
+// --- eventName ---
+
+func TestEventName(t *testing.T) {
+	for tag, want := range map[string]string{"<|Laughter|>": "laughter", "<|BGM|>": "bgm", "<|Speech|>": "", "<|Event_UNK|>": "", "": ""} {
+		if got := eventName(tag); got != want {
+			t.Errorf("eventName(%q) = %q, want %q", tag, got, want)
+		}
+	}
+}
+
+// --- ValidBackend ---
+
+func TestValidBackend(t *testing.T) {
+	for b, want := range map[string]bool{"": true, BackendMoonshine: true, BackendZipformer: true, BackendWhisper: true, BackendParaformer: true, BackendSenseVoice: true,
+		BackendNeMoCTC: true, BackendNeMoTransducer: true, "kaldi": false, "nemo": false} {
+		if got := ValidBackend(b); got != want {
+			t.Errorf("ValidBackend(%q) = %v, want %v", b, got, want)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package moonshine
+
+import (
+	"context"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// Channel selections of Options.Channel besides a 0-based channel number.
+const (
+	ChannelMix   = "mix"   // average all channels
+	ChannelLeft  = "left"  // channel 0
+	ChannelRight = "right" // channel 1
+)
+
+// ValidChannel reports whether c is a supported channel selection: "" or
+// ChannelMix, ChannelLeft, ChannelRight, or a 0-based channel number.
+func ValidChannel(c string) bool {
+	_, ok := channelIndex(c)
+	return ok
+}
+
+// channelIndex returns the 0-based channel selected by c, or -1 to
+// downmix all channels.
+func channelIndex(c string) (int, bool) {
+	switch c {
+	case "", ChannelMix:
+		return -1, true
+	case ChannelLeft:
+		return 0, true
+	case ChannelRight:
+		return 1, true
+	}
+	n, err := strconv.Atoi(c)
+	if err != nil || n < 0 {
+		return -1, false
+	}
+	return n, true
+}
+
+// transcribeFileChannels decodes the file at path keeping its channels and
+// transcribes each one separately.
+func (e *Engine) transcribeFileChannels(ctx context.Context, path string, opts Options) (Result, error) {
+	channels, sampleRate, err := e.decodeChannels(ctx, path, opts)
+	if err != nil {
+		return Result{}, err
+	}
+	return e.TranscribeChannels(ctx, channels, sampleRate, opts)
+}
+
+// decodeChannel decodes channel channel of the file at path alone, failing
+// with ErrInvalidAudio if the audio has fewer channels.
+func (e *Engine) decodeChannel(ctx context.Context, path string, channel int, opts Options) ([]float32, int, error) {
+	channels, sampleRate, err := e.decodeChannels(ctx, path, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	if channel >= len(channels) {
+		return nil, 0, errorf(ErrInvalidAudio, "channel %d not found: the audio has %d channel(s)", channel, len(channels))
+	}
+	return channels[channel], sampleRate, nil
+}
+
+// decodeChannels decodes the file at path with ffmpeg, unless it is WAV,
+// keeping its channels.
+func (e *Engine) decodeChannels(ctx context.Context, path string, opts Options) ([][]float32, int, error) {
+	wavPath, cleanupPath, err := e.ensureWav(ctx, path, opts.AudioTrack, true, e.maxDuration(opts))
+	if ctx.Err() != nil {
+		return nil, 0, ctx.Err()
+	}
+	if err != nil {
+		return nil, 0, conversionError(err)
+	}
+	if cleanupPath != "" {
+		defer os.Remove(cleanupPath) //nolint:errcheck
+	}
+	channels, sampleRate, err := loadWavChannels(wavPath)
+	if err != nil {
+		return nil, 0, errorf(ErrInvalidAudio, "load wav: %v", err)
+	}
+	return channels, sampleRate, nil
+}
+
+// TranscribeChannels transcribes each channel on its own, as for a stereo
+// call recording with the agent on one side and the customer on the other.
+// Segments from all channels are interleaved by start time and labelled
+// with their channel; a channel without segments contributes one segment
+// spanning its audio.
+func (e *Engine) TranscribeChannels(ctx context.Context, channels [][]float32, sampleRate int, opts Options) (Result, error) {
+	progress := opts.Progress
+	var res Result
+	var segments []Segment
+	done := 0 // chunks decoded in finished channels
+	var emotions [][]EmotionScore
+	var lengths []float64
+	for i, samples := range channels {
+		chOpts := opts
+		chOpts.SplitChannels = false
+		chOpts.OnChunk, chOpts.Resume = nil, nil // chunk indices repeat per channel
+		if opts.OnSegment != nil {
+			chOpts.OnSegment = func(s Segment) {
+				s.Channel = &i
+				opts.OnSegment(s)
+			}
+		}
+		chTotal := 0
+		if progress != nil {
+			// Channels not yet started are assumed to have as many chunks.
+			remaining := len(channels) - i
+			chOpts.Progress = func(n, total int) {
+				chTotal = total
+				progress(done+n, done+total*remaining)
+			}
+		}
+		r, err := e.TranscribeSamples(ctx, samples, sampleRate, chOpts)
+		if err != nil {
+			return Result{}, err
+		}
+		done += chTotal
+		if res.Language == "" {
+			res.Language = r.Language // of the first channel
+		}
+		res.AudioS = max(res.AudioS, r.AudioS)
+		res.SpeechMs += r.SpeechMs
+		res.Filtered = res.Filtered || r.Filtered
+		segments = append(segments, channelSegments(r, i)...)
+		res.DTMF = append(res.DTMF, r.DTMF...)
+		res.Skipped = append(res.Skipped, r.Skipped...)
+		emotions, lengths = append(emotions, r.Emotions), append(lengths, r.AudioS)
+	}
+	sort.SliceStable(segments, func(a, b int) bool { return segments[a].Start < segments[b].Start })
+	sort.SliceStable(res.DTMF, func(a, b int) bool { return res.DTMF[a].Start < res.DTMF[b].Start })
+	sort.SliceStable(res.Skipped, func(a, b int) bool { return res.Skipped[a].Start < res.Skipped[b].Start })
+	res.Segments = segments
+	res.Text = joinSegmentText(segments)
+	if opts.Emotions {
+		res.Emotions = mergeEmotions(emotions, lengths)
+	}
+	if res.Filtered {
+		_, res.RawText = rawSegmentText(segments)
+	}
+	return res, nil
+}
+
+// channelSegments returns the segments of one channel's result labelled
+// with channel, or a single segment spanning the audio when it has none.
+func channelSegments(r Result, channel int) []Segment {
+	segments := r.Segments
+	if len(segments) == 0 {
+		if r.Text == "" && r.RawText == "" {
+			return nil
+		}
+		segments = []Segment{{End: r.AudioS, Text: r.Text, Filtered: r.Filtered, RawText: r.RawText}}
+	}
+	for i := range segments {
+		segments[i].Channel = &channel
+	}
+	return segments
+}
@@ -0,0 +1,97 @@
+package moonshine
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// --- channelIndex ---
+
+func TestChannelIndex(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+		ok   bool
+	}{
+		{"", -1, true},
+		{"mix", -1, true},
+		{"left", 0, true},
+		{"right", 1, true},
+		{"3", 3, true},
+		{"-1", -1, false},
+		{"center", -1, false},
+	}
+	for _, tt := range tests {
+		got, ok := channelIndex(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("channelIndex(%q) = %d, %v; want %d, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// --- Engine.decodeFile ---
+
+func TestDecodeFile_Channel(t *testing.T) {
+	// Three 8-bit frames: left 255 (≈+1), right 1 (≈-1).
+	path := filepath.Join(t.TempDir(), "stereo.wav")
+	data := []byte{255, 1, 255, 1, 255, 1}
+	if err := os.WriteFile(path, wavBytes(fmtChunk(wavFormatPCM, 2, 16000, 8), data, uint32(len(data))), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	e := &Engine{cfg: Config{NativeDecode: true}}
+	tests := []struct {
+		channel string
+		want    float32
+		err     error
+	}{
+		{"", 0, nil},
+		{"left", 127.0 / 128, nil},
+		{"right", -127.0 / 128, nil},
+		{"2", 0, ErrInvalidAudio},
+		{"centre", 0, ErrInvalidAudio},
+	}
+	for _, tt := range tests {
+		samples, rate, err := e.decodeFile(context.Background(), path, Options{Channel: tt.channel})
+		if tt.err != nil {
+			if !errors.Is(err, tt.err) {
+				t.Errorf("channel %q: err = %v, want %v", tt.channel, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || rate != 16000 || len(samples) != 3 || samples[0] != tt.want {
+			t.Errorf("channel %q = %v, %d, %v; want 3 samples of %v", tt.channel, samples, rate, err, tt.want)
+		}
+	}
+}
+
+// --- Engine.TranscribeChannels ---
+
+func TestTranscribeChannels_Unavailable(t *testing.T) {
+	channels := [][]float32{make([]float32, 16000), make([]float32, 16000)}
+	_, err := new(Engine).TranscribeChannels(context.Background(), channels, 16000, Options{Lang: "en"})
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("err = %v, want ErrUnavailable", err)
+	}
+}
+
+// --- channelSegments ---
+
+func TestChannelSegments(t *testing.T) {
+	r := Result{Segments: []Segment{{Start: 0, End: 1, Text: "a"}, {Start: 2, End: 3, Text: "b"}}}
+	got := channelSegments(r, 1)
+	if len(got) != 2 || got[0].Channel == nil || *got[0].Channel != 1 || *got[1].Channel != 1 {
+		t.Errorf("segments = %+v, want both on channel 1", got)
+	}
+
+	got = channelSegments(Result{Text: "hello", AudioS: 4}, 0)
+	if len(got) != 1 || got[0].Text != "hello" || got[0].End != 4 || *got[0].Channel != 0 {
+		t.Errorf("unsegmented = %+v, want one channel-0 segment spanning the audio", got)
+	}
+
+	if got := channelSegments(Result{AudioS: 4}, 0); got != nil {
+		t.Errorf("silent channel = %+v, want none", got)
+	}
+}
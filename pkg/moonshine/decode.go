@@ -0,0 +1,155 @@
+package moonshine
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/hajimehoshi/go-mp3"
+	"github.com/jfreymuth/oggvorbis"
+	"github.com/mewkiz/flac"
+)
+
+// errNotNative means the file is not in a format decoded in-process and
+// should be handed to ffmpeg.
+var errNotNative = errors.New("format not supported natively")
+
+// decodeNative decodes WAV, MP3, FLAC, and Ogg Vorbis files in-process,
+// returning mono samples and their sample rate. The container is sniffed
+// from the file header, not the extension. Decoding stops shortly after
+// maxDurationS so oversized files fail the duration check cheaply.
+func decodeNative(path string, maxDurationS float64) (samples []float32, rate int, err error) {
+	// The third-party decoders can panic on malformed input; treat that as
+	// a decode error so the caller falls back to ffmpeg.
+	defer func() {
+		if r := recover(); r != nil {
+			samples, rate, err = nil, 0, fmt.Errorf("decoder panic: %v", r)
+		}
+	}()
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close() //nolint:errcheck
+
+	br := bufio.NewReader(f)
+	head, _ := br.Peek(64)
+	switch sniffAudio(head) {
+	case "wav":
+		return decodeWav(br)
+	case "mp3":
+		return decodeMP3(br, maxDurationS)
+	case "flac":
+		return decodeFLAC(br, maxDurationS)
+	case "vorbis":
+		return decodeVorbis(br, maxDurationS)
+	}
+	return nil, 0, errNotNative
+}
+
+// sniffAudio identifies a natively supported container from its first bytes:
+// "wav", "mp3", "flac", "vorbis", or "" for anything else.
+func sniffAudio(head []byte) string {
+	switch {
+	case len(head) >= 12 && string(head[0:4]) == "RIFF" && string(head[8:12]) == "WAVE":
+		return "wav"
+	case bytes.HasPrefix(head, []byte("fLaC")):
+		return "flac"
+	case bytes.HasPrefix(head, []byte("OggS")) && bytes.Contains(head, []byte("\x01vorbis")):
+		return "vorbis"
+	case bytes.HasPrefix(head, []byte("ID3")):
+		return "mp3" // ID3v2 tag; go-mp3 skips it
+	case len(head) >= 2 && head[0] == 0xFF && head[1]&0xE0 == 0xE0 && head[1]&0x06 != 0:
+		return "mp3" // MPEG audio frame sync, layer bits set
+	}
+	return ""
+}
+
+// maxDecodeFrames returns how many frames to decode at rate: one past the
+// duration limit, so TranscribeSamples still reports the file as too long.
+func maxDecodeFrames(rate int, maxDurationS float64) int {
+	return int(maxDurationS*float64(rate)) + 1
+}
+
+// decodeMP3 decodes an MPEG-1/2 Layer III stream. go-mp3 always produces
+// 16-bit little-endian stereo.
+func decodeMP3(r io.Reader, maxDurationS float64) ([]float32, int, error) {
+	d, err := mp3.NewDecoder(r)
+	if err != nil {
+		return nil, 0, fmt.Errorf("mp3: %w", err)
+	}
+	const frameBytes = 4
+	data, err := io.ReadAll(io.LimitReader(d, int64(maxDecodeFrames(d.SampleRate(), maxDurationS))*frameBytes))
+	if err != nil {
+		return nil, 0, fmt.Errorf("mp3: %w", err)
+	}
+	return parsePCM(data, 2, 16, d.SampleRate())
+}
+
+// decodeFLAC decodes a FLAC stream of any bit depth and channel count.
+func decodeFLAC(r io.Reader, maxDurationS float64) ([]float32, int, error) {
+	stream, err := flac.New(r)
+	if err != nil {
+		return nil, 0, fmt.Errorf("flac: %w", err)
+	}
+	info := stream.Info
+	rate, channels := int(info.SampleRate), int(info.NChannels)
+	if channels < 1 || info.BitsPerSample < 4 {
+		return nil, 0, fmt.Errorf("flac: unsupported stream %dbit %dch", info.BitsPerSample, channels)
+	}
+	scale := float32(int64(1) << (info.BitsPerSample - 1))
+	limit := maxDecodeFrames(rate, maxDurationS)
+
+	samples := make([]float32, 0, min(int(info.NSamples), limit))
+	for len(samples) < limit {
+		frame, err := stream.ParseNext()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("flac: %w", err)
+		}
+		for i := 0; i < int(frame.BlockSize); i++ {
+			var sum float32
+			for _, sub := range frame.Subframes {
+				sum += float32(sub.Samples[i]) / scale
+			}
+			samples = append(samples, sum/float32(len(frame.Subframes)))
+		}
+	}
+	return samples, rate, nil
+}
+
+// decodeVorbis decodes an Ogg Vorbis stream, downmixing to mono.
+func decodeVorbis(r io.Reader, maxDurationS float64) ([]float32, int, error) {
+	vr, err := oggvorbis.NewReader(r)
+	if err != nil {
+		return nil, 0, fmt.Errorf("vorbis: %w", err)
+	}
+	rate, channels := vr.SampleRate(), vr.Channels()
+	limit := maxDecodeFrames(rate, maxDurationS)
+
+	var samples []float32
+	buf := make([]float32, 4096*channels)
+	for len(samples) < limit {
+		n, err := vr.Read(buf)
+		for i := 0; i+channels <= n; i += channels {
+			var sum float32
+			for c := 0; c < channels; c++ {
+				sum += buf[i+c]
+			}
+			samples = append(samples, clampSample(float64(sum/float32(channels))))
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("vorbis: %w", err)
+		}
+	}
+	return samples, rate, nil
+}
@@ -0,0 +1,155 @@
+package moonshine
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mewkiz/flac"
+	"github.com/mewkiz/flac/frame"
+	"github.com/mewkiz/flac/meta"
+)
+
+// writeTempAudio writes data to a temp file with the given name.
+func writeTempAudio(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// flacBytes encodes 16-bit stereo samples as a FLAC stream with verbatim subframes.
+func flacBytes(t *testing.T, rate int, left, right []int32) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	info := &meta.StreamInfo{
+		BlockSizeMin: 16, BlockSizeMax: 65535, SampleRate: uint32(rate),
+		NChannels: 2, BitsPerSample: 16, NSamples: uint64(len(left)),
+	}
+	enc, err := flac.NewEncoder(&buf, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub := func(s []int32) *frame.Subframe {
+		return &frame.Subframe{SubHeader: frame.SubHeader{Pred: frame.PredVerbatim}, Samples: s, NSamples: len(s)}
+	}
+	f := &frame.Frame{
+		Header: frame.Header{
+			HasFixedBlockSize: true, BlockSize: uint16(len(left)), SampleRate: uint32(rate),
+			Channels: frame.ChannelsLR, BitsPerSample: 16,
+		},
+		Subframes: []*frame.Subframe{sub(left), sub(right)},
+	}
+	if err := enc.WriteFrame(f); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// --- sniffAudio ---
+
+func TestSniffAudio(t *testing.T) {
+	tests := []struct {
+		head string
+		want string
+	}{
+		{"RIFF\x00\x00\x00\x00WAVEfmt ", "wav"},
+		{"RIFF\x00\x00\x00\x00AVI ", ""},
+		{"fLaC\x00\x00\x00\x22", "flac"},
+		{"OggS\x00\x02" + string(make([]byte, 22)) + "\x01vorbis", "vorbis"},
+		{"OggS\x00\x02" + string(make([]byte, 22)) + "OpusHead", ""},
+		{"ID3\x04\x00", "mp3"},
+		{"\xFF\xFB\x90\x00", "mp3"},
+		{"\xFF\xF1\x50\x80", ""}, // ADTS AAC: layer bits 00
+		{"\x00\x00\x00\x20ftypM4A ", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := sniffAudio([]byte(tt.head)); got != tt.want {
+			t.Errorf("sniffAudio(%q) = %q, want %q", tt.head, got, tt.want)
+		}
+	}
+}
+
+// --- decodeNative ---
+
+func TestDecodeNative_FLAC(t *testing.T) {
+	left := []int32{16384, 0, -16384, 8192}
+	right := []int32{16384, 0, 0, -8192}
+	path := writeTempAudio(t, "clip.audio", flacBytes(t, 44100, left, right))
+
+	samples, rate, err := decodeNative(path, 300)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []float32{0.5, 0, -0.25, 0}
+	if rate != 44100 || len(samples) != len(want) {
+		t.Fatalf("decodeNative = %v @ %d", samples, rate)
+	}
+	for i := range want {
+		if samples[i] != want[i] {
+			t.Errorf("samples[%d] = %f, want %f", i, samples[i], want[i])
+		}
+	}
+}
+
+func TestDecodeNative_FLACDurationLimit(t *testing.T) {
+	const maxDurationS = 0.0001 // 4 frames at 44.1 kHz
+	n := make([]int32, 64)
+	path := writeTempAudio(t, "long.flac", flacBytes(t, 44100, n, n))
+	samples, _, err := decodeNative(path, maxDurationS)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if float64(len(samples))/44100 <= maxDurationS {
+		t.Errorf("decoded %d samples; must exceed the duration limit to be rejected", len(samples))
+	}
+}
+
+func TestDecodeNative_WAV(t *testing.T) {
+	path := writeTempAudio(t, "clip.mp3", wavBytes(fmtChunk(wavFormatPCM, 1, 8000, 16), []byte{0, 0x40}, 2))
+	samples, rate, err := decodeNative(path, 300)
+	if err != nil || rate != 8000 || len(samples) != 1 || samples[0] != 0.5 {
+		t.Errorf("decodeNative = %v @ %d, %v", samples, rate, err)
+	}
+}
+
+func TestDecodeNative_NotNative(t *testing.T) {
+	tests := map[string][]byte{
+		"m4a":   []byte("\x00\x00\x00\x20ftypM4A \x00\x00\x00\x00"),
+		"adpcm": wavBytes(fmtChunk(0x0002, 1, 8000, 4), []byte{0, 0}, 2),
+	}
+	for name, data := range tests {
+		_, _, err := decodeNative(writeTempAudio(t, name, data), 300)
+		if !errors.Is(err, errNotNative) {
+			t.Errorf("%s: err = %v, want errNotNative", name, err)
+		}
+	}
+}
+
+func TestDecodeNative_Corrupt(t *testing.T) {
+	tests := map[string][]byte{
+		"mp3":    []byte("ID3\x04\x00\x00\x00\x00\x00\x00garbage"),
+		"flac":   []byte("fLaC\x00\x00"),
+		"vorbis": []byte("OggS\x00\x02" + string(make([]byte, 22)) + "\x01vorbis"),
+	}
+	for name, data := range tests {
+		_, _, err := decodeNative(writeTempAudio(t, name, data), 300)
+		if err == nil || errors.Is(err, errNotNative) {
+			t.Errorf("%s: err = %v, want a decode error", name, err)
+		}
+	}
+}
+
+func TestDecodeNative_Missing(t *testing.T) {
+	if _, _, err := decodeNative("/nonexistent/clip.wav", 300); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
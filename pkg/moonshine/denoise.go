@@ -0,0 +1,36 @@
+package moonshine
+
+import (
+	"time"
+
+	sherpa "github.com/k2-fsa/sherpa-onnx-go/sherpa_onnx"
+)
+
+// initDenoiser loads the GTCRN speech enhancement model that cleans up
+// noisy audio when Options.Denoise is set.
+func (e *Engine) initDenoiser(model string) {
+	c := sherpa.OfflineSpeechDenoiserConfig{}
+	c.Model.Gtcrn.Model = model
+	c.Model.NumThreads = int32(e.cfg.NumThreads)
+	c.Model.Provider = e.Provider()
+
+	t := time.Now()
+	e.denoiser = sherpa.NewOfflineSpeechDenoiser(&c)
+	if e.denoiser == nil {
+		e.logf("WARNING: failed to load denoising model from %s", model)
+		return
+	}
+	e.logf("Denoising model loaded in %.2fs", time.Since(t).Seconds())
+}
+
+// denoise returns 16 kHz samples with background noise suppressed.
+func (e *Engine) denoise(samples []float32) []float32 {
+	e.muDenoise.Lock()
+	defer e.muDenoise.Unlock()
+
+	out := e.denoiser.Run(samples, 16000)
+	if out.SampleRate != 16000 {
+		return resample(out.Samples, out.SampleRate, 16000)
+	}
+	return out.Samples
+}
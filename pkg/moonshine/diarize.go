@@ -0,0 +1,198 @@
+package moonshine
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	sherpa "github.com/k2-fsa/sherpa-onnx-go/sherpa_onnx"
+)
+
+// maxSegmentSamples bounds a single recognizer call, matching the VAD chunk limit.
+const maxSegmentSamples = 25 * 16000
+
+// speakerTurn is a contiguous stretch of audio attributed to one speaker.
+type speakerTurn struct {
+	Start, End float64
+	Speaker    int
+}
+
+// initDiarization loads the pyannote segmentation and speaker embedding models.
+func (e *Engine) initDiarization(segmentationModel, embeddingModel string) {
+	c := sherpa.OfflineSpeakerDiarizationConfig{}
+	c.Segmentation.Pyannote.Model = segmentationModel
+	c.Segmentation.NumThreads = e.cfg.NumThreads
+	c.Segmentation.Provider = e.Provider()
+	c.Embedding.Model = embeddingModel
+	c.Embedding.NumThreads = e.cfg.NumThreads
+	c.Embedding.Provider = e.Provider()
+	c.Clustering.NumClusters = -1
+	c.Clustering.Threshold = float32(e.cfg.DiarizeThreshold)
+	c.MinDurationOn = 0.3
+	c.MinDurationOff = 0.5
+
+	t := time.Now()
+	e.diarizer, e.diarizerCfg = sherpa.NewOfflineSpeakerDiarization(&c), c
+	if e.diarizer == nil {
+		e.logf("WARNING: failed to load diarization models from %s, %s", segmentationModel, embeddingModel)
+		return
+	}
+	e.logf("Diarization models loaded in %.2fs (max_speakers=%d)", time.Since(t).Seconds(), e.cfg.DiarizeMaxSpeakers)
+}
+
+// diarize returns speaker turns for samples sorted by start time. Clustering
+// is threshold-based; if it finds more than maxSpeakers (when > 0) speakers,
+// the audio is re-clustered into exactly maxSpeakers.
+func (e *Engine) diarize(samples []float32, maxSpeakers int) []speakerTurn {
+	e.muDiarize.Lock()
+	defer e.muDiarize.Unlock()
+
+	segs := e.diarizer.Process(samples)
+	if maxSpeakers > 0 && countSpeakers(segs) > maxSpeakers {
+		capped := e.diarizerCfg
+		capped.Clustering.NumClusters = maxSpeakers
+		e.diarizer.SetConfig(&capped)
+		segs = e.diarizer.Process(samples)
+		e.diarizer.SetConfig(&e.diarizerCfg)
+	}
+
+	turns := make([]speakerTurn, len(segs))
+	for i, s := range segs {
+		turns[i] = speakerTurn{Start: float64(s.Start), End: float64(s.End), Speaker: s.Speaker}
+	}
+	sort.Slice(turns, func(i, j int) bool { return turns[i].Start < turns[j].Start })
+	return turns
+}
+
+// countSpeakers returns the number of distinct speakers in segs.
+func countSpeakers(segs []sherpa.OfflineSpeakerDiarizationSegment) int {
+	seen := make(map[int]bool)
+	for _, s := range segs {
+		seen[s.Speaker] = true
+	}
+	return len(seen)
+}
+
+// mergeTurns joins consecutive turns of the same speaker as long as the
+// merged turn stays within maxDurS seconds.
+func mergeTurns(turns []speakerTurn, maxDurS float64) []speakerTurn {
+	var out []speakerTurn
+	for _, t := range turns {
+		if n := len(out); n > 0 && out[n-1].Speaker == t.Speaker && t.End-out[n-1].Start <= maxDurS {
+			out[n-1].End = max(out[n-1].End, t.End)
+			continue
+		}
+		out = append(out, t)
+	}
+	return out
+}
+
+// splitSamples cuts samples into pieces of at most size samples, each
+// starting overlap samples before the previous one ends.
+func splitSamples(samples []float32, size, overlap int) [][]float32 {
+	var pieces [][]float32
+	for len(samples) > size {
+		pieces = append(pieces, samples[:size])
+		samples = samples[size-overlap:]
+	}
+	if len(samples) > 0 {
+		pieces = append(pieces, samples)
+	}
+	return pieces
+}
+
+// speakerLabel formats a zero-based speaker index as a stable label.
+func speakerLabel(speaker int) string {
+	return fmt.Sprintf("SPEAKER_%02d", speaker)
+}
+
+// transcribeDiarized splits samples into speaker turns and recognizes each
+// turn separately, passing each segment to emit when set. Returns the
+// labelled segments and total speech in ms, or ctx.Err() if ctx is done
+// before every turn is recognized.
+func (e *Engine) transcribeDiarized(ctx context.Context, samples []float32, sampleRate int, opts Options, maxSpeakers int, emit func(Segment)) ([]Segment, float64, error) {
+	turns := mergeTurns(e.diarize(samples, maxSpeakers), float64(maxSegmentSamples)/float64(sampleRate))
+
+	// Progress counts turns, reported as the next one starts so skipped
+	// turns count too; the chunks within a turn are not reported.
+	progress := opts.Progress
+	opts.Progress = nil
+
+	var segments []Segment
+	var speechMs float64
+	for i, t := range turns {
+		if progress != nil && i > 0 {
+			progress(i, len(turns))
+		}
+		from := min(max(int(t.Start*float64(sampleRate)), 0), len(samples))
+		to := min(max(int(t.End*float64(sampleRate)), from), len(samples))
+		if from == to {
+			continue
+		}
+		speechMs += float64(to-from) * 1000 / float64(sampleRate)
+
+		overlap := e.chunkOverlap(sampleRate, maxSegmentSamples)
+		ct, err := e.transcribeChunks(ctx, splitSamples(samples[from:to], maxSegmentSamples, overlap), sampleRate, overlap, opts)
+		if err != nil {
+			return nil, 0, err
+		}
+		if ct.Raw == "" {
+			continue
+		}
+		seg := Segment{
+			Start:   t.Start,
+			End:     t.End,
+			Speaker: speakerLabel(t.Speaker),
+			Text:    ct.Text,
+			Emotion: ct.Emotion,
+			Event:   ct.Event,
+		}
+		if ct.Filtered {
+			seg.Filtered, seg.RawText = true, ct.Raw
+		}
+		segments = append(segments, seg)
+		if emit != nil {
+			emit(seg)
+		}
+	}
+	if progress != nil && len(turns) > 0 {
+		progress(len(turns), len(turns))
+	}
+	e.logCall(opts, "Diarization: %d turn(s), %d speaker(s)", len(segments), countSegmentSpeakers(segments))
+	return segments, speechMs, nil
+}
+
+// countSegmentSpeakers returns the number of distinct speaker labels in segments.
+func countSegmentSpeakers(segments []Segment) int {
+	seen := make(map[string]bool)
+	for _, s := range segments {
+		seen[s.Speaker] = true
+	}
+	return len(seen)
+}
+
+// rawSegmentText reports whether any segment was filtered and returns the
+// transcript before filtering. Call it before punctuating segment texts.
+func rawSegmentText(segments []Segment) (bool, string) {
+	filtered := false
+	texts := make([]string, 0, len(segments))
+	for _, s := range segments {
+		if s.Filtered {
+			filtered = true
+			texts = append(texts, s.RawText)
+			continue
+		}
+		texts = append(texts, s.Text)
+	}
+	return filtered, joinTexts(texts)
+}
+
+// joinSegmentText concatenates segment texts into a single transcript.
+func joinSegmentText(segments []Segment) string {
+	texts := make([]string, 0, len(segments))
+	for _, s := range segments {
+		texts = append(texts, s.Text)
+	}
+	return joinTexts(texts)
+}
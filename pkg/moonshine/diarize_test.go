@@ -0,0 +1,99 @@
+package moonshine
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// --- mergeTurns ---
+
+func TestMergeTurns(t *testing.T) {
+	turns := []speakerTurn{
+		{0, 2, 0},
+		{2.5, 4, 0},
+		{4, 6, 1},
+		{6.2, 30, 1},
+		{30, 31, 0},
+	}
+	got := mergeTurns(turns, 25)
+	want := []speakerTurn{
+		{0, 4, 0},
+		{4, 6, 1},
+		{6.2, 30, 1},
+		{30, 31, 0},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("mergeTurns = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("turn %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestMergeTurns_Empty(t *testing.T) {
+	if got := mergeTurns(nil, 25); len(got) != 0 {
+		t.Errorf("mergeTurns(nil) = %+v", got)
+	}
+}
+
+// --- splitSamples ---
+
+func TestSplitSamples(t *testing.T) {
+	tests := []struct {
+		n, size, overlap int
+		want             []int
+	}{
+		{0, 4, 0, nil},
+		{3, 4, 0, []int{3}},
+		{4, 4, 0, []int{4}},
+		{10, 4, 0, []int{4, 4, 2}},
+		{10, 4, 1, []int{4, 4, 4}},
+		{5, 4, 1, []int{4, 2}},
+	}
+	for _, tt := range tests {
+		pieces := splitSamples(make([]float32, tt.n), tt.size, tt.overlap)
+		if len(pieces) != len(tt.want) {
+			t.Errorf("splitSamples(%d, %d, %d) = %d pieces, want %d", tt.n, tt.size, tt.overlap, len(pieces), len(tt.want))
+			continue
+		}
+		for i, p := range pieces {
+			if len(p) != tt.want[i] {
+				t.Errorf("splitSamples(%d, %d, %d) piece %d len = %d, want %d", tt.n, tt.size, tt.overlap, i, len(p), tt.want[i])
+			}
+		}
+	}
+}
+
+// --- speakerLabel / joinSegmentText ---
+
+func TestSpeakerLabel(t *testing.T) {
+	if got := speakerLabel(0); got != "SPEAKER_00" {
+		t.Errorf("speakerLabel(0) = %q", got)
+	}
+	if got := speakerLabel(12); got != "SPEAKER_12" {
+		t.Errorf("speakerLabel(12) = %q", got)
+	}
+}
+
+func TestJoinSegmentText(t *testing.T) {
+	segs := []Segment{{Text: "hello there"}, {Text: "hi"}}
+	if got := joinSegmentText(segs); got != "hello there hi" {
+		t.Errorf("joinSegmentText = %q", got)
+	}
+	if got := joinSegmentText(nil); got != "" {
+		t.Errorf("joinSegmentText(nil) = %q", got)
+	}
+}
+
+// --- Engine.TranscribeSamples with diarize ---
+
+func TestTranscribeSamples_DiarizeWithoutModel(t *testing.T) {
+	_, err := new(Engine).TranscribeSamples(context.Background(), make([]float32, 16000), 16000,
+		Options{Lang: "en", Diarize: true})
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("err = %v, want ErrUnavailable", err)
+	}
+}
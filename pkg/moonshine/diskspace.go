@@ -0,0 +1,43 @@
+package moonshine
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/google/uuid"
+)
+
+// TempPrefix starts the names of the temp files the package writes, so
+// leftovers can be told apart from other files in the temp directory.
+const TempPrefix = "moonshine_"
+
+// CheckFreeSpace fails with ErrNoSpace when the file system holding dir has
+// less than minBytes available. It passes when minBytes is 0 or the free
+// space cannot be read.
+func CheckFreeSpace(dir string, minBytes int64) error {
+	if minBytes <= 0 {
+		return nil
+	}
+	if free, ok := freeBytes(dir); ok && free < minBytes {
+		return errorf(ErrNoSpace, "only %d MB free in %s, need %d MB", free>>20, dir, minBytes>>20)
+	}
+	return nil
+}
+
+// tempDir returns the directory for temp files.
+func (e *Engine) tempDir() string {
+	if e.cfg.TempDir == "" {
+		return os.TempDir()
+	}
+	return e.cfg.TempDir
+}
+
+// tempPath returns a new temp file path with extension ext, failing with
+// ErrNoSpace when the temp directory is short of Config.TempMinFreeMB.
+func (e *Engine) tempPath(ext string) (string, error) {
+	dir := e.tempDir()
+	if err := CheckFreeSpace(dir, int64(e.cfg.TempMinFreeMB)<<20); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, TempPrefix+uuid.New().String()[:8]+ext), nil
+}
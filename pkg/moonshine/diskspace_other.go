@@ -0,0 +1,6 @@
+//go:build !linux && !darwin
+
+package moonshine
+
+// freeBytes cannot read free space on this platform.
+func freeBytes(string) (int64, bool) { return 0, false }
@@ -0,0 +1,40 @@
+package moonshine
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// --- CheckFreeSpace ---
+
+func TestCheckFreeSpace(t *testing.T) {
+	dir := t.TempDir()
+	if err := CheckFreeSpace(dir, 0); err != nil {
+		t.Errorf("no minimum: err = %v", err)
+	}
+	if err := CheckFreeSpace(dir, 1); err != nil {
+		t.Errorf("1 byte: err = %v", err)
+	}
+	if _, ok := freeBytes(dir); !ok {
+		t.Skip("free space not readable on this platform")
+	}
+	if err := CheckFreeSpace(dir, 1<<62); !errors.Is(err, ErrNoSpace) {
+		t.Errorf("huge minimum: err = %v, want ErrNoSpace", err)
+	}
+}
+
+// --- Engine.tempPath ---
+
+func TestTempPath(t *testing.T) {
+	dir := t.TempDir()
+	e := &Engine{cfg: Config{TempDir: dir}}
+	p, err := e.tempPath(".wav")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if filepath.Dir(p) != dir || !strings.HasPrefix(filepath.Base(p), TempPrefix) || filepath.Ext(p) != ".wav" {
+		t.Errorf("path = %q", p)
+	}
+}
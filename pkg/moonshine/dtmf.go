@@ -0,0 +1,125 @@
+package moonshine
+
+import "math"
+
+// DTMFEvent is a key press found in the audio with Options.DTMF.
+type DTMFEvent struct {
+	Start float64 `json:"start"` // seconds from the start of the audio
+	End   float64 `json:"end"`
+	Digit string  `json:"digit"` // 0-9, *, #, or A-D
+}
+
+// DTMF tone frequencies: a key sounds one row and one column tone together.
+var (
+	dtmfRows = [4]float64{697, 770, 852, 941}
+	dtmfCols = [4]float64{1209, 1336, 1477, 1633}
+	dtmfKeys = [4][4]string{
+		{"1", "2", "3", "A"},
+		{"4", "5", "6", "B"},
+		{"7", "8", "9", "C"},
+		{"*", "0", "#", "D"},
+	}
+)
+
+// DTMF detection parameters, for 16 kHz audio.
+const (
+	dtmfFrame     = 400  // 25 ms analysis window, 40 Hz per Goertzel bin
+	dtmfHop       = 160  // 10 ms between windows
+	dtmfMinFrames = 4    // consecutive windows, ~55 ms; ITU tones last >= 40 ms
+	dtmfMinPower  = 1e-4 // mean power of a window, -40 dBFS
+	dtmfToneShare = 0.7  // share of the window's energy in the two tones
+	dtmfPeakRatio = 4.0  // each tone over the runner-up of its group, 6 dB
+	dtmfMaxTwist  = 6.3  // row and column tone within 8 dB of each other
+)
+
+// detectDTMF returns the DTMF key presses in 16 kHz samples. A window
+// counts as a key when one row and one column tone carry most of its
+// energy; speech spreads across many more frequencies. A press is reported
+// once it holds for dtmfMinFrames windows.
+func detectDTMF(samples []float32) []DTMFEvent {
+	var events []DTMFEvent
+	digit, run, first := "", 0, 0
+	flush := func() {
+		if digit != "" && run >= dtmfMinFrames {
+			end := first + (run-1)*dtmfHop + dtmfFrame
+			events = append(events, DTMFEvent{Start: float64(first) / 16000, End: float64(end) / 16000, Digit: digit})
+		}
+	}
+	for off := 0; off+dtmfFrame <= len(samples); off += dtmfHop {
+		d := dtmfDigit(samples[off : off+dtmfFrame])
+		if d == digit && d != "" {
+			run++
+			continue
+		}
+		flush()
+		digit, run, first = d, 1, off
+	}
+	flush()
+	return events
+}
+
+// dtmfDigit returns the key sounding in frame, or "" for none.
+func dtmfDigit(frame []float32) string {
+	var energy float64
+	for _, s := range frame {
+		energy += float64(s) * float64(s)
+	}
+	if energy/float64(len(frame)) < dtmfMinPower {
+		return ""
+	}
+	row, rowPower, ok := dtmfPeak(frame, dtmfRows)
+	if !ok {
+		return ""
+	}
+	col, colPower, ok := dtmfPeak(frame, dtmfCols)
+	if !ok {
+		return ""
+	}
+	if rowPower+colPower < dtmfToneShare*energy ||
+		rowPower > dtmfMaxTwist*colPower || colPower > dtmfMaxTwist*rowPower {
+		return ""
+	}
+	return dtmfKeys[row][col]
+}
+
+// dtmfPeak returns the strongest of freqs in frame and its energy, and
+// whether it stands out from the others by dtmfPeakRatio.
+func dtmfPeak(frame []float32, freqs [4]float64) (int, float64, bool) {
+	var powers [4]float64
+	for i, f := range freqs {
+		powers[i] = goertzel(frame, f)
+	}
+	peak := 0
+	for i := range powers {
+		if powers[i] > powers[peak] {
+			peak = i
+		}
+	}
+	for i, p := range powers {
+		if i != peak && p*dtmfPeakRatio > powers[peak] {
+			return 0, 0, false
+		}
+	}
+	return peak, powers[peak], true
+}
+
+// goertzel returns the energy of frame at freq Hz (16 kHz audio), scaled
+// so a pure tone at freq has the energy of the frame.
+func goertzel(frame []float32, freq float64) float64 {
+	coeff := 2 * math.Cos(2*math.Pi*freq/16000)
+	var s1, s2 float64
+	for _, x := range frame {
+		s1, s2 = float64(x)+coeff*s1-s2, s1
+	}
+	return (s1*s1 + s2*s2 - coeff*s1*s2) * 2 / float64(len(frame))
+}
+
+// muteDTMF returns samples with the key presses of events silenced, so the
+// recognizer does not hear the tones as words.
+func muteDTMF(samples []float32, events []DTMFEvent) []float32 {
+	spans := make([]Span, len(events))
+	for i, ev := range events {
+		spans[i] = Span{Start: ev.Start, End: ev.End}
+	}
+	return muteSpans(samples, spans)
+}
@@ -0,0 +1,82 @@
+package moonshine
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// dtmfTone synthesizes key at 16 kHz for seconds, both tones at -12 dBFS.
+func dtmfTone(key string, seconds float64) []float32 {
+	var row, col float64
+	for r := range dtmfKeys {
+		for c := range dtmfKeys[r] {
+			if dtmfKeys[r][c] == key {
+				row, col = dtmfRows[r], dtmfCols[c]
+			}
+		}
+	}
+	s := make([]float32, int(seconds*16000))
+	for i := range s {
+		t := float64(i) / 16000
+		s[i] = float32(0.25*math.Sin(2*math.Pi*row*t) + 0.25*math.Sin(2*math.Pi*col*t))
+	}
+	return s
+}
+
+// --- detectDTMF ---
+
+func TestDetectDTMF(t *testing.T) {
+	gap := make([]float32, 1600) // 100 ms
+	noise := make([]float32, 8000)
+	rng := rand.New(rand.NewSource(1))
+	for i := range noise {
+		noise[i] = float32(rng.NormFloat64() * 0.2)
+	}
+	tests := []struct {
+		name    string
+		samples []float32
+		want    string
+	}{
+		{"keys", concat(gap, dtmfTone("1", 0.1), gap, dtmfTone("#", 0.1), gap, dtmfTone("0", 0.1)), "1#0"},
+		{"repeated key", concat(dtmfTone("5", 0.08), gap, dtmfTone("5", 0.08)), "55"},
+		{"too short", concat(gap, dtmfTone("9", 0.02), gap), ""},
+		{"noise", noise, ""},
+		{"silence", gap, ""},
+		{"plain tone", sine(3200, 0.5), ""},
+	}
+	for _, tt := range tests {
+		var got string
+		for _, ev := range detectDTMF(tt.samples) {
+			got += ev.Digit
+		}
+		if got != tt.want {
+			t.Errorf("%s: digits = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDetectDTMF_Times(t *testing.T) {
+	samples := concat(make([]float32, 16000), dtmfTone("7", 0.1), make([]float32, 8000))
+	events := detectDTMF(samples)
+	if len(events) != 1 || events[0].Digit != "7" {
+		t.Fatalf("events = %+v, want one 7", events)
+	}
+	if ev := events[0]; math.Abs(ev.Start-1) > 0.03 || math.Abs(ev.End-1.1) > 0.03 {
+		t.Errorf("event = %+v, want about 1.0-1.1s", ev)
+	}
+}
+
+// --- muteDTMF ---
+
+func TestMuteDTMF(t *testing.T) {
+	samples := dtmfTone("3", 0.2)
+	events := []DTMFEvent{{Start: 0.05, End: 0.15, Digit: "3"}}
+	got := muteDTMF(samples, events)
+	if got[800] != 0 || got[2399] != 0 || got[2400] == 0 || got[799] == 0 {
+		t.Errorf("muted span wrong: %v %v %v %v", got[799], got[800], got[2399], got[2400])
+	}
+	if samples[800] == 0 {
+		t.Error("muteDTMF changed its input")
+	}
+}
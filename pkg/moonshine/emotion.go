@@ -0,0 +1,140 @@
+package moonshine
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	sherpa "github.com/k2-fsa/sherpa-onnx-go/sherpa_onnx"
+)
+
+// emotionWindowSamples is the length of the windows emotion is scored over;
+// SenseVoice names one emotion per decoded utterance.
+const emotionWindowSamples = 5 * 16000
+
+// EmotionScore is an emotion heard in a stretch of audio with the share of
+// that audio it was heard in.
+type EmotionScore struct {
+	Label string  `json:"label"` // neutral, happy, sad, angry, fearful, disgusted, or surprised
+	Score float64 `json:"score"`
+}
+
+// initEmotion loads the SenseVoice model in dir, whose emotion tags label
+// audio when Options.Emotions is set.
+func (e *Engine) initEmotion(dir string) {
+	c := sherpa.OfflineRecognizerConfig{}
+	c.FeatConfig.SampleRate = 16000
+	c.FeatConfig.FeatureDim = 80
+	c.ModelConfig.SenseVoice.Model = e.onnxFile(dir, "model")
+	c.ModelConfig.Tokens = filepath.Join(dir, "tokens.txt")
+	c.ModelConfig.NumThreads = e.cfg.NumThreads
+	c.ModelConfig.Provider = e.Provider()
+	c.DecodingMethod = GreedySearch
+	if err := requireFiles(c.ModelConfig.SenseVoice.Model, c.ModelConfig.Tokens); err != nil {
+		e.logf("Emotion model not found in %s, emotion recognition disabled", dir)
+		return
+	}
+
+	t := time.Now()
+	e.emotion = sherpa.NewOfflineRecognizer(&c)
+	if e.emotion == nil {
+		e.logf("WARNING: failed to load emotion model from %s", dir)
+		return
+	}
+	e.logf("Emotion model loaded in %.2fs", time.Since(t).Seconds())
+}
+
+// addEmotions labels each segment of res, or all of samples (16 kHz) when
+// there are none, with its emotions, and res with those of the whole audio.
+func (e *Engine) addEmotions(res *Result, samples []float32) {
+	if len(res.Segments) == 0 {
+		res.Emotions = e.emotions(samples)
+		return
+	}
+	scores := make([][]EmotionScore, len(res.Segments))
+	lengths := make([]float64, len(res.Segments))
+	for i := range res.Segments {
+		seg := &res.Segments[i]
+		from := min(max(int(seg.Start*16000), 0), len(samples))
+		to := min(max(int(seg.End*16000), from), len(samples))
+		seg.Emotions = e.emotions(samples[from:to])
+		scores[i], lengths[i] = seg.Emotions, seg.End-seg.Start
+	}
+	res.Emotions = mergeEmotions(scores, lengths)
+}
+
+// emotions scores the emotions of 16 kHz samples, most frequent first. The
+// model labels each 5-second window; a label's score is the share of the
+// windows it was given, weighted by their length. Windows the model cannot
+// label are left out.
+func (e *Engine) emotions(samples []float32) []EmotionScore {
+	weights := make(map[string]int)
+	for len(samples) > 0 {
+		n := min(len(samples), emotionWindowSamples)
+		if rest := len(samples) - n; rest > 0 && rest < emotionWindowSamples/5 {
+			n = len(samples) // fold a short tail into the last window
+		}
+		if label := e.emotionLabel(samples[:n]); label != "" {
+			weights[label] += n
+		}
+		samples = samples[n:]
+	}
+	return emotionShares(weights)
+}
+
+// emotionLabel returns the emotion the model tags one window with, or "".
+func (e *Engine) emotionLabel(samples []float32) string {
+	e.muEmotion.Lock()
+	defer e.muEmotion.Unlock()
+
+	s := sherpa.NewOfflineStream(e.emotion)
+	defer sherpa.DeleteOfflineStream(s)
+	s.AcceptWaveform(16000, samples)
+	e.emotion.Decode(s)
+	return emotionName(s.GetResult().Emotion)
+}
+
+// emotionName turns a SenseVoice emotion tag such as "<|HAPPY|>" into a
+// label, or "" for unknown emotion.
+func emotionName(tag string) string {
+	label := strings.ToLower(strings.Trim(tag, "<|>"))
+	if label == "" || label == "emo_unknown" || label == "unk" {
+		return ""
+	}
+	return label
+}
+
+// mergeEmotions combines emotion scores of stretches of audio weighted by
+// the stretches' lengths.
+func mergeEmotions(scores [][]EmotionScore, lengths []float64) []EmotionScore {
+	weights := make(map[string]float64)
+	for i, list := range scores {
+		for _, s := range list {
+			weights[s.Label] += s.Score * lengths[i]
+		}
+	}
+	return emotionShares(weights)
+}
+
+// emotionShares normalizes label weights to shares, highest first.
+func emotionShares[W int | float64](weights map[string]W) []EmotionScore {
+	var total float64
+	for _, w := range weights {
+		total += float64(w)
+	}
+	if total == 0 {
+		return nil
+	}
+	scores := make([]EmotionScore, 0, len(weights))
+	for label, w := range weights {
+		scores = append(scores, EmotionScore{Label: label, Score: float64(w) / total})
+	}
+	sort.Slice(scores, func(i, j int) bool {
+		if scores[i].Score != scores[j].Score {
+			return scores[i].Score > scores[j].Score
+		}
+		return scores[i].Label < scores[j].Label
+	})
+	return scores
+}
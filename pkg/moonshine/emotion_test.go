@@ -0,0 +1,53 @@
+package moonshine
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// --- emotionName ---
+
+func TestEmotionName(t *testing.T) {
+	tests := map[string]string{
+		"<|HAPPY|>":       "happy",
+		"<|NEUTRAL|>":     "neutral",
+		"ANGRY":           "angry",
+		"<|EMO_UNKNOWN|>": "",
+		"":                "",
+	}
+	for tag, want := range tests {
+		if got := emotionName(tag); got != want {
+			t.Errorf("emotionName(%q) = %q, want %q", tag, got, want)
+		}
+	}
+}
+
+// --- mergeEmotions ---
+
+func TestMergeEmotions(t *testing.T) {
+	got := mergeEmotions([][]EmotionScore{
+		{{Label: "angry", Score: 1}},
+		{{Label: "neutral", Score: 0.5}, {Label: "happy", Score: 0.5}},
+		nil,
+	}, []float64{1, 4, 5})
+	// Highest first, ties by label.
+	want := []EmotionScore{{Label: "happy", Score: 2.0 / 5}, {Label: "neutral", Score: 2.0 / 5}, {Label: "angry", Score: 1.0 / 5}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mergeEmotions = %+v, want %+v", got, want)
+	}
+	if got := mergeEmotions(nil, nil); got != nil {
+		t.Errorf("mergeEmotions(nil) = %+v, want nil", got)
+	}
+}
+
+// --- Engine.TranscribeSamples ---
+
+func TestTranscribeSamples_EmotionsUnavailable(t *testing.T) {
+	e := &Engine{pools: map[string]*recognizerPool{"en": {}}}
+	_, err := e.TranscribeSamples(context.Background(), make([]float32, 16000), 16000, Options{Emotions: true})
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Emotions without model: err = %v, want ErrUnavailable", err)
+	}
+}
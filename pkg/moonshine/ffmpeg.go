@@ -0,0 +1,119 @@
+package moonshine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ffmpegPath returns the ffmpeg binary to run.
+func (e *Engine) ffmpegPath() string {
+	if e.cfg.FFmpegPath == "" {
+		return "ffmpeg"
+	}
+	return e.cfg.FFmpegPath
+}
+
+// ffprobePath returns the ffprobe binary to run.
+func (e *Engine) ffprobePath() string {
+	if e.cfg.FFprobePath == "" {
+		return "ffprobe"
+	}
+	return e.cfg.FFprobePath
+}
+
+// HasFFmpeg reports whether the ffmpeg binary is found.
+func (e *Engine) HasFFmpeg() bool {
+	_, err := exec.LookPath(e.ffmpegPath())
+	return err == nil
+}
+
+// runTool runs bin, ffmpeg or ffprobe, with args once one of the
+// Config.FFmpegMaxProcs process slots is free, and returns its standard
+// output. A missing binary fails with ErrUnavailable and a run over
+// Config.FFmpegTimeout with ErrConversion; other errors carry the tool's
+// standard error. The process is killed once ctx is done.
+func (e *Engine) runTool(ctx context.Context, bin string, args ...string) ([]byte, error) {
+	name := filepath.Base(bin)
+	if _, err := exec.LookPath(bin); err != nil {
+		return nil, errorf(ErrUnavailable, "%s not found at %q; install it or set its path", name, bin)
+	}
+	if e.ffmpegSlots != nil {
+		select {
+		case e.ffmpegSlots <- struct{}{}:
+			defer func() { <-e.ffmpegSlots }()
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	runCtx := ctx
+	if e.cfg.FFmpegTimeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, e.cfg.FFmpegTimeout)
+		defer cancel()
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(runCtx, bin, args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err == nil {
+		return out, nil
+	}
+	if ctx.Err() == nil && errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+		return nil, errorf(ErrConversion, "%s timed out after %s", name, e.cfg.FFmpegTimeout)
+	}
+	return nil, fmt.Errorf("%s: %v %s", name, err, strings.TrimSpace(stderr.String()))
+}
+
+// ensureWav converts audio track track (0-based, see Options.AudioTrack) of
+// audioPath to 16kHz WAV unless it is the first of a WAV file, downmixed to
+// mono unless keepChannels is set. Returns the WAV path and an
+// optional cleanup path to remove after use. Config.FFmpegArgs go before
+// the input. The WAV is written to Config.TempDir. With maxDurationS > 0
+// conversion stops a second past it, so oversized files fail the duration
+// check without being converted in full. With Config.ProbeInput the file is
+// checked with ffprobe first (see checkInput).
+func (e *Engine) ensureWav(ctx context.Context, audioPath string, track int, keepChannels bool, maxDurationS float64) (wavPath, cleanupPath string, err error) {
+	if ext := strings.ToLower(filepath.Ext(audioPath)); ext == ".wav" && track == 0 {
+		return audioPath, "", nil
+	}
+	if e.cfg.ProbeInput {
+		if err := e.checkInput(ctx, audioPath, track, maxDurationS); err != nil {
+			return "", "", err
+		}
+	}
+	if wavPath, err = e.tempPath(".wav"); err != nil {
+		return "", "", err
+	}
+	args := slices.Concat(e.cfg.FFmpegArgs, []string{"-i", audioPath, "-map", "0:a:" + strconv.Itoa(track), "-ar", "16000"})
+	if !keepChannels {
+		args = append(args, "-ac", "1")
+	}
+	if maxDurationS > 0 {
+		args = append(args, "-t", strconv.FormatFloat(maxDurationS+1, 'f', -1, 64))
+	}
+	args = append(args, "-f", "wav", wavPath, "-y", "-loglevel", "error")
+	if _, err := e.runTool(ctx, e.ffmpegPath(), args...); err != nil {
+		os.Remove(wavPath) //nolint:errcheck
+		return "", "", err
+	}
+	return wavPath, wavPath, nil
+}
+
+// conversionError returns err as an ErrConversion error unless it already
+// has a kind.
+func conversionError(err error) error {
+	var ke *kindError
+	if errors.As(err, &ke) {
+		return err
+	}
+	return errorf(ErrConversion, "%v", err)
+}
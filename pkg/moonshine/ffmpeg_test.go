@@ -0,0 +1,95 @@
+package moonshine
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+// --- Engine.ensureWav ---
+
+func TestEnsureWav_AlreadyWav(t *testing.T) {
+	wavPath, cleanup, err := new(Engine).ensureWav(context.Background(), "/tmp/test.wav", 0, false, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if wavPath != "/tmp/test.wav" {
+		t.Errorf("wavPath = %q, want %q", wavPath, "/tmp/test.wav")
+	}
+	if cleanup != "" {
+		t.Errorf("cleanup should be empty for .wav, got %q", cleanup)
+	}
+}
+
+func TestEnsureWav_UppercaseWav(t *testing.T) {
+	wavPath, cleanup, err := new(Engine).ensureWav(context.Background(), "/tmp/test.WAV", 0, false, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if wavPath != "/tmp/test.WAV" {
+		t.Errorf("wavPath = %q, want passthrough for .WAV", wavPath)
+	}
+	if cleanup != "" {
+		t.Errorf("cleanup should be empty for .WAV")
+	}
+}
+
+func TestEnsureWav_NonExistentMp3(t *testing.T) {
+	// Non-existent file: ffmpeg should fail.
+	_, _, err := new(Engine).ensureWav(context.Background(), "/tmp/nonexistent_12345.mp3", 0, false, 0)
+	if err == nil {
+		t.Error("expected error for non-existent mp3 file")
+	}
+}
+
+func TestEnsureWav_MissingBinary(t *testing.T) {
+	e := &Engine{cfg: Config{FFmpegPath: "/nonexistent/ffmpeg"}}
+	_, _, err := e.ensureWav(context.Background(), "/tmp/test.mp3", 0, false, 0)
+	if !errors.Is(err, ErrUnavailable) || !strings.Contains(err.Error(), `"/nonexistent/ffmpeg"`) {
+		t.Errorf("err = %v, want ErrUnavailable naming the path", err)
+	}
+}
+
+// --- Engine.runTool ---
+
+func TestRunTool(t *testing.T) {
+	sleep, err := exec.LookPath("sleep")
+	if err != nil {
+		t.Skip("sleep not installed")
+	}
+
+	e := &Engine{cfg: Config{FFmpegTimeout: 50 * time.Millisecond}}
+	if _, err := e.runTool(context.Background(), sleep, "5"); !errors.Is(err, ErrConversion) || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("timeout: err = %v, want ErrConversion timed out", err)
+	}
+
+	// With the only slot taken, a call waits until its context is done.
+	e = &Engine{ffmpegSlots: make(chan struct{}, 1)}
+	e.ffmpegSlots <- struct{}{}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := e.runTool(ctx, sleep, "0"); err != context.DeadlineExceeded {
+		t.Errorf("full slots: err = %v, want context.DeadlineExceeded", err)
+	}
+	<-e.ffmpegSlots
+	if _, err := e.runTool(context.Background(), sleep, "0"); err != nil {
+		t.Errorf("free slot: err = %v", err)
+	}
+	if len(e.ffmpegSlots) != 0 {
+		t.Error("slot not released")
+	}
+}
+
+// --- conversionError ---
+
+func TestConversionError(t *testing.T) {
+	if err := conversionError(errors.New("ffmpeg: exit status 1")); !errors.Is(err, ErrConversion) {
+		t.Errorf("plain error = %v, want ErrConversion", err)
+	}
+	if err := conversionError(errorf(ErrUnavailable, "ffmpeg not found")); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("kind error = %v, want ErrUnavailable kept", err)
+	}
+}
@@ -0,0 +1,49 @@
+package moonshine
+
+// G.711 companded telephony audio: 8-bit μ-law (North America, Japan) and
+// A-law (Europe), normally at 8 kHz.
+
+var (
+	ulawTable = g711Table(ulawToLinear)
+	alawTable = g711Table(alawToLinear)
+)
+
+// DecodeULaw expands a G.711 μ-law byte to a sample in [-1, 1].
+func DecodeULaw(b byte) float32 { return ulawTable[b] }
+
+// DecodeALaw expands a G.711 A-law byte to a sample in [-1, 1].
+func DecodeALaw(b byte) float32 { return alawTable[b] }
+
+// g711Table precomputes the 16-bit expansion of every code.
+func g711Table(expand func(byte) int) [256]float32 {
+	var t [256]float32
+	for i := range t {
+		t[i] = float32(expand(byte(i))) / 32768.0
+	}
+	return t
+}
+
+// ulawToLinear expands a μ-law code to 16-bit linear PCM.
+func ulawToLinear(b byte) int {
+	u := ^b
+	exp := int(u>>4) & 7
+	mag := ((int(u&0x0F) << 3) + 0x84) << exp
+	if u&0x80 != 0 {
+		return 0x84 - mag
+	}
+	return mag - 0x84
+}
+
+// alawToLinear expands an A-law code to 16-bit linear PCM.
+func alawToLinear(b byte) int {
+	a := b ^ 0x55
+	exp := int(a>>4) & 7
+	mag := int(a&0x0F)<<4 + 8
+	if exp > 0 {
+		mag = (mag + 0x100) << (exp - 1)
+	}
+	if a&0x80 != 0 {
+		return mag
+	}
+	return -mag
+}
@@ -0,0 +1,44 @@
+package moonshine
+
+import "testing"
+
+// --- DecodeULaw / DecodeALaw ---
+
+func TestDecodeULaw(t *testing.T) {
+	tests := []struct {
+		code byte
+		want int
+	}{
+		{0xFF, 0}, {0x7F, 0}, {0x80, 32124}, {0x00, -32124}, {0xFE, 8}, {0x7E, -8},
+	}
+	for _, tt := range tests {
+		if got := DecodeULaw(tt.code); got != float32(tt.want)/32768 {
+			t.Errorf("DecodeULaw(0x%02x) = %v, want %d/32768", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeALaw(t *testing.T) {
+	tests := []struct {
+		code byte
+		want int
+	}{
+		{0xD5, 8}, {0x55, -8}, {0xAA, 32256}, {0x2A, -32256}, {0xC5, 264},
+	}
+	for _, tt := range tests {
+		if got := DecodeALaw(tt.code); got != float32(tt.want)/32768 {
+			t.Errorf("DecodeALaw(0x%02x) = %v, want %d/32768", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestG711_Symmetric(t *testing.T) {
+	for i := range 128 {
+		if u := DecodeULaw(byte(i)); u != -DecodeULaw(byte(i)|0x80) {
+			t.Errorf("μ-law 0x%02x and 0x%02x are not symmetric", i, i|0x80)
+		}
+		if a := DecodeALaw(byte(i)); a != -DecodeALaw(byte(i)|0x80) {
+			t.Errorf("A-law 0x%02x and 0x%02x are not symmetric", i, i|0x80)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package moonshine
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// maxRepeatNgram is the longest phrase (in words) checked for looping.
+const maxRepeatNgram = 4
+
+// defaultHallucinationPhrases are outputs models produce on silence or noise,
+// learned from subtitle credits in their training data.
+var defaultHallucinationPhrases = []string{
+	"thank you for watching",
+	"thanks for watching",
+	"please subscribe",
+	"subtitles by the amara org community",
+	"продолжение следует",
+	"спасибо за просмотр",
+	"субтитры сделал dimatorzok",
+	"редактор субтитров а семкин корректор а егорова",
+}
+
+// hallucinationFilter decides which recognized chunks are suppressed.
+type hallucinationFilter struct {
+	maxRatio   float64         // zlib compression ratio limit, 0=off
+	maxRepeats int             // back-to-back n-gram repeat limit, 0=off
+	blocklist  map[string]bool // normalized phrases
+}
+
+// newHallucinationFilter returns a filter with the default blocklist.
+func newHallucinationFilter(maxRatio float64, maxRepeats int) hallucinationFilter {
+	return hallucinationFilter{maxRatio: maxRatio, maxRepeats: maxRepeats, blocklist: phraseSet(defaultHallucinationPhrases)}
+}
+
+// chunkText is the recognition result of one chunk (or a joined run of chunks).
+type chunkText struct {
+	Text     string // "" when filtered
+	Raw      string // recognizer output before filtering
+	Filtered bool
+	chunkTags
+}
+
+// result exports t as a ChunkResult.
+func (t chunkText) result() ChunkResult {
+	return ChunkResult{Text: t.Text, Raw: t.Raw, Filtered: t.Filtered, Emotion: t.Emotion, Event: t.Event}
+}
+
+// chunkTextOf is the chunkText of an exported ChunkResult.
+func chunkTextOf(c ChunkResult) chunkText {
+	return chunkText{Text: c.Text, Raw: c.Raw, Filtered: c.Filtered, chunkTags: chunkTags{Emotion: c.Emotion, Event: c.Event}}
+}
+
+// chunkTags are the emotion and audio event SenseVoice tags a chunk with,
+// as labels; empty for other backends.
+type chunkTags struct {
+	Emotion string
+	Event   string
+}
+
+// joinChunkTexts joins texts into one result, filtered if any part was,
+// with the tags of the first part that has any.
+func joinChunkTexts(texts []chunkText) chunkText {
+	var out chunkText
+	var kept, raw []string
+	for _, t := range texts {
+		kept = append(kept, t.Text)
+		raw = append(raw, t.Raw)
+		out.Filtered = out.Filtered || t.Filtered
+		if out.chunkTags == (chunkTags{}) {
+			out.chunkTags = t.chunkTags
+		}
+	}
+	out.Text, out.Raw = joinTexts(kept), joinTexts(raw)
+	return out
+}
+
+// reason returns why text looks hallucinated, or "" if it passes the
+// compression-ratio, repetition, and blocklist checks.
+func (f hallucinationFilter) reason(text string) string {
+	if limit := f.maxRatio; limit > 0 {
+		if ratio := compressionRatio(text); ratio > limit {
+			return fmt.Sprintf("compression ratio %.2f > %g", ratio, limit)
+		}
+	}
+	if limit := f.maxRepeats; limit > 0 {
+		if n, gram := longestRepeat(text); n > limit {
+			return fmt.Sprintf("%q repeated %d times", gram, n)
+		}
+	}
+	if f.blocklist[normalizePhrase(text)] {
+		return "blocklisted phrase"
+	}
+	return ""
+}
+
+// longestRepeat finds the phrase of up to maxRepeatNgram words that repeats
+// back-to-back the most times in text, returning the count and the phrase.
+func longestRepeat(text string) (int, string) {
+	words := strings.Fields(strings.ToLower(text))
+	best, bestGram := 0, ""
+	for n := 1; n <= maxRepeatNgram; n++ {
+		for i := 0; i+n <= len(words); i++ {
+			count := 1
+			for j := i + n; j+n <= len(words) && equalWords(words[i:i+n], words[j:j+n]); j += n {
+				count++
+			}
+			if count > best {
+				best, bestGram = count, strings.Join(words[i:i+n], " ")
+			}
+		}
+	}
+	return best, bestGram
+}
+
+// equalWords reports whether a and b hold the same words.
+func equalWords(a, b []string) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizePhrase lowercases s, turns punctuation into spaces, and collapses
+// whitespace so "Thank you for watching!" matches "thank you for watching".
+func normalizePhrase(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return ' '
+	}, s)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// phraseSet builds a normalized lookup set from phrases.
+func phraseSet(phrases []string) map[string]bool {
+	set := make(map[string]bool, len(phrases))
+	for _, p := range phrases {
+		if p = normalizePhrase(p); p != "" {
+			set[p] = true
+		}
+	}
+	return set
+}
+
+// LoadBlocklist adds the phrases in path, one per line, to the hallucination
+// blocklist and returns how many were read. Blank lines and lines starting
+// with # are skipped. Call it before transcribing.
+func (e *Engine) LoadBlocklist(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close() //nolint:errcheck
+
+	n := 0
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if p := normalizePhrase(line); p != "" {
+			if e.filter.blocklist == nil {
+				e.filter.blocklist = make(map[string]bool)
+			}
+			e.filter.blocklist[p] = true
+			n++
+		}
+	}
+	return n, sc.Err()
+}
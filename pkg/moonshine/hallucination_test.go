@@ -0,0 +1,116 @@
+package moonshine
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// --- hallucinationFilter.reason ---
+
+func TestHallucinationFilter(t *testing.T) {
+	f := newHallucinationFilter(2.4, 5)
+	tests := []struct {
+		name string
+		text string
+		want string // substring of the reason, "" = not filtered
+	}{
+		{"normal speech", "the quarterly numbers look better than expected", ""},
+		{"short repeat", "no no no", ""},
+		{"looping word", "yes yes yes yes yes yes yes", "repeated 7 times"},
+		{"looping phrase", "I mean I mean I mean I mean I mean I mean", `"i mean" repeated 6 times`},
+		{"blocklisted", "Thank you for watching!", "blocklist"},
+		{"blocklisted ru", "Продолжение следует...", "blocklist"},
+		{"blocklist needs whole chunk", "thank you for watching the demo today", ""},
+		{"compression", strings.Repeat("the cat sat on the mat and then ", 6), "compression ratio"},
+	}
+	for _, tt := range tests {
+		got := f.reason(tt.text)
+		if (tt.want == "") != (got == "") || !strings.Contains(got, tt.want) {
+			t.Errorf("%s: reason = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHallucinationFilter_Disabled(t *testing.T) {
+	f := newHallucinationFilter(0, 0)
+	if got := f.reason(strings.Repeat("yes ", 20)); got != "" {
+		t.Errorf("checks disabled: reason = %q, want none", got)
+	}
+}
+
+// --- longestRepeat ---
+
+func TestLongestRepeat(t *testing.T) {
+	tests := []struct {
+		text     string
+		wantN    int
+		wantGram string
+	}{
+		{"", 0, ""},
+		{"hello", 1, "hello"},
+		{"a b a b a b c", 3, "a b"},
+		{"go Go GO stop", 3, "go"},
+	}
+	for _, tt := range tests {
+		n, gram := longestRepeat(tt.text)
+		if n != tt.wantN || gram != tt.wantGram {
+			t.Errorf("longestRepeat(%q) = %d %q, want %d %q", tt.text, n, gram, tt.wantN, tt.wantGram)
+		}
+	}
+}
+
+// --- normalizePhrase ---
+
+func TestNormalizePhrase(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"Thank you for watching!", "thank you for watching"},
+		{"  Редактор субтитров А.Семкин  ", "редактор субтитров а семкин"},
+		{"...", ""},
+	}
+	for _, tt := range tests {
+		if got := normalizePhrase(tt.in); got != tt.want {
+			t.Errorf("normalizePhrase(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// --- joinChunkTexts ---
+
+func TestJoinChunkTexts(t *testing.T) {
+	got := joinChunkTexts([]chunkText{
+		{Text: "first", Raw: "first"},
+		{Raw: "la la la", Filtered: true, chunkTags: chunkTags{Event: "bgm"}},
+		{Text: "last", Raw: "last", chunkTags: chunkTags{Emotion: "happy"}},
+	})
+	if got.Text != "first last" || got.Raw != "first la la la last" || !got.Filtered {
+		t.Errorf("joinChunkTexts = %+v", got)
+	}
+	if got.Event != "bgm" || got.Emotion != "" {
+		t.Errorf("joinChunkTexts tags = %+v, want those of the first tagged part", got.chunkTags)
+	}
+}
+
+// --- Engine.LoadBlocklist ---
+
+func TestLoadBlocklist(t *testing.T) {
+	e := &Engine{filter: newHallucinationFilter(0, 0)}
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	body := "# known junk\n\nSee you next time.\n  Like and subscribe  \n"
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	n, err := e.LoadBlocklist(path)
+	if err != nil || n != 2 {
+		t.Fatalf("LoadBlocklist = %d, %v; want 2 phrases", n, err)
+	}
+	for _, p := range []string{"see you next time", "like and subscribe", "thanks for watching"} {
+		if !e.filter.blocklist[p] {
+			t.Errorf("blocklist missing %q", p)
+		}
+	}
+	if _, err := e.LoadBlocklist(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
@@ -0,0 +1,321 @@
+package moonshine
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Inverse text normalization (ITN) rewrites the spoken forms recognizers
+// emit in written form: "twenty five dollars" as "$25", "march fifth" as
+// "March 5", "пятое марта" as "5 марта". It is rule based, with a rule set
+// per language; text in other languages is left as is.
+
+// itnRule matches spoken words at the start of words, which are lowercased
+// and hold no punctuation, and returns their written form and how many words
+// it replaces, or n=0. An empty written form with n>0 keeps the n words as
+// they are.
+type itnRule func(words []string) (written string, n int)
+
+// itnRules are the rules of each language, tried in order at every word.
+var itnRules = map[string][]itnRule{
+	"en": {enDate, enTime, enMoney, enPercent, enOrdinal, enCardinal},
+	"ru": {ruDate, ruYear, ruTime, ruMoney, ruPercent, ruCardinal},
+}
+
+// itnToken is a word of the text being normalized with the punctuation
+// around it split off.
+type itnToken struct {
+	sep       string // separator before the token: " ", or "-" inside hyphenated words
+	pre, post string // punctuation before and after the word
+	orig      string // the word as written
+	word      string // lowercased with ё as е, for matching
+}
+
+// normalizeText applies the ITN rules of lang to text. A rewrite never spans
+// punctuation, so "twenty, five" stays two numbers.
+func normalizeText(text, lang string) string {
+	rules := itnRules[lang]
+	if len(rules) == 0 || text == "" {
+		return text
+	}
+	toks := itnTokenize(text)
+	words := make([]string, len(toks))
+	for i, t := range toks {
+		words[i] = t.word
+	}
+	// runEnd[i] is the end of the run of words from i without punctuation.
+	runEnd := make([]int, len(toks))
+	for i := len(toks) - 1; i >= 0; i-- {
+		runEnd[i] = i + 1
+		if i+1 < len(toks) && toks[i].post == "" && toks[i+1].pre == "" && toks[i+1].word != "" {
+			runEnd[i] = runEnd[i+1]
+		}
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(toks); {
+		if i > 0 {
+			b.WriteString(toks[i].sep)
+		}
+		written, n := "", 0
+		if words[i] != "" {
+			written, n = applyITN(rules, words[i:runEnd[i]])
+		}
+		if written == "" {
+			for k := i; k < i+max(n, 1); k++ {
+				if k > i {
+					b.WriteString(toks[k].sep)
+				}
+				b.WriteString(toks[k].pre + toks[k].orig + toks[k].post)
+			}
+			i += max(n, 1)
+			continue
+		}
+		b.WriteString(toks[i].pre + written + toks[i+n-1].post)
+		i += n
+	}
+	return b.String()
+}
+
+// applyITN returns the rewrite of the first rule matching at words.
+func applyITN(rules []itnRule, words []string) (string, int) {
+	for _, rule := range rules {
+		if written, n := rule(words); n > 0 {
+			return written, n
+		}
+	}
+	return "", 0
+}
+
+// itnTokenize splits text into words at whitespace and hyphens.
+func itnTokenize(text string) []itnToken {
+	var toks []itnToken
+	for _, field := range strings.Fields(text) {
+		start := strings.IndexFunc(field, isWordRune)
+		if start < 0 { // punctuation only
+			toks = append(toks, itnToken{sep: " ", pre: field})
+			continue
+		}
+		end := strings.LastIndexFunc(field, isWordRune)
+		_, size := utf8.DecodeRuneInString(field[end:])
+		end += size
+		pre, core, post := field[:start], field[start:end], field[end:]
+
+		parts := strings.Split(core, "-")
+		for _, p := range parts {
+			if p == "" {
+				parts = []string{core}
+				break
+			}
+		}
+		for i, p := range parts {
+			t := itnToken{sep: "-", orig: p, word: strings.ReplaceAll(strings.ToLower(p), "ё", "е")}
+			t.word = strings.ReplaceAll(t.word, "’", "'")
+			if i == 0 {
+				t.sep, t.pre = " ", pre
+			}
+			if i == len(parts)-1 {
+				t.post = post
+			}
+			toks = append(toks, t)
+		}
+	}
+	return toks
+}
+
+func isWordRune(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }
+
+// Classes of number words, in the order they may follow each other.
+const (
+	numNone = iota
+	numUnit
+	numTeen
+	numTens
+	numHundreds
+	numScale
+	numAnd
+)
+
+// numberWords are the cardinal number words of a language.
+type numberWords struct {
+	values    map[string]int64 // units, teens, tens, and hundreds
+	hundred   string           // word multiplying what precedes by 100, if any ("hundred")
+	scales    map[string]int64 // thousand, million, billion in their forms
+	and       string           // word allowed after hundreds and scales ("one hundred and five")
+	point     string           // decimal point word, if any
+	digits    map[string]int64 // words read as digits after point
+	bareScale bool             // a scale alone counts one ("тысяча" = 1000)
+}
+
+// numClass returns the class of a number word worth v.
+func numClass(v int64) int {
+	switch {
+	case v < 10:
+		return numUnit
+	case v < 20:
+		return numTeen
+	case v < 100:
+		return numTens
+	}
+	return numHundreds
+}
+
+// follows reports whether a number word of class cls may follow one of
+// class last.
+func follows(cls, last int) bool {
+	switch cls {
+	case numUnit:
+		return last == numNone || last == numTens || last == numHundreds || last == numScale || last == numAnd
+	case numTeen, numTens:
+		return last == numNone || last == numHundreds || last == numScale || last == numAnd
+	}
+	return last == numNone || last == numScale
+}
+
+// isNumber reports whether w is a cardinal number word.
+func (nw *numberWords) isNumber(w string) bool {
+	_, value := nw.values[w]
+	_, scale := nw.scales[w]
+	return value || scale || (w == nw.hundred && w != "") || (w == nw.and && w != "")
+}
+
+// parse reads a cardinal number from the start of words and returns its
+// value and the number of words it spans, or n=0. It stops at the first
+// word that cannot continue the number, so "twenty twenty" is read as 20.
+func (nw *numberWords) parse(words []string) (value int64, n int) {
+	var total, cur int64
+	last, lastScale := numNone, int64(math.MaxInt64)
+	for i, w := range words {
+		v, isValue := nw.values[w]
+		scale, isScale := nw.scales[w]
+		switch {
+		case isValue && v == 0:
+			if last == numNone {
+				return 0, 1
+			}
+			return total + cur, n
+		case isValue:
+			cls := numClass(v)
+			if !follows(cls, last) {
+				return total + cur, n
+			}
+			cur += v
+			last = cls
+		case w == nw.hundred && nw.hundred != "":
+			if last != numUnit && last != numTeen && last != numTens || cur >= 100 {
+				return total + cur, n
+			}
+			cur *= 100
+			last = numHundreds
+		case isScale:
+			if scale >= lastScale || last == numScale || last == numAnd || last == numNone && !nw.bareScale {
+				return total + cur, n
+			}
+			total += max(cur, 1) * scale
+			cur, last, lastScale = 0, numScale, scale
+		case w == nw.and && nw.and != "" && (last == numHundreds || last == numScale):
+			if i+1 == len(words) || nw.values[words[i+1]] == 0 {
+				return total + cur, n
+			}
+			last = numAnd
+			continue
+		default:
+			return total + cur, n
+		}
+		n = i + 1
+	}
+	return total + cur, n
+}
+
+// cardinal writes a number of two or more words or of 10 and up in digits,
+// separating the thousands from 10000 up with sep. Single words below ten
+// are usually not quantities ("one of them") and are kept, as are numbers
+// that follow each other without combining ("three thirty", "five five
+// five"), whose reading is ambiguous.
+func (nw *numberWords) cardinal(words []string, sep string) (string, int) {
+	if len(words) > 0 && isDigits(words[0]) {
+		return "", 0
+	}
+	digits, value, n := nw.amount(words)
+	if n == 0 {
+		return "", 0
+	}
+	if n < len(words) && words[n] != nw.and && nw.isNumber(words[n]) {
+		for n < len(words) && nw.isNumber(words[n]) {
+			n++
+		}
+		return "", n
+	}
+	if n == 1 && value < 10 {
+		return "", 0
+	}
+	if value >= 10000 {
+		digits = groupDigits(digits, sep)
+	}
+	return digits, n
+}
+
+// amount reads a number written in digits, a cardinal, or a cardinal with
+// a decimal part ("three point five") from the start of words. It returns
+// the number in digits, its integer value, and the words it spans, or n=0.
+func (nw *numberWords) amount(words []string) (digits string, value int64, n int) {
+	if len(words) > 0 && isDigits(words[0]) {
+		v, err := strconv.ParseInt(words[0], 10, 64)
+		if err != nil {
+			return "", 0, 0
+		}
+		return words[0], v, 1
+	}
+	value, n = nw.parse(words)
+	if n == 0 {
+		return "", 0, 0
+	}
+	digits = strconv.FormatInt(value, 10)
+	if nw.point == "" || n+1 >= len(words) || words[n] != nw.point {
+		return digits, value, n
+	}
+	var frac strings.Builder
+	for _, w := range words[n+1:] {
+		d, ok := nw.digits[w]
+		if !ok {
+			break
+		}
+		frac.WriteString(strconv.FormatInt(d, 10))
+	}
+	if frac.Len() == 0 {
+		return digits, value, n
+	}
+	return digits + "." + frac.String(), value, n + 1 + frac.Len()
+}
+
+// isDigits reports whether s is a non-empty string of ASCII digits.
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// groupDigits separates the thousands of an integer in digits with sep.
+func groupDigits(digits, sep string) string {
+	intPart, frac, _ := strings.Cut(digits, ".")
+	var b strings.Builder
+	for i, r := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			b.WriteString(sep)
+		}
+		b.WriteRune(r)
+	}
+	if frac != "" {
+		b.WriteString("." + frac)
+	}
+	return b.String()
+}
@@ -0,0 +1,246 @@
+package moonshine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// enNumbers are the English cardinal number words.
+var enNumbers = &numberWords{
+	values: map[string]int64{
+		"zero": 0, "one": 1, "two": 2, "three": 3, "four": 4, "five": 5, "six": 6, "seven": 7, "eight": 8, "nine": 9,
+		"ten": 10, "eleven": 11, "twelve": 12, "thirteen": 13, "fourteen": 14, "fifteen": 15, "sixteen": 16,
+		"seventeen": 17, "eighteen": 18, "nineteen": 19,
+		"twenty": 20, "thirty": 30, "forty": 40, "fifty": 50, "sixty": 60, "seventy": 70, "eighty": 80, "ninety": 90,
+	},
+	hundred: "hundred",
+	scales:  map[string]int64{"thousand": 1e3, "million": 1e6, "billion": 1e9},
+	and:     "and",
+	point:   "point",
+	digits: map[string]int64{
+		"zero": 0, "oh": 0, "o": 0, "one": 1, "two": 2, "three": 3, "four": 4, "five": 5, "six": 6, "seven": 7, "eight": 8, "nine": 9,
+	},
+}
+
+// enOrdinals are the English ordinal number words.
+var enOrdinals = map[string]int64{
+	"first": 1, "second": 2, "third": 3, "fourth": 4, "fifth": 5, "sixth": 6, "seventh": 7, "eighth": 8, "ninth": 9,
+	"tenth": 10, "eleventh": 11, "twelfth": 12, "thirteenth": 13, "fourteenth": 14, "fifteenth": 15, "sixteenth": 16,
+	"seventeenth": 17, "eighteenth": 18, "nineteenth": 19,
+	"twentieth": 20, "thirtieth": 30, "fortieth": 40, "fiftieth": 50, "sixtieth": 60, "seventieth": 70, "eightieth": 80, "ninetieth": 90,
+	"hundredth": 100, "thousandth": 1e3, "millionth": 1e6,
+}
+
+var enMonths = map[string]string{
+	"january": "January", "february": "February", "march": "March", "april": "April", "may": "May", "june": "June",
+	"july": "July", "august": "August", "september": "September", "october": "October", "november": "November", "december": "December",
+}
+
+// enCurrencies maps currency words to their symbols.
+var enCurrencies = map[string]string{
+	"dollar": "$", "dollars": "$", "buck": "$", "bucks": "$",
+	"euro": "€", "euros": "€",
+}
+
+var enMeridiems = map[string]string{"am": "AM", "a.m": "AM", "pm": "PM", "p.m": "PM"}
+
+// enCardinal writes cardinals in digits: "twenty five" as "25", "three
+// point five" as "3.5", "three million" as "3,000,000".
+func enCardinal(words []string) (string, int) { return enNumbers.cardinal(words, ",") }
+
+// enOrdinal writes ordinals of 10 and up with a suffix: "twenty first" as
+// "21st". Lower ones ("the first time") are kept.
+func enOrdinal(words []string) (string, int) {
+	value, n := enParseOrdinal(words)
+	if n == 0 || value < 10 {
+		return "", 0
+	}
+	return strconv.FormatInt(value, 10) + ordinalSuffix(value), n
+}
+
+// enParseOrdinal reads an ordinal, which may start with a cardinal ("one
+// hundred and first"), from the start of words.
+func enParseOrdinal(words []string) (value int64, n int) {
+	k := 0
+	for k < len(words) && enNumbers.isNumber(words[k]) {
+		k++
+	}
+	if k == len(words) {
+		return 0, 0
+	}
+	ord, ok := enOrdinals[words[k]]
+	if !ok {
+		return 0, 0
+	}
+	if k == 0 {
+		return ord, 1
+	}
+	end := k
+	if words[k-1] == enNumbers.and { // "one hundred and first"
+		end--
+	}
+	prefix, pn := enNumbers.parse(words[:end])
+	if pn == 0 || pn != end {
+		return 0, 0
+	}
+	switch {
+	case ord < 10 && prefix%10 == 0 && prefix%100 != 10,
+		ord < 100 && ord >= 10 && prefix%100 == 0:
+		return prefix + ord, k + 1
+	case ord >= 100 && prefix < ord && prefix < 100:
+		return prefix * ord, k + 1
+	}
+	return 0, 0
+}
+
+// ordinalSuffix returns the English suffix of ordinal n: "st", "nd", "rd",
+// or "th".
+func ordinalSuffix(n int64) string {
+	if n%100 >= 11 && n%100 <= 13 {
+		return "th"
+	}
+	switch n % 10 {
+	case 1:
+		return "st"
+	case 2:
+		return "nd"
+	case 3:
+		return "rd"
+	}
+	return "th"
+}
+
+// enDate writes dates with an ordinal day: "march fifth" and "the fifth of
+// march" as "March 5", with a following year as "March 5, 2024".
+func enDate(words []string) (string, int) {
+	var month string
+	var day int64
+	var n int
+	if m, ok := enMonths[words[0]]; ok && len(words) > 1 {
+		d, dn := enParseOrdinal(words[1:])
+		month, day, n = m, d, 1+dn
+	} else if words[0] == "the" && len(words) > 3 {
+		d, dn := enParseOrdinal(words[1:])
+		if dn == 0 || 1+dn+1 >= len(words) || words[1+dn] != "of" {
+			return "", 0
+		}
+		m, ok := enMonths[words[2+dn]]
+		if !ok {
+			return "", 0
+		}
+		month, day, n = m, d, 3+dn
+	}
+	if n == 0 || day < 1 || day > 31 {
+		return "", 0
+	}
+	written := fmt.Sprintf("%s %d", month, day)
+	if year, yn := enYear(words[n:]); yn > 0 {
+		return fmt.Sprintf("%s, %d", written, year), n + yn
+	}
+	return written, n
+}
+
+// enYear reads a year from 1000 to 2999 as a cardinal ("two thousand
+// twenty four") or in pairs ("nineteen ninety nine", "twenty oh five").
+func enYear(words []string) (int64, int) {
+	if len(words) > 0 && isDigits(words[0]) && len(words[0]) == 4 {
+		v, _ := strconv.ParseInt(words[0], 10, 64)
+		return v, 1
+	}
+	hi, n := enNumbers.parse(words)
+	if n == 0 {
+		return 0, 0
+	}
+	if hi >= 1000 && hi <= 2999 {
+		return hi, n
+	}
+	if hi < 10 || hi > 29 || n >= len(words) {
+		return 0, 0
+	}
+	if (words[n] == "oh" || words[n] == "o") && n+1 < len(words) {
+		if lo, ok := enNumbers.values[words[n+1]]; ok && lo > 0 && lo < 10 {
+			return hi*100 + lo, n + 2
+		}
+		return 0, 0
+	}
+	lo, ln := enNumbers.parse(words[n:])
+	if ln == 0 || lo < 10 || lo > 99 {
+		return 0, 0
+	}
+	return hi*100 + lo, n + ln
+}
+
+// enTime writes clock times: "three thirty pm" as "3:30 PM", "ten oh five
+// a.m." as "10:05 AM", "seven o'clock" as "7:00". Without "am", "pm", or
+// "o'clock" numbers are kept as numbers.
+func enTime(words []string) (string, int) {
+	hour, n := enNumbers.parse(words)
+	if n != 1 || hour < 1 || hour > 12 || n >= len(words) {
+		return "", 0
+	}
+	minute := int64(-1)
+	if (words[n] == "oh" || words[n] == "o") && n+1 < len(words) {
+		if m, ok := enNumbers.values[words[n+1]]; ok && m > 0 && m < 10 {
+			minute, n = m, n+2
+		}
+	} else if m, mn := enNumbers.parse(words[n:]); mn > 0 && m >= 10 && m <= 59 {
+		minute, n = m, n+mn
+	}
+	if n >= len(words) {
+		return "", 0
+	}
+	if words[n] == "o'clock" && minute < 0 {
+		return fmt.Sprintf("%d:00", hour), n + 1
+	}
+	meridiem, ok := enMeridiems[words[n]]
+	if !ok {
+		return "", 0
+	}
+	if minute < 0 {
+		return fmt.Sprintf("%d %s", hour, meridiem), n + 1
+	}
+	return fmt.Sprintf("%d:%02d %s", hour, minute, meridiem), n + 1
+}
+
+// enMoney writes amounts of dollars and euros with their symbol: "twenty
+// five dollars" as "$25", "five dollars and fifty cents" as "$5.50", and
+// cents alone as "50¢".
+func enMoney(words []string) (string, int) {
+	digits, value, n := enNumbers.amount(words)
+	if n == 0 || n >= len(words) {
+		return "", 0
+	}
+	if words[n] == "cents" && !strings.Contains(digits, ".") && value > 0 && value < 100 {
+		return digits + "¢", n + 1
+	}
+	symbol, ok := enCurrencies[words[n]]
+	if !ok {
+		return "", 0
+	}
+	n++
+	written := symbol + groupDigits(digits, ",")
+	if strings.Contains(digits, ".") || n+2 >= len(words) || words[n] != "and" {
+		return written, n
+	}
+	cents, cn := enNumbers.parse(words[n+1:])
+	if cn == 0 || cents >= 100 || n+1+cn >= len(words) || words[n+1+cn] != "cents" && words[n+1+cn] != "cent" {
+		return written, n
+	}
+	return fmt.Sprintf("%s.%02d", written, cents), n + 2 + cn
+}
+
+// enPercent writes percentages: "twelve point five percent" as "12.5%".
+func enPercent(words []string) (string, int) {
+	digits, _, n := enNumbers.amount(words)
+	if n == 0 || n >= len(words) {
+		return "", 0
+	}
+	if words[n] == "percent" {
+		return digits + "%", n + 1
+	}
+	if words[n] == "per" && n+1 < len(words) && words[n+1] == "cent" {
+		return digits + "%", n + 2
+	}
+	return "", 0
+}
@@ -0,0 +1,224 @@
+package moonshine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ruNumbers are the Russian cardinal number words in the nominative, the
+// case recognizers mostly produce for spoken numbers.
+var ruNumbers = &numberWords{
+	values: map[string]int64{
+		"ноль": 0, "один": 1, "одна": 1, "одно": 1, "одну": 1, "два": 2, "две": 2, "три": 3, "четыре": 4,
+		"пять": 5, "шесть": 6, "семь": 7, "восемь": 8, "девять": 9,
+		"десять": 10, "одиннадцать": 11, "двенадцать": 12, "тринадцать": 13, "четырнадцать": 14,
+		"пятнадцать": 15, "шестнадцать": 16, "семнадцать": 17, "восемнадцать": 18, "девятнадцать": 19,
+		"двадцать": 20, "тридцать": 30, "сорок": 40, "пятьдесят": 50, "шестьдесят": 60,
+		"семьдесят": 70, "восемьдесят": 80, "девяносто": 90,
+		"сто": 100, "двести": 200, "триста": 300, "четыреста": 400, "пятьсот": 500,
+		"шестьсот": 600, "семьсот": 700, "восемьсот": 800, "девятьсот": 900,
+	},
+	scales: map[string]int64{
+		"тысяча": 1e3, "тысячи": 1e3, "тысяч": 1e3, "тысячу": 1e3,
+		"миллион": 1e6, "миллиона": 1e6, "миллионов": 1e6,
+		"миллиард": 1e9, "миллиарда": 1e9, "миллиардов": 1e9,
+	},
+	bareScale: true,
+}
+
+// ruOrdinalStems are the stems of Russian ordinals, which take adjective
+// endings: "пят" + "ое", "ого", "ому", and so on. "третий" is irregular and
+// handled apart.
+var ruOrdinalStems = map[string]int64{
+	"перв": 1, "втор": 2, "четверт": 4, "пят": 5, "шест": 6, "седьм": 7, "восьм": 8, "девят": 9,
+	"десят": 10, "одиннадцат": 11, "двенадцат": 12, "тринадцат": 13, "четырнадцат": 14, "пятнадцат": 15,
+	"шестнадцат": 16, "семнадцат": 17, "восемнадцат": 18, "девятнадцат": 19,
+	"двадцат": 20, "тридцат": 30, "сороков": 40, "пятидесят": 50, "шестидесят": 60,
+	"семидесят": 70, "восьмидесят": 80, "девяност": 90,
+	"сот": 100, "двухсот": 200, "трехсот": 300, "четырехсот": 400, "пятисот": 500,
+	"шестисот": 600, "семисот": 700, "восьмисот": 800, "девятисот": 900,
+	"тысячн": 1000, "двухтысячн": 2000,
+}
+
+// ruOrdinalStemsByLength lists ruOrdinalStems longest first, so "пятнадцат"
+// is tried before "пят".
+var ruOrdinalStemsByLength = func() []string {
+	stems := make([]string, 0, len(ruOrdinalStems))
+	for s := range ruOrdinalStems {
+		stems = append(stems, s)
+	}
+	sort.Slice(stems, func(i, j int) bool { return len(stems[i]) > len(stems[j]) })
+	return stems
+}()
+
+var ruOrdinalEndings = map[string]bool{
+	"ый": true, "ий": true, "ой": true, "ое": true, "ая": true, "ую": true, "ые": true,
+	"ого": true, "ому": true, "ом": true, "ых": true, "ым": true, "ыми": true,
+}
+
+var ruThirdEndings = map[string]bool{
+	"ий": true, "ье": true, "ья": true, "ью": true, "ьи": true, "ьего": true, "ьему": true, "ьем": true, "ьей": true, "ьих": true, "ьим": true,
+}
+
+var ruMonths = map[string]bool{
+	"января": true, "февраля": true, "марта": true, "апреля": true, "мая": true, "июня": true,
+	"июля": true, "августа": true, "сентября": true, "октября": true, "ноября": true, "декабря": true,
+}
+
+// ruCurrencies maps currency words to their symbols and whether the symbol
+// goes before the amount.
+var ruCurrencies = map[string]struct {
+	symbol string
+	prefix bool
+}{
+	"рубль": {"₽", false}, "рубля": {"₽", false}, "рублей": {"₽", false},
+	"доллар": {"$", true}, "доллара": {"$", true}, "долларов": {"$", true},
+	"евро": {"€", true},
+}
+
+// ruCardinal writes cardinals in digits: "двадцать пять" as "25", "сорок
+// пять тысяч" as "45 000" with a no-break space.
+func ruCardinal(words []string) (string, int) { return ruNumbers.cardinal(words, "\u00a0") }
+
+// ruOrdinalWord returns the value of a Russian ordinal word in any case and
+// gender.
+func ruOrdinalWord(w string) (int64, bool) {
+	if rest, ok := strings.CutPrefix(w, "трет"); ok && ruThirdEndings[rest] {
+		return 3, true
+	}
+	for _, stem := range ruOrdinalStemsByLength {
+		if rest, ok := strings.CutPrefix(w, stem); ok && ruOrdinalEndings[rest] {
+			return ruOrdinalStems[stem], true
+		}
+	}
+	return 0, false
+}
+
+// ruParseOrdinal reads an ordinal, which may start with a cardinal ("две
+// тысячи двадцать четвертого"), from the start of words.
+func ruParseOrdinal(words []string) (value int64, n int) {
+	k := 0
+	for k < len(words) && ruNumbers.isNumber(words[k]) {
+		k++
+	}
+	if k == len(words) {
+		return 0, 0
+	}
+	ord, ok := ruOrdinalWord(words[k])
+	if !ok {
+		return 0, 0
+	}
+	if k == 0 {
+		return ord, 1
+	}
+	prefix, pn := ruNumbers.parse(words[:k])
+	if pn != k {
+		return 0, 0
+	}
+	switch {
+	case ord < 10 && prefix%10 == 0 && prefix%100 != 10,
+		ord >= 10 && ord < 100 && prefix%100 == 0,
+		ord >= 100 && ord < 1000 && prefix%1000 == 0:
+		return prefix + ord, k + 1
+	}
+	return 0, 0
+}
+
+// ruDate writes dates: "пятое марта" as "5 марта", with a year as "5 марта
+// 2024 года".
+func ruDate(words []string) (string, int) {
+	day, n := ruParseOrdinal(words)
+	if n == 0 || day < 1 || day > 31 || n >= len(words) || !ruMonths[words[n]] {
+		return "", 0
+	}
+	written := fmt.Sprintf("%d %s", day, words[n])
+	n++
+	year, yn := ruParseOrdinal(words[n:])
+	if yn == 0 || year < 1000 || year > 2999 {
+		return written, n
+	}
+	written, n = fmt.Sprintf("%s %d", written, year), n+yn
+	if n < len(words) && isRUYearWord(words[n]) {
+		return written + " " + words[n], n + 1
+	}
+	return written, n
+}
+
+// ruYear writes years followed by "год": "в две тысячи двадцать четвертом
+// году" as "в 2024 году".
+func ruYear(words []string) (string, int) {
+	year, n := ruParseOrdinal(words)
+	if n == 0 || year < 1000 || year > 2999 || n >= len(words) || !isRUYearWord(words[n]) {
+		return "", 0
+	}
+	return fmt.Sprintf("%d %s", year, words[n]), n + 1
+}
+
+func isRUYearWord(w string) bool {
+	return w == "год" || w == "года" || w == "году" || w == "годом" || w == "годе"
+}
+
+// ruTime writes clock times after "в": "в десять часов тридцать минут" as
+// "в 10:30". Without "в" such phrases are usually durations and are kept.
+func ruTime(words []string) (string, int) {
+	if len(words) < 5 || words[0] != "в" {
+		return "", 0
+	}
+	hour, n := ruNumbers.parse(words[1:])
+	n++
+	if n == 1 || hour > 23 || n >= len(words) || words[n] != "час" && words[n] != "часа" && words[n] != "часов" {
+		return "", 0
+	}
+	n++
+	minute, mn := ruNumbers.parse(words[n:])
+	if mn == 0 || minute > 59 || n+mn >= len(words) {
+		return "", 0
+	}
+	switch words[n+mn] {
+	case "минута", "минуты", "минут", "минуту":
+		return fmt.Sprintf("в %d:%02d", hour, minute), n + mn + 1
+	}
+	return "", 0
+}
+
+// ruMoney writes amounts of rubles, dollars, and euros with their symbol:
+// "двадцать пять рублей" as "25 ₽", "сто рублей пятьдесят копеек" as
+// "100,50 ₽", "десять долларов" as "$10".
+func ruMoney(words []string) (string, int) {
+	digits, value, n := ruNumbers.amount(words)
+	if n == 0 || n >= len(words) {
+		return "", 0
+	}
+	if value >= 10000 {
+		digits = groupDigits(digits, "\u00a0")
+	}
+	cur, ok := ruCurrencies[words[n]]
+	if !ok {
+		return "", 0
+	}
+	n++
+	if cur.prefix {
+		return cur.symbol + digits, n
+	}
+	if kop, kn := ruNumbers.parse(words[n:]); kn > 0 && kop < 100 && n+kn < len(words) {
+		switch words[n+kn] {
+		case "копейка", "копейки", "копеек", "копейку":
+			return fmt.Sprintf("%s,%02d %s", digits, kop, cur.symbol), n + kn + 1
+		}
+	}
+	return digits + " " + cur.symbol, n
+}
+
+// ruPercent writes percentages: "двадцать процентов" as "20%".
+func ruPercent(words []string) (string, int) {
+	digits, _, n := ruNumbers.amount(words)
+	if n == 0 || n >= len(words) {
+		return "", 0
+	}
+	switch words[n] {
+	case "процент", "процента", "процентов":
+		return digits + "%", n + 1
+	}
+	return "", 0
+}
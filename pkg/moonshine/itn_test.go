@@ -0,0 +1,118 @@
+package moonshine
+
+import "testing"
+
+// --- normalizeText ---
+
+func TestNormalizeText_EN(t *testing.T) {
+	tests := []struct{ in, want string }{
+		// Cardinals: two or more words, or ten and up.
+		{"I have twenty five apples", "I have 25 apples"},
+		{"one of them", "one of them"},
+		{"about ten people", "about 10 people"},
+		{"one hundred and five", "105"},
+		{"two thousand twenty four", "2024"},
+		{"three million", "3,000,000"},
+		{"three point one four", "3.14"},
+		{"zero", "zero"},
+		{"nine to five", "nine to five"},
+		{"five five five one two", "five five five one two"},
+		{"Twenty twenty vision", "Twenty twenty vision"},
+		// Punctuation splits numbers and is kept around rewrites.
+		{"Twenty, five.", "20, five."},
+		{"It costs twenty five dollars.", "It costs $25."},
+		{"twenty-five", "25"},
+		{"a twenty-year-old", "a 20-year-old"},
+		// Money and percentages.
+		{"five dollars and fifty cents", "$5.50"},
+		{"one dollar", "$1"},
+		{"fifty cents", "50¢"},
+		{"twelve thousand euros", "€12,000"},
+		{"25 dollars", "$25"},
+		{"twelve point five percent", "12.5%"},
+		{"ten per cent", "10%"},
+		// Ordinals from tenth up.
+		{"the first time", "the first time"},
+		{"the twenty first century", "the 21st century"},
+		{"her eleventh birthday", "her 11th birthday"},
+		{"one hundred and second", "102nd"},
+		// Dates.
+		{"march fifth", "March 5"},
+		{"on the third of july", "on July 3"},
+		{"March fifth twenty twenty four", "March 5, 2024"},
+		{"december thirty first nineteen ninety nine", "December 31, 1999"},
+		{"may first two thousand ten", "May 1, 2010"},
+		{"june second twenty oh five", "June 2, 2005"},
+		{"march forward", "march forward"},
+		// Times.
+		{"three thirty pm", "3:30 PM"},
+		{"at ten oh five a.m.", "at 10:05 AM."},
+		{"seven o'clock", "7:00"},
+		{"call me at nine am", "call me at 9 AM"},
+		{"three thirty", "three thirty"},
+	}
+	for _, tt := range tests {
+		if got := normalizeText(tt.in, "en"); got != tt.want {
+			t.Errorf("normalizeText(%q, en) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeText_RU(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"двадцать пять яблок", "25 яблок"},
+		{"один из них", "один из них"},
+		{"сто двадцать три", "123"},
+		{"две тысячи", "2000"},
+		{"тысяча", "1000"},
+		{"сорок пять тысяч", "45 000"},
+		{"Двадцать, пять.", "20, пять."},
+		{"три тридцать", "три тридцать"},
+		// Money and percentages.
+		{"двадцать пять рублей", "25 ₽"},
+		{"сто рублей пятьдесят копеек", "100,50 ₽"},
+		{"десять долларов", "$10"},
+		{"пять евро", "€5"},
+		{"двадцать процентов", "20%"},
+		// Dates and years.
+		{"пятое марта", "5 марта"},
+		{"Двадцать первого июня.", "21 июня."},
+		{"третье мая две тысячи двадцать четвёртого года", "3 мая 2024 года"},
+		{"первого января тысяча девятьсот девяносто девятого", "1 января 1999"},
+		{"в две тысячи двадцатом году", "в 2020 году"},
+		{"в двухтысячном году", "в 2000 году"},
+		{"пятое место", "пятое место"},
+		// Times.
+		{"в десять часов тридцать минут", "в 10:30"},
+		{"в семь часов пять минут", "в 7:05"},
+		{"ждали два часа тридцать минут", "ждали два часа 30 минут"},
+	}
+	for _, tt := range tests {
+		if got := normalizeText(tt.in, "ru"); got != tt.want {
+			t.Errorf("normalizeText(%q, ru) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeText_OtherLanguage(t *testing.T) {
+	if got := normalizeText("twenty five", "de"); got != "twenty five" {
+		t.Errorf("normalizeText(de) = %q, want unchanged", got)
+	}
+	if got := normalizeText("", "en"); got != "" {
+		t.Errorf("normalizeText(\"\") = %q", got)
+	}
+	if got := normalizeText("wait — what?", "en"); got != "wait — what?" {
+		t.Errorf("normalizeText(punctuation) = %q", got)
+	}
+}
+
+// --- groupDigits ---
+
+func TestGroupDigits(t *testing.T) {
+	tests := map[string]string{"1": "1", "999": "999", "1000": "1,000", "1234567": "1,234,567", "12345.5": "12,345.5"}
+	for in, want := range tests {
+		if got := groupDigits(in, ","); got != want {
+			t.Errorf("groupDigits(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
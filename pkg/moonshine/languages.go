@@ -0,0 +1,72 @@
+package moonshine
+
+import (
+	"maps"
+	"slices"
+)
+
+// builtinLanguages have a model directory field of their own in Config.
+var builtinLanguages = []string{"en", "ru", "zh"}
+
+// ValidLanguage reports whether lang can name a model directory in
+// Config.ModelDirs: two or three lowercase letters, as ISO 639 codes are.
+func ValidLanguage(lang string) bool {
+	if len(lang) < 2 || len(lang) > 3 {
+		return false
+	}
+	for _, c := range lang {
+		if c < 'a' || c > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+// modelDirs returns the model directory of every language: ModelsDir,
+// RUModelsDir, and ZHModelsDir, overridden and extended by ModelDirs. A
+// language's directory may be "", meaning no model.
+func (c Config) modelDirs() map[string]string {
+	dirs := map[string]string{"en": c.ModelsDir, "ru": c.RUModelsDir, "zh": c.ZHModelsDir}
+	maps.Copy(dirs, c.ModelDirs)
+	return dirs
+}
+
+// Languages returns the languages a model directory is configured for,
+// sorted, whether or not the model loaded.
+func (e *Engine) Languages() []string {
+	var langs []string
+	for lang, dir := range e.cfg.modelDirs() {
+		if dir != "" {
+			langs = append(langs, lang)
+		}
+	}
+	slices.Sort(langs)
+	return langs
+}
+
+// langModel returns the recognizer model for lang: its own for a built-in
+// language or one with a directory in Config.ModelDirs, else the EN model.
+func (e *Engine) langModel(lang string) string {
+	if slices.Contains(builtinLanguages, lang) || e.cfg.ModelDirs[lang] != "" {
+		return lang
+	}
+	return "en"
+}
+
+// poolSize returns how many recognizers to load for model: its language's
+// entry in PoolSizes, else PoolSize.
+func (c Config) poolSize(model string) int {
+	if n := c.PoolSizes[modelArch(model)]; n > 0 {
+		return n
+	}
+	return c.PoolSize
+}
+
+// threads returns the ONNX threads of each recognizer of model: its
+// language's entry in ModelThreads, else NumThreads.
+func (c Config) threads(model string) int {
+	if n := c.ModelThreads[modelArch(model)]; n > 0 {
+		return n
+	}
+	return c.NumThreads
+}
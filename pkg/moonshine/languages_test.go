@@ -0,0 +1,36 @@
+package moonshine
+
+import (
+	"slices"
+	"testing"
+)
+
+// --- ValidLanguage ---
+
+func TestValidLanguage(t *testing.T) {
+	for lang, want := range map[string]bool{"en": true, "de": true, "yue": true, "": false, "e": false, "EN": false, "en-us": false, "deu1": false} {
+		if got := ValidLanguage(lang); got != want {
+			t.Errorf("ValidLanguage(%q) = %v, want %v", lang, got, want)
+		}
+	}
+}
+
+// --- Engine.Languages ---
+
+func TestLanguages(t *testing.T) {
+	e := &Engine{cfg: Config{ModelsDir: "/models", ZHModelsDir: "/zh-models", ModelDirs: map[string]string{"de": "/de-models", "zh": ""}}}
+	if got, want := e.Languages(), []string{"de", "en"}; !slices.Equal(got, want) {
+		t.Errorf("Languages() = %q, want %q", got, want)
+	}
+}
+
+// --- langModel ---
+
+func TestLangModel(t *testing.T) {
+	e := &Engine{cfg: Config{ModelDirs: map[string]string{"de": "/de-models"}}}
+	for lang, want := range map[string]string{"en": "en", "ru": "ru", "zh": "zh", "de": "de", "fr": "en", "": "en"} {
+		if got := e.langModel(lang); got != want {
+			t.Errorf("langModel(%q) = %q, want %q", lang, got, want)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package moonshine
+
+import (
+	"context"
+	"strings"
+)
+
+// ensureLoaded makes sure, with Config.LazyLoad, that the recognizer a call
+// in lang would use is loaded: it starts loading the model on first use and
+// returns ErrLoading until it is ready, or waits for it with
+// Options.WaitForLoad. A model that fails to load stays unavailable, as it
+// would at startup.
+func (e *Engine) ensureLoaded(ctx context.Context, lang string, opts Options) error {
+	if !e.cfg.LazyLoad || opts.Task == TaskTranslate {
+		return nil
+	}
+	model := e.langModel(lang)
+	if err := e.ensureModel(ctx, model, opts.WaitForLoad); err != nil {
+		return err
+	}
+	if model != "ru" && !e.hasModel(model) {
+		return e.ensureModel(ctx, "en", opts.WaitForLoad) // see selectModel
+	}
+	return nil
+}
+
+// ensureModel loads the language model in the background unless it is
+// loaded, loading, failed, or not configured.
+func (e *Engine) ensureModel(ctx context.Context, model string, wait bool) error {
+	dir := e.cfg.modelDirs()[model]
+	if dir == "" || e.hasModel(model) {
+		return nil
+	}
+	e.muLoad.Lock()
+	if e.failed[model] || e.hasModel(model) {
+		e.muLoad.Unlock()
+		return nil
+	}
+	done, ok := e.loading[model]
+	if !ok {
+		if e.loading == nil {
+			e.loading, e.failed = make(map[string]chan struct{}), make(map[string]bool)
+		}
+		done = make(chan struct{})
+		e.loading[model] = done
+		go e.lazyLoad(model, dir, done)
+	}
+	e.muLoad.Unlock()
+	if !wait {
+		return errorf(ErrLoading, "%s model is loading, retry shortly", strings.ToUpper(model))
+	}
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// lazyLoad loads and warms up model from dir, then closes done.
+func (e *Engine) lazyLoad(model, dir string, done chan struct{}) {
+	e.loadLangModel(model, dir)
+	e.warmupModel(model)
+	e.muLoad.Lock()
+	delete(e.loading, model)
+	if !e.hasModel(model) {
+		e.failed[model] = true
+	}
+	e.muLoad.Unlock()
+	close(done)
+}
+
+// Loading reports whether the recognizer for lang is being loaded with
+// Config.LazyLoad.
+func (e *Engine) Loading(lang string) bool {
+	e.muLoad.Lock()
+	defer e.muLoad.Unlock()
+	_, ok := e.loading[lang]
+	return ok
+}
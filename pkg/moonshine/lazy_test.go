@@ -0,0 +1,53 @@
+package moonshine
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+// --- ensureModel ---
+
+func TestEnsureModel_NotLazy(t *testing.T) {
+	e := &Engine{cfg: Config{ModelsDir: t.TempDir()}}
+	if err := e.ensureLoaded(context.Background(), "en", Options{}); err != nil {
+		t.Errorf("ensureLoaded = %v, want nil without LazyLoad", err)
+	}
+}
+
+func TestEnsureModel_LoadsOnFirstUse(t *testing.T) {
+	e := &Engine{cfg: Config{LazyLoad: true, ModelsDir: filepath.Join(t.TempDir(), "missing")}}
+	ctx := context.Background()
+	if err := e.ensureLoaded(ctx, "en", Options{}); !errors.Is(err, ErrLoading) {
+		t.Fatalf("first use = %v, want ErrLoading", err)
+	}
+	// Waiting joins the load already started; the missing model fails it.
+	if err := e.ensureLoaded(ctx, "en", Options{WaitForLoad: true}); err != nil {
+		t.Fatalf("wait = %v, want nil once the load ends", err)
+	}
+	if e.Loading("en") || e.HasLanguage("en") {
+		t.Errorf("loading=%v loaded=%v, want neither after a failed load", e.Loading("en"), e.HasLanguage("en"))
+	}
+	// A failed model is not loaded again; selection reports it missing.
+	if err := e.ensureLoaded(ctx, "en", Options{}); err != nil {
+		t.Errorf("after failure = %v, want nil", err)
+	}
+	if _, err := e.selectModel("en", 16000, nil, ""); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("selectModel = %v, want ErrUnavailable", err)
+	}
+}
+
+func TestEnsureModel_WaitCanceled(t *testing.T) {
+	e := &Engine{cfg: Config{LazyLoad: true, ModelsDir: t.TempDir()}}
+	done := make(chan struct{})
+	e.loading = map[string]chan struct{}{"en": done} // a load in progress
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := e.ensureModel(ctx, "en", true); !errors.Is(err, context.Canceled) {
+		t.Errorf("ensureModel = %v, want context.Canceled", err)
+	}
+	if !e.Loading("en") {
+		t.Error("Loading(en) = false during a load")
+	}
+}
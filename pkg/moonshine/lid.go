@@ -0,0 +1,143 @@
+package moonshine
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"time"
+
+	sherpa "github.com/k2-fsa/sherpa-onnx-go/sherpa_onnx"
+)
+
+// LangAuto as Options.Lang identifies the spoken language with the language
+// ID model and transcribes with the recognizer for it.
+const LangAuto = "auto"
+
+const (
+	lidWindowSamples = 30 * 16000 // Whisper looks at 30s of audio at a time
+	lidMaxWindows    = 10         // windows scored per call; later audio is ignored
+)
+
+// LanguageScore is a language found in audio with the share of the scored
+// speech attributed to it.
+type LanguageScore struct {
+	Language string  `json:"language"` // ISO 639-1 code, e.g. "en", "ru", "de"
+	Prob     float64 `json:"prob"`
+}
+
+// initLID loads the Whisper encoder and decoder used for spoken language
+// identification.
+func (e *Engine) initLID(encoder, decoder string) {
+	c := sherpa.SpokenLanguageIdentificationConfig{}
+	c.Whisper.Encoder = encoder
+	c.Whisper.Decoder = decoder
+	c.NumThreads = e.cfg.NumThreads
+	c.Provider = e.Provider()
+
+	t := time.Now()
+	e.lid = sherpa.NewSpokenLanguageIdentification(&c)
+	if e.lid == nil {
+		e.logf("WARNING: failed to load language ID model from %s, %s", encoder, decoder)
+		return
+	}
+	e.logf("Language ID model loaded in %.2fs", time.Since(t).Seconds())
+}
+
+// IdentifyLanguage decodes the audio at path like TranscribeFile and returns
+// the languages spoken in it, most likely first. The model names one
+// language per window of up to 30 seconds of speech (VAD chunks when VAD
+// applies, as for transcription); each language's Prob is its share of the
+// scored audio. Only the first ten windows are scored. It fails with
+// ErrUnavailable when the language ID model is not loaded.
+func (e *Engine) IdentifyLanguage(ctx context.Context, path string, opts Options) ([]LanguageScore, error) {
+	if e.lid == nil {
+		return nil, errorf(ErrUnavailable, "language ID model not loaded")
+	}
+	samples, rate, err := e.decodeChecked(ctx, path, opts)
+	if err != nil {
+		return nil, err
+	}
+	if rate != 16000 {
+		samples = resample(samples, rate, 16000)
+	}
+	return e.identifySamples(ctx, samples, opts.VAD)
+}
+
+// identifySamples scores the language of 16 kHz samples; see
+// IdentifyLanguage. Audio without speech yields no scores.
+func (e *Engine) identifySamples(ctx context.Context, samples []float32, vad *bool) ([]LanguageScore, error) {
+	chunks, _, _ := e.buildAudioChunks(samples, float64(len(samples))/16000, Options{VAD: vad})
+	var windows [][]float32
+	for _, c := range chunks {
+		for len(c) > lidWindowSamples {
+			windows = append(windows, c[:lidWindowSamples])
+			c = c[lidWindowSamples:]
+		}
+		windows = append(windows, c)
+	}
+	if len(windows) > lidMaxWindows {
+		windows = windows[:lidMaxWindows]
+	}
+
+	weights := make(map[string]int)
+	total := 0
+	for _, w := range windows {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if lang := e.identifyWindow(w); lang != "" {
+			weights[lang] += len(w)
+			total += len(w)
+		}
+	}
+	scores := make([]LanguageScore, 0, len(weights))
+	for lang, n := range weights {
+		scores = append(scores, LanguageScore{Language: lang, Prob: float64(n) / float64(total)})
+	}
+	sort.Slice(scores, func(i, j int) bool {
+		if scores[i].Prob != scores[j].Prob {
+			return scores[i].Prob > scores[j].Prob
+		}
+		return scores[i].Language < scores[j].Language
+	})
+	return scores, nil
+}
+
+// identifyWindow returns the language the model finds in one window.
+func (e *Engine) identifyWindow(samples []float32) string {
+	e.muLID.Lock()
+	defer e.muLID.Unlock()
+
+	s := e.lid.CreateStream()
+	defer sherpa.DeleteOfflineStream(s)
+	s.AcceptWaveform(16000, samples)
+	return e.lid.Compute(s).Lang
+}
+
+// detectLanguage picks the language to transcribe 16 kHz samples in for
+// LangAuto: the most likely of the candidates whose recognizer is loaded.
+// The candidates are Options.AutoLanguages, or every configured language.
+// When none of them is found in the audio, the first loaded one is used.
+func (e *Engine) detectLanguage(ctx context.Context, samples []float32, opts Options) (string, error) {
+	if e.lid == nil {
+		return "", errorf(ErrUnavailable, "language ID model not loaded")
+	}
+	candidates := opts.AutoLanguages
+	if len(candidates) == 0 {
+		candidates = e.Languages()
+	}
+	candidates = slices.DeleteFunc(slices.Clone(candidates), func(lang string) bool { return !e.HasLanguage(lang) })
+	if len(candidates) == 0 {
+		return "", errorf(ErrUnavailable, "no model loaded for the candidate languages")
+	}
+	scores, err := e.identifySamples(ctx, samples, opts.VAD)
+	if err != nil {
+		return "", err
+	}
+	for _, s := range scores {
+		if slices.Contains(candidates, s.Language) {
+			return s.Language, nil
+		}
+	}
+	return candidates[0], nil
+}
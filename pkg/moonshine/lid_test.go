@@ -0,0 +1,25 @@
+package moonshine
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// --- Engine.IdentifyLanguage ---
+
+func TestIdentifyLanguage_NoModel(t *testing.T) {
+	_, err := new(Engine).IdentifyLanguage(context.Background(), "/nonexistent/clip.wav", Options{})
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("IdentifyLanguage without model: err = %v, want ErrUnavailable", err)
+	}
+}
+
+// --- Engine.detectLanguage ---
+
+func TestDetectLanguage_NoModel(t *testing.T) {
+	_, err := new(Engine).TranscribeSamples(context.Background(), make([]float32, 16000), 16000, Options{Lang: LangAuto})
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("TranscribeSamples with LangAuto without model: err = %v, want ErrUnavailable", err)
+	}
+}
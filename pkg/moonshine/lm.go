@@ -0,0 +1,31 @@
+package moonshine
+
+import sherpa "github.com/k2-fsa/sherpa-onnx-go/sherpa_onnx"
+
+// LM is a language model a language's Zipformer transducer fuses into
+// modified_beam_search (shallow fusion), to boost domain vocabulary.
+type LM struct {
+	Model string  // ONNX language model file
+	Scale float64 // weight of the LM score; 0=0.5
+}
+
+// applyLM sets the LM of Config.LMs for lang on c, if there is one.
+func (e *Engine) applyLM(lang string, c *sherpa.OfflineRecognizerConfig) {
+	lm := e.cfg.LMs[lang]
+	if lm.Model == "" {
+		return
+	}
+	c.LmConfig.Model = lm.Model
+	c.LmConfig.Scale = float32(lm.Scale)
+	if lm.Scale == 0 {
+		c.LmConfig.Scale = 0.5
+	}
+}
+
+// HasLM reports whether the loaded model of lang fuses a language model.
+func (e *Engine) HasLM(lang string) bool {
+	e.muPools.Lock()
+	p := e.pools[e.langModel(lang)]
+	e.muPools.Unlock()
+	return p != nil && p.cfg.LmConfig.Model != ""
+}
@@ -0,0 +1,61 @@
+package moonshine
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	sherpa "github.com/k2-fsa/sherpa-onnx-go/sherpa_onnx"
+)
+
+// --- applyLM ---
+
+func TestApplyLM(t *testing.T) {
+	e := &Engine{cfg: Config{LMs: map[string]LM{"ru": {Model: "/lm/ru.onnx"}, "en": {Model: "/lm/en.onnx", Scale: 0.3}}}}
+	tests := []struct {
+		lang  string
+		model string
+		scale float32
+	}{
+		{"ru", "/lm/ru.onnx", 0.5},
+		{"en", "/lm/en.onnx", 0.3},
+		{"zh", "", 0},
+	}
+	for _, tt := range tests {
+		var c sherpa.OfflineRecognizerConfig
+		e.applyLM(tt.lang, &c)
+		if c.LmConfig.Model != tt.model || c.LmConfig.Scale != tt.scale {
+			t.Errorf("applyLM(%q) = %+v, want {%s %g}", tt.lang, c.LmConfig, tt.model, tt.scale)
+		}
+	}
+}
+
+// --- HasLM ---
+
+func TestHasLM(t *testing.T) {
+	p := testPool(1)
+	p.cfg.LmConfig.Model = "/lm/ru.onnx"
+	e := &Engine{pools: map[string]*recognizerPool{"ru": p, "en": testPool(1)}}
+	for lang, want := range map[string]bool{"ru": true, "en": false, "zh": false} {
+		if got := e.HasLM(lang); got != want {
+			t.Errorf("HasLM(%q) = %v, want %v", lang, got, want)
+		}
+	}
+}
+
+// --- newZipformerRecognizer ---
+
+func TestNewZipformerRecognizer_MissingLM(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"encoder.int8.onnx", "decoder.int8.onnx", "joiner.int8.onnx", "tokens.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	e := &Engine{cfg: Config{LMs: map[string]LM{"ru": {Model: filepath.Join(dir, "lm.onnx")}}}}
+	if _, _, err := e.newZipformerRecognizer("ru", dir); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("missing LM: err = %v, want fs.ErrNotExist", err)
+	}
+}
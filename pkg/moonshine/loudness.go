@@ -0,0 +1,78 @@
+package moonshine
+
+import "math"
+
+// Loudness normalization brings quiet (and overly loud) recordings to a
+// level the VAD and the recognizers are tuned for. Loudness is measured
+// over gated blocks, as in EBU R128: silent blocks are left out, then
+// blocks well below the average, so pauses do not make speech look quiet.
+const (
+	loudnessBlockSamples = 1600  // 100 ms at 16 kHz
+	loudnessTargetDB     = -20.0 // RMS level of the gated blocks, dBFS
+	loudnessAbsGateDB    = -70.0 // blocks below this are silence
+	loudnessRelGateDB    = -10.0 // blocks this far below the average are left out
+	loudnessMaxGainDB    = 40.0
+	loudnessPeak         = 0.99 // gain never pushes a sample past this
+)
+
+// normalizeLoudness returns a copy of samples with a gain applied so their
+// gated RMS level is loudnessTargetDB, limited by loudnessMaxGainDB and so
+// the peak stays below loudnessPeak. Silent audio is returned as is.
+func normalizeLoudness(samples []float32) []float32 {
+	level, ok := gatedLevel(samples)
+	if !ok {
+		return samples
+	}
+	gainDB := min(loudnessTargetDB-level, loudnessMaxGainDB)
+	gain := math.Pow(10, gainDB/20)
+	var peak float64
+	for _, s := range samples {
+		peak = max(peak, math.Abs(float64(s)))
+	}
+	if peak*gain > loudnessPeak {
+		gain = loudnessPeak / peak
+	}
+	out := make([]float32, len(samples))
+	for i, s := range samples {
+		out[i] = float32(float64(s) * gain)
+	}
+	return out
+}
+
+// gatedLevel returns the RMS level in dBFS of the blocks of samples that
+// pass the absolute and relative gates, or false when none do.
+func gatedLevel(samples []float32) (float64, bool) {
+	var powers []float64
+	for start := 0; start < len(samples); start += loudnessBlockSamples {
+		block := samples[start:min(start+loudnessBlockSamples, len(samples))]
+		var sum float64
+		for _, s := range block {
+			sum += float64(s) * float64(s)
+		}
+		if p := sum / float64(len(block)); dB(p) > loudnessAbsGateDB {
+			powers = append(powers, p)
+		}
+	}
+	if len(powers) == 0 {
+		return 0, false
+	}
+	gate := dB(meanOf(powers)) + loudnessRelGateDB
+	var kept []float64
+	for _, p := range powers {
+		if dB(p) > gate {
+			kept = append(kept, p)
+		}
+	}
+	return dB(meanOf(kept)), true
+}
+
+// dB converts a mean square power to decibels.
+func dB(power float64) float64 { return 10 * math.Log10(power) }
+
+func meanOf(xs []float64) float64 {
+	var sum float64
+	for _, x := range xs {
+		sum += x
+	}
+	return sum / float64(len(xs))
+}
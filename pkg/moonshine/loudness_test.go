@@ -0,0 +1,60 @@
+package moonshine
+
+import (
+	"math"
+	"testing"
+)
+
+// sine returns n samples of a 440 Hz tone at 16 kHz with the given peak.
+func sine(n int, peak float64) []float32 {
+	s := make([]float32, n)
+	for i := range s {
+		s[i] = float32(peak * math.Sin(2*math.Pi*440*float64(i)/16000))
+	}
+	return s
+}
+
+// --- normalizeLoudness ---
+
+func TestNormalizeLoudness(t *testing.T) {
+	quiet := sine(16000, 0.01) // about -43 dBFS RMS
+	got := normalizeLoudness(quiet)
+	level, _ := gatedLevel(got)
+	if math.Abs(level-loudnessTargetDB) > 0.1 {
+		t.Errorf("quiet: level = %.2f dBFS, want %.0f", level, loudnessTargetDB)
+	}
+	if quiet[100] == got[100] {
+		t.Error("quiet: input was modified or not amplified")
+	}
+
+	// A quiet tone with one loud click is limited by its peak.
+	clicked := sine(16000, 0.001)
+	clicked[8000] = 0.5
+	var peak float64
+	for _, s := range normalizeLoudness(clicked) {
+		peak = max(peak, math.Abs(float64(s)))
+	}
+	if peak > loudnessPeak+1e-6 {
+		t.Errorf("clicked: peak = %.4f, want <= %.2f", peak, loudnessPeak)
+	}
+
+	// Pauses are gated out: speech and half silence get the same gain.
+	paused := append(sine(16000, 0.01), make([]float32, 16000)...)
+	if a, b := normalizeLoudness(quiet)[100], normalizeLoudness(paused)[100]; math.Abs(float64(a-b)) > 1e-6 {
+		t.Errorf("paused: sample = %v, want %v", b, a)
+	}
+
+	silent := make([]float32, 16000)
+	if got := normalizeLoudness(silent); &got[0] != &silent[0] {
+		t.Error("silent: want the input back")
+	}
+}
+
+func TestNormalizeLoudness_MaxGain(t *testing.T) {
+	faint := sine(16000, 0.001) // about -63 dBFS RMS, 43 dB below the target
+	got := normalizeLoudness(faint)
+	gainDB := 20 * math.Log10(float64(got[100]/faint[100]))
+	if math.Abs(gainDB-loudnessMaxGainDB) > 0.01 {
+		t.Errorf("gain = %.2f dB, want %.0f", gainDB, loudnessMaxGainDB)
+	}
+}
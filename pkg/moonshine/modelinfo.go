@@ -0,0 +1,86 @@
+package moonshine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	sherpa "github.com/k2-fsa/sherpa-onnx-go/sherpa_onnx"
+)
+
+// ModelInfo identifies the build of a loaded recognizer model.
+type ModelInfo struct {
+	Dir       string            `json:"dir"`
+	Files     map[string]string `json:"files"`               // file name in Dir → SHA-256, hex
+	Precision string            `json:"precision,omitempty"` // int8 or fp32; "" when the model comes in one precision
+	LoadedAt  time.Time         `json:"loaded_at"`
+	LoadS     float64           `json:"load_s"`    // time to load its recognizers and hash its files
+	Threads   int               `json:"threads"`   // ONNX Runtime intra-op threads of each recognizer
+	Instances int               `json:"instances"` // recognizers loaded
+}
+
+// newModelInfo describes the model loaded from dir with c, started at
+// start: it hashes every file c names.
+func newModelInfo(dir string, c sherpa.OfflineRecognizerConfig, start time.Time) (ModelInfo, error) {
+	info := ModelInfo{Dir: dir, Files: map[string]string{}, Threads: c.ModelConfig.NumThreads}
+	for _, path := range modelFiles(c) {
+		sum, err := fileSHA256(path)
+		if err != nil {
+			return ModelInfo{}, err
+		}
+		info.Files[filepath.Base(path)] = sum
+		if info.Precision == "" && filepath.Ext(path) == ".onnx" {
+			info.Precision = precisionOf(path)
+		}
+	}
+	info.LoadedAt = time.Now().UTC()
+	info.LoadS = time.Since(start).Seconds()
+	return info, nil
+}
+
+// modelFiles returns the paths of the files c loads.
+func modelFiles(c sherpa.OfflineRecognizerConfig) []string {
+	mc := c.ModelConfig
+	var files []string
+	for _, p := range []string{
+		mc.Moonshine.Preprocessor, mc.Moonshine.Encoder, mc.Moonshine.UncachedDecoder, mc.Moonshine.CachedDecoder, mc.Moonshine.MergedDecoder,
+		mc.Transducer.Encoder, mc.Transducer.Decoder, mc.Transducer.Joiner,
+		mc.Whisper.Encoder, mc.Whisper.Decoder,
+		mc.Paraformer.Model, mc.NemoCTC.Model, mc.SenseVoice.Model,
+		mc.Tokens, mc.BpeVocab, c.LmConfig.Model,
+	} {
+		if p != "" {
+			files = append(files, p)
+		}
+	}
+	return files
+}
+
+// fileSHA256 returns the hex SHA-256 of the file at path.
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close() //nolint:errcheck
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ModelInfo returns the directory, file checksums, precision, and load
+// time of the recognizer loaded for lang, or false when none is.
+func (e *Engine) ModelInfo(lang string) (ModelInfo, bool) {
+	e.muPools.Lock()
+	p := e.pools[e.langModel(lang)]
+	e.muPools.Unlock()
+	if p == nil {
+		return ModelInfo{}, false
+	}
+	return p.info, true
+}
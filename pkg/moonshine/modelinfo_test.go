@@ -0,0 +1,59 @@
+package moonshine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	sherpa "github.com/k2-fsa/sherpa-onnx-go/sherpa_onnx"
+)
+
+// --- newModelInfo ---
+
+func TestNewModelInfo(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"encoder.int8.onnx", "decoder.int8.onnx", "joiner.int8.onnx", "tokens.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("abc"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var c sherpa.OfflineRecognizerConfig
+	c.ModelConfig.NumThreads = 4
+	c.ModelConfig.Transducer.Encoder = filepath.Join(dir, "encoder.int8.onnx")
+	c.ModelConfig.Transducer.Decoder = filepath.Join(dir, "decoder.int8.onnx")
+	c.ModelConfig.Transducer.Joiner = filepath.Join(dir, "joiner.int8.onnx")
+	c.ModelConfig.Tokens = filepath.Join(dir, "tokens.txt")
+
+	start := time.Now()
+	info, err := newModelInfo(dir, c, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const abc = "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+	if len(info.Files) != 4 || info.Files["encoder.int8.onnx"] != abc || info.Files["tokens.txt"] != abc {
+		t.Errorf("files = %v", info.Files)
+	}
+	if info.Dir != dir || info.Precision != PrecisionInt8 || info.LoadedAt.Before(start) || info.LoadS < 0 || info.Threads != 4 {
+		t.Errorf("info = %+v", info)
+	}
+
+	c.ModelConfig.BpeVocab = filepath.Join(dir, "bpe.vocab")
+	if _, err := newModelInfo(dir, c, start); err == nil {
+		t.Error("missing file: no error")
+	}
+}
+
+// --- Engine.ModelInfo ---
+
+func TestEngineModelInfo(t *testing.T) {
+	p := testPool(1)
+	p.info = ModelInfo{Dir: "/models/ru"}
+	e := &Engine{pools: map[string]*recognizerPool{"ru": p}}
+	if info, ok := e.ModelInfo("ru"); !ok || info.Dir != "/models/ru" {
+		t.Errorf("ModelInfo(ru) = %+v, %v", info, ok)
+	}
+	if _, ok := e.ModelInfo("en"); ok {
+		t.Error("ModelInfo(en) found without a loaded model")
+	}
+}
@@ -0,0 +1,325 @@
+package moonshine
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	sherpa "github.com/k2-fsa/sherpa-onnx-go/sherpa_onnx"
+)
+
+// loadModels loads the EN, RU, and ZH recognizers in parallel, unless
+// Config.LazyLoad defers them, then the optional telephony, accurate,
+// translation, streaming, VAD, punctuation, diarization, audio tagging,
+// language ID, speaker embedding, emotion, and denoising models.
+func (e *Engine) loadModels() error {
+	if e.cfg.LazyLoad {
+		if _, err := os.Stat(e.cfg.modelDirs()["en"]); err != nil {
+			return fmt.Errorf("EN model: %w", err)
+		}
+		e.logf("Language models load on first use")
+	} else if err := e.loadLanguageModels(); err != nil {
+		return err
+	}
+	e.loadTelephonyModels()
+	if e.cfg.AccurateModelsDir != "" {
+		e.loadAccurateModel()
+	}
+	if e.cfg.TranslateModelDir != "" {
+		e.loadTranslateModel()
+	}
+	e.loadStreamingModels()
+
+	if _, err := os.Stat(e.cfg.VADModel); err == nil {
+		e.initVAD(e.cfg.VADModel)
+	} else {
+		e.logf("%s VAD not found at %s, VAD disabled", vadName(e.cfg.vadBackend()), e.cfg.VADModel)
+	}
+
+	if _, errM := os.Stat(e.cfg.PunctModel); errM == nil {
+		if _, errV := os.Stat(e.cfg.PunctVocab); errV == nil {
+			e.initPunctuation(e.cfg.PunctModel, e.cfg.PunctVocab)
+		} else {
+			e.logf("Punctuation vocab not found at %s, punctuation disabled", e.cfg.PunctVocab)
+		}
+	} else {
+		e.logf("Punctuation model not found at %s, punctuation disabled", e.cfg.PunctModel)
+	}
+
+	if _, errS := os.Stat(e.cfg.DiarizeSegmentationModel); errS == nil {
+		if _, errE := os.Stat(e.cfg.DiarizeEmbeddingModel); errE == nil {
+			e.initDiarization(e.cfg.DiarizeSegmentationModel, e.cfg.DiarizeEmbeddingModel)
+		} else {
+			e.logf("Speaker embedding model not found at %s, diarization disabled", e.cfg.DiarizeEmbeddingModel)
+		}
+	} else {
+		e.logf("Diarization model not found at %s, diarization disabled", e.cfg.DiarizeSegmentationModel)
+	}
+
+	if _, errM := os.Stat(e.cfg.TaggingModel); errM == nil {
+		if _, errL := os.Stat(e.cfg.TaggingLabels); errL == nil {
+			e.initTagging(e.cfg.TaggingModel, e.cfg.TaggingLabels)
+		} else {
+			e.logf("Audio tagging labels not found at %s, audio tagging disabled", e.cfg.TaggingLabels)
+		}
+	} else {
+		e.logf("Audio tagging model not found at %s, audio tagging disabled", e.cfg.TaggingModel)
+	}
+
+	if _, errE := os.Stat(e.cfg.LIDEncoder); errE == nil {
+		if _, errD := os.Stat(e.cfg.LIDDecoder); errD == nil {
+			e.initLID(e.cfg.LIDEncoder, e.cfg.LIDDecoder)
+		} else {
+			e.logf("Language ID decoder not found at %s, language ID disabled", e.cfg.LIDDecoder)
+		}
+	} else {
+		e.logf("Language ID encoder not found at %s, language ID disabled", e.cfg.LIDEncoder)
+	}
+
+	if _, err := os.Stat(e.cfg.SpeakerModel); err == nil {
+		e.initSpeakerEmbedding(e.cfg.SpeakerModel)
+	} else {
+		e.logf("Speaker embedding model not found at %s, speaker identification disabled", e.cfg.SpeakerModel)
+	}
+
+	if e.cfg.EmotionModelDir != "" {
+		e.initEmotion(e.cfg.EmotionModelDir)
+	}
+
+	if !e.HasFFmpeg() {
+		e.logf("ffmpeg not found at %q, only WAV, MP3, FLAC, and Ogg Vorbis can be decoded", e.ffmpegPath())
+	}
+
+	if e.cfg.DenoiseModel != "" {
+		if _, err := os.Stat(e.cfg.DenoiseModel); err == nil {
+			e.initDenoiser(e.cfg.DenoiseModel)
+		} else {
+			e.logf("Denoising model not found at %s, denoising disabled", e.cfg.DenoiseModel)
+		}
+	}
+	return nil
+}
+
+// loadLanguageModels loads the EN recognizer and those of the other
+// configured languages in parallel. Only the EN model is required.
+func (e *Engine) loadLanguageModels() error {
+	t0 := time.Now()
+	var wg sync.WaitGroup
+	var errEN error
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		t := time.Now()
+		p, err := e.loadPool("en", e.cfg.modelDirs()["en"])
+		if err != nil {
+			errEN = fmt.Errorf("EN model: %w", err)
+			return
+		}
+		e.setPool("en", p)
+		e.logf("EN model loaded in %.2fs (%d instance(s))", time.Since(t).Seconds(), len(p.all))
+	}()
+
+	for lang, dir := range e.cfg.modelDirs() {
+		if lang == "en" || dir == "" {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			e.loadLangModel(lang, dir)
+		}()
+	}
+
+	wg.Wait()
+	if errEN != nil {
+		return errEN
+	}
+	e.logf("All models loaded in %.2fs", time.Since(t0).Seconds())
+	return nil
+}
+
+// initVAD loads the VAD model of Config.VADBackend.
+func (e *Engine) initVAD(model string) {
+	vadCfg, window := e.vadModelConfig(e.cfg.vadBackend(), model)
+	e.vadDetector = sherpa.NewVoiceActivityDetector(vadCfg, float32(e.cfg.MaxAudioDurationS))
+	if e.vadDetector != nil {
+		e.vadWindow = window
+		e.logf("%s VAD loaded (min_duration=%.0fs)", vadName(e.cfg.vadBackend()), e.cfg.VADMinDurationS)
+	}
+}
+
+// loadLangModel loads the optional model of lang from dir. A missing or
+// broken model leaves the language unavailable.
+func (e *Engine) loadLangModel(lang, dir string) {
+	t := time.Now()
+	name := strings.ToUpper(lang)
+	p, err := e.loadPool(lang, dir)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		e.logf("%s model not found at %s, %s transcription unavailable", name, dir, name)
+	case err != nil:
+		e.logf("WARNING: %s model: %v", name, err)
+	default:
+		e.setPool(lang, p)
+		kind := e.Backend(lang)
+		if p.info.Precision != "" {
+			kind += " " + p.info.Precision
+		}
+		if p.cfg.LmConfig.Model != "" {
+			kind += " + LM"
+		}
+		e.logf("%s model loaded in %.2fs (%s, %s, %d instance(s))", name, time.Since(t).Seconds(), kind, p.cfg.DecodingMethod, len(p.all))
+	}
+}
+
+// loadPool loads Config.poolSize(model) recognizers for model (a built-in or
+// Config.ModelDirs language, or translateModel) from dir and records their ModelInfo.
+func (e *Engine) loadPool(model, dir string) (*recognizerPool, error) {
+	start := time.Now()
+	var r *sherpa.OfflineRecognizer
+	var c sherpa.OfflineRecognizerConfig
+	var err error
+	switch {
+	case model == translateModel:
+		// Multilingual Whisper detects the spoken language itself.
+		r, c, err = e.newWhisperRecognizer(dir, "", TaskTranslate)
+	case e.langModel(model) == model:
+		r, c, err = e.newLangRecognizer(model, dir)
+	default:
+		return nil, fmt.Errorf("unknown language %q", model)
+	}
+	if err != nil {
+		return nil, err
+	}
+	p, err := newRecognizerPool(r, c, e.cfg.poolSize(model))
+	if err != nil {
+		return nil, err
+	}
+	if p.info, err = newModelInfo(dir, c, start); err != nil {
+		p.delete()
+		return nil, err
+	}
+	p.info.Instances = len(p.all)
+	return p, nil
+}
+
+// newMoonshineRecognizer loads the Moonshine model of lang from dir and returns it with the
+// config it was created from.
+func (e *Engine) newMoonshineRecognizer(lang, dir string) (*sherpa.OfflineRecognizer, sherpa.OfflineRecognizerConfig, error) {
+	c := sherpa.OfflineRecognizerConfig{}
+	c.FeatConfig.SampleRate = 16000
+	c.FeatConfig.FeatureDim = 80
+	c.ModelConfig.Moonshine.Encoder = filepath.Join(dir, "encoder_model.ort")
+	c.ModelConfig.Moonshine.MergedDecoder = filepath.Join(dir, "decoder_model_merged.ort")
+	c.ModelConfig.Tokens = filepath.Join(dir, "tokens.txt")
+	c.ModelConfig.NumThreads = e.cfg.threads(lang)
+	c.ModelConfig.Provider = e.Provider()
+	c.DecodingMethod = GreedySearch
+	if err := requireFiles(c.ModelConfig.Moonshine.Encoder, c.ModelConfig.Moonshine.MergedDecoder, c.ModelConfig.Tokens); err != nil {
+		return nil, c, err
+	}
+	r := sherpa.NewOfflineRecognizer(&c)
+	if r == nil {
+		return nil, c, fmt.Errorf("failed to load Moonshine model from %s", dir)
+	}
+	return r, c, nil
+}
+
+// newZipformerRecognizer loads the Zipformer transducer of lang from dir, with
+// its LM of Config.LMs, and returns it with the config it was created from. A missing encoder yields an error
+// wrapping fs.ErrNotExist.
+func (e *Engine) newZipformerRecognizer(lang, dir string) (*sherpa.OfflineRecognizer, sherpa.OfflineRecognizerConfig, error) {
+	c := sherpa.OfflineRecognizerConfig{}
+	c.FeatConfig.SampleRate = 16000
+	c.FeatConfig.FeatureDim = 80
+	c.ModelConfig.Transducer.Encoder = e.onnxFile(dir, "encoder")
+	c.ModelConfig.Transducer.Decoder = e.onnxFile(dir, "decoder")
+	c.ModelConfig.Transducer.Joiner = e.onnxFile(dir, "joiner")
+	c.ModelConfig.Tokens = filepath.Join(dir, "tokens.txt")
+	c.ModelConfig.NumThreads = e.cfg.threads(lang)
+	c.ModelConfig.Provider = e.Provider()
+	c.DecodingMethod = e.cfg.RUDecodingMethod
+	c.MaxActivePaths = e.cfg.RUBeamSize
+	c.HotwordsFile = e.cfg.HotwordsFile
+	c.HotwordsScore = float32(e.cfg.HotwordsScore)
+	e.applyLM(lang, &c)
+	bpeVocab := filepath.Join(dir, "bpe.vocab")
+	if _, err := os.Stat(bpeVocab); err == nil {
+		c.ModelConfig.ModelingUnit = "bpe"
+		c.ModelConfig.BpeVocab = bpeVocab
+	}
+	if err := requireFiles(c.ModelConfig.Transducer.Encoder, c.ModelConfig.Transducer.Decoder,
+		c.ModelConfig.Transducer.Joiner, c.ModelConfig.Tokens); err != nil {
+		return nil, c, err
+	}
+	if c.LmConfig.Model != "" {
+		if err := requireFiles(c.LmConfig.Model); err != nil {
+			return nil, c, fmt.Errorf("language model: %w", err)
+		}
+	}
+	r := sherpa.NewOfflineRecognizer(&c)
+	if r == nil {
+		return nil, c, fmt.Errorf("failed to load Zipformer model from %s", dir)
+	}
+	return r, c, nil
+}
+
+// requireFiles returns an error for the first path that does not exist.
+func requireFiles(paths ...string) error {
+	for _, p := range paths {
+		if _, err := os.Stat(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// warmupRecognizer runs one second of silence through r so the first real
+// request does not pay for lazy initialization.
+func warmupRecognizer(r *sherpa.OfflineRecognizer) {
+	s := sherpa.NewOfflineStream(r)
+	s.AcceptWaveform(16000, make([]float32, 16000))
+	r.Decode(s)
+	sherpa.DeleteOfflineStream(s)
+}
+
+// Warmup runs dummy inference on every loaded recognizer to eliminate
+// first-request latency.
+func (e *Engine) Warmup() {
+	for _, model := range e.models() {
+		e.warmupModel(model)
+	}
+}
+
+// warmupModel runs dummy inference on the recognizers of model, if loaded.
+func (e *Engine) warmupModel(model string) {
+	e.muPools.Lock()
+	p := e.pools[model]
+	if p != nil {
+		p.users.Add(1) // keep a concurrent reload from freeing it
+	}
+	e.muPools.Unlock()
+	if p != nil {
+		p.warmup()
+		p.users.Done()
+	}
+}
+
+// ReloadModel loads the model for lang (one of Languages) from dir, warms it up,
+// and swaps it in. In-flight decodes finish on the old recognizers, which
+// are freed once idle. On error the loaded model is kept.
+func (e *Engine) ReloadModel(lang, dir string) error {
+	p, err := e.loadPool(lang, dir)
+	if err != nil {
+		return err
+	}
+	p.warmup()
+	e.setPool(lang, p)
+	return nil
+}
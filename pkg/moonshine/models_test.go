@@ -0,0 +1,61 @@
+package moonshine
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"log"
+	"testing"
+)
+
+// --- newZipformerRecognizer / newMoonshineRecognizer / newWhisperRecognizer / newParaformerRecognizer / newSenseVoiceRecognizer / newNeMo* ---
+
+func TestNewRecognizers_MissingFiles(t *testing.T) {
+	dir := t.TempDir()
+	e := new(Engine)
+	if _, _, err := e.newMoonshineRecognizer("en", dir); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("newMoonshineRecognizer(empty dir) err = %v, want fs.ErrNotExist", err)
+	}
+	if _, _, err := e.newZipformerRecognizer("ru", dir); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("newZipformerRecognizer(empty dir) err = %v, want fs.ErrNotExist", err)
+	}
+	if _, _, err := e.newWhisperRecognizer(dir, "en", TaskTranscribe); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("newWhisperRecognizer(empty dir) err = %v, want fs.ErrNotExist", err)
+	}
+	if _, _, err := e.newParaformerRecognizer("en", dir); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("newParaformerRecognizer(empty dir) err = %v, want fs.ErrNotExist", err)
+	}
+	if _, _, err := e.newSenseVoiceRecognizer("en", dir); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("newSenseVoiceRecognizer(empty dir) err = %v, want fs.ErrNotExist", err)
+	}
+	if _, _, err := e.newNeMoCTCRecognizer("en", dir); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("newNeMoCTCRecognizer(empty dir) err = %v, want fs.ErrNotExist", err)
+	}
+	if _, _, err := e.newNeMoTransducerRecognizer("en", dir); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("newNeMoTransducerRecognizer(empty dir) err = %v, want fs.ErrNotExist", err)
+	}
+}
+
+// --- New ---
+
+func TestNew_MissingENModel(t *testing.T) {
+	_, err := New(Config{ModelsDir: t.TempDir(), Logger: log.New(io.Discard, "", 0)})
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("New err = %v, want fs.ErrNotExist", err)
+	}
+}
+
+// --- Engine.ReloadModel ---
+
+func TestReloadModel_Errors(t *testing.T) {
+	e := new(Engine)
+	if err := e.ReloadModel("de", t.TempDir()); err == nil {
+		t.Error("expected error for unknown language")
+	}
+	if err := e.ReloadModel("ru", t.TempDir()); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("err = %v, want fs.ErrNotExist", err)
+	}
+	if e.HasLanguage("ru") {
+		t.Error("failed reload must not load a model")
+	}
+}
@@ -0,0 +1,542 @@
+// Package moonshine transcribes speech on the CPU with sherpa-onnx: Moonshine
+// for English, a Zipformer transducer for Russian, Silero VAD chunking,
+// punctuation, speaker diarization, and hallucination filtering.
+//
+// Load the models once with New and share the Engine between goroutines:
+//
+//	e, err := moonshine.New(moonshine.Config{ModelsDir: "/models/en", NumThreads: 4})
+//	if err != nil {
+//		return err
+//	}
+//	defer e.Close()
+//	res, err := e.TranscribeFile(ctx, "call.ogg", moonshine.Options{Lang: "en"})
+package moonshine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	sherpa "github.com/k2-fsa/sherpa-onnx-go/sherpa_onnx"
+)
+
+// Transcriber turns audio into text.
+type Transcriber interface {
+	// TranscribeFile decodes the audio file at path and transcribes it.
+	TranscribeFile(ctx context.Context, path string, opts Options) (Result, error)
+	// TranscribeSamples transcribes mono samples in [-1, 1] at sampleRate.
+	TranscribeSamples(ctx context.Context, samples []float32, sampleRate int, opts Options) (Result, error)
+}
+
+// Errors wrapped by transcription failures, for use with errors.Is.
+// Transcriptions stopped because ctx is done return ctx.Err() instead.
+var (
+	ErrInvalidAudio = errors.New("invalid audio")           // empty, too long, bad rate, or unreadable
+	ErrConversion   = errors.New("audio conversion failed") // ffmpeg could not decode the file
+	ErrUnavailable  = errors.New("model not loaded")        // the requested model is not loaded
+	ErrLoading      = errors.New("model loading")           // the model is loading on first use (Config.LazyLoad); retry shortly
+	ErrNoSpace      = errors.New("not enough disk space")   // the temp directory is short of Config.TempMinFreeMB
+)
+
+// kindError is an error with its own message that matches kind in errors.Is.
+type kindError struct {
+	kind error
+	msg  string
+}
+
+func (e *kindError) Error() string { return e.msg }
+func (e *kindError) Unwrap() error { return e.kind }
+
+// errorf formats an error message that wraps kind.
+func errorf(kind error, format string, args ...any) error {
+	return &kindError{kind: kind, msg: fmt.Sprintf(format, args...)}
+}
+
+// Config selects the models an Engine loads and tunes the pipeline. Optional
+// models whose files are missing are skipped; zero numeric fields take the
+// defaults noted below.
+type Config struct {
+	ModelsDir   string // Moonshine (EN) model directory, required
+	RUModelsDir string // Zipformer (RU) transducer directory, optional
+	ZHModelsDir string // Paraformer (ZH) directory, optional
+	NumThreads  int    // 0=1
+	PoolSize    int    // recognizers per model, decoding chunks in parallel; 0=1
+	Provider    string // ONNX Runtime execution provider, one of Providers(); ""=the platform default
+
+	// LazyLoad defers loading the recognizer of each language (EN, RU, ZH,
+	// and ModelDirs) to its first use, which starts the load in the
+	// background and fails with ErrLoading until it is ready, unless
+	// Options.WaitForLoad is set. Other models load in New.
+	LazyLoad bool
+
+	// ModelDirs maps a language (see ValidLanguage) to its model directory,
+	// overriding ModelsDir, RUModelsDir, or ZHModelsDir for en, ru, or zh.
+	// Any other language runs on the backend of Backends, Moonshine by
+	// default. Optional.
+	ModelDirs map[string]string
+
+	// PoolSizes maps a language to the recognizers loaded for its model,
+	// overriding PoolSize, so capacity can follow each language's share of
+	// traffic. Its telephony and accurate models get as many. Optional.
+	PoolSizes map[string]int
+
+	// ModelThreads maps a language to the ONNX Runtime intra-op threads of
+	// each recognizer of its models, overriding NumThreads. Optional.
+	ModelThreads map[string]int
+
+	// StreamingModels maps a language to a streaming Zipformer transducer
+	// directory, for NewStream. Optional.
+	StreamingModels map[string]string
+
+	// TelephonyModels maps a language to the directory of a model tuned for
+	// 8 kHz telephone speech, with the same architecture as the language's
+	// model. Optional; see Options.Telephony.
+	TelephonyModels map[string]string
+
+	// Backends maps a language to the backend its model directory holds
+	// (see ValidBackend); a language left out keeps its default (Moonshine
+	// for EN, Zipformer for RU, Paraformer for ZH). Its telephony and accurate models share the backend. Optional.
+	Backends map[string]string
+
+	// AccurateModelsDir is a larger Moonshine (EN) model directory, such as
+	// base next to the tiny model of ModelsDir, serving Options.Quality
+	// QualityAccurate. Optional.
+	AccurateModelsDir string
+
+	// TranslateModelDir is a multilingual Whisper directory
+	// (encoder.int8.onnx, decoder.int8.onnx, tokens.txt) serving
+	// TaskTranslate. Optional.
+	TranslateModelDir string
+
+	// ModelPrecision picks the quantized or full-precision files of the
+	// Zipformer, Whisper, and SenseVoice directories: PrecisionAuto (""),
+	// PrecisionInt8, or PrecisionFP32. Moonshine ships one precision.
+	ModelPrecision string
+
+	// RUDecodingMethod is the decoder Zipformer transducers are built with,
+	// ""=greedy_search, the fastest. Hotwords (HotwordsFile and
+	// Options.Hotwords) and LMs need modified_beam_search, which costs
+	// latency on every call.
+	RUDecodingMethod string
+	RUBeamSize       int     // 0=4
+	HotwordsFile     string  // default hotwords for the RU transducer
+	HotwordsScore    float64 // 0=1.5
+
+	// LMs maps a language to the language model its Zipformer transducer
+	// fuses into modified_beam_search. Optional.
+	LMs map[string]LM
+
+	VADModel        string  // VAD model of VADBackend, optional
+	VADBackend      string  // VADSilero ("") or VADTen
+	VADThreshold    float64 // 0=0.5
+	VADMinSilenceS  float64
+	VADMinSpeechS   float64
+	VADMinDurationS float64 // shorter audio skips VAD unless Options.VAD forces it
+
+	// ChunkOverlapS is how many seconds neighbouring pieces share when long
+	// audio is split without VAD (Whisper models, long speaker turns), so
+	// words at the seams are heard whole; the words recognized twice are
+	// merged. 0=no overlap.
+	ChunkOverlapS float64
+
+	PunctModel string // CNN-BiLSTM punctuation model, optional
+	PunctVocab string
+
+	DiarizeSegmentationModel string // pyannote segmentation model, optional
+	DiarizeEmbeddingModel    string
+	DiarizeThreshold         float64 // 0=0.5
+	DiarizeMaxSpeakers       int     // 0=unlimited
+
+	TaggingModel     string // audio tagging (sound event) model, optional
+	TaggingModelType string // TaggingZipformer or TaggingCED; ""=TaggingZipformer
+	TaggingLabels    string // class_labels_indices.csv of the model
+
+	LIDEncoder string // Whisper encoder for spoken language ID, optional
+	LIDDecoder string
+
+	SpeakerModel string // speaker embedding model for SpeakerEmbedding, optional
+
+	EmotionModelDir string // SenseVoice directory whose emotion tags serve Options.Emotions, optional
+
+	DenoiseModel string // GTCRN speech enhancement model for Options.Denoise, optional
+
+	MaxAudioDurationS float64 // 0=300
+	NativeDecode      bool    // decode WAV, MP3, FLAC, and Ogg Vorbis in-process before trying ffmpeg
+
+	FFmpegPath     string        // ffmpeg binary; ""="ffmpeg" on PATH
+	FFprobePath    string        // ffprobe binary; ""="ffprobe" on PATH
+	ProbeInput     bool          // check files with ffprobe before converting them
+	FFmpegArgs     []string      // extra ffmpeg options, placed before the input
+	FFmpegTimeout  time.Duration // per ffmpeg or ffprobe run; 0=none
+	FFmpegMaxProcs int           // ffmpeg and ffprobe processes at once; 0=unlimited
+
+	TempDir       string // for converted WAVs; ""=os.TempDir()
+	TempMinFreeMB int    // refuse conversions with less free space in TempDir; 0=off
+
+	HallucinationMaxRatio   float64 // 0=off
+	HallucinationMaxRepeats int     // 0=off
+
+	Logger *log.Logger // nil=log.Default()
+}
+
+// withDefaults fills in the zero numeric fields.
+func (c Config) withDefaults() Config {
+	if c.NumThreads <= 0 {
+		c.NumThreads = 1
+	}
+	if c.PoolSize <= 0 {
+		c.PoolSize = 1
+	}
+	if c.RUDecodingMethod == "" {
+		c.RUDecodingMethod = GreedySearch
+	}
+	if c.RUBeamSize <= 0 {
+		c.RUBeamSize = 4
+	}
+	if c.HotwordsScore <= 0 {
+		c.HotwordsScore = 1.5
+	}
+	if c.VADThreshold <= 0 {
+		c.VADThreshold = 0.5
+	}
+	if c.DiarizeThreshold <= 0 {
+		c.DiarizeThreshold = 0.5
+	}
+	if c.TaggingModelType == "" {
+		c.TaggingModelType = TaggingZipformer
+	}
+	if c.MaxAudioDurationS <= 0 {
+		c.MaxAudioDurationS = 300
+	}
+	if c.Logger == nil {
+		c.Logger = log.Default()
+	}
+	return c
+}
+
+// Options are the per-call settings of the recognition pipeline.
+type Options struct {
+	Lang        string // "en", "ru", "zh", or LangAuto
+	VAD         *bool  // nil=auto, false=skip
+	Punctuate   *bool  // nil=auto, true=force
+	Diarize     bool
+	Emotions    bool   // label the audio and each segment with emotions
+	ITN         bool   // write spoken numbers, amounts, dates, and times in written form (EN, RU)
+	Denoise     bool   // suppress background noise before VAD and recognition
+	Normalize   bool   // bring the audio to a standard loudness before VAD and recognition
+	TrimSilence bool   // strip leading and trailing silence by amplitude, even without VAD
+	DTMF        bool   // report DTMF key presses and keep them from the recognizer
+	SkipMusic   bool   // leave music without speech undecoded; needs the tagging model
+	AudioTrack  int    // files: 0-based audio track to transcribe, as of a multi-track video
+	Task        string // TaskTranscribe (""), or TaskTranslate for English text
+	MaxSpeakers int    // 0=Config.DiarizeMaxSpeakers
+	Hotwords    string // sherpa-onnx hotwords, one "phrase :boost" per line
+	Telephony   *bool  // use the telephony model; nil=for audio at 8 kHz or less, if loaded
+	Quality     string // QualityFast ("") or QualityAccurate for the larger EN model
+
+	// AutoLanguages limits the languages LangAuto chooses from; nil=all.
+	AutoLanguages []string
+
+	// MaxAudioDurationS lowers Config.MaxAudioDurationS for this call; 0 or
+	// a larger value keeps the configured limit.
+	MaxAudioDurationS float64
+
+	// VADMinDurationS replaces Config.VADMinDurationS for this call; 0 keeps
+	// the configured value.
+	VADMinDurationS float64
+
+	// SplitChannels transcribes each channel of a file separately instead of
+	// downmixing; TranscribeFile only.
+	SplitChannels bool
+
+	// Channel picks how a multichannel file is reduced to mono: ChannelMix
+	// ("") averages the channels, ChannelLeft, ChannelRight, or a 0-based
+	// channel number transcribes that channel alone; TranscribeFile only.
+	Channel string
+
+	// DecodingMethod and BeamSize confirm the RU transducer's decoding:
+	// a value other than Config.RUDecodingMethod or Config.RUBeamSize fails
+	// (see Engine.CheckDecoding). ""/0=as configured.
+	DecodingMethod string
+	BeamSize       int
+
+	// NumThreads caps the ONNX threads the call uses across chunks decoded
+	// in parallel; 0=the model's threads for each recognizer of its pool.
+	NumThreads int
+	Priority   string // PriorityNormal ("") or PriorityLow
+
+	// WaitForLoad waits for a model being loaded with Config.LazyLoad
+	// instead of failing with ErrLoading.
+	WaitForLoad bool
+
+	TwoPass *bool // streams only: re-decode final utterances offline; nil=if the offline model is loaded
+
+	// Endpointing of streams: an utterance ends after EndpointSilenceS of
+	// silence following speech, after EndpointIdleS with no speech, or
+	// once it is EndpointMaxUtteranceS long. 0 takes the defaults of
+	// NewStream.
+	EndpointSilenceS      float64
+	EndpointIdleS         float64
+	EndpointMaxUtteranceS float64
+
+	// Logger, when set, receives the log lines of this call in place of
+	// Config.Logger, for example to tag them with a request ID.
+	Logger *log.Logger
+
+	// Progress, when set, is called after each chunk (or speaker turn) is
+	// decoded with the number done and the total. Calls are serialized.
+	Progress func(done, total int)
+
+	// OnSegment, when set, is called with each finished segment as soon as
+	// it and all before it are decoded, so results can be shown before the
+	// whole audio is done. Segments come in order, per channel with
+	// SplitChannels, and lack per-segment emotions; the Result has them all.
+	// Without VAD or diarization the segments come once decoding ends.
+	// Calls are serialized.
+	OnSegment func(Segment)
+
+	// OnChunk, when set, is called with each decoded chunk in chunk order,
+	// out of total, so a long call can be checkpointed and continued with
+	// Resume. It is not called when diarizing or with SplitChannels.
+	// Calls are serialized.
+	OnChunk func(i, total int, c ChunkResult)
+
+	// Resume continues an interrupted call on the same audio with the same
+	// options: its chunks are not decoded again. It is ignored when the
+	// audio splits into a different number of chunks.
+	Resume *Checkpoint
+
+	// OnDecoded, when set, is called by TranscribeFile with the decoded
+	// mono audio before it is transcribed, e.g. to keep a copy (see
+	// WriteWav). It is not called with SplitChannels.
+	OnDecoded func(samples []float32, sampleRate int)
+
+	model     string                   // recognizer pool chosen by TranscribeSamples; ""=the language's
+	chunkDone func(i int, t chunkText) // called by recognizeChunks in chunk order
+	resumed   map[int]ChunkResult      // chunks recognizeChunks does not decode
+}
+
+// ChunkResult is the recognized text of one chunk of a call, as passed to
+// Options.OnChunk.
+type ChunkResult struct {
+	Text     string `json:"text,omitempty"` // "" when filtered
+	Raw      string `json:"raw,omitempty"`  // recognizer output before filtering
+	Filtered bool   `json:"filtered,omitempty"`
+	Emotion  string `json:"emotion,omitempty"`
+	Event    string `json:"event,omitempty"`
+}
+
+// Checkpoint holds the chunks of a call decoded so far, by chunk index, out
+// of Total.
+type Checkpoint struct {
+	Total  int                 `json:"total"`
+	Chunks map[int]ChunkResult `json:"chunks"`
+}
+
+// Result is a finished transcription.
+type Result struct {
+	Text     string
+	Language string         // language transcribed; the detected one for LangAuto
+	Segments []Segment      // VAD or decoded chunks, or speaker turns when diarizing
+	AudioS   float64        // length of the input audio in seconds
+	SpeechMs float64        // speech found by VAD or diarization
+	Filtered bool           // some text was suppressed as a hallucination
+	RawText  string         // transcript before suppression, when filtered
+	Emotions []EmotionScore // of the whole audio, with Options.Emotions
+	DTMF     []DTMFEvent    // key presses, with Options.DTMF
+	Skipped  []Span         // music left undecoded, with Options.SkipMusic
+}
+
+// Segment is a time-aligned piece of the transcript.
+type Segment struct {
+	Start   float64 `json:"start"` // seconds from the start of the audio
+	End     float64 `json:"end"`
+	Speaker string  `json:"speaker,omitempty"`
+	Channel *int    `json:"channel,omitempty"` // source channel with Options.SplitChannels
+	Text    string  `json:"text"`
+	Speech  []Span  `json:"speech,omitempty"` // VAD speech regions the text came from
+
+	Emotions []EmotionScore `json:"emotions,omitempty"` // with Options.Emotions
+
+	// Emotion and Event are the tags the SenseVoice backend gives the
+	// segment's audio, such as "happy" and "laughter"; empty for other
+	// backends, and Event for plain speech.
+	Emotion string `json:"emotion,omitempty"`
+	Event   string `json:"event,omitempty"`
+
+	Filtered bool   `json:"filtered,omitempty"` // text was suppressed as a hallucination
+	RawText  string `json:"raw_text,omitempty"` // recognizer output when filtered
+}
+
+// Span is a time range of the original audio in seconds.
+type Span struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+// Engine holds the loaded models and implements Transcriber. It is safe for
+// concurrent use; each recognizer decodes one stream at a time, and
+// Config.PoolSize recognizers per model decode in parallel. The zero Engine
+// has no models loaded and fails every transcription with ErrUnavailable.
+type Engine struct {
+	cfg    Config
+	filter hallucinationFilter
+
+	ffmpegSlots chan struct{} // Config.FFmpegMaxProcs process slots; nil=unlimited
+
+	muPools sync.Mutex
+	pools   map[string]*recognizerPool // "en" (Moonshine), "ru" (Zipformer), "zh" (Paraformer), their "/telephony" variants, "en/accurate", and translateModel (Whisper)
+
+	muLoad  sync.Mutex
+	loading map[string]chan struct{} // models loading with Config.LazyLoad, closed when done
+	failed  map[string]bool          // models that failed to load with Config.LazyLoad
+
+	muVAD       sync.Mutex
+	vadDetector *sherpa.VoiceActivityDetector
+	vadWindow   int // samples the detector consumes at a time
+
+	muPunct    sync.Mutex
+	punctuator *sherpa.OnlinePunctuation
+
+	muDiarize   sync.Mutex
+	diarizer    *sherpa.OfflineSpeakerDiarization
+	diarizerCfg sherpa.OfflineSpeakerDiarizationConfig
+
+	muTagging sync.Mutex
+	tagger    *sherpa.AudioTagging
+
+	muLID sync.Mutex
+	lid   *sherpa.SpokenLanguageIdentification
+
+	muEmbed  sync.Mutex
+	embedder *sherpa.SpeakerEmbeddingExtractor
+
+	muEmotion sync.Mutex
+	emotion   *sherpa.OfflineRecognizer // SenseVoice, for its emotion tags
+
+	muDenoise sync.Mutex
+	denoiser  *sherpa.OfflineSpeechDenoiser
+
+	online map[string]*onlineModel // language -> streaming model
+}
+
+var _ Transcriber = (*Engine)(nil)
+
+// New loads the models selected by cfg. Only the EN model is required; the
+// RU, translation, streaming, VAD, punctuation, diarization, audio tagging,
+// language ID, speaker embedding, emotion, and denoising models are skipped
+// with a log line when their files are missing.
+func New(cfg Config) (*Engine, error) {
+	e := &Engine{cfg: cfg.withDefaults()}
+	e.filter = newHallucinationFilter(e.cfg.HallucinationMaxRatio, e.cfg.HallucinationMaxRepeats)
+	if e.cfg.FFmpegMaxProcs > 0 {
+		e.ffmpegSlots = make(chan struct{}, e.cfg.FFmpegMaxProcs)
+	}
+	if err := e.loadModels(); err != nil {
+		e.Close()
+		return nil, err
+	}
+	return e, nil
+}
+
+// Close frees the models. The Engine must not be used afterwards.
+func (e *Engine) Close() {
+	for _, model := range e.models() {
+		e.setPool(model, nil)
+	}
+	if e.vadDetector != nil {
+		sherpa.DeleteVoiceActivityDetector(e.vadDetector)
+		e.vadDetector = nil
+	}
+	if e.punctuator != nil {
+		sherpa.DeleteOnlinePunctuation(e.punctuator)
+		e.punctuator = nil
+	}
+	if e.diarizer != nil {
+		sherpa.DeleteOfflineSpeakerDiarization(e.diarizer)
+		e.diarizer = nil
+	}
+	if e.tagger != nil {
+		sherpa.DeleteAudioTagging(e.tagger)
+		e.tagger = nil
+	}
+	if e.lid != nil {
+		sherpa.DeleteSpokenLanguageIdentification(e.lid)
+		e.lid = nil
+	}
+	if e.embedder != nil {
+		sherpa.DeleteSpeakerEmbeddingExtractor(e.embedder)
+		e.embedder = nil
+	}
+	if e.emotion != nil {
+		sherpa.DeleteOfflineRecognizer(e.emotion)
+		e.emotion = nil
+	}
+	if e.denoiser != nil {
+		sherpa.DeleteOfflineSpeechDenoiser(e.denoiser)
+		e.denoiser = nil
+	}
+	for lang, m := range e.online {
+		sherpa.DeleteOnlineRecognizer(m.r)
+		delete(e.online, lang)
+	}
+}
+
+// SherpaVersion returns the version of the linked sherpa-onnx library.
+func SherpaVersion() string { return sherpa.GetVersion() }
+
+// HasLanguage reports whether the recognizer for lang is loaded.
+func (e *Engine) HasLanguage(lang string) bool { return e.hasModel(lang) }
+
+// hasModel reports whether the pool for model is loaded.
+func (e *Engine) hasModel(model string) bool {
+	e.muPools.Lock()
+	defer e.muPools.Unlock()
+	return e.pools[model] != nil
+}
+
+// HasVAD reports whether the VAD model is loaded.
+func (e *Engine) HasVAD() bool { return e.vadDetector != nil }
+
+// HasPunctuation reports whether the punctuation model is loaded.
+func (e *Engine) HasPunctuation() bool { return e.punctuator != nil }
+
+// HasDiarization reports whether the diarization models are loaded.
+func (e *Engine) HasDiarization() bool { return e.diarizer != nil }
+
+// HasTagging reports whether the audio tagging model is loaded.
+func (e *Engine) HasTagging() bool { return e.tagger != nil }
+
+// HasLID reports whether the language ID model is loaded.
+func (e *Engine) HasLID() bool { return e.lid != nil }
+
+// HasSpeakerEmbedding reports whether the speaker embedding model is loaded.
+func (e *Engine) HasSpeakerEmbedding() bool { return e.embedder != nil }
+
+// HasEmotion reports whether the emotion model is loaded.
+func (e *Engine) HasEmotion() bool { return e.emotion != nil }
+
+// HasDenoise reports whether the denoising model is loaded.
+func (e *Engine) HasDenoise() bool { return e.denoiser != nil }
+
+// logf logs through Config.Logger.
+func (e *Engine) logf(format string, args ...any) {
+	if e.cfg.Logger == nil {
+		log.Printf(format, args...)
+		return
+	}
+	e.cfg.Logger.Printf(format, args...)
+}
+
+// logCall logs a line of the call made with opts, through Options.Logger
+// when set.
+func (e *Engine) logCall(opts Options, format string, args ...any) {
+	if opts.Logger == nil {
+		e.logf(format, args...)
+		return
+	}
+	opts.Logger.Printf(format, args...)
+}
@@ -0,0 +1,80 @@
+package moonshine
+
+import (
+	"context"
+	"strings"
+)
+
+// Music detection parameters for Options.SkipMusic.
+const (
+	musicWindow     = 2 * 16000 // samples the tagging model hears at a time
+	musicMinWindows = 2         // consecutive music windows to skip, so 4s at least
+	musicSpeechProb = 0.3       // speech this likely keeps a window, as for talk over music
+	musicTopK       = 5
+)
+
+// musicSpans returns the parts of 16 kHz samples the tagging model hears as
+// music without speech, such as hold music and jingles. Each window of
+// musicWindow samples is classified on its own; runs of at least
+// musicMinWindows music windows become spans.
+func (e *Engine) musicSpans(ctx context.Context, samples []float32) ([]Span, error) {
+	var music []bool
+	for off := 0; off < len(samples); off += musicWindow {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		window := samples[off:min(off+musicWindow, len(samples))]
+		music = append(music, len(window) >= musicWindow/2 && isMusic(e.classifySamples(window, musicTopK)))
+	}
+	return musicRuns(music, float64(len(samples))/16000), nil
+}
+
+// isMusic reports whether tags, most likely first, describe music without
+// speech: the top class is a music one and speech is unlikely.
+func isMusic(tags []AudioTag) bool {
+	if len(tags) == 0 || !strings.Contains(strings.ToLower(tags[0].Name), "music") {
+		return false
+	}
+	for _, t := range tags {
+		if t.Name == "Speech" && t.Prob >= musicSpeechProb {
+			return false
+		}
+	}
+	return true
+}
+
+// musicRuns turns per-window music flags into spans of at least
+// musicMinWindows windows, the last one ending at durationS.
+func musicRuns(music []bool, durationS float64) []Span {
+	const windowS = float64(musicWindow) / 16000
+	var spans []Span
+	start := -1
+	for i := 0; i <= len(music); i++ {
+		switch {
+		case i < len(music) && music[i]:
+			if start < 0 {
+				start = i
+			}
+		case start >= 0:
+			if i-start >= musicMinWindows {
+				spans = append(spans, Span{Start: float64(start) * windowS, End: min(float64(i)*windowS, durationS)})
+			}
+			start = -1
+		}
+	}
+	return spans
+}
+
+// muteSpans returns a copy of 16 kHz samples with spans silenced, or
+// samples itself when there are none.
+func muteSpans(samples []float32, spans []Span) []float32 {
+	if len(spans) == 0 {
+		return samples
+	}
+	muted := make([]float32, len(samples))
+	copy(muted, samples)
+	for _, sp := range spans {
+		clear(muted[min(int(sp.Start*16000), len(muted)):min(int(sp.End*16000), len(muted))])
+	}
+	return muted
+}
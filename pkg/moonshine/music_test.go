@@ -0,0 +1,73 @@
+package moonshine
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// --- isMusic ---
+
+func TestIsMusic(t *testing.T) {
+	tests := []struct {
+		name string
+		tags []AudioTag
+		want bool
+	}{
+		{"music", []AudioTag{{"Music", 0.8}, {"Musical instrument", 0.4}}, true},
+		{"elevator music", []AudioTag{{"Elevator music", 0.6}, {"Music", 0.5}}, true},
+		{"talk over music", []AudioTag{{"Music", 0.7}, {"Speech", 0.5}}, false},
+		{"faint speech", []AudioTag{{"Music", 0.7}, {"Speech", 0.1}}, true},
+		{"speech", []AudioTag{{"Speech", 0.9}, {"Music", 0.2}}, false},
+		{"none", nil, false},
+	}
+	for _, tt := range tests {
+		if got := isMusic(tt.tags); got != tt.want {
+			t.Errorf("%s: isMusic = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// --- musicRuns ---
+
+func TestMusicRuns(t *testing.T) {
+	tests := []struct {
+		name  string
+		music []bool
+		dur   float64
+		want  []Span
+	}{
+		{"run in the middle", []bool{false, true, true, true, false}, 10, []Span{{Start: 2, End: 8}}},
+		{"single window kept", []bool{true, false, true, true}, 8, []Span{{Start: 4, End: 8}}},
+		{"run at the end", []bool{false, true, true}, 5.5, []Span{{Start: 2, End: 5.5}}},
+		{"no music", []bool{false, false}, 4, nil},
+	}
+	for _, tt := range tests {
+		if got := musicRuns(tt.music, tt.dur); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: musicRuns = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// --- muteSpans ---
+
+func TestMuteSpans(t *testing.T) {
+	samples := []float32{1, 1, 1, 1}
+	if got := muteSpans(samples, nil); &got[0] != &samples[0] {
+		t.Error("muteSpans without spans copied the samples")
+	}
+	got := muteSpans(samples, []Span{{Start: 1.0 / 16000, End: 3.0 / 16000}, {Start: 1, End: 2}})
+	if !reflect.DeepEqual(got, []float32{1, 0, 0, 1}) || samples[1] != 1 {
+		t.Errorf("muteSpans = %v, input %v", got, samples)
+	}
+}
+
+// --- Engine.TranscribeSamples ---
+
+func TestTranscribeSamples_SkipMusicUnavailable(t *testing.T) {
+	_, err := new(Engine).TranscribeSamples(context.Background(), make([]float32, 16000), 16000, Options{SkipMusic: true})
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("err = %v, want ErrUnavailable", err)
+	}
+}
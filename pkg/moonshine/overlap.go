@@ -0,0 +1,63 @@
+package moonshine
+
+import (
+	"strings"
+	"unicode"
+)
+
+// maxOverlapWords bounds how many words at a seam are compared; a second
+// or two of overlap holds a handful at most.
+const maxOverlapWords = 8
+
+// chunkOverlap returns the samples at sampleRate that neighbouring pieces
+// of audio split by splitSamples share (Config.ChunkOverlapS), at most half
+// a piece of size samples.
+func (e *Engine) chunkOverlap(sampleRate, size int) int {
+	return min(int(e.cfg.ChunkOverlapS*float64(sampleRate)), size/2)
+}
+
+// mergeOverlaps removes the words recognized twice where neighbouring texts
+// of overlapping pieces meet.
+func mergeOverlaps(texts []chunkText) {
+	for i := 1; i < len(texts); i++ {
+		texts[i-1].Text, texts[i].Text = trimOverlap(texts[i-1].Text, texts[i].Text)
+		texts[i-1].Raw, texts[i].Raw = trimOverlap(texts[i-1].Raw, texts[i].Raw)
+	}
+}
+
+// trimOverlap removes from next the longest run of words that ends prev
+// and starts next, ignoring case and punctuation. A word cut at the seam
+// may end prev or start next as a fragment; it is dropped too when the
+// words around it match, with at least two words of match to rule out
+// chance.
+func trimOverlap(prev, next string) (string, string) {
+	a, b := strings.Fields(prev), strings.Fields(next)
+	for k := min(len(a), len(b), maxOverlapWords); k > 0; k-- {
+		for _, skip := range [][2]int{{0, 0}, {1, 0}, {0, 1}, {1, 1}} {
+			ja, jb := skip[0], skip[1]
+			if (ja > 0 || jb > 0) && k < 2 || k+ja > len(a) || k+jb > len(b) {
+				continue
+			}
+			if wordsEqual(a[len(a)-ja-k:len(a)-ja], b[jb:jb+k]) {
+				return strings.Join(a[:len(a)-ja], " "), strings.Join(b[jb+k:], " ")
+			}
+		}
+	}
+	return prev, next
+}
+
+// wordsEqual reports whether a and b hold the same words, ignoring case and
+// punctuation.
+func wordsEqual(a, b []string) bool {
+	for i := range a {
+		if normWord(a[i]) != normWord(b[i]) || normWord(a[i]) == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// normWord lowercases w and strips punctuation from it.
+func normWord(w string) string {
+	return strings.ToLower(strings.TrimFunc(w, unicode.IsPunct))
+}
@@ -0,0 +1,54 @@
+package moonshine
+
+import "testing"
+
+// --- trimOverlap ---
+
+func TestTrimOverlap(t *testing.T) {
+	tests := []struct {
+		prev, next         string
+		wantPrev, wantNext string
+	}{
+		{"we went to the store", "the store and bought milk", "we went to the store", "and bought milk"},
+		{"we went to the Store.", "store, and bought milk", "we went to the Store.", "and bought milk"},
+		{"we went to the store and bou", "the store and bought milk", "we went to the store and", "bought milk"},
+		{"we went to the store", "ore the store and bought milk", "we went to the store", "and bought milk"},
+		{"we went to the store", "and bought milk", "we went to the store", "and bought milk"},
+		{"he said no", "and then no", "he said no", "and then no"},
+		{"", "hello", "", "hello"},
+		{"hello", "", "hello", ""},
+	}
+	for _, tt := range tests {
+		gotPrev, gotNext := trimOverlap(tt.prev, tt.next)
+		if gotPrev != tt.wantPrev || gotNext != tt.wantNext {
+			t.Errorf("trimOverlap(%q, %q) = %q, %q, want %q, %q", tt.prev, tt.next, gotPrev, gotNext, tt.wantPrev, tt.wantNext)
+		}
+	}
+}
+
+// --- mergeOverlaps ---
+
+func TestMergeOverlaps(t *testing.T) {
+	texts := []chunkText{
+		{Text: "one two three", Raw: "one two three"},
+		{Text: "two three four five", Raw: "two three four five"},
+		{Text: "five six", Raw: "five six"},
+	}
+	mergeOverlaps(texts)
+	if got := joinChunkTexts(texts); got.Text != "one two three four five six" || got.Raw != got.Text {
+		t.Errorf("merged = %+v, want one to six", got)
+	}
+}
+
+// --- Engine.chunkOverlap ---
+
+func TestChunkOverlap(t *testing.T) {
+	e := &Engine{cfg: Config{ChunkOverlapS: 1}}
+	if got := e.chunkOverlap(16000, maxSegmentSamples); got != 16000 {
+		t.Errorf("chunkOverlap = %d, want 16000", got)
+	}
+	e.cfg.ChunkOverlapS = 60
+	if got := e.chunkOverlap(16000, maxSegmentSamples); got != maxSegmentSamples/2 {
+		t.Errorf("chunkOverlap = %d, want half a piece", got)
+	}
+}
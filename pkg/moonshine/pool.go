@@ -0,0 +1,174 @@
+package moonshine
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	sherpa "github.com/k2-fsa/sherpa-onnx-go/sherpa_onnx"
+)
+
+// Priorities of Options.Priority, which order calls waiting for a
+// recognizer.
+const (
+	PriorityNormal = "normal"
+	PriorityLow    = "low" // waits while any normal call waits, as for batch jobs
+)
+
+// ValidPriority reports whether p is a supported priority; "" means
+// PriorityNormal.
+func ValidPriority(p string) bool { return p == "" || p == PriorityNormal || p == PriorityLow }
+
+// recognizerPool is a set of identical recognizers for one model. Each
+// recognizer decodes one stream at a time; the pool lets chunks of the same
+// audio, or different requests, decode in parallel.
+type recognizerPool struct {
+	// cfg is the config at load time; per-call decoding overrides are
+	// applied on top of it and then restored.
+	cfg   sherpa.OfflineRecognizerConfig
+	info  ModelInfo
+	all   []*sherpa.OfflineRecognizer
+	users sync.WaitGroup // acquired and not yet released
+
+	mu      sync.Mutex
+	free    []*sherpa.OfflineRecognizer
+	waiting [2][]chan *sherpa.OfflineRecognizer // normal, then low priority; first come first served
+}
+
+// newRecognizerPool returns a pool of size recognizers: first plus copies
+// loaded from c.
+func newRecognizerPool(first *sherpa.OfflineRecognizer, c sherpa.OfflineRecognizerConfig, size int) (*recognizerPool, error) {
+	p := &recognizerPool{cfg: c}
+	p.add(first)
+	for len(p.all) < size {
+		r := sherpa.NewOfflineRecognizer(&c)
+		if r == nil {
+			p.delete()
+			return nil, fmt.Errorf("failed to load recognizer %d of %d", len(p.all)+1, size)
+		}
+		p.add(r)
+	}
+	return p, nil
+}
+
+// add puts a recognizer in the pool.
+func (p *recognizerPool) add(r *sherpa.OfflineRecognizer) {
+	p.all = append(p.all, r)
+	p.put(r)
+}
+
+// take returns a free recognizer, waiting for one if all are busy. A low
+// priority call also waits while a normal one does.
+func (p *recognizerPool) take(low bool) *sherpa.OfflineRecognizer {
+	p.mu.Lock()
+	if n := len(p.free); n > 0 && (!low || len(p.waiting[0]) == 0) {
+		r := p.free[n-1]
+		p.free = p.free[:n-1]
+		p.mu.Unlock()
+		return r
+	}
+	ch := make(chan *sherpa.OfflineRecognizer, 1)
+	lane := 0
+	if low {
+		lane = 1
+	}
+	p.waiting[lane] = append(p.waiting[lane], ch)
+	p.mu.Unlock()
+	return <-ch
+}
+
+// put hands r to the longest waiting call, normal priority first, or marks
+// it free.
+func (p *recognizerPool) put(r *sherpa.OfflineRecognizer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for lane, waiting := range p.waiting {
+		if len(waiting) > 0 {
+			waiting[0] <- r
+			p.waiting[lane] = waiting[1:]
+			return
+		}
+	}
+	p.free = append(p.free, r)
+}
+
+// warmup runs one second of silence through each recognizer, waiting for
+// busy ones to be released.
+func (p *recognizerPool) warmup() {
+	taken := make([]*sherpa.OfflineRecognizer, 0, len(p.all))
+	for range p.all {
+		r := p.take(false)
+		warmupRecognizer(r)
+		taken = append(taken, r)
+	}
+	for _, r := range taken {
+		p.put(r)
+	}
+}
+
+// release returns a recognizer taken by Engine.acquire.
+func (p *recognizerPool) release(r *sherpa.OfflineRecognizer) {
+	p.put(r)
+	p.users.Done()
+}
+
+// drain waits until every acquired recognizer is released and returns them
+// all. The pool must no longer be reachable through the Engine.
+func (p *recognizerPool) drain() []*sherpa.OfflineRecognizer {
+	p.users.Wait()
+	return p.all
+}
+
+// delete frees the recognizers once they are idle.
+func (p *recognizerPool) delete() {
+	for _, r := range p.drain() {
+		sherpa.DeleteOfflineRecognizer(r)
+	}
+}
+
+// acquire takes a free recognizer from the pool for model, waiting for one
+// if all are busy; low priority calls yield to normal ones. It returns a
+// nil pool if the model is not loaded; pass the recognizer back to
+// pool.release when done.
+func (e *Engine) acquire(model string, low bool) (*recognizerPool, *sherpa.OfflineRecognizer) {
+	e.muPools.Lock()
+	p := e.pools[model]
+	if p == nil {
+		e.muPools.Unlock()
+		return nil, nil
+	}
+	p.users.Add(1)
+	e.muPools.Unlock()
+	return p, p.take(low)
+}
+
+// setPool installs p for model, or unloads the model when p is nil. The
+// replaced pool is freed after its in-flight decodes finish.
+func (e *Engine) setPool(model string, p *recognizerPool) {
+	e.muPools.Lock()
+	old := e.pools[model]
+	if p != nil {
+		if e.pools == nil {
+			e.pools = make(map[string]*recognizerPool)
+		}
+		e.pools[model] = p
+	} else {
+		delete(e.pools, model)
+	}
+	e.muPools.Unlock()
+	if old != nil {
+		old.delete()
+	}
+}
+
+// models returns the names of the loaded pools, sorted.
+func (e *Engine) models() []string {
+	e.muPools.Lock()
+	defer e.muPools.Unlock()
+	models := make([]string, 0, len(e.pools))
+	for model := range e.pools {
+		models = append(models, model)
+	}
+	sort.Strings(models)
+	return models
+}
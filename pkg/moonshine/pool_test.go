@@ -0,0 +1,127 @@
+package moonshine
+
+import (
+	"testing"
+	"time"
+
+	sherpa "github.com/k2-fsa/sherpa-onnx-go/sherpa_onnx"
+)
+
+// testPool returns a pool of n placeholder recognizers that are never decoded.
+func testPool(n int) *recognizerPool {
+	p := &recognizerPool{}
+	for range n {
+		p.add(new(sherpa.OfflineRecognizer))
+	}
+	return p
+}
+
+// --- Engine.acquire ---
+
+func TestAcquire_NotLoaded(t *testing.T) {
+	if p, r := new(Engine).acquire("en", false); p != nil || r != nil {
+		t.Errorf("acquire on empty engine = %v, %v, want nil", p, r)
+	}
+}
+
+func TestAcquire_DistinctRecognizers(t *testing.T) {
+	e := &Engine{pools: map[string]*recognizerPool{"en": testPool(2)}}
+	p1, r1 := e.acquire("en", false)
+	p2, r2 := e.acquire("en", false)
+	if r1 == r2 {
+		t.Error("concurrent acquires must get different recognizers")
+	}
+	p1.release(r1)
+	p2.release(r2)
+}
+
+func TestAcquire_LowPriorityYields(t *testing.T) {
+	e := &Engine{pools: map[string]*recognizerPool{"en": testPool(1)}}
+	p, r := e.acquire("en", false)
+
+	got := make(chan string, 2)
+	go func() {
+		p, r := e.acquire("en", true)
+		got <- "low"
+		p.release(r)
+	}()
+	time.Sleep(20 * time.Millisecond) // the low priority call waits first
+	go func() {
+		p, r := e.acquire("en", false)
+		got <- "normal"
+		p.release(r)
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	p.release(r)
+	if first, second := <-got, <-got; first != "normal" || second != "low" {
+		t.Errorf("served %s then %s, want normal then low", first, second)
+	}
+}
+
+// --- Engine.parallelism ---
+
+func TestParallelism(t *testing.T) {
+	tests := []struct {
+		poolSize, threads, numThreads int
+		want                          int
+	}{
+		{0, 0, 0, 1},
+		{4, 1, 0, 4},
+		{4, 2, 4, 2},
+		{4, 2, 1, 1},
+		{4, 1, 16, 4},
+		{2, 4, 3, 1},
+	}
+	for _, tt := range tests {
+		e := &Engine{cfg: Config{PoolSize: tt.poolSize, NumThreads: tt.threads}}
+		if got := e.parallelism(Options{NumThreads: tt.numThreads}); got != tt.want {
+			t.Errorf("pool %d x %d threads, num_threads %d: parallelism = %d, want %d",
+				tt.poolSize, tt.threads, tt.numThreads, got, tt.want)
+		}
+	}
+}
+
+func TestParallelism_PoolSizes(t *testing.T) {
+	e := &Engine{cfg: Config{PoolSize: 2, NumThreads: 1, PoolSizes: map[string]int{"en": 4, "ru": 1}, ModelThreads: map[string]int{"zh": 2}}}
+	tests := []struct {
+		opts Options
+		want int
+	}{
+		{Options{Lang: "en"}, 4},
+		{Options{Lang: "ru"}, 1},
+		{Options{Lang: "zh"}, 2},
+		{Options{Lang: "de"}, 4}, // falls back to the EN model
+		{Options{Lang: "en", model: "en" + telephonySuffix}, 4},
+		{Options{Lang: "en", NumThreads: 3}, 3},
+		{Options{Lang: "zh", NumThreads: 3}, 1}, // 2 threads per recognizer
+	}
+	for _, tt := range tests {
+		if got := e.parallelism(tt.opts); got != tt.want {
+			t.Errorf("%s (%q): parallelism = %d, want %d", tt.opts.Lang, tt.opts.model, got, tt.want)
+		}
+	}
+}
+
+// --- recognizerPool.drain ---
+
+func TestPoolDrain_WaitsForRelease(t *testing.T) {
+	e := &Engine{pools: map[string]*recognizerPool{"en": testPool(1)}}
+	p, r := e.acquire("en", false)
+
+	e.muPools.Lock()
+	delete(e.pools, "en") // unreachable, as after a reload
+	e.muPools.Unlock()
+
+	done := make(chan []*sherpa.OfflineRecognizer)
+	go func() { done <- p.drain() }()
+	select {
+	case <-done:
+		t.Fatal("drain returned while a recognizer was in use")
+	case <-time.After(20 * time.Millisecond):
+	}
+	p.release(r)
+	if all := <-done; len(all) != 1 {
+		t.Errorf("drain returned %d recognizers, want 1", len(all))
+	}
+}
@@ -0,0 +1,72 @@
+package moonshine
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Precisions of Config.ModelPrecision, choosing between the quantized
+// (name.int8.onnx) and full-precision (name.onnx) files of a model
+// directory.
+const (
+	PrecisionAuto = "auto" // int8 when present, else fp32
+	PrecisionInt8 = "int8"
+	PrecisionFP32 = "fp32"
+)
+
+// ValidPrecision reports whether p is a supported precision; "" means
+// PrecisionAuto.
+func ValidPrecision(p string) bool {
+	return p == "" || p == PrecisionAuto || p == PrecisionInt8 || p == PrecisionFP32
+}
+
+// onnxFile returns the path of the ONNX file name in dir at the precision
+// of Config.ModelPrecision. When only the other precision is there it is
+// used instead, so single-precision directories load either way; when
+// neither is, the preferred path is returned for the caller to report.
+func (e *Engine) onnxFile(dir, name string) string {
+	int8Path := filepath.Join(dir, name+".int8.onnx")
+	fp32Path := filepath.Join(dir, name+".onnx")
+	want, other := int8Path, fp32Path
+	if e.cfg.ModelPrecision == PrecisionFP32 {
+		want, other = fp32Path, int8Path
+	}
+	if _, err := os.Stat(want); err == nil {
+		return want
+	}
+	if _, err := os.Stat(other); err != nil {
+		return want
+	}
+	if e.cfg.ModelPrecision == PrecisionInt8 || e.cfg.ModelPrecision == PrecisionFP32 {
+		e.logf("WARNING: %s not found, using %s", filepath.Base(want), filepath.Base(other))
+	}
+	return other
+}
+
+// precisionOf returns the precision of the ONNX file at path.
+func precisionOf(path string) string {
+	if strings.HasSuffix(path, ".int8.onnx") {
+		return PrecisionInt8
+	}
+	return PrecisionFP32
+}
+
+// Precision returns the precision of the loaded recognizer for lang, int8
+// or fp32, or "" when it is not loaded or, like Moonshine, comes in one
+// precision only.
+func (e *Engine) Precision(lang string) string {
+	e.muPools.Lock()
+	p := e.pools[e.langModel(lang)]
+	e.muPools.Unlock()
+	if p == nil {
+		return ""
+	}
+	mc := p.cfg.ModelConfig
+	for _, path := range []string{mc.Transducer.Encoder, mc.Whisper.Encoder, mc.Paraformer.Model, mc.SenseVoice.Model, mc.NemoCTC.Model} {
+		if path != "" {
+			return precisionOf(path)
+		}
+	}
+	return ""
+}
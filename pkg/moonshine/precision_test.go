@@ -0,0 +1,70 @@
+package moonshine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// --- Engine.onnxFile ---
+
+func TestOnnxFile(t *testing.T) {
+	both, int8Only, fp32Only, none := t.TempDir(), t.TempDir(), t.TempDir(), t.TempDir()
+	for _, path := range []string{
+		filepath.Join(both, "encoder.int8.onnx"), filepath.Join(both, "encoder.onnx"),
+		filepath.Join(int8Only, "encoder.int8.onnx"), filepath.Join(fp32Only, "encoder.onnx"),
+	} {
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		precision string
+		dir       string
+		want      string
+	}{
+		{"", both, "encoder.int8.onnx"},
+		{PrecisionAuto, fp32Only, "encoder.onnx"},
+		{PrecisionInt8, both, "encoder.int8.onnx"},
+		{PrecisionInt8, fp32Only, "encoder.onnx"},
+		{PrecisionFP32, both, "encoder.onnx"},
+		{PrecisionFP32, int8Only, "encoder.int8.onnx"},
+		{PrecisionFP32, none, "encoder.onnx"},
+		{"", none, "encoder.int8.onnx"},
+	}
+	for _, tt := range tests {
+		e := &Engine{cfg: Config{ModelPrecision: tt.precision}.withDefaults()}
+		if got := e.onnxFile(tt.dir, "encoder"); got != filepath.Join(tt.dir, tt.want) {
+			t.Errorf("%q: onnxFile = %s, want %s", tt.precision, filepath.Base(got), tt.want)
+		}
+	}
+}
+
+// --- precisionOf ---
+
+func TestPrecisionOf(t *testing.T) {
+	if got := precisionOf("/ru/encoder.int8.onnx"); got != PrecisionInt8 {
+		t.Errorf("int8 file = %q", got)
+	}
+	if got := precisionOf("/ru/encoder.onnx"); got != PrecisionFP32 {
+		t.Errorf("fp32 file = %q", got)
+	}
+}
+
+// --- Engine.Precision ---
+
+func TestPrecision(t *testing.T) {
+	p := testPool(1)
+	p.cfg.ModelConfig.Transducer.Encoder = "/ru/encoder.onnx"
+	e := &Engine{pools: map[string]*recognizerPool{"ru": p, "en": testPool(1)}}
+	if got := e.Precision("ru"); got != PrecisionFP32 {
+		t.Errorf("Precision(ru) = %q, want fp32", got)
+	}
+	if got := e.Precision("en"); got != "" {
+		t.Errorf("Precision(en) = %q, want empty for Moonshine", got)
+	}
+	if got := new(Engine).Precision("ru"); got != "" {
+		t.Errorf("Precision on empty engine = %q", got)
+	}
+}
@@ -0,0 +1,324 @@
+package moonshine
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jfreymuth/oggvorbis"
+	"github.com/mewkiz/flac"
+)
+
+// AudioInfo describes an audio file without transcribing it.
+type AudioInfo struct {
+	DurationS  float64 `json:"duration_s"`
+	SampleRate int     `json:"sample_rate"` // of the file, before resampling
+	Channels   int     `json:"channels"`
+	Codec      string  `json:"codec"` // ffmpeg codec name, e.g. pcm_s16le, mp3, opus
+
+	// SpeechS and SpeechRatio are the speech VAD finds in the audio, in
+	// seconds and as a share of DurationS; unset without the VAD model.
+	SpeechS     float64  `json:"speech_s,omitempty"`
+	SpeechRatio *float64 `json:"speech_ratio,omitempty"`
+}
+
+// Probe decodes the audio at path like TranscribeFile and reports its
+// format, duration, and, with the VAD model loaded and Options.VAD not
+// false, how much of it is speech. Nothing is transcribed.
+func (e *Engine) Probe(ctx context.Context, path string, opts Options) (AudioInfo, error) {
+	info, err := e.probeHeader(ctx, path)
+	if err != nil {
+		return AudioInfo{}, err
+	}
+	samples, rate, err := e.decodeChecked(ctx, path, opts)
+	if err != nil {
+		return AudioInfo{}, err
+	}
+	info.DurationS = float64(len(samples)) / float64(rate)
+	if e.vadDetector == nil || opts.VAD != nil && !*opts.VAD {
+		return info, nil
+	}
+	if rate != 16000 {
+		samples = resample(samples, rate, 16000)
+	}
+	_, spans := e.applyVADChunked(samples)
+	for _, chunk := range spans {
+		for _, s := range chunk {
+			info.SpeechS += s.End - s.Start
+		}
+	}
+	ratio := min(info.SpeechS/info.DurationS, 1)
+	info.SpeechRatio = &ratio
+	return info, nil
+}
+
+// decodeChecked decodes the audio at path like TranscribeFile and rejects
+// empty audio, unsupported sample rates, and audio over the duration limit
+// of opts.
+func (e *Engine) decodeChecked(ctx context.Context, path string, opts Options) ([]float32, int, error) {
+	samples, rate, err := e.decodeFile(ctx, path, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(samples) == 0 {
+		return nil, 0, errorf(ErrInvalidAudio, "no audio samples")
+	}
+	if rate < MinSampleRate || rate > MaxSampleRate {
+		return nil, 0, errorf(ErrInvalidAudio, "unsupported sample rate %d (need %d-%d)", rate, MinSampleRate, MaxSampleRate)
+	}
+	durationS := float64(len(samples)) / float64(rate)
+	if limit := e.maxDuration(opts); limit > 0 && durationS > limit {
+		return nil, 0, errorf(ErrInvalidAudio, "audio too long: %.1fs > max %.0fs", durationS, limit)
+	}
+	return samples, rate, nil
+}
+
+// probeHeader reads the codec, sample rate, and channel count of the audio
+// at path from its header, or with ffprobe for formats without a native
+// decoder.
+func (e *Engine) probeHeader(ctx context.Context, path string) (AudioInfo, error) {
+	info, err := probeNative(path)
+	if err == nil {
+		return info, nil
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return AudioInfo{}, errorf(ErrInvalidAudio, "%v", err)
+	}
+	return e.ffprobe(ctx, path)
+}
+
+// probeNative reads the header of a WAV, MP3, FLAC, or Ogg Vorbis file. It
+// returns an error wrapping errNotNative for other formats.
+func probeNative(path string) (info AudioInfo, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			info, err = AudioInfo{}, fmt.Errorf("decoder panic: %v", r)
+		}
+	}()
+
+	f, err := os.Open(path)
+	if err != nil {
+		return AudioInfo{}, err
+	}
+	defer f.Close() //nolint:errcheck
+
+	br := bufio.NewReader(f)
+	head, _ := br.Peek(64)
+	switch sniffAudio(head) {
+	case "wav":
+		format, err := readWavFormat(br)
+		if err != nil {
+			return AudioInfo{}, err
+		}
+		codec := wavCodec(format)
+		if codec == "" {
+			return AudioInfo{}, fmt.Errorf("WAV format tag 0x%04x: %w", format.AudioFormat, errNotNative)
+		}
+		return AudioInfo{Codec: codec, SampleRate: format.SampleRate, Channels: format.Channels}, nil
+	case "mp3":
+		return probeMP3(f)
+	case "flac":
+		stream, err := flac.New(br)
+		if err != nil {
+			return AudioInfo{}, fmt.Errorf("flac: %w", err)
+		}
+		return AudioInfo{Codec: "flac", SampleRate: int(stream.Info.SampleRate), Channels: int(stream.Info.NChannels)}, nil
+	case "vorbis":
+		vr, err := oggvorbis.NewReader(br)
+		if err != nil {
+			return AudioInfo{}, fmt.Errorf("vorbis: %w", err)
+		}
+		return AudioInfo{Codec: "vorbis", SampleRate: vr.SampleRate(), Channels: vr.Channels()}, nil
+	}
+	return AudioInfo{}, errNotNative
+}
+
+// readWavFormat returns the fmt chunk of a WAV stream without reading its
+// samples.
+func readWavFormat(r io.Reader) (wavFormat, error) {
+	var riff [12]byte
+	if _, err := io.ReadFull(r, riff[:]); err != nil {
+		return wavFormat{}, fmt.Errorf("read header: %w", err)
+	}
+	for {
+		var hdr [8]byte
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			return wavFormat{}, errors.New("no fmt chunk")
+		}
+		id, size := string(hdr[0:4]), binary.LittleEndian.Uint32(hdr[4:8])
+		if id == "fmt " {
+			if size > 1<<16 {
+				return wavFormat{}, fmt.Errorf("fmt chunk too long: %d bytes", size)
+			}
+			body := make([]byte, size)
+			if _, err := io.ReadFull(r, body); err != nil {
+				return wavFormat{}, fmt.Errorf("read fmt chunk: %w", err)
+			}
+			return parseWavFormat(body)
+		}
+		if _, err := io.CopyN(io.Discard, r, int64(size)+int64(size&1)); err != nil {
+			return wavFormat{}, errors.New("no fmt chunk")
+		}
+	}
+}
+
+// wavCodec names the encoding of a WAV stream as ffmpeg does, or returns ""
+// for encodings without a native decoder.
+func wavCodec(f wavFormat) string {
+	switch {
+	case f.AudioFormat == wavFormatPCM && f.BitsPerSample == 8:
+		return "pcm_u8"
+	case f.AudioFormat == wavFormatPCM && (f.BitsPerSample == 16 || f.BitsPerSample == 24 || f.BitsPerSample == 32):
+		return fmt.Sprintf("pcm_s%dle", f.BitsPerSample)
+	case f.AudioFormat == wavFormatIEEEFloat && (f.BitsPerSample == 32 || f.BitsPerSample == 64):
+		return fmt.Sprintf("pcm_f%dle", f.BitsPerSample)
+	case f.AudioFormat == wavFormatMuLaw:
+		return "pcm_mulaw"
+	case f.AudioFormat == wavFormatALaw:
+		return "pcm_alaw"
+	}
+	return ""
+}
+
+// probeMP3 reads the sample rate and channel count from the first MPEG
+// audio frame header after any ID3v2 tag.
+func probeMP3(f *os.File) (AudioInfo, error) {
+	var id3 [10]byte
+	if _, err := f.ReadAt(id3[:], 0); err != nil {
+		return AudioInfo{}, fmt.Errorf("mp3: %w", err)
+	}
+	var offset int64
+	if string(id3[0:3]) == "ID3" {
+		// The tag size is a 28-bit "syncsafe" integer, 7 bits per byte.
+		offset = 10 + int64(id3[6]&0x7f)<<21 | int64(id3[7]&0x7f)<<14 | int64(id3[8]&0x7f)<<7 | int64(id3[9]&0x7f)
+		if id3[5]&0x10 != 0 {
+			offset += 10 // footer
+		}
+	}
+	buf := make([]byte, 8192)
+	n, _ := f.ReadAt(buf, offset)
+	for i := 0; i+4 <= n; i++ {
+		if info, ok := mp3FrameHeader(buf[i : i+4]); ok {
+			return info, nil
+		}
+	}
+	return AudioInfo{}, errors.New("mp3: no frame header")
+}
+
+// mp3FrameHeader decodes a 4-byte MPEG audio frame header.
+func mp3FrameHeader(h []byte) (AudioInfo, bool) {
+	if h[0] != 0xFF || h[1]&0xE0 != 0xE0 {
+		return AudioInfo{}, false
+	}
+	version, layer, rateIndex := h[1]>>3&3, h[1]>>1&3, h[2]>>2&3
+	if version == 1 || layer == 0 || rateIndex == 3 {
+		return AudioInfo{}, false // reserved values
+	}
+	rate := [3]int{44100, 48000, 32000}[rateIndex]
+	switch version {
+	case 2: // MPEG-2
+		rate /= 2
+	case 0: // MPEG-2.5
+		rate /= 4
+	}
+	channels := 2
+	if h[3]>>6 == 3 {
+		channels = 1
+	}
+	return AudioInfo{Codec: [4]string{"", "mp3", "mp2", "mp1"}[layer], SampleRate: rate, Channels: channels}, true
+}
+
+// ffprobe reads the first audio stream of the file at path with ffprobe.
+func (e *Engine) ffprobe(ctx context.Context, path string) (AudioInfo, error) {
+	out, err := e.runTool(ctx, e.ffprobePath(), "-v", "error", "-select_streams", "a:0",
+		"-show_entries", "stream=codec_name,sample_rate,channels", "-of", "json", path)
+	if ctx.Err() != nil {
+		return AudioInfo{}, ctx.Err()
+	}
+	if err != nil {
+		return AudioInfo{}, conversionError(err)
+	}
+	var probe struct {
+		Streams []struct {
+			CodecName  string `json:"codec_name"`
+			SampleRate string `json:"sample_rate"`
+			Channels   int    `json:"channels"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(out, &probe); err != nil {
+		return AudioInfo{}, errorf(ErrConversion, "ffprobe: %v", err)
+	}
+	if len(probe.Streams) == 0 {
+		return AudioInfo{}, errorf(ErrInvalidAudio, "no audio stream")
+	}
+	s := probe.Streams[0]
+	rate, _ := strconv.Atoi(s.SampleRate)
+	return AudioInfo{Codec: s.CodecName, SampleRate: rate, Channels: s.Channels}, nil
+}
+
+// checkInput probes the file at path with ffprobe before audio track track
+// is converted, so files ffprobe cannot read, files without that track,
+// and audio longer than maxDurationS (when > 0) fail early with
+// ErrInvalidAudio and a message saying why, instead of mid-conversion.
+// Without ffprobe installed the check is skipped.
+func (e *Engine) checkInput(ctx context.Context, path string, track int, maxDurationS float64) error {
+	out, err := e.runTool(ctx, e.ffprobePath(), "-v", "error",
+		"-show_entries", "format=duration:stream=codec_type,codec_name,sample_rate,channels", "-of", "json", path)
+	switch {
+	case ctx.Err() != nil:
+		return ctx.Err()
+	case errors.Is(err, ErrUnavailable):
+		return nil
+	case errors.Is(err, ErrConversion):
+		return err
+	case err != nil:
+		return errorf(ErrInvalidAudio, "unreadable or corrupt audio: %v", err)
+	}
+	var probe struct {
+		Format struct {
+			Duration string `json:"duration"`
+		} `json:"format"`
+		Streams []struct {
+			CodecType  string `json:"codec_type"`
+			CodecName  string `json:"codec_name"`
+			SampleRate string `json:"sample_rate"`
+			Channels   int    `json:"channels"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(out, &probe); err != nil {
+		return errorf(ErrConversion, "ffprobe: %v", err)
+	}
+	var kinds []string
+	tracks := 0
+	for _, s := range probe.Streams {
+		if s.CodecType != "audio" {
+			kinds = append(kinds, s.CodecType+" "+s.CodecName)
+			continue
+		}
+		if tracks++; tracks != track+1 {
+			continue
+		}
+		if rate, _ := strconv.Atoi(s.SampleRate); s.CodecName == "" || rate <= 0 || s.Channels <= 0 {
+			return errorf(ErrInvalidAudio, "unsupported audio stream (codec %q, %s Hz, %d channel(s))", s.CodecName, s.SampleRate, s.Channels)
+		}
+	}
+	switch {
+	case tracks == 0 && len(kinds) == 0:
+		return errorf(ErrInvalidAudio, "no audio stream")
+	case tracks == 0:
+		return errorf(ErrInvalidAudio, "no audio stream (found %s)", strings.Join(kinds, ", "))
+	case track >= tracks:
+		return errorf(ErrInvalidAudio, "audio track %d not found: the file has %d audio track(s)", track, tracks)
+	}
+	if d, err := strconv.ParseFloat(probe.Format.Duration, 64); err == nil && maxDurationS > 0 && d > maxDurationS {
+		return errorf(ErrInvalidAudio, "audio too long: %.1fs > max %.0fs", d, maxDurationS)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package moonshine
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// --- probeNative ---
+
+func TestProbeNative(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want AudioInfo
+	}{
+		{"clip.wav", wavBytes(fmtChunk(wavFormatPCM, 2, 8000, 16), make([]byte, 8), 8), AudioInfo{Codec: "pcm_s16le", SampleRate: 8000, Channels: 2}},
+		{"call.wav", wavBytes(fmtChunk(wavFormatMuLaw, 1, 8000, 8), make([]byte, 8), 8), AudioInfo{Codec: "pcm_mulaw", SampleRate: 8000, Channels: 1}},
+		{"clip.flac", flacBytes(t, 44100, []int32{0, 1}, []int32{0, 1}), AudioInfo{Codec: "flac", SampleRate: 44100, Channels: 2}},
+		{"mono.mp3", []byte("ID3\x03\x00\x00\x00\x00\x00\x02xx\xff\xfb\x90\xc4\x00\x00"), AudioInfo{Codec: "mp3", SampleRate: 44100, Channels: 1}},
+	}
+	for _, tt := range tests {
+		got, err := probeNative(writeTempAudio(t, tt.name, tt.data))
+		if err != nil || got != tt.want {
+			t.Errorf("%s: probeNative = %+v, %v; want %+v", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestProbeNative_NotNative(t *testing.T) {
+	tests := map[string][]byte{
+		"m4a":   []byte("\x00\x00\x00\x20ftypM4A \x00\x00\x00\x00"),
+		"adpcm": wavBytes(fmtChunk(0x0002, 1, 8000, 4), []byte{0, 0}, 2),
+	}
+	for name, data := range tests {
+		if _, err := probeNative(writeTempAudio(t, name, data)); !errors.Is(err, errNotNative) {
+			t.Errorf("%s: err = %v, want errNotNative", name, err)
+		}
+	}
+}
+
+// --- mp3FrameHeader ---
+
+func TestMP3FrameHeader(t *testing.T) {
+	tests := []struct {
+		header []byte
+		want   AudioInfo
+		ok     bool
+	}{
+		{[]byte{0xFF, 0xFB, 0x90, 0x44}, AudioInfo{Codec: "mp3", SampleRate: 44100, Channels: 2}, true},
+		{[]byte{0xFF, 0xF3, 0x84, 0xC4}, AudioInfo{Codec: "mp3", SampleRate: 24000, Channels: 1}, true},
+		{[]byte{0xFF, 0xE3, 0x88, 0x00}, AudioInfo{Codec: "mp3", SampleRate: 8000, Channels: 2}, true},
+		{[]byte{0xFF, 0xFD, 0x90, 0x00}, AudioInfo{Codec: "mp2", SampleRate: 44100, Channels: 2}, true},
+		{[]byte{0xFF, 0xEB, 0x90, 0x00}, AudioInfo{}, false}, // reserved version
+		{[]byte{0xFF, 0xFB, 0x9C, 0x00}, AudioInfo{}, false}, // reserved rate
+		{[]byte{0x49, 0x44, 0x33, 0x03}, AudioInfo{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := mp3FrameHeader(tt.header)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("mp3FrameHeader(% x) = %+v, %v; want %+v, %v", tt.header, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// --- Probe ---
+
+func TestProbe(t *testing.T) {
+	e := &Engine{cfg: Config{NativeDecode: true, MaxAudioDurationS: 300}}
+	path := writeTempAudio(t, "clip.wav", wavBytes(fmtChunk(wavFormatPCM, 1, 8000, 16), make([]byte, 16000), 16000))
+	got, err := e.Probe(context.Background(), path, Options{})
+	if err != nil {
+		t.Fatalf("Probe: %v", err)
+	}
+	want := AudioInfo{DurationS: 1, SampleRate: 8000, Channels: 1, Codec: "pcm_s16le"}
+	if got != want {
+		t.Errorf("Probe = %+v, want %+v (no speech ratio without VAD)", got, want)
+	}
+
+	if _, err := e.Probe(context.Background(), path, Options{MaxAudioDurationS: 0.5}); !errors.Is(err, ErrInvalidAudio) {
+		t.Errorf("over the duration limit: err = %v, want ErrInvalidAudio", err)
+	}
+	if _, err := e.Probe(context.Background(), path+".missing", Options{}); !errors.Is(err, ErrInvalidAudio) {
+		t.Errorf("missing file: err = %v, want ErrInvalidAudio", err)
+	}
+}
+
+// --- Engine.checkInput ---
+
+func TestCheckInput(t *testing.T) {
+	tool := func(script string) string {
+		path := filepath.Join(t.TempDir(), "ffprobe")
+		if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	tests := []struct {
+		name   string
+		script string
+		track  int
+		want   error  // nil = accepted
+		msg    string // part of the error message
+	}{
+		{"audio", `echo '{"format":{"duration":"12.5"},"streams":[{"codec_type":"audio","codec_name":"opus","sample_rate":"48000","channels":2}]}'`, 0, nil, ""},
+		{"video with audio", `echo '{"format":{"duration":"60"},"streams":[{"codec_type":"video","codec_name":"h264"},{"codec_type":"audio","codec_name":"aac","sample_rate":"44100","channels":2}]}'`, 0, nil, ""},
+		{"too long", `echo '{"format":{"duration":"400"},"streams":[{"codec_type":"audio","codec_name":"mp3","sample_rate":"44100","channels":2}]}'`, 0, ErrInvalidAudio, "audio too long"},
+		{"no audio", `echo '{"format":{"duration":"5"},"streams":[{"codec_type":"video","codec_name":"h264"}]}'`, 0, ErrInvalidAudio, "no audio stream (found video h264)"},
+		{"empty", `echo '{"format":{},"streams":[]}'`, 0, ErrInvalidAudio, "no audio stream"},
+		{"no sample rate", `echo '{"format":{},"streams":[{"codec_type":"audio","codec_name":"none","sample_rate":"0","channels":0}]}'`, 0, ErrInvalidAudio, "unsupported audio stream"},
+		{"corrupt", `echo 'Invalid data found when processing input' >&2; exit 1`, 0, ErrInvalidAudio, "Invalid data found"},
+		{"garbage output", `echo 'not json'`, 0, ErrConversion, "ffprobe"},
+		{"second track", `echo '{"format":{"duration":"60"},"streams":[{"codec_type":"video","codec_name":"h264"},{"codec_type":"audio","codec_name":"aac","sample_rate":"48000","channels":2},{"codec_type":"audio","codec_name":"aac","sample_rate":"48000","channels":1}]}'`, 1, nil, ""},
+		{"missing track", `echo '{"format":{"duration":"60"},"streams":[{"codec_type":"audio","codec_name":"aac","sample_rate":"48000","channels":2}]}'`, 1, ErrInvalidAudio, "audio track 1 not found: the file has 1 audio track(s)"},
+	}
+	for _, tt := range tests {
+		e := &Engine{cfg: Config{FFprobePath: tool(tt.script)}}
+		err := e.checkInput(context.Background(), "/tmp/in.m4a", tt.track, 300)
+		if tt.want == nil {
+			if err != nil {
+				t.Errorf("%s: err = %v, want accepted", tt.name, err)
+			}
+			continue
+		}
+		if !errors.Is(err, tt.want) || !strings.Contains(err.Error(), tt.msg) {
+			t.Errorf("%s: err = %v, want %v containing %q", tt.name, err, tt.want, tt.msg)
+		}
+	}
+
+	e := &Engine{cfg: Config{FFprobePath: "/nonexistent/ffprobe"}}
+	if err := e.checkInput(context.Background(), "/tmp/in.m4a", 0, 300); err != nil {
+		t.Errorf("missing ffprobe: err = %v, want the check skipped", err)
+	}
+}
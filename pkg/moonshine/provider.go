@@ -0,0 +1,48 @@
+package moonshine
+
+import "slices"
+
+// ONNX Runtime execution providers of Config.Provider.
+const (
+	ProviderCPU      = "cpu"
+	ProviderCoreML   = "coreml"   // Apple Neural Engine and GPU, macOS on Apple Silicon
+	ProviderDirectML = "directml" // any DirectX 12 GPU, Windows
+)
+
+// ortNames are the ONNX Runtime names of the execution providers.
+var ortNames = map[string]string{
+	ProviderCPU:      "CPUExecutionProvider",
+	ProviderCoreML:   "CoreMLExecutionProvider",
+	ProviderDirectML: "DmlExecutionProvider",
+}
+
+// Providers returns the execution providers supported by this build.
+func Providers() []string { return slices.Clone(platformProviders) }
+
+// ValidProvider reports whether p is supported by this build; "" means the
+// platform default.
+func ValidProvider(p string) bool { return p == "" || slices.Contains(platformProviders, p) }
+
+// Provider returns the execution provider models run on: Config.Provider,
+// or the platform default.
+func (e *Engine) Provider() string {
+	if e.cfg.Provider == "" {
+		return defaultProvider
+	}
+	return e.cfg.Provider
+}
+
+// EffectiveProvider returns the execution provider models actually run on.
+// sherpa-onnx silently falls back to the CPU when it or the ONNX Runtime
+// library was built without Provider; EffectiveProvider reports cpu then.
+// If ONNX Runtime cannot be queried it trusts the library.
+func (e *Engine) EffectiveProvider() string {
+	p := e.Provider()
+	if sherpaLacks(p) {
+		return ProviderCPU
+	}
+	if available := ortProviders(); available != nil && !slices.Contains(available, ortNames[p]) {
+		return ProviderCPU
+	}
+	return p
+}
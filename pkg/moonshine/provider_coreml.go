@@ -0,0 +1,14 @@
+//go:build darwin && arm64
+
+package moonshine
+
+// defaultProvider runs models through CoreML on Apple Silicon, which puts
+// them on the Neural Engine where it can. Operators CoreML lacks fall back
+// to the CPU inside ONNX Runtime.
+const defaultProvider = ProviderCoreML
+
+var platformProviders = []string{ProviderCPU, ProviderCoreML}
+
+// sherpaLacks reports whether the loaded sherpa-onnx library was built
+// without p. Builds for this platform carry every provider it offers.
+func sherpaLacks(string) bool { return false }
@@ -0,0 +1,72 @@
+package moonshine
+
+/*
+#cgo linux LDFLAGS: -ldl
+#include <stdint.h>
+#include <stddef.h>
+#ifdef _WIN32
+#include <windows.h>
+#else
+#include <dlfcn.h>
+#endif
+
+typedef struct {
+	const void* (*GetApi)(uint32_t version);
+	const char* (*GetVersionString)(void);
+} ortApiBase;
+
+typedef void* (*ortGetAvailableProviders)(char*** providers, int* n);
+typedef void* (*ortReleaseAvailableProviders)(char** providers, int n);
+
+// Slots of GetAvailableProviders and ReleaseAvailableProviders in the
+// OrtApi function table, which ONNX Runtime only ever appends to.
+enum { ortGetAvailableProvidersSlot = 125, ortReleaseAvailableProvidersSlot = 126 };
+
+// ort_api returns the OrtApi function table of the ONNX Runtime library
+// sherpa-onnx loaded, or NULL if it is not found.
+static void* const* ort_api(void) {
+	const ortApiBase* (*get)(void) = NULL;
+#ifdef _WIN32
+	HMODULE ort = GetModuleHandleA("onnxruntime.dll");
+	if (ort) get = (const ortApiBase* (*)(void))GetProcAddress(ort, "OrtGetApiBase");
+#else
+	get = (const ortApiBase* (*)(void))dlsym(RTLD_DEFAULT, "OrtGetApiBase");
+#endif
+	if (!get) return NULL;
+	return (void* const*)get()->GetApi(1);
+}
+
+static char** ort_available_providers(int* n) {
+	void* const* api = ort_api();
+	char** providers = NULL;
+	if (!api || ((ortGetAvailableProviders)api[ortGetAvailableProvidersSlot])(&providers, n) != NULL) return NULL;
+	return providers;
+}
+
+static void ort_release_providers(char** providers, int n) {
+	((ortReleaseAvailableProviders)ort_api()[ortReleaseAvailableProvidersSlot])(providers, n);
+}
+*/
+import "C"
+
+import (
+	"sync"
+	"unsafe"
+)
+
+// ortProviders returns the execution providers the loaded ONNX Runtime
+// library was built with, by their ONNX Runtime names, or nil if it cannot
+// be queried.
+var ortProviders = sync.OnceValue(func() []string {
+	var n C.int
+	p := C.ort_available_providers(&n)
+	if p == nil {
+		return nil
+	}
+	defer C.ort_release_providers(p, n)
+	var names []string
+	for _, s := range unsafe.Slice(p, int(n)) {
+		names = append(names, C.GoString(s))
+	}
+	return names
+})
@@ -0,0 +1,12 @@
+//go:build !(darwin && arm64) && !windows
+
+package moonshine
+
+// defaultProvider runs models on the CPU.
+const defaultProvider = ProviderCPU
+
+var platformProviders = []string{ProviderCPU}
+
+// sherpaLacks reports whether the loaded sherpa-onnx library was built
+// without p. Builds for this platform carry every provider it offers.
+func sherpaLacks(string) bool { return false }
@@ -0,0 +1,54 @@
+package moonshine
+
+import (
+	"slices"
+	"testing"
+)
+
+// --- ValidProvider ---
+
+func TestValidProvider(t *testing.T) {
+	for _, p := range append([]string{""}, Providers()...) {
+		if !ValidProvider(p) {
+			t.Errorf("ValidProvider(%q) = false", p)
+		}
+	}
+	if ValidProvider("tpu") {
+		t.Error(`ValidProvider("tpu") = true`)
+	}
+	if !slices.Contains(Providers(), ProviderCPU) {
+		t.Errorf("Providers() = %v, want cpu among them", Providers())
+	}
+}
+
+// --- Engine.Provider ---
+
+func TestEngineProvider(t *testing.T) {
+	if got := new(Engine).Provider(); got != defaultProvider {
+		t.Errorf("default Provider() = %q, want %q", got, defaultProvider)
+	}
+	e := &Engine{cfg: Config{Provider: ProviderCPU}}
+	if got := e.Provider(); got != ProviderCPU {
+		t.Errorf("Provider() = %q, want cpu", got)
+	}
+}
+
+// --- Engine.EffectiveProvider ---
+
+func TestEffectiveProvider(t *testing.T) {
+	if !slices.Contains(ortProviders(), ortNames[ProviderCPU]) {
+		t.Fatalf("ortProviders() = %v, want CPUExecutionProvider among them", ortProviders())
+	}
+	if got := (&Engine{cfg: Config{Provider: ProviderCPU}}).EffectiveProvider(); got != ProviderCPU {
+		t.Errorf("EffectiveProvider() with cpu = %q", got)
+	}
+	// Providers the bundled ONNX Runtime lacks fall back to the CPU.
+	for _, p := range []string{ProviderCoreML, ProviderDirectML} {
+		if slices.Contains(ortProviders(), ortNames[p]) {
+			continue
+		}
+		if got := (&Engine{cfg: Config{Provider: p}}).EffectiveProvider(); got != ProviderCPU {
+			t.Errorf("EffectiveProvider() with %s missing from ONNX Runtime = %q, want cpu", p, got)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+//go:build windows
+
+package moonshine
+
+/*
+#include <windows.h>
+
+// sherpa_library_path writes the path of the loaded sherpa-onnx C API DLL
+// to buf, or returns 0 if it is not loaded.
+static DWORD sherpa_library_path(char* buf, DWORD size) {
+	HMODULE lib = GetModuleHandleA("sherpa-onnx-c-api.dll");
+	return lib ? GetModuleFileNameA(lib, buf, size) : 0;
+}
+*/
+import "C"
+
+import (
+	"bytes"
+	"os"
+	"sync"
+	"unsafe"
+)
+
+// defaultProvider runs models on the CPU; DirectML is opt-in, as it needs
+// an ONNX Runtime built with it.
+const defaultProvider = ProviderCPU
+
+var platformProviders = []string{ProviderCPU, ProviderDirectML}
+
+// sherpaLacks reports whether the loaded sherpa-onnx library was built
+// without p, so it falls back to the CPU whatever ONNX Runtime offers.
+func sherpaLacks(p string) bool { return p == ProviderDirectML && sherpaWithoutDirectML() }
+
+// sherpaWithoutDirectML reports whether sherpa-onnx was built without
+// SHERPA_ONNX_ENABLE_DIRECTML, as the DLL bundled with sherpa-onnx-go is.
+// Only such builds carry the message they log when falling back.
+var sherpaWithoutDirectML = sync.OnceValue(func() bool {
+	buf := make([]byte, 4096)
+	n := C.sherpa_library_path((*C.char)(unsafe.Pointer(&buf[0])), C.DWORD(len(buf)))
+	if n == 0 {
+		return false
+	}
+	data, err := os.ReadFile(string(buf[:n]))
+	return err == nil && bytes.Contains(data, []byte("DirectML is for Windows only. Fallback to cpu!"))
+})
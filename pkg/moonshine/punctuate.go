@@ -0,0 +1,34 @@
+package moonshine
+
+import (
+	"time"
+
+	sherpa "github.com/k2-fsa/sherpa-onnx-go/sherpa_onnx"
+)
+
+// initPunctuation loads the CNN-BiLSTM punctuation model if available.
+func (e *Engine) initPunctuation(modelPath, vocabPath string) {
+	punctCfg := &sherpa.OnlinePunctuationConfig{}
+	punctCfg.Model.CnnBilstm = modelPath
+	punctCfg.Model.BpeVocab = vocabPath
+	punctCfg.Model.Provider = e.Provider()
+
+	t := time.Now()
+	e.punctuator = sherpa.NewOnlinePunctuation(punctCfg)
+	if e.punctuator == nil {
+		e.logf("WARNING: failed to load punctuation model from %s", modelPath)
+		return
+	}
+	e.logf("Punctuation model loaded in %.2fs", time.Since(t).Seconds())
+}
+
+// addPunctuation adds punctuation to raw transcription text.
+// Returns the original text unchanged if the model is not loaded.
+func (e *Engine) addPunctuation(text string) string {
+	if e.punctuator == nil || text == "" {
+		return text
+	}
+	e.muPunct.Lock()
+	defer e.muPunct.Unlock()
+	return e.punctuator.AddPunct(text)
+}
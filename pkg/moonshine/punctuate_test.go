@@ -1,19 +1,18 @@
-package main
+package moonshine
 
 import "testing"
 
-// --- addPunctuation ---
+// --- Engine.addPunctuation ---
 
 func TestAddPunctuation_NilPunctuator(t *testing.T) {
-	// punctuator is nil by default in tests (no model loaded).
-	got := addPunctuation("hello world")
+	got := new(Engine).addPunctuation("hello world")
 	if got != "hello world" {
 		t.Errorf("addPunctuation with nil punctuator = %q, want passthrough", got)
 	}
 }
 
 func TestAddPunctuation_Empty(t *testing.T) {
-	got := addPunctuation("")
+	got := new(Engine).addPunctuation("")
 	if got != "" {
 		t.Errorf("addPunctuation empty = %q, want empty", got)
 	}
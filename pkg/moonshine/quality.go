@@ -0,0 +1,35 @@
+package moonshine
+
+import "time"
+
+// Qualities of Options.Quality, which trade latency for accuracy.
+const (
+	QualityFast     = "fast"     // the language's model
+	QualityAccurate = "accurate" // the larger EN model of Config.AccurateModelsDir
+)
+
+// accurateSuffix marks the pool of a language's larger, slower model.
+const accurateSuffix = "/accurate"
+
+// ValidQuality reports whether q is a supported quality; "" means
+// QualityFast.
+func ValidQuality(q string) bool { return q == "" || q == QualityFast || q == QualityAccurate }
+
+// loadAccurateModel loads the Moonshine model of Config.AccurateModelsDir. A
+// model that fails to load is skipped with a warning.
+func (e *Engine) loadAccurateModel() {
+	t := time.Now()
+	p, err := e.loadPool("en", e.cfg.AccurateModelsDir)
+	if err != nil {
+		e.logf("WARNING: EN accurate model: %v", err)
+		return
+	}
+	e.setPool("en"+accurateSuffix, p)
+	e.logf("EN accurate model loaded in %.2fs (%d instance(s))", time.Since(t).Seconds(), len(p.all))
+}
+
+// HasAccurate reports whether a model for Options.Quality QualityAccurate is
+// loaded for lang.
+func (e *Engine) HasAccurate(lang string) bool {
+	return e.hasModel(e.langModel(lang) + accurateSuffix)
+}
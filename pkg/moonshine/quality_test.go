@@ -0,0 +1,27 @@
+package moonshine
+
+import (
+	"io"
+	"log"
+	"testing"
+)
+
+// --- loadAccurateModel ---
+
+func TestLoadAccurateModel_SkipsMissing(t *testing.T) {
+	e := &Engine{cfg: Config{AccurateModelsDir: t.TempDir(), Logger: log.New(io.Discard, "", 0)}}
+	e.loadAccurateModel()
+	if e.HasAccurate("en") {
+		t.Error("missing accurate model must not be loaded")
+	}
+}
+
+// --- ValidQuality ---
+
+func TestValidQuality(t *testing.T) {
+	for q, want := range map[string]bool{"": true, QualityFast: true, QualityAccurate: true, "best": false, "Fast": false} {
+		if got := ValidQuality(q); got != want {
+			t.Errorf("ValidQuality(%q) = %v, want %v", q, got, want)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package moonshine
+
+import "math"
+
+// Accepted input sample rates; anything in range is resampled to 16 kHz.
+const (
+	MinSampleRate = 4000
+	MaxSampleRate = 384000
+)
+
+// resampleZeroCrossings is the number of sinc zero crossings on each side of
+// the interpolation kernel. Higher values sharpen the anti-aliasing filter.
+const resampleZeroCrossings = 16
+
+// resample converts samples from rate `from` to rate `to` with a polyphase
+// windowed-sinc filter. When downsampling, the cutoff is lowered to the
+// target Nyquist frequency to avoid aliasing.
+func resample(samples []float32, from, to int) []float32 {
+	if from == to || len(samples) == 0 {
+		return samples
+	}
+	g := gcd(from, to)
+	up, down := to/g, from/g // output advances `down` input steps per `up` phases
+
+	// Cutoff as a fraction of the input Nyquist; 0.95 leaves a transition band.
+	cutoff := 0.95 * min(1, float64(up)/float64(down))
+	halfWidth := int(math.Ceil(resampleZeroCrossings / cutoff))
+	taps := 2 * halfWidth
+
+	// filters[p][j] weights input sample i0-halfWidth+1+j for output phase p.
+	filters := make([][]float32, up)
+	for p := range filters {
+		frac := float64(p) / float64(up)
+		f := make([]float32, taps)
+		for j := range f {
+			u := frac + float64(halfWidth-1-j) // distance from output to input sample
+			f[j] = float32(cutoff * sinc(cutoff*u) * blackman(u/float64(halfWidth)))
+		}
+		filters[p] = f
+	}
+
+	n := len(samples) * up / down
+	out := make([]float32, n)
+	for k := range out {
+		pos := k * down
+		i0, p := pos/up, pos%up
+		first := i0 - halfWidth + 1
+		var acc float32
+		for j, w := range filters[p] {
+			if i := first + j; i >= 0 && i < len(samples) {
+				acc += samples[i] * w
+			}
+		}
+		out[k] = acc
+	}
+	return out
+}
+
+// sinc is the normalized sinc function sin(πx)/(πx).
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+// blackman is the Blackman window on x ∈ [-1, 1], zero outside.
+func blackman(x float64) float64 {
+	if x <= -1 || x >= 1 {
+		return 0
+	}
+	return 0.42 + 0.5*math.Cos(math.Pi*x) + 0.08*math.Cos(2*math.Pi*x)
+}
+
+// gcd returns the greatest common divisor of a and b.
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
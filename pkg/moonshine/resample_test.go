@@ -0,0 +1,82 @@
+package moonshine
+
+import (
+	"math"
+	"testing"
+)
+
+// tone returns n samples of a sine wave at freq Hz sampled at rate.
+func tone(freq float64, rate, n int) []float32 {
+	s := make([]float32, n)
+	for i := range s {
+		s[i] = float32(0.5 * math.Sin(2*math.Pi*freq*float64(i)/float64(rate)))
+	}
+	return s
+}
+
+// rms returns the root mean square of s, ignoring edge samples.
+func rms(s []float32, edge int) float64 {
+	var sum float64
+	for _, v := range s[edge : len(s)-edge] {
+		sum += float64(v) * float64(v)
+	}
+	return math.Sqrt(sum / float64(len(s)-2*edge))
+}
+
+// --- resample ---
+
+func TestResample_Length(t *testing.T) {
+	tests := []struct {
+		from, n, want int
+	}{
+		{16000, 1000, 1000},
+		{8000, 8000, 16000},
+		{44100, 44100, 16000},
+		{48000, 4800, 1600},
+		{22050, 0, 0},
+	}
+	for _, tt := range tests {
+		if got := len(resample(make([]float32, tt.n), tt.from, 16000)); got != tt.want {
+			t.Errorf("resample(%d samples @ %d) len = %d, want %d", tt.n, tt.from, got, tt.want)
+		}
+	}
+}
+
+func TestResample_Identity(t *testing.T) {
+	in := []float32{0.1, -0.2, 0.3}
+	out := resample(in, 16000, 16000)
+	if &out[0] != &in[0] {
+		t.Error("same-rate resample should return the input unchanged")
+	}
+}
+
+func TestResample_DC(t *testing.T) {
+	in := make([]float32, 4410)
+	for i := range in {
+		in[i] = 0.5
+	}
+	out := resample(in, 44100, 16000)
+	for i := 100; i < len(out)-100; i++ {
+		if math.Abs(float64(out[i])-0.5) > 0.005 {
+			t.Fatalf("out[%d] = %f, want ~0.5", i, out[i])
+		}
+	}
+}
+
+func TestResample_PassbandTone(t *testing.T) {
+	for _, from := range []int{8000, 44100, 48000} {
+		out := resample(tone(1000, from, from), from, 16000)
+		want := 0.5 / math.Sqrt2
+		if got := rms(out, 200); math.Abs(got-want) > 0.01 {
+			t.Errorf("1 kHz tone @ %d Hz: rms = %f, want ~%f", from, got, want)
+		}
+	}
+}
+
+func TestResample_RejectsAliases(t *testing.T) {
+	// A 12 kHz tone is above the 8 kHz output Nyquist and must be filtered out.
+	out := resample(tone(12000, 48000, 48000), 48000, 16000)
+	if got := rms(out, 200); got > 0.01 {
+		t.Errorf("12 kHz tone leaked through: rms = %f", got)
+	}
+}
@@ -0,0 +1,102 @@
+package moonshine
+
+import (
+	"context"
+	"math"
+	"time"
+
+	sherpa "github.com/k2-fsa/sherpa-onnx-go/sherpa_onnx"
+)
+
+// initSpeakerEmbedding loads the speaker embedding model used for speaker
+// verification and identification.
+func (e *Engine) initSpeakerEmbedding(model string) {
+	c := sherpa.SpeakerEmbeddingExtractorConfig{Model: model, NumThreads: e.cfg.NumThreads, Provider: e.Provider()}
+
+	t := time.Now()
+	e.embedder = sherpa.NewSpeakerEmbeddingExtractor(&c)
+	if e.embedder == nil {
+		e.logf("WARNING: failed to load speaker embedding model from %s", model)
+		return
+	}
+	e.logf("Speaker embedding model loaded in %.2fs (dim=%d)", time.Since(t).Seconds(), e.embedder.Dim())
+}
+
+// SpeakerEmbedding decodes the audio at path like TranscribeFile and returns
+// a unit-length voice print of its speech, for comparing speakers with
+// CosineSimilarity. With VAD applied as for transcription, only the speech
+// is used. It fails with ErrUnavailable when the speaker embedding model is
+// not loaded and with ErrInvalidAudio when the audio has no speech.
+func (e *Engine) SpeakerEmbedding(ctx context.Context, path string, opts Options) ([]float32, error) {
+	if e.embedder == nil {
+		return nil, errorf(ErrUnavailable, "speaker embedding model not loaded")
+	}
+	samples, rate, err := e.decodeChecked(ctx, path, opts)
+	if err != nil {
+		return nil, err
+	}
+	if rate != 16000 {
+		samples = resample(samples, rate, 16000)
+	}
+	chunks, spans, _ := e.buildAudioChunks(samples, float64(len(samples))/16000, opts)
+	if spans != nil {
+		samples = nil
+		for _, c := range chunks {
+			samples = append(samples, c...)
+		}
+	}
+	if len(samples) == 0 {
+		return nil, errorf(ErrInvalidAudio, "no speech found")
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return e.embed(samples), nil
+}
+
+// embed computes the normalized embedding of 16 kHz samples.
+func (e *Engine) embed(samples []float32) []float32 {
+	e.muEmbed.Lock()
+	defer e.muEmbed.Unlock()
+
+	s := e.embedder.CreateStream()
+	defer sherpa.DeleteOnlineStream(s)
+	s.AcceptWaveform(16000, samples)
+	s.InputFinished()
+	return normalize(e.embedder.Compute(s))
+}
+
+// normalize scales v to unit length in place and returns it.
+func normalize(v []float32) []float32 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return v
+	}
+	scale := float32(1 / math.Sqrt(sum))
+	for i := range v {
+		v[i] *= scale
+	}
+	return v
+}
+
+// CosineSimilarity returns the cosine of the angle between two embeddings,
+// from -1 to 1; the same speaker scores high. It is 0 when the lengths
+// differ or either is zero.
+func CosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
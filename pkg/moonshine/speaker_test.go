@@ -0,0 +1,51 @@
+package moonshine
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+)
+
+// --- CosineSimilarity ---
+
+func TestCosineSimilarity(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []float32
+		want float64
+	}{
+		{"same", []float32{1, 2, 3}, []float32{1, 2, 3}, 1},
+		{"scaled", []float32{1, 2, 3}, []float32{2, 4, 6}, 1},
+		{"opposite", []float32{1, 0}, []float32{-1, 0}, -1},
+		{"orthogonal", []float32{1, 0}, []float32{0, 1}, 0},
+		{"length mismatch", []float32{1, 0}, []float32{1, 0, 0}, 0},
+		{"zero", []float32{0, 0}, []float32{1, 0}, 0},
+	}
+	for _, tt := range tests {
+		if got := CosineSimilarity(tt.a, tt.b); math.Abs(got-tt.want) > 1e-6 {
+			t.Errorf("%s: CosineSimilarity = %g, want %g", tt.name, got, tt.want)
+		}
+	}
+}
+
+// --- normalize ---
+
+func TestNormalize(t *testing.T) {
+	v := normalize([]float32{3, 4})
+	if math.Abs(float64(v[0])-0.6) > 1e-6 || math.Abs(float64(v[1])-0.8) > 1e-6 {
+		t.Errorf("normalize([3 4]) = %v, want [0.6 0.8]", v)
+	}
+	if v := normalize([]float32{0, 0}); v[0] != 0 || v[1] != 0 {
+		t.Errorf("normalize([0 0]) = %v, want zeros", v)
+	}
+}
+
+// --- Engine.SpeakerEmbedding ---
+
+func TestSpeakerEmbedding_NoModel(t *testing.T) {
+	_, err := new(Engine).SpeakerEmbedding(context.Background(), "/nonexistent/clip.wav", Options{})
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("SpeakerEmbedding without model: err = %v, want ErrUnavailable", err)
+	}
+}
@@ -0,0 +1,274 @@
+package moonshine
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	sherpa "github.com/k2-fsa/sherpa-onnx-go/sherpa_onnx"
+)
+
+// onlineModel is a streaming recognizer. Streams share it; mu serializes
+// their decode steps.
+type onlineModel struct {
+	mu sync.Mutex
+	r  *sherpa.OnlineRecognizer
+}
+
+// StreamResult is a hypothesis from a Stream. A partial result may still be
+// revised; a final one ends an utterance and is not.
+type StreamResult struct {
+	Text  string  `json:"text"`
+	Final bool    `json:"final"`
+	Start float64 `json:"start"` // utterance start, seconds from the start of the stream
+	End   float64 `json:"end"`
+
+	// FirstPass is the streaming model's text for a final result that was
+	// re-decoded with the offline model.
+	FirstPass string `json:"first_pass,omitempty"`
+}
+
+// Stream recognizes audio as it arrives with a streaming model. A Stream is
+// not safe for concurrent use; open one per audio source.
+type Stream struct {
+	e         *Engine
+	m         *onlineModel
+	s         *sherpa.OnlineStream
+	opts      Options
+	punctuate bool
+	twoPass   bool
+
+	samples  int       // 16 kHz samples accepted so far
+	uttStart int       // sample offset where the current utterance began
+	utt      []float32 // audio of the current utterance, kept for two-pass decoding
+	partial  string    // last partial text returned
+
+	hyp      string // current hypothesis of the utterance
+	hypSince int    // sample offset where hyp last changed
+}
+
+// Default endpointing of a stream, as in sherpa-onnx's endpoint rules.
+const (
+	defaultEndpointSilenceS      = 1.2
+	defaultEndpointIdleS         = 2.4
+	defaultEndpointMaxUtteranceS = 20
+)
+
+// loadStreamingModels loads the streaming recognizers in Config.StreamingModels.
+// A model that fails to load is skipped with a warning.
+func (e *Engine) loadStreamingModels() {
+	langs := make([]string, 0, len(e.cfg.StreamingModels))
+	for lang := range e.cfg.StreamingModels {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	for _, lang := range langs {
+		dir := e.cfg.StreamingModels[lang]
+		t := time.Now()
+		r, err := e.newOnlineRecognizer(lang, dir)
+		if err != nil {
+			e.logf("WARNING: %s streaming model: %v", strings.ToUpper(lang), err)
+			continue
+		}
+		if e.online == nil {
+			e.online = make(map[string]*onlineModel)
+		}
+		e.online[lang] = &onlineModel{r: r}
+		e.logf("%s streaming model loaded in %.2fs", strings.ToUpper(lang), time.Since(t).Seconds())
+	}
+}
+
+// newOnlineRecognizer loads the streaming Zipformer transducer of lang from dir.
+// Endpoints are detected per stream by Stream.endpoint instead of by the
+// recognizer, so each stream can have its own rules.
+func (e *Engine) newOnlineRecognizer(lang, dir string) (*sherpa.OnlineRecognizer, error) {
+	c := &sherpa.OnlineRecognizerConfig{}
+	c.FeatConfig.SampleRate = 16000
+	c.FeatConfig.FeatureDim = 80
+	c.ModelConfig.Transducer.Encoder = e.onnxFile(dir, "encoder")
+	c.ModelConfig.Transducer.Decoder = e.onnxFile(dir, "decoder")
+	c.ModelConfig.Transducer.Joiner = e.onnxFile(dir, "joiner")
+	c.ModelConfig.Tokens = filepath.Join(dir, "tokens.txt")
+	c.ModelConfig.NumThreads = e.cfg.threads(lang)
+	c.ModelConfig.Provider = e.Provider()
+	c.DecodingMethod = GreedySearch
+	if err := requireFiles(c.ModelConfig.Transducer.Encoder, c.ModelConfig.Transducer.Decoder,
+		c.ModelConfig.Transducer.Joiner, c.ModelConfig.Tokens); err != nil {
+		return nil, err
+	}
+	r := sherpa.NewOnlineRecognizer(c)
+	if r == nil {
+		return nil, fmt.Errorf("failed to load streaming model from %s", dir)
+	}
+	return r, nil
+}
+
+// HasStreaming reports whether a streaming model is loaded for lang.
+func (e *Engine) HasStreaming(lang string) bool { return e.online[lang] != nil }
+
+// NewStream opens a stream on the streaming model for opts.Lang. Lang,
+// Punctuate, ITN, TwoPass, and the Endpoint options apply; punctuation and
+// ITN, when enabled, are applied to final results. Endpointing defaults to
+// 1.2s of silence after speech, 2.4s without speech, and 20s utterances.
+func (e *Engine) NewStream(opts Options) (*Stream, error) {
+	lang := opts.Lang
+	if lang == "" {
+		lang = "en"
+	}
+	m := e.online[lang]
+	if m == nil {
+		return nil, errorf(ErrUnavailable, "%s streaming model not loaded", strings.ToUpper(lang))
+	}
+	doPunct := e.punctuator != nil && lang == "en"
+	if opts.Punctuate != nil {
+		doPunct = *opts.Punctuate && e.punctuator != nil
+	}
+	// Two-pass: auto (nil) = yes if the offline model is loaded. With
+	// Config.LazyLoad, streams opened before it loads are single-pass.
+	e.ensureModel(context.Background(), e.langModel(lang), false) //nolint:errcheck
+	twoPass := e.HasLanguage(lang)
+	if opts.TwoPass != nil {
+		if *opts.TwoPass && !twoPass {
+			return nil, errorf(ErrUnavailable, "%s model not loaded", strings.ToUpper(lang))
+		}
+		twoPass = *opts.TwoPass
+	}
+	opts.Lang = lang
+	if opts.EndpointSilenceS <= 0 {
+		opts.EndpointSilenceS = defaultEndpointSilenceS
+	}
+	if opts.EndpointIdleS <= 0 {
+		opts.EndpointIdleS = defaultEndpointIdleS
+	}
+	if opts.EndpointMaxUtteranceS <= 0 {
+		opts.EndpointMaxUtteranceS = defaultEndpointMaxUtteranceS
+	}
+	return &Stream{e: e, m: m, s: sherpa.NewOnlineStream(m.r), opts: opts, punctuate: doPunct, twoPass: twoPass}, nil
+}
+
+// Accept feeds mono samples in [-1, 1] at sampleRate and decodes what is
+// ready. It returns a partial result when the hypothesis changed and a
+// final one when an endpoint was detected.
+func (s *Stream) Accept(samples []float32, sampleRate int) []StreamResult {
+	if len(samples) == 0 {
+		return nil
+	}
+	if sampleRate != 16000 {
+		samples = resample(samples, sampleRate, 16000)
+	}
+	s.m.mu.Lock()
+	s.s.AcceptWaveform(16000, samples)
+	s.samples += len(samples)
+	if s.twoPass {
+		s.utt = append(s.utt, samples...)
+	}
+	r, utt := s.step(false)
+	s.m.mu.Unlock()
+	return s.emit(r, utt)
+}
+
+// Finish marks the end of the audio, decodes the rest, and returns the
+// final result for the trailing utterance, if it has any text.
+func (s *Stream) Finish() []StreamResult {
+	s.m.mu.Lock()
+	s.s.InputFinished()
+	r, utt := s.step(true)
+	s.m.mu.Unlock()
+	return s.emit(r, utt)
+}
+
+// Duration returns the length of the audio accepted so far in seconds.
+func (s *Stream) Duration() float64 { return float64(s.samples) / 16000 }
+
+// Close frees the stream.
+func (s *Stream) Close() {
+	if s.s != nil {
+		sherpa.DeleteOnlineStream(s.s)
+		s.s = nil
+	}
+}
+
+// step runs the streaming model over the buffered frames and returns the
+// current hypothesis. At an endpoint, or when flush is set, the utterance is
+// closed and returned as final along with its audio for two-pass decoding.
+// Callers hold s.m.mu.
+func (s *Stream) step(flush bool) (StreamResult, []float32) {
+	for s.m.r.IsReady(s.s) {
+		s.m.r.Decode(s.s)
+	}
+	r := StreamResult{
+		Text:  strings.TrimSpace(sanitizeUTF8(s.m.r.GetResult(s.s).Text)),
+		Start: float64(s.uttStart) / 16000,
+		End:   float64(s.samples) / 16000,
+	}
+	if r.Text != s.hyp {
+		s.hyp, s.hypSince = r.Text, s.samples
+	}
+	if !flush && !s.endpoint() {
+		return r, nil
+	}
+	r.Final = true
+	utt := s.utt
+	s.m.r.Reset(s.s)
+	s.uttStart, s.utt = s.samples, nil
+	s.hyp, s.hypSince = "", s.samples
+	return r, utt
+}
+
+// endpoint reports whether the current utterance has ended by the stream's
+// endpointing rules. The hypothesis standing still stands in for trailing
+// silence: the model emits no tokens while nobody speaks.
+func (s *Stream) endpoint() bool {
+	silence := float64(s.samples-s.hypSince) / 16000
+	switch {
+	case float64(s.samples-s.uttStart)/16000 >= s.opts.EndpointMaxUtteranceS:
+		return true
+	case s.hyp == "":
+		return silence >= s.opts.EndpointIdleS
+	default:
+		return silence >= s.opts.EndpointSilenceS
+	}
+}
+
+// emit turns a hypothesis into the results to return: partial results only
+// when the text changed, final ones re-decoded with the offline model in
+// two-pass mode, punctuated, and normalized.
+func (s *Stream) emit(r StreamResult, utt []float32) []StreamResult {
+	if !r.Final {
+		if r.Text == s.partial {
+			return nil
+		}
+		s.partial = r.Text
+		return []StreamResult{r}
+	}
+	s.partial = ""
+	if r.Text == "" {
+		return nil
+	}
+	if s.twoPass && len(utt) > 0 {
+		r.FirstPass = r.Text
+		r.Text = s.rescore(utt, r.Text)
+	}
+	if s.punctuate {
+		r.Text = s.e.addPunctuation(r.Text)
+	}
+	if s.opts.ITN {
+		r.Text = normalizeText(r.Text, s.opts.Lang)
+	}
+	return []StreamResult{r}
+}
+
+// rescore decodes an utterance with the offline model. The first-pass text
+// stands when the offline pass yields nothing or is suppressed as a
+// hallucination.
+func (s *Stream) rescore(utt []float32, firstPass string) string {
+	texts, err := s.e.recognizeChunks(context.Background(), [][]float32{utt}, 16000, s.opts)
+	if err != nil || texts[0].Text == "" {
+		return firstPass
+	}
+	return texts[0].Text
+}
@@ -0,0 +1,87 @@
+package moonshine
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"log"
+	"testing"
+)
+
+// --- newOnlineRecognizer ---
+
+func TestNewOnlineRecognizer_MissingFiles(t *testing.T) {
+	if _, err := new(Engine).newOnlineRecognizer("en", t.TempDir()); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("err = %v, want fs.ErrNotExist", err)
+	}
+}
+
+// --- loadStreamingModels ---
+
+func TestLoadStreamingModels_SkipsMissing(t *testing.T) {
+	e := &Engine{cfg: Config{
+		StreamingModels: map[string]string{"en": t.TempDir()},
+		Logger:          log.New(io.Discard, "", 0),
+	}}
+	e.loadStreamingModels()
+	if e.HasStreaming("en") {
+		t.Error("missing streaming model must not be loaded")
+	}
+}
+
+// --- Engine.NewStream ---
+
+func TestNewStream_Unavailable(t *testing.T) {
+	for _, lang := range []string{"", "en", "ru"} {
+		_, err := new(Engine).NewStream(Options{Lang: lang})
+		if !errors.Is(err, ErrUnavailable) {
+			t.Errorf("lang %q: err = %v, want ErrUnavailable", lang, err)
+		}
+	}
+}
+
+// --- Stream.emit ---
+
+func TestStreamEmit(t *testing.T) {
+	s := &Stream{e: new(Engine)}
+	if got := s.emit(StreamResult{Text: "hello"}, nil); len(got) != 1 || got[0].Text != "hello" {
+		t.Errorf("first partial = %+v, want hello", got)
+	}
+	if got := s.emit(StreamResult{Text: "hello"}, nil); got != nil {
+		t.Errorf("unchanged partial = %+v, want none", got)
+	}
+	if got := s.emit(StreamResult{Text: "hello world", Final: true}, nil); len(got) != 1 || !got[0].Final || got[0].FirstPass != "" {
+		t.Errorf("final = %+v, want one single-pass final", got)
+	}
+	if got := s.emit(StreamResult{Final: true}, nil); got != nil {
+		t.Errorf("empty final = %+v, want none", got)
+	}
+	if got := s.emit(StreamResult{Text: "hello"}, nil); len(got) != 1 {
+		t.Errorf("partial after final = %+v, want it reported again", got)
+	}
+}
+
+// --- Stream.endpoint ---
+
+func TestStreamEndpoint(t *testing.T) {
+	opts := Options{EndpointSilenceS: 1, EndpointIdleS: 2, EndpointMaxUtteranceS: 10}
+	tests := []struct {
+		name                      string
+		hyp                       string
+		uttStart, hypSince, samps float64 // seconds
+		want                      bool
+	}{
+		{"speech, short pause", "hello", 0, 3, 3.5, false},
+		{"speech, long pause", "hello", 0, 3, 4, true},
+		{"no speech, short", "", 0, 0, 1.5, false},
+		{"no speech, idle", "", 0, 0, 2, true},
+		{"max utterance", "hello", 0, 9.9, 10, true},
+		{"after reset", "hello", 10, 11, 11.5, false},
+	}
+	for _, tt := range tests {
+		s := &Stream{opts: opts, hyp: tt.hyp, uttStart: int(tt.uttStart * 16000), hypSince: int(tt.hypSince * 16000), samples: int(tt.samps * 16000)}
+		if got := s.endpoint(); got != tt.want {
+			t.Errorf("%s: endpoint = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package moonshine
+
+import (
+	"context"
+	"time"
+
+	sherpa "github.com/k2-fsa/sherpa-onnx-go/sherpa_onnx"
+)
+
+// Audio tagging model architectures for Config.TaggingModelType.
+const (
+	TaggingZipformer = "zipformer"
+	TaggingCED       = "ced"
+)
+
+// AudioTag is a sound event class found in audio, from the AudioSet
+// ontology: "Speech", "Music", "Applause", "Dog", "Silence", and so on.
+type AudioTag struct {
+	Name string  `json:"name"`
+	Prob float64 `json:"prob"`
+}
+
+// initTagging loads the audio tagging model and its class labels.
+func (e *Engine) initTagging(model, labels string) {
+	c := sherpa.AudioTaggingConfig{Labels: labels, TopK: 5}
+	if e.cfg.TaggingModelType == TaggingCED {
+		c.Model.Ced = model
+	} else {
+		c.Model.Zipformer.Model = model
+	}
+	c.Model.NumThreads = int32(e.cfg.NumThreads)
+	c.Model.Provider = e.Provider()
+
+	t := time.Now()
+	e.tagger = sherpa.NewAudioTagging(&c)
+	if e.tagger == nil {
+		e.logf("WARNING: failed to load audio tagging model from %s", model)
+		return
+	}
+	e.logf("Audio tagging model loaded in %.2fs (%s)", time.Since(t).Seconds(), e.cfg.TaggingModelType)
+}
+
+// Classify decodes the audio at path like TranscribeFile and returns its
+// topK most likely sound event classes, most likely first. It fails with
+// ErrUnavailable when the audio tagging model is not loaded.
+func (e *Engine) Classify(ctx context.Context, path string, opts Options, topK int) ([]AudioTag, error) {
+	if e.tagger == nil {
+		return nil, errorf(ErrUnavailable, "audio tagging model not loaded")
+	}
+	samples, rate, err := e.decodeChecked(ctx, path, opts)
+	if err != nil {
+		return nil, err
+	}
+	if rate != 16000 {
+		samples = resample(samples, rate, 16000)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return e.classifySamples(samples, topK), nil
+}
+
+// classifySamples runs the tagging model over 16 kHz samples.
+func (e *Engine) classifySamples(samples []float32, topK int) []AudioTag {
+	e.muTagging.Lock()
+	defer e.muTagging.Unlock()
+
+	s := sherpa.NewAudioTaggingStream(e.tagger)
+	defer sherpa.DeleteOfflineStream(s)
+	s.AcceptWaveform(16000, samples)
+	events := e.tagger.Compute(s, int32(topK))
+	tags := make([]AudioTag, len(events))
+	for i, ev := range events {
+		tags[i] = AudioTag{Name: ev.Name, Prob: float64(ev.Prob)}
+	}
+	return tags
+}
@@ -0,0 +1,16 @@
+package moonshine
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// --- Engine.Classify ---
+
+func TestClassify_NoModel(t *testing.T) {
+	_, err := new(Engine).Classify(context.Background(), "/nonexistent/clip.wav", Options{}, 5)
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Classify without model: err = %v, want ErrUnavailable", err)
+	}
+}
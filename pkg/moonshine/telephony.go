@@ -0,0 +1,77 @@
+package moonshine
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// telephonySuffix marks the pool of a language's telephony model.
+const telephonySuffix = "/telephony"
+
+// modelArch returns the base model (one of Languages) whose architecture model
+// shares, so telephony and accurate models decode like the language's own
+// model.
+func modelArch(model string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(model, telephonySuffix), accurateSuffix)
+}
+
+// loadTelephonyModels loads the recognizers in Config.TelephonyModels. A
+// model that fails to load is skipped with a warning.
+func (e *Engine) loadTelephonyModels() {
+	langs := make([]string, 0, len(e.cfg.TelephonyModels))
+	for lang := range e.cfg.TelephonyModels {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	for _, lang := range langs {
+		t := time.Now()
+		p, err := e.loadPool(e.langModel(lang), e.cfg.TelephonyModels[lang])
+		if err != nil {
+			e.logf("WARNING: %s telephony model: %v", strings.ToUpper(lang), err)
+			continue
+		}
+		e.setPool(e.langModel(lang)+telephonySuffix, p)
+		e.logf("%s telephony model loaded in %.2fs (%d instance(s))", strings.ToUpper(lang), time.Since(t).Seconds(), len(p.all))
+	}
+}
+
+// HasTelephony reports whether a telephony model is loaded for lang.
+func (e *Engine) HasTelephony(lang string) bool {
+	return e.hasModel(e.langModel(lang) + telephonySuffix)
+}
+
+// selectModel picks the recognizer model for lang and audio recorded at
+// sampleRate. The telephony model is used when telephony is set, or, when
+// it is nil, for narrowband audio (8 kHz or less) if one is loaded. Other
+// audio goes to the accurate model with QualityAccurate; only EN has one,
+// so other languages keep their model. ZH without its own model runs on
+// the EN one.
+func (e *Engine) selectModel(lang string, sampleRate int, telephony *bool, quality string) (string, error) {
+	model := e.langModel(lang)
+	if model != "ru" && !e.hasModel(model) {
+		model = "en" // Moonshine also speaks the language
+	}
+	tel := model + telephonySuffix
+	switch {
+	case telephony == nil:
+		if sampleRate <= 8000 && e.hasModel(tel) {
+			return tel, nil
+		}
+	case *telephony:
+		if !e.hasModel(tel) {
+			return "", errorf(ErrUnavailable, "%s telephony model not loaded", strings.ToUpper(model))
+		}
+		return tel, nil
+	}
+	if quality == QualityAccurate && model == "en" {
+		if !e.hasModel(model + accurateSuffix) {
+			return "", errorf(ErrUnavailable, "EN accurate model not loaded")
+		}
+		return model + accurateSuffix, nil
+	}
+	if !e.hasModel(model) {
+		return "", errorf(ErrUnavailable, "%s model not loaded", strings.ToUpper(model))
+	}
+	return model, nil
+}
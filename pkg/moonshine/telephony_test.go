@@ -0,0 +1,76 @@
+package moonshine
+
+import (
+	"errors"
+	"io"
+	"log"
+	"testing"
+)
+
+// --- loadTelephonyModels ---
+
+func TestLoadTelephonyModels_SkipsMissing(t *testing.T) {
+	e := &Engine{cfg: Config{
+		TelephonyModels: map[string]string{"ru": t.TempDir()},
+		Logger:          log.New(io.Discard, "", 0),
+	}}
+	e.loadTelephonyModels()
+	if e.HasTelephony("ru") {
+		t.Error("missing telephony model must not be loaded")
+	}
+}
+
+// --- Engine.selectModel ---
+
+func TestSelectModel(t *testing.T) {
+	yes, no := true, false
+	full := &Engine{pools: map[string]*recognizerPool{
+		"en": testPool(1), "ru": testPool(1), "en/telephony": testPool(1), "en/accurate": testPool(1),
+	}}
+	tests := []struct {
+		name      string
+		lang      string
+		rate      int
+		telephony *bool
+		quality   string
+		want      string
+		err       error
+	}{
+		{"wideband", "en", 16000, nil, "", "en", nil},
+		{"narrowband auto", "en", 8000, nil, "", "en/telephony", nil},
+		{"other language", "es", 8000, nil, "", "en/telephony", nil},
+		{"narrowband off", "en", 8000, &no, "", "en", nil},
+		{"wideband forced", "en", 44100, &yes, "", "en/telephony", nil},
+		{"no RU telephony", "ru", 8000, nil, "", "ru", nil},
+		{"fast", "en", 16000, nil, QualityFast, "en", nil},
+		{"accurate", "en", 16000, nil, QualityAccurate, "en/accurate", nil},
+		{"accurate narrowband", "en", 8000, nil, QualityAccurate, "en/telephony", nil},
+		{"accurate RU", "ru", 16000, nil, QualityAccurate, "ru", nil},
+		{"RU telephony forced", "ru", 8000, &yes, "", "", ErrUnavailable},
+		{"ZH on Moonshine", "zh", 16000, nil, "", "en", nil},
+	}
+	for _, tt := range tests {
+		got, err := full.selectModel(tt.lang, tt.rate, tt.telephony, tt.quality)
+		if got != tt.want || !errors.Is(err, tt.err) {
+			t.Errorf("%s: selectModel = %q, %v, want %q, %v", tt.name, got, err, tt.want, tt.err)
+		}
+	}
+
+	if _, err := new(Engine).selectModel("en", 8000, nil, ""); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("empty engine: err = %v, want ErrUnavailable", err)
+	}
+	base := &Engine{pools: map[string]*recognizerPool{"en": testPool(1)}}
+	if _, err := base.selectModel("en", 16000, nil, QualityAccurate); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("no accurate model: err = %v, want ErrUnavailable", err)
+	}
+}
+
+// --- modelArch ---
+
+func TestModelArch(t *testing.T) {
+	for model, want := range map[string]string{"en": "en", "ru/telephony": "ru", "en/telephony": "en", "en/accurate": "en"} {
+		if got := modelArch(model); got != want {
+			t.Errorf("modelArch(%q) = %q, want %q", model, got, want)
+		}
+	}
+}
@@ -0,0 +1,624 @@
+package moonshine
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	sherpa "github.com/k2-fsa/sherpa-onnx-go/sherpa_onnx"
+)
+
+// Decoding methods supported by transducer models.
+const (
+	GreedySearch       = "greedy_search"
+	ModifiedBeamSearch = "modified_beam_search"
+	MaxBeamSize        = 32
+)
+
+// ValidDecodingMethod reports whether m is a supported decoding method.
+func ValidDecodingMethod(m string) bool {
+	return m == GreedySearch || m == ModifiedBeamSearch
+}
+
+// TranscribeFile decodes the audio at path and transcribes it. WAV, MP3,
+// FLAC, and Ogg Vorbis are decoded in-process when Config.NativeDecode is
+// set; other formats, and files the native decoders reject, go through
+// ffmpeg. With Options.SplitChannels each channel is transcribed on its own
+// (see TranscribeChannels). Work stops early with ctx.Err() once ctx is done.
+func (e *Engine) TranscribeFile(ctx context.Context, path string, opts Options) (Result, error) {
+	if opts.SplitChannels {
+		return e.transcribeFileChannels(ctx, path, opts)
+	}
+	samples, sampleRate, err := e.decodeFile(ctx, path, opts)
+	if err != nil {
+		return Result{}, err
+	}
+	if opts.OnDecoded != nil {
+		opts.OnDecoded(samples, sampleRate)
+	}
+	return e.TranscribeSamples(ctx, samples, sampleRate, opts)
+}
+
+// decodeFile decodes the audio at path to mono samples, in-process when
+// Config.NativeDecode is set and the format and Options.AudioTrack allow,
+// with ffmpeg otherwise. The channels are averaged unless Options.Channel
+// selects one.
+// Decoding stops past the duration limit of opts.
+func (e *Engine) decodeFile(ctx context.Context, path string, opts Options) ([]float32, int, error) {
+	channel, ok := channelIndex(opts.Channel)
+	if !ok {
+		return nil, 0, errorf(ErrInvalidAudio, "invalid channel %q", opts.Channel)
+	}
+	if channel >= 0 {
+		return e.decodeChannel(ctx, path, channel, opts)
+	}
+	maxDurationS := e.maxDuration(opts)
+	if e.cfg.NativeDecode && opts.AudioTrack == 0 {
+		samples, sampleRate, err := decodeNative(path, maxDurationS)
+		if err == nil {
+			return samples, sampleRate, nil
+		}
+		if !errors.Is(err, errNotNative) {
+			e.logCall(opts, "native decode failed, falling back to ffmpeg: %v", err)
+		}
+	}
+
+	wavPath, cleanupPath, err := e.ensureWav(ctx, path, opts.AudioTrack, false, maxDurationS)
+	if ctx.Err() != nil {
+		return nil, 0, ctx.Err()
+	}
+	if err != nil {
+		return nil, 0, conversionError(err)
+	}
+	if cleanupPath != "" {
+		defer os.Remove(cleanupPath) //nolint:errcheck
+	}
+
+	samples, sampleRate, err := loadWav(wavPath)
+	if err != nil {
+		return nil, 0, errorf(ErrInvalidAudio, "load wav: %v", err)
+	}
+	return samples, sampleRate, nil
+}
+
+// maxDuration returns the audio length limit for a call with opts.
+func (e *Engine) maxDuration(opts Options) float64 {
+	if opts.MaxAudioDurationS > 0 && opts.MaxAudioDurationS < e.cfg.MaxAudioDurationS {
+		return opts.MaxAudioDurationS
+	}
+	return e.cfg.MaxAudioDurationS
+}
+
+// TranscribeSamples runs duration checks, resampling to 16 kHz, denoising,
+// loudness normalization, VAD (or diarization), recognition, and
+// punctuation on decoded mono samples, trimming silence first with
+// Options.TrimSilence, finding DTMF key presses with Options.DTMF, and
+// silencing music with Options.SkipMusic. With Options.Lang LangAuto the language is
+// identified first, except for TaskTranslate, where Whisper identifies it
+// and Result.Language is empty.
+func (e *Engine) TranscribeSamples(ctx context.Context, samples []float32, sampleRate int, opts Options) (Result, error) {
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+	if len(samples) == 0 {
+		return Result{}, errorf(ErrInvalidAudio, "no audio samples")
+	}
+	if sampleRate < MinSampleRate || sampleRate > MaxSampleRate {
+		return Result{}, errorf(ErrInvalidAudio, "unsupported sample rate %d (need %d-%d)", sampleRate, MinSampleRate, MaxSampleRate)
+	}
+
+	audioDurS := float64(len(samples)) / float64(sampleRate)
+	if limit := e.maxDuration(opts); limit > 0 && audioDurS > limit {
+		return Result{}, errorf(ErrInvalidAudio, "audio too long: %.1fs > max %.0fs", audioDurS, limit)
+	}
+	lang := opts.Lang
+	if lang == "" {
+		lang = "en"
+	}
+	inputRate := sampleRate
+	if sampleRate != 16000 {
+		samples, sampleRate = resample(samples, sampleRate, 16000), 16000
+	}
+	var dtmf []DTMFEvent
+	if opts.DTMF {
+		dtmf = detectDTMF(samples)
+		samples = muteDTMF(samples, dtmf)
+	}
+	var skipped []Span
+	if opts.SkipMusic {
+		if e.tagger == nil {
+			return Result{}, errorf(ErrUnavailable, "audio tagging model not loaded")
+		}
+		var err error
+		if skipped, err = e.musicSpans(ctx, samples); err != nil {
+			return Result{}, err
+		}
+		samples = muteSpans(samples, skipped)
+	}
+	if opts.Denoise {
+		if e.denoiser == nil {
+			return Result{}, errorf(ErrUnavailable, "denoising model not loaded")
+		}
+		samples = e.denoise(samples)
+	}
+	if opts.Normalize {
+		samples = normalizeLoudness(samples)
+	}
+	translate := opts.Task == TaskTranslate
+	switch {
+	case lang == LangAuto && translate:
+		lang = ""
+	case lang == LangAuto:
+		var err error
+		if lang, err = e.detectLanguage(ctx, samples, opts); err != nil {
+			return Result{}, err
+		}
+		opts.Lang = lang
+	}
+	if err := e.ensureLoaded(ctx, lang, opts); err != nil {
+		return Result{}, err
+	}
+	model, err := e.taskModel(lang, inputRate, opts)
+	if err != nil {
+		return Result{}, err
+	}
+	opts.model = model
+	if err := e.CheckDecoding(opts); err != nil {
+		return Result{}, err
+	}
+	if opts.Diarize && e.diarizer == nil {
+		return Result{}, errorf(ErrUnavailable, "diarization models not loaded")
+	}
+	if opts.Emotions && e.emotion == nil {
+		return Result{}, errorf(ErrUnavailable, "emotion model not loaded")
+	}
+	var trimmedS float64 // seconds trimmed from the start
+	if opts.TrimSilence {
+		var offset int
+		if samples, offset = trimSilence(samples); len(samples) == 0 {
+			return Result{Language: lang, AudioS: audioDurS, DTMF: dtmf, Skipped: skipped}, nil
+		}
+		trimmedS = float64(offset) / 16000
+	}
+
+	// Translations are English text.
+	textLang := lang
+	if translate {
+		textLang = "en"
+	}
+	// Apply punctuation: auto (nil) = yes if EN and model loaded, except for
+	// models that punctuate themselves; explicit override respected.
+	doPunct := e.punctuator != nil && lang == "en" && !e.selfPunctuating(model)
+	if opts.Punctuate != nil {
+		doPunct = *opts.Punctuate && e.punctuator != nil
+	}
+
+	// emit passes a segment to opts.OnSegment as the Result will have it.
+	var emit func(Segment)
+	emitted := false
+	if opts.OnSegment != nil {
+		emit = func(seg Segment) {
+			emitted = true
+			seg.Text = e.finishText(seg.Text, doPunct, opts.ITN, textLang)
+			seg.Speech = slices.Clone(seg.Speech) // shifted apart from the Result's
+			shifted := []Segment{seg}
+			shiftSegments(shifted, trimmedS)
+			opts.OnSegment(shifted[0])
+		}
+	}
+
+	var segments []Segment
+	var speechMs float64
+	if opts.Diarize {
+		maxSpeakers := opts.MaxSpeakers
+		if maxSpeakers == 0 {
+			maxSpeakers = e.cfg.DiarizeMaxSpeakers
+		}
+		segments, speechMs, err = e.transcribeDiarized(ctx, samples, sampleRate, opts, maxSpeakers, emit)
+		if err != nil {
+			return Result{}, err
+		}
+	} else {
+		chunks, spans, vadSpeechMs := e.buildAudioChunks(samples, audioDurS, opts)
+		overlap := 0
+		if e.whisperModel(model) && spans == nil && len(chunks) == 1 {
+			overlap = e.chunkOverlap(sampleRate, maxSegmentSamples)
+			chunks = splitSamples(chunks[0], maxSegmentSamples, overlap) // Whisper hears 30s at a time
+		}
+		if emit != nil && spans != nil {
+			opts.chunkDone = func(i int, t chunkText) { emit(vadSegments([]chunkText{t}, spans[i:i+1])[0]) }
+		}
+		if opts.OnChunk != nil {
+			emitChunk, onChunk := opts.chunkDone, opts.OnChunk
+			opts.chunkDone = func(i int, t chunkText) {
+				onChunk(i, len(chunks), t.result())
+				if emitChunk != nil {
+					emitChunk(i, t)
+				}
+			}
+		}
+		if opts.Resume != nil && opts.Resume.Total == len(chunks) {
+			opts.resumed = opts.Resume.Chunks
+		}
+		texts, err := e.recognizeChunks(ctx, chunks, sampleRate, opts)
+		if err != nil {
+			return Result{}, err
+		}
+		speechMs = vadSpeechMs
+		if spans != nil {
+			segments = vadSegments(texts, spans)
+		} else {
+			if overlap > 0 {
+				mergeOverlaps(texts)
+			}
+			segments = chunkSegments(texts, chunks, sampleRate, overlap)
+		}
+	}
+
+	res := Result{Language: lang, Segments: segments, AudioS: audioDurS, SpeechMs: speechMs, DTMF: dtmf, Skipped: skipped}
+	// Punctuate per segment so segment texts and the full text agree.
+	if filtered, raw := rawSegmentText(segments); filtered {
+		res.Filtered, res.RawText = true, raw
+	}
+	for i := range segments {
+		segments[i].Text = e.finishText(segments[i].Text, doPunct, opts.ITN, textLang)
+	}
+	res.Text = joinSegmentText(segments)
+	if opts.Emotions {
+		e.addEmotions(&res, samples)
+	}
+	shiftSegments(res.Segments, trimmedS)
+	if emit != nil && !emitted {
+		for _, seg := range res.Segments {
+			opts.OnSegment(seg)
+		}
+	}
+	return res, nil
+}
+
+// finishText punctuates recognized text when punctuate is set and writes
+// it in written form (in lang) with itn.
+func (e *Engine) finishText(text string, punctuate, itn bool, lang string) string {
+	if punctuate {
+		text = e.addPunctuation(text)
+	}
+	if itn {
+		text = normalizeText(text, lang)
+	}
+	return text
+}
+
+// vadMinDuration returns the audio length from which VAD runs by default
+// for opts.
+func (e *Engine) vadMinDuration(opts Options) float64 {
+	if opts.VADMinDurationS > 0 {
+		return opts.VADMinDurationS
+	}
+	return e.cfg.VADMinDurationS
+}
+
+// buildAudioChunks decides whether to use VAD and returns audio chunks with speech duration.
+// Audio shorter than the VAD minimum duration of opts skips VAD unless
+// Options.VAD forces it.
+// With VAD, it also returns the speech spans each chunk was assembled from;
+// without VAD, spans is nil and the whole input is a single chunk.
+func (e *Engine) buildAudioChunks(samples []float32, audioDurS float64, opts Options) ([][]float32, [][]Span, float64) {
+	useVAD := e.vadDetector != nil && audioDurS >= e.vadMinDuration(opts)
+	if opts.VAD != nil {
+		useVAD = *opts.VAD && e.vadDetector != nil
+	}
+
+	if !useVAD {
+		return [][]float32{samples}, nil, 0
+	}
+
+	chunks, spans := e.applyVADChunked(samples)
+	if len(chunks) == 0 {
+		return nil, nil, 0
+	}
+
+	var speechMs float64
+	for _, c := range chunks {
+		speechMs += float64(len(c)) / 16.0
+	}
+	e.logCall(opts, "VAD: %.0fms speech / %.0fms total (%.0f%%), %d chunk(s)",
+		speechMs, audioDurS*1000, 100*speechMs/(audioDurS*1000), len(chunks))
+
+	return chunks, spans, speechMs
+}
+
+// transcribeChunks recognizes each audio chunk and joins results,
+// suppressing hallucinations (see hallucinationFilter). Words repeated where
+// chunks sharing overlap samples meet are merged. It returns ctx.Err() if
+// ctx is done before all chunks are decoded.
+func (e *Engine) transcribeChunks(ctx context.Context, chunks [][]float32, sampleRate, overlap int, opts Options) (chunkText, error) {
+	texts, err := e.recognizeChunks(ctx, chunks, sampleRate, opts)
+	if err != nil {
+		return chunkText{}, err
+	}
+	if overlap > 0 {
+		mergeOverlaps(texts)
+	}
+	return joinChunkTexts(texts), nil
+}
+
+// recognizeChunks returns the text of each audio chunk, in order. Up to
+// e.parallelism(opts) chunks decode in parallel, reporting to
+// opts.Progress, and to opts.chunkDone in chunk order; chunks in
+// opts.resumed are taken from there. Chunks that look
+// hallucinated keep their raw text but are marked filtered. It returns
+// ctx.Err() if ctx is done before all chunks are decoded.
+func (e *Engine) recognizeChunks(ctx context.Context, chunks [][]float32, sampleRate int, opts Options) ([]chunkText, error) {
+	texts := make([]chunkText, len(chunks))
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	var muProgress sync.Mutex
+	done := 0
+	ready, emitted := make([]bool, len(chunks)), 0
+	for range min(e.parallelism(opts), len(chunks)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(next.Add(1) - 1)
+				if i >= len(chunks) || ctx.Err() != nil {
+					return
+				}
+				if c, ok := opts.resumed[i]; ok {
+					texts[i] = chunkTextOf(c)
+				} else {
+					texts[i] = e.recognizeText(chunks[i], sampleRate, opts)
+				}
+				muProgress.Lock()
+				done++
+				if opts.Progress != nil {
+					opts.Progress(done, len(chunks))
+				}
+				ready[i] = true
+				for opts.chunkDone != nil && emitted < len(chunks) && ready[emitted] {
+					opts.chunkDone(emitted, texts[emitted])
+					emitted++
+				}
+				muProgress.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return texts, nil
+}
+
+// parallelism returns how many chunks of a call decode at once: one per
+// recognizer of the model's pool, or as many as Options.NumThreads allows
+// when each recognizer runs Config.threads(model) ONNX threads, but at
+// least one.
+func (e *Engine) parallelism(opts Options) int {
+	model := opts.model
+	if model == "" {
+		model = e.langModel(opts.Lang)
+	}
+	n := max(e.cfg.poolSize(model), 1)
+	if opts.NumThreads > 0 {
+		n = min(n, max(opts.NumThreads/max(e.cfg.threads(model), 1), 1))
+	}
+	return n
+}
+
+// recognizeText recognizes one chunk and applies the hallucination filter.
+func (e *Engine) recognizeText(chunk []float32, sampleRate int, opts Options) chunkText {
+	raw, tags := e.recognizeChunk(chunk, sampleRate, opts)
+	t := sanitizeUTF8(strings.TrimSpace(raw))
+	if t == "" {
+		return chunkText{chunkTags: tags}
+	}
+	if reason := e.filter.reason(t); reason != "" {
+		e.logCall(opts, "WARNING: suppressing hallucinated chunk: %s", reason)
+		return chunkText{Raw: t, Filtered: true, chunkTags: tags}
+	}
+	return chunkText{Text: t, Raw: t, chunkTags: tags}
+}
+
+// joinTexts joins the non-empty texts with single spaces.
+func joinTexts(texts []string) string {
+	parts := make([]string, 0, len(texts))
+	for _, t := range texts {
+		if t != "" {
+			parts = append(parts, t)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// sanitizeUTF8 ensures text is valid UTF-8 and strips null bytes.
+// Ported from Vaelor's channels.sanitizeUTF8.
+func sanitizeUTF8(text string) string {
+	text = strings.ToValidUTF8(text, "")
+	text = strings.ReplaceAll(text, "\x00", "")
+	return text
+}
+
+// vadSegments pairs chunk texts with the speech spans each chunk was built
+// from. Every chunk yields a segment, even when nothing was recognized, so
+// all detected speech is reported.
+func vadSegments(texts []chunkText, spans [][]Span) []Segment {
+	segments := make([]Segment, 0, len(texts))
+	for i, t := range texts {
+		sp := spans[i]
+		seg := Segment{
+			Start:   sp[0].Start,
+			End:     sp[len(sp)-1].End,
+			Text:    t.Text,
+			Speech:  sp,
+			Emotion: t.Emotion,
+			Event:   t.Event,
+		}
+		if t.Filtered {
+			seg.Filtered, seg.RawText = true, t.Raw
+		}
+		segments = append(segments, seg)
+	}
+	return segments
+}
+
+// chunkSegments pairs chunk texts with the time each chunk covers when the
+// chunks split the audio without VAD: one segment for the whole input, or
+// one per piece of it decoded separately. Pieces sharing overlap samples
+// meet in the middle of the overlap.
+func chunkSegments(texts []chunkText, chunks [][]float32, sampleRate, overlap int) []Segment {
+	segments := make([]Segment, 0, len(texts))
+	start := 0
+	for i, t := range texts {
+		n := len(chunks[i])
+		from, to := start, start+n
+		if i > 0 {
+			from += overlap / 2
+		}
+		if i < len(texts)-1 {
+			to -= overlap - overlap/2
+		}
+		seg := Segment{
+			Start:   float64(from) / float64(sampleRate),
+			End:     float64(to) / float64(sampleRate),
+			Text:    t.Text,
+			Emotion: t.Emotion,
+			Event:   t.Event,
+		}
+		if t.Filtered {
+			seg.Filtered, seg.RawText = true, t.Raw
+		}
+		segments = append(segments, seg)
+		start += n - overlap
+	}
+	return segments
+}
+
+// applyVADChunked feeds samples into VAD and returns speech segments
+// grouped into chunks of at most 25 seconds each, together with the time
+// span of every speech segment in each chunk.
+func (e *Engine) applyVADChunked(samples []float32) ([][]float32, [][]Span) {
+	const maxChunkSamples = 25 * 16000 // 25s x 16kHz
+
+	e.muVAD.Lock()
+	defer e.muVAD.Unlock()
+	vad := e.vadDetector
+	windowSize := e.vadWindow
+
+	for i := 0; i+windowSize <= len(samples); i += windowSize {
+		vad.AcceptWaveform(samples[i : i+windowSize])
+	}
+	if rem := len(samples) % windowSize; rem != 0 {
+		pad := make([]float32, windowSize)
+		copy(pad, samples[len(samples)-rem:])
+		vad.AcceptWaveform(pad)
+	}
+	vad.Flush()
+
+	var chunks [][]float32
+	var spans [][]Span
+	var current []float32
+	var currentSpans []Span
+	for !vad.IsEmpty() {
+		seg := vad.Front()
+		if len(current)+len(seg.Samples) > maxChunkSamples && len(current) > 0 {
+			chunks = append(chunks, current)
+			spans = append(spans, currentSpans)
+			current, currentSpans = nil, nil
+		}
+		current = append(current, seg.Samples...)
+		currentSpans = append(currentSpans, sampleSpan(seg.Start, len(seg.Samples), len(samples)))
+		vad.Pop()
+	}
+	if len(current) > 0 {
+		chunks = append(chunks, current)
+		spans = append(spans, currentSpans)
+	}
+	vad.Reset()
+	return chunks, spans
+}
+
+// sampleSpan converts a run of n 16 kHz samples starting at sample start
+// into seconds, clipped to total samples (VAD pads the final window).
+func sampleSpan(start, n, total int) Span {
+	end := min(start+n, total)
+	return Span{Start: float64(start) / 16000, End: float64(end) / 16000}
+}
+
+// recognizeChunk runs inference on a single audio chunk using the requested
+// language model and returns its text and, from SenseVoice, tags. Hotwords
+// apply to the RU transducer, which decodes as it was built (see
+// CheckDecoding); Moonshine always decodes greedily.
+func (e *Engine) recognizeChunk(samples []float32, sampleRate int, opts Options) (string, chunkTags) {
+	model := opts.model
+	if model == "" {
+		model = e.langModel(opts.Lang)
+	}
+	p, r := e.acquire(model, opts.Priority == PriorityLow)
+	if p == nil {
+		return "", chunkTags{}
+	}
+	defer p.release(r)
+
+	var s *sherpa.OfflineStream
+	if e.Backend(modelArch(model)) == BackendZipformer && opts.Hotwords != "" {
+		s = sherpa.NewOfflineStreamWithHotwords(r, opts.Hotwords)
+	} else {
+		s = sherpa.NewOfflineStream(r)
+	}
+	s.AcceptWaveform(sampleRate, samples)
+	r.Decode(s)
+	res := s.GetResult()
+	sherpa.DeleteOfflineStream(s)
+	return res.Text, chunkTags{Emotion: emotionName(res.Emotion), Event: eventName(res.Event)}
+}
+
+// CheckDecoding returns an error wrapping ErrUnavailable if opts asks the
+// Zipformer transducer of its language for decoding other than that of
+// Config.RUDecodingMethod and Config.RUBeamSize, or for hotwords from a
+// greedy transducer, which would ignore them. sherpa-onnx fixes a
+// transducer's decoder when the recognizer is built (its SetConfig only
+// takes effect for Whisper and Canary), so Options.DecodingMethod and
+// Options.BeamSize can only confirm the configured decoding. Other
+// backends ignore both.
+func (e *Engine) CheckDecoding(opts Options) error {
+	model := opts.model
+	if model == "" {
+		model = e.langModel(opts.Lang)
+	}
+	if e.Backend(modelArch(model)) != BackendZipformer {
+		return nil
+	}
+	c := e.cfg.withDefaults()
+	method, paths := c.RUDecodingMethod, c.RUBeamSize
+	switch {
+	case opts.DecodingMethod != "" && opts.DecodingMethod != method:
+		return errorf(ErrUnavailable, "decoding method %s is not loaded: the transducer decodes with %s", opts.DecodingMethod, method)
+	case opts.BeamSize > 0 && method != ModifiedBeamSearch:
+		return errorf(ErrUnavailable, "beam size needs modified_beam_search: the transducer decodes with %s", method)
+	case opts.BeamSize > 0 && opts.BeamSize != paths:
+		return errorf(ErrUnavailable, "beam size %d is not loaded: the transducer keeps %d active paths", opts.BeamSize, paths)
+	case opts.Hotwords != "" && method != ModifiedBeamSearch:
+		return errorf(ErrUnavailable, "hotwords need modified_beam_search: the transducer decodes with %s", method)
+	}
+	return nil
+}
+
+// compressionRatio returns the zlib compression ratio of text.
+// High values (>2.4) indicate repetitive/hallucinated output.
+func compressionRatio(text string) float64 {
+	if len(text) < 10 {
+		return 0
+	}
+	var b bytes.Buffer
+	w := zlib.NewWriter(&b)
+	w.Write([]byte(text)) //nolint:errcheck
+	_ = w.Close()
+	return float64(len(text)) / float64(b.Len())
+}
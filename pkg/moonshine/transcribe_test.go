@@ -0,0 +1,396 @@
+package moonshine
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// --- compressionRatio ---
+
+func TestCompressionRatio_Empty(t *testing.T) {
+	if got := compressionRatio(""); got != 0 {
+		t.Errorf("compressionRatio empty = %f, want 0", got)
+	}
+}
+
+func TestCompressionRatio_BelowThreshold(t *testing.T) {
+	// 9 chars — below the 10-char minimum.
+	if got := compressionRatio("123456789"); got != 0 {
+		t.Errorf("compressionRatio 9 chars = %f, want 0", got)
+	}
+}
+
+func TestCompressionRatio_ExactlyTenChars(t *testing.T) {
+	ratio := compressionRatio("1234567890")
+	if ratio <= 0 {
+		t.Errorf("compressionRatio 10 chars = %f, want > 0", ratio)
+	}
+}
+
+func TestCompressionRatio_Normal(t *testing.T) {
+	text := "The quick brown fox jumps over the lazy dog near the river"
+	ratio := compressionRatio(text)
+	if ratio <= 0 || ratio > 2.4 {
+		t.Errorf("compressionRatio normal = %f, want (0, 2.4]", ratio)
+	}
+}
+
+func TestCompressionRatio_Repetitive(t *testing.T) {
+	text := strings.Repeat("aaaa ", 40)
+	ratio := compressionRatio(text)
+	if ratio <= 2.4 {
+		t.Errorf("compressionRatio repetitive = %f, want > 2.4", ratio)
+	}
+}
+
+func TestCompressionRatio_AllSameChar(t *testing.T) {
+	text := strings.Repeat("z", 100)
+	ratio := compressionRatio(text)
+	if ratio <= 2.4 {
+		t.Errorf("compressionRatio all-same = %f, want > 2.4", ratio)
+	}
+}
+
+func TestCompressionRatio_CyrillicNormal(t *testing.T) {
+	text := "Быстрая коричневая лиса перепрыгивает через ленивую собаку"
+	ratio := compressionRatio(text)
+	if ratio <= 0 {
+		t.Errorf("compressionRatio cyrillic = %f, want > 0", ratio)
+	}
+}
+
+func TestCompressionRatio_Deterministic(t *testing.T) {
+	text := "deterministic test with enough characters to pass threshold"
+	r1 := compressionRatio(text)
+	r2 := compressionRatio(text)
+	if r1 != r2 {
+		t.Errorf("compressionRatio not deterministic: %f vs %f", r1, r2)
+	}
+}
+
+// --- CheckDecoding ---
+
+func TestCheckDecoding(t *testing.T) {
+	e := &Engine{cfg: Config{RUDecodingMethod: ModifiedBeamSearch, RUBeamSize: 4}}
+	tests := []struct {
+		name string
+		opts Options
+		ok   bool
+	}{
+		{"defaults", Options{Lang: "ru"}, true},
+		{"loaded method", Options{Lang: "ru", DecodingMethod: ModifiedBeamSearch}, true},
+		{"loaded beam", Options{Lang: "ru", BeamSize: 4}, true},
+		{"other method", Options{Lang: "ru", DecodingMethod: GreedySearch}, false},
+		{"other beam", Options{Lang: "ru", BeamSize: 8}, false},
+		{"telephony model", Options{Lang: "ru", BeamSize: 8, model: "ru" + telephonySuffix}, false},
+		{"moonshine ignores", Options{Lang: "en", DecodingMethod: GreedySearch, BeamSize: 8}, true},
+		{"hotwords", Options{Lang: "ru", Hotwords: "Сбер :2"}, true},
+	}
+	for _, tt := range tests {
+		err := e.CheckDecoding(tt.opts)
+		if (err == nil) != tt.ok || (err != nil && !errors.Is(err, ErrUnavailable)) {
+			t.Errorf("%s: CheckDecoding = %v, want ok=%v", tt.name, err, tt.ok)
+		}
+	}
+
+	greedy := &Engine{cfg: Config{RUDecodingMethod: GreedySearch, RUBeamSize: 4}}
+	if err := greedy.CheckDecoding(Options{Lang: "ru", BeamSize: 4}); err == nil {
+		t.Error("beam_size accepted by a greedy transducer")
+	}
+	if err := greedy.CheckDecoding(Options{Lang: "ru", Hotwords: "Сбер :2"}); err == nil {
+		t.Error("hotwords accepted by a greedy transducer, which ignores them")
+	}
+	if err := (&Engine{}).CheckDecoding(Options{Lang: "ru", Hotwords: "Сбер :2"}); err == nil {
+		t.Error("hotwords accepted by the default decoder, greedy_search")
+	}
+}
+
+// The transducer decodes with the configured method for every call: its
+// config is fixed when the recognizer is built.
+func TestZipformerDecoder(t *testing.T) {
+	for _, method := range []string{GreedySearch, ModifiedBeamSearch} {
+		e := &Engine{cfg: Config{RUDecodingMethod: method, RUBeamSize: 6}}
+		_, c, err := e.newZipformerRecognizer("ru", t.TempDir())
+		if !errors.Is(err, fs.ErrNotExist) {
+			t.Fatalf("newZipformerRecognizer(empty dir) = %v, want fs.ErrNotExist", err)
+		}
+		if c.DecodingMethod != method || c.MaxActivePaths != 6 {
+			t.Errorf("%s: recognizer built with %s/%d", method, c.DecodingMethod, c.MaxActivePaths)
+		}
+	}
+}
+
+// --- vadMinDuration ---
+
+func TestVADMinDuration(t *testing.T) {
+	e := &Engine{cfg: Config{VADMinDurationS: 10}}
+	for _, tt := range []struct{ opt, want float64 }{{0, 10}, {30, 30}, {2, 2}} {
+		if got := e.vadMinDuration(Options{VADMinDurationS: tt.opt}); got != tt.want {
+			t.Errorf("vadMinDuration(%g) = %g, want %g", tt.opt, got, tt.want)
+		}
+	}
+}
+
+// --- Engine.TranscribeSamples / transcribeChunks ---
+
+func TestTranscribeSamples_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := new(Engine).TranscribeSamples(ctx, make([]float32, 16000), 16000, Options{Lang: "en"}); err != context.Canceled {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
+
+func TestTranscribeSamples_Errors(t *testing.T) {
+	e := &Engine{cfg: Config{MaxAudioDurationS: 1}}
+	tests := []struct {
+		name    string
+		samples int
+		rate    int
+		opts    Options
+		want    error
+		message string
+	}{
+		{"empty", 0, 16000, Options{}, ErrInvalidAudio, "no audio samples"},
+		{"bad rate", 100, 1000, Options{}, ErrInvalidAudio, "unsupported sample rate"},
+		{"too long", 32000, 16000, Options{}, ErrInvalidAudio, "audio too long"},
+		{"too long for call", 16000, 16000, Options{MaxAudioDurationS: 0.5}, ErrInvalidAudio, "max 0s"},
+		{"call limit above config", 32000, 16000, Options{MaxAudioDurationS: 10}, ErrInvalidAudio, "audio too long"},
+		{"no EN model", 16000, 16000, Options{Lang: "en"}, ErrUnavailable, "EN model not loaded"},
+		{"no RU model", 16000, 16000, Options{Lang: "ru"}, ErrUnavailable, "RU model not loaded"},
+		{"no denoising model", 16000, 16000, Options{Denoise: true}, ErrUnavailable, "denoising model not loaded"},
+	}
+	for _, tt := range tests {
+		_, err := e.TranscribeSamples(context.Background(), make([]float32, tt.samples), tt.rate, tt.opts)
+		if !errors.Is(err, tt.want) || !strings.Contains(err.Error(), tt.message) {
+			t.Errorf("%s: err = %v, want %v containing %q", tt.name, err, tt.want, tt.message)
+		}
+	}
+}
+
+func TestTranscribeChunks_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// The recognizer is never reached: the context is checked before each chunk.
+	if _, err := new(Engine).transcribeChunks(ctx, [][]float32{make([]float32, 16000)}, 16000, 0, Options{}); err != context.Canceled {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
+
+func TestRecognizeChunks_Progress(t *testing.T) {
+	e := &Engine{cfg: Config{PoolSize: 3}}
+	var got []int
+	opts := Options{Progress: func(done, total int) {
+		if total != 5 {
+			t.Errorf("total = %d, want 5", total)
+		}
+		got = append(got, done)
+	}}
+	if _, err := e.recognizeChunks(context.Background(), make([][]float32, 5), 16000, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []int{1, 2, 3, 4, 5}; !slices.Equal(got, want) {
+		t.Errorf("progress = %v, want %v", got, want)
+	}
+}
+
+func TestRecognizeChunks_ChunkDoneInOrder(t *testing.T) {
+	e := &Engine{cfg: Config{PoolSize: 4}}
+	var got []int
+	opts := Options{chunkDone: func(i int, _ chunkText) { got = append(got, i) }}
+	if _, err := e.recognizeChunks(context.Background(), make([][]float32, 20), 16000, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i, n := range got {
+		if n != i {
+			t.Fatalf("chunkDone order = %v, want 0..19", got)
+		}
+	}
+	if len(got) != 20 {
+		t.Errorf("chunkDone called %d times, want 20", len(got))
+	}
+}
+
+func TestRecognizeChunks_Resumed(t *testing.T) {
+	e := &Engine{cfg: Config{PoolSize: 2}}
+	opts := Options{resumed: map[int]ChunkResult{1: {Text: "kept", Raw: "kept"}, 3: {Raw: "la la", Filtered: true, Emotion: "happy"}}}
+	texts, err := e.recognizeChunks(context.Background(), make([][]float32, 4), 16000, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if texts[1].Text != "kept" || texts[0].Text != "" || texts[2].Text != "" {
+		t.Errorf("texts = %+v, want chunk 1 from the checkpoint", texts)
+	}
+	if want := (ChunkResult{Raw: "la la", Filtered: true, Emotion: "happy"}); texts[3].result() != want {
+		t.Errorf("chunk 3 = %+v, want %+v", texts[3].result(), want)
+	}
+}
+
+// --- vadSegments / joinTexts / sampleSpan ---
+
+func TestVADSegments(t *testing.T) {
+	texts := []chunkText{
+		{Text: "hello there", Raw: "hello there"},
+		{},
+		{Raw: "thank you for watching", Filtered: true, chunkTags: chunkTags{Emotion: "sad", Event: "laughter"}},
+	}
+	spans := [][]Span{
+		{{Start: 0.5, End: 1.2}, {Start: 1.8, End: 3.0}},
+		{{Start: 30.1, End: 31.0}},
+		{{Start: 40, End: 41}},
+	}
+	segs := vadSegments(texts, spans)
+	if len(segs) != 3 {
+		t.Fatalf("got %d segments, want 3", len(segs))
+	}
+	if segs[0].Start != 0.5 || segs[0].End != 3.0 || segs[0].Text != "hello there" || len(segs[0].Speech) != 2 {
+		t.Errorf("segment 0 = %+v", segs[0])
+	}
+	if segs[1].Start != 30.1 || segs[1].End != 31.0 || segs[1].Text != "" {
+		t.Errorf("segment 1 = %+v, want empty text kept", segs[1])
+	}
+	if !segs[2].Filtered || segs[2].Text != "" || segs[2].RawText != "thank you for watching" {
+		t.Errorf("segment 2 = %+v, want filtered with raw text", segs[2])
+	}
+	if segs[2].Emotion != "sad" || segs[2].Event != "laughter" || segs[0].Emotion != "" {
+		t.Errorf("segment tags = %+v, %+v", segs[0], segs[2])
+	}
+	if got := joinSegmentText(segs); got != "hello there" {
+		t.Errorf("joinSegmentText = %q", got)
+	}
+	if filtered, raw := rawSegmentText(segs); !filtered || raw != "hello there thank you for watching" {
+		t.Errorf("rawSegmentText = %v, %q", filtered, raw)
+	}
+}
+
+func TestChunkSegments(t *testing.T) {
+	texts := []chunkText{
+		{Text: "first part", Raw: "first part"},
+		{Raw: "thank you for watching", Filtered: true},
+	}
+	chunks := [][]float32{make([]float32, 16000*30), make([]float32, 8000)}
+	segs := chunkSegments(texts, chunks, 16000, 0)
+	if len(segs) != 2 {
+		t.Fatalf("got %d segments, want 2", len(segs))
+	}
+	if segs[0].Start != 0 || segs[0].End != 30 || segs[0].Text != "first part" {
+		t.Errorf("segment 0 = %+v", segs[0])
+	}
+	if segs[1].Start != 30 || segs[1].End != 30.5 || !segs[1].Filtered || segs[1].RawText != "thank you for watching" {
+		t.Errorf("segment 1 = %+v, want filtered 30-30.5s", segs[1])
+	}
+	if got := chunkSegments(nil, nil, 16000, 0); len(got) != 0 {
+		t.Errorf("no chunks = %+v, want none", got)
+	}
+
+	// Overlapping pieces meet in the middle of their 1s overlap.
+	chunks = [][]float32{make([]float32, 16000*25), make([]float32, 16000*10)}
+	segs = chunkSegments(texts, chunks, 16000, 16000)
+	if segs[0].End != 24.5 || segs[1].Start != 24.5 || segs[1].End != 34 {
+		t.Errorf("overlapping segments = %g-%g, %g-%g, want 0-24.5, 24.5-34", segs[0].Start, segs[0].End, segs[1].Start, segs[1].End)
+	}
+}
+
+func TestJoinTexts(t *testing.T) {
+	tests := []struct {
+		in   []string
+		want string
+	}{
+		{nil, ""},
+		{[]string{"a"}, "a"},
+		{[]string{"a", "", "b"}, "a b"},
+		{[]string{"", ""}, ""},
+	}
+	for _, tt := range tests {
+		if got := joinTexts(tt.in); got != tt.want {
+			t.Errorf("joinTexts(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSampleSpan(t *testing.T) {
+	if got := sampleSpan(8000, 16000, 100000); got != (Span{Start: 0.5, End: 1.5}) {
+		t.Errorf("sampleSpan = %+v", got)
+	}
+	// The padded final VAD window is clipped to the input length.
+	if got := sampleSpan(16000, 512, 16100); got != (Span{Start: 1, End: 16100.0 / 16000}) {
+		t.Errorf("sampleSpan clipped = %+v", got)
+	}
+}
+
+// --- sanitizeUTF8 ---
+
+func TestSanitizeUTF8_Valid(t *testing.T) {
+	if got := sanitizeUTF8("hello world"); got != "hello world" {
+		t.Errorf("got %q, want %q", got, "hello world")
+	}
+}
+
+func TestSanitizeUTF8_Empty(t *testing.T) {
+	if got := sanitizeUTF8(""); got != "" {
+		t.Errorf("got %q, want empty", got)
+	}
+}
+
+func TestSanitizeUTF8_NullBytes(t *testing.T) {
+	if got := sanitizeUTF8("hello\x00world"); got != "helloworld" {
+		t.Errorf("got %q, want %q", got, "helloworld")
+	}
+}
+
+func TestSanitizeUTF8_MultipleNullBytes(t *testing.T) {
+	if got := sanitizeUTF8("\x00\x00\x00"); got != "" {
+		t.Errorf("got %q, want empty", got)
+	}
+}
+
+func TestSanitizeUTF8_OnlyInvalidBytes(t *testing.T) {
+	if got := sanitizeUTF8("\xff\xfe\xfd"); got != "" {
+		t.Errorf("got %q, want empty", got)
+	}
+}
+
+func TestSanitizeUTF8_InvalidBytes(t *testing.T) {
+	if got := sanitizeUTF8("hello\xff\xfeworld"); got != "helloworld" {
+		t.Errorf("got %q, want %q", got, "helloworld")
+	}
+}
+
+func TestSanitizeUTF8_Cyrillic(t *testing.T) {
+	if got := sanitizeUTF8("Привет мир"); got != "Привет мир" {
+		t.Errorf("got %q, want %q", got, "Привет мир")
+	}
+}
+
+func TestSanitizeUTF8_Emoji(t *testing.T) {
+	if got := sanitizeUTF8("hello 🌍 world"); got != "hello 🌍 world" {
+		t.Errorf("got %q, want %q", got, "hello 🌍 world")
+	}
+}
+
+func TestSanitizeUTF8_MixedInvalidAndNull(t *testing.T) {
+	got := sanitizeUTF8("ok\xff\x00good\xfe")
+	if got != "okgood" {
+		t.Errorf("got %q, want %q", got, "okgood")
+	}
+}
+
+func TestSanitizeUTF8_SurrogatePairs(t *testing.T) {
+	// UTF-8 encoded surrogates (U+D800) are invalid and should be stripped.
+	got := sanitizeUTF8("a\xed\xa0\x80b")
+	if got != "ab" {
+		t.Errorf("got %q, want %q", got, "ab")
+	}
+}
+
+func TestSanitizeUTF8_TruncatedMultibyte(t *testing.T) {
+	// First byte of a 2-byte sequence without continuation.
+	got := sanitizeUTF8("abc\xc3")
+	if got != "abc" {
+		t.Errorf("got %q, want %q", got, "abc")
+	}
+}
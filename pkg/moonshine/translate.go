@@ -0,0 +1,51 @@
+package moonshine
+
+import (
+	"errors"
+	"io/fs"
+	"time"
+)
+
+// Tasks for Options.Task.
+const (
+	TaskTranscribe = "transcribe"
+	TaskTranslate  = "translate" // English text from speech in any language Whisper knows
+)
+
+// translateModel is the recognizer pool of the Whisper model serving
+// TaskTranslate.
+const translateModel = "translate"
+
+// ValidTask reports whether t is a supported task; "" means TaskTranscribe.
+func ValidTask(t string) bool { return t == "" || t == TaskTranscribe || t == TaskTranslate }
+
+// loadTranslateModel loads the recognizers of Config.TranslateModelDir.
+func (e *Engine) loadTranslateModel() {
+	t := time.Now()
+	p, err := e.loadPool(translateModel, e.cfg.TranslateModelDir)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		e.logf("Translation model not found at %s, translation disabled", e.cfg.TranslateModelDir)
+	case err != nil:
+		e.logf("WARNING: %v", err)
+	default:
+		e.setPool(translateModel, p)
+		e.logf("Translation model loaded in %.2fs (%s, %d instance(s))", time.Since(t).Seconds(),
+			precisionOf(p.cfg.ModelConfig.Whisper.Encoder), len(p.all))
+	}
+}
+
+// taskModel picks the recognizer model of a call: the translation model for
+// TaskTranslate, otherwise the one selectModel picks.
+func (e *Engine) taskModel(lang string, sampleRate int, opts Options) (string, error) {
+	if opts.Task != TaskTranslate {
+		return e.selectModel(lang, sampleRate, opts.Telephony, opts.Quality)
+	}
+	if !e.HasTranslation() {
+		return "", errorf(ErrUnavailable, "translation model not loaded")
+	}
+	return translateModel, nil
+}
+
+// HasTranslation reports whether the translation model is loaded.
+func (e *Engine) HasTranslation() bool { return e.hasModel(translateModel) }
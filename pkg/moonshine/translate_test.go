@@ -0,0 +1,42 @@
+package moonshine
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// --- ValidTask ---
+
+func TestValidTask(t *testing.T) {
+	tests := map[string]bool{"": true, TaskTranscribe: true, TaskTranslate: true, "summarize": false, "Translate": false}
+	for task, want := range tests {
+		if got := ValidTask(task); got != want {
+			t.Errorf("ValidTask(%q) = %v, want %v", task, got, want)
+		}
+	}
+}
+
+// --- Engine.TranscribeSamples ---
+
+func TestTranscribeSamples_TranslateUnavailable(t *testing.T) {
+	e := &Engine{pools: map[string]*recognizerPool{"en": {}}}
+	for _, lang := range []string{"ru", LangAuto} {
+		_, err := e.TranscribeSamples(context.Background(), make([]float32, 16000), 16000, Options{Lang: lang, Task: TaskTranslate})
+		if !errors.Is(err, ErrUnavailable) {
+			t.Errorf("lang %s: err = %v, want ErrUnavailable", lang, err)
+		}
+	}
+}
+
+// --- Engine.taskModel ---
+
+func TestTaskModel(t *testing.T) {
+	e := &Engine{pools: map[string]*recognizerPool{"en": {}, translateModel: {}}}
+	if model, err := e.taskModel("ru", 16000, Options{Task: TaskTranslate}); err != nil || model != translateModel {
+		t.Errorf("translate = %q, %v; want %q", model, err, translateModel)
+	}
+	if model, err := e.taskModel("en", 16000, Options{}); err != nil || model != "en" {
+		t.Errorf("transcribe = %q, %v; want en", model, err)
+	}
+}
@@ -0,0 +1,51 @@
+package moonshine
+
+import "math"
+
+// Silence trimming strips dead air around a clip by amplitude alone, much
+// cheaper than VAD: frames whose peak stays below trimThresholdDB are
+// silence, and trimming stops trimMarginSamples short of the first and
+// last loud frame so soft onsets and word endings survive.
+const (
+	trimFrameSamples  = 160   // 10 ms at 16 kHz
+	trimThresholdDB   = -40.0 // frame peak below this is silence, dBFS
+	trimMarginSamples = 3200  // 200 ms kept around the sound
+)
+
+// trimSilence returns the part of 16 kHz samples between the first and the
+// last frame that is not silence, with trimMarginSamples of margin, and
+// its offset in samples. All-silent audio yields no samples.
+func trimSilence(samples []float32) ([]float32, int) {
+	threshold := float32(math.Pow(10, trimThresholdDB/20))
+	first, last := -1, -1
+	for from := 0; from < len(samples); from += trimFrameSamples {
+		to := min(from+trimFrameSamples, len(samples))
+		for _, s := range samples[from:to] {
+			if s > threshold || -s > threshold {
+				if first < 0 {
+					first = from
+				}
+				last = to
+				break
+			}
+		}
+	}
+	if first < 0 {
+		return nil, 0
+	}
+	from, to := max(first-trimMarginSamples, 0), min(last+trimMarginSamples, len(samples))
+	return samples[from:to], from
+}
+
+// shiftSegments moves the times of segments later by s seconds.
+func shiftSegments(segments []Segment, s float64) {
+	for i := range segments {
+		seg := &segments[i]
+		seg.Start += s
+		seg.End += s
+		for j := range seg.Speech {
+			seg.Speech[j].Start += s
+			seg.Speech[j].End += s
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package moonshine
+
+import "testing"
+
+// --- trimSilence ---
+
+func TestTrimSilence(t *testing.T) {
+	tone := func(n int) []float32 {
+		s := make([]float32, n)
+		for i := range s {
+			s[i] = 0.3
+		}
+		return s
+	}
+	quiet := make([]float32, 16000) // 1s of silence
+	tests := []struct {
+		name       string
+		samples    []float32
+		wantLen    int
+		wantOffset int
+	}{
+		{"silence around", concat(quiet, tone(8000), quiet), 8000 + 2*trimMarginSamples, 16000 - trimMarginSamples},
+		{"no silence", tone(8000), 8000, 0},
+		{"short lead-in", concat(make([]float32, 1600), tone(8000)), 9600, 0},
+		{"all silent", quiet, 0, 0},
+		{"empty", nil, 0, 0},
+	}
+	for _, tt := range tests {
+		got, offset := trimSilence(tt.samples)
+		if len(got) != tt.wantLen || offset != tt.wantOffset {
+			t.Errorf("%s: trimSilence = %d samples at %d, want %d at %d", tt.name, len(got), offset, tt.wantLen, tt.wantOffset)
+		}
+	}
+}
+
+// --- shiftSegments ---
+
+func TestShiftSegments(t *testing.T) {
+	segs := []Segment{{Start: 0, End: 2, Speech: []Span{{Start: 0.5, End: 1.5}}}}
+	shiftSegments(segs, 1.5)
+	if segs[0].Start != 1.5 || segs[0].End != 3.5 || segs[0].Speech[0] != (Span{Start: 2, End: 3}) {
+		t.Errorf("shifted = %+v", segs[0])
+	}
+}
+
+func concat(parts ...[]float32) []float32 {
+	var out []float32
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
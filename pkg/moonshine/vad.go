@@ -0,0 +1,66 @@
+package moonshine
+
+import sherpa "github.com/k2-fsa/sherpa-onnx-go/sherpa_onnx"
+
+// VAD backends of Config.VADBackend.
+const (
+	VADSilero = "silero" // Silero VAD, the default
+	VADTen    = "ten"    // TEN VAD; steadier on far-field and noisy audio
+)
+
+// ValidVADBackend reports whether b is a supported VAD backend; "" means
+// VADSilero.
+func ValidVADBackend(b string) bool { return b == "" || b == VADSilero || b == VADTen }
+
+// vadBackend returns the VAD backend of the configuration.
+func (c Config) vadBackend() string {
+	if c.VADBackend == "" {
+		return VADSilero
+	}
+	return c.VADBackend
+}
+
+// vadModelConfig returns the detector config for model with backend, and
+// the window in samples it consumes audio in.
+func (e *Engine) vadModelConfig(backend, model string) (*sherpa.VadModelConfig, int) {
+	c := &sherpa.VadModelConfig{
+		SampleRate: 16000,
+		NumThreads: 1,
+		Provider:   ProviderCPU, // windows of a few ms are too small to gain from an accelerator
+	}
+	if backend == VADTen {
+		c.TenVad = sherpa.TenVadModelConfig{
+			Model:              model,
+			Threshold:          float32(e.cfg.VADThreshold),
+			MinSilenceDuration: float32(e.cfg.VADMinSilenceS),
+			MinSpeechDuration:  float32(e.cfg.VADMinSpeechS),
+			WindowSize:         256,
+		}
+		return c, c.TenVad.WindowSize
+	}
+	c.SileroVad = sherpa.SileroVadModelConfig{
+		Model:              model,
+		Threshold:          float32(e.cfg.VADThreshold),
+		MinSilenceDuration: float32(e.cfg.VADMinSilenceS),
+		MinSpeechDuration:  float32(e.cfg.VADMinSpeechS),
+		WindowSize:         512,
+	}
+	return c, c.SileroVad.WindowSize
+}
+
+// VADBackend returns the backend of the loaded VAD model, or "" if none is
+// loaded.
+func (e *Engine) VADBackend() string {
+	if e.vadDetector == nil {
+		return ""
+	}
+	return e.cfg.vadBackend()
+}
+
+// vadName returns the display name of a VAD backend for logs.
+func vadName(backend string) string {
+	if backend == VADTen {
+		return "TEN"
+	}
+	return "Silero"
+}
@@ -0,0 +1,38 @@
+package moonshine
+
+import "testing"
+
+// --- ValidVADBackend ---
+
+func TestValidVADBackend(t *testing.T) {
+	for b, want := range map[string]bool{"": true, "silero": true, "ten": true, "webrtc": false, "Silero": false} {
+		if got := ValidVADBackend(b); got != want {
+			t.Errorf("ValidVADBackend(%q) = %v, want %v", b, got, want)
+		}
+	}
+}
+
+// --- Engine.vadModelConfig ---
+
+func TestVADModelConfig(t *testing.T) {
+	e := &Engine{cfg: Config{VADThreshold: 0.4}}
+	c, window := e.vadModelConfig(VADTen, "/vad/ten-vad.onnx")
+	if c.TenVad.Model != "/vad/ten-vad.onnx" || c.SileroVad.Model != "" || window != 256 || c.TenVad.Threshold != 0.4 {
+		t.Errorf("ten: %+v, window %d", c, window)
+	}
+	c, window = e.vadModelConfig(VADSilero, "/vad/silero_vad.onnx")
+	if c.SileroVad.Model != "/vad/silero_vad.onnx" || c.TenVad.Model != "" || window != 512 {
+		t.Errorf("silero: %+v, window %d", c, window)
+	}
+}
+
+// --- Engine.VADBackend ---
+
+func TestVADBackend(t *testing.T) {
+	if got := new(Engine).VADBackend(); got != "" {
+		t.Errorf("no VAD loaded: VADBackend = %q, want empty", got)
+	}
+	if got := (Config{}).vadBackend(); got != VADSilero {
+		t.Errorf("default vadBackend = %q, want silero", got)
+	}
+}
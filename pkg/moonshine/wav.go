@@ -0,0 +1,307 @@
+package moonshine
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// WAV format tags from the fmt chunk.
+const (
+	wavFormatPCM        = 0x0001
+	wavFormatIEEEFloat  = 0x0003
+	wavFormatALaw       = 0x0006
+	wavFormatMuLaw      = 0x0007
+	wavFormatExtensible = 0xFFFE
+)
+
+// wavFormat describes the sample layout declared by a WAV fmt chunk.
+type wavFormat struct {
+	AudioFormat   int
+	Channels      int
+	SampleRate    int
+	BitsPerSample int
+}
+
+// loadWav reads a WAV file and returns mono samples as float32 in [-1, +1] range.
+func loadWav(path string) ([]float32, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close() //nolint:errcheck
+	return decodeWav(f)
+}
+
+// WriteWav writes mono samples in [-1, +1] to w as a 16-bit PCM WAV file.
+func WriteWav(w io.Writer, samples []float32, sampleRate int) error {
+	dataLen := uint32(2 * len(samples))
+	header := struct {
+		RIFF          [4]byte
+		Size          uint32
+		WAVEfmt       [8]byte
+		FmtSize       uint32
+		AudioFormat   uint16
+		Channels      uint16
+		SampleRate    uint32
+		ByteRate      uint32
+		BlockAlign    uint16
+		BitsPerSample uint16
+		Data          [4]byte
+		DataSize      uint32
+	}{
+		[4]byte([]byte("RIFF")), 36 + dataLen, [8]byte([]byte("WAVEfmt ")), 16,
+		wavFormatPCM, 1, uint32(sampleRate), uint32(2 * sampleRate), 2, 16,
+		[4]byte([]byte("data")), dataLen,
+	}
+	pcm := make([]int16, len(samples))
+	for i, s := range samples {
+		pcm[i] = int16(math.Round(float64(max(-1, min(1, s))) * math.MaxInt16))
+	}
+	if err := binary.Write(w, binary.LittleEndian, header); err != nil {
+		return err
+	}
+	return binary.Write(w, binary.LittleEndian, pcm)
+}
+
+// decodeWav decodes a PCM, IEEE float, or G.711 WAV stream to mono samples.
+// Other encodings (ADPCM, GSM...) fail with an error wrapping errNotNative.
+func decodeWav(r io.Reader) ([]float32, int, error) {
+	format, data, err := readWav(r)
+	if err != nil {
+		return nil, 0, err
+	}
+	switch format.AudioFormat {
+	case wavFormatPCM:
+		return parsePCM(data, format.Channels, format.BitsPerSample, format.SampleRate)
+	case wavFormatIEEEFloat:
+		return parseFloatPCM(data, format.Channels, format.BitsPerSample, format.SampleRate)
+	case wavFormatALaw, wavFormatMuLaw:
+		return parseG711(data, format.Channels, format.AudioFormat, format.SampleRate)
+	default:
+		return nil, 0, fmt.Errorf("unsupported WAV format tag 0x%04x: %w", format.AudioFormat, errNotNative)
+	}
+}
+
+// readWav walks the RIFF chunks of a WAV stream and returns the fmt chunk
+// and the raw bytes of the data chunk. Unknown chunks (LIST, fact, bext...)
+// are skipped. A data chunk with size 0 or 0xFFFFFFFF, as written by
+// streaming recorders, or one cut short, is read to the end of the stream.
+func readWav(r io.Reader) (wavFormat, []byte, error) {
+	var riff [12]byte
+	if _, err := io.ReadFull(r, riff[:]); err != nil {
+		return wavFormat{}, nil, fmt.Errorf("read header: %w", err)
+	}
+	if string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return wavFormat{}, nil, errors.New("not a RIFF/WAVE file")
+	}
+
+	var format wavFormat
+	haveFmt := false
+	for {
+		var hdr [8]byte
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			return wavFormat{}, nil, errors.New("no data chunk")
+		}
+		id, size := string(hdr[0:4]), binary.LittleEndian.Uint32(hdr[4:8])
+
+		switch id {
+		case "fmt ":
+			body := make([]byte, int64(size)+int64(size&1))
+			if _, err := io.ReadFull(r, body); err != nil {
+				return wavFormat{}, nil, fmt.Errorf("read fmt chunk: %w", err)
+			}
+			f, err := parseWavFormat(body[:size])
+			if err != nil {
+				return wavFormat{}, nil, err
+			}
+			format, haveFmt = f, true
+		case "data":
+			if !haveFmt {
+				return wavFormat{}, nil, errors.New("data chunk before fmt chunk")
+			}
+			var buf bytes.Buffer
+			if size == 0 || size == math.MaxUint32 {
+				_, err := buf.ReadFrom(r)
+				return format, buf.Bytes(), err
+			}
+			_, err := io.CopyN(&buf, r, int64(size))
+			if err != nil && !errors.Is(err, io.EOF) {
+				return wavFormat{}, nil, fmt.Errorf("read data chunk: %w", err)
+			}
+			return format, buf.Bytes(), nil
+		default:
+			if _, err := io.CopyN(io.Discard, r, int64(size)+int64(size&1)); err != nil {
+				return wavFormat{}, nil, errors.New("no data chunk")
+			}
+		}
+	}
+}
+
+// parseWavFormat decodes a fmt chunk body, resolving WAVE_FORMAT_EXTENSIBLE
+// to the format tag of its sub-format GUID.
+func parseWavFormat(body []byte) (wavFormat, error) {
+	if len(body) < 16 {
+		return wavFormat{}, fmt.Errorf("fmt chunk too short: %d bytes", len(body))
+	}
+	f := wavFormat{
+		AudioFormat:   int(binary.LittleEndian.Uint16(body[0:2])),
+		Channels:      int(binary.LittleEndian.Uint16(body[2:4])),
+		SampleRate:    int(binary.LittleEndian.Uint32(body[4:8])),
+		BitsPerSample: int(binary.LittleEndian.Uint16(body[14:16])),
+	}
+	if f.AudioFormat == wavFormatExtensible {
+		if len(body) < 26 {
+			return wavFormat{}, errors.New("extensible fmt chunk too short")
+		}
+		f.AudioFormat = int(binary.LittleEndian.Uint16(body[24:26]))
+	}
+	return f, nil
+}
+
+// parsePCM converts raw little-endian integer PCM (8-bit unsigned, 16/24/32-bit
+// signed) to float32 samples normalized to [-1, +1], averaging all channels.
+func parsePCM(data []byte, numChannels, bitsPerSample, sampleRate int) ([]float32, int, error) {
+	sample := pcmSample(bitsPerSample)
+	if sample == nil || numChannels < 1 {
+		return nil, 0, fmt.Errorf("unsupported WAV: %dbit %dch", bitsPerSample, numChannels)
+	}
+	return downmix(data, numChannels, bitsPerSample/8, sample), sampleRate, nil
+}
+
+// parseFloatPCM converts raw little-endian IEEE float PCM (32 or 64-bit) to
+// float32 samples, averaging all channels and clamping to [-1, +1].
+func parseFloatPCM(data []byte, numChannels, bitsPerSample, sampleRate int) ([]float32, int, error) {
+	sample := floatSample(bitsPerSample)
+	if sample == nil || numChannels < 1 {
+		return nil, 0, fmt.Errorf("unsupported float WAV: %dbit %dch", bitsPerSample, numChannels)
+	}
+	return downmix(data, numChannels, bitsPerSample/8, sample), sampleRate, nil
+}
+
+// parseG711 expands 8-bit μ-law or A-law samples (format tag tag) to float32
+// samples, averaging all channels.
+func parseG711(data []byte, numChannels, tag, sampleRate int) ([]float32, int, error) {
+	if numChannels < 1 {
+		return nil, 0, fmt.Errorf("unsupported G.711 WAV: %dch", numChannels)
+	}
+	return downmix(data, numChannels, 1, g711Sample(tag)), sampleRate, nil
+}
+
+// g711Sample returns the decoder for one G.711 sample of format tag tag.
+func g711Sample(tag int) func([]byte) float32 {
+	if tag == wavFormatALaw {
+		return func(b []byte) float32 { return DecodeALaw(b[0]) }
+	}
+	return func(b []byte) float32 { return DecodeULaw(b[0]) }
+}
+
+// pcmSample returns the decoder for one little-endian integer PCM sample of
+// the given width, or nil if unsupported.
+func pcmSample(bitsPerSample int) func([]byte) float32 {
+	switch bitsPerSample {
+	case 8:
+		return func(b []byte) float32 { return (float32(b[0]) - 128) / 128.0 }
+	case 16:
+		return func(b []byte) float32 { return float32(int16(binary.LittleEndian.Uint16(b))) / 32768.0 }
+	case 24:
+		return func(b []byte) float32 {
+			v := int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24) >> 8
+			return float32(v) / 8388608.0
+		}
+	case 32:
+		return func(b []byte) float32 { return float32(int32(binary.LittleEndian.Uint32(b))) / 2147483648.0 }
+	}
+	return nil
+}
+
+// floatSample returns the decoder for one little-endian IEEE float sample
+// of the given width, or nil if unsupported.
+func floatSample(bitsPerSample int) func([]byte) float32 {
+	switch bitsPerSample {
+	case 32:
+		return func(b []byte) float32 {
+			return clampSample(float64(math.Float32frombits(binary.LittleEndian.Uint32(b))))
+		}
+	case 64:
+		return func(b []byte) float32 { return clampSample(math.Float64frombits(binary.LittleEndian.Uint64(b))) }
+	}
+	return nil
+}
+
+// downmix decodes interleaved frames with sample and averages the channels.
+// A trailing partial frame is ignored.
+func downmix(data []byte, numChannels, bytesPerSample int, sample func([]byte) float32) []float32 {
+	frameSize := numChannels * bytesPerSample
+	samples := make([]float32, 0, len(data)/frameSize)
+	for i := 0; i+frameSize <= len(data); i += frameSize {
+		var sum float32
+		for c := 0; c < numChannels; c++ {
+			off := i + c*bytesPerSample
+			sum += sample(data[off : off+bytesPerSample])
+		}
+		samples = append(samples, sum/float32(numChannels))
+	}
+	return samples
+}
+
+// loadWavChannels reads a PCM, IEEE float, or G.711 WAV file and returns
+// the samples of each channel separately.
+func loadWavChannels(path string) ([][]float32, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close() //nolint:errcheck
+	format, data, err := readWav(f)
+	if err != nil {
+		return nil, 0, err
+	}
+	var sample func([]byte) float32
+	switch format.AudioFormat {
+	case wavFormatPCM:
+		sample = pcmSample(format.BitsPerSample)
+	case wavFormatIEEEFloat:
+		sample = floatSample(format.BitsPerSample)
+	case wavFormatALaw, wavFormatMuLaw:
+		sample, format.BitsPerSample = g711Sample(format.AudioFormat), 8
+	}
+	if sample == nil || format.Channels < 1 {
+		return nil, 0, fmt.Errorf("unsupported WAV: format 0x%04x %dbit %dch", format.AudioFormat, format.BitsPerSample, format.Channels)
+	}
+	return deinterleave(data, format.Channels, format.BitsPerSample/8, sample), format.SampleRate, nil
+}
+
+// deinterleave decodes interleaved frames with sample into one slice per
+// channel. A trailing partial frame is ignored.
+func deinterleave(data []byte, numChannels, bytesPerSample int, sample func([]byte) float32) [][]float32 {
+	frameSize := numChannels * bytesPerSample
+	channels := make([][]float32, numChannels)
+	for c := range channels {
+		channels[c] = make([]float32, 0, len(data)/frameSize)
+	}
+	for i := 0; i+frameSize <= len(data); i += frameSize {
+		for c := range channels {
+			off := i + c*bytesPerSample
+			channels[c] = append(channels[c], sample(data[off:off+bytesPerSample]))
+		}
+	}
+	return channels
+}
+
+// clampSample limits v to [-1, +1]; NaN becomes silence.
+func clampSample(v float64) float32 {
+	switch {
+	case math.IsNaN(v):
+		return 0
+	case v > 1:
+		return 1
+	case v < -1:
+		return -1
+	}
+	return float32(v)
+}
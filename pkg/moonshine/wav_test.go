@@ -0,0 +1,424 @@
+package moonshine
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// wavBytes builds a WAV file with the given fmt chunk body and data,
+// inserting extra chunks between fmt and data.
+func wavBytes(fmtBody, data []byte, dataSize uint32, extra ...[]byte) []byte {
+	var b bytes.Buffer
+	b.WriteString("RIFF\x00\x00\x00\x00WAVE")
+	writeChunk := func(id string, size uint32, body []byte) {
+		b.WriteString(id)
+		binary.Write(&b, binary.LittleEndian, size) //nolint:errcheck
+		b.Write(body)
+		if len(body)%2 == 1 {
+			b.WriteByte(0)
+		}
+	}
+	writeChunk("fmt ", uint32(len(fmtBody)), fmtBody)
+	for _, e := range extra {
+		b.Write(e)
+	}
+	writeChunk("data", dataSize, data)
+	return b.Bytes()
+}
+
+// fmtChunk builds a 16-byte fmt chunk body.
+func fmtChunk(tag, channels, rate, bits int) []byte {
+	body := make([]byte, 16)
+	binary.LittleEndian.PutUint16(body[0:2], uint16(tag))
+	binary.LittleEndian.PutUint16(body[2:4], uint16(channels))
+	binary.LittleEndian.PutUint32(body[4:8], uint32(rate))
+	binary.LittleEndian.PutUint16(body[12:14], uint16(channels*bits/8))
+	binary.LittleEndian.PutUint32(body[8:12], uint32(rate*channels*bits/8))
+	binary.LittleEndian.PutUint16(body[14:16], uint16(bits))
+	return body
+}
+
+// writeWavFile writes data to a temp .wav file and returns its path.
+func writeWavFile(t *testing.T, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "a.wav")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// --- parsePCM ---
+
+func TestParsePCM_Mono16(t *testing.T) {
+	// Two samples: 0 and max positive.
+	data := make([]byte, 4)
+	binary.LittleEndian.PutUint16(data[0:2], 0)
+	binary.LittleEndian.PutUint16(data[2:4], 0x7FFF) // 32767
+
+	samples, sr, err := parsePCM(data, 1, 16, 16000)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sr != 16000 {
+		t.Errorf("sampleRate = %d, want 16000", sr)
+	}
+	if len(samples) != 2 {
+		t.Fatalf("expected 2 samples, got %d", len(samples))
+	}
+	if samples[0] != 0 {
+		t.Errorf("samples[0] = %f, want 0", samples[0])
+	}
+	// 32767/32768 ≈ 0.99997
+	if samples[1] < 0.999 || samples[1] > 1.0 {
+		t.Errorf("samples[1] = %f, want ~1.0", samples[1])
+	}
+}
+
+func TestParsePCM_Mono16_Negative(t *testing.T) {
+	// Min negative: -32768 → -1.0.
+	data := make([]byte, 2)
+	binary.LittleEndian.PutUint16(data[0:2], 0x8000) // -32768 as int16
+
+	samples, _, err := parsePCM(data, 1, 16, 16000)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(samples) != 1 {
+		t.Fatalf("expected 1 sample, got %d", len(samples))
+	}
+	if samples[0] != -1.0 {
+		t.Errorf("samples[0] = %f, want -1.0", samples[0])
+	}
+}
+
+func TestParsePCM_Stereo16(t *testing.T) {
+	// L=16384 (0.5), R=0 → avg=0.25.
+	data := make([]byte, 4)
+	binary.LittleEndian.PutUint16(data[0:2], 0x4000) // 16384
+	binary.LittleEndian.PutUint16(data[2:4], 0)      // 0
+
+	samples, _, err := parsePCM(data, 2, 16, 16000)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(samples) != 1 {
+		t.Fatalf("expected 1 sample, got %d", len(samples))
+	}
+	// (16384 + 0) / 2.0 / 32768.0 = 0.25
+	if samples[0] < 0.249 || samples[0] > 0.251 {
+		t.Errorf("samples[0] = %f, want ~0.25", samples[0])
+	}
+}
+
+func TestParsePCM_StereoSymmetric(t *testing.T) {
+	// L=10000, R=10000 → avg should be same as mono 10000.
+	data := make([]byte, 4)
+	binary.LittleEndian.PutUint16(data[0:2], uint16(10000))
+	binary.LittleEndian.PutUint16(data[2:4], uint16(10000))
+
+	samples, _, err := parsePCM(data, 2, 16, 16000)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := float32(10000) / 32768.0
+	if samples[0] < expected-0.001 || samples[0] > expected+0.001 {
+		t.Errorf("samples[0] = %f, want ~%f", samples[0], expected)
+	}
+}
+
+func TestParsePCM_EmptyData(t *testing.T) {
+	samples, _, err := parsePCM([]byte{}, 1, 16, 16000)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(samples) != 0 {
+		t.Errorf("expected 0 samples for empty data, got %d", len(samples))
+	}
+}
+
+func TestParsePCM_OddBytes(t *testing.T) {
+	// 3 bytes for mono 16-bit: only 1 complete sample (2 bytes), last byte ignored.
+	data := []byte{0x00, 0x01, 0xFF}
+	samples, _, err := parsePCM(data, 1, 16, 16000)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(samples) != 1 {
+		t.Errorf("expected 1 sample, got %d", len(samples))
+	}
+}
+
+func TestParsePCM_UnsupportedFormat(t *testing.T) {
+	_, _, err := parsePCM([]byte{0, 0}, 1, 12, 16000)
+	if err == nil {
+		t.Error("expected error for 12-bit audio")
+	}
+}
+
+func TestParsePCM_ZeroChannels(t *testing.T) {
+	_, _, err := parsePCM([]byte{0, 0}, 0, 16, 16000)
+	if err == nil {
+		t.Error("expected error for 0 channels")
+	}
+}
+
+func TestParsePCM_BitDepths(t *testing.T) {
+	tests := []struct {
+		name string
+		bits int
+		data []byte
+		want []float32
+	}{
+		{"8-bit unsigned", 8, []byte{0x00, 0x80, 0xC0}, []float32{-1, 0, 0.5}},
+		{"24-bit", 24, []byte{0x00, 0x00, 0x40, 0x00, 0x00, 0x80}, []float32{0.5, -1}},
+		{"32-bit", 32, []byte{0x00, 0x00, 0x00, 0xC0}, []float32{-0.5}},
+	}
+	for _, tt := range tests {
+		samples, _, err := parsePCM(tt.data, 1, tt.bits, 16000)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		if len(samples) != len(tt.want) {
+			t.Fatalf("%s: got %d samples, want %d", tt.name, len(samples), len(tt.want))
+		}
+		for i := range tt.want {
+			if samples[i] != tt.want[i] {
+				t.Errorf("%s: samples[%d] = %f, want %f", tt.name, i, samples[i], tt.want[i])
+			}
+		}
+	}
+}
+
+func TestParsePCM_3Channels(t *testing.T) {
+	data := make([]byte, 6)
+	binary.LittleEndian.PutUint16(data[0:2], 0x6000) // 0.75
+	binary.LittleEndian.PutUint16(data[2:4], 0x0000)
+	binary.LittleEndian.PutUint16(data[4:6], 0xA000) // -0.75
+	samples, _, err := parsePCM(data, 3, 16, 16000)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(samples) != 1 || samples[0] != 0 {
+		t.Errorf("samples = %v, want [0]", samples)
+	}
+}
+
+func TestParsePCM_SampleRatePassthrough(t *testing.T) {
+	data := make([]byte, 2)
+	_, sr, err := parsePCM(data, 1, 16, 44100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sr != 44100 {
+		t.Errorf("sampleRate = %d, want 44100", sr)
+	}
+}
+
+// --- parseFloatPCM ---
+
+func TestParseFloatPCM(t *testing.T) {
+	data := make([]byte, 16)
+	binary.LittleEndian.PutUint32(data[0:4], math.Float32bits(0.25))
+	binary.LittleEndian.PutUint32(data[4:8], math.Float32bits(1.5))
+	binary.LittleEndian.PutUint32(data[8:12], math.Float32bits(-2))
+	binary.LittleEndian.PutUint32(data[12:16], math.Float32bits(float32(math.NaN())))
+	samples, _, err := parseFloatPCM(data, 1, 32, 48000)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []float32{0.25, 1, -1, 0}
+	for i := range want {
+		if samples[i] != want[i] {
+			t.Errorf("samples[%d] = %f, want %f", i, samples[i], want[i])
+		}
+	}
+
+	data64 := make([]byte, 16)
+	binary.LittleEndian.PutUint64(data64[0:8], math.Float64bits(0.5))
+	binary.LittleEndian.PutUint64(data64[8:16], math.Float64bits(-0.5))
+	samples, _, err = parseFloatPCM(data64, 2, 64, 16000)
+	if err != nil || len(samples) != 1 || samples[0] != 0 {
+		t.Errorf("64-bit stereo = %v, %v; want [0]", samples, err)
+	}
+
+	if _, _, err := parseFloatPCM(data, 1, 16, 16000); err == nil {
+		t.Error("expected error for 16-bit float")
+	}
+}
+
+// --- loadWav ---
+
+func TestLoadWav_Float32WithListChunk(t *testing.T) {
+	data := make([]byte, 8)
+	binary.LittleEndian.PutUint32(data[0:4], math.Float32bits(0.5))
+	binary.LittleEndian.PutUint32(data[4:8], math.Float32bits(-0.5))
+	list := append([]byte("LIST\x05\x00\x00\x00INFOx"), 0) // odd size, padded
+	path := writeWavFile(t, wavBytes(fmtChunk(wavFormatIEEEFloat, 1, 48000, 32), data, 8, list))
+
+	samples, rate, err := loadWav(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rate != 48000 || len(samples) != 2 || samples[0] != 0.5 || samples[1] != -0.5 {
+		t.Errorf("loadWav = %v @ %d", samples, rate)
+	}
+}
+
+func TestLoadWav_Extensible24Bit(t *testing.T) {
+	body := make([]byte, 40)
+	copy(body, fmtChunk(wavFormatExtensible, 2, 16000, 24))
+	binary.LittleEndian.PutUint16(body[16:18], 22) // cbSize
+	binary.LittleEndian.PutUint16(body[24:26], wavFormatPCM)
+	data := []byte{0x00, 0x00, 0x40, 0x00, 0x00, 0x40} // L=0.5, R=0.5
+	path := writeWavFile(t, wavBytes(body, data, uint32(len(data))))
+
+	samples, _, err := loadWav(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(samples) != 1 || samples[0] != 0.5 {
+		t.Errorf("samples = %v, want [0.5]", samples)
+	}
+}
+
+func TestLoadWav_StreamingDataSize(t *testing.T) {
+	data := make([]byte, 6)
+	for _, size := range []uint32{0, math.MaxUint32, 1000} {
+		path := writeWavFile(t, wavBytes(fmtChunk(wavFormatPCM, 1, 16000, 16), data, size))
+		samples, _, err := loadWav(path)
+		if err != nil {
+			t.Fatalf("size %d: unexpected error: %v", size, err)
+		}
+		if len(samples) != 3 {
+			t.Errorf("size %d: got %d samples, want 3", size, len(samples))
+		}
+	}
+}
+
+func TestLoadWav_G711(t *testing.T) {
+	tests := []struct {
+		tag  int
+		data []byte
+		want []float32
+	}{
+		{wavFormatMuLaw, []byte{0xFF, 0x80}, []float32{0, 32124.0 / 32768}},
+		{wavFormatALaw, []byte{0xD5, 0x2A}, []float32{8.0 / 32768, -32256.0 / 32768}},
+	}
+	for _, tt := range tests {
+		path := writeWavFile(t, wavBytes(fmtChunk(tt.tag, 1, 8000, 8), tt.data, uint32(len(tt.data))))
+		samples, rate, err := loadWav(path)
+		if err != nil {
+			t.Fatalf("tag 0x%04x: unexpected error: %v", tt.tag, err)
+		}
+		if rate != 8000 || len(samples) != 2 || samples[0] != tt.want[0] || samples[1] != tt.want[1] {
+			t.Errorf("tag 0x%04x: loadWav = %v @ %d, want %v @ 8000", tt.tag, samples, rate, tt.want)
+		}
+	}
+}
+
+func TestLoadWav_Errors(t *testing.T) {
+	noData := wavBytes(fmtChunk(wavFormatPCM, 1, 16000, 16), nil, 0)
+	noData = noData[:len(noData)-8] // drop the data chunk header
+	tests := map[string][]byte{
+		"not riff":    []byte("OggS\x00\x00\x00\x00WAVEfmt "),
+		"short":       []byte("RIFF"),
+		"no data":     noData,
+		"data first":  append([]byte("RIFF\x00\x00\x00\x00WAVEdata\x02\x00\x00\x00"), 0, 0),
+		"adpcm":       wavBytes(fmtChunk(0x0002, 1, 16000, 4), []byte{0, 0}, 2),
+		"short fmt":   wavBytes([]byte{1, 0, 1, 0}, []byte{0, 0}, 2),
+		"unsupported": wavBytes(fmtChunk(wavFormatPCM, 1, 16000, 12), []byte{0, 0}, 2),
+	}
+	for name, data := range tests {
+		if _, _, err := loadWav(writeWavFile(t, data)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+// --- loadWavChannels ---
+
+// --- WriteWav ---
+
+func TestWriteWav_RoundTrip(t *testing.T) {
+	in := []float32{0, 0.5, -0.5, 1, -1, 2}
+	var b bytes.Buffer
+	if err := WriteWav(&b, in, 8000); err != nil {
+		t.Fatal(err)
+	}
+	if b.Len() != 44+2*len(in) {
+		t.Errorf("len = %d, want %d", b.Len(), 44+2*len(in))
+	}
+	out, rate, err := decodeWav(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rate != 8000 || len(out) != len(in) {
+		t.Fatalf("got %d samples at %d Hz, want %d at 8000", len(out), rate, len(in))
+	}
+	for i, want := range []float32{0, 0.5, -0.5, 1, -1, 1} {
+		if math.Abs(float64(out[i]-want)) > 1e-3 {
+			t.Errorf("sample %d = %v, want %v", i, out[i], want)
+		}
+	}
+}
+
+func TestLoadWavChannels_Stereo16(t *testing.T) {
+	data := make([]byte, 8) // two frames: (0.5, -0.5), (0, 0.25)
+	binary.LittleEndian.PutUint16(data[0:2], uint16(int16(16384)))
+	binary.LittleEndian.PutUint16(data[2:4], uint16(0xC000))
+	binary.LittleEndian.PutUint16(data[6:8], uint16(int16(8192)))
+	path := writeWavFile(t, wavBytes(fmtChunk(wavFormatPCM, 2, 8000, 16), data, 8))
+
+	channels, rate, err := loadWavChannels(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rate != 8000 || len(channels) != 2 {
+		t.Fatalf("got %d channels @ %d, want 2 @ 8000", len(channels), rate)
+	}
+	if l := channels[0]; len(l) != 2 || l[0] != 0.5 || l[1] != 0 {
+		t.Errorf("left = %v, want [0.5 0]", l)
+	}
+	if r := channels[1]; len(r) != 2 || r[0] != -0.5 || r[1] != 0.25 {
+		t.Errorf("right = %v, want [-0.5 0.25]", r)
+	}
+}
+
+func TestLoadWavChannels_StereoMuLaw(t *testing.T) {
+	path := writeWavFile(t, wavBytes(fmtChunk(wavFormatMuLaw, 2, 8000, 8), []byte{0x80, 0x00}, 2))
+	channels, _, err := loadWavChannels(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(channels) != 2 || channels[0][0] != DecodeULaw(0x80) || channels[1][0] != DecodeULaw(0x00) {
+		t.Errorf("channels = %v", channels)
+	}
+}
+
+func TestLoadWavChannels_Errors(t *testing.T) {
+	tests := map[string][]byte{
+		"not riff":    []byte("OggS\x00\x00\x00\x00WAVEfmt "),
+		"no channels": wavBytes(fmtChunk(wavFormatPCM, 0, 16000, 16), []byte{0, 0}, 2),
+		"unsupported": wavBytes(fmtChunk(wavFormatPCM, 1, 16000, 12), []byte{0, 0}, 2),
+	}
+	for name, data := range tests {
+		if _, _, err := loadWavChannels(writeWavFile(t, data)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+// --- deinterleave ---
+
+func TestDeinterleave_PartialFrame(t *testing.T) {
+	sample := func(b []byte) float32 { return float32(b[0]) }
+	got := deinterleave([]byte{1, 2, 3, 4, 5, 6, 7}, 3, 1, sample)
+	if len(got) != 3 || len(got[0]) != 2 || got[0][1] != 4 || got[2][1] != 6 {
+		t.Errorf("deinterleave = %v, want [[1 4] [2 5] [3 6]]", got)
+	}
+}
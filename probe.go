@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"os"
+)
+
+// handleProbe handles POST /probe: the duration, format, and speech ratio
+// of the audio in a /transcribe JSON body or an "audio" multipart upload,
+// without transcribing it.
+func handleProbe(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "POST only")
+		return
+	}
+	ctx, req, audioPath, done, ok := analysisAudio(w, r)
+	if !ok {
+		return
+	}
+	defer done()
+	info, err := engineFor(ctx).Probe(ctx, audioPath, requestOptions(ctx, req))
+	if err != nil {
+		resp, status := engineError(ctx, err)
+		writeJSON(w, status, resp)
+		return
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+// analysisAudio reads the audio of a request to an endpoint that inspects
+// audio without transcribing it: a /transcribe JSON body or an "audio"
+// multipart upload. It returns the request context with REQUEST_TIMEOUT_S
+// applied, the request, and a local path to the audio; done releases them.
+// On failure it writes the error response and returns ok false.
+func analysisAudio(w http.ResponseWriter, r *http.Request) (ctx context.Context, req TranscribeRequest, audioPath string, done func(), ok bool) {
+	extendDeadlines(w)
+	removeUpload := func() {}
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+		tmpFile, _, values, ok := saveUpload(w, r)
+		if !ok {
+			return nil, req, "", nil, false
+		}
+		removeUpload = func() { os.Remove(tmpFile) } //nolint:errcheck
+		req = requestFromValues(values.Get)
+		req.AudioPath = tmpFile
+	} else {
+		if !readJSON(w, r, &req) {
+			return nil, req, "", nil, false
+		}
+		if msg := validateSource(req); msg != "" {
+			writeError(w, http.StatusBadRequest, msg)
+			return nil, req, "", nil, false
+		}
+	}
+
+	ctx, cancel := withRequestTimeout(r.Context())
+	audioPath, cleanup, status, err := fetchAudio(ctx, req)
+	if ctx.Err() != nil {
+		resp, status := contextError(ctx)
+		writeJSON(w, status, resp)
+		cancel()
+		removeUpload()
+		return nil, req, "", nil, false
+	}
+	if err != nil {
+		writeError(w, status, err.Error())
+		cancel()
+		removeUpload()
+		return nil, req, "", nil, false
+	}
+	return ctx, req, audioPath, func() {
+		cleanup()
+		cancel()
+		removeUpload()
+	}, true
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/anatolykoptev/moonshine-whisper/pkg/moonshine"
+)
+
+// silentWav returns a 16-bit mono WAV of n silent samples at rate.
+func silentWav(rate, n int) []byte {
+	b := make([]byte, 44+2*n)
+	copy(b, "RIFF")
+	binary.LittleEndian.PutUint32(b[4:], uint32(36+2*n))
+	copy(b[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(b[16:], 16)
+	binary.LittleEndian.PutUint16(b[20:], 1) // PCM
+	binary.LittleEndian.PutUint16(b[22:], 1) // mono
+	binary.LittleEndian.PutUint32(b[24:], uint32(rate))
+	binary.LittleEndian.PutUint32(b[28:], uint32(2*rate))
+	binary.LittleEndian.PutUint16(b[32:], 2)
+	binary.LittleEndian.PutUint16(b[34:], 16)
+	copy(b[36:], "data")
+	binary.LittleEndian.PutUint32(b[40:], uint32(2*n))
+	return b
+}
+
+// --- handleProbe ---
+
+func TestHandleProbe(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "clip.wav")
+	if err := os.WriteFile(path, silentWav(8000, 16000), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	want := moonshine.AudioInfo{DurationS: 2, SampleRate: 8000, Channels: 1, Codec: "pcm_s16le"}
+
+	rec := httptest.NewRecorder()
+	handleProbe(rec, httptest.NewRequest(http.MethodPost, "/probe", strings.NewReader(`{"audio_path":"`+path+`"}`)))
+	var got moonshine.AudioInfo
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil || rec.Code != http.StatusOK || got != want {
+		t.Errorf("JSON probe = %d %+v, %v; want %+v", rec.Code, got, err, want)
+	}
+
+	oldMB := cfg.UploadMaxMB
+	cfg.UploadMaxMB = 1
+	t.Cleanup(func() { cfg.UploadMaxMB = oldMB })
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, _ := mw.CreateFormFile("audio", "clip.wav")
+	fw.Write(silentWav(8000, 16000)) //nolint:errcheck
+	mw.Close()                       //nolint:errcheck
+	r := httptest.NewRequest(http.MethodPost, "/probe", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	rec = httptest.NewRecorder()
+	handleProbe(rec, r)
+	got = moonshine.AudioInfo{}
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil || rec.Code != http.StatusOK || got != want {
+		t.Errorf("upload probe = %d %+v, %v; want %+v", rec.Code, got, err, want)
+	}
+}
+
+func TestHandleProbe_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		want   int
+	}{
+		{"method", http.MethodGet, "", http.StatusMethodNotAllowed},
+		{"invalid json", http.MethodPost, "{", http.StatusBadRequest},
+		{"no source", http.MethodPost, `{}`, http.StatusBadRequest},
+		{"missing file", http.MethodPost, `{"audio_path":"/nonexistent/clip.wav"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handleProbe(rec, httptest.NewRequest(tt.method, "/probe", strings.NewReader(tt.body)))
+		if rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d (%s)", tt.name, rec.Code, tt.want, rec.Body)
+		}
+	}
+}
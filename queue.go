@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/anatolykoptev/moonshine-whisper/pkg/moonshine"
+	"github.com/google/uuid"
+)
+
+// QueueRequest is a transcription request read from a message queue: a
+// TranscribeRequest with an ID that is echoed in the result.
+type QueueRequest struct {
+	ID string `json:"id,omitempty"` // ""=the message key, or a new UUID
+	TranscribeRequest
+}
+
+// processQueueMessage transcribes the QueueRequest in data and returns the
+// finished job, in the shape of GET /jobs/{id}, to publish as the result.
+// key identifies the message when the request has no ID. Invalid requests
+// yield a failed job.
+func processQueueMessage(ctx context.Context, data []byte, key string) Job {
+	started := time.Now()
+	var req QueueRequest
+	err := json.Unmarshal(data, &req)
+	if req.ID == "" {
+		req.ID = key
+	}
+	if req.ID == "" {
+		req.ID = uuid.New().String()
+	}
+	j := Job{ID: req.ID, Status: jobFailed, CreatedAt: started, StartedAt: &started}
+
+	var resp TranscribeResponse
+	var status int
+	msg := validateSource(req.TranscribeRequest)
+	if msg == "" {
+		msg = validateOptions(req.TranscribeRequest)
+	}
+	switch {
+	case err != nil:
+		resp = TranscribeResponse{Error: "invalid JSON: " + err.Error()}
+	case msg != "":
+		resp = TranscribeResponse{Error: msg}
+	default:
+		ctx, cancel := withRequestTimeout(ctx)
+		resp, status = runTranscribeRequest(ctx, req.TranscribeRequest, func(o *moonshine.Options) { o.WaitForLoad = true })
+		cancel()
+		recordTranscript(ctx, sourceQueue, req.audioName(), req.TranscribeRequest, &resp)
+	}
+	if status == http.StatusOK {
+		j.Status = jobDone
+	}
+	finished := time.Now()
+	j.FinishedAt, j.Result = &finished, &resp
+	log.Printf("queue request %s %s in %dms", j.ID, j.Status, finished.Sub(started).Milliseconds())
+	return j
+}
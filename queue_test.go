@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// --- processQueueMessage ---
+
+func TestProcessQueueMessage_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		key     string
+		wantID  string
+		wantErr string
+	}{
+		{"invalid JSON", `{"audio_path":`, "k1", "k1", "invalid JSON"},
+		{"no source", `{"id":"r1","language":"en"}`, "k1", "r1", "audio_path"},
+		{"bad options", `{"audio_url":"https://example.com/a.wav","max_speakers":-1}`, "k2", "k2", "max_speakers"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := processQueueMessage(context.Background(), []byte(tt.data), tt.key)
+			if j.ID != tt.wantID {
+				t.Errorf("ID = %q, want %q", j.ID, tt.wantID)
+			}
+			if j.Status != jobFailed {
+				t.Errorf("Status = %q, want %q", j.Status, jobFailed)
+			}
+			if j.Result == nil || !strings.Contains(j.Result.Error, tt.wantErr) {
+				t.Errorf("Result = %+v, want error containing %q", j.Result, tt.wantErr)
+			}
+			if j.StartedAt == nil || j.FinishedAt == nil {
+				t.Error("StartedAt and FinishedAt must be set")
+			}
+		})
+	}
+}
+
+func TestProcessQueueMessage_GeneratesID(t *testing.T) {
+	j := processQueueMessage(context.Background(), []byte(`{}`), "")
+	if len(j.ID) != 36 {
+		t.Errorf("ID = %q, want a UUID", j.ID)
+	}
+}
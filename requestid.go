@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// requestIDHeader carries the ID that correlates a request across services.
+const requestIDHeader = "X-Request-ID"
+
+// requestIDMaxLen bounds the length of a client-supplied request ID.
+const requestIDMaxLen = 128
+
+type requestIDCtxKey struct{}
+
+// requestIDMiddleware gives every request an ID: the client's X-Request-ID
+// when it is valid (see validRequestID), else a new UUID. The ID is echoed
+// in the X-Request-ID response header and carried in the request context.
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.New().String()
+		}
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(withRequestID(r.Context(), id)))
+	})
+}
+
+// validRequestID reports whether id is 1 to requestIDMaxLen letters,
+// digits, and "-", "_", ".", ":" characters, so it is safe to log and to
+// pass on in headers.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > requestIDMaxLen {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// withRequestID returns ctx carrying the request ID id; an empty id leaves
+// ctx as is.
+func withRequestID(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, requestIDCtxKey{}, id)
+}
+
+// requestID returns the request ID of ctx, or "".
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDCtxKey{}).(string)
+	return id
+}
+
+// logf logs like log.Printf, prefixed with the request ID of ctx when it
+// has one.
+func logf(ctx context.Context, format string, args ...any) {
+	if id := requestID(ctx); id != "" {
+		format = "[" + id + "] " + format
+	}
+	log.Printf(format, args...)
+}
+
+// requestLogger returns a logger that prefixes its lines with the request
+// ID of ctx, for the engine's log lines of the request, or nil when ctx has
+// no ID.
+func requestLogger(ctx context.Context) *log.Logger {
+	id := requestID(ctx)
+	if id == "" {
+		return nil
+	}
+	return log.New(log.Writer(), "["+id+"] ", log.Flags()|log.Lmsgprefix)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// --- requestIDMiddleware ---
+
+func TestRequestIDMiddleware(t *testing.T) {
+	h := requestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Seen", requestID(r.Context()))
+	}))
+	tests := []struct {
+		name  string
+		id    string
+		keeps bool
+	}{
+		{"client id", "req-42.a_b:c", true},
+		{"none", "", false},
+		{"invalid chars", "a b", false},
+		{"too long", strings.Repeat("a", requestIDMaxLen+1), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/health", nil)
+			if tt.id != "" {
+				r.Header.Set("X-Request-ID", tt.id)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, r)
+			got := rec.Header().Get("X-Request-ID")
+			if got == "" || got != rec.Header().Get("X-Seen") {
+				t.Fatalf("header %q, context %q", got, rec.Header().Get("X-Seen"))
+			}
+			if (got == tt.id) != tt.keeps {
+				t.Errorf("X-Request-ID = %q for %q, keeps = %v", got, tt.id, tt.keeps)
+			}
+		})
+	}
+}
+
+// --- logf / requestLogger ---
+
+func TestLogf_RequestID(t *testing.T) {
+	var buf bytes.Buffer
+	oldOut, oldFlags := log.Writer(), log.Flags()
+	log.SetOutput(&buf)
+	log.SetFlags(0)
+	t.Cleanup(func() { log.SetOutput(oldOut); log.SetFlags(oldFlags) })
+
+	ctx := withRequestID(context.Background(), "r1")
+	logf(ctx, "job %s done", "j1")
+	logf(context.Background(), "plain")
+	requestLogger(ctx).Printf("VAD: %d chunk(s)", 2)
+	if want := "[r1] job j1 done\nplain\n[r1] VAD: 2 chunk(s)\n"; buf.String() != want {
+		t.Errorf("logged %q, want %q", buf.String(), want)
+	}
+	if requestLogger(context.Background()) != nil {
+		t.Error("requestLogger without ID is not nil")
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/anatolykoptev/moonshine-whisper/pkg/moonshine"
+	"github.com/google/uuid"
+)
+
+// Audio retention: with RETAIN_AUDIO set, the audio of every file-based
+// transcription is kept under a new audio ID instead of only being deleted,
+// as <id>/original<ext> and, with RETAIN_WAV, <id>/decoded.wav, the 16-bit
+// mono audio the engine transcribed. The ID is returned as audio_id and
+// stored with the transcript.
+
+// audioStore keeps retained audio files.
+type audioStore interface {
+	// put copies the file at src to key.
+	put(ctx context.Context, key, src string) error
+}
+
+// retainStore is set when RETAIN_AUDIO is configured.
+var retainStore audioStore
+
+// openAudioStore opens the store at target: an s3://bucket/prefix URI or a
+// local directory, created if missing.
+func openAudioStore(target string) (audioStore, error) {
+	if strings.HasPrefix(target, "s3://") {
+		u, err := url.Parse(target)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid S3 URI %q (want s3://bucket/prefix)", target)
+		}
+		return s3AudioStore{bucket: u.Host, prefix: strings.Trim(u.Path, "/")}, nil
+	}
+	if err := os.MkdirAll(target, 0o700); err != nil {
+		return nil, err
+	}
+	return dirAudioStore{dir: target}, nil
+}
+
+// dirAudioStore keeps audio in a local directory.
+type dirAudioStore struct {
+	dir string
+}
+
+func (s dirAudioStore) put(_ context.Context, key, src string) error {
+	dst := filepath.Join(s.dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(dst), 0o700); err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close() //nolint:errcheck
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()    //nolint:errcheck
+		os.Remove(dst) //nolint:errcheck
+		return err
+	}
+	return out.Close()
+}
+
+// s3AudioStore keeps audio in an S3 bucket under a key prefix, with the
+// credentials and endpoint that s3:// input uses.
+type s3AudioStore struct {
+	bucket, prefix string
+}
+
+func (s s3AudioStore) put(ctx context.Context, key, src string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close() //nolint:errcheck
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	req, err := newS3Request(ctx, http.MethodPut, s.bucket, path.Join(s.prefix, key), nil, f)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = info.Size()
+	resp, err := objectClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close() //nolint:errcheck
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("s3: put s3://%s/%s: %s", s.bucket, path.Join(s.prefix, key), resp.Status)
+	}
+	return nil
+}
+
+// retainAudio keeps the audio file at src when RETAIN_AUDIO is set and
+// returns its audio ID, or "" when retention is off or failed; failures are
+// logged and do not fail the request. With RETAIN_WAV it also sets
+// opts.OnDecoded to keep the decoded audio.
+func retainAudio(ctx context.Context, src string, opts *moonshine.Options) string {
+	if retainStore == nil {
+		return ""
+	}
+	id := uuid.New().String()
+	if err := retainStore.put(ctx, id+"/original"+strings.ToLower(filepath.Ext(src)), src); err != nil {
+		logf(ctx, "WARNING: retain audio: %v", err)
+		return ""
+	}
+	if cfg.RetainWAV {
+		opts.OnDecoded = func(samples []float32, sampleRate int) {
+			if err := retainWav(ctx, id, samples, sampleRate); err != nil {
+				logf(ctx, "WARNING: retain decoded audio: %v", err)
+			}
+		}
+	}
+	return id
+}
+
+// retainWav keeps samples as <id>/decoded.wav.
+func retainWav(ctx context.Context, id string, samples []float32, sampleRate int) error {
+	tmp, err := newTempPath(".wav")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp) //nolint:errcheck
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err := moonshine.WriteWav(f, samples, sampleRate); err != nil {
+		f.Close() //nolint:errcheck
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return retainStore.put(ctx, id+"/decoded.wav", tmp)
+}
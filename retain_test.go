@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/anatolykoptev/moonshine-whisper/pkg/moonshine"
+)
+
+// newTestRetainStore enables audio retention in a temp directory and
+// returns it.
+func newTestRetainStore(t *testing.T, wav bool) string {
+	t.Helper()
+	dir := t.TempDir()
+	oldStore, oldWAV := retainStore, cfg.RetainWAV
+	retainStore, cfg.RetainWAV = dirAudioStore{dir: dir}, wav
+	t.Cleanup(func() { retainStore, cfg.RetainWAV = oldStore, oldWAV })
+	return dir
+}
+
+// --- openAudioStore ---
+
+func TestOpenAudioStore(t *testing.T) {
+	s, err := openAudioStore("s3://archive/calls/")
+	if err != nil || s != (s3AudioStore{bucket: "archive", prefix: "calls"}) {
+		t.Errorf("s3 = %+v, %v", s, err)
+	}
+	dir := filepath.Join(t.TempDir(), "retained")
+	if s, err := openAudioStore(dir); err != nil || s != (dirAudioStore{dir: dir}) {
+		t.Errorf("dir = %+v, %v", s, err)
+	}
+	if _, err := os.Stat(dir); err != nil {
+		t.Errorf("directory not created: %v", err)
+	}
+}
+
+// --- retainAudio ---
+
+func TestRetainAudio(t *testing.T) {
+	dir := newTestRetainStore(t, true)
+	src := filepath.Join(t.TempDir(), "call.MP3")
+	os.WriteFile(src, []byte("ID3 audio"), 0o600) //nolint:errcheck
+
+	var opts moonshine.Options
+	id := retainAudio(context.Background(), src, &opts)
+	if id == "" {
+		t.Fatal("no audio ID")
+	}
+	if b, err := os.ReadFile(filepath.Join(dir, id, "original.mp3")); err != nil || string(b) != "ID3 audio" {
+		t.Errorf("original = %q, %v", b, err)
+	}
+	if opts.OnDecoded == nil {
+		t.Fatal("OnDecoded not set with RETAIN_WAV")
+	}
+	opts.OnDecoded([]float32{0, 0.5}, 16000)
+	if info, err := os.Stat(filepath.Join(dir, id, "decoded.wav")); err != nil || info.Size() != 48 {
+		t.Errorf("decoded.wav = %v, %v; want 48 bytes", info, err)
+	}
+}
+
+func TestRetainAudio_Disabled(t *testing.T) {
+	var opts moonshine.Options
+	if id := retainAudio(context.Background(), "/nonexistent.wav", &opts); id != "" || opts.OnDecoded != nil {
+		t.Errorf("retainAudio = %q, OnDecoded set %v; want nothing", id, opts.OnDecoded != nil)
+	}
+}
+
+func TestRetainAudio_Failure(t *testing.T) {
+	newTestRetainStore(t, false)
+	var opts moonshine.Options
+	if id := retainAudio(context.Background(), filepath.Join(t.TempDir(), "missing.wav"), &opts); id != "" {
+		t.Errorf("retainAudio = %q, want \"\" when the copy fails", id)
+	}
+}
+
+// --- s3AudioStore ---
+
+func TestS3AudioStore_Put(t *testing.T) {
+	isolateCloudEnv(t)
+	var gotMethod, gotPath, gotHash string
+	var gotBody []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod, gotPath, gotHash = r.Method, r.URL.Path, r.Header.Get("X-Amz-Content-Sha256")
+		gotBody, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+	t.Setenv("AWS_ENDPOINT_URL_S3", srv.URL)
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "SECRET")
+
+	src := filepath.Join(t.TempDir(), "a.wav")
+	os.WriteFile(src, []byte("RIFFdata"), 0o600) //nolint:errcheck
+	if err := (s3AudioStore{bucket: "archive", prefix: "calls"}).put(context.Background(), "id1/original.wav", src); err != nil {
+		t.Fatal(err)
+	}
+	if gotMethod != http.MethodPut || gotPath != "/archive/calls/id1/original.wav" || gotHash != unsignedPayload || string(gotBody) != "RIFFdata" {
+		t.Errorf("got %s %s hash %q body %q", gotMethod, gotPath, gotHash, gotBody)
+	}
+}
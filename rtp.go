@@ -0,0 +1,333 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/anatolykoptev/moonshine-whisper/pkg/moonshine"
+	"github.com/google/uuid"
+)
+
+// RTP payload types with a static audio format (RFC 3551).
+const (
+	rtpPayloadPCMU = 0
+	rtpPayloadPCMA = 8
+	rtpPayloadL16  = 11 // mono, 44.1 kHz
+)
+
+// rtpFormats maps the supported payload types to their sample format.
+var rtpFormats = map[int]pcmFormat{
+	rtpPayloadPCMU: {SampleRate: 8000, Channels: 1, BigEndian: true, Encoding: pcmULaw},
+	rtpPayloadPCMA: {SampleRate: 8000, Channels: 1, BigEndian: true, Encoding: pcmALaw},
+	rtpPayloadL16:  {SampleRate: 44100, Channels: 1, BigEndian: true, Encoding: pcmL16},
+}
+
+// rtpMaxGap bounds the silence inserted for lost packets; a larger jump in
+// the RTP timestamp is treated as a discontinuity and not filled.
+const rtpMaxGap = time.Second
+
+// rtpQueueSize is the number of packets buffered per call while its decoder
+// is busy; packets beyond it are dropped.
+const rtpQueueSize = 256
+
+// rtpPacket is the part of an RTP packet (RFC 3550) the listener uses.
+type rtpPacket struct {
+	PayloadType int
+	Seq         uint16
+	Timestamp   uint32
+	SSRC        uint32
+	Payload     []byte
+}
+
+// parseRTP decodes an RTP packet, skipping CSRCs, the header extension, and
+// padding.
+func parseRTP(b []byte) (rtpPacket, error) {
+	if len(b) < 12 {
+		return rtpPacket{}, errors.New("short RTP packet")
+	}
+	if v := b[0] >> 6; v != 2 {
+		return rtpPacket{}, fmt.Errorf("unsupported RTP version %d", v)
+	}
+	p := rtpPacket{
+		PayloadType: int(b[1] & 0x7F),
+		Seq:         binary.BigEndian.Uint16(b[2:4]),
+		Timestamp:   binary.BigEndian.Uint32(b[4:8]),
+		SSRC:        binary.BigEndian.Uint32(b[8:12]),
+	}
+	start := 12 + 4*int(b[0]&0x0F)
+	if b[0]&0x10 != 0 {
+		if len(b) < start+4 {
+			return rtpPacket{}, errors.New("truncated RTP header extension")
+		}
+		start += 4 + 4*int(binary.BigEndian.Uint16(b[start+2:start+4]))
+	}
+	end := len(b)
+	if b[0]&0x20 != 0 {
+		end -= int(b[len(b)-1])
+	}
+	if start > end {
+		return rtpPacket{}, errors.New("truncated RTP packet")
+	}
+	p.Payload = b[start:end]
+	return p, nil
+}
+
+// callStream is the part of *moonshine.Stream a call uses.
+type callStream interface {
+	Accept(samples []float32, sampleRate int) []moonshine.StreamResult
+	Finish() []moonshine.StreamResult
+	Duration() float64
+	Close()
+}
+
+// openEngineStream opens a stream on the engine's streaming model.
+func openEngineStream(opts moonshine.Options) (callStream, error) {
+	s, err := engine.NewStream(opts)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// rtpEvent is POSTed to RTP_WEBHOOK_URL for each final result of a call and
+// once when the call ends.
+type rtpEvent struct {
+	Event  string `json:"event"` // "transcript" or "end"
+	CallID string `json:"call_id"`
+	SSRC   uint32 `json:"ssrc"`
+	Remote string `json:"remote"` // sender address
+
+	*moonshine.StreamResult         // transcript events
+	DurationS               float64 `json:"duration_s,omitempty"` // end events
+}
+
+// rtpCall is one incoming RTP stream, keyed by sender address and SSRC. The
+// listener goroutine owns the mutable fields; audio is handed to the call's
+// decoder goroutine through audio.
+type rtpCall struct {
+	id          string
+	ssrc        uint32
+	remote      string
+	payloadType int
+	audio       chan []float32
+
+	seq      uint16 // last sequence number accepted
+	next     uint32 // RTP timestamp expected for the next packet
+	lastSeen time.Time
+	dropped  int // packets dropped because the decoder fell behind
+}
+
+// event returns an event of kind name for the call.
+func (c *rtpCall) event(name string) rtpEvent {
+	return rtpEvent{Event: name, CallID: c.id, SSRC: c.ssrc, Remote: c.remote}
+}
+
+// rtpListener receives RTP audio on a UDP socket and transcribes each call
+// with a streaming model as it arrives. A call ends when no packets have
+// arrived for idle.
+type rtpListener struct {
+	conn     net.PacketConn
+	opts     moonshine.Options
+	webhook  string
+	idle     time.Duration
+	maxCalls int
+	open     func(moonshine.Options) (callStream, error)
+	post     func(url string, ev rtpEvent)
+
+	calls map[string]*rtpCall // by sender address and SSRC
+	full  bool                // the call limit was reported
+	wg    sync.WaitGroup      // call decoders
+}
+
+// newRTPListener listens for RTP on the UDP address addr, using the
+// RTP_WEBHOOK_URL, RTP_IDLE_TIMEOUT_S, and RTP_MAX_CALLS settings.
+func newRTPListener(addr string, opts moonshine.Options) (*rtpListener, error) {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &rtpListener{
+		conn:     conn,
+		opts:     opts,
+		webhook:  cfg.RTPWebhookURL,
+		idle:     cfg.RTPIdleTimeout,
+		maxCalls: cfg.RTPMaxCalls,
+		open:     openEngineStream,
+		post:     deliverRTPEvent,
+		calls:    make(map[string]*rtpCall),
+	}, nil
+}
+
+// run reads packets until ctx is done, then ends every call, waits for
+// their final results, and closes the socket.
+func (l *rtpListener) run(ctx context.Context) {
+	defer l.conn.Close() //nolint:errcheck
+	buf := make([]byte, 64*1024)
+	swept := time.Now()
+	for ctx.Err() == nil {
+		l.conn.SetReadDeadline(time.Now().Add(time.Second)) //nolint:errcheck
+		n, addr, err := l.conn.ReadFrom(buf)
+		now := time.Now()
+		switch {
+		case err == nil:
+			l.receive(buf[:n], addr.String(), now)
+		case errors.Is(err, os.ErrDeadlineExceeded):
+		case errors.Is(err, net.ErrClosed):
+			return
+		default:
+			log.Printf("WARNING: rtp: %v", err)
+		}
+		if now.Sub(swept) >= time.Second {
+			l.expire(now)
+			swept = now
+		}
+	}
+	for key, c := range l.calls {
+		l.end(key, c)
+	}
+	l.wg.Wait()
+}
+
+// receive routes one datagram to its call, starting the call on its first
+// packet. Packets that are not RTP audio in a supported format (RTCP,
+// telephone events, other codecs) and late or duplicate packets are
+// ignored; short gaps left by lost packets are filled with silence.
+func (l *rtpListener) receive(b []byte, remote string, now time.Time) {
+	p, err := parseRTP(b)
+	if err != nil {
+		return
+	}
+	f, ok := rtpFormats[p.PayloadType]
+	if !ok {
+		return
+	}
+	key := fmt.Sprintf("%s/%08x", remote, p.SSRC)
+	c := l.calls[key]
+	switch {
+	case c == nil:
+		if c = l.start(key, remote, p); c == nil {
+			return
+		}
+	case p.PayloadType != c.payloadType || int16(p.Seq-c.seq) <= 0:
+		return
+	}
+
+	samples := decodePCMFrames(p.Payload, f)
+	next := p.Timestamp + uint32(len(samples))
+	if gap := int64(int32(p.Timestamp - c.next)); gap > 0 && gap <= int64(rtpMaxGap.Seconds()*float64(f.SampleRate)) {
+		samples = append(make([]float32, gap), samples...)
+	}
+	c.seq, c.next, c.lastSeen = p.Seq, next, now
+	select {
+	case c.audio <- samples:
+	default:
+		c.dropped++
+	}
+}
+
+// start opens a stream for a new call and starts its decoder. It returns
+// nil if the call limit is reached or the stream cannot be opened.
+func (l *rtpListener) start(key, remote string, p rtpPacket) *rtpCall {
+	if len(l.calls) >= l.maxCalls {
+		if !l.full {
+			log.Printf("WARNING: rtp: %d calls in progress, ignoring new calls", len(l.calls))
+			l.full = true
+		}
+		return nil
+	}
+	s, err := l.open(l.opts)
+	if err != nil {
+		log.Printf("WARNING: rtp: %v", err)
+		return nil
+	}
+	c := &rtpCall{
+		id:          uuid.New().String(),
+		ssrc:        p.SSRC,
+		remote:      remote,
+		payloadType: p.PayloadType,
+		audio:       make(chan []float32, rtpQueueSize),
+		seq:         p.Seq - 1,
+		next:        p.Timestamp,
+	}
+	l.calls[key] = c
+	l.wg.Add(1)
+	go l.decode(c, s, rtpFormats[p.PayloadType].SampleRate)
+	log.Printf("rtp call %s started from %s (ssrc %08x)", c.id, remote, p.SSRC)
+	return c
+}
+
+// expire ends the calls that have been silent for l.idle.
+func (l *rtpListener) expire(now time.Time) {
+	for key, c := range l.calls {
+		if now.Sub(c.lastSeen) >= l.idle {
+			l.end(key, c)
+		}
+	}
+}
+
+// end stops feeding a call; its decoder then finishes the stream.
+func (l *rtpListener) end(key string, c *rtpCall) {
+	close(c.audio)
+	delete(l.calls, key)
+	l.full = false
+	if c.dropped > 0 {
+		log.Printf("WARNING: rtp call %s: dropped %d packets while decoding fell behind", c.id, c.dropped)
+	}
+}
+
+// decode runs a call's audio through its stream until the listener closes
+// c.audio, delivering final results as they are decoded.
+func (l *rtpListener) decode(c *rtpCall, s callStream, sampleRate int) {
+	defer l.wg.Done()
+	defer s.Close()
+	for samples := range c.audio {
+		l.deliver(c, s.Accept(samples, sampleRate))
+	}
+	l.deliver(c, s.Finish())
+	ev := c.event("end")
+	ev.DurationS = s.Duration()
+	l.notify(ev)
+	statTranscriptions.Add(1)
+	statAudioSeconds.Add(s.Duration())
+	log.Printf("rtp call %s ended after %.1fs", c.id, s.Duration())
+}
+
+// deliver reports the final results among results.
+func (l *rtpListener) deliver(c *rtpCall, results []moonshine.StreamResult) {
+	for _, r := range results {
+		if !r.Final {
+			continue
+		}
+		log.Printf("rtp call %s [%.1fs-%.1fs]: %s", c.id, r.Start, r.End, r.Text)
+		ev := c.event("transcript")
+		ev.StreamResult = &r
+		l.notify(ev)
+	}
+}
+
+// notify sends ev to the webhook, if one is configured.
+func (l *rtpListener) notify(ev rtpEvent) {
+	if l.webhook != "" {
+		l.post(l.webhook, ev)
+	}
+}
+
+// deliverRTPEvent POSTs ev as JSON to url, signed with CALLBACK_SECRET.
+func deliverRTPEvent(url string, ev rtpEvent) {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		log.Printf("rtp call %s webhook: encode: %v", ev.CallID, err)
+		return
+	}
+	if _, err := postCallback(webhookClient, url, "", cfg.CallbackSecret, body); err != nil {
+		log.Printf("rtp call %s webhook: %v", ev.CallID, err)
+	}
+}
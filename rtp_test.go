@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anatolykoptev/moonshine-whisper/pkg/moonshine"
+)
+
+// rtpBytes builds an RTP packet with the given header fields and payload.
+func rtpBytes(pt int, seq uint16, ts, ssrc uint32, payload []byte) []byte {
+	b := make([]byte, 12, 12+len(payload))
+	b[0] = 0x80
+	b[1] = byte(pt)
+	binary.BigEndian.PutUint16(b[2:4], seq)
+	binary.BigEndian.PutUint32(b[4:8], ts)
+	binary.BigEndian.PutUint32(b[8:12], ssrc)
+	return append(b, payload...)
+}
+
+// fakeCallStream records the samples accepted and finalizes each Accept.
+type fakeCallStream struct {
+	mu       sync.Mutex
+	accepted []int
+	finished bool
+	closed   bool
+}
+
+func (s *fakeCallStream) Accept(samples []float32, sampleRate int) []moonshine.StreamResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.accepted = append(s.accepted, len(samples))
+	return []moonshine.StreamResult{{Text: "partial"}, {Text: "hello", Final: true}}
+}
+
+func (s *fakeCallStream) Finish() []moonshine.StreamResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.finished = true
+	return nil
+}
+
+func (s *fakeCallStream) Duration() float64 { return 1.5 }
+func (s *fakeCallStream) Close()            { s.closed = true }
+
+// --- parseRTP ---
+
+func TestParseRTP(t *testing.T) {
+	p, err := parseRTP(rtpBytes(rtpPayloadPCMA, 7, 160, 0xCAFE, []byte{1, 2, 3}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.PayloadType != rtpPayloadPCMA || p.Seq != 7 || p.Timestamp != 160 || p.SSRC != 0xCAFE || len(p.Payload) != 3 {
+		t.Errorf("parseRTP = %+v", p)
+	}
+}
+
+func TestParseRTP_CSRCExtensionPadding(t *testing.T) {
+	b := rtpBytes(rtpPayloadPCMU|0x80, 1, 0, 1, nil) // marker bit set
+	b[0] |= 0x20 | 0x10 | 0x01                       // padding, extension, one CSRC
+	b = append(b, 0, 0, 0, 9)                        // CSRC
+	b = append(b, 0xBE, 0xDE, 0, 1, 0, 0, 0, 0)      // extension with one word
+	b = append(b, 0xAA, 0xBB, 0, 2)                  // payload + 2 bytes of padding
+	p, err := parseRTP(b)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.PayloadType != rtpPayloadPCMU || len(p.Payload) != 2 || p.Payload[0] != 0xAA {
+		t.Errorf("parseRTP = %+v, want PCMU payload [aa bb]", p)
+	}
+}
+
+func TestParseRTP_Errors(t *testing.T) {
+	ext := rtpBytes(0, 1, 0, 1, nil)
+	ext[0] |= 0x10
+	pad := rtpBytes(0, 1, 0, 1, []byte{0, 9})
+	pad[0] |= 0x20
+	tests := map[string][]byte{
+		"short":     {0x80, 0},
+		"version 1": append([]byte{0x40}, make([]byte, 11)...),
+		"extension": ext,
+		"padding":   pad,
+	}
+	for name, b := range tests {
+		if _, err := parseRTP(b); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+// --- rtpListener ---
+
+func TestRTPListener_Call(t *testing.T) {
+	stream := new(fakeCallStream)
+	var mu sync.Mutex
+	var events []rtpEvent
+	l := &rtpListener{
+		opts:     moonshine.Options{Lang: "en"},
+		webhook:  "http://hooks.example.com/rtp",
+		idle:     time.Second,
+		maxCalls: 1,
+		open:     func(moonshine.Options) (callStream, error) { return stream, nil },
+		post: func(_ string, ev rtpEvent) {
+			mu.Lock()
+			events = append(events, ev)
+			mu.Unlock()
+		},
+		calls: make(map[string]*rtpCall),
+	}
+	now := time.Now()
+	payload := make([]byte, 160) // 20 ms of μ-law
+	l.receive(rtpBytes(rtpPayloadPCMU, 10, 0, 1, payload), "10.0.0.1:4000", now)
+	l.receive(rtpBytes(rtpPayloadPCMU, 10, 0, 1, payload), "10.0.0.1:4000", now)     // duplicate
+	l.receive(rtpBytes(rtpPayloadPCMU, 12, 320, 1, payload), "10.0.0.1:4000", now)   // one packet lost
+	l.receive(rtpBytes(101, 13, 480, 1, []byte{1, 0, 0, 160}), "10.0.0.1:4000", now) // telephone event
+	l.receive(rtpBytes(rtpPayloadPCMU, 1, 0, 2, payload), "10.0.0.2:4000", now)      // over the call limit
+	if len(l.calls) != 1 {
+		t.Fatalf("calls = %d, want 1", len(l.calls))
+	}
+
+	l.expire(now.Add(500 * time.Millisecond))
+	if len(l.calls) != 1 {
+		t.Fatal("call ended before the idle timeout")
+	}
+	l.expire(now.Add(time.Second))
+	l.wg.Wait()
+	if len(l.calls) != 0 {
+		t.Fatal("idle call was not ended")
+	}
+
+	if want := []int{160, 320}; len(stream.accepted) != 2 || stream.accepted[0] != want[0] || stream.accepted[1] != want[1] {
+		t.Errorf("accepted = %v, want %v (lost packet filled with silence)", stream.accepted, want)
+	}
+	if !stream.finished || !stream.closed {
+		t.Error("stream must be finished and closed when the call ends")
+	}
+	if len(events) != 3 || events[0].Event != "transcript" || events[0].Text != "hello" || events[2].Event != "end" {
+		t.Fatalf("events = %+v, want two transcripts and an end", events)
+	}
+	if events[2].DurationS != 1.5 || events[2].SSRC != 1 || events[2].Remote != "10.0.0.1:4000" || events[2].CallID != events[0].CallID {
+		t.Errorf("end event = %+v", events[2])
+	}
+}
+
+func TestRTPEvent_JSON(t *testing.T) {
+	c := &rtpCall{id: "c1", ssrc: 5, remote: "10.0.0.1:4000"}
+	ev := c.event("transcript")
+	ev.StreamResult = &moonshine.StreamResult{Text: "hi", Final: true, End: 1}
+	b, _ := json.Marshal(ev)
+	if want := `{"event":"transcript","call_id":"c1","ssrc":5,"remote":"10.0.0.1:4000","text":"hi","final":true,"start":0,"end":1}`; string(b) != want {
+		t.Errorf("transcript = %s, want %s", b, want)
+	}
+	ev = c.event("end")
+	ev.DurationS = 2
+	b, _ = json.Marshal(ev)
+	if want := `{"event":"end","call_id":"c1","ssrc":5,"remote":"10.0.0.1:4000","duration_s":2}`; string(b) != want {
+		t.Errorf("end = %s, want %s", b, want)
+	}
+}
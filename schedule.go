@@ -0,0 +1,508 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anatolykoptev/moonshine-whisper/pkg/moonshine"
+)
+
+// Scheduled batches: each entry of schedules transcribes, at the times of
+// its cron expression, the audio files in a directory or S3 prefix that have
+// no transcript in the destination yet. A schedule's runs never overlap.
+
+// ScheduleConfig is one scheduled batch.
+type ScheduleConfig struct {
+	Name        string   `yaml:"name"`
+	Cron        string   `yaml:"cron"`        // five fields or @hourly, @daily, @weekly, @monthly; local time
+	Source      string   `yaml:"source"`      // directory or s3://bucket/prefix
+	Destination string   `yaml:"destination"` // directory or s3://bucket/prefix
+	Formats     []string `yaml:"formats"`     // empty = txt, json
+	Language    string   `yaml:"language"`    // empty = en
+}
+
+// scheduleSettle is how long a source file must be unmodified before it is
+// picked up, so files still being copied are skipped.
+const scheduleSettle = time.Minute
+
+// --- cron ---
+
+// cronMacros are the accepted cron shorthands.
+var cronMacros = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+// cronSpec is a parsed cron expression: one bit per allowed value of each
+// field. As in cron, a day matches either day field when both are restricted.
+type cronSpec struct {
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool
+}
+
+// parseCron parses a five-field cron expression (minute, hour, day of month,
+// month, day of week) or one of cronMacros.
+func parseCron(expr string) (*cronSpec, error) {
+	if m, ok := cronMacros[expr]; ok {
+		expr = m
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron %q must have 5 fields", expr)
+	}
+	var s cronSpec
+	var err error
+	for i, f := range []struct {
+		bits   *uint64
+		star   *bool
+		lo, hi int
+		name   string
+	}{
+		{&s.minute, nil, 0, 59, "minute"},
+		{&s.hour, nil, 0, 23, "hour"},
+		{&s.dom, &s.domStar, 1, 31, "day of month"},
+		{&s.month, nil, 1, 12, "month"},
+		{&s.dow, &s.dowStar, 0, 7, "day of week"},
+	} {
+		var star bool
+		if *f.bits, star, err = parseCronField(fields[i], f.lo, f.hi); err != nil {
+			return nil, fmt.Errorf("cron %q %s: %w", expr, f.name, err)
+		}
+		if f.star != nil {
+			*f.star = star
+		}
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1 // 7 is Sunday too
+	}
+	if s.next(time.Now()).IsZero() {
+		return nil, fmt.Errorf("cron %q never matches", expr)
+	}
+	return &s, nil
+}
+
+// parseCronField parses a comma-separated list of *, n, a-b, each with an
+// optional /step, into a bitset of values in [lo, hi]. star reports whether
+// the field starts with *.
+func parseCronField(f string, lo, hi int) (bits uint64, star bool, err error) {
+	for part := range strings.SplitSeq(f, ",") {
+		rng, step := part, 1
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			rng = part[:i]
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step <= 0 {
+				return 0, false, fmt.Errorf("invalid step in %q", part)
+			}
+		}
+		first, last := lo, hi
+		switch {
+		case rng == "*":
+		case strings.Contains(rng, "-"):
+			a, b, _ := strings.Cut(rng, "-")
+			first, err = strconv.Atoi(a)
+			if err == nil {
+				last, err = strconv.Atoi(b)
+			}
+		default:
+			first, err = strconv.Atoi(rng)
+			if err == nil && !strings.Contains(part, "/") {
+				last = first
+			}
+		}
+		if err != nil || first < lo || last > hi || first > last {
+			return 0, false, fmt.Errorf("%q is not a value or range in [%d, %d]", part, lo, hi)
+		}
+		for v := first; v <= last; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, strings.HasPrefix(f, "*"), nil
+}
+
+// next returns the first time after t that s matches, to the minute, or the
+// zero time if there is none within five years.
+func (s *cronSpec) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	for limit := t.AddDate(5, 0, 0); t.Before(limit); {
+		y, m, d := t.Date()
+		switch {
+		case s.month&(1<<int(m)) == 0:
+			t = time.Date(y, m+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.dayMatches(t):
+			t = time.Date(y, m, d+1, 0, 0, 0, 0, t.Location())
+		case s.hour&(1<<t.Hour()) == 0:
+			t = time.Date(y, m, d, t.Hour()+1, 0, 0, 0, t.Location())
+		case s.minute&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// dayMatches reports whether the date of t matches the day fields of s.
+func (s *cronSpec) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<t.Day()) != 0
+	dow := s.dow&(1<<int(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// --- locations ---
+
+// batchLocation is the source or destination of a scheduled batch.
+type batchLocation interface {
+	// list returns the names of the audio files ready to transcribe.
+	list(ctx context.Context) ([]string, error)
+	// fetch returns a local path of name and a function that releases it.
+	fetch(ctx context.Context, name string) (string, func(), error)
+	exists(ctx context.Context, name string) (bool, error)
+	write(ctx context.Context, name string, data []byte) error
+	// uri names name in logs and stored transcripts.
+	uri(name string) string
+}
+
+// openBatchLocation opens target: an s3://bucket/prefix URI or a local
+// directory.
+func openBatchLocation(target string) (batchLocation, error) {
+	if strings.HasPrefix(target, "s3://") {
+		u, err := url.Parse(target)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid S3 URI %q (want s3://bucket/prefix)", target)
+		}
+		return s3Location{bucket: u.Host, prefix: strings.Trim(u.Path, "/")}, nil
+	}
+	return dirLocation(target), nil
+}
+
+// batchAudio reports whether name is an audio file to pick up: not hidden,
+// with one of watchExtensions, and unmodified for scheduleSettle.
+func batchAudio(name string, modTime time.Time) bool {
+	return !strings.HasPrefix(name, ".") && watchExtensions[strings.ToLower(path.Ext(name))] &&
+		time.Since(modTime) >= scheduleSettle
+}
+
+// dirLocation is a local directory; subdirectories are not scanned.
+type dirLocation string
+
+func (d dirLocation) list(context.Context) ([]string, error) {
+	entries, err := os.ReadDir(string(d))
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		if info, err := e.Info(); err == nil && batchAudio(e.Name(), info.ModTime()) {
+			names = append(names, e.Name())
+		}
+	}
+	return names, nil
+}
+
+func (d dirLocation) fetch(_ context.Context, name string) (string, func(), error) {
+	return filepath.Join(string(d), name), func() {}, nil
+}
+
+func (d dirLocation) exists(_ context.Context, name string) (bool, error) {
+	_, err := os.Stat(filepath.Join(string(d), name))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// write renames the file into place so readers never see a partial one.
+func (d dirLocation) write(_ context.Context, name string, data []byte) error {
+	if err := os.MkdirAll(string(d), 0o755); err != nil {
+		return err
+	}
+	dst := filepath.Join(string(d), name)
+	if err := os.WriteFile(dst+".tmp", data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(dst+".tmp", dst)
+}
+
+func (d dirLocation) uri(name string) string { return filepath.Join(string(d), name) }
+
+// s3Location is an S3 key prefix; keys below a further "/" are not scanned.
+type s3Location struct {
+	bucket, prefix string
+}
+
+// key returns the object key of name.
+func (s s3Location) key(name string) string { return path.Join(s.prefix, name) }
+
+func (s s3Location) uri(name string) string { return "s3://" + s.bucket + "/" + s.key(name) }
+
+func (s s3Location) list(ctx context.Context) ([]string, error) {
+	prefix := s.prefix
+	if prefix != "" {
+		prefix += "/"
+	}
+	var names []string
+	token := ""
+	for {
+		q := url.Values{"list-type": {"2"}, "prefix": {prefix}, "delimiter": {"/"}}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		req, err := newS3Request(ctx, http.MethodGet, s.bucket, "", q, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := objectClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Contents []struct {
+				Key          string
+				LastModified time.Time
+			}
+			IsTruncated           bool
+			NextContinuationToken string
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close() //nolint:errcheck
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("s3: list %s: %s", s.uri(""), resp.Status)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("s3: list %s: %w", s.uri(""), err)
+		}
+		for _, obj := range page.Contents {
+			if name := strings.TrimPrefix(obj.Key, prefix); batchAudio(name, obj.LastModified) {
+				names = append(names, name)
+			}
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return names, nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+func (s s3Location) fetch(ctx context.Context, name string) (string, func(), error) {
+	p, _, err := fetchObject(ctx, s.uri(name))
+	if err != nil {
+		return "", nil, err
+	}
+	return p, func() { os.Remove(p) }, nil //nolint:errcheck
+}
+
+func (s s3Location) exists(ctx context.Context, name string) (bool, error) {
+	req, err := newS3Request(ctx, http.MethodHead, s.bucket, s.key(name), nil, nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := objectClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close() //nolint:errcheck
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("s3: head %s: %s", s.uri(name), resp.Status)
+	}
+}
+
+func (s s3Location) write(ctx context.Context, name string, data []byte) error {
+	req, err := newS3Request(ctx, http.MethodPut, s.bucket, s.key(name), nil, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	resp, err := objectClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close() //nolint:errcheck
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("s3: put %s: %s", s.uri(name), resp.Status)
+	}
+	return nil
+}
+
+// --- batchSchedule ---
+
+// batchSchedule runs one ScheduleConfig. A source file is new while the
+// destination has neither its first-format transcript nor its .error file;
+// the first format is written last, so it marks a finished file.
+type batchSchedule struct {
+	name       string
+	spec       *cronSpec
+	src, dst   batchLocation
+	formats    []string
+	opts       moonshine.Options
+	transcribe func(context.Context, string, moonshine.Options) (TranscribeResponse, int)
+}
+
+// newBatchSchedule opens the locations of sc.
+func newBatchSchedule(sc ScheduleConfig) (*batchSchedule, error) {
+	spec, err := parseCron(sc.Cron)
+	if err != nil {
+		return nil, err
+	}
+	src, err := openBatchLocation(sc.Source)
+	if err != nil {
+		return nil, err
+	}
+	dst, err := openBatchLocation(sc.Destination)
+	if err != nil {
+		return nil, err
+	}
+	formats := sc.Formats
+	if len(formats) == 0 {
+		formats = []string{outputText, outputJSON}
+	}
+	lang := sc.Language
+	if lang == "" {
+		lang = "en"
+	}
+	return &batchSchedule{
+		name:       sc.Name,
+		spec:       spec,
+		src:        src,
+		dst:        dst,
+		formats:    formats,
+		opts:       moonshine.Options{Lang: normLang(lang), WaitForLoad: true},
+		transcribe: transcribeFile,
+	}, nil
+}
+
+// run runs the batch at each time of the schedule until ctx is done.
+func (b *batchSchedule) run(ctx context.Context) {
+	for {
+		at := b.spec.next(time.Now())
+		if at.IsZero() {
+			return
+		}
+		t := time.NewTimer(time.Until(at))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-t.C:
+		}
+		b.runOnce(ctx)
+	}
+}
+
+// runOnce transcribes the new files of the source one at a time and returns
+// how many it transcribed. Files that fail to be fetched or stored are left
+// for the next run.
+func (b *batchSchedule) runOnce(ctx context.Context) int {
+	names, err := b.src.list(ctx)
+	if err != nil {
+		log.Printf("WARNING: schedule %s: %v", b.name, err)
+		return 0
+	}
+	start := time.Now()
+	done := 0
+	for _, name := range names {
+		if ctx.Err() != nil {
+			break
+		}
+		base := strings.TrimSuffix(name, path.Ext(name))
+		if b.finished(ctx, base) {
+			continue
+		}
+		if err := b.process(ctx, name, base); err != nil {
+			log.Printf("WARNING: schedule %s: %s: %v", b.name, b.src.uri(name), err)
+			continue
+		}
+		done++
+	}
+	if done > 0 {
+		log.Printf("schedule %s transcribed %d files in %.2fs", b.name, done, time.Since(start).Seconds())
+	}
+	return done
+}
+
+// finished reports whether the destination has a transcript or error for
+// base; lookup errors count as finished so the file is retried next run.
+func (b *batchSchedule) finished(ctx context.Context, base string) bool {
+	for _, name := range []string{base + "." + b.formats[0], base + ".error"} {
+		ok, err := b.dst.exists(ctx, name)
+		if err != nil {
+			log.Printf("WARNING: schedule %s: %v", b.name, err)
+		}
+		if ok || err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// process transcribes one source file and writes its transcripts, or a
+// <base>.error file when it cannot be transcribed.
+func (b *batchSchedule) process(ctx context.Context, name, base string) error {
+	local, release, err := b.src.fetch(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer release()
+	reqCtx, cancel := withRequestTimeout(ctx)
+	opts := b.opts
+	audioID := retainAudio(reqCtx, local, &opts)
+	resp, status := b.transcribe(reqCtx, local, opts)
+	resp.AudioID = audioID
+	cancel()
+	if ctx.Err() != nil {
+		return ctx.Err() // shutting down; retry on the next run
+	}
+	req := TranscribeRequest{AudioPath: b.src.uri(name), Language: b.opts.Lang}
+	if isObjectURI(req.AudioPath) {
+		req.AudioURL, req.AudioPath = req.AudioPath, ""
+	}
+	recordTranscript(ctx, sourceSchedule, b.src.uri(name), req, &resp)
+	if status != http.StatusOK {
+		return b.dst.write(ctx, base+".error", fmt.Appendf(nil, "%d: %s\n", status, resp.Error))
+	}
+	for i := len(b.formats) - 1; i >= 0; i-- {
+		data, err := renderTranscript(b.formats[i], name, resp)
+		if err != nil {
+			return err
+		}
+		if err := b.dst.write(ctx, base+"."+b.formats[i], data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startSchedules starts a goroutine for each configured schedule.
+func startSchedules(ctx context.Context, schedules []ScheduleConfig) error {
+	for _, sc := range schedules {
+		b, err := newBatchSchedule(sc)
+		if err != nil {
+			return fmt.Errorf("schedule %s: %w", sc.Name, err)
+		}
+		go b.run(ctx)
+		log.Printf("Schedule %s: %s -> %s at %q (%s)", sc.Name, sc.Source, sc.Destination, sc.Cron, strings.Join(b.formats, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anatolykoptev/moonshine-whisper/pkg/moonshine"
+)
+
+// --- parseCron ---
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr bool
+	}{
+		{"*/15 * * * *", false},
+		{"0 2 * * 1-5", false},
+		{"30 6,18 1 */2 7", false},
+		{"@daily", false},
+		{"5/10 * * * *", false},
+		{"* * * *", true},
+		{"60 * * * *", true},
+		{"* 24 * * *", true},
+		{"* * 0 * *", true},
+		{"* * * 13 *", true},
+		{"* * * * 8", true},
+		{"*/0 * * * *", true},
+		{"5-1 * * * *", true},
+		{"a * * * *", true},
+		{"0 0 30 2 *", true}, // never matches
+		{"@yearly", true},
+	}
+	for _, tt := range tests {
+		_, err := parseCron(tt.expr)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseCron(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+		}
+	}
+}
+
+// --- cronSpec.next ---
+
+func TestCronSpec_Next(t *testing.T) {
+	from := time.Date(2026, 3, 4, 10, 7, 30, 0, time.UTC) // a Wednesday
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"*/15 * * * *", time.Date(2026, 3, 4, 10, 15, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2026, 3, 4, 11, 0, 0, 0, time.UTC)},
+		{"0 2 * * *", time.Date(2026, 3, 5, 2, 0, 0, 0, time.UTC)},
+		{"0 9 * * 1", time.Date(2026, 3, 9, 9, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2026, 3, 8, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 * *", time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 1 *", time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+		// Both day fields restricted: either matches (the 10th or a Friday).
+		{"0 0 10 * 5", time.Date(2026, 3, 6, 0, 0, 0, 0, time.UTC)},
+		{"5/20 10 * * *", time.Date(2026, 3, 4, 10, 25, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		s, err := parseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("parseCron(%q): %v", tt.expr, err)
+		}
+		if got := s.next(from); !got.Equal(tt.want) {
+			t.Errorf("%q next = %s, want %s", tt.expr, got, tt.want)
+		}
+	}
+}
+
+// --- batchSchedule.runOnce ---
+
+func newTestSchedule(t *testing.T, transcribe func(context.Context, string, moonshine.Options) (TranscribeResponse, int)) (*batchSchedule, string, string) {
+	t.Helper()
+	src, dst := t.TempDir(), filepath.Join(t.TempDir(), "out")
+	b, err := newBatchSchedule(ScheduleConfig{Name: "nightly", Cron: "@daily", Source: src, Destination: dst, Formats: []string{outputText, outputSRT}})
+	if err != nil {
+		t.Fatalf("newBatchSchedule: %v", err)
+	}
+	b.transcribe = transcribe
+	return b, src, dst
+}
+
+func writeScheduleFile(t *testing.T, dir, name string, age time.Duration) {
+	t.Helper()
+	p := filepath.Join(dir, name)
+	if err := os.WriteFile(p, []byte("audio"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mod := time.Now().Add(-age)
+	if err := os.Chtimes(p, mod, mod); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBatchSchedule_RunOnceDir(t *testing.T) {
+	var calls []string
+	b, src, dst := newTestSchedule(t, func(_ context.Context, path string, _ moonshine.Options) (TranscribeResponse, int) {
+		calls = append(calls, filepath.Base(path))
+		if strings.HasPrefix(filepath.Base(path), "bad") {
+			return TranscribeResponse{Error: "unsupported audio"}, http.StatusUnprocessableEntity
+		}
+		return TranscribeResponse{Text: "hello world", AudioS: 2}, http.StatusOK
+	})
+	writeScheduleFile(t, src, "call.wav", time.Hour)
+	writeScheduleFile(t, src, "bad.mp3", time.Hour)
+	writeScheduleFile(t, src, "copying.wav", 0)
+	writeScheduleFile(t, src, "notes.txt", time.Hour)
+
+	if got := b.runOnce(context.Background()); got != 2 {
+		t.Errorf("first run transcribed %d files, want 2", got)
+	}
+	slices.Sort(calls)
+	if !slices.Equal(calls, []string{"bad.mp3", "call.wav"}) {
+		t.Errorf("transcribed %v, want bad.mp3 and call.wav", calls)
+	}
+	txt, err := os.ReadFile(filepath.Join(dst, "call.txt"))
+	if err != nil || string(txt) != "hello world\n" {
+		t.Errorf("call.txt = %q, %v", txt, err)
+	}
+	if srt, err := os.ReadFile(filepath.Join(dst, "call.srt")); err != nil || !strings.Contains(string(srt), "00:00:02,000") {
+		t.Errorf("call.srt = %q, %v", srt, err)
+	}
+	if e, err := os.ReadFile(filepath.Join(dst, "bad.error")); err != nil || !strings.Contains(string(e), "unsupported audio") {
+		t.Errorf("bad.error = %q, %v", e, err)
+	}
+	if _, err := os.Stat(filepath.Join(src, "call.wav")); err != nil {
+		t.Errorf("source file moved: %v", err)
+	}
+
+	calls = nil
+	if got := b.runOnce(context.Background()); got != 0 || len(calls) != 0 {
+		t.Errorf("second run transcribed %v, want nothing", calls)
+	}
+
+	// A missing first-format transcript means the file is not finished.
+	os.Remove(filepath.Join(dst, "call.txt")) //nolint:errcheck
+	if got := b.runOnce(context.Background()); got != 1 {
+		t.Errorf("rerun transcribed %d files, want 1", got)
+	}
+}
+
+func TestBatchSchedule_MissingSource(t *testing.T) {
+	b, _, _ := newTestSchedule(t, nil)
+	b.src = dirLocation(filepath.Join(t.TempDir(), "missing"))
+	if got := b.runOnce(context.Background()); got != 0 {
+		t.Errorf("runOnce = %d, want 0", got)
+	}
+}
+
+// --- s3Location ---
+
+func TestS3Location_ListAndWrite(t *testing.T) {
+	isolateCloudEnv(t)
+	old := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	var queries []string
+	puts := make(map[string]string)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPut:
+			data := make([]byte, r.ContentLength)
+			r.Body.Read(data) //nolint:errcheck
+			puts[r.URL.Path] = string(data)
+			return
+		case http.MethodHead:
+			if _, ok := puts[r.URL.Path]; !ok {
+				http.NotFound(w, r)
+			}
+			return
+		}
+		queries = append(queries, r.URL.RawQuery)
+		if r.URL.Path != "/calls/" || r.URL.Query().Get("prefix") != "in/" || r.URL.Query().Get("delimiter") != "/" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if r.URL.Query().Get("continuation-token") == "" {
+			fmt.Fprintf(w, `<ListBucketResult><IsTruncated>true</IsTruncated><NextContinuationToken>p2</NextContinuationToken>
+<Contents><Key>in/a.wav</Key><LastModified>%s</LastModified></Contents>
+<Contents><Key>in/notes.txt</Key><LastModified>%s</LastModified></Contents></ListBucketResult>`, old, old)
+			return
+		}
+		fmt.Fprintf(w, `<ListBucketResult><IsTruncated>false</IsTruncated>
+<Contents><Key>in/b.mp3</Key><LastModified>%s</LastModified></Contents>
+<Contents><Key>in/new.mp3</Key><LastModified>%s</LastModified></Contents></ListBucketResult>`, old, time.Now().UTC().Format(time.RFC3339))
+	}))
+	defer srv.Close()
+	t.Setenv("AWS_ENDPOINT_URL_S3", srv.URL)
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "SECRET")
+
+	loc, err := openBatchLocation("s3://calls/in/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	names, err := loc.list(context.Background())
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if !slices.Equal(names, []string{"a.wav", "b.mp3"}) {
+		t.Errorf("list = %v, want [a.wav b.mp3]", names)
+	}
+	if len(queries) != 2 || !strings.Contains(queries[0], "list-type=2") {
+		t.Errorf("list queries = %v", queries)
+	}
+
+	out, _ := openBatchLocation("s3://calls/out")
+	if ok, err := out.exists(context.Background(), "a.txt"); ok || err != nil {
+		t.Errorf("exists before write = %v, %v", ok, err)
+	}
+	if err := out.write(context.Background(), "a.txt", []byte("hello\n")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if puts["/calls/out/a.txt"] != "hello\n" {
+		t.Errorf("puts = %v", puts)
+	}
+	if ok, err := out.exists(context.Background(), "a.txt"); !ok || err != nil {
+		t.Errorf("exists after write = %v, %v", ok, err)
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/anatolykoptev/moonshine-whisper/pkg/moonshine"
+)
+
+// Self-test outcomes, of one language and overall.
+const (
+	selftestPass    = "pass"
+	selftestFail    = "fail"
+	selftestSkipped = "skipped" // no reference recording for the language
+)
+
+// selftestTranscripts is the transcript list sherpa-onnx ships beside the
+// test_wavs of some models: one "<file> <text>" line per recording.
+const selftestTranscripts = "trans.txt"
+
+// SelftestLanguage is the self-test outcome of one language.
+type SelftestLanguage struct {
+	Status    string  `json:"status"`
+	Reference string  `json:"reference,omitempty"` // recording checked
+	Text      string  `json:"text,omitempty"`
+	Expected  string  `json:"expected,omitempty"`
+	WER       float64 `json:"wer"` // word error rate of text against expected; characters for Chinese
+	ElapsedS  float64 `json:"elapsed_s"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// SelftestResponse is the body of GET /selftest.
+type SelftestResponse struct {
+	Status    string                      `json:"status"`
+	Languages map[string]SelftestLanguage `json:"languages"`
+}
+
+// handleSelftest handles GET /selftest: it transcribes the reference
+// recording of each loaded language and checks the transcript against the
+// expected one. It answers 503 when any language fails or none was checked.
+func handleSelftest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "GET only")
+		return
+	}
+	e := engineFor(r.Context())
+	var langs []string
+	for _, lang := range e.Languages() {
+		if e.HasLanguage(lang) {
+			langs = append(langs, lang)
+		}
+	}
+	resp := runSelftest(r.Context(), langs, func(ctx context.Context, path, lang string) (string, error) {
+		// The engine directly, so neither the cache nor the statistics see it.
+		res, err := e.TranscribeFile(ctx, path, moonshine.Options{Lang: lang})
+		return res.Text, err
+	})
+	status := http.StatusOK
+	if resp.Status != selftestPass {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, resp)
+}
+
+// runSelftest checks each of langs in turn with transcribe against its
+// reference recording. The overall status fails if any language does, and
+// is skipped if none was checked.
+func runSelftest(ctx context.Context, langs []string, transcribe func(ctx context.Context, path, lang string) (string, error)) SelftestResponse {
+	resp := SelftestResponse{Status: selftestSkipped, Languages: make(map[string]SelftestLanguage, len(langs))}
+	for _, lang := range langs {
+		l := selftestLanguage(ctx, lang, transcribe)
+		if l.Status == selftestFail || resp.Status == selftestSkipped {
+			resp.Status = l.Status
+		}
+		resp.Languages[lang] = l
+	}
+	return resp
+}
+
+// selftestLanguage transcribes the reference recording of lang, see
+// selftestReference, and compares the text with the expected transcript.
+// Without one the language is skipped.
+func selftestLanguage(ctx context.Context, lang string, transcribe func(ctx context.Context, path, lang string) (string, error)) SelftestLanguage {
+	audio, expected, err := selftestReference(lang)
+	if audio == "" && err == nil {
+		return SelftestLanguage{Status: selftestSkipped, Error: "no reference recording in SELFTEST_DIR or the model's test_wavs"}
+	}
+	l := SelftestLanguage{Status: selftestFail, Reference: audio, Expected: expected}
+	if err != nil {
+		l.Error = err.Error()
+		return l
+	}
+	start := time.Now()
+	text, err := transcribe(ctx, audio, lang)
+	l.ElapsedS = roundStat(time.Since(start).Seconds())
+	if err != nil {
+		l.Error = err.Error()
+		return l
+	}
+	l.Text = text
+	if l.Expected == "" {
+		// A bundled recording without a transcript: decoding speech at all
+		// is the check.
+		if len(selftestWords(text)) > 0 {
+			l.Status = selftestPass
+		} else {
+			l.Error = "no speech decoded"
+		}
+		return l
+	}
+	l.WER = roundStat(wordErrorRate(selftestWords(l.Expected), selftestWords(text)))
+	if l.WER <= cfg.SelftestMaxWER {
+		l.Status = selftestPass
+	}
+	return l
+}
+
+// selftestReference returns the reference recording of lang and its
+// expected transcript: <lang>.wav and <lang>.txt of SELFTEST_DIR, or else
+// the first of the test_wavs bundled with the model, as bench uses, with
+// its transcript from a <name>.txt beside it or trans.txt when there is
+// one. audio is "" when lang has neither.
+func selftestReference(lang string) (audio, expected string, err error) {
+	if cfg.SelftestDir != "" {
+		audio = filepath.Join(cfg.SelftestDir, lang+".wav")
+		text, err := os.ReadFile(filepath.Join(cfg.SelftestDir, lang+".txt"))
+		if err == nil {
+			_, err = os.Stat(audio)
+		}
+		if err == nil {
+			return audio, strings.TrimSpace(string(text)), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return audio, "", err
+		}
+	}
+	models.mu.Lock()
+	dir := models.dirs[lang]
+	models.mu.Unlock()
+	wavs := benchReferences(dir)
+	if len(wavs) == 0 {
+		return "", "", nil
+	}
+	audio = wavs[0]
+	name := filepath.Base(audio)
+	stem := strings.TrimSuffix(name, filepath.Ext(name))
+	if text, err := os.ReadFile(strings.TrimSuffix(audio, filepath.Ext(audio)) + ".txt"); err == nil {
+		return audio, strings.TrimSpace(string(text)), nil
+	}
+	list, err := os.ReadFile(filepath.Join(filepath.Dir(audio), selftestTranscripts))
+	if err != nil {
+		return audio, "", nil
+	}
+	for _, line := range strings.Split(string(list), "\n") {
+		line = strings.TrimSpace(line)
+		i := strings.IndexFunc(line, unicode.IsSpace)
+		if i > 0 && (line[:i] == name || line[:i] == stem) {
+			return audio, strings.TrimSpace(line[i:]), nil
+		}
+	}
+	return audio, "", nil
+}
+
+// selftestWords splits s into lowercase words without punctuation, so
+// models that punctuate and case differently compare equal. Han characters
+// count as words of their own, as Chinese has no spaces.
+func selftestWords(s string) []string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			b.WriteRune(' ')
+			b.WriteRune(r)
+			b.WriteRune(' ')
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '\'':
+			b.WriteRune(r)
+		default:
+			b.WriteRune(' ')
+		}
+	}
+	return strings.Fields(b.String())
+}
+
+// wordErrorRate returns the word-level edit distance from ref to hyp per
+// word of ref: 0 for a match, and past 1 when hyp adds many words. An empty
+// ref gives 1 unless hyp is empty too.
+func wordErrorRate(ref, hyp []string) float64 {
+	if len(ref) == 0 {
+		if len(hyp) == 0 {
+			return 0
+		}
+		return 1
+	}
+	prev, cur := make([]int, len(hyp)+1), make([]int, len(hyp)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ref); i++ {
+		cur[0] = i
+		for j := 1; j <= len(hyp); j++ {
+			sub := prev[j-1]
+			if ref[i-1] != hyp[j-1] {
+				sub++
+			}
+			cur[j] = min(sub, prev[j]+1, cur[j-1]+1)
+		}
+		prev, cur = cur, prev
+	}
+	return float64(prev[len(hyp)]) / float64(len(ref))
+}
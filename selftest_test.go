@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// --- selftestWords / wordErrorRate ---
+
+func TestSelftestWords(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"Hello, world!", []string{"hello", "world"}},
+		{"  it's 42 ", []string{"it's", "42"}},
+		{"Привет, мир.", []string{"привет", "мир"}},
+		{"你好世界。", []string{"你", "好", "世", "界"}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := selftestWords(tt.in); !slices.Equal(got, tt.want) {
+			t.Errorf("selftestWords(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestWordErrorRate(t *testing.T) {
+	tests := []struct {
+		ref, hyp string
+		want     float64
+	}{
+		{"the cat sat", "The cat sat.", 0},
+		{"the cat sat", "the bat sat", 1.0 / 3},
+		{"the cat sat", "the cat", 1.0 / 3},
+		{"the cat sat", "the cat sat down", 1.0 / 3},
+		{"the cat", "a dog barked loudly", 2},
+		{"", "", 0},
+		{"", "noise", 1},
+	}
+	for _, tt := range tests {
+		if got := wordErrorRate(selftestWords(tt.ref), selftestWords(tt.hyp)); got != tt.want {
+			t.Errorf("wordErrorRate(%q, %q) = %g, want %g", tt.ref, tt.hyp, got, tt.want)
+		}
+	}
+}
+
+// --- runSelftest ---
+
+func TestRunSelftest(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"en.wav": "audio", "en.txt": "Hello world\n",
+		"ru.wav": "audio", "ru.txt": "привет мир",
+		"zh.wav": "audio", "zh.txt": "你好",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := cfg
+	t.Cleanup(func() { cfg = old })
+	cfg.SelftestDir, cfg.SelftestMaxWER = dir, 0.2
+
+	texts := map[string]string{"en": "Hello, world.", "ru": "пока мир", "de": "hallo"}
+	transcribe := func(_ context.Context, path, lang string) (string, error) {
+		if filepath.Dir(path) != dir {
+			t.Errorf("transcribed %s", path)
+		}
+		if lang == "zh" {
+			return "", errors.New("recognizer failed")
+		}
+		return texts[lang], nil
+	}
+	resp := runSelftest(context.Background(), []string{"en", "ru", "zh", "de"}, transcribe)
+	if resp.Status != selftestFail {
+		t.Errorf("status = %q, want fail", resp.Status)
+	}
+	want := map[string]string{"en": selftestPass, "ru": selftestFail, "zh": selftestFail, "de": selftestSkipped}
+	for lang, status := range want {
+		if got := resp.Languages[lang]; got.Status != status {
+			t.Errorf("%s = %+v, want %s", lang, got, status)
+		}
+	}
+	if en := resp.Languages["en"]; en.Expected != "Hello world" || en.Text != "Hello, world." || en.WER != 0 {
+		t.Errorf("en = %+v", en)
+	}
+	if ru := resp.Languages["ru"]; ru.WER != 0.5 {
+		t.Errorf("ru wer = %g, want 0.5", ru.WER)
+	}
+	if zh := resp.Languages["zh"]; zh.Error != "recognizer failed" {
+		t.Errorf("zh error = %q", zh.Error)
+	}
+
+	if resp := runSelftest(context.Background(), []string{"en"}, transcribe); resp.Status != selftestPass {
+		t.Errorf("en only: status = %q, want pass", resp.Status)
+	}
+	if resp := runSelftest(context.Background(), []string{"de"}, transcribe); resp.Status != selftestSkipped {
+		t.Errorf("de only: status = %q, want skipped", resp.Status)
+	}
+	cfg.SelftestDir = ""
+	if resp := runSelftest(context.Background(), []string{"en"}, transcribe); resp.Languages["en"].Status != selftestSkipped {
+		t.Errorf("without SELFTEST_DIR: %+v", resp.Languages["en"])
+	}
+}
+
+func TestSelftestReference(t *testing.T) {
+	write := func(path, data string) {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	selftestDir, root := t.TempDir(), t.TempDir()
+	write(filepath.Join(selftestDir, "en.wav"), "audio")
+	write(filepath.Join(selftestDir, "en.txt"), "hello world\n")
+	write(filepath.Join(selftestDir, "ru.txt"), "без записи") // no ru.wav: the model's test_wavs
+	write(filepath.Join(root, "ru", "test_wavs", "0.wav"), "audio")
+	write(filepath.Join(root, "ru", "test_wavs", "1.wav"), "audio")
+	write(filepath.Join(root, "ru", "test_wavs", "trans.txt"), "1.wav другой\n0.wav\tпривет мир\n")
+	write(filepath.Join(root, "zh", "test_wavs", "a.wav"), "audio")
+	write(filepath.Join(root, "zh", "test_wavs", "a.txt"), "你好")
+	write(filepath.Join(root, "de", "test_wavs", "a.wav"), "audio")
+	os.MkdirAll(filepath.Join(root, "fr"), 0o755) //nolint:errcheck
+
+	old, oldDirs := cfg, models.dirs
+	t.Cleanup(func() { cfg, models.dirs = old, oldDirs })
+	cfg.SelftestDir = selftestDir
+	models.dirs = map[string]string{}
+	for _, lang := range []string{"en", "ru", "zh", "de", "fr"} {
+		models.dirs[lang] = filepath.Join(root, lang)
+	}
+
+	tests := []struct {
+		lang, audio, expected string
+	}{
+		{"en", filepath.Join(selftestDir, "en.wav"), "hello world"},
+		{"ru", filepath.Join(root, "ru", "test_wavs", "0.wav"), "привет мир"},
+		{"zh", filepath.Join(root, "zh", "test_wavs", "a.wav"), "你好"},
+		{"de", filepath.Join(root, "de", "test_wavs", "a.wav"), ""}, // no transcript
+		{"fr", "", ""},
+		{"ja", "", ""},
+	}
+	for _, tt := range tests {
+		audio, expected, err := selftestReference(tt.lang)
+		if err != nil || audio != tt.audio || expected != tt.expected {
+			t.Errorf("%s: got %q %q %v, want %q %q", tt.lang, audio, expected, err, tt.audio, tt.expected)
+		}
+	}
+
+	transcribe := func(_ context.Context, path, lang string) (string, error) {
+		return map[string]string{"de": "Hallo Welt.", "fr": ""}[lang], nil
+	}
+	if de := selftestLanguage(context.Background(), "de", transcribe); de.Status != selftestPass || de.Reference == "" {
+		t.Errorf("de without a transcript = %+v, want pass", de)
+	}
+	write(filepath.Join(root, "fr", "test_wavs", "a.wav"), "audio")
+	if fr := selftestLanguage(context.Background(), "fr", transcribe); fr.Status != selftestFail {
+		t.Errorf("fr decoding nothing = %+v, want fail", fr)
+	}
+}
+
+// --- handleSelftest ---
+
+func TestHandleSelftest_MethodNotAllowed(t *testing.T) {
+	rec := httptest.NewRecorder()
+	handleSelftest(rec, httptest.NewRequest(http.MethodPost, "/selftest", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("status = %d, want 405", rec.Code)
+	}
+}
+
+func TestHandleSelftest_NothingChecked(t *testing.T) {
+	rec := httptest.NewRecorder()
+	handleSelftest(rec, httptest.NewRequest(http.MethodGet, "/selftest", nil))
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), `"status":"skipped"`) {
+		t.Errorf("no model loaded: %d %s, want 503 skipped", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/anatolykoptev/moonshine-whisper/pkg/moonshine"
+)
+
+// speakerName restricts enrolled speaker names to what fits in a URL path
+// segment without escaping ambiguity.
+var speakerName = regexp.MustCompile(`^[\p{L}\p{N}._-]{1,64}$`)
+
+// Speaker is an enrolled speaker.
+type Speaker struct {
+	Name      string    `json:"name"`
+	Samples   int       `json:"samples"` // clips enrolled
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SpeakerMatch is an enrolled speaker scored against a clip.
+type SpeakerMatch struct {
+	Name  string  `json:"name"`
+	Score float64 `json:"score"` // cosine similarity, -1 to 1
+}
+
+// IdentifySpeakerResponse is the JSON response of POST /identify-speaker.
+type IdentifySpeakerResponse struct {
+	Speakers   []SpeakerMatch `json:"speakers"`        // best first
+	Match      string         `json:"match,omitempty"` // best speaker scoring at least SPEAKER_THRESHOLD
+	DurationMs float64        `json:"duration_ms"`
+}
+
+// speakerRecord is the stored voice print of a speaker: the mean of the
+// embeddings of its enrolled clips.
+type speakerRecord struct {
+	Embedding []float32 `json:"embedding"`
+	Samples   int       `json:"samples"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// speakerStore holds the enrolled speakers of each tenant, saved to a JSON
+// file after every change when it has a path.
+type speakerStore struct {
+	mu      sync.Mutex
+	path    string
+	tenants map[string]map[string]*speakerRecord
+}
+
+// speakers is the speaker store; in memory only unless SPEAKER_DB is set.
+var speakers = &speakerStore{tenants: make(map[string]map[string]*speakerRecord)}
+
+// openSpeakerStore loads the speakers saved at path, which need not exist.
+func openSpeakerStore(path string) (*speakerStore, error) {
+	s := &speakerStore{path: path, tenants: make(map[string]map[string]*speakerRecord)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.tenants); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// save writes the store to its file, replacing it atomically. Callers hold
+// s.mu.
+func (s *speakerStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(s.tenants)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".speakers-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) //nolint:errcheck
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close() //nolint:errcheck
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// enroll adds a clip's embedding to the voice print of name, creating the
+// speaker if needed. A voice print from a model of another dimension is
+// replaced.
+func (s *speakerStore) enroll(tenant, name string, embedding []float32) (Speaker, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	byName := s.tenants[tenant]
+	if byName == nil {
+		byName = make(map[string]*speakerRecord)
+		s.tenants[tenant] = byName
+	}
+	rec := byName[name]
+	if rec == nil || len(rec.Embedding) != len(embedding) {
+		rec = &speakerRecord{Embedding: make([]float32, len(embedding))}
+		byName[name] = rec
+	}
+	n := float32(rec.Samples)
+	for i, x := range embedding {
+		rec.Embedding[i] = (rec.Embedding[i]*n + x) / (n + 1)
+	}
+	rec.Samples++
+	rec.UpdatedAt = time.Now().UTC()
+	return Speaker{Name: name, Samples: rec.Samples, UpdatedAt: rec.UpdatedAt}, s.save()
+}
+
+// remove deletes a speaker and reports whether it was enrolled.
+func (s *speakerStore) remove(tenant, name string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.tenants[tenant][name]; !ok {
+		return false, nil
+	}
+	delete(s.tenants[tenant], name)
+	return true, s.save()
+}
+
+// list returns the speakers of tenant sorted by name.
+func (s *speakerStore) list(tenant string) []Speaker {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]Speaker, 0, len(s.tenants[tenant]))
+	for name, rec := range s.tenants[tenant] {
+		list = append(list, Speaker{Name: name, Samples: rec.Samples, UpdatedAt: rec.UpdatedAt})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// identify scores every speaker of tenant against embedding, best first.
+func (s *speakerStore) identify(tenant string, embedding []float32) []SpeakerMatch {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	matches := make([]SpeakerMatch, 0, len(s.tenants[tenant]))
+	for name, rec := range s.tenants[tenant] {
+		matches = append(matches, SpeakerMatch{Name: name, Score: moonshine.CosineSimilarity(rec.Embedding, embedding)})
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].Name < matches[j].Name
+	})
+	return matches
+}
+
+// handleSpeakers handles GET /speakers: the enrolled speakers.
+func handleSpeakers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "GET only")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"speakers": speakers.list(tenantName(r.Context()))})
+}
+
+// handleSpeaker handles POST /speakers/{name}, which enrolls the audio of a
+// /transcribe JSON body or an "audio" multipart upload as a clip of the
+// speaker, and DELETE /speakers/{name}.
+func handleSpeaker(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if r.Method == http.MethodDelete {
+		deleteSpeaker(w, r, name)
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "POST or DELETE only")
+		return
+	}
+	if !speakerName.MatchString(name) {
+		writeError(w, http.StatusBadRequest, "speaker name must be 1-64 letters, digits, '.', '_', or '-'")
+		return
+	}
+	ctx, req, audioPath, done, ok := analysisAudio(w, r)
+	if !ok {
+		return
+	}
+	defer done()
+	embedding, err := engineFor(ctx).SpeakerEmbedding(ctx, audioPath, requestOptions(ctx, req))
+	if err != nil {
+		resp, status := engineError(ctx, err)
+		writeJSON(w, status, resp)
+		return
+	}
+	sp, err := speakers.enroll(tenantName(ctx), name, embedding)
+	if err != nil {
+		log.Printf("WARNING: save speakers: %v", err)
+		writeError(w, http.StatusInternalServerError, "save failed")
+		return
+	}
+	writeJSON(w, http.StatusOK, sp)
+}
+
+// deleteSpeaker removes an enrolled speaker.
+func deleteSpeaker(w http.ResponseWriter, r *http.Request, name string) {
+	ok, err := speakers.remove(tenantName(r.Context()), name)
+	if err != nil {
+		log.Printf("WARNING: save speakers: %v", err)
+		writeError(w, http.StatusInternalServerError, "save failed")
+		return
+	}
+	if !ok {
+		writeError(w, http.StatusNotFound, "speaker not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleIdentifySpeaker handles POST /identify-speaker: the enrolled
+// speakers most similar to the voice in the audio of a /transcribe JSON
+// body or an "audio" multipart upload. The top_k query parameter bounds
+// how many are returned.
+func handleIdentifySpeaker(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "POST only")
+		return
+	}
+	topK := 0 // all
+	if v := r.URL.Query().Get("top_k"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeError(w, http.StatusBadRequest, "top_k must be >= 1")
+			return
+		}
+		topK = n
+	}
+	start := time.Now()
+	ctx, req, audioPath, done, ok := analysisAudio(w, r)
+	if !ok {
+		return
+	}
+	defer done()
+	embedding, err := engineFor(ctx).SpeakerEmbedding(ctx, audioPath, requestOptions(ctx, req))
+	if err != nil {
+		resp, status := engineError(ctx, err)
+		writeJSON(w, status, resp)
+		return
+	}
+	resp := IdentifySpeakerResponse{Speakers: speakers.identify(tenantName(ctx), embedding)}
+	if len(resp.Speakers) > 0 && resp.Speakers[0].Score >= cfg.SpeakerThreshold {
+		resp.Match = resp.Speakers[0].Name
+	}
+	if topK > 0 && len(resp.Speakers) > topK {
+		resp.Speakers = resp.Speakers[:topK]
+	}
+	resp.DurationMs = float64(time.Since(start).Milliseconds())
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// --- speakerStore ---
+
+func TestSpeakerStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "speakers.json")
+	s, err := openSpeakerStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.enroll("", "alice", []float32{1, 0}); err != nil {
+		t.Fatal(err)
+	}
+	sp, err := s.enroll("", "alice", []float32{0, 1})
+	if err != nil || sp.Samples != 2 {
+		t.Fatalf("second enroll = %+v, %v; want 2 samples", sp, err)
+	}
+	if _, err := s.enroll("", "bob", []float32{-1, 0}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.enroll("acme", "carol", []float32{1, 0}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reopen to check the file round trip.
+	if s, err = openSpeakerStore(path); err != nil {
+		t.Fatal(err)
+	}
+	matches := s.identify("", []float32{1, 1})
+	if len(matches) != 2 || matches[0].Name != "alice" || matches[0].Score < 0.99 || matches[1].Name != "bob" {
+		t.Errorf("identify = %+v, want alice (~1) then bob", matches)
+	}
+	if list := s.list("acme"); len(list) != 1 || list[0].Name != "carol" {
+		t.Errorf("tenant acme speakers = %+v, want [carol]", list)
+	}
+	if ok, err := s.remove("", "bob"); !ok || err != nil {
+		t.Errorf("remove bob = %v, %v", ok, err)
+	}
+	if ok, _ := s.remove("", "bob"); ok {
+		t.Error("removing bob twice succeeded")
+	}
+	if list := s.list(""); len(list) != 1 || list[0].Name != "alice" {
+		t.Errorf("speakers after remove = %+v, want [alice]", list)
+	}
+}
+
+// --- handleSpeaker ---
+
+func TestHandleSpeaker(t *testing.T) {
+	old := speakers
+	speakers = &speakerStore{tenants: make(map[string]map[string]*speakerRecord)}
+	t.Cleanup(func() { speakers = old })
+	path := filepath.Join(t.TempDir(), "clip.wav")
+	if err := os.WriteFile(path, silentWav(16000, 16000), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	body := `{"audio_path":"` + path + `"}`
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		want   int
+	}{
+		{"method", http.MethodGet, "/speakers/alice", "", http.StatusMethodNotAllowed},
+		{"bad name", http.MethodPost, "/speakers/a%20b", body, http.StatusBadRequest},
+		{"no source", http.MethodPost, "/speakers/alice", `{}`, http.StatusBadRequest},
+		{"model not loaded", http.MethodPost, "/speakers/alice", body, http.StatusServiceUnavailable},
+		{"delete unknown", http.MethodDelete, "/speakers/alice", "", http.StatusNotFound},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/speakers/{name}", handleSpeaker)
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body)))
+		if rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d (%s)", tt.name, rec.Code, tt.want, rec.Body)
+		}
+	}
+
+	speakers.enroll("", "alice", []float32{1, 0}) //nolint:errcheck
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/speakers/alice", nil))
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("delete: status %d, want 204", rec.Code)
+	}
+}
+
+// --- handleIdentifySpeaker ---
+
+func TestHandleIdentifySpeaker(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "clip.wav")
+	if err := os.WriteFile(path, silentWav(16000, 16000), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		want   int
+	}{
+		{"method", http.MethodGet, "/identify-speaker", "", http.StatusMethodNotAllowed},
+		{"top_k zero", http.MethodPost, "/identify-speaker?top_k=0", `{"audio_path":"` + path + `"}`, http.StatusBadRequest},
+		{"model not loaded", http.MethodPost, "/identify-speaker", `{"audio_path":"` + path + `"}`, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handleIdentifySpeaker(rec, httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body)))
+		if rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d (%s)", tt.name, rec.Code, tt.want, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/anatolykoptev/moonshine-whisper/pkg/moonshine"
+)
+
+// statsLatencyWindow is how many of the latest transcription latencies the
+// percentiles of /admin/stats are computed over.
+const statsLatencyWindow = 1000
+
+// stats accumulates the runtime statistics served on /admin/stats.
+var stats = newRuntimeStats(time.Now())
+
+type runtimeStats struct {
+	mu      sync.Mutex
+	started time.Time
+
+	requests int64
+	byStatus map[int]int64
+
+	transcriptions int64
+	failed         int64
+	cached         int64
+	byLanguage     map[string]int64
+	filtered       int64 // transcriptions with text suppressed as a hallucination
+
+	audioS      float64
+	processingS float64
+	vadCount    int64   // transcriptions with speech measured by VAD
+	vadAudioS   float64 // their audio
+	vadSpeechS  float64 // the speech found in it
+
+	latencies []float64 // ms, a ring of the latest statsLatencyWindow
+	next      int
+}
+
+func newRuntimeStats(now time.Time) *runtimeStats {
+	return &runtimeStats{started: now, byStatus: map[int]int64{}, byLanguage: map[string]int64{}}
+}
+
+// recordRequest counts an HTTP request answered with status.
+func (s *runtimeStats) recordRequest(status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+	s.byStatus[status]++
+}
+
+// recordTranscription counts a successful transcription that took elapsed.
+func (s *runtimeStats) recordTranscription(res moonshine.Result, elapsed time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.transcriptions++
+	s.byLanguage[res.Language]++
+	if res.Filtered {
+		s.filtered++
+	}
+	s.audioS += res.AudioS
+	s.processingS += elapsed.Seconds()
+	if res.SpeechMs > 0 {
+		s.vadCount++
+		s.vadAudioS += res.AudioS
+		s.vadSpeechS += res.SpeechMs / 1000
+	}
+	ms := float64(elapsed.Microseconds()) / 1000
+	if len(s.latencies) < statsLatencyWindow {
+		s.latencies = append(s.latencies, ms)
+		return
+	}
+	s.latencies[s.next] = ms
+	s.next = (s.next + 1) % statsLatencyWindow
+}
+
+// recordFailure counts a transcription that failed.
+func (s *runtimeStats) recordFailure() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failed++
+}
+
+// recordCacheHit counts a transcription served from the transcript cache.
+func (s *runtimeStats) recordCacheHit() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cached++
+}
+
+// statsReport is the /admin/stats response.
+type statsReport struct {
+	StartedAt time.Time `json:"started_at"`
+	UptimeS   float64   `json:"uptime_s"`
+
+	Requests struct {
+		Total    int64            `json:"total"`
+		ByStatus map[string]int64 `json:"by_status"`
+	} `json:"requests"`
+
+	Transcriptions struct {
+		Total      int64            `json:"total"`
+		Failed     int64            `json:"failed"`
+		Cached     int64            `json:"cached"`
+		ByLanguage map[string]int64 `json:"by_language"`
+	} `json:"transcriptions"`
+
+	LatencyMs struct {
+		Avg float64 `json:"avg"`
+		P50 float64 `json:"p50"`
+		P90 float64 `json:"p90"`
+		P99 float64 `json:"p99"`
+	} `json:"latency_ms"`
+
+	AudioS         float64 `json:"audio_s"`
+	RealTimeFactor float64 `json:"real_time_factor"` // processing time per second of audio
+
+	VAD struct {
+		Transcriptions int64   `json:"transcriptions"`
+		SpeechS        float64 `json:"speech_s"`
+		SpeechRatio    float64 `json:"speech_ratio"`
+	} `json:"vad"`
+
+	HallucinationsFiltered int64 `json:"hallucinations_filtered"`
+}
+
+// report returns the statistics gathered until now.
+func (s *runtimeStats) report(now time.Time) statsReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var r statsReport
+	r.StartedAt = s.started.UTC()
+	r.UptimeS = now.Sub(s.started).Seconds()
+	r.Requests.Total = s.requests
+	r.Requests.ByStatus = make(map[string]int64, len(s.byStatus))
+	for status, n := range s.byStatus {
+		r.Requests.ByStatus[strconv.Itoa(status)] = n
+	}
+	r.Transcriptions.Total = s.transcriptions
+	r.Transcriptions.Failed = s.failed
+	r.Transcriptions.Cached = s.cached
+	r.Transcriptions.ByLanguage = make(map[string]int64, len(s.byLanguage))
+	for lang, n := range s.byLanguage {
+		r.Transcriptions.ByLanguage[lang] = n
+	}
+	if s.transcriptions > 0 {
+		r.LatencyMs.Avg = roundStat(s.processingS * 1000 / float64(s.transcriptions))
+	}
+	sorted := slices.Sorted(slices.Values(s.latencies))
+	r.LatencyMs.P50 = percentile(sorted, 50)
+	r.LatencyMs.P90 = percentile(sorted, 90)
+	r.LatencyMs.P99 = percentile(sorted, 99)
+	r.AudioS = roundStat(s.audioS)
+	if s.audioS > 0 {
+		r.RealTimeFactor = roundStat(s.processingS / s.audioS)
+	}
+	r.VAD.Transcriptions = s.vadCount
+	r.VAD.SpeechS = roundStat(s.vadSpeechS)
+	if s.vadAudioS > 0 {
+		r.VAD.SpeechRatio = roundStat(s.vadSpeechS / s.vadAudioS)
+	}
+	r.HallucinationsFiltered = s.filtered
+	return r
+}
+
+// percentile returns the nearest-rank pth percentile of sorted, or 0 when
+// it is empty.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return roundStat(sorted[max(rank, 1)-1])
+}
+
+// roundStat rounds v to three decimals.
+func roundStat(v float64) float64 { return math.Round(v*1000) / 1000 }
+
+// statsMiddleware counts the requests next answers, by status.
+func statsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+		stats.recordRequest(sw.status)
+	})
+}
+
+// handleStats handles GET /admin/stats.
+func handleStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "GET only")
+		return
+	}
+	writeJSON(w, http.StatusOK, stats.report(time.Now()))
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/anatolykoptev/moonshine-whisper/pkg/moonshine"
+)
+
+// --- runtimeStats ---
+
+func TestRuntimeStats(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := newRuntimeStats(start)
+	s.recordRequest(http.StatusOK)
+	s.recordRequest(http.StatusOK)
+	s.recordRequest(http.StatusBadRequest)
+	s.recordTranscription(moonshine.Result{Language: "en", AudioS: 10, SpeechMs: 6000}, time.Second)
+	s.recordTranscription(moonshine.Result{Language: "ru", AudioS: 30, Filtered: true}, 3*time.Second)
+	s.recordFailure()
+	s.recordCacheHit()
+
+	r := s.report(start.Add(time.Minute))
+	if r.UptimeS != 60 || r.Requests.Total != 3 || r.Requests.ByStatus["200"] != 2 || r.Requests.ByStatus["400"] != 1 {
+		t.Errorf("requests = %+v, uptime %g", r.Requests, r.UptimeS)
+	}
+	tr := r.Transcriptions
+	if tr.Total != 2 || tr.Failed != 1 || tr.Cached != 1 || tr.ByLanguage["en"] != 1 || tr.ByLanguage["ru"] != 1 {
+		t.Errorf("transcriptions = %+v", tr)
+	}
+	if r.LatencyMs.Avg != 2000 || r.LatencyMs.P50 != 1000 || r.LatencyMs.P99 != 3000 {
+		t.Errorf("latency = %+v", r.LatencyMs)
+	}
+	if r.AudioS != 40 || r.RealTimeFactor != 0.1 {
+		t.Errorf("audio_s = %g, real_time_factor = %g", r.AudioS, r.RealTimeFactor)
+	}
+	if r.VAD.Transcriptions != 1 || r.VAD.SpeechS != 6 || r.VAD.SpeechRatio != 0.6 {
+		t.Errorf("vad = %+v", r.VAD)
+	}
+	if r.HallucinationsFiltered != 1 {
+		t.Errorf("hallucinations_filtered = %d, want 1", r.HallucinationsFiltered)
+	}
+}
+
+func TestRuntimeStats_LatencyWindow(t *testing.T) {
+	s := newRuntimeStats(time.Now())
+	for i := range statsLatencyWindow + 10 {
+		s.recordTranscription(moonshine.Result{}, time.Duration(i)*time.Millisecond)
+	}
+	if len(s.latencies) != statsLatencyWindow {
+		t.Fatalf("kept %d latencies, want %d", len(s.latencies), statsLatencyWindow)
+	}
+	// The 10 oldest (0-9 ms) were overwritten.
+	if r := s.report(time.Now()); r.LatencyMs.P50 != 509 {
+		t.Errorf("p50 = %g, want 509", r.LatencyMs.P50)
+	}
+}
+
+// --- percentile ---
+
+func TestPercentile(t *testing.T) {
+	sorted := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	tests := []struct {
+		p    float64
+		want float64
+	}{
+		{50, 5},
+		{90, 9},
+		{99, 10},
+		{0, 1},
+	}
+	for _, tt := range tests {
+		if got := percentile(sorted, tt.p); got != tt.want {
+			t.Errorf("percentile(%g) = %g, want %g", tt.p, got, tt.want)
+		}
+	}
+	if got := percentile(nil, 50); got != 0 {
+		t.Errorf("percentile(nil) = %g, want 0", got)
+	}
+}
+
+// --- handleStats ---
+
+func TestHandleStats(t *testing.T) {
+	h := statsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusTeapot, "no")
+	}))
+	before := stats.report(time.Now()).Requests.ByStatus["418"]
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	rec := httptest.NewRecorder()
+	handleStats(rec, httptest.NewRequest(http.MethodGet, "/admin/stats", nil))
+	var got statsReport
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("GET /admin/stats = %d, %v", rec.Code, err)
+	}
+	if got.Requests.ByStatus["418"] != before+1 {
+		t.Errorf("by_status[418] = %d, want %d", got.Requests.ByStatus["418"], before+1)
+	}
+
+	rec = httptest.NewRecorder()
+	handleStats(rec, httptest.NewRequest(http.MethodPost, "/admin/stats", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST status = %d, want 405", rec.Code)
+	}
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anatolykoptev/moonshine-whisper/pkg/moonshine"
+)
+
+// transcodedPCM is the format startTranscoder produces.
+var transcodedPCM = pcmFormat{SampleRate: 16000, Channels: 1, Encoding: pcmL16}
+
+// isContainerStream reports whether ct is a streamable container that
+// needs ffmpeg to decode: WebM or Ogg, typically Opus from a browser.
+func isContainerStream(ct string) bool {
+	mt, _, err := mime.ParseMediaType(ct)
+	return err == nil && (mt == "audio/webm" || mt == "video/webm" || mt == "audio/ogg" || mt == "audio/opus")
+}
+
+// transcoder is an ffmpeg process decoding a container stream to
+// transcodedPCM as it arrives.
+type transcoder struct {
+	cmd    *exec.Cmd
+	out    io.ReadCloser
+	stderr strings.Builder
+}
+
+// startTranscoder starts ffmpeg reading from in. ffmpeg is killed when ctx
+// is done.
+func startTranscoder(ctx context.Context, in io.Reader) (*transcoder, error) {
+	if _, err := exec.LookPath(cfg.FFmpegPath); err != nil {
+		return nil, fmt.Errorf("ffmpeg not found at %q; install it or set FFMPEG_PATH", cfg.FFmpegPath)
+	}
+	args := slices.Concat(cfg.FFmpegArgs, []string{"-loglevel", "error",
+		"-i", "pipe:0", "-f", "s16le", "-ac", "1", "-ar", "16000", "pipe:1"})
+	t := &transcoder{cmd: exec.CommandContext(ctx, cfg.FFmpegPath, args...)}
+	t.cmd.Stdin = in
+	t.cmd.Stderr = &t.stderr
+	t.cmd.WaitDelay = time.Second
+	out, err := t.cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	t.out = out
+	if err := t.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("ffmpeg: %w", err)
+	}
+	return t, nil
+}
+
+// Read reads decoded PCM. At the end of the output it returns io.EOF if
+// ffmpeg succeeded, or its error.
+func (t *transcoder) Read(p []byte) (int, error) {
+	n, err := t.out.Read(p)
+	if err == io.EOF {
+		if werr := t.cmd.Wait(); werr != nil {
+			return n, fmt.Errorf("ffmpeg: %v %s", werr, strings.TrimSpace(t.stderr.String()))
+		}
+	}
+	return n, err
+}
+
+// Close stops ffmpeg if it is still running.
+func (t *transcoder) Close() error {
+	if t.cmd.ProcessState == nil {
+		t.cmd.Process.Kill() //nolint:errcheck
+		t.cmd.Wait()         //nolint:errcheck
+	}
+	return nil
+}
+
+// handleStream handles POST /transcribe/stream: a raw audio body (see
+// parsePCMContentType), or a WebM or Ogg stream such as a browser's Opus
+// MediaRecorder output, sent as it is captured and answered with
+// newline-delimited JSON results as soon as they are decoded. Options
+// (language, punctuate, two_pass) are query parameters. Errors after the
+// response has started are sent as a final {"error": ...} line. The
+// endpointing rules (endpoint_silence_s, endpoint_idle_s,
+// endpoint_max_utterance_s) are query parameters too. A GET that upgrades
+// to a WebSocket streams the same way; see handleStreamWebSocket.
+func handleStream(w http.ResponseWriter, r *http.Request) {
+	if isWebSocketUpgrade(r) {
+		handleStreamWebSocket(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "POST only, or GET with a WebSocket upgrade")
+		return
+	}
+	stream, f, container, ok := openStream(w, r, r.Header.Get("Content-Type"))
+	if !ok {
+		return
+	}
+	defer stream.Close()
+
+	ctx, cancel := withRequestTimeout(r.Context())
+	defer cancel()
+	// Live audio outlasts the server's read and write timeouts; the request
+	// timeout bounds the stream instead, interrupting a pending body read.
+	rc := http.NewResponseController(w)
+	rc.EnableFullDuplex()            //nolint:errcheck
+	rc.SetReadDeadline(time.Time{})  //nolint:errcheck
+	rc.SetWriteDeadline(time.Time{}) //nolint:errcheck
+	stop := context.AfterFunc(ctx, func() {
+		rc.SetReadDeadline(time.Now()) //nolint:errcheck
+	})
+	defer stop()
+
+	var body io.Reader = r.Body
+	if container {
+		t, err := startTranscoder(ctx, r.Body)
+		if err != nil {
+			writeError(w, http.StatusServiceUnavailable, err.Error())
+			return
+		}
+		defer t.Close() //nolint:errcheck
+		body = t
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	rc.Flush() //nolint:errcheck
+	enc := json.NewEncoder(w)
+	pumpStream(ctx, stream, f, body, func(v any) {
+		enc.Encode(v) //nolint:errcheck
+		rc.Flush()    //nolint:errcheck
+	})
+}
+
+// handleStreamWebSocket handles a WebSocket on /transcribe/stream, for
+// browsers, which cannot stream a request body while reading the response.
+// The client sends audio as binary messages, in the content type of the
+// content_type query parameter (audio/webm by default), and the text
+// message "end" when it stops recording; each result is a JSON text
+// message, and an error the last one before the socket closes. Browsers
+// cannot set headers on a WebSocket, so the API key may be the api_key
+// query parameter.
+func handleStreamWebSocket(w http.ResponseWriter, r *http.Request) {
+	if !webSocketOriginAllowed(r) {
+		writeError(w, http.StatusForbidden, "origin not allowed")
+		return
+	}
+	ct := r.URL.Query().Get("content_type")
+	if ct == "" {
+		ct = "audio/webm"
+	}
+	stream, f, container, ok := openStream(w, r, ct)
+	if !ok {
+		return
+	}
+	defer stream.Close()
+	conn, ok := acceptWebSocket(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := withRequestTimeout(r.Context())
+	defer cancel()
+	stop := context.AfterFunc(ctx, func() {
+		conn.conn.SetReadDeadline(time.Now()) //nolint:errcheck
+	})
+	defer stop()
+
+	var body io.Reader = conn
+	var t *transcoder
+	if container {
+		var err error
+		if t, err = startTranscoder(ctx, conn); err != nil {
+			conn.writeJSON(map[string]string{"error": err.Error()}) //nolint:errcheck
+			conn.shutdown(wsCloseInternalError, "")
+			return
+		}
+		body = t
+	}
+	err := pumpStream(ctx, stream, f, body, func(v any) {
+		conn.writeJSON(v) //nolint:errcheck
+	})
+	if t != nil {
+		t.Close() //nolint:errcheck // before shutdown, which takes over reading
+	}
+	// ffmpeg sees the end of its input for a protocol error too.
+	var cerr *wsCloseError
+	switch {
+	case errors.As(err, &cerr), errors.As(conn.readErr(), &cerr):
+		conn.shutdown(cerr.Code, cerr.Msg)
+	case err != nil:
+		conn.shutdown(wsCloseInternalError, "")
+	default:
+		conn.shutdown(wsCloseNormal, "")
+	}
+}
+
+// openStream validates a stream request, whose audio has content type ct,
+// and opens a decoder stream for it, in the PCM format f, transcoded first
+// if container. On failure it writes the error response and returns ok
+// false.
+func openStream(w http.ResponseWriter, r *http.Request, ct string) (stream *moonshine.Stream, f pcmFormat, container, ok bool) {
+	container = isContainerStream(ct)
+	f, err := parsePCMContentType(ct)
+	if container {
+		f, err = transcodedPCM, nil
+	}
+	if err != nil {
+		writeError(w, http.StatusUnsupportedMediaType, err.Error())
+		return nil, f, false, false
+	}
+	q := r.URL.Query()
+	req := requestFromValues(q.Get)
+	if msg := validateOptions(req); msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return nil, f, false, false
+	}
+	if req.Task == moonshine.TaskTranslate {
+		writeError(w, http.StatusBadRequest, "task translate is not supported for streams")
+		return nil, f, false, false
+	}
+	if req.Denoise || req.Normalize || req.TrimSilence || req.AudioTrack > 0 || req.Channel != "" || req.DTMF || req.SkipMusic {
+		writeError(w, http.StatusBadRequest, "denoise, normalize, trim_silence, dtmf, skip_music, audio_track, and channel are not supported for streams")
+		return nil, f, false, false
+	}
+	if msg := validateTenant(r.Context(), req); msg != "" {
+		writeError(w, http.StatusForbidden, msg)
+		return nil, f, false, false
+	}
+	opts := requestOptions(r.Context(), req)
+	opts.TwoPass = parseBoolPtr(q.Get("two_pass"))
+	if msg := parseEndpointing(q, &opts); msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return nil, f, false, false
+	}
+	stream, err = engineFor(r.Context()).NewStream(opts)
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return nil, f, false, false
+	}
+	return stream, f, container, true
+}
+
+// pumpStream feeds the PCM of body, in format f, to stream and sends each
+// result as it is decoded, then those of the end of the audio, or a final
+// {"error": ...} message. It returns the error that ended the audio early.
+func pumpStream(ctx context.Context, stream *moonshine.Stream, f pcmFormat, body io.Reader, send func(any)) error {
+	maxDuration := maxAudioDuration(ctx)
+	maxFrames := int(maxDuration * float64(f.SampleRate))
+	frames := 0
+	err := readPCM(body, f, func(samples []float32) error {
+		if frames += len(samples); frames > maxFrames {
+			return errPCMTooLong
+		}
+		for _, res := range stream.Accept(samples, f.SampleRate) {
+			send(res)
+		}
+		return nil
+	})
+	switch {
+	case ctx.Err() != nil:
+		resp, _ := contextError(ctx)
+		send(map[string]string{"error": resp.Error})
+		return ctx.Err()
+	case errors.Is(err, errPCMTooLong):
+		send(map[string]string{"error": fmt.Sprintf("audio too long: > max %.0fs", maxDuration)})
+	case err != nil:
+		send(map[string]string{"error": "read body: " + err.Error()})
+	default:
+		for _, res := range stream.Finish() {
+			send(res)
+		}
+		statTranscriptions.Add(1)
+		statAudioSeconds.Add(stream.Duration())
+	}
+	return err
+}
+
+// parseEndpointing sets the endpointing options of a stream from the query
+// and returns a message for an invalid value. Unset ones keep the defaults.
+func parseEndpointing(q url.Values, opts *moonshine.Options) string {
+	for _, p := range []struct {
+		key string
+		dst *float64
+	}{
+		{"endpoint_silence_s", &opts.EndpointSilenceS},
+		{"endpoint_idle_s", &opts.EndpointIdleS},
+		{"endpoint_max_utterance_s", &opts.EndpointMaxUtteranceS},
+	} {
+		v := q.Get(p.key)
+		if v == "" {
+			continue
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || !(f > 0) || math.IsInf(f, 0) {
+			return p.key + " must be a positive number of seconds"
+		}
+		*p.dst = f
+	}
+	return ""
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/anatolykoptev/moonshine-whisper/pkg/client"
+	"github.com/anatolykoptev/moonshine-whisper/pkg/moonshine"
+)
+
+// --- handleStream ---
+
+func TestHandleStream_Rejects(t *testing.T) {
+	tests := []struct {
+		method, ct, query string
+		want              int
+	}{
+		{http.MethodGet, "audio/l16", "", http.StatusMethodNotAllowed},
+		{http.MethodPost, "audio/wav", "", http.StatusUnsupportedMediaType},
+		{http.MethodPost, "audio/l16;rate=16000", "", http.StatusServiceUnavailable}, // no streaming model loaded
+		{http.MethodPost, "audio/webm;codecs=opus", "", http.StatusServiceUnavailable},
+		{http.MethodPost, "audio/l16;rate=16000", "?task=translate", http.StatusBadRequest},
+		{http.MethodPost, "audio/l16;rate=16000", "?denoise=true", http.StatusBadRequest},
+		{http.MethodPost, "audio/l16;rate=16000", "?normalize=true", http.StatusBadRequest},
+		{http.MethodPost, "audio/l16;rate=16000", "?trim_silence=true", http.StatusBadRequest},
+		{http.MethodPost, "audio/l16;rate=16000", "?audio_track=1", http.StatusBadRequest},
+		{http.MethodPost, "audio/l16;rate=16000", "?channel=left", http.StatusBadRequest},
+		{http.MethodPost, "audio/l16;rate=16000", "?dtmf=true", http.StatusBadRequest},
+		{http.MethodPost, "audio/l16;rate=16000", "?skip_music=true", http.StatusBadRequest},
+		{http.MethodPost, "audio/l16;rate=16000", "?endpoint_silence_s=0", http.StatusBadRequest},
+		{http.MethodPost, "audio/l16;rate=16000", "?task=summarize", http.StatusBadRequest},
+		{http.MethodPost, "audio/l16;rate=16000", "?quality=best", http.StatusBadRequest},
+		{http.MethodPost, "audio/l16;rate=16000", "?beam_size=4", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "/transcribe/stream"+tt.query, strings.NewReader(""))
+		req.Header.Set("Content-Type", tt.ct)
+		rec := httptest.NewRecorder()
+		handleStream(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s %q = %d, want %d: %s", tt.method, tt.ct, rec.Code, tt.want, rec.Body)
+		}
+	}
+}
+
+func TestHandleStream_WebSocket(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(handleStream))
+	defer srv.Close()
+	tests := []struct {
+		path, origin string
+		want         int
+	}{
+		{"/transcribe/stream", "", http.StatusServiceUnavailable}, // no streaming model loaded
+		{"/transcribe/stream?content_type=audio/l16;rate=16000", "", http.StatusServiceUnavailable},
+		{"/transcribe/stream?content_type=audio/wav", "", http.StatusUnsupportedMediaType},
+		{"/transcribe/stream?denoise=true", "", http.StatusBadRequest},
+		{"/transcribe/stream", "https://evil.example.com", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		h := http.Header{}
+		if tt.origin != "" {
+			h.Set("Origin", tt.origin)
+		}
+		_, _, resp := wsDial(t, srv, tt.path, h)
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s (origin %q) = %d, want %d", tt.path, tt.origin, resp.StatusCode, tt.want)
+		}
+	}
+}
+
+func TestHandleStream_Client(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(handleStream))
+	defer srv.Close()
+	c := &client.Client{BaseURL: srv.URL}
+	tests := []struct {
+		query url.Values
+		want  int
+		msg   string
+	}{
+		{url.Values{"content_type": {"audio/l16;rate=16000"}}, http.StatusServiceUnavailable, "streaming model not loaded"},
+		{url.Values{"content_type": {"audio/wav"}}, http.StatusUnsupportedMediaType, ""},
+		{url.Values{"denoise": {"true"}}, http.StatusBadRequest, "not supported for streams"},
+	}
+	for _, tt := range tests {
+		ws, err := c.Dial(context.Background(), tt.query)
+		var cerr *client.Error
+		if !errors.As(err, &cerr) || cerr.StatusCode != tt.want || !strings.Contains(cerr.Message, tt.msg) {
+			t.Errorf("Dial(%s) = %v, want HTTP %d with %q", tt.query.Encode(), err, tt.want, tt.msg)
+		}
+		if err == nil {
+			ws.Close() //nolint:errcheck
+		}
+	}
+	// Without a streaming model the handshake is as far as handleStream
+	// goes; the open socket is tested against acceptWebSocket.
+}
+
+// --- parseEndpointing ---
+
+func TestParseEndpointing(t *testing.T) {
+	tests := []struct {
+		query string
+		want  moonshine.Options
+		msg   bool
+	}{
+		{"", moonshine.Options{}, false},
+		{"endpoint_silence_s=0.6&endpoint_idle_s=5&endpoint_max_utterance_s=30",
+			moonshine.Options{EndpointSilenceS: 0.6, EndpointIdleS: 5, EndpointMaxUtteranceS: 30}, false},
+		{"endpoint_silence_s=-1", moonshine.Options{}, true},
+		{"endpoint_idle_s=soon", moonshine.Options{}, true},
+		{"endpoint_max_utterance_s=NaN", moonshine.Options{}, true},
+		{"endpoint_max_utterance_s=inf", moonshine.Options{}, true},
+	}
+	for _, tt := range tests {
+		q, _ := url.ParseQuery(tt.query)
+		var opts moonshine.Options
+		msg := parseEndpointing(q, &opts)
+		if (msg != "") != tt.msg {
+			t.Errorf("%q: msg = %q, want error %v", tt.query, msg, tt.msg)
+		}
+		if !tt.msg && (opts.EndpointSilenceS != tt.want.EndpointSilenceS || opts.EndpointIdleS != tt.want.EndpointIdleS ||
+			opts.EndpointMaxUtteranceS != tt.want.EndpointMaxUtteranceS) {
+			t.Errorf("%q: options = %+v, want %+v", tt.query, opts, tt.want)
+		}
+	}
+}
+
+// --- isContainerStream ---
+
+func TestIsContainerStream(t *testing.T) {
+	tests := map[string]bool{
+		"audio/webm;codecs=opus": true,
+		"video/webm":             true,
+		"audio/ogg; codecs=opus": true,
+		"audio/l16;rate=16000":   false,
+		"audio/wav":              false,
+		"":                       false,
+	}
+	for ct, want := range tests {
+		if got := isContainerStream(ct); got != want {
+			t.Errorf("isContainerStream(%q) = %v, want %v", ct, got, want)
+		}
+	}
+}
+
+// --- startTranscoder ---
+
+func TestTranscoder_InvalidInput(t *testing.T) {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		t.Skip("ffmpeg not installed")
+	}
+	old := cfg
+	cfg = defaultConfig()
+	t.Cleanup(func() { cfg = old })
+	tr, err := startTranscoder(context.Background(), strings.NewReader("not audio"))
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	defer tr.Close() //nolint:errcheck
+	if _, err := io.ReadAll(tr); err == nil || !strings.Contains(err.Error(), "ffmpeg") {
+		t.Errorf("err = %v, want ffmpeg error", err)
+	}
+}
+
+func TestTranscoder_MissingBinary(t *testing.T) {
+	old := cfg
+	cfg = defaultConfig()
+	cfg.FFmpegPath = "/nonexistent/ffmpeg"
+	t.Cleanup(func() { cfg = old })
+	if _, err := startTranscoder(context.Background(), strings.NewReader("")); err == nil || !strings.Contains(err.Error(), "FFMPEG_PATH") {
+		t.Errorf("err = %v, want ffmpeg not found", err)
+	}
+}
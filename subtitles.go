@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"github.com/anatolykoptev/moonshine-whisper/pkg/moonshine"
+)
+
+// subtitleCues returns the timed pieces of resp to render as subtitles:
+// its segments, or the whole transcript spanning the audio when there are none.
+func subtitleCues(resp TranscribeResponse) []moonshine.Segment {
+	if len(resp.Segments) > 0 {
+		return resp.Segments
+	}
+	if resp.Text == "" {
+		return nil
+	}
+	return []moonshine.Segment{{Start: 0, End: resp.AudioS, Text: resp.Text}}
+}
+
+// formatSRT renders resp as SubRip subtitles. Segments without text are
+// skipped; speaker labels prefix the cue text.
+func formatSRT(resp TranscribeResponse) string {
+	var b strings.Builder
+	n := 0
+	for _, s := range subtitleCues(resp) {
+		if s.Text == "" {
+			continue
+		}
+		n++
+		text := s.Text
+		if s.Speaker != "" {
+			text = s.Speaker + ": " + text
+		}
+		fmt.Fprintf(&b, "%d\n%s --> %s\n%s\n\n", n, srtTimestamp(s.Start), srtTimestamp(s.End), text)
+	}
+	return b.String()
+}
+
+// formatVTT renders resp as WebVTT captions. Segments without text are
+// skipped; speaker labels become <v> voice tags, which players show as the
+// speaker's name.
+func formatVTT(resp TranscribeResponse) string {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n\n")
+	for _, s := range subtitleCues(resp) {
+		if s.Text == "" {
+			continue
+		}
+		text := vttEscaper.Replace(s.Text)
+		if s.Speaker != "" {
+			text = "<v " + vttEscaper.Replace(s.Speaker) + ">" + text
+		}
+		fmt.Fprintf(&b, "%s --> %s\n%s\n\n", vttTimestamp(s.Start), vttTimestamp(s.End), text)
+	}
+	return b.String()
+}
+
+// formatTTML renders resp as a TTML document, the XML subtitle format of
+// broadcast workflows. Segments without text are skipped; speakers are
+// declared as ttm:agent metadata and referenced by each paragraph.
+func formatTTML(resp TranscribeResponse) string {
+	cues := subtitleCues(resp)
+	agents := map[string]string{} // speaker label -> xml:id
+	var names []string
+	for _, s := range cues {
+		if s.Text != "" && s.Speaker != "" && agents[s.Speaker] == "" {
+			names = append(names, s.Speaker)
+			agents[s.Speaker] = fmt.Sprintf("speaker%d", len(names))
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<tt xmlns="http://www.w3.org/ns/ttml" xmlns:ttm="http://www.w3.org/ns/ttml#metadata"`)
+	if resp.Language != "" {
+		fmt.Fprintf(&b, ` xml:lang="%s"`, xmlEscape(resp.Language))
+	}
+	b.WriteString(">\n")
+	if len(names) > 0 {
+		b.WriteString("  <head>\n    <metadata>\n")
+		for _, name := range names {
+			fmt.Fprintf(&b, "      <ttm:agent xml:id=\"%s\" type=\"person\"><ttm:name type=\"full\">%s</ttm:name></ttm:agent>\n", agents[name], xmlEscape(name))
+		}
+		b.WriteString("    </metadata>\n  </head>\n")
+	}
+	b.WriteString("  <body>\n    <div>\n")
+	for _, s := range cues {
+		if s.Text == "" {
+			continue
+		}
+		fmt.Fprintf(&b, `      <p begin="%s" end="%s"`, vttTimestamp(s.Start), vttTimestamp(s.End))
+		if s.Speaker != "" {
+			fmt.Fprintf(&b, ` ttm:agent="%s"`, agents[s.Speaker])
+		}
+		fmt.Fprintf(&b, ">%s</p>\n", xmlEscape(s.Text))
+	}
+	b.WriteString("    </div>\n  </body>\n</tt>\n")
+	return b.String()
+}
+
+// xmlEscape escapes s for XML text and attribute values.
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s)) //nolint:errcheck
+	return b.String()
+}
+
+// vttEscaper escapes the characters WebVTT cue text reserves for markup.
+var vttEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// srtTimestamp formats seconds as HH:MM:SS,mmm.
+func srtTimestamp(sec float64) string {
+	ms := int64(sec*1000 + 0.5)
+	if ms < 0 {
+		ms = 0
+	}
+	return fmt.Sprintf("%02d:%02d:%02d,%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// vttTimestamp formats seconds as HH:MM:SS.mmm.
+func vttTimestamp(sec float64) string {
+	return strings.Replace(srtTimestamp(sec), ",", ".", 1)
+}
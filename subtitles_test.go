@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/anatolykoptev/moonshine-whisper/pkg/moonshine"
+)
+
+// --- srtTimestamp ---
+
+func TestSRTTimestamp(t *testing.T) {
+	tests := []struct {
+		sec  float64
+		want string
+	}{
+		{0, "00:00:00,000"},
+		{1.5, "00:00:01,500"},
+		{61.0004, "00:01:01,000"},
+		{3723.25, "01:02:03,250"},
+		{-1, "00:00:00,000"},
+	}
+	for _, tt := range tests {
+		if got := srtTimestamp(tt.sec); got != tt.want {
+			t.Errorf("srtTimestamp(%v) = %q, want %q", tt.sec, got, tt.want)
+		}
+	}
+}
+
+// --- formatSRT ---
+
+func TestFormatSRT_Segments(t *testing.T) {
+	resp := TranscribeResponse{Segments: []moonshine.Segment{
+		{Start: 0, End: 1.2, Speaker: "SPEAKER_00", Text: "hello"},
+		{Start: 1.2, End: 2, Text: ""},
+		{Start: 2, End: 3.5, Text: "world"},
+	}}
+	want := "1\n00:00:00,000 --> 00:00:01,200\nSPEAKER_00: hello\n\n" +
+		"2\n00:00:02,000 --> 00:00:03,500\nworld\n\n"
+	if got := formatSRT(resp); got != want {
+		t.Errorf("formatSRT = %q, want %q", got, want)
+	}
+}
+
+func TestFormatSRT_NoSegments(t *testing.T) {
+	got := formatSRT(TranscribeResponse{Text: "hello world", AudioS: 4.5})
+	want := "1\n00:00:00,000 --> 00:00:04,500\nhello world\n\n"
+	if got != want {
+		t.Errorf("formatSRT = %q, want %q", got, want)
+	}
+}
+
+func TestFormatSRT_Empty(t *testing.T) {
+	if got := formatSRT(TranscribeResponse{AudioS: 3}); got != "" {
+		t.Errorf("formatSRT of empty transcript = %q, want empty", got)
+	}
+}
+
+// --- formatVTT ---
+
+func TestFormatVTT(t *testing.T) {
+	resp := TranscribeResponse{Segments: []moonshine.Segment{
+		{Start: 0, End: 1.2, Speaker: "SPEAKER_00", Text: "hello"},
+		{Start: 1.2, End: 2, Speaker: "SPEAKER_01", Text: ""},
+		{Start: 2, End: 3723.25, Speaker: "SPEAKER_01", Text: "a < b & c"},
+		{Start: 3724, End: 3725, Text: "no speaker"},
+	}}
+	want := "WEBVTT\n\n" +
+		"00:00:00.000 --> 00:00:01.200\n<v SPEAKER_00>hello\n\n" +
+		"00:00:02.000 --> 01:02:03.250\n<v SPEAKER_01>a &lt; b &amp; c\n\n" +
+		"01:02:04.000 --> 01:02:05.000\nno speaker\n\n"
+	if got := formatVTT(resp); got != want {
+		t.Errorf("formatVTT = %q, want %q", got, want)
+	}
+	if got := formatVTT(TranscribeResponse{AudioS: 3}); got != "WEBVTT\n\n" {
+		t.Errorf("formatVTT of empty transcript = %q, want the header only", got)
+	}
+}
+
+// --- formatTTML ---
+
+func TestFormatTTML(t *testing.T) {
+	resp := TranscribeResponse{Language: "en", Segments: []moonshine.Segment{
+		{Start: 0.4, End: 2.1, Speaker: "SPEAKER_00", Text: "Hi, how are you?"},
+		{Start: 2.1, End: 2.5, Speaker: "SPEAKER_02", Text: ""},
+		{Start: 2.6, End: 4.2, Speaker: "SPEAKER_01", Text: "Fish & chips <3"},
+	}}
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<tt xmlns="http://www.w3.org/ns/ttml" xmlns:ttm="http://www.w3.org/ns/ttml#metadata" xml:lang="en">
+  <head>
+    <metadata>
+      <ttm:agent xml:id="speaker1" type="person"><ttm:name type="full">SPEAKER_00</ttm:name></ttm:agent>
+      <ttm:agent xml:id="speaker2" type="person"><ttm:name type="full">SPEAKER_01</ttm:name></ttm:agent>
+    </metadata>
+  </head>
+  <body>
+    <div>
+      <p begin="00:00:00.400" end="00:00:02.100" ttm:agent="speaker1">Hi, how are you?</p>
+      <p begin="00:00:02.600" end="00:00:04.200" ttm:agent="speaker2">Fish &amp; chips &lt;3</p>
+    </div>
+  </body>
+</tt>
+`
+	if got := formatTTML(resp); got != want {
+		t.Errorf("formatTTML =\n%s\nwant\n%s", got, want)
+	}
+
+	got := formatTTML(TranscribeResponse{Text: "hello", AudioS: 1})
+	if !strings.Contains(got, `<tt xmlns="http://www.w3.org/ns/ttml" xmlns:ttm="http://www.w3.org/ns/ttml#metadata">`) ||
+		strings.Contains(got, "<head>") || !strings.Contains(got, `<p begin="00:00:00.000" end="00:00:01.000">hello</p>`) {
+		t.Errorf("formatTTML without speakers or language =\n%s", got)
+	}
+	var doc struct{ XMLName xml.Name }
+	if err := xml.Unmarshal([]byte(formatTTML(resp)), &doc); err != nil || doc.XMLName.Local != "tt" {
+		t.Errorf("formatTTML is not well-formed: %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/anatolykoptev/moonshine-whisper/pkg/moonshine"
+	"github.com/google/uuid"
+)
+
+// tempJanitorInterval is how often the janitor looks for orphaned temp files.
+const tempJanitorInterval = 10 * time.Minute
+
+// tempDir returns TEMP_DIR, or the system temp directory when unset.
+func tempDir() string {
+	if cfg.TempDir == "" {
+		return os.TempDir()
+	}
+	return cfg.TempDir
+}
+
+// newTempPath returns a new path for a temp file with extension ext in
+// TEMP_DIR, failing with moonshine.ErrNoSpace when less than
+// TEMP_MIN_FREE_MB is free there.
+func newTempPath(ext string) (string, error) {
+	dir := tempDir()
+	if err := moonshine.CheckFreeSpace(dir, int64(cfg.TempMinFreeMB)<<20); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, moonshine.TempPrefix+uuid.New().String()[:8]+ext), nil
+}
+
+// tempMaxAge returns the age past which a temp file is orphaned:
+// TEMP_MAX_AGE_S, or by default an hour, or twice REQUEST_TIMEOUT_S when
+// longer, so files of running requests are kept.
+func tempMaxAge() time.Duration {
+	if cfg.TempMaxAge > 0 {
+		return cfg.TempMaxAge
+	}
+	return max(time.Hour, 2*cfg.RequestTimeout)
+}
+
+// sweepTemp removes the service's temp files in TEMP_DIR last modified more
+// than maxAge before now and returns how many it removed.
+func sweepTemp(now time.Time, maxAge time.Duration) int {
+	dir := tempDir()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		log.Printf("WARNING: temp janitor: %v", err)
+		return 0
+	}
+	removed := 0
+	for _, ent := range entries {
+		if !ent.Type().IsRegular() || !strings.HasPrefix(ent.Name(), moonshine.TempPrefix) {
+			continue
+		}
+		info, err := ent.Info()
+		if err != nil || now.Sub(info.ModTime()) < maxAge {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, ent.Name())); err == nil {
+			removed++
+		}
+	}
+	return removed
+}
+
+// runTempJanitor removes orphaned temp files, left behind when the service
+// was killed mid-request, at start and then every tempJanitorInterval, or
+// every tempMaxAge when shorter, until ctx is done.
+func runTempJanitor(ctx context.Context) {
+	t := time.NewTicker(min(tempJanitorInterval, tempMaxAge()))
+	defer t.Stop()
+	for {
+		if n := sweepTemp(time.Now(), tempMaxAge()); n > 0 {
+			log.Printf("Temp janitor: removed %d orphaned file(s) from %s", n, tempDir())
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
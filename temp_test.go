@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anatolykoptev/moonshine-whisper/pkg/moonshine"
+)
+
+// --- newTempPath ---
+
+func TestNewTempPath(t *testing.T) {
+	old := cfg
+	cfg.TempDir, cfg.TempMinFreeMB = t.TempDir(), 0
+	t.Cleanup(func() { cfg = old })
+
+	p, err := newTempPath(".mp3")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if filepath.Dir(p) != cfg.TempDir || !strings.HasPrefix(filepath.Base(p), moonshine.TempPrefix) || filepath.Ext(p) != ".mp3" {
+		t.Errorf("path = %q", p)
+	}
+
+	cfg.TempMinFreeMB = 1 << 40 // more than any disk
+	if _, err := newTempPath(".mp3"); !errors.Is(err, moonshine.ErrNoSpace) {
+		t.Errorf("err = %v, want ErrNoSpace", err)
+	}
+}
+
+// --- sweepTemp ---
+
+func TestSweepTemp(t *testing.T) {
+	old := cfg
+	cfg.TempDir = t.TempDir()
+	t.Cleanup(func() { cfg = old })
+
+	now := time.Now()
+	files := map[string]time.Duration{
+		"moonshine_old.wav":   2 * time.Hour,
+		"moonshine_fresh.wav": time.Minute,
+		"other_old.wav":       2 * time.Hour,
+	}
+	for name, age := range files {
+		p := filepath.Join(cfg.TempDir, name)
+		if err := os.WriteFile(p, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(p, now.Add(-age), now.Add(-age)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := sweepTemp(now, time.Hour); n != 1 {
+		t.Errorf("removed %d, want 1", n)
+	}
+	for name := range files {
+		_, err := os.Stat(filepath.Join(cfg.TempDir, name))
+		if gone := errors.Is(err, os.ErrNotExist); gone != (name == "moonshine_old.wav") {
+			t.Errorf("%s: removed = %v", name, gone)
+		}
+	}
+}
+
+// --- tempMaxAge ---
+
+func TestTempMaxAge(t *testing.T) {
+	old := cfg
+	t.Cleanup(func() { cfg = old })
+	for timeout, want := range map[time.Duration]time.Duration{0: time.Hour, 10 * time.Minute: time.Hour, 2 * time.Hour: 4 * time.Hour} {
+		cfg.RequestTimeout = timeout
+		if got := tempMaxAge(); got != want {
+			t.Errorf("RequestTimeout %s: tempMaxAge = %s, want %s", timeout, got, want)
+		}
+	}
+	cfg.TempMaxAge = 20 * time.Minute
+	if got := tempMaxAge(); got != 20*time.Minute {
+		t.Errorf("TempMaxAge 20m: tempMaxAge = %s, want 20m", got)
+	}
+}
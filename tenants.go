@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/anatolykoptev/moonshine-whisper/pkg/moonshine"
+)
+
+// TenantConfig holds the settings applied to requests made with one of a
+// tenant's API keys.
+type TenantConfig struct {
+	Name              string    `yaml:"name"`
+	APIKeys           []string  `yaml:"api_keys"`
+	Languages         []string  `yaml:"languages"`            // allowed languages; empty = all
+	Hotwords          []Hotword `yaml:"hotwords"`             // added to every RU request
+	MaxAudioDurationS float64   `yaml:"max_audio_duration_s"` // 0 = MAX_AUDIO_DURATION_S; can only lower it
+	VADMinDurationS   float64   `yaml:"vad_min_duration_s"`   // 0 = VAD_MIN_DURATION_S
+	ModelsDir         string    `yaml:"models_dir"`           // dedicated EN model; "" = shared
+	RUModelsDir       string    `yaml:"ru_models_dir"`        // dedicated RU model; "" = shared
+	CallbackSecret    string    `yaml:"callback_secret"`      // signs job callbacks; "" = CALLBACK_SECRET
+	Priority          string    `yaml:"priority"`             // of requests without one; "" = normal
+}
+
+// tenant is a configured tenant with its dedicated engine, if any.
+type tenant struct {
+	TenantConfig
+	engine *moonshine.Engine // nil = the shared engine
+}
+
+// Tenants by API key and by name; nil when no tenants are configured and
+// requests need no API key.
+var (
+	tenantsByKey  map[string]*tenant
+	tenantsByName map[string]*tenant
+)
+
+type tenantCtxKey struct{}
+
+// loadTenants indexes cfg.Tenants and loads the dedicated models of those
+// that have them. The returned func frees those models.
+func loadTenants() func() {
+	if len(cfg.Tenants) == 0 {
+		return func() {}
+	}
+	tenantsByKey, tenantsByName = make(map[string]*tenant), make(map[string]*tenant)
+	var engines []*moonshine.Engine
+	for _, tc := range cfg.Tenants {
+		t := &tenant{TenantConfig: tc}
+		if tc.ModelsDir != "" || tc.RUModelsDir != "" {
+			ec := engineConfig()
+			if tc.ModelsDir != "" {
+				ec.ModelsDir = tc.ModelsDir
+			}
+			if tc.RUModelsDir != "" {
+				ec.RUModelsDir = tc.RUModelsDir
+			}
+			e, err := moonshine.New(ec)
+			if err != nil {
+				log.Fatalf("tenant %s: %v", tc.Name, err)
+			}
+			e.Warmup()
+			t.engine = e
+			engines = append(engines, e)
+			log.Printf("Tenant %s: dedicated models loaded", tc.Name)
+		}
+		tenantsByName[tc.Name] = t
+		for _, key := range tc.APIKeys {
+			tenantsByKey[key] = t
+		}
+	}
+	log.Printf("%d tenant(s) configured; API keys required", len(cfg.Tenants))
+	return func() {
+		for _, e := range engines {
+			e.Close()
+		}
+	}
+}
+
+// tenantMiddleware resolves the API key of each request, from
+// "Authorization: Bearer <key>" or X-API-Key, to its tenant, and rejects
+// requests without a valid key with 401. /health, /version, and
+// /openapi.json stay open. Without configured tenants it passes every
+// request through.
+func tenantMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if tenantsByKey == nil || r.URL.Path == "/health" || r.URL.Path == "/version" || r.URL.Path == "/openapi.json" || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+		t, ok := tenantsByKey[requestAPIKey(r)]
+		if !ok {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "missing or invalid API key")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(withTenant(r.Context(), t)))
+	})
+}
+
+// requestAPIKey returns the API key of r, from X-API-Key or
+// "Authorization: Bearer <key>", or "". A WebSocket handshake, which a
+// browser cannot add headers to, may pass it as the api_key query
+// parameter instead.
+func requestAPIKey(r *http.Request) string {
+	key := r.Header.Get("X-API-Key")
+	if auth := r.Header.Get("Authorization"); key == "" && strings.HasPrefix(auth, "Bearer ") {
+		key = strings.TrimPrefix(auth, "Bearer ")
+	}
+	if key == "" && isWebSocketUpgrade(r) {
+		key = r.URL.Query().Get("api_key")
+	}
+	return key
+}
+
+// withTenant returns ctx carrying t; a nil t leaves ctx as is.
+func withTenant(ctx context.Context, t *tenant) context.Context {
+	if t == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, tenantCtxKey{}, t)
+}
+
+// tenantFrom returns the tenant of ctx, or nil.
+func tenantFrom(ctx context.Context) *tenant {
+	t, _ := ctx.Value(tenantCtxKey{}).(*tenant)
+	return t
+}
+
+// tenantName returns the name of the tenant of ctx, or "".
+func tenantName(ctx context.Context) string {
+	if t := tenantFrom(ctx); t != nil {
+		return t.Name
+	}
+	return ""
+}
+
+// engineFor returns the engine that serves ctx: its tenant's dedicated
+// models, or the shared engine.
+func engineFor(ctx context.Context) *moonshine.Engine {
+	if t := tenantFrom(ctx); t != nil && t.engine != nil {
+		return t.engine
+	}
+	return engine
+}
+
+// validateTenant checks req against the policy of the tenant of ctx and
+// returns an error message, or "" if allowed. language=auto is allowed and
+// chooses among the tenant's languages.
+func validateTenant(ctx context.Context, req TranscribeRequest) string {
+	t := tenantFrom(ctx)
+	if t == nil || len(t.Languages) == 0 {
+		return ""
+	}
+	if lang := normLang(req.Language); lang != moonshine.LangAuto && !slices.Contains(t.Languages, lang) {
+		return fmt.Sprintf("language %s is not enabled for this API key (allowed: %s)", lang, strings.Join(t.Languages, ", "))
+	}
+	return ""
+}
+
+// requestOptions returns the pipeline settings of req with the tenant of
+// ctx applied: its hotwords ahead of the request's for RU, its audio
+// duration limit and VAD minimum duration, its languages as the candidates
+// of language=auto, and its priority unless req has one. Engine log lines
+// carry the request ID of ctx.
+func requestOptions(ctx context.Context, req TranscribeRequest) moonshine.Options {
+	opts := req.options()
+	opts.Logger = requestLogger(ctx)
+	t := tenantFrom(ctx)
+	if t == nil {
+		return opts
+	}
+	if len(t.Hotwords) > 0 && opts.Lang == "ru" {
+		opts.Hotwords = encodeHotwords(append(slices.Clip(t.Hotwords), req.Hotwords...))
+	}
+	opts.MaxAudioDurationS = t.MaxAudioDurationS
+	opts.VADMinDurationS = t.VADMinDurationS
+	opts.AutoLanguages = t.Languages
+	if opts.Priority == "" {
+		opts.Priority = t.Priority
+	}
+	return opts
+}
+
+// maxAudioDuration returns the audio length limit for ctx in seconds.
+func maxAudioDuration(ctx context.Context) float64 {
+	if t := tenantFrom(ctx); t != nil && t.MaxAudioDurationS > 0 {
+		return min(t.MaxAudioDurationS, cfg.MaxAudioDurationS)
+	}
+	return cfg.MaxAudioDurationS
+}
+
+// vadMinDuration returns the audio length from which VAD runs by default
+// for ctx in seconds.
+func vadMinDuration(ctx context.Context) float64 {
+	if t := tenantFrom(ctx); t != nil && t.VADMinDurationS > 0 {
+		return t.VADMinDurationS
+	}
+	return cfg.VADMinDurationS
+}
+
+// tenantCacheKey prefixes the transcript cache key of the audio for
+// tenants with dedicated models, whose transcripts differ from the shared
+// engine's. An empty key, which bypasses the cache, stays empty.
+func tenantCacheKey(ctx context.Context, key string) string {
+	if t := tenantFrom(ctx); t != nil && t.engine != nil && key != "" {
+		return "tenant:" + t.Name + "|" + key
+	}
+	return key
+}
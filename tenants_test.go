@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/anatolykoptev/moonshine-whisper/pkg/moonshine"
+)
+
+// setTestTenants configures tenants without dedicated models for one test.
+func setTestTenants(t *testing.T, tenants ...TenantConfig) {
+	t.Helper()
+	oldCfg, oldKeys, oldNames := cfg.Tenants, tenantsByKey, tenantsByName
+	cfg.Tenants = tenants
+	loadTenants()
+	t.Cleanup(func() { cfg.Tenants, tenantsByKey, tenantsByName = oldCfg, oldKeys, oldNames })
+}
+
+// --- tenantMiddleware ---
+
+func TestTenantMiddleware(t *testing.T) {
+	setTestTenants(t, TenantConfig{Name: "acme", APIKeys: []string{"k1", "k2"}})
+	h := tenantMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Tenant", tenantName(r.Context()))
+	}))
+	tests := []struct {
+		name   string
+		path   string
+		header string
+		value  string
+		want   int
+		tenant string
+	}{
+		{"x-api-key", "/transcribe", "X-API-Key", "k1", http.StatusOK, "acme"},
+		{"bearer", "/transcribe", "Authorization", "Bearer k2", http.StatusOK, "acme"},
+		{"no key", "/transcribe", "", "", http.StatusUnauthorized, ""},
+		{"wrong key", "/transcribe", "X-API-Key", "nope", http.StatusUnauthorized, ""},
+		{"basic auth", "/transcribe", "Authorization", "Basic azE6", http.StatusUnauthorized, ""},
+		{"health", "/health", "", "", http.StatusOK, ""},
+		{"version", "/version", "", "", http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, tt.path, nil)
+			if tt.header != "" {
+				r.Header.Set(tt.header, tt.value)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, r)
+			if rec.Code != tt.want || rec.Header().Get("X-Tenant") != tt.tenant {
+				t.Errorf("got %d tenant %q, want %d tenant %q", rec.Code, rec.Header().Get("X-Tenant"), tt.want, tt.tenant)
+			}
+		})
+	}
+}
+
+func TestTenantMiddleware_NoTenants(t *testing.T) {
+	setTestTenants(t)
+	rec := httptest.NewRecorder()
+	tenantMiddleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})).
+		ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/transcribe", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d without tenants, want 200", rec.Code)
+	}
+}
+
+// --- validateTenant ---
+
+func TestValidateTenant(t *testing.T) {
+	ctx := withTenant(context.Background(), &tenant{TenantConfig: TenantConfig{Name: "acme", Languages: []string{"ru"}}})
+	if msg := validateTenant(ctx, TranscribeRequest{Language: "RU"}); msg != "" {
+		t.Errorf("allowed language rejected: %s", msg)
+	}
+	if msg := validateTenant(ctx, TranscribeRequest{}); msg == "" {
+		t.Error("default language en accepted for an ru-only tenant")
+	}
+	if msg := validateTenant(ctx, TranscribeRequest{Language: "auto"}); msg != "" {
+		t.Errorf("language auto rejected: %s", msg)
+	}
+	if got := requestOptions(ctx, TranscribeRequest{Language: "auto"}).AutoLanguages; !slices.Equal(got, []string{"ru"}) {
+		t.Errorf("auto candidates = %v, want [ru]", got)
+	}
+	if msg := validateTenant(context.Background(), TranscribeRequest{Language: "ru"}); msg != "" {
+		t.Errorf("request without tenant rejected: %s", msg)
+	}
+}
+
+// --- requestOptions ---
+
+func TestRequestOptions(t *testing.T) {
+	acme := &tenant{TenantConfig: TenantConfig{
+		Name:              "acme",
+		Hotwords:          []Hotword{{Phrase: "acme", Boost: 2}},
+		MaxAudioDurationS: 60,
+		VADMinDurationS:   30,
+	}}
+	ctx := withTenant(context.Background(), acme)
+	tests := []struct {
+		name string
+		ctx  context.Context
+		req  TranscribeRequest
+		want moonshine.Options
+	}{
+		{"ru merges hotwords", ctx, TranscribeRequest{Language: "ru", Hotwords: []Hotword{{Phrase: "order"}}},
+			moonshine.Options{Lang: "ru", Hotwords: "acme :2/order", MaxAudioDurationS: 60, VADMinDurationS: 30}},
+		{"en skips hotwords", ctx, TranscribeRequest{},
+			moonshine.Options{Lang: "en", MaxAudioDurationS: 60, VADMinDurationS: 30}},
+		{"no tenant", context.Background(), TranscribeRequest{Language: "ru"},
+			moonshine.Options{Lang: "ru"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := requestOptions(tt.ctx, tt.req)
+			if got.Lang != tt.want.Lang || got.Hotwords != tt.want.Hotwords || got.MaxAudioDurationS != tt.want.MaxAudioDurationS ||
+				got.VADMinDurationS != tt.want.VADMinDurationS {
+				t.Errorf("got lang %q hotwords %q max %g vad min %g, want %q %q %g %g", got.Lang, got.Hotwords, got.MaxAudioDurationS,
+					got.VADMinDurationS, tt.want.Lang, tt.want.Hotwords, tt.want.MaxAudioDurationS, tt.want.VADMinDurationS)
+			}
+		})
+	}
+	if len(acme.Hotwords) != 1 {
+		t.Errorf("tenant hotwords modified: %+v", acme.Hotwords)
+	}
+}
+
+func TestRequestOptions_Priority(t *testing.T) {
+	ctx := withTenant(context.Background(), &tenant{TenantConfig: TenantConfig{Name: "batch", Priority: moonshine.PriorityLow}})
+	if got := requestOptions(ctx, TranscribeRequest{}).Priority; got != moonshine.PriorityLow {
+		t.Errorf("tenant default: priority = %q, want low", got)
+	}
+	if got := requestOptions(ctx, TranscribeRequest{Priority: moonshine.PriorityNormal}).Priority; got != moonshine.PriorityNormal {
+		t.Errorf("request override: priority = %q, want normal", got)
+	}
+}
+
+// --- maxAudioDuration ---
+
+func TestMaxAudioDuration(t *testing.T) {
+	old := cfg.MaxAudioDurationS
+	cfg.MaxAudioDurationS = 300
+	t.Cleanup(func() { cfg.MaxAudioDurationS = old })
+	tests := []struct {
+		limit float64
+		want  float64
+	}{
+		{0, 300},
+		{60, 60},
+		{600, 300},
+	}
+	for _, tt := range tests {
+		ctx := withTenant(context.Background(), &tenant{TenantConfig: TenantConfig{MaxAudioDurationS: tt.limit}})
+		if got := maxAudioDuration(ctx); got != tt.want {
+			t.Errorf("maxAudioDuration(tenant %g) = %g, want %g", tt.limit, got, tt.want)
+		}
+	}
+}
+
+// --- vadMinDuration ---
+
+func TestVADMinDuration(t *testing.T) {
+	old := cfg.VADMinDurationS
+	cfg.VADMinDurationS = 10
+	t.Cleanup(func() { cfg.VADMinDurationS = old })
+	if got := vadMinDuration(context.Background()); got != 10 {
+		t.Errorf("vadMinDuration() = %g without tenant, want 10", got)
+	}
+	for _, tt := range []struct{ min, want float64 }{{0, 10}, {30, 30}, {2, 2}} {
+		ctx := withTenant(context.Background(), &tenant{TenantConfig: TenantConfig{VADMinDurationS: tt.min}})
+		if got := vadMinDuration(ctx); got != tt.want {
+			t.Errorf("vadMinDuration(tenant %g) = %g, want %g", tt.min, got, tt.want)
+		}
+	}
+}
+
+// --- requestAPIKey ---
+
+func TestRequestAPIKey(t *testing.T) {
+	tests := []struct {
+		header, value, want string
+	}{
+		{"X-API-Key", "k1", "k1"},
+		{"Authorization", "Bearer k2", "k2"},
+		{"Authorization", "Basic azE6", ""},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/health", nil)
+		if tt.header != "" {
+			r.Header.Set(tt.header, tt.value)
+		}
+		if got := requestAPIKey(r); got != tt.want {
+			t.Errorf("requestAPIKey(%s: %s) = %q, want %q", tt.header, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestRequestAPIKey_Query(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/transcribe/stream?api_key=k3", nil)
+	if got := requestAPIKey(r); got != "" {
+		t.Errorf("requestAPIKey(plain GET) = %q, want the query ignored", got)
+	}
+	r.Header.Set("Upgrade", "websocket")
+	r.Header.Set("Connection", "Upgrade")
+	if got := requestAPIKey(r); got != "k3" {
+		t.Errorf("requestAPIKey(WebSocket) = %q, want k3", got)
+	}
+}
+
+// --- engineFor ---
+
+func TestEngineFor(t *testing.T) {
+	dedicated := new(moonshine.Engine)
+	if got := engineFor(context.Background()); got != engine {
+		t.Error("request without tenant not served by the shared engine")
+	}
+	shared := withTenant(context.Background(), &tenant{TenantConfig: TenantConfig{Name: "a"}})
+	if got := engineFor(shared); got != engine || tenantCacheKey(shared, "k") != "k" {
+		t.Error("tenant without models not served by the shared engine")
+	}
+	own := withTenant(context.Background(), &tenant{TenantConfig: TenantConfig{Name: "b"}, engine: dedicated})
+	if got := engineFor(own); got != dedicated || tenantCacheKey(own, "k") == "k" {
+		t.Error("tenant with models not served by its engine")
+	}
+	// Audio without a content key bypasses the cache for every tenant.
+	if got := tenantCacheKey(own, ""); got != "" {
+		t.Errorf("tenantCacheKey(own, \"\") = %q, want the cache bypassed", got)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// certReloader serves the TLS certificate from certFile/keyFile and, when
+// interval > 0, reloads the pair once either file's modification time changes
+// so rotated certificates are picked up without a restart.
+type certReloader struct {
+	certFile, keyFile string
+	interval          time.Duration
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	modTime   time.Time // latest mtime of the loaded pair
+	lastCheck time.Time
+}
+
+// newCertReloader loads the initial certificate pair.
+func newCertReloader(certFile, keyFile string, interval time.Duration) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile, interval: interval}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// load reads and parses the certificate pair. Caller holds r.mu or owns r.
+func (r *certReloader) load() error {
+	modTime, err := r.pairModTime()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("load TLS key pair: %w", err)
+	}
+	r.cert, r.modTime = &cert, modTime
+	return nil
+}
+
+// pairModTime returns the later modification time of the cert and key files.
+func (r *certReloader) pairModTime() (time.Time, error) {
+	var latest time.Time
+	for _, name := range []string{r.certFile, r.keyFile} {
+		fi, err := os.Stat(name)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("stat TLS file: %w", err)
+		}
+		if fi.ModTime().After(latest) {
+			latest = fi.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// getCertificate implements tls.Config.GetCertificate. A failed reload keeps
+// serving the previous certificate.
+func (r *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.interval > 0 && time.Since(r.lastCheck) >= r.interval {
+		r.lastCheck = time.Now()
+		if modTime, err := r.pairModTime(); err != nil {
+			log.Printf("WARNING: TLS reload: %v", err)
+		} else if !modTime.Equal(r.modTime) {
+			if err := r.load(); err != nil {
+				log.Printf("WARNING: TLS reload: %v", err)
+			} else {
+				log.Printf("TLS certificate reloaded from %s", r.certFile)
+			}
+		}
+	}
+	return r.cert, nil
+}
+
+// tlsConfig returns a server TLS config backed by r.
+func (r *certReloader) tlsConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: r.getCertificate,
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCertPair writes a self-signed certificate for commonName and its key
+// to certFile and keyFile, stamping both with modTime.
+func writeCertPair(t *testing.T, certFile, keyFile, commonName string, modTime time.Time) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, block := range map[string]*pem.Block{
+		certFile: {Type: "CERTIFICATE", Bytes: der},
+		keyFile:  {Type: "EC PRIVATE KEY", Bytes: keyDER},
+	} {
+		if err := os.WriteFile(name, pem.EncodeToMemory(block), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(name, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// servedName returns the common name of the certificate r currently serves.
+func servedName(t *testing.T, r *certReloader) string {
+	t.Helper()
+	cert, err := r.getCertificate(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.Subject.CommonName
+}
+
+// --- certReloader ---
+
+func TestCertReloader_Reload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	base := time.Now().Add(-time.Hour)
+	writeCertPair(t, certFile, keyFile, "first", base)
+
+	r, err := newCertReloader(certFile, keyFile, time.Nanosecond)
+	if err != nil {
+		t.Fatalf("newCertReloader: %v", err)
+	}
+	if got := servedName(t, r); got != "first" {
+		t.Fatalf("served %q, want first", got)
+	}
+
+	writeCertPair(t, certFile, keyFile, "second", base.Add(time.Minute))
+	if got := servedName(t, r); got != "second" {
+		t.Errorf("after rotation served %q, want second", got)
+	}
+
+	// A broken replacement keeps the last good certificate.
+	if err := os.WriteFile(certFile, []byte("garbage"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if got := servedName(t, r); got != "second" {
+		t.Errorf("after bad rotation served %q, want second", got)
+	}
+}
+
+func TestCertReloader_NoReload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	base := time.Now().Add(-time.Hour)
+	writeCertPair(t, certFile, keyFile, "first", base)
+
+	r, err := newCertReloader(certFile, keyFile, 0)
+	if err != nil {
+		t.Fatalf("newCertReloader: %v", err)
+	}
+	writeCertPair(t, certFile, keyFile, "second", base.Add(time.Minute))
+	if got := servedName(t, r); got != "first" {
+		t.Errorf("interval 0 served %q, want first", got)
+	}
+}
+
+func TestNewCertReloader_Errors(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	if _, err := newCertReloader(certFile, keyFile, 0); err == nil {
+		t.Error("expected error for missing files")
+	}
+
+	writeCertPair(t, certFile, keyFile, "first", time.Now())
+	other := filepath.Join(dir, "other.key")
+	writeCertPair(t, filepath.Join(dir, "other.crt"), other, "other", time.Now())
+	if _, err := newCertReloader(certFile, other, 0); err == nil {
+		t.Error("expected error for mismatched key")
+	}
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anatolykoptev/moonshine-whisper/pkg/moonshine"
+	"github.com/google/uuid"
+	_ "modernc.org/sqlite" // registers the "sqlite" driver
+)
+
+// Sources recorded with each stored transcript.
+const (
+	sourceTranscribe = "transcribe"
+	sourceUpload     = "upload"
+	sourcePCM        = "pcm"
+	sourceJob        = "job"
+	sourceQueue      = "queue"
+	sourceWatch      = "watch"
+	sourceSchedule   = "schedule"
+)
+
+// Page sizes of GET /transcripts.
+const (
+	transcriptsDefaultLimit = 20
+	transcriptsMaxLimit     = 100
+)
+
+// transcriptSchema creates the transcripts table and its full-text index,
+// which holds the text of each transcript by rowid.
+const transcriptSchema = `
+CREATE TABLE IF NOT EXISTS transcripts (
+	id          TEXT PRIMARY KEY,
+	created_at  INTEGER NOT NULL,
+	tenant      TEXT NOT NULL,
+	source      TEXT NOT NULL,
+	audio       TEXT NOT NULL,
+	language    TEXT NOT NULL,
+	audio_s     REAL NOT NULL,
+	duration_ms REAL NOT NULL,
+	text        TEXT NOT NULL,
+	segments    TEXT NOT NULL,
+	request     TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS transcripts_created_at ON transcripts (tenant, created_at);
+CREATE VIRTUAL TABLE IF NOT EXISTS transcripts_fts USING fts5 (text, content='transcripts', content_rowid='rowid');
+CREATE TRIGGER IF NOT EXISTS transcripts_ai AFTER INSERT ON transcripts BEGIN
+	INSERT INTO transcripts_fts (rowid, text) VALUES (new.rowid, new.text);
+END;
+`
+
+// transcriptMigrations add the columns newer than transcriptSchema; a
+// "duplicate column" error means one is already there.
+var transcriptMigrations = []string{
+	`ALTER TABLE transcripts ADD COLUMN audio_id TEXT NOT NULL DEFAULT ''`,
+}
+
+// Transcript is a stored transcript. Listings leave out the text, segments,
+// and request and carry a snippet of the text instead. Each tenant sees only
+// its own transcripts.
+type Transcript struct {
+	ID         string              `json:"id"`
+	CreatedAt  time.Time           `json:"created_at"`
+	Tenant     string              `json:"-"`
+	Source     string              `json:"source"`          // endpoint or integration that produced it
+	Audio      string              `json:"audio,omitempty"` // path, URL without query, or file name
+	Language   string              `json:"language"`
+	AudioS     float64             `json:"audio_s"`
+	DurationMs float64             `json:"duration_ms"`
+	Text       string              `json:"text,omitempty"`
+	Snippet    string              `json:"snippet,omitempty"` // search matches in [brackets]
+	Segments   []moonshine.Segment `json:"segments,omitempty"`
+	Request    *TranscribeRequest  `json:"request,omitempty"`  // without audio_base64
+	AudioID    string              `json:"audio_id,omitempty"` // retained audio, with RETAIN_AUDIO
+}
+
+// transcriptStore persists transcripts in SQLite.
+type transcriptStore struct {
+	db *sql.DB
+}
+
+// transcriptDB is set when TRANSCRIPT_DB is configured.
+var transcriptDB *transcriptStore
+
+// openTranscriptStore opens or creates the SQLite database at path.
+func openTranscriptStore(path string) (*transcriptStore, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(transcriptSchema); err != nil {
+		db.Close() //nolint:errcheck
+		return nil, err
+	}
+	for _, m := range transcriptMigrations {
+		if _, err := db.Exec(m); err != nil && !strings.Contains(err.Error(), "duplicate column") {
+			db.Close() //nolint:errcheck
+			return nil, err
+		}
+	}
+	return &transcriptStore{db: db}, nil
+}
+
+// add stores t.
+func (s *transcriptStore) add(t Transcript) error {
+	segments, err := json.Marshal(t.Segments)
+	if err != nil {
+		return err
+	}
+	req, err := json.Marshal(t.Request)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`INSERT INTO transcripts
+		(id, created_at, tenant, source, audio, language, audio_s, duration_ms, text, segments, request, audio_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		t.ID, t.CreatedAt.UnixMilli(), t.Tenant, t.Source, t.Audio, t.Language, t.AudioS, t.DurationMs, t.Text, segments, req, t.AudioID)
+	return err
+}
+
+// get returns the transcript of tenant with the given ID.
+func (s *transcriptStore) get(tenant, id string) (Transcript, bool, error) {
+	var t Transcript
+	var created int64
+	var segments, req []byte
+	err := s.db.QueryRow(`SELECT id, created_at, source, audio, language, audio_s, duration_ms, text, segments, request, audio_id
+		FROM transcripts WHERE id = ? AND tenant = ?`, id, tenant).
+		Scan(&t.ID, &created, &t.Source, &t.Audio, &t.Language, &t.AudioS, &t.DurationMs, &t.Text, &segments, &req, &t.AudioID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Transcript{}, false, nil
+	}
+	if err != nil {
+		return Transcript{}, false, err
+	}
+	t.CreatedAt = time.UnixMilli(created).UTC()
+	if err := json.Unmarshal(segments, &t.Segments); err != nil {
+		return Transcript{}, false, err
+	}
+	if err := json.Unmarshal(req, &t.Request); err != nil {
+		return Transcript{}, false, err
+	}
+	return t, true, nil
+}
+
+// search lists the transcripts of tenant matching the words of q, best
+// match first, or the most recent ones when q is empty.
+func (s *transcriptStore) search(tenant, q string, limit, offset int) ([]Transcript, error) {
+	const columns = `t.id, t.created_at, t.source, t.audio, t.language, t.audio_s, t.duration_ms`
+	var rows *sql.Rows
+	var err error
+	if match := ftsQuery(q); match != "" {
+		rows, err = s.db.Query(`SELECT `+columns+`, snippet(transcripts_fts, 0, '[', ']', '…', 16)
+			FROM transcripts_fts JOIN transcripts t ON t.rowid = transcripts_fts.rowid
+			WHERE transcripts_fts MATCH ? AND t.tenant = ? ORDER BY rank LIMIT ? OFFSET ?`, match, tenant, limit, offset)
+	} else {
+		rows, err = s.db.Query(`SELECT `+columns+`, substr(t.text, 1, 160)
+			FROM transcripts t WHERE t.tenant = ? ORDER BY t.created_at DESC LIMIT ? OFFSET ?`, tenant, limit, offset)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close() //nolint:errcheck
+	list := []Transcript{}
+	for rows.Next() {
+		var t Transcript
+		var created int64
+		if err := rows.Scan(&t.ID, &created, &t.Source, &t.Audio, &t.Language, &t.AudioS, &t.DurationMs, &t.Snippet); err != nil {
+			return nil, err
+		}
+		t.CreatedAt = time.UnixMilli(created).UTC()
+		list = append(list, t)
+	}
+	return list, rows.Err()
+}
+
+// ftsQuery turns free text into an FTS5 query matching every word, so
+// quotes and operators in q are searched for literally.
+func ftsQuery(q string) string {
+	words := strings.Fields(q)
+	for i, w := range words {
+		words[i] = `"` + strings.ReplaceAll(w, `"`, `""`) + `"`
+	}
+	return strings.Join(words, " ")
+}
+
+// recordTranscript stores a successful transcription for the tenant of ctx
+// when TRANSCRIPT_DB is set and sets resp.TranscriptID. audio names the
+// input. Every transcription, failed or not, also goes to the audit log.
+func recordTranscript(ctx context.Context, source, audio string, req TranscribeRequest, resp *TranscribeResponse) {
+	auditTranscription(ctx, source, audio, req, *resp)
+	if transcriptDB == nil || resp.Error != "" {
+		return
+	}
+	req.AudioBase64 = ""
+	lang := resp.Language
+	if lang == "" {
+		lang = normLang(req.Language)
+	}
+	t := Transcript{
+		ID:         uuid.New().String(),
+		CreatedAt:  time.Now(),
+		Tenant:     tenantName(ctx),
+		Source:     source,
+		Audio:      audio,
+		Language:   lang,
+		AudioS:     resp.AudioS,
+		DurationMs: resp.DurationMs,
+		Text:       resp.Text,
+		Segments:   resp.Segments,
+		Request:    &req,
+		AudioID:    resp.AudioID,
+	}
+	if err := transcriptDB.add(t); err != nil {
+		log.Printf("WARNING: store transcript: %v", err)
+		return
+	}
+	resp.TranscriptID = t.ID
+}
+
+// audioName describes the audio source of req for the transcript store:
+// its path, its URL without the query (which may hold credentials), or
+// "inline".
+func (req TranscribeRequest) audioName() string {
+	switch {
+	case req.AudioURL != "":
+		u, err := url.Parse(req.AudioURL)
+		if err != nil {
+			return ""
+		}
+		u.RawQuery, u.Fragment, u.User = "", "", nil
+		return u.String()
+	case req.AudioBase64 != "":
+		return "inline"
+	}
+	return req.AudioPath
+}
+
+// handleTranscriptSearch handles GET /transcripts: stored transcripts
+// matching q, or the most recent ones, paged by limit and offset.
+func handleTranscriptSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "GET only")
+		return
+	}
+	query := r.URL.Query()
+	limit, offset := transcriptsDefaultLimit, 0
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > transcriptsMaxLimit {
+			writeError(w, http.StatusBadRequest, "limit must be in [1, "+strconv.Itoa(transcriptsMaxLimit)+"]")
+			return
+		}
+		limit = n
+	}
+	if v := query.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, "offset must be >= 0")
+			return
+		}
+		offset = n
+	}
+	list, err := transcriptDB.search(tenantName(r.Context()), query.Get("q"), limit, offset)
+	if err != nil {
+		log.Printf("WARNING: search transcripts: %v", err)
+		writeError(w, http.StatusInternalServerError, "search failed")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"transcripts": list})
+}
+
+// handleTranscriptGet handles GET /transcripts/{id}.
+func handleTranscriptGet(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "GET only")
+		return
+	}
+	t, ok, err := transcriptDB.get(tenantName(r.Context()), r.PathValue("id"))
+	if err != nil {
+		log.Printf("WARNING: get transcript: %v", err)
+		writeError(w, http.StatusInternalServerError, "lookup failed")
+		return
+	}
+	if !ok {
+		writeError(w, http.StatusNotFound, "transcript not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, t)
+}
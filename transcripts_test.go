@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/anatolykoptev/moonshine-whisper/pkg/moonshine"
+)
+
+// newTestTranscriptStore enables an isolated transcript store.
+func newTestTranscriptStore(t *testing.T) {
+	t.Helper()
+	s, err := openTranscriptStore(filepath.Join(t.TempDir(), "transcripts.db"))
+	if err != nil {
+		t.Fatalf("openTranscriptStore: %v", err)
+	}
+	old := transcriptDB
+	transcriptDB = s
+	t.Cleanup(func() {
+		transcriptDB = old
+		s.db.Close() //nolint:errcheck
+	})
+}
+
+// --- recordTranscript ---
+
+func TestRecordTranscript(t *testing.T) {
+	newTestTranscriptStore(t)
+	req := TranscribeRequest{AudioBase64: "UklGRg==", Language: "EN"}
+	resp := TranscribeResponse{Text: "Our refund policy covers thirty days.", AudioS: 3.5, Segments: []moonshine.Segment{{Start: 0, End: 3.5, Text: "Our refund policy covers thirty days."}}}
+	recordTranscript(context.Background(), sourceTranscribe, req.audioName(), req, &resp)
+	if resp.TranscriptID == "" {
+		t.Fatal("TranscriptID not set")
+	}
+
+	got, ok, err := transcriptDB.get("", resp.TranscriptID)
+	if err != nil || !ok {
+		t.Fatalf("get = %v, %v", ok, err)
+	}
+	if got.Text != resp.Text || got.Language != "en" || got.Source != sourceTranscribe || got.Audio != "inline" || got.AudioS != 3.5 {
+		t.Errorf("stored %+v", got)
+	}
+	if len(got.Segments) != 1 || got.Request == nil || got.Request.AudioBase64 != "" || got.Request.Language != "EN" {
+		t.Errorf("stored segments %+v, request %+v; want the request without its audio", got.Segments, got.Request)
+	}
+
+	failed := TranscribeResponse{Error: "boom"}
+	recordTranscript(context.Background(), sourceTranscribe, "", req, &failed)
+	if failed.TranscriptID != "" {
+		t.Error("failed transcriptions must not be stored")
+	}
+}
+
+func TestRecordTranscript_AudioID(t *testing.T) {
+	newTestTranscriptStore(t)
+	resp := TranscribeResponse{Text: "hello", AudioID: "a1"}
+	recordTranscript(context.Background(), sourceUpload, "call.wav", TranscribeRequest{}, &resp)
+	if got, ok, err := transcriptDB.get("", resp.TranscriptID); err != nil || !ok || got.AudioID != "a1" {
+		t.Errorf("get = %+v, %v, %v; want audio_id a1", got, ok, err)
+	}
+}
+
+func TestOpenTranscriptStore_Reopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "transcripts.db")
+	for range 2 {
+		s, err := openTranscriptStore(path)
+		if err != nil {
+			t.Fatalf("openTranscriptStore: %v", err)
+		}
+		s.db.Close() //nolint:errcheck
+	}
+}
+
+func TestRecordTranscript_Disabled(t *testing.T) {
+	resp := TranscribeResponse{Text: "hello"}
+	recordTranscript(context.Background(), sourceTranscribe, "", TranscribeRequest{}, &resp)
+	if resp.TranscriptID != "" {
+		t.Errorf("TranscriptID = %q without a store", resp.TranscriptID)
+	}
+}
+
+// --- transcriptStore.search ---
+
+func TestTranscriptStore_Search(t *testing.T) {
+	newTestTranscriptStore(t)
+	for _, text := range []string{"the refund policy", "shipping policy", `say "hello" world`} {
+		resp := TranscribeResponse{Text: text}
+		recordTranscript(context.Background(), sourceJob, "/a.wav", TranscribeRequest{}, &resp)
+	}
+	tests := []struct {
+		q    string
+		want int
+	}{
+		{"policy", 2},
+		{"refund policy", 1},
+		{"POLICY refund", 1},
+		{`"hello`, 1},
+		{"missing", 0},
+		{"", 3},
+	}
+	for _, tt := range tests {
+		list, err := transcriptDB.search("", tt.q, 10, 0)
+		if err != nil {
+			t.Fatalf("search(%q): %v", tt.q, err)
+		}
+		if len(list) != tt.want {
+			t.Errorf("search(%q) = %d results, want %d", tt.q, len(list), tt.want)
+		}
+	}
+	list, _ := transcriptDB.search("", "refund", 10, 0)
+	if len(list) == 1 && list[0].Snippet != "the [refund] policy" {
+		t.Errorf("snippet = %q", list[0].Snippet)
+	}
+	if list, _ := transcriptDB.search("", "", 1, 1); len(list) != 1 || list[0].Text != "" {
+		t.Errorf("paged listing = %+v, want one entry without text", list)
+	}
+}
+
+func TestTranscriptStore_Tenants(t *testing.T) {
+	newTestTranscriptStore(t)
+	acme := withTenant(context.Background(), &tenant{TenantConfig: TenantConfig{Name: "acme"}})
+	resp := TranscribeResponse{Text: "acme policy"}
+	recordTranscript(acme, sourceJob, "/a.wav", TranscribeRequest{}, &resp)
+	shared := TranscribeResponse{Text: "shared policy"}
+	recordTranscript(context.Background(), sourceJob, "/b.wav", TranscribeRequest{}, &shared)
+
+	if list, _ := transcriptDB.search("acme", "policy", 10, 0); len(list) != 1 || list[0].ID != resp.TranscriptID {
+		t.Errorf("tenant search = %+v, want only its transcript", list)
+	}
+	if list, _ := transcriptDB.search("", "", 10, 0); len(list) != 1 || list[0].ID != shared.TranscriptID {
+		t.Errorf("untenanted listing = %+v, want only the shared transcript", list)
+	}
+	if _, ok, _ := transcriptDB.get("other", resp.TranscriptID); ok {
+		t.Error("another tenant read the transcript")
+	}
+}
+
+// --- audioName ---
+
+func TestAudioName(t *testing.T) {
+	tests := []struct {
+		req  TranscribeRequest
+		want string
+	}{
+		{TranscribeRequest{AudioPath: "/audio/a.wav"}, "/audio/a.wav"},
+		{TranscribeRequest{AudioURL: "https://user:pw@bucket.example.com/a.wav?X-Amz-Signature=abc#t"}, "https://bucket.example.com/a.wav"},
+		{TranscribeRequest{AudioBase64: "UklGRg=="}, "inline"},
+	}
+	for _, tt := range tests {
+		if got := tt.req.audioName(); got != tt.want {
+			t.Errorf("audioName(%+v) = %q, want %q", tt.req, got, tt.want)
+		}
+	}
+}
+
+// --- handlers ---
+
+func TestHandleTranscripts(t *testing.T) {
+	newTestTranscriptStore(t)
+	resp := TranscribeResponse{Text: "hello world"}
+	recordTranscript(context.Background(), sourceUpload, "a.wav", TranscribeRequest{}, &resp)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/transcripts", handleTranscriptSearch)
+	mux.HandleFunc("/transcripts/{id}", handleTranscriptGet)
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/transcripts?q=hello", http.StatusOK},
+		{"/transcripts?limit=0", http.StatusBadRequest},
+		{"/transcripts?limit=101", http.StatusBadRequest},
+		{"/transcripts?offset=-1", http.StatusBadRequest},
+		{"/transcripts/" + resp.TranscriptID, http.StatusOK},
+		{"/transcripts/missing", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if rec.Code != tt.want {
+			t.Errorf("GET %s = %d, want %d", tt.path, rec.Code, tt.want)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/transcripts?q=hello", nil))
+	var got struct{ Transcripts []Transcript }
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(got.Transcripts) != 1 || got.Transcripts[0].ID != resp.TranscriptID || got.Transcripts[0].Audio != "a.wav" {
+		t.Errorf("search = %+v", got.Transcripts)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"runtime"
+	"slices"
+
+	"github.com/anatolykoptev/moonshine-whisper/pkg/moonshine"
+)
+
+// versionInfo is the GET /version response.
+type versionInfo struct {
+	Version    string   `json:"version"`
+	Commit     string   `json:"commit"`
+	BuildDate  string   `json:"build_date"`
+	GoVersion  string   `json:"go_version"`
+	SherpaONNX string   `json:"sherpa_onnx_version"`
+	Provider   string   `json:"provider"`
+	Features   []string `json:"features"`  // enabled models, tools, and integrations, sorted
+	Platform   string   `json:"platform"`  // GOOS/GOARCH
+	Languages  []string `json:"languages"` // languages with a loaded model
+}
+
+// enabledFeatures returns the names of the engine capabilities that are
+// available and of the optional integrations that are configured, sorted.
+func enabledFeatures() []string {
+	var features []string
+	for name, ok := range engineCapabilities() {
+		if ok {
+			features = append(features, name)
+		}
+	}
+	for name, ok := range map[string]bool{
+		"cache":            transcripts != nil,
+		"transcript_store": transcriptDB != nil,
+		"speaker_store":    cfg.SpeakerDB != "",
+		"audit_log":        auditLog != nil,
+		"tenants":          tenantsByKey != nil,
+		"watch":            cfg.WatchDir != "",
+		"schedules":        len(cfg.Schedules) > 0,
+		"selftest":         cfg.SelftestDir != "",
+		"rtp":              cfg.RTPAddr != "",
+		"kafka":            len(cfg.KafkaBrokers) > 0,
+		"nats":             cfg.NATSURL != "",
+	} {
+		if ok {
+			features = append(features, name)
+		}
+	}
+	slices.Sort(features)
+	return features
+}
+
+// handleVersion handles GET /version: the build of the service and what it
+// has enabled, without the readiness checks of /health.
+func handleVersion(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "GET only")
+		return
+	}
+	var langs []string
+	for _, lang := range engine.Languages() {
+		if engine.HasLanguage(lang) {
+			langs = append(langs, lang)
+		}
+	}
+	writeJSON(w, http.StatusOK, versionInfo{
+		Version:    version,
+		Commit:     commit,
+		BuildDate:  buildDate,
+		GoVersion:  runtime.Version(),
+		SherpaONNX: moonshine.SherpaVersion(),
+		Provider:   engine.EffectiveProvider(),
+		Features:   enabledFeatures(),
+		Platform:   runtime.GOOS + "/" + runtime.GOARCH,
+		Languages:  langs,
+	})
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"slices"
+	"testing"
+)
+
+// --- enabledFeatures ---
+
+func TestEnabledFeatures(t *testing.T) {
+	old := cfg
+	t.Cleanup(func() { cfg = old })
+	cfg.NATSURL, cfg.WatchDir = "nats://localhost:4222", "/in"
+	cfg.Schedules = []ScheduleConfig{{Name: "nightly"}}
+	got := enabledFeatures()
+	for _, want := range []string{"nats", "schedules", "watch"} {
+		if !slices.Contains(got, want) {
+			t.Errorf("features %v lack %s", got, want)
+		}
+	}
+	if slices.Contains(got, "kafka") || slices.Contains(got, "vad") {
+		t.Errorf("features %v list disabled ones", got)
+	}
+	if !slices.IsSorted(got) {
+		t.Errorf("features %v not sorted", got)
+	}
+}
+
+// --- handleVersion ---
+
+func TestHandleVersion(t *testing.T) {
+	rec := httptest.NewRecorder()
+	handleVersion(rec, httptest.NewRequest(http.MethodGet, "/version", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	var v versionInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Version != version || v.Commit != commit || v.BuildDate != buildDate || v.GoVersion != runtime.Version() || v.SherpaONNX == "" {
+		t.Errorf("version = %+v", v)
+	}
+
+	rec = httptest.NewRecorder()
+	handleVersion(rec, httptest.NewRequest(http.MethodPost, "/version", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST status = %d, want 405", rec.Code)
+	}
+}
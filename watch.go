@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/anatolykoptev/moonshine-whisper/pkg/moonshine"
+)
+
+// Subfolders of WATCH_DIR that finished audio files are moved into.
+const (
+	watchProcessedDir = "processed"
+	watchFailedDir    = "failed"
+)
+
+// watchExtensions are the file extensions picked up from WATCH_DIR.
+var watchExtensions = map[string]bool{
+	".wav": true, ".mp3": true, ".flac": true, ".ogg": true, ".oga": true, ".opus": true,
+	".m4a": true, ".aac": true, ".amr": true, ".wma": true, ".webm": true, ".mp4": true,
+	".mkv": true, ".mov": true,
+}
+
+// fileState is the size and modification time of a file at the last scan.
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// folderWatcher polls a directory for new audio files and transcribes them
+// one at a time. Transcripts are written next to the audio once it is moved
+// to processed/; audio that fails goes to failed/ with a .error file.
+type folderWatcher struct {
+	dir        string
+	formats    []string // outputText, outputJSON, outputSRT
+	opts       moonshine.Options
+	transcribe func(context.Context, string, moonshine.Options) (TranscribeResponse, int)
+
+	seen map[string]fileState // files not yet stable, by name
+}
+
+// newFolderWatcher creates the processed/ and failed/ subfolders of dir.
+func newFolderWatcher(dir string, formats []string, opts moonshine.Options) (*folderWatcher, error) {
+	for _, sub := range []string{watchProcessedDir, watchFailedDir} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return nil, err
+		}
+	}
+	return &folderWatcher{
+		dir:        dir,
+		formats:    formats,
+		opts:       opts,
+		transcribe: transcribeFile,
+		seen:       make(map[string]fileState),
+	}, nil
+}
+
+// run scans the directory every interval until ctx is done. A file being
+// transcribed when ctx ends is left in place and picked up on the next start.
+func (w *folderWatcher) run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		for _, name := range w.scan() {
+			if ctx.Err() != nil {
+				return
+			}
+			w.process(ctx, name)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// scan returns the audio files whose size and modification time did not
+// change since the previous scan, so files still being copied are skipped.
+func (w *folderWatcher) scan() []string {
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		log.Printf("WARNING: watch %s: %v", w.dir, err)
+		return nil
+	}
+	var ready []string
+	current := make(map[string]fileState)
+	for _, e := range entries {
+		name := e.Name()
+		if !e.Type().IsRegular() || strings.HasPrefix(name, ".") ||
+			!watchExtensions[strings.ToLower(filepath.Ext(name))] {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		st := fileState{size: info.Size(), modTime: info.ModTime()}
+		if prev, ok := w.seen[name]; ok && prev == st {
+			ready = append(ready, name)
+			continue
+		}
+		current[name] = st
+	}
+	w.seen = current
+	return ready
+}
+
+// process transcribes one file from the watch directory and moves it, with
+// its transcripts or error, to processed/ or failed/.
+func (w *folderWatcher) process(ctx context.Context, name string) {
+	path := filepath.Join(w.dir, name)
+	start := time.Now()
+	reqCtx, cancel := withRequestTimeout(ctx)
+	opts := w.opts
+	audioID := retainAudio(reqCtx, path, &opts)
+	resp, status := w.transcribe(reqCtx, path, opts)
+	resp.AudioID = audioID
+	cancel()
+	if ctx.Err() != nil {
+		return // shutting down; retry on the next start
+	}
+
+	var err error
+	if status != http.StatusOK {
+		err = fmt.Errorf("%d: %s", status, resp.Error)
+	} else {
+		recordTranscript(ctx, sourceWatch, name, TranscribeRequest{AudioPath: path, Language: w.opts.Lang}, &resp)
+		err = w.writeOutputs(filepath.Join(w.dir, watchProcessedDir), name, resp)
+	}
+	if err != nil {
+		log.Printf("WARNING: watch %s failed: %v", name, err)
+		w.fail(name, err)
+		return
+	}
+	if err := os.Rename(path, filepath.Join(w.dir, watchProcessedDir, name)); err != nil {
+		log.Printf("WARNING: watch %s: %v", name, err)
+		return
+	}
+	log.Printf("watch %s transcribed in %.2fs", name, time.Since(start).Seconds())
+}
+
+// writeOutputs writes resp to dir as <base>.<format> for each format. Each
+// file is renamed into place so readers never see a partial transcript.
+func (w *folderWatcher) writeOutputs(dir, name string, resp TranscribeResponse) error {
+	base := strings.TrimSuffix(name, filepath.Ext(name))
+	for _, format := range w.formats {
+		data, err := renderTranscript(format, name, resp)
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(dir, base+"."+format)
+		if err := os.WriteFile(dst+".tmp", data, 0o644); err != nil {
+			return err
+		}
+		if err := os.Rename(dst+".tmp", dst); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fail moves name to failed/ next to a <base>.error file describing cause.
+func (w *folderWatcher) fail(name string, cause error) {
+	failed := filepath.Join(w.dir, watchFailedDir)
+	base := strings.TrimSuffix(name, filepath.Ext(name))
+	if err := os.WriteFile(filepath.Join(failed, base+".error"), []byte(cause.Error()+"\n"), 0o644); err != nil {
+		log.Printf("WARNING: watch %s: %v", name, err)
+	}
+	if err := os.Rename(filepath.Join(w.dir, name), filepath.Join(failed, name)); err != nil {
+		log.Printf("WARNING: watch %s: %v", name, err)
+	}
+}
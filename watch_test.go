@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anatolykoptev/moonshine-whisper/pkg/moonshine"
+)
+
+func newTestWatcher(t *testing.T, resp TranscribeResponse, status int) *folderWatcher {
+	t.Helper()
+	w, err := newFolderWatcher(t.TempDir(), []string{outputText, outputSRT}, moonshine.Options{Lang: "en"})
+	if err != nil {
+		t.Fatalf("newFolderWatcher: %v", err)
+	}
+	w.transcribe = func(context.Context, string, moonshine.Options) (TranscribeResponse, int) {
+		return resp, status
+	}
+	return w
+}
+
+func writeWatchFile(t *testing.T, w *folderWatcher, name, data string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(w.dir, name), []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// --- folderWatcher.scan ---
+
+func TestFolderWatcher_ScanWaitsForStableFiles(t *testing.T) {
+	w := newTestWatcher(t, TranscribeResponse{}, http.StatusOK)
+	writeWatchFile(t, w, "call.wav", "RIFF")
+	writeWatchFile(t, w, "notes.txt", "ignored")
+	writeWatchFile(t, w, ".partial.wav", "ignored")
+
+	if got := w.scan(); len(got) != 0 {
+		t.Fatalf("first scan = %v, want nothing until the file is stable", got)
+	}
+	if got := w.scan(); len(got) != 1 || got[0] != "call.wav" {
+		t.Fatalf("second scan = %v, want [call.wav]", got)
+	}
+
+	// A file still growing is not ready.
+	writeWatchFile(t, w, "growing.mp3", "a")
+	w.scan()
+	writeWatchFile(t, w, "growing.mp3", "ab")
+	for _, name := range w.scan() {
+		if name == "growing.mp3" {
+			t.Error("growing file reported ready")
+		}
+	}
+}
+
+// --- folderWatcher.process ---
+
+func TestFolderWatcher_ProcessSuccess(t *testing.T) {
+	w := newTestWatcher(t, TranscribeResponse{Text: "hello world", AudioS: 2}, http.StatusOK)
+	writeWatchFile(t, w, "call.ogg", "audio")
+	w.process(context.Background(), "call.ogg")
+
+	processed := filepath.Join(w.dir, watchProcessedDir)
+	if _, err := os.Stat(filepath.Join(processed, "call.ogg")); err != nil {
+		t.Errorf("audio not moved to processed/: %v", err)
+	}
+	txt, err := os.ReadFile(filepath.Join(processed, "call.txt"))
+	if err != nil || string(txt) != "hello world\n" {
+		t.Errorf("call.txt = %q, %v", txt, err)
+	}
+	srt, err := os.ReadFile(filepath.Join(processed, "call.srt"))
+	if err != nil || !strings.Contains(string(srt), "00:00:02,000") {
+		t.Errorf("call.srt = %q, %v", srt, err)
+	}
+	if _, err := os.Stat(filepath.Join(processed, "call.json")); !os.IsNotExist(err) {
+		t.Error("json written although not in formats")
+	}
+}
+
+func TestFolderWatcher_ProcessFailure(t *testing.T) {
+	w := newTestWatcher(t, TranscribeResponse{Error: "audio too long"}, http.StatusBadRequest)
+	writeWatchFile(t, w, "long.wav", "audio")
+	w.process(context.Background(), "long.wav")
+
+	failed := filepath.Join(w.dir, watchFailedDir)
+	if _, err := os.Stat(filepath.Join(failed, "long.wav")); err != nil {
+		t.Errorf("audio not moved to failed/: %v", err)
+	}
+	msg, err := os.ReadFile(filepath.Join(failed, "long.error"))
+	if err != nil || !strings.Contains(string(msg), "audio too long") {
+		t.Errorf("long.error = %q, %v", msg, err)
+	}
+}
+
+func TestFolderWatcher_ProcessCanceledLeavesFile(t *testing.T) {
+	w := newTestWatcher(t, TranscribeResponse{}, statusClientClosedRequest)
+	writeWatchFile(t, w, "call.wav", "audio")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w.process(ctx, "call.wav")
+	if _, err := os.Stat(filepath.Join(w.dir, "call.wav")); err != nil {
+		t.Errorf("canceled file should stay in place: %v", err)
+	}
+}
+
+// --- folderWatcher.run ---
+
+func TestFolderWatcher_Run(t *testing.T) {
+	w := newTestWatcher(t, TranscribeResponse{Text: "hi"}, http.StatusOK)
+	writeWatchFile(t, w, "a.wav", "audio")
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		w.run(ctx, 10*time.Millisecond)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, err := os.Stat(filepath.Join(w.dir, watchProcessedDir, "a.txt")); err == nil {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("watched file was not processed")
+}
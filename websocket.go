@@ -0,0 +1,312 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The server side of the WebSocket protocol (RFC 6455), as much as
+// /transcribe/stream needs to serve browsers: binary audio in, JSON text
+// messages out.
+
+// websocketGUID is appended to the client key to compute the handshake
+// accept value.
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// maxWebSocketFrame bounds the payload of one client frame.
+const maxWebSocketFrame = 1 << 20
+
+// WebSocket opcodes.
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xa
+)
+
+// WebSocket close codes.
+const (
+	wsCloseNormal        = 1000
+	wsCloseProtocolError = 1002
+	wsCloseUnsupported   = 1003
+	wsCloseTooBig        = 1009
+	wsCloseInternalError = 1011
+)
+
+// wsEndMessage is the text message that ends the audio of a socket.
+const wsEndMessage = "end"
+
+// wsCloseError is a protocol violation by the client, which closes the
+// socket with Code.
+type wsCloseError struct {
+	Code int
+	Msg  string
+}
+
+func (e *wsCloseError) Error() string { return "websocket: " + e.Msg }
+
+// isWebSocketUpgrade reports whether r asks to open a WebSocket.
+func isWebSocketUpgrade(r *http.Request) bool {
+	if r.Method != http.MethodGet || !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return false
+	}
+	for _, v := range r.Header.Values("Connection") {
+		for _, token := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// webSocketOriginAllowed reports whether a browser on the Origin of r may
+// open a WebSocket: same-origin pages, and those of CORS_ALLOWED_ORIGINS.
+// Browsers do not apply CORS to WebSockets, so the server has to.
+func webSocketOriginAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true // not a browser
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	return originAllowed(origin, cfg.CORSAllowedOrigins)
+}
+
+// wsConn is the server end of an open WebSocket. Read returns the payload
+// of binary messages as one byte stream, answering pings on the way, until
+// the client sends the text message "end" or closes the socket. Writes may
+// come from several goroutines.
+type wsConn struct {
+	conn net.Conn
+	br   io.Reader
+
+	rmu       sync.Mutex // held by Read
+	err       error      // sticky read error
+	remaining int64      // unread payload of the current binary frame
+	mask      [4]byte
+	maskPos   int
+	binary    bool // continuation frames extend a binary message
+	peerDone  bool // the client sent a close frame
+
+	wmu    sync.Mutex // serializes frame writes
+	closed bool       // a close frame was sent
+}
+
+// acceptWebSocket completes the opening handshake of r and takes over its
+// connection. On failure it writes the error response and returns ok false.
+func acceptWebSocket(w http.ResponseWriter, r *http.Request) (*wsConn, bool) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Header.Get("Sec-WebSocket-Version") != "13" || key == "" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		writeError(w, http.StatusBadRequest, "WebSocket version 13 with a Sec-WebSocket-Key required")
+		return nil, false
+	}
+	conn, brw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "websocket: "+err.Error())
+		return nil, false
+	}
+	// The server's timeouts no longer apply; the caller bounds the socket.
+	conn.SetDeadline(time.Time{}) //nolint:errcheck
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	h := w.Header().Clone() // X-Request-ID and the CORS headers
+	h.Set("Upgrade", "websocket")
+	h.Set("Connection", "Upgrade")
+	h.Set("Sec-WebSocket-Accept", base64.StdEncoding.EncodeToString(sum[:]))
+	brw.WriteString("HTTP/1.1 101 Switching Protocols\r\n") //nolint:errcheck
+	h.Write(brw)                                            //nolint:errcheck
+	brw.WriteString("\r\n")                                 //nolint:errcheck
+	if err := brw.Flush(); err != nil {
+		conn.Close() //nolint:errcheck
+		return nil, false
+	}
+	return &wsConn{conn: conn, br: brw.Reader}, true
+}
+
+// Read reads audio bytes from the binary messages of the client. It
+// returns io.EOF once the client ends the audio or closes the socket, and a
+// *wsCloseError for a protocol violation.
+func (c *wsConn) Read(p []byte) (int, error) {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+	for c.remaining == 0 {
+		if c.err != nil {
+			return 0, c.err
+		}
+		c.err = c.nextFrame()
+	}
+	if int64(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+	n, err := c.br.Read(p)
+	for i := range n {
+		p[i] ^= c.mask[c.maskPos%4]
+		c.maskPos++
+	}
+	c.remaining -= int64(n)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF // the connection ended mid-frame
+	}
+	if err != nil {
+		c.err = err
+	}
+	return n, err
+}
+
+// nextFrame reads the header of the next frame, and control and text frames
+// whole. After a binary frame header, c.remaining is its payload length.
+func (c *wsConn) nextFrame() error {
+	var h [2]byte
+	if _, err := io.ReadFull(c.br, h[:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF // dropped without a close frame
+		}
+		return err
+	}
+	fin, op := h[0]&0x80 != 0, h[0]&0x0f
+	if h[0]&0x70 != 0 {
+		return &wsCloseError{wsCloseProtocolError, "reserved bits set"}
+	}
+	if h[1]&0x80 == 0 {
+		return &wsCloseError{wsCloseProtocolError, "client frames must be masked"}
+	}
+	n := uint64(h[1] & 0x7f)
+	switch n {
+	case 126:
+		var b [2]byte
+		if _, err := io.ReadFull(c.br, b[:]); err != nil {
+			return err
+		}
+		n = uint64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		if _, err := io.ReadFull(c.br, b[:]); err != nil {
+			return err
+		}
+		n = binary.BigEndian.Uint64(b[:])
+	}
+	if n > maxWebSocketFrame {
+		return &wsCloseError{wsCloseTooBig, "frame larger than 1 MiB"}
+	}
+	if _, err := io.ReadFull(c.br, c.mask[:]); err != nil {
+		return err
+	}
+	c.maskPos = 0
+
+	switch op {
+	case wsBinary, wsContinuation:
+		if op == wsContinuation && !c.binary {
+			return &wsCloseError{wsCloseProtocolError, "unexpected continuation frame"}
+		}
+		c.binary = !fin
+		c.remaining = int64(n)
+		return nil
+	case wsText:
+		if !fin {
+			return &wsCloseError{wsCloseUnsupported, "fragmented text messages are not supported"}
+		}
+	case wsClose, wsPing, wsPong:
+		if !fin || n > 125 {
+			return &wsCloseError{wsCloseProtocolError, "invalid control frame"}
+		}
+	default:
+		return &wsCloseError{wsCloseProtocolError, "unknown opcode"}
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return err
+	}
+	for i := range payload {
+		payload[i] ^= c.mask[i%4]
+	}
+	switch op {
+	case wsText:
+		if string(payload) != wsEndMessage {
+			return &wsCloseError{wsCloseUnsupported, `audio must be sent as binary messages; send the text message "end" to finish`}
+		}
+		return io.EOF
+	case wsPing:
+		c.write(wsPong, payload) //nolint:errcheck // a write error surfaces on the next write
+	case wsClose:
+		c.peerDone = true
+		c.write(wsClose, payload) //nolint:errcheck // echo the close
+		return io.EOF
+	}
+	return nil // pong
+}
+
+// write sends one unfragmented frame. Frames after a close are dropped.
+func (c *wsConn) write(op byte, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.closed {
+		return net.ErrClosed
+	}
+	c.closed = op == wsClose
+	hdr := []byte{0x80 | op}
+	switch n := len(payload); {
+	case n < 126:
+		hdr = append(hdr, byte(n))
+	case n <= 0xffff:
+		hdr = binary.BigEndian.AppendUint16(append(hdr, 126), uint16(n))
+	default:
+		hdr = binary.BigEndian.AppendUint64(append(hdr, 127), uint64(n))
+	}
+	c.conn.SetWriteDeadline(time.Now().Add(serverTimeout)) //nolint:errcheck
+	_, err := (&net.Buffers{hdr, payload}).WriteTo(c.conn)
+	return err
+}
+
+// writeJSON sends v as a text message.
+func (c *wsConn) writeJSON(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.write(wsText, data)
+}
+
+// readErr returns the error that ended reading, if any.
+func (c *wsConn) readErr() error {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+	return c.err
+}
+
+// shutdown sends a close frame with code and reason, waits up to a second
+// for the client's close frame, discarding what comes before it, and
+// closes the connection.
+func (c *wsConn) shutdown(code int, reason string) {
+	if len(reason) > 123 {
+		reason = reason[:123]
+	}
+	c.write(wsClose, append(binary.BigEndian.AppendUint16(nil, uint16(code)), reason...)) //nolint:errcheck
+	c.conn.SetReadDeadline(time.Now())                                                    //nolint:errcheck // unblock a pending Read
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+	c.conn.SetReadDeadline(time.Now().Add(time.Second)) //nolint:errcheck
+	for !c.peerDone {
+		if c.remaining > 0 {
+			n, err := io.CopyN(io.Discard, c.br, c.remaining)
+			if c.remaining -= n; err != nil {
+				break
+			}
+		} else if err := c.nextFrame(); err != nil && err != io.EOF {
+			break
+		}
+	}
+	c.conn.Close() //nolint:errcheck
+}
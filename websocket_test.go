@@ -0,0 +1,352 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anatolykoptev/moonshine-whisper/pkg/client"
+)
+
+// wsDial opens a WebSocket to path on srv by hand, without a client
+// library, and returns the connection and its reader.
+func wsDial(t *testing.T, srv *httptest.Server, path string, header http.Header) (net.Conn, *bufio.Reader, *http.Response) {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(10 * time.Second)) //nolint:errcheck
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn, br, resp
+}
+
+// wsSend writes one client frame, masked unless unmasked is set.
+func wsSend(t *testing.T, conn net.Conn, first byte, payload []byte, unmasked bool) {
+	t.Helper()
+	if _, err := conn.Write(wsFrame(first, payload, unmasked)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// wsFrame encodes one client frame, masked unless unmasked is set.
+func wsFrame(first byte, payload []byte, unmasked bool) []byte {
+	var hdr []byte
+	maskBit := byte(0x80)
+	if unmasked {
+		maskBit = 0
+	}
+	switch n := len(payload); {
+	case n < 126:
+		hdr = []byte{first, maskBit | byte(n)}
+	case n <= 0xffff:
+		hdr = binary.BigEndian.AppendUint16([]byte{first, maskBit | 126}, uint16(n))
+	default:
+		hdr = binary.BigEndian.AppendUint64([]byte{first, maskBit | 127}, uint64(n))
+	}
+	data := append([]byte(nil), payload...)
+	if !unmasked {
+		mask := []byte{1, 2, 3, 4}
+		hdr = append(hdr, mask...)
+		for i := range data {
+			data[i] ^= mask[i%4]
+		}
+	}
+	return append(hdr, data...)
+}
+
+// wsRecv reads one unmasked server frame.
+func wsRecv(t *testing.T, br *bufio.Reader) (op byte, payload []byte) {
+	t.Helper()
+	var h [2]byte
+	if _, err := io.ReadFull(br, h[:]); err != nil {
+		t.Fatal(err)
+	}
+	n := uint64(h[1] & 0x7f)
+	switch n {
+	case 126:
+		var b [2]byte
+		io.ReadFull(br, b[:]) //nolint:errcheck
+		n = uint64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		io.ReadFull(br, b[:]) //nolint:errcheck
+		n = binary.BigEndian.Uint64(b[:])
+	}
+	payload = make([]byte, n)
+	if _, err := io.ReadFull(br, payload); err != nil {
+		t.Fatal(err)
+	}
+	return h[0] & 0x0f, payload
+}
+
+// wsSumServer accepts WebSockets, reads the audio to the end, and answers
+// with its length.
+func wsSumServer(t *testing.T) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-ID", "req-1")
+		conn, ok := acceptWebSocket(w, r)
+		if !ok {
+			return
+		}
+		data, err := io.ReadAll(conn)
+		conn.writeJSON(map[string]any{"bytes": len(data), "sum": string(data)}) //nolint:errcheck
+		if cerr, ok := err.(*wsCloseError); ok {
+			conn.shutdown(cerr.Code, cerr.Msg)
+			return
+		}
+		conn.shutdown(wsCloseNormal, "")
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// --- acceptWebSocket ---
+
+func TestAcceptWebSocket(t *testing.T) {
+	srv := wsSumServer(t)
+	conn, br, resp := wsDial(t, srv, "/", nil)
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("status = %d, want 101", resp.StatusCode)
+	}
+	// The example key of RFC 6455, section 1.3.
+	if got := resp.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("Sec-WebSocket-Accept = %q", got)
+	}
+	if got := resp.Header.Get("X-Request-ID"); got != "req-1" {
+		t.Errorf("X-Request-ID = %q, want the handler's headers kept", got)
+	}
+
+	// A half-duplex client: send all of the audio, then read the answers.
+	wsSend(t, conn, 0x00|wsBinary, []byte("abc"), false) // not final
+	wsSend(t, conn, 0x80|wsPing, []byte("hi"), false)
+	wsSend(t, conn, 0x80|wsContinuation, []byte("def"), false)
+	wsSend(t, conn, 0x80|wsBinary, []byte(strings.Repeat("g", 300)), false)
+	wsSend(t, conn, 0x80|wsText, []byte("end"), false)
+
+	if op, p := wsRecv(t, br); op != wsPong || string(p) != "hi" {
+		t.Errorf("frame 1 = %x %q, want the pong", op, p)
+	}
+	op, p := wsRecv(t, br)
+	var got struct {
+		Bytes int    `json:"bytes"`
+		Sum   string `json:"sum"`
+	}
+	if op != wsText || json.Unmarshal(p, &got) != nil || got.Bytes != 306 || !strings.HasPrefix(got.Sum, "abcdefg") {
+		t.Errorf("frame 2 = %x %q, want the 306 bytes of audio, unmasked", op, p)
+	}
+	if op, p := wsRecv(t, br); op != wsClose || binary.BigEndian.Uint16(p) != wsCloseNormal {
+		t.Errorf("frame 3 = %x %q, want a normal close", op, p)
+	}
+	wsSend(t, conn, 0x80|wsClose, binary.BigEndian.AppendUint16(nil, wsCloseNormal), false)
+	if _, err := br.ReadByte(); err != io.EOF {
+		t.Errorf("after the close handshake: %v, want the connection closed", err)
+	}
+}
+
+func TestAcceptWebSocket_ClientClose(t *testing.T) {
+	srv := wsSumServer(t)
+	conn, br, _ := wsDial(t, srv, "/", nil)
+	wsSend(t, conn, 0x80|wsBinary, []byte("abc"), false)
+	wsSend(t, conn, 0x80|wsClose, binary.BigEndian.AppendUint16(nil, wsCloseNormal), false)
+	if op, p := wsRecv(t, br); op != wsClose || binary.BigEndian.Uint16(p) != wsCloseNormal {
+		t.Errorf("frame 1 = %x %q, want the close echoed", op, p)
+	}
+	// Nothing may follow a close frame.
+	if _, err := br.ReadByte(); err != io.EOF {
+		t.Errorf("after the close: %v, want the connection closed", err)
+	}
+}
+
+func TestAcceptWebSocket_ProtocolErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		first    byte
+		payload  []byte
+		unmasked bool
+		want     uint16
+	}{
+		{"unmasked", 0x80 | wsBinary, []byte("abc"), true, wsCloseProtocolError},
+		{"text", 0x80 | wsText, []byte("hello"), false, wsCloseUnsupported},
+		{"too big", 0x80 | wsBinary, make([]byte, maxWebSocketFrame+1), false, wsCloseTooBig},
+		{"continuation", 0x80 | wsContinuation, []byte("abc"), false, wsCloseProtocolError},
+		{"reserved bits", 0xc0 | wsBinary, []byte("abc"), false, wsCloseProtocolError},
+	}
+	for _, tt := range tests {
+		srv := wsSumServer(t)
+		conn, br, _ := wsDial(t, srv, "/", nil)
+		go conn.Write(wsFrame(tt.first, tt.payload, tt.unmasked)) //nolint:errcheck // the server may stop reading
+		wsRecv(t, br)                                             // the bytes read
+		op, p := wsRecv(t, br)
+		if op != wsClose || len(p) < 2 || binary.BigEndian.Uint16(p) != tt.want {
+			t.Errorf("%s: frame = %x %q, want close %d", tt.name, op, p, tt.want)
+		}
+	}
+}
+
+func TestAcceptWebSocket_BadHandshake(t *testing.T) {
+	srv := wsSumServer(t)
+	_, _, resp := wsDial(t, srv, "/", http.Header{"Sec-Websocket-Version": {"8"}})
+	if resp.StatusCode != http.StatusBadRequest || resp.Header.Get("Sec-WebSocket-Version") != "13" {
+		t.Errorf("version 8: %d %v, want 400 advertising 13", resp.StatusCode, resp.Header)
+	}
+}
+
+// --- client.Stream ---
+
+func TestStreamClient(t *testing.T) {
+	srv := wsSumServer(t)
+	ws, err := (&client.Client{BaseURL: srv.URL}).Dial(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close() //nolint:errcheck
+
+	audio := bytes.Repeat([]byte("0123456789"), 1000) // several audio messages
+	if err := ws.SendAudio(bytes.NewReader(audio)); err != nil {
+		t.Fatal(err)
+	}
+	msg, err := ws.ReadMessage()
+	var got struct {
+		Bytes int    `json:"bytes"`
+		Sum   string `json:"sum"`
+	}
+	// The server closes on an unmasked frame, so the audio arriving whole
+	// shows that the client masked it.
+	if err != nil || json.Unmarshal(msg, &got) != nil || got.Bytes != len(audio) || got.Sum != string(audio) {
+		t.Errorf("message = %.60q, %v; want the %d bytes of audio", msg, err, len(audio))
+	}
+	if _, err := ws.ReadMessage(); err != io.EOF || ws.CloseCode != client.CloseNormal {
+		t.Errorf("after the results: %v, close %d; want io.EOF, close 1000", err, ws.CloseCode)
+	}
+}
+
+func TestStreamClient_FragmentsAndClose(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, ok := acceptWebSocket(w, r)
+		if !ok {
+			return
+		}
+		// A text message in three fragments, with a ping between them.
+		conn.conn.Write(slices.Concat( //nolint:errcheck
+			wsFrame(wsText, []byte(`{"text":`), true),
+			wsFrame(0x80|wsPing, []byte("hi"), true),
+			wsFrame(wsContinuation, []byte(`"hel`), true),
+			wsFrame(0x80|wsContinuation, []byte(`lo"}`), true),
+		))
+		data, _ := io.ReadAll(conn)
+		conn.writeJSON(map[string]any{"bytes": len(data)}) //nolint:errcheck
+		conn.shutdown(wsCloseInternalError, "decoder failed")
+	}))
+	defer srv.Close()
+	ws, err := (&client.Client{BaseURL: srv.URL}).Dial(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close() //nolint:errcheck
+
+	if msg, err := ws.ReadMessage(); err != nil || string(msg) != `{"text":"hello"}` {
+		t.Errorf("message 1 = %q, %v; want the fragments joined", msg, err)
+	}
+	if err := ws.SendAudio(strings.NewReader("xyz")); err != nil {
+		t.Fatal(err)
+	}
+	if msg, err := ws.ReadMessage(); err != nil || string(msg) != `{"bytes":3}` {
+		t.Errorf("message 2 = %q, %v; want the audio after the pong", msg, err)
+	}
+	if _, err := ws.ReadMessage(); err != io.EOF || ws.CloseCode != wsCloseInternalError || ws.CloseReason != "decoder failed" {
+		t.Errorf("close = %v, %d %q; want io.EOF, 1011 decoder failed", err, ws.CloseCode, ws.CloseReason)
+	}
+}
+
+func TestStreamClient_ConnectionLost(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if conn, ok := acceptWebSocket(w, r); ok {
+			conn.conn.Close() //nolint:errcheck // without a close frame
+		}
+	}))
+	defer srv.Close()
+	ws, err := (&client.Client{BaseURL: srv.URL}).Dial(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close() //nolint:errcheck
+	if _, err := ws.ReadMessage(); err == nil || err == io.EOF {
+		t.Errorf("ReadMessage = %v, want an error other than io.EOF", err)
+	}
+}
+
+// --- isWebSocketUpgrade ---
+
+func TestIsWebSocketUpgrade(t *testing.T) {
+	tests := []struct {
+		method, upgrade, connection string
+		want                        bool
+	}{
+		{http.MethodGet, "websocket", "Upgrade", true},
+		{http.MethodGet, "WebSocket", "keep-alive, Upgrade", true},
+		{http.MethodGet, "websocket", "keep-alive", false},
+		{http.MethodGet, "h2c", "Upgrade", false},
+		{http.MethodPost, "websocket", "Upgrade", false},
+		{http.MethodGet, "", "", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, "/transcribe/stream", nil)
+		r.Header.Set("Upgrade", tt.upgrade)
+		r.Header.Set("Connection", tt.connection)
+		if got := isWebSocketUpgrade(r); got != tt.want {
+			t.Errorf("isWebSocketUpgrade(%s, %q, %q) = %v, want %v", tt.method, tt.upgrade, tt.connection, got, tt.want)
+		}
+	}
+}
+
+// --- webSocketOriginAllowed ---
+
+func TestWebSocketOriginAllowed(t *testing.T) {
+	defer func(o []string) { cfg.CORSAllowedOrigins = o }(cfg.CORSAllowedOrigins)
+	cfg.CORSAllowedOrigins = []string{"https://app.example.com"}
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"", true},
+		{"http://stt.local:8092", true},
+		{"https://app.example.com", true},
+		{"https://evil.example.com", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "http://stt.local:8092/transcribe/stream", nil)
+		if tt.origin != "" {
+			r.Header.Set("Origin", tt.origin)
+		}
+		if got := webSocketOriginAllowed(r); got != tt.want {
+			t.Errorf("webSocketOriginAllowed(%q) = %v, want %v", tt.origin, got, tt.want)
+		}
+	}
+}